@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/caicloud/cyclone/pkg/workflow/resolver"
+	"github.com/caicloud/cyclone/pkg/workflow/resolver/git"
+	"github.com/caicloud/cyclone/pkg/workflow/resolver/helm"
+	"github.com/caicloud/cyclone/pkg/workflow/resolver/http"
+	"github.com/caicloud/cyclone/pkg/workflow/resolver/image"
+	"github.com/caicloud/cyclone/pkg/workflow/resolver/kv"
+	"github.com/caicloud/cyclone/pkg/workflow/resolver/maven"
+	"github.com/caicloud/cyclone/pkg/workflow/resolver/s3"
+	"github.com/caicloud/cyclone/pkg/workflow/resolver/svn"
+)
+
+// resolvers are built-in resource resolvers, keyed by resource type in lower case.
+var resolvers = map[string]resolver.Resolver{
+	"git":   &git.Resolver{},
+	"svn":   &svn.Resolver{},
+	"image": &image.Resolver{},
+	"kv":    &kv.Resolver{},
+	"http":  &http.Resolver{},
+	"s3":    &s3.Resolver{},
+	"helm":  &helm.Resolver{},
+	"maven": &maven.Resolver{},
+}
+
+// Usage: resolver <git|svn|image|kv|http|s3|helm|maven> <pull|push|help>
+func main() {
+	flag.Parse()
+
+	r, ok := resolvers[flag.Arg(0)]
+	if !ok {
+		fmt.Printf("Usage: %s <git|svn|image|kv|http|s3|helm|maven> <pull|push|help>\n", os.Args[0])
+		os.Exit(1)
+	}
+
+	command := flag.Arg(1)
+	if command == "" {
+		command = "help"
+	}
+	if err := resolver.Run(r, command); err != nil {
+		log.WithField("resolver", flag.Arg(0)).WithField("command", command).Error("Resolve resource error: ", err)
+		os.Exit(1)
+	}
+}
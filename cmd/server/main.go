@@ -27,6 +27,8 @@ import (
 	"github.com/caicloud/cyclone/pkg/server/handler/v1alpha1"
 	"github.com/caicloud/cyclone/pkg/server/version"
 
+	"github.com/caicloud/cyclone/pkg/server/biz/audit"
+	"github.com/caicloud/cyclone/pkg/server/biz/auth"
 	"github.com/caicloud/cyclone/pkg/server/biz/tenants"
 	"github.com/caicloud/nirvana"
 	nconfig "github.com/caicloud/nirvana/config"
@@ -87,6 +89,21 @@ func initialize(opts *APIServerOptions) {
 		log.Fatalf("Create default tenant cyclone error %v", err)
 	}
 	tenants.InitStageTemplates("")
+
+	if config.AuthEnabled {
+		if err := auth.EnsureAdminUser(config.AdminPassword); err != nil {
+			log.Fatalf("Create admin user error: %v", err)
+		}
+		if err := auth.InitConnectors(config.IdentityProviders); err != nil {
+			log.Fatalf("Init identity providers error: %v", err)
+		}
+	} else {
+		log.Warning("Authentication disabled, all API requests are allowed")
+	}
+
+	if err := audit.Init(config.AuditSink, config.AuditFile); err != nil {
+		log.Fatalf("Init audit sink error: %v", err)
+	}
 }
 
 func main() {
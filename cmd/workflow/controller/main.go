@@ -53,7 +53,7 @@ func main() {
 	go wftController.Run(ctx.Done())
 
 	// Create and start WorkflowRun controller.
-	wfrController := controllers.NewWorkflowRunController(client)
+	wfrController := controllers.NewWorkflowRunController(client, *namespace)
 	go wfrController.Run(ctx.Done())
 
 	// Create and start Pod controller.
@@ -4,20 +4,59 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"os/exec"
+	"path"
+	"syscall"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
 
 	k8sclient "github.com/caicloud/cyclone/pkg/common"
+	"github.com/caicloud/cyclone/pkg/workflow/artifact"
+	"github.com/caicloud/cyclone/pkg/workflow/common"
 	"github.com/caicloud/cyclone/pkg/workflow/coordinator"
 )
 
 var kubeConfigPath = flag.String("kubeconfig", "", "Path to kubeconfig. Only required if out-of-cluster.")
 
+// logsTimeout is the max time to wait logs pushed to Cyclone server before coordinator exits.
+const logsTimeout = 2 * time.Minute
+
 func main() {
 	flag.Parse()
 
+	// Run as artifact fetcher to fetch input artifacts from artifact store.
+	if flag.Arg(0) == common.ArtifactFetcherCommand {
+		if err := fetchArtifacts(); err != nil {
+			log.Errorf("Fetch input artifacts error: %v", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Run as artifact reader to serve artifacts in PVC to Cyclone Server.
+	if flag.Arg(0) == common.ArtifactReaderCommand {
+		store := artifact.NewPVCStore(common.ArtifactReaderPVCPath, "")
+		addr := fmt.Sprintf(":%d", common.ArtifactReaderPort)
+		log.Infof("Serve artifacts in PVC on %s", addr)
+		handler := artifact.NewHandler(store, os.Getenv(common.EnvArtifactReaderToken))
+		if err := artifact.Serve(addr, handler, common.ArtifactReaderIdleTimeout); err != nil {
+			log.Errorf("Serve artifacts error: %v", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Run as sidecar gate in workload container to run the workload after sidecars ready.
+	if flag.Arg(0) == common.SidecarGateCommand {
+		if err := waitSidecars(flag.Args()[1:]); err != nil {
+			log.Errorf("Run workload error: %v", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	var err error
 	var message string
 
@@ -36,15 +75,30 @@ func main() {
 	}
 
 	defer func() {
+		// Stop workload sidecars if the stage failed before they're stopped.
+		if e := c.StopSidecars(); e != nil {
+			log.Warningf("Stop workload sidecars error: %v", e)
+		}
+
+		// Wait logs to be pushed, otherwise tail of the logs would be lost.
+		if !c.WaitLogs(logsTimeout) {
+			log.Warningf("Logs not pushed completely in %s", logsTimeout)
+		}
+
+		c.StopSampling()
+		if e := c.WriteTerminationMessage(message); e != nil {
+			log.Warningf("Write termination message error: %v", e)
+		}
+
 		if err != nil {
 			log.Error(message)
-			c.Recorder.Eventf(c.Wfr, corev1.EventTypeWarning, "StageFailed", message)
+			c.Recorder.Event(c.Wfr, corev1.EventTypeWarning, "StageFailed", message)
 			// Wait for sending event
 			time.Sleep(1 * time.Second)
 			os.Exit(1)
 		} else {
 			log.Info(message)
-			c.Recorder.Eventf(c.Wfr, corev1.EventTypeNormal, "StageSucceeded", message)
+			c.Recorder.Event(c.Wfr, corev1.EventTypeNormal, "StageSucceeded", message)
 			// Wait for sending event
 			time.Sleep(1 * time.Second)
 			os.Exit(0)
@@ -53,20 +107,51 @@ func main() {
 
 	// Wait all containers running, so we can start to collect logs.
 	log.Info("Wait all containers running ... ")
-	c.WaitRunning()
+	err = c.WaitRunning()
+	if err != nil {
+		message = fmt.Sprintf("Stage %s failed to wait containers running, error: %v", c.Stage.Name, err)
+		return
+	}
+
+	// Sample resource usage of containers until coordinator exits.
+	c.StartSampling()
 
 	// Collect real-time container logs using goroutines.
 	log.Info("Start to collect logs.")
 	c.CollectLogs()
 
+	// Wait workload sidecars ready, so that the workload can start to run.
+	log.Info("Wait workload sidecars ready ... ")
+	err = c.WaitSidecarsReady()
+	if err != nil {
+		message = fmt.Sprintf("Stage %s failed to wait workload sidecars ready, error: %v", c.Stage.Name, err)
+		return
+	}
+
 	// Wait workload containers completion, so we can notify output resolvers.
 	log.Info("Wait workload containers completion ... ")
-	c.WaitWorkloadTerminate()
+	err = c.WaitWorkloadTerminate()
+	if err != nil {
+		message = fmt.Sprintf("Stage %s failed to wait workload completion, error: %v", c.Stage.Name, err)
+		return
+	}
+
+	// Stop workload sidecars since they are not needed after workload finished.
+	log.Info("Stop workload sidecars.")
+	if e := c.StopSidecars(); e != nil {
+		log.Warningf("Stop workload sidecars error: %v", e)
+	}
+
+	// Collect test reports, even if the workload failed.
+	log.Info("Start to collect reports.")
+	if e := c.CollectReports(); e != nil {
+		log.Warningf("Collect reports error: %v", e)
+	}
 
 	// Check if the workload is succeeded.
 	if !c.WorkLoadSuccess() {
 		message = fmt.Sprintf("Stage %s failed, workload exit code is not 0", c.Stage.Name)
-		err = fmt.Errorf(message)
+		err = fmt.Errorf("%s", message)
 		return
 	}
 
@@ -97,7 +182,11 @@ func main() {
 	// Wait all others container completion. Coordinator will be the last one
 	// to quit since it need to collect other containers' logs.
 	log.Info("Wait for all other containers completion ... ")
-	c.WaitAllOthersTerminate()
+	err = c.WaitAllOthersTerminate()
+	if err != nil {
+		message = fmt.Sprintf("Stage %s failed to wait other containers completion, error: %v", c.Stage.Name, err)
+		return
+	}
 
 	// Check if the workload and resolver containers are succeeded.
 	if c.StageSuccess() {
@@ -106,6 +195,51 @@ func main() {
 	}
 
 	message = fmt.Sprintf("Stage %s failed, resolver exit code is not 0", c.Stage.Name)
-	err = fmt.Errorf(message)
+	err = fmt.Errorf("%s", message)
 	return
 }
+
+// fetchArtifacts fetches input artifacts given in environment variable from artifact store.
+func fetchArtifacts() error {
+	config, err := artifact.DecodeConfig(os.Getenv(common.EnvArtifactStore))
+	if err != nil {
+		return err
+	}
+	inputs, err := artifact.DecodeInputs(os.Getenv(common.EnvInputArtifacts))
+	if err != nil {
+		return err
+	}
+	store, err := artifact.NewStore(config, common.ArtifactFetcherPVCPath, "")
+	if err != nil {
+		return err
+	}
+
+	return artifact.Fetch(store, inputs, common.InputArtifactsPath)
+}
+
+// sidecarGatePollInterval is interval to check whether workload sidecars are ready.
+const sidecarGatePollInterval = time.Second
+
+// waitSidecars waits coordinator to notify that workload sidecars are ready, and then replaces
+// itself with the workload command. It exits with error if sidecars fail to get ready.
+func waitSidecars(command []string) error {
+	if len(command) == 0 {
+		return fmt.Errorf("no workload command given")
+	}
+
+	for {
+		if _, err := os.Stat(path.Join(common.SidecarGatePath, common.SidecarReadyFile)); err == nil {
+			break
+		}
+		if _, err := os.Stat(path.Join(common.SidecarGatePath, common.SidecarAbortFile)); err == nil {
+			return fmt.Errorf("workload sidecars failed to get ready")
+		}
+		time.Sleep(sidecarGatePollInterval)
+	}
+
+	binary, err := exec.LookPath(command[0])
+	if err != nil {
+		return err
+	}
+	return syscall.Exec(binary, command, os.Environ())
+}
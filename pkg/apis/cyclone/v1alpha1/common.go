@@ -6,11 +6,12 @@ type ResourceItem struct {
 	Name string `json:"name"`
 	// For input resource, this is the path that resource will be mounted in workload container.
 	// Resolver would resolve resources and mount it in this path.
-	// For output resource, this is the path in the workload container specify output data, for
-	// the moment, only one path can be given. In the resolver container, data specified here would
-	// be mounted in /workspace/data by default. Resolver will then push resource to remote server.
-	// TODO(ChenDe): For output resource, need support multiple paths.
+	// For output resource, this is the path in the workload container specify output data, more
+	// paths can be given in Paths. In the resolver container, data specified here would be mounted
+	// in /workspace/data by default. Resolver will then push resource to remote server.
 	Path string `json:"path"`
+	// OutputOptions configures how to collect output data, only used for output resource.
+	OutputOptions `json:",inline"`
 }
 
 // ArtifactItem defines an artifact
@@ -23,6 +24,74 @@ type ArtifactItem struct {
 	// It's in the format of: <stage name>/<artifact name>
 	// +Optional
 	Source string `json:"source"`
+	// OutputOptions configures how to collect output data, only used for output artifact.
+	OutputOptions `json:",inline"`
+}
+
+// ReportFormat is format of test report.
+type ReportFormat string
+
+const (
+	// ReportJUnit is JUnit XML test report
+	ReportJUnit ReportFormat = "junit"
+	// ReportCobertura is Cobertura XML coverage report
+	ReportCobertura ReportFormat = "cobertura"
+	// ReportGoCover is coverage profile generated by 'go test -coverprofile'
+	ReportGoCover ReportFormat = "go-cover"
+)
+
+// ReportItem defines a test report output. Report files are collected from workload container
+// and parsed by coordinator, results are stored in Cyclone server.
+type ReportItem struct {
+	// Report name
+	Name string `json:"name"`
+	// Format of report files, 'junit', 'cobertura' or 'go-cover'
+	Format ReportFormat `json:"format"`
+	// Path of report file in the workload container
+	Path string `json:"path"`
+	// OutputOptions configures how to collect report files, e.g. glob patterns of multiple
+	// report files. Archive is ignored for reports.
+	OutputOptions `json:",inline"`
+}
+
+// OutputOptions configures how to collect output data from workload container.
+type OutputOptions struct {
+	// Paths are additional paths of the output data besides Path. Glob patterns are supported
+	// in both Path and Paths, and '**' matches zero or more directories, for example,
+	// 'dist/*.tar.gz', 'reports/**/*.xml'. When multiple paths or glob patterns given, matched
+	// files are collected into a directory named after the output, with their paths relative to
+	// the static part of the pattern kept.
+	// +Optional
+	Paths []string `json:"paths,omitempty"`
+	// Excludes are glob patterns of files to exclude, they are matched against both the base
+	// name of a file and its path relative to the static part of the pattern.
+	// +Optional
+	Excludes []string `json:"excludes,omitempty"`
+	// Archive packs the collected data into a single archive if given. Archived input artifacts
+	// are unpacked to the declared path.
+	// +Optional
+	Archive *Archive `json:"archive,omitempty"`
+}
+
+// ArchiveFormat is format of archive.
+type ArchiveFormat string
+
+const (
+	// ArchiveTar is tar archive
+	ArchiveTar ArchiveFormat = "tar"
+	// ArchiveTarGz is gzip compressed tar archive
+	ArchiveTarGz ArchiveFormat = "tar.gz"
+)
+
+// Archive describes an archive of output data.
+type Archive struct {
+	// Name of the archive file, default to '<output name>.tar.gz'
+	// +Optional
+	Name string `json:"name,omitempty"`
+	// Format of the archive, 'tar' or 'tar.gz'. If not set, it's determined by extension of Name,
+	// and default to 'tar.gz'.
+	// +Optional
+	Format ArchiveFormat `json:"format,omitempty"`
 }
 
 // ParameterItem defines a parameter
@@ -34,11 +34,17 @@ func GroupResource(resource string) schema.GroupResource {
 func addKnownTypes(scheme *runtime.Scheme) error {
 	scheme.AddKnownTypes(SchemeGroupVersion,
 		&Resource{},
+		&ResourceList{},
 		&Workflow{},
+		&WorkflowList{},
 		&WorkflowRun{},
+		&WorkflowRunList{},
 		&Stage{},
+		&StageList{},
 		&WorkflowTrigger{},
+		&WorkflowTriggerList{},
 		&Project{},
+		&ProjectList{},
 	)
 	// Add the watch version that applies
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
@@ -24,8 +24,18 @@ const (
 	ImageResourceType = "Image"
 	// GitResourceType represents git repo in SCM
 	GitResourceType = "Git"
+	// SVNResourceType represents svn repo in SCM
+	SVNResourceType = "SVN"
 	// KVResourceType represents a set of key-values
 	KVResourceType = "KV"
+	// HTTPResourceType represents a file served by HTTP server
+	HTTPResourceType = "HTTP"
+	// S3ResourceType represents an object in S3 compatible object storage
+	S3ResourceType = "S3"
+	// HelmResourceType represents a chart in Helm chart repository
+	HelmResourceType = "Helm"
+	// MavenResourceType represents an artifact in Maven repository
+	MavenResourceType = "Maven"
 	// GeneralResourceType represents general resource
 	GeneralResourceType = "General"
 )
@@ -44,7 +54,7 @@ const (
 type ResourceSpec struct {
 	// Image to resolve this kind of resource.
 	Resolver string `json:"resolver,omitempty"`
-	// Resource type, e.g. image, git, kv, general.
+	// Resource type, e.g. image, git, svn, kv, http, s3, helm, maven, general.
 	Type ResourceType `json:"type"`
 	// Persistent resource to PVC.
 	Persistent *Persistent `json:"persistent"`
@@ -34,6 +34,10 @@ type PodWorkload struct {
 	Outputs Outputs `json:"outputs,omitempty"`
 	// Stage workload specification
 	Spec corev1.PodSpec `json:"spec"`
+	// DockerSock indicates whether to mount host docker socket /var/run/docker.sock to workload
+	// containers. Image resources don't need it, it's only for workloads using docker daemon of
+	// the node, e.g. 'docker build'.
+	DockerSock bool `json:"dockerSock,omitempty"`
 }
 
 // Argument defines a argument.
@@ -58,6 +62,8 @@ type Outputs struct {
 	Resources []ResourceItem `json:"resources,omitempty"`
 	// Artifacts to output
 	Artifacts []ArtifactItem `json:"artifacts,omitempty"`
+	// Reports are test reports to collect, they are collected even if the workload fails.
+	Reports []ReportItem `json:"reports,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -19,6 +19,9 @@ type Workflow struct {
 // WorkflowSpec defines workflow specification.
 type WorkflowSpec struct {
 	Stages []StageItem `json:"stages"`
+	// Notifications are rules to send notifications when WorkflowRuns of the workflow terminate.
+	// +Optional
+	Notifications []NotificationRule `json:"notifications,omitempty"`
 }
 
 // StageItem describes a stage in a workflow.
@@ -29,6 +32,37 @@ type StageItem struct {
 	Artifacts []ArtifactItem `json:"artifacts"`
 	// Stages that this stage depends on
 	Depends []string `json:"depends"`
+	// Cache configures result caching of this stage, it's disabled by default.
+	// +Optional
+	Cache *CachePolicy `json:"cache,omitempty"`
+}
+
+// CachePolicy configures result caching of a stage. When enabled, a cache key is computed from
+// the rendered pod spec, resolved revisions of input resources, checksums of input artifacts and
+// arguments of the stage. If results of a previous run are stored under the same key, the stage
+// would be skipped, and the cached artifacts and key-value outputs are used instead.
+type CachePolicy struct {
+	// Enabled indicates whether to cache results of the stage.
+	Enabled bool `json:"enabled"`
+	// TTL is time to keep cached results, for example, '72h'. Empty means never expire.
+	// +Optional
+	TTL string `json:"ttl,omitempty"`
+}
+
+// NotificationRule configures a notification sent when WorkflowRuns terminate with some statuses.
+type NotificationRule struct {
+	// Name of the rule
+	Name string `json:"name"`
+	// Statuses of WorkflowRuns to send notification, they are terminal statuses: Completed, Error
+	// and Cancelled. Empty means all of them.
+	// +Optional
+	Statuses []string `json:"statuses,omitempty"`
+	// Integration is name of the Slack, Email or Webhook integration to send notification.
+	Integration string `json:"integration"`
+	// Template is Go template of the notification message, it's rendered with the WorkflowRun
+	// result, e.g. '{{.WorkflowRun}} {{.Status}}'. A default message is sent if it's empty.
+	// +Optional
+	Template string `json:"template,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
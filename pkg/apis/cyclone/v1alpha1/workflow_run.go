@@ -37,6 +37,10 @@ type WorkflowRunSpec struct {
 	Resources []ParameterConfig `json:"resources"`
 	// Stage parameters
 	Stages []ParameterConfig `json:"stages"`
+	// Replay is name of an earlier WorkflowRun to replay, resources would be pinned to the
+	// versions recorded in its status, see WorkflowRunStatus.Resources.
+	// +Optional
+	Replay string `json:"replay,omitempty"`
 }
 
 // ParameterConfig configures parameters of a resource or a stage.
@@ -55,6 +59,24 @@ type WorkflowRunStatus struct {
 	Overall Status `json:"overall"`
 	// Whether gc is performed on this WorkflowRun, such as deleting pods.
 	Cleaned bool `json:"cleaned"`
+	// Resolved versions of input resources, keyed by resource name.
+	// +Optional
+	Resources map[string]*ResourceStatus `json:"resources,omitempty"`
+}
+
+// ResourceStatus records the version of an input resource resolved in a WorkflowRun.
+type ResourceStatus struct {
+	// Type of the resource
+	Type ResourceType `json:"type"`
+	// Revision is the resolved version of the resource, e.g. commit SHA for git resources,
+	// digest for image resources, ETag or version id for S3 resources.
+	Revision string `json:"revision"`
+	// Parameters to pull exactly the revision, they override resource parameters when the
+	// WorkflowRun is replayed. Empty if the resource can't be pinned.
+	// +Optional
+	Parameters []ParameterItem `json:"parameters,omitempty"`
+	// Stage in which the resource is resolved
+	Stage string `json:"stage"`
 }
 
 // StageStatus describes status of a stage execution.
@@ -65,6 +87,37 @@ type StageStatus struct {
 	Status Status `json:"status"`
 	// Key-value outputs of this stage
 	Outputs []KeyValue `json:"outputs"`
+	// Checksums of output artifacts of this stage, keyed by artifact name.
+	// +Optional
+	Artifacts map[string]string `json:"artifacts,omitempty"`
+	// Cache status of this stage, only set when cache is enabled for the stage.
+	// +Optional
+	Cache *StageCacheStatus `json:"cache,omitempty"`
+	// Compute resource usage of containers in the stage pod, keyed by container name.
+	// +Optional
+	Usage map[string]ContainerUsage `json:"usage,omitempty"`
+}
+
+// ContainerUsage describes compute resource usage of a container, it's sampled by coordinator
+// while the stage runs.
+type ContainerUsage struct {
+	// Peak is the max usage among samples
+	Peak corev1.ResourceList `json:"peak,omitempty"`
+	// Average is the average usage of samples
+	Average corev1.ResourceList `json:"average,omitempty"`
+	// Samples is the number of samples taken
+	Samples int `json:"samples"`
+}
+
+// StageCacheStatus describes cache status of a stage.
+type StageCacheStatus struct {
+	// Key computed for the stage
+	Key string `json:"key"`
+	// Hit indicates whether results of the stage are restored from cache.
+	Hit bool `json:"hit"`
+	// Source is the WorkflowRun that produced the cached results, only set when cache hit.
+	// +Optional
+	Source string `json:"source,omitempty"`
 }
 
 const (
@@ -46,6 +46,19 @@ type WorkflowTriggerSpec struct {
 type WorkflowTriggerStatus struct {
 	// How many times this trigger got triggered
 	Count int `json:"count"`
+	// Webhook registered in SCM for Webhook type trigger
+	// +Optional
+	Webhook *WebhookStatus `json:"webhook,omitempty"`
+}
+
+// WebhookStatus describes webhook registered in SCM repository for a trigger
+type WebhookStatus struct {
+	// SCM integration used to register the webhook
+	Integration string `json:"integration"`
+	// Repository where the webhook registered, e.g. 'caicloud/cyclone'
+	Repository string `json:"repository"`
+	// ID of the webhook in SCM
+	ID string `json:"id"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -0,0 +1,154 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	def "github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/service"
+
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/server/biz/audit"
+	httputil "github.com/caicloud/cyclone/pkg/util/http"
+)
+
+// maxAuditBodySize is max size of request bodies read for audit summaries, larger bodies are not
+// summarized.
+const maxAuditBodySize = 64 << 10
+
+type auditRecordKey struct{}
+
+// newAuditMiddleware records mutating API calls to the audit sink. It runs before the auth
+// middleware, so that denied calls are also recorded, actor of the call is filled in by the auth
+// middleware through the record in context.
+func newAuditMiddleware() def.Middleware {
+	return func(ctx context.Context, next def.Chain) error {
+		httpCtx := service.HTTPContextFrom(ctx)
+		req := httpCtx.Request()
+		if !audit.Enabled() || req.Method == http.MethodGet || req.Method == http.MethodHead || req.Method == http.MethodOptions {
+			return next.Continue(ctx)
+		}
+
+		start := time.Now()
+		attrs := newAttributes(req.Method, httpCtx.RoutePath(), httpCtx.ValueContainer())
+		record := &api.AuditRecord{
+			Time:     start.Format(time.RFC3339Nano),
+			SourceIP: sourceIP(req),
+			Project:  attrs.project,
+			Method:   req.Method,
+			Path:     req.URL.Path,
+			Request:  summarizeBody(req),
+		}
+		record.Resource, record.Name, record.Verb = operation(req.Method, attrs.route, httpCtx.ValueContainer())
+
+		err := next.Continue(context.WithValue(ctx, auditRecordKey{}, record))
+
+		switch {
+		case attrs.resource == "tenants" || attrs.resource == "webhooks":
+			record.Tenant = attrs.tenant
+		case attrs.tenantScoped():
+			// Tenant header is set to the verified tenant by the auth middleware.
+			record.Tenant = req.Header.Get(httputil.TenantHeaderName)
+		}
+		record.StatusCode = httpCtx.ResponseWriter().StatusCode()
+		if err != nil {
+			// Errors of middlewares are written after all middlewares return.
+			record.StatusCode = http.StatusInternalServerError
+			if e, ok := err.(service.Error); ok {
+				record.StatusCode = e.Code()
+			}
+			record.Error = err.Error()
+		}
+		record.Result = api.AuditResultSuccess
+		if record.StatusCode >= http.StatusBadRequest {
+			record.Result = api.AuditResultFailure
+		}
+		record.LatencyMillis = int64(time.Since(start) / time.Millisecond)
+		audit.Record(record)
+
+		return err
+	}
+}
+
+// auditRecordFrom gets the audit record of the call from context, nil is returned if the call
+// is not audited.
+func auditRecordFrom(ctx context.Context) *api.AuditRecord {
+	r, _ := ctx.Value(auditRecordKey{}).(*api.AuditRecord)
+	return r
+}
+
+// operation determines resource, resource name and verb of the call from its route. The last
+// segment not followed by a path parameter is the resource collection if it's plural, e.g.
+// 'POST /projects/{project}/workflows', otherwise it's an action on the resource before it,
+// e.g. 'PUT /projects/{project}/workflows/{workflow}/workflowruns/{workflowrun}/pause'.
+func operation(method, route string, values service.ValueContainer) (resource, name, verb string) {
+	switch method {
+	case http.MethodPost:
+		verb = "create"
+	case http.MethodPut:
+		verb = "update"
+	case http.MethodPatch:
+		verb = "patch"
+	case http.MethodDelete:
+		verb = "delete"
+	default:
+		verb = strings.ToLower(method)
+	}
+
+	segments := strings.Split(strings.Trim(route, "/"), "/")
+	for i := 0; i < len(segments); i++ {
+		s := segments[i]
+		if i+1 < len(segments) && isPathParameter(segments[i+1]) {
+			resource = s
+			name, _ = values.Path(strings.Trim(segments[i+1], "{}"))
+			i++
+			continue
+		}
+
+		if strings.HasSuffix(s, "s") || resource == "" {
+			resource, name = s, ""
+		} else {
+			verb = s
+		}
+	}
+	return resource, name, verb
+}
+
+func isPathParameter(segment string) bool {
+	return strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
+
+// summarizeBody summarizes JSON request body for audit, the body is restored for handlers.
+func summarizeBody(req *http.Request) string {
+	if req.Body == nil || !strings.HasPrefix(req.Header.Get(httputil.HeaderContentType), "application/json") {
+		return ""
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(req.Body, maxAuditBodySize+1))
+	req.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(data), req.Body), req.Body}
+	if err != nil || len(data) > maxAuditBodySize {
+		return ""
+	}
+	return audit.Summarize(data)
+}
+
+// sourceIP gets IP of the caller, forwarded address is preferred if the server is behind proxies.
+func sourceIP(req *http.Request) string {
+	if forwarded := req.Header.Get("X-Forwarded-For"); forwarded != "" {
+		return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
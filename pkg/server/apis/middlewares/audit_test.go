@@ -0,0 +1,60 @@
+package middlewares
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOperation(t *testing.T) {
+	values := &pathValues{values: map[string]string{"project": "p1", "workflow": "w1", "workflowrun": "r1", "user": "alice"}}
+	cases := []struct {
+		method   string
+		route    string
+		resource string
+		name     string
+		verb     string
+	}{
+		{"POST", "/projects", "projects", "", "create"},
+		{"PUT", "/projects/{project}", "projects", "p1", "update"},
+		{"POST", "/projects/{project}/workflows", "workflows", "", "create"},
+		{"DELETE", "/projects/{project}/workflows/{workflow}", "workflows", "w1", "delete"},
+		{"PUT", "/projects/{project}/workflows/{workflow}/workflowruns/{workflowrun}/pause", "workflowruns", "r1", "pause"},
+		{"POST", "/projects/{project}/workflows/{workflow}/workflowruns/{workflowrun}/replay", "workflowruns", "r1", "replay"},
+		{"POST", "/projects/{project}/workflows/{workflow}/workflowruns/{workflowrun}/reports", "reports", "", "create"},
+		{"POST", "/users/{user}/tokens", "tokens", "", "create"},
+	}
+	for _, c := range cases {
+		resource, name, verb := operation(c.method, c.route, values)
+		assert.Equal(t, c.resource, resource, c.route)
+		assert.Equal(t, c.name, name, c.route)
+		assert.Equal(t, c.verb, verb, c.route)
+	}
+}
+
+func TestSummarizeBody(t *testing.T) {
+	body := `{"metadata":{"name":"i1"},"spec":{"scm":{"user":"u","password":"p"}}}`
+	req, _ := http.NewRequest(http.MethodPost, "/apis/v1alpha1/integrations", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	assert.Equal(t, `{"metadata":{"name":"i1"},"spec":{"scm":{"password":"******","user":"u"}}}`, summarizeBody(req))
+
+	// Body is restored for handlers.
+	data, err := ioutil.ReadAll(req.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, body, string(data))
+
+	req, _ = http.NewRequest(http.MethodPost, "/apis/v1alpha1/reports", strings.NewReader("<xml/>"))
+	req.Header.Set("Content-Type", "application/xml")
+	assert.Equal(t, "", summarizeBody(req))
+}
+
+func TestSourceIP(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "/", nil)
+	req.RemoteAddr = "10.0.0.1:5678"
+	assert.Equal(t, "10.0.0.1", sourceIP(req))
+	req.Header.Set("X-Forwarded-For", "1.2.3.4, 10.0.0.2")
+	assert.Equal(t, "1.2.3.4", sourceIP(req))
+}
@@ -0,0 +1,132 @@
+package middlewares
+
+import (
+	"context"
+	"strings"
+
+	def "github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/errors"
+	"github.com/caicloud/nirvana/log"
+	"github.com/caicloud/nirvana/service"
+
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/server/biz/auth"
+	"github.com/caicloud/cyclone/pkg/server/common"
+	"github.com/caicloud/cyclone/pkg/server/config"
+	httputil "github.com/caicloud/cyclone/pkg/util/http"
+)
+
+// apiVersionPath is the path prefix of versioned APIs, routes are matched after it.
+const apiVersionPath = "/v1alpha1"
+
+// newAuthMiddleware authenticates API callers and authorizes requests by their roles. Tenant of
+// tenant scoped requests is determined by the verified identity, the tenant header is set to the
+// verified tenant for handlers, so callers can't access tenants they don't belong to.
+func newAuthMiddleware() def.Middleware {
+	return func(ctx context.Context, next def.Chain) error {
+		if !config.AuthEnabled {
+			return next.Continue(ctx)
+		}
+
+		httpCtx := service.HTTPContextFrom(ctx)
+		req := httpCtx.Request()
+		attrs := newAttributes(req.Method, httpCtx.RoutePath(), httpCtx.ValueContainer())
+		// Login APIs are used to get credentials, and webhooks are verified by secrets of triggers.
+		if attrs.resource == "auth" || attrs.resource == "webhooks" {
+			return next.Continue(ctx)
+		}
+
+		id, err := auth.Authenticate(req)
+		if err != nil {
+			httpCtx.ResponseWriter().Header().Set("WWW-Authenticate", `Basic realm="cyclone"`)
+			return errors.Unauthorized.Error("authentication failed: ${error}", err.Error())
+		}
+		if r := auditRecordFrom(ctx); r != nil {
+			r.Actor = id.User
+		}
+
+		if attrs.tenantScoped() {
+			tenant, err := resolveTenant(id, req.Header.Get(httputil.TenantHeaderName))
+			if err != nil {
+				return err
+			}
+			attrs.tenant = tenant
+			req.Header.Set(httputil.TenantHeaderName, tenant)
+		}
+
+		if !authorize(id, attrs) {
+			log.Warningf("User %s is forbidden to %s %s", id.User, req.Method, req.URL.Path)
+			return errors.Forbidden.Error("user ${user} is forbidden to ${method} ${route}", id.User, req.Method, attrs.route)
+		}
+
+		return next.Continue(auth.WithIdentity(ctx, id))
+	}
+}
+
+// resolveTenant determines tenant of the request. The requested tenant is used if given, whether
+// the identity belongs to it is checked in authorization. Otherwise the only tenant of the
+// identity is used.
+func resolveTenant(id *api.Identity, requested string) (string, error) {
+	if requested != "" {
+		return requested, nil
+	}
+
+	if tenants := auth.Tenants(id); len(tenants) == 1 {
+		return tenants[0], nil
+	}
+	if auth.IsSystemAdmin(id) {
+		return common.AdminTenant, nil
+	}
+	return "", errors.BadRequest.Error("tenant should be specified with header ${header}", httputil.TenantHeaderName)
+}
+
+// attributes are attributes of the request used for authorization.
+type attributes struct {
+	// route is the matched route path relative to API version, e.g. '/projects/{project}'.
+	route string
+	// resource is the top level resource of the route, e.g. 'projects'.
+	resource string
+	// write indicates whether the request modifies resources.
+	write  bool
+	method string
+	// tenant, project and user in the request.
+	tenant  string
+	project string
+	user    string
+}
+
+func newAttributes(method, routePath string, values service.ValueContainer) *attributes {
+	route := routePath
+	if i := strings.Index(route, apiVersionPath+"/"); i != -1 {
+		route = route[i+len(apiVersionPath):]
+	}
+
+	attrs := &attributes{
+		route:    route,
+		resource: strings.SplitN(strings.TrimPrefix(route, "/"), "/", 2)[0],
+		method:   method,
+		// Log streams are pushed through websocket which is set up by GET requests.
+		write: !(method == "GET" || method == "HEAD") || strings.HasSuffix(route, "/streamlogs"),
+	}
+	attrs.project, _ = values.Path(httputil.ProjectNamePathParameterName)
+	attrs.user, _ = values.Path(httputil.UserNamePathParameterName)
+	switch attrs.resource {
+	case "tenants", "webhooks":
+		attrs.tenant, _ = values.Path(httputil.TenantNamePathParameterName)
+	case "auditrecords":
+		// Audit records are queried across tenants, tenant to query is given in query.
+		if tenants, ok := values.Query(httputil.TenantQueryParameter); ok && len(tenants) > 0 {
+			attrs.tenant = tenants[0]
+		}
+	}
+	return attrs
+}
+
+// tenantScoped checks whether the request is scoped in a tenant given by the tenant header.
+func (a *attributes) tenantScoped() bool {
+	switch a.resource {
+	case "tenants", "users", "identity", "auth", "auditrecords", "webhooks":
+		return false
+	}
+	return true
+}
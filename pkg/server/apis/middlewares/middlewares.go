@@ -11,7 +11,7 @@ import (
 
 // Middlewares returns a list of middlewares.
 func Middlewares() []def.Middleware {
-	return []def.Middleware{newLogMiddleware()}
+	return []def.Middleware{newLogMiddleware(), newAuditMiddleware(), newAuthMiddleware()}
 }
 
 func newLogMiddleware() def.Middleware {
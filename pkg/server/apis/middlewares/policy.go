@@ -0,0 +1,82 @@
+package middlewares
+
+import (
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/server/biz/auth"
+)
+
+// workloadRoutes are routes workloads can access, they send logs and test reports of WorkflowRuns.
+var workloadRoutes = map[string]string{
+	"/projects/{project}/workflows/{workflow}/workflowruns/{workflowrun}/streamlogs": "GET",
+	"/projects/{project}/workflows/{workflow}/workflowruns/{workflowrun}/reports":    "POST",
+}
+
+// authorize checks whether the identity is allowed to perform the request:
+// - System admin can do anything.
+// - Tenants and users are managed by system admin, while users can view tenants they belong to
+//   and manage their own API tokens. Tenant admin can update its tenant.
+// - In a tenant, tenant admin can do anything. Project developer can manage resources in
+//   projects, and viewer can only view, both of them can't access integrations, which hold
+//   credentials of external systems and clusters, and audit records of the tenant. Workloads can
+//   only send logs and reports.
+func authorize(id *api.Identity, a *attributes) bool {
+	if auth.IsSystemAdmin(id) {
+		return true
+	}
+
+	switch a.resource {
+	case "identity":
+		return true
+	case "users":
+		// Users can view and update themselves, e.g. change password, and manage their own API
+		// tokens. Roles can only be changed by system admin.
+		return a.user == id.User && (a.route != "/users/{user}" || a.method != "DELETE")
+	case "tenants":
+		switch {
+		case a.route == "/tenants":
+			// Tenants listed are filtered by the identity.
+			return !a.write
+		case a.method == "DELETE":
+			return false
+		}
+	}
+
+	for _, b := range id.Roles {
+		if b.Tenant == a.tenant && roleAllows(b, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// roleAllows checks whether the role in the tenant allows the request.
+func roleAllows(b api.RoleBinding, a *attributes) bool {
+	switch b.Role {
+	case api.RoleTenantAdmin:
+		return true
+	case api.RoleProjectDeveloper, api.RoleViewer:
+		if a.resource == "integrations" || a.resource == "auditrecords" {
+			return false
+		}
+		if a.project != "" && len(b.Projects) > 0 && !contains(b.Projects, a.project) {
+			return false
+		}
+		if !a.write {
+			return true
+		}
+		// Project developer can manage resources in projects, but not the project itself.
+		return b.Role == api.RoleProjectDeveloper && a.project != "" && a.route != "/projects/{project}"
+	case api.RoleWorkload:
+		return workloadRoutes[a.route] == a.method
+	}
+	return false
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package middlewares
+
+import (
+	"testing"
+
+	"github.com/caicloud/nirvana/service"
+	"github.com/stretchr/testify/assert"
+
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/server/common"
+)
+
+// pathValues is a value container with path and query values.
+type pathValues struct {
+	service.ValueContainer
+	values map[string]string
+	query  map[string][]string
+}
+
+func (p *pathValues) Path(key string) (string, bool) {
+	v, ok := p.values[key]
+	return v, ok
+}
+
+func (p *pathValues) Query(key string) ([]string, bool) {
+	v, ok := p.query[key]
+	return v, ok
+}
+
+func TestNewAttributes(t *testing.T) {
+	attrs := newAttributes("GET", "/apis/v1alpha1/projects/{project}/workflows/{workflow}/workflowruns/{workflowrun}/streamlogs",
+		&pathValues{values: map[string]string{"project": "p1"}})
+	assert.Equal(t, "/projects/{project}/workflows/{workflow}/workflowruns/{workflowrun}/streamlogs", attrs.route)
+	assert.Equal(t, "projects", attrs.resource)
+	assert.Equal(t, "p1", attrs.project)
+	assert.True(t, attrs.write)
+	assert.True(t, attrs.tenantScoped())
+
+	attrs = newAttributes("GET", "/apis/v1alpha1/tenants/{tenant}", &pathValues{values: map[string]string{"tenant": "t1"}})
+	assert.Equal(t, "tenants", attrs.resource)
+	assert.Equal(t, "t1", attrs.tenant)
+	assert.False(t, attrs.write)
+	assert.False(t, attrs.tenantScoped())
+
+	attrs = newAttributes("GET", "/apis/v1alpha1/auditrecords", &pathValues{query: map[string][]string{"tenant": {"t1"}}})
+	assert.Equal(t, "t1", attrs.tenant)
+	assert.False(t, attrs.tenantScoped())
+
+	attrs = newAttributes("POST", "/apis/v1alpha1/webhooks/{tenant}", &pathValues{values: map[string]string{"tenant": "t1"}})
+	assert.Equal(t, "webhooks", attrs.resource)
+	assert.Equal(t, "t1", attrs.tenant)
+	assert.False(t, attrs.tenantScoped())
+}
+
+func TestResolveTenant(t *testing.T) {
+	admin := &api.Identity{User: "admin", Roles: []api.RoleBinding{{Role: api.RoleSystemAdmin}}}
+	single := &api.Identity{User: "alice", Roles: []api.RoleBinding{{Role: api.RoleViewer, Tenant: "t1"}}}
+	multiple := &api.Identity{User: "bob", Roles: []api.RoleBinding{
+		{Role: api.RoleViewer, Tenant: "t1"},
+		{Role: api.RoleViewer, Tenant: "t2"},
+	}}
+
+	cases := []struct {
+		id        *api.Identity
+		requested string
+		expected  string
+		err       bool
+	}{
+		{admin, "", common.AdminTenant, false},
+		{admin, "t2", "t2", false},
+		{single, "", "t1", false},
+		{single, "t2", "t2", false},
+		{multiple, "", "", true},
+		{multiple, "t2", "t2", false},
+	}
+	for _, c := range cases {
+		tenant, err := resolveTenant(c.id, c.requested)
+		assert.Equal(t, c.err, err != nil, c.id.User)
+		assert.Equal(t, c.expected, tenant, c.id.User)
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	binding := func(role api.Role, projects ...string) *api.Identity {
+		return &api.Identity{User: "alice", Roles: []api.RoleBinding{{Role: role, Tenant: "t1", Projects: projects}}}
+	}
+	admin := &api.Identity{User: "admin", Roles: []api.RoleBinding{{Role: api.RoleSystemAdmin}}}
+	tenantAdmin := binding(api.RoleTenantAdmin)
+	developer := binding(api.RoleProjectDeveloper)
+	limited := binding(api.RoleProjectDeveloper, "p1")
+	viewer := binding(api.RoleViewer)
+	workload := binding(api.RoleWorkload)
+
+	const (
+		runs      = "/projects/{project}/workflows/{workflow}/workflowruns"
+		streamlog = runs + "/{workflowrun}/streamlogs"
+	)
+	cases := []struct {
+		id      *api.Identity
+		method  string
+		route   string
+		tenant  string
+		project string
+		user    string
+		allowed bool
+	}{
+		{admin, "DELETE", "/tenants/{tenant}", "t1", "", "", true},
+		{admin, "POST", "/users", "", "", "", true},
+		{tenantAdmin, "PUT", "/tenants/{tenant}", "t1", "", "", true},
+		{tenantAdmin, "PUT", "/tenants/{tenant}", "t2", "", "", false},
+		{tenantAdmin, "DELETE", "/tenants/{tenant}", "t1", "", "", false},
+		{tenantAdmin, "POST", "/integrations", "t1", "", "", true},
+		{tenantAdmin, "POST", "/users", "", "", "", false},
+		{viewer, "GET", "/tenants", "", "", "", true},
+		{viewer, "POST", "/tenants", "", "", "", false},
+		{viewer, "GET", "/identity", "", "", "", true},
+		{viewer, "GET", "/users/{user}", "", "", "alice", true},
+		{viewer, "POST", "/users/{user}/tokens", "", "", "alice", true},
+		{viewer, "DELETE", "/users/{user}", "", "", "alice", false},
+		{viewer, "GET", "/users/{user}", "", "", "bob", false},
+		{viewer, "GET", runs, "t1", "p1", "", true},
+		{viewer, "GET", runs, "t2", "p1", "", false},
+		{viewer, "POST", runs, "t1", "p1", "", false},
+		{viewer, "GET", "/integrations", "t1", "", "", false},
+		{developer, "POST", runs, "t1", "p1", "", true},
+		{developer, "GET", streamlog, "t1", "p1", "", true},
+		{developer, "DELETE", "/projects/{project}", "t1", "p1", "", false},
+		{developer, "POST", "/projects", "t1", "", "", false},
+		{limited, "POST", runs, "t1", "p1", "", true},
+		{limited, "GET", runs, "t1", "p2", "", false},
+		{tenantAdmin, "GET", "/auditrecords", "t1", "", "", true},
+		{tenantAdmin, "GET", "/auditrecords", "", "", "", false},
+		{viewer, "GET", "/auditrecords", "t1", "", "", false},
+		{workload, "GET", streamlog, "t1", "p1", "", true},
+		{workload, "GET", runs, "t1", "p1", "", false},
+		{workload, "GET", streamlog, "t2", "p1", "", false},
+	}
+	for _, c := range cases {
+		attrs := &attributes{
+			route:    c.route,
+			resource: resourceOf(c.route),
+			method:   c.method,
+			write:    c.method != "GET" || c.route == streamlog,
+			tenant:   c.tenant,
+			project:  c.project,
+			user:     c.user,
+		}
+		assert.Equal(t, c.allowed, authorize(c.id, attrs), "%s %s %s by %s", c.method, c.route, c.tenant, c.id.Roles[0].Role)
+	}
+}
+
+func resourceOf(route string) string {
+	return newAttributes("", route, &pathValues{}).resource
+}
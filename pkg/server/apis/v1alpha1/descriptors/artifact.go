@@ -0,0 +1,106 @@
+package descriptors
+
+import (
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/operators/validator"
+
+	handler "github.com/caicloud/cyclone/pkg/server/handler/v1alpha1"
+	httputil "github.com/caicloud/cyclone/pkg/util/http"
+)
+
+func init() {
+	register(artifact...)
+}
+
+var artifact = []definition.Descriptor{
+	{
+		Path:        "/projects/{project}/workflows/{workflow}/workflowruns/{workflowrun}/artifacts",
+		Description: "artifact APIs",
+		Definitions: []definition.Definition{
+			{
+				Method:      definition.Get,
+				Function:    handler.ListWorkflowRunArtifacts,
+				Description: "List artifacts of workflowrun",
+				Parameters: []definition.Parameter{
+					{
+						Source: definition.Path,
+						Name:   httputil.ProjectNamePathParameterName,
+					},
+					{
+						Source: definition.Path,
+						Name:   httputil.WorkflowNamePathParameterName,
+					},
+					{
+						Source: definition.Path,
+						Name:   httputil.WorkflowRunNamePathParameterName,
+					},
+					{
+						Source: definition.Header,
+						Name:   httputil.TenantHeaderName,
+					},
+					{
+						Source:      definition.Query,
+						Name:        httputil.StageNameQueryParameter,
+						Description: "only list artifacts of the stage",
+					},
+				},
+				Results: definition.DataErrorResults("artifacts"),
+			},
+		},
+	},
+	{
+		Path:        "/projects/{project}/workflows/{workflow}/workflowruns/{workflowrun}/stages/{stage}/artifacts/{artifact}",
+		Description: "artifact APIs",
+		Definitions: []definition.Definition{
+			{
+				Method:      definition.Get,
+				Function:    handler.DownloadWorkflowRunArtifact,
+				Description: "Download artifact of workflowrun stage",
+				Parameters: []definition.Parameter{
+					{
+						Source: definition.Path,
+						Name:   httputil.ProjectNamePathParameterName,
+					},
+					{
+						Source: definition.Path,
+						Name:   httputil.WorkflowNamePathParameterName,
+					},
+					{
+						Source: definition.Path,
+						Name:   httputil.WorkflowRunNamePathParameterName,
+					},
+					{
+						Source: definition.Header,
+						Name:   httputil.TenantHeaderName,
+					},
+					{
+						Source: definition.Path,
+						Name:   httputil.StageNamePathParameterName,
+					},
+					{
+						Source: definition.Path,
+						Name:   httputil.ArtifactNamePathParameterName,
+					},
+					{
+						Source:      definition.Query,
+						Name:        httputil.ArchiveQueryParameter,
+						Operators:   []definition.Operator{validator.Bool("")},
+						Description: "download file artifact as tar.gz archive",
+					},
+				},
+				Results: []definition.Result{
+					{
+						Destination: definition.Data,
+						Description: "artifact data",
+					},
+					{
+						Destination: definition.Meta,
+					},
+					{
+						Destination: definition.Error,
+					},
+				},
+			},
+		},
+	},
+}
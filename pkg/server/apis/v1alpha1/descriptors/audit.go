@@ -0,0 +1,54 @@
+package descriptors
+
+import (
+	"github.com/caicloud/nirvana/definition"
+
+	handler "github.com/caicloud/cyclone/pkg/server/handler/v1alpha1"
+	httputil "github.com/caicloud/cyclone/pkg/util/http"
+)
+
+func init() {
+	register(audit...)
+}
+
+var audit = []definition.Descriptor{
+	{
+		Path:        "/auditrecords",
+		Description: "Audit record APIs",
+		Definitions: []definition.Definition{
+			{
+				Method:      definition.List,
+				Function:    handler.ListAuditRecords,
+				Description: "List audit records of mutating API calls",
+				Parameters: []definition.Parameter{
+					{
+						Source:      definition.Query,
+						Name:        httputil.TenantQueryParameter,
+						Description: "tenant of records",
+					},
+					{
+						Source:      definition.Query,
+						Name:        httputil.ActorQueryParameter,
+						Description: "actor of records",
+					},
+					{
+						Source:      definition.Query,
+						Name:        httputil.SinceQueryParameter,
+						Description: "start of time range in RFC3339 format",
+					},
+					{
+						Source:      definition.Query,
+						Name:        httputil.UntilQueryParameter,
+						Description: "end of time range in RFC3339 format",
+					},
+					{
+						Source:      definition.Auto,
+						Name:        httputil.PaginationAutoParameter,
+						Description: "pagination",
+					},
+				},
+				Results: definition.DataErrorResults("audit records"),
+			},
+		},
+	},
+}
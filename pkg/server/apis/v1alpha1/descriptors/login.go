@@ -0,0 +1,59 @@
+package descriptors
+
+import (
+	"github.com/caicloud/nirvana/definition"
+
+	handler "github.com/caicloud/cyclone/pkg/server/handler/v1alpha1"
+	httputil "github.com/caicloud/cyclone/pkg/util/http"
+)
+
+func init() {
+	register(login...)
+}
+
+var login = []definition.Descriptor{
+	{
+		Path:        "/auth/{provider}/login",
+		Description: "Login APIs",
+		Definitions: []definition.Definition{
+			{
+				Method:      definition.Get,
+				Function:    handler.Login,
+				Description: "Redirect to login with identity provider",
+				Parameters: []definition.Parameter{
+					{
+						Source: definition.Path,
+						Name:   httputil.ProviderPathParameterName,
+					},
+				},
+				Results: []definition.Result{definition.ErrorResult()},
+			},
+		},
+	},
+	{
+		Path:        "/auth/{provider}/callback",
+		Description: "Login APIs",
+		Definitions: []definition.Definition{
+			{
+				Method:      definition.Get,
+				Function:    handler.LoginCallback,
+				Description: "Handle callback from identity provider, and issue API token",
+				Parameters: []definition.Parameter{
+					{
+						Source: definition.Path,
+						Name:   httputil.ProviderPathParameterName,
+					},
+					{
+						Source: definition.Query,
+						Name:   httputil.CodeQueryParameter,
+					},
+					{
+						Source: definition.Query,
+						Name:   httputil.StateQueryParameter,
+					},
+				},
+				Results: definition.DataErrorResults("API token"),
+			},
+		},
+	},
+}
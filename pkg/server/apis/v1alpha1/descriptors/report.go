@@ -0,0 +1,182 @@
+package descriptors
+
+import (
+	"github.com/caicloud/nirvana/definition"
+
+	handler "github.com/caicloud/cyclone/pkg/server/handler/v1alpha1"
+	httputil "github.com/caicloud/cyclone/pkg/util/http"
+)
+
+func init() {
+	register(report...)
+}
+
+var report = []definition.Descriptor{
+	{
+		Path:        "/projects/{project}/workflows/{workflow}/workflowruns/{workflowrun}/reports",
+		Description: "test report APIs",
+		Definitions: []definition.Definition{
+			{
+				Method:      definition.Create,
+				Function:    handler.CreateTestReport,
+				Description: "Store test report of workflowrun stage, used by coordinator",
+				Parameters: []definition.Parameter{
+					{
+						Source: definition.Path,
+						Name:   httputil.ProjectNamePathParameterName,
+					},
+					{
+						Source: definition.Path,
+						Name:   httputil.WorkflowNamePathParameterName,
+					},
+					{
+						Source: definition.Path,
+						Name:   httputil.WorkflowRunNamePathParameterName,
+					},
+					{
+						Source: definition.Header,
+						Name:   httputil.TenantHeaderName,
+					},
+					{
+						Source:      definition.Body,
+						Description: "JSON body to describe the test report",
+					},
+				},
+				Results: definition.DataErrorResults("test report"),
+			},
+			{
+				Method:      definition.Get,
+				Function:    handler.ListTestReports,
+				Description: "List test reports of workflowrun without test cases",
+				Parameters: []definition.Parameter{
+					{
+						Source: definition.Path,
+						Name:   httputil.ProjectNamePathParameterName,
+					},
+					{
+						Source: definition.Path,
+						Name:   httputil.WorkflowNamePathParameterName,
+					},
+					{
+						Source: definition.Path,
+						Name:   httputil.WorkflowRunNamePathParameterName,
+					},
+					{
+						Source: definition.Header,
+						Name:   httputil.TenantHeaderName,
+					},
+					{
+						Source:      definition.Query,
+						Name:        httputil.StageNameQueryParameter,
+						Description: "only list reports of the stage",
+					},
+				},
+				Results: definition.DataErrorResults("test reports"),
+			},
+		},
+	},
+	{
+		Path:        "/projects/{project}/workflows/{workflow}/workflowruns/{workflowrun}/stages/{stage}/reports/{report}",
+		Description: "test report APIs",
+		Definitions: []definition.Definition{
+			{
+				Method:      definition.Get,
+				Function:    handler.GetTestReport,
+				Description: "Get test report of workflowrun stage with test cases",
+				Parameters: []definition.Parameter{
+					{
+						Source: definition.Path,
+						Name:   httputil.ProjectNamePathParameterName,
+					},
+					{
+						Source: definition.Path,
+						Name:   httputil.WorkflowNamePathParameterName,
+					},
+					{
+						Source: definition.Path,
+						Name:   httputil.WorkflowRunNamePathParameterName,
+					},
+					{
+						Source: definition.Header,
+						Name:   httputil.TenantHeaderName,
+					},
+					{
+						Source: definition.Path,
+						Name:   httputil.StageNamePathParameterName,
+					},
+					{
+						Source: definition.Path,
+						Name:   httputil.ReportNamePathParameterName,
+					},
+				},
+				Results: definition.DataErrorResults("test report"),
+			},
+		},
+	},
+	{
+		Path:        "/projects/{project}/workflows/{workflow}/workflowruns/{workflowrun}/failedtests",
+		Description: "test report APIs",
+		Definitions: []definition.Definition{
+			{
+				Method:      definition.Get,
+				Function:    handler.ListFailedTests,
+				Description: "List failed test cases of workflowrun",
+				Parameters: []definition.Parameter{
+					{
+						Source: definition.Path,
+						Name:   httputil.ProjectNamePathParameterName,
+					},
+					{
+						Source: definition.Path,
+						Name:   httputil.WorkflowNamePathParameterName,
+					},
+					{
+						Source: definition.Path,
+						Name:   httputil.WorkflowRunNamePathParameterName,
+					},
+					{
+						Source: definition.Header,
+						Name:   httputil.TenantHeaderName,
+					},
+					{
+						Source:      definition.Query,
+						Name:        httputil.StageNameQueryParameter,
+						Description: "only list failed test cases of the stage",
+					},
+				},
+				Results: definition.DataErrorResults("failed tests"),
+			},
+		},
+	},
+	{
+		Path:        "/projects/{project}/workflows/{workflow}/testtrends",
+		Description: "test report APIs",
+		Definitions: []definition.Definition{
+			{
+				Method:      definition.Get,
+				Function:    handler.ListTestTrends,
+				Description: "List test results of workflowruns of the workflow, latest first",
+				Parameters: []definition.Parameter{
+					{
+						Source: definition.Path,
+						Name:   httputil.ProjectNamePathParameterName,
+					},
+					{
+						Source: definition.Path,
+						Name:   httputil.WorkflowNamePathParameterName,
+					},
+					{
+						Source: definition.Header,
+						Name:   httputil.TenantHeaderName,
+					},
+					{
+						Source:      definition.Auto,
+						Name:        httputil.PaginationAutoParameter,
+						Description: "pagination",
+					},
+				},
+				Results: definition.DataErrorResults("test trends"),
+			},
+		},
+	},
+}
@@ -145,4 +145,36 @@ var stage = []definition.Descriptor{
 			},
 		},
 	},
+	{
+		Path:        "/projects/{project}/stages/{stage}/resourcesuggestion",
+		Description: "Stage APIs",
+		Definitions: []definition.Definition{
+			{
+				Method:      definition.Get,
+				Function:    handler.GetStageResourceSuggestion,
+				Description: "Suggest resource requests and limits of stage from usage in recent runs",
+				Parameters: []definition.Parameter{
+					{
+						Source: definition.Path,
+						Name:   httputil.ProjectNamePathParameterName,
+					},
+					{
+						Source: definition.Path,
+						Name:   httputil.StageNamePathParameterName,
+					},
+					{
+						Source: definition.Header,
+						Name:   httputil.TenantHeaderName,
+					},
+					{
+						Source:      definition.Query,
+						Name:        httputil.RunsQueryParameter,
+						Default:     10,
+						Description: "Number of recent runs to suggest resources from",
+					},
+				},
+				Results: definition.DataErrorResults("resource suggestion"),
+			},
+		},
+	},
 }
@@ -0,0 +1,160 @@
+package descriptors
+
+import (
+	"github.com/caicloud/nirvana/definition"
+
+	handler "github.com/caicloud/cyclone/pkg/server/handler/v1alpha1"
+	httputil "github.com/caicloud/cyclone/pkg/util/http"
+)
+
+func init() {
+	register(user...)
+}
+
+var user = []definition.Descriptor{
+	{
+		Path:        "/users",
+		Description: "User APIs",
+		Definitions: []definition.Definition{
+			{
+				Method:      definition.Create,
+				Function:    handler.CreateUser,
+				Description: "Create user",
+				Parameters: []definition.Parameter{
+					{
+						Source:      definition.Body,
+						Description: "user",
+					},
+				},
+				Results: definition.DataErrorResults("user"),
+			},
+			{
+				Method:      definition.List,
+				Function:    handler.ListUsers,
+				Description: "List users",
+				Parameters: []definition.Parameter{
+					{
+						Source:      definition.Auto,
+						Name:        httputil.PaginationAutoParameter,
+						Description: "pagination",
+					},
+				},
+				Results: definition.DataErrorResults("users"),
+			},
+		},
+	},
+	{
+		Path:        "/users/{user}",
+		Description: "User APIs",
+		Definitions: []definition.Definition{
+			{
+				Method:      definition.Get,
+				Function:    handler.GetUser,
+				Description: "Get user",
+				Parameters: []definition.Parameter{
+					{
+						Source: definition.Path,
+						Name:   httputil.UserNamePathParameterName,
+					},
+				},
+				Results: definition.DataErrorResults("user"),
+			},
+			{
+				Method:      definition.Update,
+				Function:    handler.UpdateUser,
+				Description: "Update user",
+				Parameters: []definition.Parameter{
+					{
+						Source: definition.Path,
+						Name:   httputil.UserNamePathParameterName,
+					},
+					{
+						Source:      definition.Body,
+						Description: "user",
+					},
+				},
+				Results: definition.DataErrorResults("user"),
+			},
+			{
+				Method:      definition.Delete,
+				Function:    handler.DeleteUser,
+				Description: "Delete user",
+				Parameters: []definition.Parameter{
+					{
+						Source: definition.Path,
+						Name:   httputil.UserNamePathParameterName,
+					},
+				},
+				Results: []definition.Result{definition.ErrorResult()},
+			},
+		},
+	},
+	{
+		Path:        "/users/{user}/tokens",
+		Description: "API token APIs",
+		Definitions: []definition.Definition{
+			{
+				Method:      definition.Create,
+				Function:    handler.CreateAPIToken,
+				Description: "Create API token for user",
+				Parameters: []definition.Parameter{
+					{
+						Source: definition.Path,
+						Name:   httputil.UserNamePathParameterName,
+					},
+					{
+						Source:      definition.Body,
+						Description: "API token",
+					},
+				},
+				Results: definition.DataErrorResults("API token"),
+			},
+			{
+				Method:      definition.List,
+				Function:    handler.ListAPITokens,
+				Description: "List API tokens of user",
+				Parameters: []definition.Parameter{
+					{
+						Source: definition.Path,
+						Name:   httputil.UserNamePathParameterName,
+					},
+				},
+				Results: definition.DataErrorResults("API tokens"),
+			},
+		},
+	},
+	{
+		Path:        "/users/{user}/tokens/{token}",
+		Description: "API token APIs",
+		Definitions: []definition.Definition{
+			{
+				Method:      definition.Delete,
+				Function:    handler.DeleteAPIToken,
+				Description: "Delete API token of user",
+				Parameters: []definition.Parameter{
+					{
+						Source: definition.Path,
+						Name:   httputil.UserNamePathParameterName,
+					},
+					{
+						Source: definition.Path,
+						Name:   httputil.TokenIDPathParameterName,
+					},
+				},
+				Results: []definition.Result{definition.ErrorResult()},
+			},
+		},
+	},
+	{
+		Path:        "/identity",
+		Description: "Identity APIs",
+		Definitions: []definition.Definition{
+			{
+				Method:      definition.Get,
+				Function:    handler.GetIdentity,
+				Description: "Get identity of the caller",
+				Results:     definition.DataErrorResults("identity"),
+			},
+		},
+	},
+}
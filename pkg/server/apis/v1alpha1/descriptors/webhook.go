@@ -0,0 +1,33 @@
+package descriptors
+
+import (
+	"github.com/caicloud/nirvana/definition"
+
+	handler "github.com/caicloud/cyclone/pkg/server/handler/v1alpha1"
+	httputil "github.com/caicloud/cyclone/pkg/util/http"
+)
+
+func init() {
+	register(webhook...)
+}
+
+var webhook = []definition.Descriptor{
+	{
+		Path:        "/webhooks/{tenant}",
+		Description: "Webhook APIs",
+		Definitions: []definition.Definition{
+			{
+				Method:      definition.Create,
+				Function:    handler.ReceiveWebhook,
+				Description: "Receive webhooks from SCM or other systems to trigger workflows",
+				Parameters: []definition.Parameter{
+					{
+						Source: definition.Path,
+						Name:   httputil.TenantNamePathParameterName,
+					},
+				},
+				Results: definition.DataErrorResults("triggered workflowruns"),
+			},
+		},
+	},
+}
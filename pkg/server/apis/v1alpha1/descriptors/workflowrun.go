@@ -224,6 +224,35 @@ var workflowrun = []definition.Descriptor{
 			},
 		},
 	},
+	{
+		Path: "/projects/{project}/workflows/{workflow}/workflowruns/{workflowrun}/replay",
+		Definitions: []definition.Definition{
+			{
+				Method:      definition.Create,
+				Function:    handler.ReplayWorkflowRun,
+				Description: "Replay a workflowrun with resources pinned to versions it resolved",
+				Parameters: []definition.Parameter{
+					{
+						Source: definition.Path,
+						Name:   httputil.ProjectNamePathParameterName,
+					},
+					{
+						Source: definition.Path,
+						Name:   httputil.WorkflowNamePathParameterName,
+					},
+					{
+						Source: definition.Path,
+						Name:   httputil.WorkflowRunNamePathParameterName,
+					},
+					{
+						Source: definition.Header,
+						Name:   httputil.TenantHeaderName,
+					},
+				},
+				Results: definition.DataErrorResults("workflowrun"),
+			},
+		},
+	},
 	{
 		Path: "/projects/{project}/workflows/{workflow}/workflowruns/{workflowrun}/streamlogs",
 		Definitions: []definition.Definition{
@@ -31,6 +31,20 @@ type TenantSpec struct {
 	// ResourceQuota describes the resource quota of the namespace,
 	// eg map[core_v1.ResourceName]string{"cpu": "2", "memory": "4Gi"}
 	ResourceQuota map[core_v1.ResourceName]string `json:"resourceQuota"`
+
+	// GroupRoles bind roles in the tenant to groups of users from external identity providers,
+	// e.g. LDAP and OIDC, users get these roles when they login.
+	GroupRoles []GroupRoleBinding `json:"groupRoles,omitempty"`
+}
+
+// GroupRoleBinding binds a role to users in a group of external identity providers
+type GroupRoleBinding struct {
+	// Group is name of the group in identity providers
+	Group string `json:"group"`
+	// Role bound to users in the group, it can't be system admin or workload.
+	Role Role `json:"role"`
+	// Projects where the role applies, see RoleBinding.
+	Projects []string `json:"projects,omitempty"`
 }
 
 // PersistentVolumeClaim describes information about pvc belongs to a tenant
@@ -67,6 +81,17 @@ const (
 	SCM IntegrationType = "SCM"
 	// Cluster is the Cluster integration
 	Cluster IntegrationType = "Cluster"
+	// ObjectStorage is the S3 compatible object storage integration
+	ObjectStorage IntegrationType = "ObjectStorage"
+	// Repository is the artifact repository integration, e.g. HTTP file server, Helm chart
+	// repository and Maven repository
+	Repository IntegrationType = "Repository"
+	// Slack is the Slack compatible incoming webhook integration to send notifications
+	Slack IntegrationType = "Slack"
+	// Email is the SMTP server integration to send notifications by emails
+	Email IntegrationType = "Email"
+	// Webhook is the generic HTTP webhook integration to send notifications
+	Webhook IntegrationType = "Webhook"
 	// GeneralIntegration is the General integration
 	GeneralIntegration IntegrationType = "General"
 )
@@ -96,6 +121,21 @@ type IntegrationSource struct {
 	// and clusters integrated here can be used to deploy application in CD tasks.
 	Cluster *ClusterSource `json:"cluster"`
 
+	// ObjectStorage describes info about S3 compatible object storage, and is used by S3 resources.
+	ObjectStorage *ObjectStorageSource `json:"objectStorage,omitempty"`
+
+	// Repository describes info about artifact repository, and is used by HTTP, Helm and Maven resources.
+	Repository *RepositorySource `json:"repository,omitempty"`
+
+	// Slack describes info about Slack compatible incoming webhook, and is used to send notifications.
+	Slack *SlackSource `json:"slack,omitempty"`
+
+	// Email describes info about SMTP server, and is used to send notifications by emails.
+	Email *EmailSource `json:"email,omitempty"`
+
+	// Webhook describes info about generic HTTP webhook, and is used to send notifications.
+	Webhook *WebhookSource `json:"webhook,omitempty"`
+
 	// General contains parameters defined by users.
 	General []ParameterItem `json:"general"`
 }
@@ -126,6 +166,64 @@ type DockerRegistrySource struct {
 	Password string `json:"password"`
 }
 
+// ObjectStorageSource represents a S3 compatible object storage.
+type ObjectStorageSource struct {
+	// Endpoint of the object storage, e.g. s3.amazonaws.com.
+	Endpoint string `json:"endpoint"`
+	// Region of the buckets, default to us-east-1.
+	Region string `json:"region,omitempty"`
+	// AccessKey is the access key of the object storage.
+	AccessKey string `json:"accessKey"`
+	// SecretKey is the secret key of the object storage.
+	SecretKey string `json:"secretKey"`
+	// Insecure indicates whether to access the object storage over plain HTTP.
+	Insecure bool `json:"insecure,omitempty"`
+}
+
+// RepositorySource represents an artifact repository.
+type RepositorySource struct {
+	// Server is the address of the repository.
+	Server string `json:"server"`
+	// User is a user of the repository.
+	User string `json:"user"`
+	// Password is the password of the corresponding user.
+	Password string `json:"password"`
+	// Token is the credential to access the repository, it takes precedence over user and password.
+	Token string `json:"token,omitempty"`
+}
+
+// SlackSource represents a Slack compatible incoming webhook.
+type SlackSource struct {
+	// URL of the incoming webhook.
+	URL string `json:"url"`
+	// Channel overrides the default channel of the webhook, it's optional.
+	Channel string `json:"channel,omitempty"`
+}
+
+// EmailSource represents a SMTP server to send emails.
+type EmailSource struct {
+	// Server is the address of SMTP server with port, e.g. smtp.example.com:587. STARTTLS is used
+	// if the server supports it.
+	Server string `json:"server"`
+	// User is a user of the SMTP server, emails are sent without authentication if it's empty.
+	User string `json:"user,omitempty"`
+	// Password is the password of the corresponding user.
+	Password string `json:"password,omitempty"`
+	// From is the sender address.
+	From string `json:"from"`
+	// To are the recipient addresses.
+	To []string `json:"to"`
+}
+
+// WebhookSource represents a generic HTTP webhook.
+type WebhookSource struct {
+	// URL to post notifications to.
+	URL string `json:"url"`
+	// Secret is used to sign bodies of requests with HMAC-SHA256, the signature is sent in
+	// header X-Cyclone-Signature. It's optional.
+	Secret string `json:"secret,omitempty"`
+}
+
 // SCMType defines the type of Source Code Management
 type SCMType string
 
@@ -150,6 +248,14 @@ type SCMSource struct {
 	Password string `json:"password"`
 	// Token is the credential to access SCM.
 	Token string `json:"token"`
+	// SSHKey is the private key to access repositories in SCM over SSH.
+	SSHKey string `json:"sshKey,omitempty"`
+	// KnownHosts is SSH known_hosts entries of the SCM server, they are used to verify host keys
+	// when accessing repositories over SSH. If not given, known hosts in resolver image are used.
+	KnownHosts string `json:"knownHosts,omitempty"`
+	// InsecureSkipHostKeyCheck disables host key checking when accessing repositories over SSH,
+	// which is vulnerable to man-in-the-middle attacks. It should only be used for testing.
+	InsecureSkipHostKeyCheck bool `json:"insecureSkipHostKeyCheck,omitempty"`
 }
 
 // ClusterSource contains info about clusters.
@@ -200,3 +306,271 @@ type TLSClientConfig struct {
 	// CAData takes precedence over CAFile
 	CAData []byte `json:"caData,omitempty" bson:"caData"`
 }
+
+// Artifact describes an artifact produced by a stage in WorkflowRun.
+type Artifact struct {
+	// Stage is name of the stage that produced the artifact
+	Stage string `json:"stage"`
+	// Name is name of the artifact
+	Name string `json:"name"`
+	// FileName is name of the file or directory collected as the artifact
+	FileName string `json:"fileName"`
+	// Directory indicates whether the artifact is a directory
+	Directory bool `json:"directory"`
+	// Size is size of the stored artifact in bytes, for directories, it's size of the tar archive
+	Size int64 `json:"size"`
+	// Checksum is checksum of the stored artifact, in format sha256:<hex>
+	Checksum string `json:"checksum"`
+	// CreationTime records the time the artifact stored
+	CreationTime string `json:"creationTime"`
+}
+
+// TestReport describes a test report produced by a stage in WorkflowRun.
+type TestReport struct {
+	// Stage is name of the stage that produced the report
+	Stage string `json:"stage"`
+	// Name is name of the report
+	Name string `json:"name"`
+	// Format is format of the report files, 'junit', 'cobertura' or 'go-cover'
+	Format string `json:"format"`
+	// Summary summarizes test cases in the report
+	Summary TestSummary `json:"summary"`
+	// Coverage is code coverage in the report, only for coverage reports
+	Coverage *Coverage `json:"coverage,omitempty"`
+	// Cases are test cases in the report
+	Cases []TestCase `json:"cases,omitempty"`
+	// CreationTime records the time the report stored
+	CreationTime string `json:"creationTime"`
+}
+
+// TestSummary summarizes results of test cases.
+type TestSummary struct {
+	// Total is number of all test cases
+	Total int `json:"total"`
+	// Passed is number of passed test cases
+	Passed int `json:"passed"`
+	// Failed is number of failed test cases, including errored ones
+	Failed int `json:"failed"`
+	// Skipped is number of skipped test cases
+	Skipped int `json:"skipped"`
+	// Duration is total time of test cases in seconds
+	Duration float64 `json:"duration"`
+}
+
+// Add adds results of the test case to the summary.
+func (s *TestSummary) Add(c TestCase) {
+	s.Total++
+	s.Duration += c.Duration
+	switch c.Status {
+	case TestPassed:
+		s.Passed++
+	case TestSkipped:
+		s.Skipped++
+	default:
+		s.Failed++
+	}
+}
+
+// Merge merges another summary to the summary.
+func (s *TestSummary) Merge(o TestSummary) {
+	s.Total += o.Total
+	s.Passed += o.Passed
+	s.Failed += o.Failed
+	s.Skipped += o.Skipped
+	s.Duration += o.Duration
+}
+
+// TestStatus is status of a test case.
+type TestStatus string
+
+const (
+	// TestPassed means the test case passed
+	TestPassed TestStatus = "passed"
+	// TestFailed means assertions of the test case failed
+	TestFailed TestStatus = "failed"
+	// TestErrored means the test case failed with unexpected error
+	TestErrored TestStatus = "error"
+	// TestSkipped means the test case was skipped
+	TestSkipped TestStatus = "skipped"
+)
+
+// TestCase describes result of a test case.
+type TestCase struct {
+	// Suite is name of the test suite
+	Suite string `json:"suite,omitempty"`
+	// ClassName is class name of the test case, it's usually package name for Go tests
+	ClassName string `json:"className,omitempty"`
+	// Name is name of the test case
+	Name string `json:"name"`
+	// Status is status of the test case
+	Status TestStatus `json:"status"`
+	// Duration is time of the test case in seconds
+	Duration float64 `json:"duration"`
+	// Message is message of the failure or error
+	Message string `json:"message,omitempty"`
+	// Details is details of the failure or error, e.g. stack trace
+	Details string `json:"details,omitempty"`
+}
+
+// Coverage describes code coverage.
+type Coverage struct {
+	// Covered is number of covered lines, or statements for Go coverage profile
+	Covered int64 `json:"covered"`
+	// Total is number of all lines, or statements for Go coverage profile
+	Total int64 `json:"total"`
+	// Rate is ratio of covered lines or statements, in range [0, 1]
+	Rate float64 `json:"rate"`
+	// BranchRate is ratio of covered branches, in range [0, 1], only for Cobertura report
+	BranchRate *float64 `json:"branchRate,omitempty"`
+}
+
+// FailedTest describes a failed test case in WorkflowRun.
+type FailedTest struct {
+	// Stage is name of the stage that produced the report
+	Stage string `json:"stage"`
+	// Report is name of the report containing the test case
+	Report string `json:"report"`
+	// TestCase is the failed test case
+	TestCase `json:",inline"`
+}
+
+// TestTrend describes test results of a WorkflowRun, trends of a Workflow consist of test
+// results of its WorkflowRuns.
+type TestTrend struct {
+	// WorkflowRun is name of the WorkflowRun
+	WorkflowRun string `json:"workflowRun"`
+	// CreationTime records the time the WorkflowRun created
+	CreationTime string `json:"creationTime"`
+	// Summary summarizes test cases in all reports of the WorkflowRun
+	Summary TestSummary `json:"summary"`
+	// Coverage is code coverage merged from all coverage reports of the WorkflowRun
+	Coverage *Coverage `json:"coverage,omitempty"`
+}
+
+// ResourceSuggestion suggests compute resources of containers in a stage, it's calculated from
+// resource usage recorded in recent runs of the stage.
+type ResourceSuggestion struct {
+	// Stage is name of the stage
+	Stage string `json:"stage"`
+	// Runs is the number of runs with resource usage recorded that the suggestion based on
+	Runs int `json:"runs"`
+	// Containers are suggested requests and limits of containers, keyed by container name
+	Containers map[string]core_v1.ResourceRequirements `json:"containers"`
+}
+
+// User is a user account of Cyclone
+type User struct {
+	// Metadata contains metadata information about user
+	Metadata Metadata `json:"metadata"`
+	// Spec contains user spec
+	Spec UserSpec `json:"spec"`
+}
+
+// UserSpec contains the user spec information
+type UserSpec struct {
+	// Password is used to set password of the user, it's never returned.
+	Password string `json:"password,omitempty"`
+	// Provider is the external identity provider of the user, e.g. 'ldap' or 'oidc', it's empty
+	// for local users. Users from external identity providers are created when they first login,
+	// and their roles are determined by their groups at each login.
+	Provider string `json:"provider,omitempty"`
+	// Roles are roles bound to the user
+	Roles []RoleBinding `json:"roles"`
+}
+
+// Role defines what users can do in Cyclone
+type Role string
+
+const (
+	// RoleSystemAdmin can do anything, including managing tenants and users.
+	RoleSystemAdmin Role = "system-admin"
+	// RoleTenantAdmin can do anything in the tenant, including managing integrations and clusters.
+	RoleTenantAdmin Role = "tenant-admin"
+	// RoleProjectDeveloper can manage resources in projects of the tenant, and view others except
+	// integrations.
+	RoleProjectDeveloper Role = "project-developer"
+	// RoleViewer can view resources in the tenant except integrations.
+	RoleViewer Role = "viewer"
+	// RoleWorkload is role of stage workloads, e.g. coordinators, they are identified by service
+	// accounts of tenant namespaces, and can only send logs and test reports of WorkflowRuns.
+	RoleWorkload Role = "workload"
+)
+
+// RoleBinding binds a role to a user
+type RoleBinding struct {
+	// Role bound to the user
+	Role Role `json:"role"`
+	// Tenant where the role applies, it's not used by system admin.
+	Tenant string `json:"tenant,omitempty"`
+	// Projects where the role applies, it's only used by project developer and viewer, and all
+	// projects of the tenant are applied if empty.
+	Projects []string `json:"projects,omitempty"`
+}
+
+// APIToken is a token for users to access Cyclone API, it's sent in 'Authorization' header as
+// bearer token.
+type APIToken struct {
+	// ID identifies the token
+	ID string `json:"id"`
+	// Description describes usage of the token
+	Description string `json:"description,omitempty"`
+	// Token is value of the token, it's only returned when the token is created.
+	Token string `json:"token,omitempty"`
+	// CreationTime records the time of the token creation
+	CreationTime string `json:"creationTime"`
+	// ExpirationTime is the time when the token expires, the token never expires if empty.
+	ExpirationTime string `json:"expirationTime,omitempty"`
+}
+
+// Identity is the authenticated identity of the API caller
+type Identity struct {
+	// User is name of the user, or name of the service account for workloads
+	User string `json:"user"`
+	// Roles are roles bound to the user
+	Roles []RoleBinding `json:"roles"`
+}
+
+// AuditRecord records a mutating API operation.
+type AuditRecord struct {
+	// Time is the time the operation started, in RFC3339 format with nanoseconds.
+	Time string `json:"time"`
+	// Actor is the user performed the operation, it's empty if authentication is disabled or failed.
+	Actor string `json:"actor"`
+	// SourceIP is the address the request came from.
+	SourceIP string `json:"sourceIP"`
+	// Tenant and Project where the operation performed.
+	Tenant  string `json:"tenant,omitempty"`
+	Project string `json:"project,omitempty"`
+	// Resource is type of the resource operated, e.g. 'workflows'.
+	Resource string `json:"resource"`
+	// Name is name of the resource operated, it's empty for creation.
+	Name string `json:"name,omitempty"`
+	// Verb is the operation, e.g. 'create', 'update', 'delete', or sub resource operated, e.g. 'stop'.
+	Verb string `json:"verb"`
+	// Method and Path are HTTP method and path of the request.
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	// Request is summary of the request body, sensitive values like passwords are redacted.
+	Request string `json:"request,omitempty"`
+	// StatusCode is HTTP status code of the response.
+	StatusCode int `json:"statusCode"`
+	// Result is 'success' or 'failure' of the operation.
+	Result string `json:"result"`
+	// Error is error message of failed operations.
+	Error string `json:"error,omitempty"`
+	// LatencyMillis is time taken by the operation in milliseconds.
+	LatencyMillis int64 `json:"latencyMillis"`
+}
+
+const (
+	// AuditResultSuccess means the operation succeeded.
+	AuditResultSuccess = "success"
+	// AuditResultFailure means the operation failed.
+	AuditResultFailure = "failure"
+)
+
+// WebhookResponse is the response to webhooks received.
+type WebhookResponse struct {
+	// WorkflowRuns are names of WorkflowRuns triggered by the webhook.
+	WorkflowRuns []string `json:"workflowRuns"`
+}
@@ -0,0 +1,139 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/caicloud/nirvana/log"
+
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+)
+
+const (
+	// SinkFile writes audit records to a file, records can be queried.
+	SinkFile = "file"
+	// SinkStdout writes audit records to stdout in JSON, so that they can be collected with
+	// container logs.
+	SinkStdout = "stdout"
+	// SinkNone disables audit.
+	SinkNone = "none"
+)
+
+// Sink is where audit records go.
+type Sink interface {
+	// Write writes the audit record.
+	Write(record *api.AuditRecord) error
+}
+
+// Querier is implemented by sinks that support querying records.
+type Querier interface {
+	// Query queries records matching the filter, newest first.
+	Query(filter *Filter) ([]api.AuditRecord, error)
+}
+
+// Filter filters audit records, empty fields match all.
+type Filter struct {
+	Tenant string
+	Actor  string
+	// Since and Until limit time range of records, both are inclusive.
+	Since time.Time
+	Until time.Time
+}
+
+// Match checks whether the record matches the filter.
+func (f *Filter) Match(r *api.AuditRecord) bool {
+	if f.Tenant != "" && r.Tenant != f.Tenant {
+		return false
+	}
+	if f.Actor != "" && r.Actor != f.Actor {
+		return false
+	}
+	if f.Since.IsZero() && f.Until.IsZero() {
+		return true
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, r.Time)
+	if err != nil {
+		return false
+	}
+	return !t.Before(f.Since) && (f.Until.IsZero() || !t.After(f.Until))
+}
+
+// sink is the sink audit records go to, it's nil if audit is disabled.
+var sink Sink
+
+// Init initializes the sink by type, path is used by file sink.
+func Init(sinkType, path string) error {
+	switch sinkType {
+	case SinkFile:
+		s, err := NewFileSink(path, defaultMaxFileSize)
+		if err != nil {
+			return err
+		}
+		sink = s
+	case SinkStdout:
+		sink = NewWriterSink(os.Stdout)
+	case SinkNone, "":
+		sink = nil
+		log.Warning("Audit disabled")
+	default:
+		return fmt.Errorf("unsupported audit sink '%s'", sinkType)
+	}
+	return nil
+}
+
+// SetSink sets the sink, it's used to plug in other sinks.
+func SetSink(s Sink) {
+	sink = s
+}
+
+// Enabled checks whether audit is enabled.
+func Enabled() bool {
+	return sink != nil
+}
+
+// Record records the audit record, errors are logged only, since they should not fail the API.
+func Record(record *api.AuditRecord) {
+	if sink == nil {
+		return
+	}
+	if err := sink.Write(record); err != nil {
+		log.Errorf("Write audit record of %s %s error: %v", record.Method, record.Path, err)
+	}
+}
+
+// Query queries audit records, it's only supported by sinks implementing Querier.
+func Query(filter *Filter) ([]api.AuditRecord, error) {
+	q, ok := sink.(Querier)
+	if !ok {
+		return nil, fmt.Errorf("querying audit records is not supported by the audit sink")
+	}
+	return q.Query(filter)
+}
+
+// writerSink writes audit records as JSON lines to a writer.
+type writerSink struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+// NewWriterSink creates a sink writing records as JSON lines to the writer.
+func NewWriterSink(w io.Writer) Sink {
+	return &writerSink{w: w}
+}
+
+func (s *writerSink) Write(record *api.AuditRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, err = s.w.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,94 @@
+package audit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+)
+
+func TestSummarize(t *testing.T) {
+	body := `{
+		"metadata": {"name": "cluster"},
+		"spec": {
+			"cluster": {"credential": {"server": "https://k8s", "bearerToken": "abc", "password": "p"}},
+			"general": [{"name": "API_SECRET", "value": "s"}, {"name": "url", "value": "http://x"}],
+			"kubeConfig": {"users": [{"name": "u", "user": {"client-key-data": "key"}}]}
+		}
+	}`
+	summary := Summarize([]byte(body))
+	assert.Contains(t, summary, `"server":"https://k8s"`)
+	assert.Contains(t, summary, `"bearerToken":"******"`)
+	assert.Contains(t, summary, `"password":"******"`)
+	assert.Contains(t, summary, `{"name":"API_SECRET","value":"******"}`)
+	assert.Contains(t, summary, `{"name":"url","value":"http://x"}`)
+	assert.Contains(t, summary, `"client-key-data":"******"`)
+
+	assert.Equal(t, "", Summarize([]byte("not json")))
+	assert.Equal(t, "", Summarize(nil))
+	long := Summarize([]byte(`"` + string(make([]byte, 3000)) + `"`))
+	assert.True(t, len(long) < 3000)
+}
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Small size limit to rotate on each record.
+	s, err := NewFileSink(filepath.Join(dir, "audit", "audit.log"), 10)
+	assert.Nil(t, err)
+
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	records := []api.AuditRecord{
+		{Actor: "alice", Tenant: "t1", Verb: "create"},
+		{Actor: "bob", Tenant: "t1", Verb: "update"},
+		{Actor: "alice", Tenant: "t2", Verb: "delete"},
+	}
+	for i := range records {
+		records[i].Time = start.Add(time.Duration(i) * time.Hour).Format(time.RFC3339Nano)
+		assert.Nil(t, s.Write(&records[i]))
+	}
+
+	// Only the last rotated file is kept.
+	q := s.(Querier)
+	all, err := q.Query(&Filter{})
+	assert.Nil(t, err)
+	assert.Equal(t, []api.AuditRecord{records[2], records[1]}, all)
+
+	filtered, err := q.Query(&Filter{Actor: "alice"})
+	assert.Nil(t, err)
+	assert.Equal(t, []api.AuditRecord{records[2]}, filtered)
+
+	filtered, err = q.Query(&Filter{Tenant: "t1", Until: start.Add(time.Hour)})
+	assert.Nil(t, err)
+	assert.Equal(t, []api.AuditRecord{records[1]}, filtered)
+
+	filtered, err = q.Query(&Filter{Since: start.Add(90 * time.Minute)})
+	assert.Nil(t, err)
+	assert.Equal(t, []api.AuditRecord{records[2]}, filtered)
+
+	// Records are appended after reopened.
+	s, err = NewFileSink(filepath.Join(dir, "audit", "audit.log"), 1<<20)
+	assert.Nil(t, err)
+	assert.Nil(t, s.Write(&records[0]))
+	all, err = s.(Querier).Query(&Filter{})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(all))
+}
+
+func TestQueryUnsupported(t *testing.T) {
+	SetSink(NewWriterSink(ioutil.Discard))
+	defer SetSink(nil)
+
+	assert.True(t, Enabled())
+	_, err := Query(&Filter{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,138 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/caicloud/nirvana/log"
+
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+)
+
+// defaultMaxFileSize is the default max size of audit log file, it's rotated when exceeded.
+const defaultMaxFileSize = 100 << 20
+
+// maxLineSize is max size of a record line when reading audit log files.
+const maxLineSize = 1 << 20
+
+// fileSink writes audit records as JSON lines to a file. The file is rotated to '<path>.1' when
+// its size exceeds the limit, only one rotated file is kept.
+type fileSink struct {
+	path    string
+	maxSize int64
+
+	lock sync.Mutex
+	file *os.File
+	size int64
+}
+
+var _ Querier = &fileSink{}
+
+// NewFileSink creates a sink writing records to the file.
+func NewFileSink(path string, maxSize int64) (Sink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, err
+	}
+
+	s := &fileSink{path: path, maxSize: maxSize}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	s.file = f
+	s.size = info.Size()
+	return nil
+}
+
+// rotate moves the current file to '<path>.1' and opens a new one.
+func (s *fileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		log.Warningf("Close audit log file error: %v", err)
+	}
+	if err := os.Rename(s.path, s.path+".1"); err != nil {
+		return err
+	}
+	return s.open()
+}
+
+func (s *fileSink) Write(record *api.AuditRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.size > 0 && s.size+int64(len(data)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.file.Write(data)
+	s.size += int64(n)
+	return err
+}
+
+// Query reads records from the rotated file and the current file, newest records are returned
+// first.
+func (s *fileSink) Query(filter *Filter) ([]api.AuditRecord, error) {
+	// Hold the lock to avoid reading while rotating.
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	records := []api.AuditRecord{}
+	for _, path := range []string{s.path + ".1", s.path} {
+		var err error
+		if records, err = readRecords(path, filter, records); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	return records, nil
+}
+
+// readRecords reads records matching the filter from the file and appends them to records.
+func readRecords(path string, filter *Filter, records []api.AuditRecord) ([]api.AuditRecord, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		record := api.AuditRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			log.Warningf("Skip malformed audit record in %s: %v", path, err)
+			continue
+		}
+		if filter.Match(&record) {
+			records = append(records, record)
+		}
+	}
+	return records, scanner.Err()
+}
@@ -0,0 +1,74 @@
+package audit
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// maxSummarySize is max size of request summaries, longer ones are truncated.
+const maxSummarySize = 2048
+
+// redacted replaces sensitive values in request summaries.
+const redacted = "******"
+
+// sensitiveKeys are substrings of keys whose values are sensitive, keys are matched case
+// insensitively with '-' and '_' removed, e.g. 'client-key-data' in kube config.
+var sensitiveKeys = []string{"password", "passwd", "secret", "token", "keydata", "privatekey"}
+
+// sensitive checks whether values of the key are sensitive.
+func sensitive(key string) bool {
+	key = strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(key))
+	for _, k := range sensitiveKeys {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// Summarize summarizes JSON request body with sensitive values redacted, it's truncated if too
+// long. Bodies that are not JSON are not recorded, since they may contain anything.
+func Summarize(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return ""
+	}
+	data, err := json.Marshal(redact(v))
+	if err != nil {
+		return ""
+	}
+
+	if len(data) > maxSummarySize {
+		return string(data[:maxSummarySize]) + "...(truncated)"
+	}
+	return string(data)
+}
+
+// redact redacts sensitive values in the JSON value. Values of sensitive keys are redacted, and
+// for objects of name and value pairs, e.g. parameters, values are redacted if names are sensitive.
+func redact(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, value := range t {
+			if sensitive(k) && value != nil {
+				t[k] = redacted
+				continue
+			}
+			t[k] = redact(value)
+		}
+		if name, ok := t["name"].(string); ok && sensitive(name) {
+			if _, ok := t["value"]; ok {
+				t["value"] = redacted
+			}
+		}
+	case []interface{}:
+		for i := range t {
+			t[i] = redact(t[i])
+		}
+	}
+	return v
+}
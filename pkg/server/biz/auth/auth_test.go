@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"encoding/hex"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	authn_v1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/caicloud/cyclone/pkg/k8s/clientset/fake"
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/server/handler"
+)
+
+func TestPassword(t *testing.T) {
+	hash, err := hashPassword("secret")
+	assert.Nil(t, err)
+	assert.True(t, verifyPassword(hash, "secret"))
+	assert.False(t, verifyPassword(hash, "Secret"))
+	assert.False(t, verifyPassword("plain", "plain"))
+
+	another, err := hashPassword("secret")
+	assert.Nil(t, err)
+	assert.NotEqual(t, hash, another)
+}
+
+func TestPBKDF2(t *testing.T) {
+	// Test vector from RFC 7914, section 11.
+	key := pbkdf2([]byte("passwd"), []byte("salt"), 1, 64)
+	assert.Equal(t, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc"+
+		"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783", hex.EncodeToString(key))
+}
+
+func TestAuthenticate(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	handler.InitHandlers(client)
+
+	_, err := CreateUser(&api.User{
+		Metadata: api.Metadata{Name: "Alice"},
+	})
+	assert.Error(t, err)
+	_, err = CreateUser(&api.User{
+		Metadata: api.Metadata{Name: "alice"},
+		Spec: api.UserSpec{
+			Roles: []api.RoleBinding{{Role: api.RoleViewer}},
+		},
+	})
+	assert.Error(t, err)
+
+	user, err := CreateUser(&api.User{
+		Metadata: api.Metadata{Name: "alice"},
+		Spec: api.UserSpec{
+			Password: "secret",
+			Roles:    []api.RoleBinding{{Role: api.RoleViewer, Tenant: "t1"}},
+		},
+	})
+	assert.Nil(t, err)
+	assert.Empty(t, user.Spec.Password)
+
+	req, _ := http.NewRequest(http.MethodGet, "/apis/v1alpha1/projects", nil)
+	_, err = Authenticate(req)
+	assert.Equal(t, ErrNoCredentials, err)
+
+	req.SetBasicAuth("alice", "wrong")
+	_, err = Authenticate(req)
+	assert.Error(t, err)
+
+	req.SetBasicAuth("alice", "secret")
+	id, err := Authenticate(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", id.User)
+	assert.Equal(t, []string{"t1"}, Tenants(id))
+
+	// Password is kept if not given when updating.
+	_, err = UpdateUser("alice", &api.User{
+		Spec: api.UserSpec{
+			Roles: []api.RoleBinding{{Role: api.RoleTenantAdmin, Tenant: "t2"}},
+		},
+	})
+	assert.Nil(t, err)
+	id, err = Authenticate(req)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"t2"}, Tenants(id))
+
+	token, err := CreateToken("alice", &api.APIToken{Description: "ci"})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, token.Token)
+	tokens, err := ListTokens("alice")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(tokens))
+	assert.Empty(t, tokens[0].Token)
+
+	req, _ = http.NewRequest(http.MethodGet, "/apis/v1alpha1/projects", nil)
+	req.Header.Set("Authorization", "Bearer "+token.Token)
+	id, err = Authenticate(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", id.User)
+
+	req.Header.Set("Authorization", "Bearer "+token.ID+".wrong")
+	_, err = Authenticate(req)
+	assert.Error(t, err)
+
+	expired, err := CreateToken("alice", &api.APIToken{ExpirationTime: time.Now().Add(-time.Minute).Format(time.RFC3339)})
+	assert.Nil(t, err)
+	req.Header.Set("Authorization", "Bearer "+expired.Token)
+	_, err = Authenticate(req)
+	assert.Error(t, err)
+
+	assert.Nil(t, DeleteUser("alice"))
+	tokens, err = ListTokens("alice")
+	assert.Nil(t, err)
+	assert.Empty(t, tokens)
+	req.Header.Set("Authorization", "Bearer "+token.Token)
+	_, err = Authenticate(req)
+	assert.Error(t, err)
+}
+
+func TestAuthenticateServiceAccount(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authn_v1.TokenReview)
+		switch review.Spec.Token {
+		case "workload":
+			review.Status.Authenticated = true
+			review.Status.User.Username = "system:serviceaccount:cyclone--t1:default"
+		case "system":
+			review.Status.Authenticated = true
+			review.Status.User.Username = "system:serviceaccount:kube-system:default"
+		}
+		return true, review, nil
+	})
+	handler.InitHandlers(client)
+
+	req, _ := http.NewRequest(http.MethodGet, "/apis/v1alpha1/projects", nil)
+	req.Header.Set("Authorization", "Bearer workload")
+	id, err := Authenticate(req)
+	assert.Nil(t, err)
+	assert.Equal(t, []api.RoleBinding{{Role: api.RoleWorkload, Tenant: "t1"}}, id.Roles)
+
+	for _, token := range []string{"system", "invalid"} {
+		req.Header.Set("Authorization", "Bearer "+token)
+		_, err = Authenticate(req)
+		assert.Error(t, err, token)
+	}
+
+	// Token in query is only accepted for websocket.
+	req, _ = http.NewRequest(http.MethodGet, "/apis/v1alpha1/streamlogs?access_token=workload", nil)
+	_, err = Authenticate(req)
+	assert.Equal(t, ErrNoCredentials, err)
+	req.Header.Set("Upgrade", "websocket")
+	_, err = Authenticate(req)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/caicloud/nirvana/log"
+	authn_v1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/server/common"
+	"github.com/caicloud/cyclone/pkg/server/handler"
+)
+
+const (
+	// AccessTokenQueryParameter is the query parameter to pass bearer token in websocket requests,
+	// since browsers can't set headers for websocket.
+	AccessTokenQueryParameter = "access_token"
+
+	// serviceAccountPrefix is prefix of user names of Kubernetes service accounts.
+	serviceAccountPrefix = "system:serviceaccount:"
+)
+
+// ErrNoCredentials is returned if there are no credentials in the request.
+var ErrNoCredentials = fmt.Errorf("no credentials provided")
+
+// Authenticate authenticates the request with credentials in 'Authorization' header, they can be:
+// - Basic auth with user name and password.
+// - Bearer API token created by users.
+// - Bearer token of Kubernetes service accounts in tenant namespaces, they are used by workloads.
+func Authenticate(req *http.Request) (*api.Identity, error) {
+	if user, password, ok := req.BasicAuth(); ok {
+		return authenticatePassword(user, password)
+	}
+
+	token := bearerToken(req)
+	if token == "" {
+		return nil, ErrNoCredentials
+	}
+	if _, _, ok := parseToken(token); ok {
+		user, err := verifyToken(token)
+		if err != nil {
+			return nil, err
+		}
+		return identity(user)
+	}
+	return authenticateServiceAccount(token)
+}
+
+// bearerToken gets bearer token from 'Authorization' header, or from query parameter for
+// websocket requests.
+func bearerToken(req *http.Request) string {
+	header := req.Header.Get("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+
+	if strings.EqualFold(req.Header.Get("Upgrade"), "websocket") {
+		return req.URL.Query().Get(AccessTokenQueryParameter)
+	}
+	return ""
+}
+
+// authenticatePassword authenticates the user with password. Local users are verified with
+// their password hashes, others are verified by password connectors of external identity providers.
+func authenticatePassword(name, password string) (*api.Identity, error) {
+	var stored *storedUser
+	if converted, err := userName(name); err == nil {
+		var user *api.User
+		user, stored, err = getUser(converted)
+		if err != nil && !errors.IsNotFound(err) {
+			log.Warningf("Get user %s error: %v", converted, err)
+			return nil, fmt.Errorf("invalid user name or password")
+		}
+		if err == nil && stored.Provider == "" {
+			if stored.PasswordHash == "" || !verifyPassword(stored.PasswordHash, password) {
+				return nil, fmt.Errorf("invalid user name or password")
+			}
+			return &api.Identity{User: user.Metadata.Name, Roles: user.Spec.Roles}, nil
+		}
+	}
+
+	for _, c := range passwordConnectors {
+		if stored != nil && stored.Provider != c.Provider() {
+			continue
+		}
+		ext, err := c.Login(name, password)
+		if err != nil {
+			log.Warningf("Login %s with %s error: %v", name, c.Provider(), err)
+			continue
+		}
+		return provisionUser(ext)
+	}
+	return nil, fmt.Errorf("invalid user name or password")
+}
+
+// identity gets identity of the user.
+func identity(name string) (*api.Identity, error) {
+	user, err := GetUser(name)
+	if err != nil {
+		log.Warningf("Get user %s error: %v", name, err)
+		return nil, fmt.Errorf("user %s not found", name)
+	}
+
+	return &api.Identity{User: user.Metadata.Name, Roles: user.Spec.Roles}, nil
+}
+
+// authenticateServiceAccount authenticates the token with Kubernetes TokenReview, only service
+// accounts in tenant namespaces are accepted, they get workload role in the tenant.
+func authenticateServiceAccount(token string) (*api.Identity, error) {
+	review, err := handler.K8sClient.AuthenticationV1().TokenReviews().Create(&authn_v1.TokenReview{
+		Spec: authn_v1.TokenReviewSpec{
+			Token: token,
+		},
+	})
+	if err != nil {
+		log.Errorf("Review token error: %v", err)
+		return nil, fmt.Errorf("invalid token")
+	}
+	if !review.Status.Authenticated {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	// User name of service accounts is 'system:serviceaccount:<namespace>:<name>'.
+	username := review.Status.User.Username
+	parts := strings.Split(strings.TrimPrefix(username, serviceAccountPrefix), ":")
+	if !strings.HasPrefix(username, serviceAccountPrefix) || len(parts) != 2 || common.TenantNamespace(common.NamespaceTenant(parts[0])) != parts[0] {
+		return nil, fmt.Errorf("%s is not a service account of tenants", username)
+	}
+
+	return &api.Identity{
+		User: username,
+		Roles: []api.RoleBinding{
+			{Role: api.RoleWorkload, Tenant: common.NamespaceTenant(parts[0])},
+		},
+	}, nil
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a copy of the context with the identity of API caller.
+func WithIdentity(ctx context.Context, id *api.Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// IdentityFrom gets identity of API caller from the context, nil is returned if authentication
+// is not enabled.
+func IdentityFrom(ctx context.Context) *api.Identity {
+	id, _ := ctx.Value(identityKey{}).(*api.Identity)
+	return id
+}
+
+// IsSystemAdmin checks whether the identity has system admin role.
+func IsSystemAdmin(id *api.Identity) bool {
+	for _, b := range id.Roles {
+		if b.Role == api.RoleSystemAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// Tenants gets tenants where the identity has roles, system admin role is not counted.
+func Tenants(id *api.Identity) []string {
+	var tenants []string
+	seen := make(map[string]bool)
+	for _, b := range id.Roles {
+		if b.Role == api.RoleSystemAdmin || b.Tenant == "" || seen[b.Tenant] {
+			continue
+		}
+		seen[b.Tenant] = true
+		tenants = append(tenants, b.Tenant)
+	}
+	return tenants
+}
@@ -0,0 +1,36 @@
+package connector
+
+// Identity is identity of a user verified by an external identity provider.
+type Identity struct {
+	// Provider is name of the identity provider, e.g. 'ldap'.
+	Provider string
+	// ID is the unique and immutable ID of the user in the identity provider, e.g. DN in LDAP,
+	// issuer and subject in OIDC. Accounts are bound to it, since user names may change.
+	ID string
+	// User is name of the user in the identity provider.
+	User string
+	// Groups are names of groups the user belongs to in the identity provider.
+	Groups []string
+}
+
+// PasswordConnector authenticates users with user name and password against an identity
+// provider, e.g. LDAP.
+type PasswordConnector interface {
+	// Provider returns name of the identity provider.
+	Provider() string
+	// Login verifies user name and password, and returns identity of the user.
+	Login(user, password string) (*Identity, error)
+}
+
+// RedirectConnector authenticates users by redirecting them to an identity provider, which
+// redirects them back with an authorization code after login, e.g. OIDC.
+type RedirectConnector interface {
+	// Provider returns name of the identity provider.
+	Provider() string
+	// LoginURL returns URL of the identity provider to redirect users to, the state is passed
+	// back in callback, it should be unguessable.
+	LoginURL(state string) (string, error)
+	// HandleCallback exchanges the authorization code for identity of the user, state is the
+	// one given to LoginURL.
+	HandleCallback(code, state string) (*Identity, error)
+}
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/caicloud/nirvana/log"
+
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/server/biz/auth/connector"
+	"github.com/caicloud/cyclone/pkg/server/biz/auth/ldap"
+	"github.com/caicloud/cyclone/pkg/server/biz/auth/oidc"
+)
+
+// loginTokenTTL is the lifetime of API tokens issued to users logged in with redirect connectors.
+const loginTokenTTL = 24 * time.Hour
+
+// ProvidersConfig is config of external identity providers.
+type ProvidersConfig struct {
+	// LDAP is config of LDAP, users login with Basic auth.
+	LDAP *ldap.Config `json:"ldap,omitempty"`
+	// OIDC is config of OIDC provider, users login at '/auth/oidc/login'.
+	OIDC *oidc.Config `json:"oidc,omitempty"`
+}
+
+var (
+	// passwordConnectors are connectors to verify user name and password, they are tried in order
+	// for users not found locally.
+	passwordConnectors []connector.PasswordConnector
+	// redirectConnectors are connectors to login by redirecting, keyed by provider name.
+	redirectConnectors = map[string]connector.RedirectConnector{}
+)
+
+// InitConnectors initializes connectors of external identity providers from the config file,
+// nothing is done if path of the config file is empty.
+func InitConnectors(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	config := &ProvidersConfig{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return fmt.Errorf("unmarshal identity providers config error: %v", err)
+	}
+
+	if config.LDAP != nil {
+		c, err := ldap.New(config.LDAP)
+		if err != nil {
+			return err
+		}
+		RegisterPasswordConnector(c)
+	}
+	if config.OIDC != nil {
+		c, err := oidc.New(config.OIDC)
+		if err != nil {
+			return err
+		}
+		RegisterRedirectConnector(c)
+	}
+	return nil
+}
+
+// RegisterPasswordConnector registers a connector to verify user name and password.
+func RegisterPasswordConnector(c connector.PasswordConnector) {
+	log.Infof("Identity provider %s registered", c.Provider())
+	passwordConnectors = append(passwordConnectors, c)
+}
+
+// RegisterRedirectConnector registers a connector to login by redirecting.
+func RegisterRedirectConnector(c connector.RedirectConnector) {
+	log.Infof("Identity provider %s registered", c.Provider())
+	redirectConnectors[c.Provider()] = c
+}
+
+// LoginURL gets URL of the identity provider to redirect users to.
+func LoginURL(provider, state string) (string, error) {
+	c, ok := redirectConnectors[provider]
+	if !ok {
+		return "", fmt.Errorf("identity provider %s not found", provider)
+	}
+	return c.LoginURL(state)
+}
+
+// LoginCallback handles callback from the identity provider, user is provisioned with roles from
+// its groups, and an API token is issued to it.
+func LoginCallback(provider, code, state string) (*api.APIToken, error) {
+	c, ok := redirectConnectors[provider]
+	if !ok {
+		return nil, fmt.Errorf("identity provider %s not found", provider)
+	}
+
+	ext, err := c.HandleCallback(code, state)
+	if err != nil {
+		log.Warningf("Login with %s error: %v", provider, err)
+		return nil, err
+	}
+	id, err := provisionUser(ext)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tokens are issued at each login, clean up expired ones.
+	deleteExpiredTokens(id.User)
+	return CreateToken(id.User, &api.APIToken{
+		Description:    fmt.Sprintf("Login with %s", provider),
+		ExpirationTime: time.Now().Add(loginTokenTTL).Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,181 @@
+package ldap
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// BER tag classes and the constructed flag, LDAP messages are encoded with the subset of BER
+// defined in RFC 4511, section 5.1.
+const (
+	classApplication byte = 0x40
+	classContext     byte = 0x80
+	constructed      byte = 0x20
+)
+
+// Universal tags used in LDAP messages.
+const (
+	tagBoolean     byte = 0x01
+	tagInteger     byte = 0x02
+	tagOctetString byte = 0x04
+	tagEnumerated  byte = 0x0a
+	tagSequence         = constructed | 0x10
+	tagSet              = constructed | 0x11
+)
+
+// maxPacketSize limits size of packets read, to protect from malicious servers.
+const maxPacketSize = 16 << 20
+
+// packet is a BER element, value holds contents of primitive elements, while children holds
+// elements in constructed ones.
+type packet struct {
+	tag      byte
+	value    []byte
+	children []*packet
+}
+
+func newSequence(tag byte, children ...*packet) *packet {
+	return &packet{tag: tag | constructed, children: children}
+}
+
+func newString(tag byte, s string) *packet {
+	return &packet{tag: tag, value: []byte(s)}
+}
+
+func newInteger(tag byte, v int64) *packet {
+	// Minimal two's complement encoding.
+	n := 1
+	for i := v; i > 127 || i < -128; i >>= 8 {
+		n++
+	}
+	b := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+	return &packet{tag: tag, value: b}
+}
+
+func newBoolean(v bool) *packet {
+	if v {
+		return &packet{tag: tagBoolean, value: []byte{0xff}}
+	}
+	return &packet{tag: tagBoolean, value: []byte{0x00}}
+}
+
+func (p *packet) isConstructed() bool {
+	return p.tag&constructed != 0
+}
+
+// integer decodes contents of INTEGER or ENUMERATED elements.
+func (p *packet) integer() (int64, error) {
+	if len(p.value) == 0 || len(p.value) > 8 {
+		return 0, fmt.Errorf("invalid integer length %d", len(p.value))
+	}
+	v := int64(int8(p.value[0]))
+	for _, b := range p.value[1:] {
+		v = v<<8 | int64(b)
+	}
+	return v, nil
+}
+
+// child gets the i-th child, nil is returned if it doesn't exist.
+func (p *packet) child(i int) *packet {
+	if i < len(p.children) {
+		return p.children[i]
+	}
+	return nil
+}
+
+// bytes encodes the packet.
+func (p *packet) bytes() []byte {
+	content := p.value
+	if p.isConstructed() {
+		content = nil
+		for _, c := range p.children {
+			content = append(content, c.bytes()...)
+		}
+	}
+
+	b := []byte{p.tag}
+	b = append(b, encodeLength(len(content))...)
+	return append(b, content...)
+}
+
+func encodeLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+
+	var b []byte
+	for ; n > 0; n >>= 8 {
+		b = append([]byte{byte(n)}, b...)
+	}
+	return append([]byte{0x80 | byte(len(b))}, b...)
+}
+
+// reader is the reader packets are read from.
+type reader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// readPacket reads a packet from the reader.
+func readPacket(r reader) (*packet, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if tag&0x1f == 0x1f {
+		return nil, fmt.Errorf("multi-byte tags are not supported")
+	}
+
+	l, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	length := int(l)
+	if l&0x80 != 0 {
+		n := int(l & 0x7f)
+		if n == 0 || n > 4 {
+			return nil, fmt.Errorf("unsupported length of %d bytes", n)
+		}
+		length = 0
+		for i := 0; i < n; i++ {
+			b, err := r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			length = length<<8 | int(b)
+		}
+	}
+	if length > maxPacketSize {
+		return nil, fmt.Errorf("packet too large: %d bytes", length)
+	}
+
+	content := make([]byte, length)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return nil, err
+	}
+	return parsePacket(tag, content)
+}
+
+// parsePacket parses contents of the element with the tag.
+func parsePacket(tag byte, content []byte) (*packet, error) {
+	p := &packet{tag: tag}
+	if !p.isConstructed() {
+		p.value = content
+		return p, nil
+	}
+
+	r := bytes.NewReader(content)
+	for r.Len() > 0 {
+		c, err := readPacket(r)
+		if err != nil {
+			return nil, err
+		}
+		p.children = append(p.children, c)
+	}
+	return p, nil
+}
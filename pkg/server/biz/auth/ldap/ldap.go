@@ -0,0 +1,415 @@
+package ldap
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/caicloud/nirvana/log"
+
+	"github.com/caicloud/cyclone/pkg/server/biz/auth/connector"
+)
+
+// Provider is name of the LDAP identity provider.
+const Provider = "ldap"
+
+// timeout is the time limit of a login, including connecting, binding and searching.
+const timeout = 10 * time.Second
+
+// startTLSOID is OID of the StartTLS extended operation.
+const startTLSOID = "1.3.6.1.4.1.1466.20037"
+
+// Application tags of LDAP operations, see RFC 4511, section 4.
+const (
+	opBindRequest      = classApplication | constructed | 0
+	opBindResponse     = classApplication | constructed | 1
+	opUnbindRequest    = classApplication | 2
+	opSearchRequest    = classApplication | constructed | 3
+	opSearchEntry      = classApplication | constructed | 4
+	opSearchDone       = classApplication | constructed | 5
+	opSearchReference  = classApplication | constructed | 19
+	opExtendedRequest  = classApplication | constructed | 23
+	opExtendedResponse = classApplication | constructed | 24
+)
+
+// Context tags of search filters.
+const (
+	filterAnd      = classContext | constructed | 0
+	filterEquality = classContext | constructed | 3
+)
+
+// resultSuccess is result code of successful LDAP operations.
+const resultSuccess = 0
+
+// Config is config of the LDAP connector. Users are searched by their names, and then verified by
+// binding with their DNs and passwords. Groups are searched by member DNs.
+type Config struct {
+	// URL is URL of the LDAP server, e.g. 'ldap://ldap.example.com' or 'ldaps://ldap.example.com'.
+	URL string `json:"url"`
+	// StartTLS indicates whether to upgrade 'ldap://' connections to TLS by StartTLS.
+	StartTLS bool `json:"startTLS"`
+	// InsecureSkipVerify indicates whether to skip verification of server certificates.
+	InsecureSkipVerify bool `json:"insecureSkipVerify"`
+
+	// BindDN and BindPassword are credentials to search users and groups, anonymous search is
+	// performed if BindDN is empty.
+	BindDN       string `json:"bindDN"`
+	BindPassword string `json:"bindPassword"`
+
+	// UserBaseDN is the base DN to search users, e.g. 'ou=people,dc=example,dc=com'.
+	UserBaseDN string `json:"userBaseDN"`
+	// UserObjectClass is object class of user entries, defaults to 'person'.
+	UserObjectClass string `json:"userObjectClass"`
+	// UserAttribute is attribute of user names, defaults to 'uid'.
+	UserAttribute string `json:"userAttribute"`
+
+	// GroupBaseDN is the base DN to search groups, groups are not searched if it's empty.
+	GroupBaseDN string `json:"groupBaseDN"`
+	// GroupObjectClass is object class of group entries, defaults to 'groupOfNames'.
+	GroupObjectClass string `json:"groupObjectClass"`
+	// GroupMemberAttribute is attribute of group members, whose values are DNs of users,
+	// defaults to 'member'.
+	GroupMemberAttribute string `json:"groupMemberAttribute"`
+	// GroupNameAttribute is attribute of group names, defaults to 'cn'.
+	GroupNameAttribute string `json:"groupNameAttribute"`
+}
+
+// Connector authenticates users against LDAP server.
+type Connector struct {
+	config  Config
+	address string
+	tls     *tls.Config
+	// implicitTLS indicates whether to connect with TLS directly, it's for 'ldaps://'.
+	implicitTLS bool
+}
+
+var _ connector.PasswordConnector = &Connector{}
+
+// New creates a LDAP connector with the config.
+func New(config *Config) (*Connector, error) {
+	c := &Connector{config: *config}
+	if c.config.UserBaseDN == "" {
+		return nil, fmt.Errorf("userBaseDN of LDAP is required")
+	}
+	if c.config.UserObjectClass == "" {
+		c.config.UserObjectClass = "person"
+	}
+	if c.config.UserAttribute == "" {
+		c.config.UserAttribute = "uid"
+	}
+	if c.config.GroupObjectClass == "" {
+		c.config.GroupObjectClass = "groupOfNames"
+	}
+	if c.config.GroupMemberAttribute == "" {
+		c.config.GroupMemberAttribute = "member"
+	}
+	if c.config.GroupNameAttribute == "" {
+		c.config.GroupNameAttribute = "cn"
+	}
+
+	u, err := url.Parse(c.config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LDAP URL %s: %v", c.config.URL, err)
+	}
+	port := u.Port()
+	switch u.Scheme {
+	case "ldap":
+		if port == "" {
+			port = "389"
+		}
+	case "ldaps":
+		if port == "" {
+			port = "636"
+		}
+		c.implicitTLS = true
+	default:
+		return nil, fmt.Errorf("unsupported LDAP URL scheme '%s'", u.Scheme)
+	}
+	c.address = net.JoinHostPort(u.Hostname(), port)
+	c.tls = &tls.Config{
+		ServerName:         u.Hostname(),
+		InsecureSkipVerify: c.config.InsecureSkipVerify,
+	}
+
+	return c, nil
+}
+
+// Provider returns name of the identity provider.
+func (c *Connector) Provider() string {
+	return Provider
+}
+
+// Login verifies user name and password by searching the user and binding as it, and then
+// searches groups of the user.
+func (c *Connector) Login(user, password string) (*connector.Identity, error) {
+	// Binding with empty password is unauthenticated bind, it always succeeds.
+	if user == "" || password == "" {
+		return nil, fmt.Errorf("user name and password are required")
+	}
+
+	lc, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer lc.close()
+
+	if err := c.bindService(lc); err != nil {
+		return nil, err
+	}
+	entries, err := lc.search(c.config.UserBaseDN, and(
+		equality("objectClass", c.config.UserObjectClass),
+		equality(c.config.UserAttribute, user),
+	), c.config.UserAttribute)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) != 1 {
+		log.Warningf("Found %d LDAP entries for user %s", len(entries), user)
+		return nil, fmt.Errorf("invalid user name or password")
+	}
+	dn := entries[0].dn
+	if err := lc.bind(dn, password); err != nil {
+		log.Warningf("Bind LDAP as %s error: %v", dn, err)
+		return nil, fmt.Errorf("invalid user name or password")
+	}
+
+	id := &connector.Identity{Provider: Provider, ID: dn, User: user}
+	if names := entries[0].get(c.config.UserAttribute); len(names) > 0 {
+		id.User = names[0]
+	}
+	if c.config.GroupBaseDN == "" {
+		return id, nil
+	}
+
+	if err := c.bindService(lc); err != nil {
+		return nil, err
+	}
+	groups, err := lc.search(c.config.GroupBaseDN, and(
+		equality("objectClass", c.config.GroupObjectClass),
+		equality(c.config.GroupMemberAttribute, dn),
+	), c.config.GroupNameAttribute)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range groups {
+		id.Groups = append(id.Groups, g.get(c.config.GroupNameAttribute)...)
+	}
+
+	return id, nil
+}
+
+// bindService binds as the service account to search, nothing is done for anonymous search.
+func (c *Connector) bindService(lc *conn) error {
+	if c.config.BindDN == "" {
+		return nil
+	}
+	if err := lc.bind(c.config.BindDN, c.config.BindPassword); err != nil {
+		return fmt.Errorf("bind LDAP as %s error: %v", c.config.BindDN, err)
+	}
+	return nil
+}
+
+// dial connects to the LDAP server, with TLS if required.
+func (c *Connector) dial() (*conn, error) {
+	nc, err := net.DialTimeout("tcp", c.address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	nc.SetDeadline(time.Now().Add(timeout))
+	if c.implicitTLS {
+		nc = tls.Client(nc, c.tls)
+	}
+
+	lc := &conn{Conn: nc, r: bufio.NewReader(nc)}
+	if c.config.StartTLS && !c.implicitTLS {
+		if err := lc.startTLS(c.tls); err != nil {
+			lc.Close()
+			return nil, err
+		}
+	}
+	return lc, nil
+}
+
+// entry is a LDAP entry returned by search, attribute names are in lower case.
+type entry struct {
+	dn         string
+	attributes map[string][]string
+}
+
+// get gets values of the attribute.
+func (e *entry) get(attribute string) []string {
+	return e.attributes[strings.ToLower(attribute)]
+}
+
+func and(filters ...*packet) *packet {
+	return newSequence(filterAnd, filters...)
+}
+
+func equality(attribute, value string) *packet {
+	return newSequence(filterEquality, newString(tagOctetString, attribute), newString(tagOctetString, value))
+}
+
+// conn is a connection to LDAP server, operations are performed synchronously.
+type conn struct {
+	net.Conn
+	r     *bufio.Reader
+	msgID int64
+}
+
+// send sends a LDAP message with the operation.
+func (c *conn) send(op *packet) error {
+	c.msgID++
+	msg := newSequence(tagSequence, newInteger(tagInteger, c.msgID), op)
+	_, err := c.Write(msg.bytes())
+	return err
+}
+
+// receive receives the operation of a LDAP message responding to the last request.
+func (c *conn) receive() (*packet, error) {
+	msg, err := readPacket(c.r)
+	if err != nil {
+		return nil, err
+	}
+	if msg.tag != tagSequence || len(msg.children) < 2 {
+		return nil, fmt.Errorf("malformed LDAP message")
+	}
+	id, err := msg.child(0).integer()
+	if err != nil {
+		return nil, err
+	}
+	if id != c.msgID {
+		return nil, fmt.Errorf("unexpected LDAP message ID %d, expected %d", id, c.msgID)
+	}
+	return msg.child(1), nil
+}
+
+// request sends the request operation and receives its response, which should be the operation
+// of the tag.
+func (c *conn) request(op *packet, tag byte) (*packet, error) {
+	if err := c.send(op); err != nil {
+		return nil, err
+	}
+	resp, err := c.receive()
+	if err != nil {
+		return nil, err
+	}
+	if resp.tag != tag {
+		return nil, fmt.Errorf("unexpected LDAP response 0x%x, expected 0x%x", resp.tag, tag)
+	}
+	return resp, checkResult(resp)
+}
+
+// checkResult checks LDAPResult in the response.
+func checkResult(resp *packet) error {
+	if len(resp.children) < 3 {
+		return fmt.Errorf("malformed LDAP result")
+	}
+	code, err := resp.child(0).integer()
+	if err != nil {
+		return err
+	}
+	if code != resultSuccess {
+		return fmt.Errorf("LDAP result code %d: %s", code, resp.child(2).value)
+	}
+	return nil
+}
+
+// bind binds as the DN with simple authentication.
+func (c *conn) bind(dn, password string) error {
+	_, err := c.request(newSequence(opBindRequest,
+		newInteger(tagInteger, 3),
+		newString(tagOctetString, dn),
+		newString(classContext|0, password),
+	), opBindResponse)
+	return err
+}
+
+// search searches entries matching the filter in subtree of the base DN.
+func (c *conn) search(baseDN string, filter *packet, attributes ...string) ([]entry, error) {
+	attrs := newSequence(tagSequence)
+	for _, a := range attributes {
+		attrs.children = append(attrs.children, newString(tagOctetString, a))
+	}
+	err := c.send(newSequence(opSearchRequest,
+		newString(tagOctetString, baseDN),
+		// Scope is whole subtree, and aliases are never dereferenced.
+		newInteger(tagEnumerated, 2),
+		newInteger(tagEnumerated, 0),
+		// No size or time limit, and return values of attributes.
+		newInteger(tagInteger, 0),
+		newInteger(tagInteger, 0),
+		newBoolean(false),
+		filter,
+		attrs,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []entry
+	for {
+		resp, err := c.receive()
+		if err != nil {
+			return nil, err
+		}
+
+		switch resp.tag {
+		case opSearchEntry:
+			entries = append(entries, parseEntry(resp))
+		case opSearchReference:
+			// Referrals to other servers are not followed.
+		case opSearchDone:
+			return entries, checkResult(resp)
+		default:
+			return nil, fmt.Errorf("unexpected LDAP search response 0x%x", resp.tag)
+		}
+	}
+}
+
+// parseEntry parses SearchResultEntry.
+func parseEntry(p *packet) entry {
+	e := entry{attributes: make(map[string][]string)}
+	if dn := p.child(0); dn != nil {
+		e.dn = string(dn.value)
+	}
+	if attrs := p.child(1); attrs != nil {
+		for _, a := range attrs.children {
+			if len(a.children) < 2 {
+				continue
+			}
+			name := strings.ToLower(string(a.child(0).value))
+			for _, v := range a.child(1).children {
+				e.attributes[name] = append(e.attributes[name], string(v.value))
+			}
+		}
+	}
+	return e
+}
+
+// startTLS upgrades the connection to TLS.
+func (c *conn) startTLS(config *tls.Config) error {
+	_, err := c.request(newSequence(opExtendedRequest,
+		newString(classContext|0, startTLSOID),
+	), opExtendedResponse)
+	if err != nil {
+		return fmt.Errorf("StartTLS error: %v", err)
+	}
+
+	tc := tls.Client(c.Conn, config)
+	if err := tc.Handshake(); err != nil {
+		return err
+	}
+	c.Conn = tc
+	c.r = bufio.NewReader(tc)
+	return nil
+}
+
+// close unbinds and closes the connection.
+func (c *conn) close() {
+	c.send(&packet{tag: opUnbindRequest})
+	c.Close()
+}
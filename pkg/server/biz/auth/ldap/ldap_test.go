@@ -0,0 +1,187 @@
+package ldap
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testServer is an embedded LDAP server serving bind and search with entries in memory.
+type testServer struct {
+	listener net.Listener
+	// passwords are passwords of entries keyed by DN.
+	passwords map[string]string
+	entries   []entry
+}
+
+func newTestServer(t *testing.T) *testServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testServer{
+		listener: l,
+		passwords: map[string]string{
+			"cn=admin,dc=example,dc=com":            "admin",
+			"uid=alice,ou=people,dc=example,dc=com": "alice-password",
+		},
+		entries: []entry{
+			{dn: "uid=alice,ou=people,dc=example,dc=com", attributes: map[string][]string{
+				"objectclass": {"person"}, "uid": {"alice"},
+			}},
+			{dn: "uid=bob,ou=people,dc=example,dc=com", attributes: map[string][]string{
+				"objectclass": {"person"}, "uid": {"bob"},
+			}},
+			{dn: "cn=developers,ou=groups,dc=example,dc=com", attributes: map[string][]string{
+				"objectclass": {"groupOfNames"}, "cn": {"developers"},
+				"member": {"uid=alice,ou=people,dc=example,dc=com", "uid=bob,ou=people,dc=example,dc=com"},
+			}},
+			{dn: "cn=admins,ou=groups,dc=example,dc=com", attributes: map[string][]string{
+				"objectclass": {"groupOfNames"}, "cn": {"admins"},
+				"member": {"uid=bob,ou=people,dc=example,dc=com"},
+			}},
+		},
+	}
+	go s.serve()
+	return s
+}
+
+func (s *testServer) serve() {
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(c)
+	}
+}
+
+func (s *testServer) handle(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	bound := ""
+	for {
+		msg, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		id, _ := msg.child(0).integer()
+		reply := func(op *packet) {
+			c.Write(newSequence(tagSequence, newInteger(tagInteger, id), op).bytes())
+		}
+		result := func(tag byte, code int64) *packet {
+			return newSequence(tag, newInteger(tagEnumerated, code), newString(tagOctetString, ""), newString(tagOctetString, ""))
+		}
+
+		op := msg.child(1)
+		switch op.tag {
+		case opBindRequest:
+			dn, password := string(op.child(1).value), string(op.child(2).value)
+			if p, ok := s.passwords[dn]; !ok || p != password {
+				reply(result(opBindResponse, 49))
+				continue
+			}
+			bound = dn
+			reply(result(opBindResponse, resultSuccess))
+		case opSearchRequest:
+			// Only the service account can search.
+			if bound != "cn=admin,dc=example,dc=com" {
+				reply(result(opSearchDone, 50))
+				continue
+			}
+			base, filter, attrs := string(op.child(0).value), op.child(6), op.child(7)
+			for _, e := range s.entries {
+				if !strings.HasSuffix(e.dn, ","+base) || !match(e, filter) {
+					continue
+				}
+				values := newSequence(tagSequence)
+				for _, a := range attrs.children {
+					name := string(a.value)
+					set := newSequence(tagSet)
+					for _, v := range e.attributes[strings.ToLower(name)] {
+						set.children = append(set.children, newString(tagOctetString, v))
+					}
+					values.children = append(values.children, newSequence(tagSequence, newString(tagOctetString, name), set))
+				}
+				reply(newSequence(opSearchEntry, newString(tagOctetString, e.dn), values))
+			}
+			reply(result(opSearchDone, resultSuccess))
+		default:
+			return
+		}
+	}
+}
+
+// match matches the entry with 'and' and 'equality' filters.
+func match(e entry, filter *packet) bool {
+	switch filter.tag {
+	case filterAnd:
+		for _, f := range filter.children {
+			if !match(e, f) {
+				return false
+			}
+		}
+		return true
+	case filterEquality:
+		for _, v := range e.get(string(filter.child(0).value)) {
+			if strings.EqualFold(v, string(filter.child(1).value)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestPacket(t *testing.T) {
+	for _, v := range []int64{0, 1, 127, 128, 255, 256, -1, -128, -129, 1 << 40} {
+		p, err := parsePacket(tagInteger, newInteger(tagInteger, v).value)
+		assert.Nil(t, err)
+		decoded, err := p.integer()
+		assert.Nil(t, err)
+		assert.Equal(t, v, decoded)
+	}
+
+	long := strings.Repeat("x", 300)
+	encoded := newSequence(tagSequence, newString(tagOctetString, long), newBoolean(true)).bytes()
+	assert.Equal(t, []byte{0x30, 0x82, 0x01, 0x33}, encoded[:4])
+	p, err := readPacket(bufio.NewReader(strings.NewReader(string(encoded))))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(p.children))
+	assert.Equal(t, long, string(p.child(0).value))
+
+	_, err = readPacket(bufio.NewReader(strings.NewReader(string(encoded[:100]))))
+	assert.Error(t, err)
+}
+
+func TestLogin(t *testing.T) {
+	s := newTestServer(t)
+	defer s.listener.Close()
+
+	c, err := New(&Config{
+		URL:          "ldap://" + s.listener.Addr().String(),
+		BindDN:       "cn=admin,dc=example,dc=com",
+		BindPassword: "admin",
+		UserBaseDN:   "ou=people,dc=example,dc=com",
+		GroupBaseDN:  "ou=groups,dc=example,dc=com",
+	})
+	assert.Nil(t, err)
+
+	id, err := c.Login("alice", "alice-password")
+	assert.Nil(t, err)
+	assert.Equal(t, "ldap", id.Provider)
+	assert.Equal(t, "uid=alice,ou=people,dc=example,dc=com", id.ID)
+	assert.Equal(t, "alice", id.User)
+	assert.Equal(t, []string{"developers"}, id.Groups)
+
+	for _, cred := range [][2]string{{"alice", "wrong"}, {"alice", ""}, {"bob", "any"}, {"carol", "any"}} {
+		_, err = c.Login(cred[0], cred[1])
+		assert.Error(t, err, cred[0])
+	}
+
+	_, err = New(&Config{URL: "http://ldap.example.com", UserBaseDN: "dc=example,dc=com"})
+	assert.Error(t, err)
+}
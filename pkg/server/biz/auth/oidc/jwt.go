@@ -0,0 +1,164 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// clockSkew is the tolerance of clock differences between Cyclone and the identity provider.
+const clockSkew = time.Minute
+
+// jwt is a parsed JSON Web Token, signature is not verified yet.
+type jwt struct {
+	header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	claims map[string]interface{}
+	// signed is the signed part of the token, i.e. '<header>.<payload>'.
+	signed    string
+	signature []byte
+}
+
+// parseJWT parses JWT in compact serialization.
+func parseJWT(raw string) (*jwt, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed JWT")
+	}
+
+	t := &jwt{signed: parts[0] + "." + parts[1]}
+	if err := decodeSegment(parts[0], &t.header); err != nil {
+		return nil, fmt.Errorf("malformed JWT header: %v", err)
+	}
+	if err := decodeSegment(parts[1], &t.claims); err != nil {
+		return nil, fmt.Errorf("malformed JWT payload: %v", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed JWT signature: %v", err)
+	}
+	t.signature = signature
+	return t, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// verifySignature verifies the signature with the RSA public key, only RS256 is supported, which
+// is required to be supported by all OIDC providers.
+func (t *jwt) verifySignature(key *rsa.PublicKey) error {
+	if t.header.Alg != "RS256" {
+		return fmt.Errorf("unsupported JWT algorithm '%s'", t.header.Alg)
+	}
+
+	sum := sha256.Sum256([]byte(t.signed))
+	return rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], t.signature)
+}
+
+// verifyClaims verifies issuer, audience, expiration and nonce of ID token.
+func (t *jwt) verifyClaims(issuer, audience, nonce string, now time.Time) error {
+	if iss, _ := t.claims["iss"].(string); iss != issuer {
+		return fmt.Errorf("unexpected issuer '%s'", iss)
+	}
+
+	var audiences []string
+	switch aud := t.claims["aud"].(type) {
+	case string:
+		audiences = []string{aud}
+	case []interface{}:
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				audiences = append(audiences, s)
+			}
+		}
+	}
+	found := false
+	for _, a := range audiences {
+		if a == audience {
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("token is not issued to %s", audience)
+	}
+
+	exp, ok := t.claims["exp"].(float64)
+	if !ok {
+		return fmt.Errorf("expiration of token is missing")
+	}
+	if now.Add(-clockSkew).After(time.Unix(int64(exp), 0)) {
+		return fmt.Errorf("token expired")
+	}
+
+	if n, _ := t.claims["nonce"].(string); n != nonce {
+		return fmt.Errorf("unexpected nonce")
+	}
+	return nil
+}
+
+// stringClaim gets a string claim.
+func (t *jwt) stringClaim(name string) string {
+	s, _ := t.claims[name].(string)
+	return s
+}
+
+// stringsClaim gets a claim of string array, single string is also accepted.
+func (t *jwt) stringsClaim(name string) []string {
+	switch v := t.claims[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var values []string
+		for _, i := range v {
+			if s, ok := i.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// jwk is a JSON Web Key, only RSA keys are used.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// publicKey gets RSA public key from the JWK.
+func (k *jwk) publicKey() (*rsa.PublicKey, error) {
+	if k.Kty != "RSA" {
+		return nil, fmt.Errorf("unsupported key type '%s'", k.Kty)
+	}
+
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("invalid RSA exponent")
+	}
+
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
@@ -0,0 +1,296 @@
+package oidc
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caicloud/nirvana/log"
+
+	"github.com/caicloud/cyclone/pkg/server/biz/auth/connector"
+)
+
+// Provider is name of the OIDC identity provider.
+const Provider = "oidc"
+
+// timeout is the timeout of requests to the identity provider.
+const timeout = 10 * time.Second
+
+// maxResponseSize limits size of responses read from the identity provider.
+const maxResponseSize = 1 << 20
+
+// Config is config of the OIDC connector, users login with authorization code flow.
+type Config struct {
+	// Issuer is URL of the OIDC issuer, provider metadata is discovered from it.
+	Issuer string `json:"issuer"`
+	// ClientID and ClientSecret are credentials of Cyclone registered in the provider.
+	ClientID     string `json:"clientID"`
+	ClientSecret string `json:"clientSecret"`
+	// RedirectURL is the callback URL of Cyclone registered in the provider, e.g.
+	// 'https://cyclone.example.com/apis/v1alpha1/auth/oidc/callback'.
+	RedirectURL string `json:"redirectURL"`
+	// Scopes are scopes to request, 'openid' is always requested. Defaults to 'profile' and 'email'.
+	Scopes []string `json:"scopes"`
+	// UsernameClaim is the ID token claim used as user name, defaults to 'preferred_username'. It's
+	// only used to name accounts on first login, accounts are bound to 'sub' claim.
+	UsernameClaim string `json:"usernameClaim"`
+	// GroupsClaim is the ID token claim of user groups, defaults to 'groups'.
+	GroupsClaim string `json:"groupsClaim"`
+}
+
+// metadata is the provider metadata, see OpenID Connect Discovery 1.0, section 3.
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Connector authenticates users with OIDC authorization code flow.
+type Connector struct {
+	config Config
+	client *http.Client
+
+	// lock protects metadata and keys, which are fetched from the provider lazily.
+	lock     sync.Mutex
+	metadata *metadata
+	keys     map[string]*rsa.PublicKey
+}
+
+var _ connector.RedirectConnector = &Connector{}
+
+// New creates an OIDC connector with the config, the provider is not contacted until users login.
+func New(config *Config) (*Connector, error) {
+	c := &Connector{
+		config: *config,
+		client: &http.Client{Timeout: timeout},
+	}
+	if c.config.Issuer == "" || c.config.ClientID == "" || c.config.RedirectURL == "" {
+		return nil, fmt.Errorf("issuer, clientID and redirectURL of OIDC are required")
+	}
+	c.config.Issuer = strings.TrimSuffix(c.config.Issuer, "/")
+	if len(c.config.Scopes) == 0 {
+		c.config.Scopes = []string{"profile", "email"}
+	}
+	if c.config.UsernameClaim == "" {
+		c.config.UsernameClaim = "preferred_username"
+	}
+	if c.config.GroupsClaim == "" {
+		c.config.GroupsClaim = "groups"
+	}
+
+	return c, nil
+}
+
+// Provider returns name of the identity provider.
+func (c *Connector) Provider() string {
+	return Provider
+}
+
+// LoginURL returns URL of the authorization endpoint, the state is also used as nonce of ID token.
+func (c *Connector) LoginURL(state string) (string, error) {
+	m, err := c.discover()
+	if err != nil {
+		return "", err
+	}
+
+	scopes := []string{"openid"}
+	for _, s := range c.config.Scopes {
+		if s != "openid" {
+			scopes = append(scopes, s)
+		}
+	}
+	params := url.Values{
+		"response_type": {"code"},
+		"client_id":     {c.config.ClientID},
+		"redirect_uri":  {c.config.RedirectURL},
+		"scope":         {strings.Join(scopes, " ")},
+		"state":         {state},
+		"nonce":         {state},
+	}
+
+	sep := "?"
+	if strings.Contains(m.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return m.AuthorizationEndpoint + sep + params.Encode(), nil
+}
+
+// HandleCallback exchanges the authorization code for ID token, and gets identity of the user
+// from the verified ID token.
+func (c *Connector) HandleCallback(code, state string) (*connector.Identity, error) {
+	m, err := c.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := c.exchange(m.TokenEndpoint, code)
+	if err != nil {
+		return nil, err
+	}
+	token, err := parseJWT(raw)
+	if err != nil {
+		return nil, err
+	}
+	key, err := c.key(m.JWKSURI, token.header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := token.verifySignature(key); err != nil {
+		return nil, fmt.Errorf("invalid ID token signature: %v", err)
+	}
+	if err := token.verifyClaims(m.Issuer, c.config.ClientID, state, time.Now()); err != nil {
+		return nil, fmt.Errorf("invalid ID token: %v", err)
+	}
+
+	sub := token.stringClaim("sub")
+	if sub == "" {
+		return nil, fmt.Errorf("claim sub not found in ID token")
+	}
+	user := token.stringClaim(c.config.UsernameClaim)
+	if user == "" {
+		return nil, fmt.Errorf("claim %s not found in ID token", c.config.UsernameClaim)
+	}
+	return &connector.Identity{
+		Provider: Provider,
+		ID:       m.Issuer + "#" + sub,
+		User:     user,
+		Groups:   token.stringsClaim(c.config.GroupsClaim),
+	}, nil
+}
+
+// discover fetches provider metadata from the issuer, it's cached once fetched.
+func (c *Connector) discover() (*metadata, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.metadata != nil {
+		return c.metadata, nil
+	}
+
+	m := &metadata{}
+	if err := c.getJSON(c.config.Issuer+"/.well-known/openid-configuration", m); err != nil {
+		return nil, fmt.Errorf("discover OIDC provider %s error: %v", c.config.Issuer, err)
+	}
+	if m.Issuer != c.config.Issuer {
+		return nil, fmt.Errorf("issuer %s in provider metadata mismatches %s", m.Issuer, c.config.Issuer)
+	}
+	if m.AuthorizationEndpoint == "" || m.TokenEndpoint == "" || m.JWKSURI == "" {
+		return nil, fmt.Errorf("endpoints missing in metadata of OIDC provider %s", c.config.Issuer)
+	}
+
+	c.metadata = m
+	return m, nil
+}
+
+// key gets the signing key by key ID. Keys are fetched again if not found, since providers
+// rotate their keys.
+func (c *Connector) key(jwksURI, kid string) (*rsa.PublicKey, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if k := c.findKey(kid); k != nil {
+		return k, nil
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := c.getJSON(jwksURI, &set); err != nil {
+		return nil, fmt.Errorf("get OIDC signing keys error: %v", err)
+	}
+	c.keys = make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pk, err := k.publicKey()
+		if err != nil {
+			log.Warningf("Skip OIDC signing key %s: %v", k.Kid, err)
+			continue
+		}
+		c.keys[k.Kid] = pk
+	}
+
+	if k := c.findKey(kid); k != nil {
+		return k, nil
+	}
+	return nil, fmt.Errorf("OIDC signing key %s not found", kid)
+}
+
+// findKey finds the key by key ID, the only key is used if key ID is not given.
+func (c *Connector) findKey(kid string) *rsa.PublicKey {
+	if kid == "" && len(c.keys) == 1 {
+		for _, k := range c.keys {
+			return k
+		}
+	}
+	return c.keys[kid]
+}
+
+// exchange exchanges the authorization code for ID token at the token endpoint.
+func (c *Connector) exchange(endpoint, code string) (string, error) {
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {c.config.RedirectURL},
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(c.config.ClientID), url.QueryEscape(c.config.ClientSecret))
+
+	var resp struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := c.doJSON(req, &resp); err != nil && resp.Error == "" {
+		return "", fmt.Errorf("exchange OIDC authorization code error: %v", err)
+	}
+	if resp.Error != "" {
+		return "", fmt.Errorf("exchange OIDC authorization code error: %s %s", resp.Error, resp.ErrorDescription)
+	}
+	if resp.IDToken == "" {
+		return "", fmt.Errorf("no ID token returned by OIDC provider")
+	}
+	return resp.IDToken, nil
+}
+
+func (c *Connector) getJSON(u string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	return c.doJSON(req, v)
+}
+
+// doJSON sends the request and decodes JSON response into v, response body is decoded even if
+// status code is not 200, since errors are returned in JSON.
+func (c *Connector) doJSON(req *http.Request, v interface{}) error {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+	jsonErr := json.Unmarshal(body, v)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s returns %d", req.Method, req.URL, resp.StatusCode)
+	}
+	return jsonErr
+}
@@ -0,0 +1,170 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testIssuer is a mock OIDC issuer, it issues ID tokens with the claims for code 'valid'.
+type testIssuer struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	kid    string
+	claims map[string]interface{}
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := &testIssuer{key: key, kid: "key-1"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 i.URL,
+			"authorization_endpoint": i.URL + "/authorize",
+			"token_endpoint":         i.URL + "/token",
+			"jwks_uri":               i.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": i.kid,
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(i.key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(i.key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != "cyclone" || secret != "secret" || r.FormValue("code") != "valid" ||
+			r.FormValue("grant_type") != "authorization_code" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": i.sign(t, i.claims)})
+	})
+	i.Server = httptest.NewServer(mux)
+	return i
+}
+
+func (i *testIssuer) sign(t *testing.T, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": i.kid})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sum := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, i.key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (i *testIssuer) validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss":                i.URL,
+		"aud":                []string{"cyclone", "other"},
+		"sub":                "1234",
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"nonce":              "state-1",
+		"preferred_username": "alice",
+		"groups":             []string{"developers", "viewers"},
+	}
+}
+
+func TestLoginURL(t *testing.T) {
+	i := newTestIssuer(t)
+	defer i.Close()
+
+	c, err := New(&Config{Issuer: i.URL + "/", ClientID: "cyclone", ClientSecret: "secret", RedirectURL: "https://cyclone/callback"})
+	assert.Nil(t, err)
+	u, err := c.LoginURL("state-1")
+	assert.Nil(t, err)
+
+	parsed, err := url.Parse(u)
+	assert.Nil(t, err)
+	assert.Equal(t, "/authorize", parsed.Path)
+	q := parsed.Query()
+	assert.Equal(t, "code", q.Get("response_type"))
+	assert.Equal(t, "cyclone", q.Get("client_id"))
+	assert.Equal(t, "openid profile email", q.Get("scope"))
+	assert.Equal(t, "state-1", q.Get("state"))
+	assert.Equal(t, "state-1", q.Get("nonce"))
+	assert.Equal(t, "https://cyclone/callback", q.Get("redirect_uri"))
+}
+
+func TestHandleCallback(t *testing.T) {
+	i := newTestIssuer(t)
+	defer i.Close()
+
+	c, err := New(&Config{Issuer: i.URL, ClientID: "cyclone", ClientSecret: "secret", RedirectURL: "https://cyclone/callback"})
+	assert.Nil(t, err)
+
+	i.claims = i.validClaims()
+	id, err := c.HandleCallback("valid", "state-1")
+	assert.Nil(t, err)
+	assert.Equal(t, "oidc", id.Provider)
+	assert.Equal(t, i.URL+"#1234", id.ID)
+	assert.Equal(t, "alice", id.User)
+	assert.Equal(t, []string{"developers", "viewers"}, id.Groups)
+
+	_, err = c.HandleCallback("invalid", "state-1")
+	assert.Error(t, err)
+	_, err = c.HandleCallback("valid", "state-2")
+	assert.Error(t, err)
+
+	invalid := map[string]func(map[string]interface{}){
+		"expired":     func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"audience":    func(c map[string]interface{}) { c["aud"] = "other" },
+		"issuer":      func(c map[string]interface{}) { c["iss"] = "https://evil" },
+		"no username": func(c map[string]interface{}) { delete(c, "preferred_username") },
+	}
+	for name, modify := range invalid {
+		i.claims = i.validClaims()
+		modify(i.claims)
+		_, err = c.HandleCallback("valid", "state-1")
+		assert.Error(t, err, name)
+	}
+
+	// Keys are fetched again after rotated.
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	i.key, i.kid = key, "key-2"
+	i.claims = i.validClaims()
+	_, err = c.HandleCallback("valid", "state-1")
+	assert.Nil(t, err)
+}
+
+func TestVerifySignature(t *testing.T) {
+	i := newTestIssuer(t)
+	defer i.Close()
+
+	token, err := parseJWT(i.sign(t, i.validClaims()))
+	assert.Nil(t, err)
+	assert.Nil(t, token.verifySignature(&i.key.PublicKey))
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	assert.Error(t, token.verifySignature(&other.PublicKey))
+
+	token.header.Alg = "none"
+	assert.Error(t, token.verifySignature(&i.key.PublicKey))
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// passwordHashScheme is scheme of password hashes, passwords are hashed with PBKDF2 using
+	// HMAC-SHA256, hashes are stored as '<scheme>$<iterations>$<salt>$<key>'.
+	passwordHashScheme = "pbkdf2-sha256"
+	// passwordHashIterations is number of PBKDF2 iterations for new password hashes.
+	passwordHashIterations = 10000
+	// passwordSaltSize is size of random salt in bytes.
+	passwordSaltSize = 16
+)
+
+// hashPassword hashes the password with random salt.
+func hashPassword(password string) (string, error) {
+	salt, err := randomBytes(passwordSaltSize)
+	if err != nil {
+		return "", err
+	}
+
+	key := pbkdf2([]byte(password), salt, passwordHashIterations, sha256.Size)
+	return fmt.Sprintf("%s$%d$%s$%s", passwordHashScheme, passwordHashIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// verifyPassword checks whether the password matches the hash.
+func verifyPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != passwordHashScheme {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare(key, pbkdf2([]byte(password), salt, iterations, len(key))) == 1
+}
+
+// pbkdf2 derives a key from the password with PBKDF2 (RFC 8018) using HMAC-SHA256.
+func pbkdf2(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	var key []byte
+	for block := uint32(1); len(key) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		var index [4]byte
+		binary.BigEndian.PutUint32(index[:], block)
+		prf.Write(index[:])
+		u := prf.Sum(nil)
+
+		t := make([]byte, len(u))
+		copy(t, u)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+
+	return key[:keyLen]
+}
+
+// randomBytes generates n cryptographically secure random bytes.
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/caicloud/nirvana/log"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/server/biz/auth/connector"
+	"github.com/caicloud/cyclone/pkg/server/common"
+	"github.com/caicloud/cyclone/pkg/server/config"
+	"github.com/caicloud/cyclone/pkg/server/handler"
+)
+
+// invalidNameChars matches characters not allowed in user names.
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// userName converts user name from identity providers to valid user name, e.g. 'Alice@example.com'
+// is converted to 'alice-example-com'.
+func userName(name string) (string, error) {
+	converted := strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if len(converted) > maxNameLength || !namePattern.MatchString(converted) {
+		return "", fmt.Errorf("user name '%s' can't be converted to valid user name", name)
+	}
+	return converted, nil
+}
+
+// ValidateGroupRoles validates group role bindings of tenants.
+func ValidateGroupRoles(bindings []api.GroupRoleBinding) error {
+	for _, b := range bindings {
+		if b.Group == "" {
+			return fmt.Errorf("group of role binding is required")
+		}
+		switch b.Role {
+		case api.RoleTenantAdmin, api.RoleProjectDeveloper, api.RoleViewer:
+		default:
+			return fmt.Errorf("role '%s' can't be bound to groups", b.Role)
+		}
+	}
+	return nil
+}
+
+// groupRoles gets roles bound to the groups in all tenants.
+func groupRoles(groups []string) ([]api.RoleBinding, error) {
+	namespaces, err := handler.K8sClient.CoreV1().Namespaces().List(meta_v1.ListOptions{
+		LabelSelector: common.LabelOwnerCyclone(),
+	})
+	if err != nil {
+		log.Errorf("List cyclone namespace error %v", err)
+		return nil, err
+	}
+
+	roles := []api.RoleBinding{}
+	for _, ns := range namespaces.Items {
+		tenant := &api.Tenant{}
+		if err := json.Unmarshal([]byte(ns.Annotations[common.AnnotationTenant]), tenant); err != nil {
+			log.Warningf("Unmarshal tenant annotation of namespace %s error: %v", ns.Name, err)
+			continue
+		}
+
+		for _, b := range tenant.Spec.GroupRoles {
+			if contains(groups, b.Group) {
+				roles = append(roles, api.RoleBinding{
+					Role:     b.Role,
+					Tenant:   tenant.Metadata.Name,
+					Projects: b.Projects,
+				})
+			}
+		}
+	}
+	return roles, nil
+}
+
+// externalIDHash hashes ID of the user in the identity provider to label value, IDs like LDAP DNs
+// can't be label values.
+func externalIDHash(provider, id string) string {
+	sum := sha256.Sum256([]byte(provider + "\x00" + id))
+	return hex.EncodeToString(sum[:20])
+}
+
+// findExternalUser finds secret of the user account bound to ID of the external user, nil is
+// returned if not found.
+func findExternalUser(ext *connector.Identity) (*core_v1.Secret, error) {
+	secrets, err := handler.K8sClient.CoreV1().Secrets(config.SystemNamespace).List(meta_v1.ListOptions{
+		LabelSelector: common.LabelUserID + "=" + externalIDHash(ext.Provider, ext.ID),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range secrets.Items {
+		_, stored, err := secretToUser(&secrets.Items[i])
+		if err != nil {
+			log.Warningf("Unmarshal user secret %s error: %v", secrets.Items[i].Name, err)
+			continue
+		}
+		if stored.Provider == ext.Provider && stored.ExternalID == ext.ID {
+			return &secrets.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// provisionUser creates or updates the user from external identity provider, roles of the user
+// are determined by its groups. Local users and users from other providers are not touched.
+// Accounts are bound to ID of users in the provider, user name is only used to name the account
+// on first login, it's rejected if the name is taken by another user, e.g. 'a.b' and 'a_b' are
+// both converted to 'a-b'. The account is only updated when roles change.
+func provisionUser(ext *connector.Identity) (*api.Identity, error) {
+	if ext.ID == "" {
+		return nil, fmt.Errorf("ID of user %s from %s is required", ext.User, ext.Provider)
+	}
+	roles, err := groupRoles(ext.Groups)
+	if err != nil {
+		return nil, err
+	}
+
+	var name string
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		secret, err := findExternalUser(ext)
+		if err != nil {
+			return err
+		}
+		if secret == nil {
+			if name, err = userName(ext.User); err != nil {
+				return err
+			}
+			secret, err = handler.K8sClient.CoreV1().Secrets(config.SystemNamespace).Get(userSecretName(name), meta_v1.GetOptions{})
+			if errors.IsNotFound(err) {
+				_, err = createUserSecret(&storedUser{
+					Name:       name,
+					Provider:   ext.Provider,
+					ExternalID: ext.ID,
+					Roles:      roles,
+				})
+				return err
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		_, existing, err := secretToUser(secret)
+		if err != nil {
+			return err
+		}
+		name = existing.Name
+		// Accounts provisioned before IDs are recorded are bound to the ID on next login.
+		if existing.Provider != ext.Provider || (existing.ExternalID != "" && existing.ExternalID != ext.ID) {
+			return fmt.Errorf("user %s already exists and isn't %s from %s", name, ext.User, ext.Provider)
+		}
+		if existing.ExternalID == ext.ID && equalRoles(existing.Roles, roles) {
+			return nil
+		}
+
+		existing.ExternalID = ext.ID
+		existing.Roles = roles
+		if secret.Data[common.SecretKeyUser], err = json.Marshal(existing); err != nil {
+			return err
+		}
+		if secret.Labels == nil {
+			secret.Labels = make(map[string]string)
+		}
+		secret.Labels[common.LabelUserID] = externalIDHash(ext.Provider, ext.ID)
+		_, err = handler.K8sClient.CoreV1().Secrets(config.SystemNamespace).Update(secret)
+		return err
+	})
+	if err != nil {
+		log.Errorf("Provision user %s from %s error: %v", ext.User, ext.Provider, err)
+		return nil, err
+	}
+
+	log.Infof("User %s logged in with %s, groups: %v", name, ext.Provider, ext.Groups)
+	return &api.Identity{User: name, Roles: roles}, nil
+}
+
+// equalRoles checks whether two lists of role bindings are the same when stored.
+func equalRoles(a, b []api.RoleBinding) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return bytes.Equal(ja, jb)
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/cyclone/pkg/k8s/clientset/fake"
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/server/biz/auth/connector"
+	"github.com/caicloud/cyclone/pkg/server/common"
+	"github.com/caicloud/cyclone/pkg/server/handler"
+)
+
+// fakeConnector is a password and redirect connector with users in memory.
+type fakeConnector struct {
+	provider string
+	// users are users keyed by password for password login, or by code for redirect login.
+	users map[string]*connector.Identity
+}
+
+func (c *fakeConnector) Provider() string {
+	return c.provider
+}
+
+func (c *fakeConnector) Login(user, password string) (*connector.Identity, error) {
+	if id, ok := c.users[password]; ok && id.User == user {
+		return id, nil
+	}
+	return nil, fmt.Errorf("invalid user name or password")
+}
+
+func (c *fakeConnector) LoginURL(state string) (string, error) {
+	return "https://idp/authorize?state=" + state, nil
+}
+
+func (c *fakeConnector) HandleCallback(code, state string) (*connector.Identity, error) {
+	if id, ok := c.users[code]; ok {
+		return id, nil
+	}
+	return nil, fmt.Errorf("invalid code")
+}
+
+func tenantNamespace(name string, groupRoles ...api.GroupRoleBinding) *core_v1.Namespace {
+	tenant, _ := json.Marshal(&api.Tenant{
+		Metadata: api.Metadata{Name: name},
+		Spec:     api.TenantSpec{GroupRoles: groupRoles},
+	})
+	return &core_v1.Namespace{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:        common.TenantNamespace(name),
+			Labels:      map[string]string{common.LabelOwner: common.OwnerCyclone},
+			Annotations: map[string]string{common.AnnotationTenant: string(tenant)},
+		},
+	}
+}
+
+func TestUserName(t *testing.T) {
+	cases := map[string]string{
+		"alice":             "alice",
+		"Alice@Example.com": "alice-example-com",
+		"-bob_":             "bob",
+		"@@":                "",
+	}
+	for name, expected := range cases {
+		converted, err := userName(name)
+		assert.Equal(t, expected == "", err != nil, name)
+		assert.Equal(t, expected, converted, name)
+	}
+}
+
+func TestValidateGroupRoles(t *testing.T) {
+	assert.Nil(t, ValidateGroupRoles([]api.GroupRoleBinding{{Group: "devs", Role: api.RoleProjectDeveloper}}))
+	assert.Error(t, ValidateGroupRoles([]api.GroupRoleBinding{{Role: api.RoleViewer}}))
+	assert.Error(t, ValidateGroupRoles([]api.GroupRoleBinding{{Group: "devs", Role: api.RoleSystemAdmin}}))
+	assert.Error(t, ValidateGroupRoles([]api.GroupRoleBinding{{Group: "devs", Role: api.RoleWorkload}}))
+}
+
+func TestExternalLogin(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		tenantNamespace("t1",
+			api.GroupRoleBinding{Group: "developers", Role: api.RoleProjectDeveloper, Projects: []string{"p1"}},
+			api.GroupRoleBinding{Group: "admins", Role: api.RoleTenantAdmin},
+		),
+		tenantNamespace("t2", api.GroupRoleBinding{Group: "developers", Role: api.RoleViewer}),
+	)
+	handler.InitHandlers(client)
+
+	ldap := &fakeConnector{provider: "ldap", users: map[string]*connector.Identity{
+		"alice-password": {Provider: "ldap", ID: "uid=alice", User: "Alice", Groups: []string{"developers"}},
+		"bob-password":   {Provider: "ldap", ID: "uid=bob", User: "bob", Groups: []string{"admins"}},
+	}}
+	oidc := &fakeConnector{provider: "oidc", users: map[string]*connector.Identity{
+		"code-carol":   {Provider: "oidc", ID: "idp#1", User: "carol@example.com", Groups: []string{"admins"}},
+		"code-bob":     {Provider: "oidc", ID: "idp#2", User: "bob"},
+		"code-dave":    {Provider: "oidc", ID: "idp#3", User: "d.ave"},
+		"code-mallory": {Provider: "oidc", ID: "idp#4", User: "d_ave"},
+		"code-renamed": {Provider: "oidc", ID: "idp#1", User: "carol.new", Groups: []string{"admins"}},
+		"code-no-id":   {Provider: "oidc", User: "erin"},
+	}}
+	passwordConnectors = nil
+	RegisterPasswordConnector(ldap)
+	RegisterRedirectConnector(oidc)
+	defer func() {
+		passwordConnectors = nil
+		redirectConnectors = map[string]connector.RedirectConnector{}
+	}()
+
+	// Local user is not verified by connectors.
+	_, err := CreateUser(&api.User{
+		Metadata: api.Metadata{Name: "bob"},
+		Spec:     api.UserSpec{Password: "local-password"},
+	})
+	assert.Nil(t, err)
+
+	req, _ := http.NewRequest(http.MethodGet, "/apis/v1alpha1/projects", nil)
+	req.SetBasicAuth("Alice", "alice-password")
+	id, err := Authenticate(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", id.User)
+	assert.Equal(t, []api.RoleBinding{
+		{Role: api.RoleProjectDeveloper, Tenant: "t1", Projects: []string{"p1"}},
+		{Role: api.RoleViewer, Tenant: "t2"},
+	}, id.Roles)
+
+	user, err := GetUser("alice")
+	assert.Nil(t, err)
+	assert.Equal(t, "ldap", user.Spec.Provider)
+	assert.Equal(t, id.Roles, user.Spec.Roles)
+	_, err = UpdateUser("alice", &api.User{Spec: api.UserSpec{Password: "new"}})
+	assert.Error(t, err)
+
+	req.SetBasicAuth("bob", "bob-password")
+	_, err = Authenticate(req)
+	assert.Error(t, err)
+	req.SetBasicAuth("bob", "local-password")
+	_, err = Authenticate(req)
+	assert.Nil(t, err)
+
+	u, err := LoginURL("oidc", "state")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://idp/authorize?state=state", u)
+	_, err = LoginURL("saml", "state")
+	assert.Error(t, err)
+
+	token, err := LoginCallback("oidc", "code-carol", "state")
+	assert.Nil(t, err)
+	assert.NotEmpty(t, token.ExpirationTime)
+	req, _ = http.NewRequest(http.MethodGet, "/apis/v1alpha1/projects", nil)
+	req.Header.Set("Authorization", "Bearer "+token.Token)
+	id, err = Authenticate(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "carol-example-com", id.User)
+	assert.Equal(t, []api.RoleBinding{{Role: api.RoleTenantAdmin, Tenant: "t1"}}, id.Roles)
+
+	_, err = LoginCallback("oidc", "code-bob", "state")
+	assert.Error(t, err)
+	_, err = LoginCallback("oidc", "invalid", "state")
+	assert.Error(t, err)
+	_, err = LoginCallback("oidc", "code-no-id", "state")
+	assert.Error(t, err)
+
+	// Accounts are bound to user IDs, user names converted to the same name are rejected, and
+	// renamed users keep their accounts.
+	_, err = provisionUser(oidc.users["code-dave"])
+	assert.Nil(t, err)
+	_, err = provisionUser(oidc.users["code-mallory"])
+	assert.Error(t, err)
+	id, err = provisionUser(oidc.users["code-renamed"])
+	assert.Nil(t, err)
+	assert.Equal(t, "carol-example-com", id.User)
+
+	// The account is not updated if roles don't change.
+	client.ClearActions()
+	_, err = provisionUser(ldap.users["alice-password"])
+	assert.Nil(t, err)
+	for _, action := range client.Actions() {
+		assert.NotEqual(t, "update", action.GetVerb())
+	}
+	ldap.users["alice-password"].Groups = []string{"admins"}
+	id, err = provisionUser(ldap.users["alice-password"])
+	assert.Nil(t, err)
+	assert.Equal(t, []api.RoleBinding{{Role: api.RoleTenantAdmin, Tenant: "t1"}}, id.Roles)
+	user, err = GetUser("alice")
+	assert.Nil(t, err)
+	assert.Equal(t, id.Roles, user.Spec.Roles)
+}
@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/caicloud/nirvana/log"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/server/common"
+	"github.com/caicloud/cyclone/pkg/server/config"
+	"github.com/caicloud/cyclone/pkg/server/handler"
+)
+
+const (
+	// tokenIDSize is size of token IDs in bytes, IDs are hex encoded.
+	tokenIDSize = 8
+	// tokenSecretSize is size of the secret part of tokens in bytes.
+	tokenSecretSize = 32
+)
+
+// storedToken is the API token stored in secret, only hash of the token secret is stored.
+type storedToken struct {
+	ID             string `json:"id"`
+	User           string `json:"user"`
+	Description    string `json:"description,omitempty"`
+	Hash           string `json:"hash"`
+	CreationTime   string `json:"creationTime"`
+	ExpirationTime string `json:"expirationTime,omitempty"`
+}
+
+func (t *storedToken) toAPIToken() *api.APIToken {
+	return &api.APIToken{
+		ID:             t.ID,
+		Description:    t.Description,
+		CreationTime:   t.CreationTime,
+		ExpirationTime: t.ExpirationTime,
+	}
+}
+
+// expired checks whether the token has expired at the time.
+func (t *storedToken) expired(now time.Time) bool {
+	if t.ExpirationTime == "" {
+		return false
+	}
+	expiration, err := time.Parse(time.RFC3339, t.ExpirationTime)
+	return err != nil || !now.Before(expiration)
+}
+
+func tokenSecretName(id string) string {
+	return common.TokenSecretPrefix + id
+}
+
+func hashTokenSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseToken splits token value '<id>.<secret>' into ID and secret.
+func parseToken(token string) (string, string, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 || len(parts[0]) != 2*tokenIDSize || parts[1] == "" {
+		return "", "", false
+	}
+	if _, err := hex.DecodeString(parts[0]); err != nil {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+func getToken(id string) (*storedToken, error) {
+	secret, err := handler.K8sClient.CoreV1().Secrets(config.SystemNamespace).Get(tokenSecretName(id), meta_v1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	token := &storedToken{}
+	if err := json.Unmarshal(secret.Data[common.SecretKeyToken], token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// CreateToken creates an API token for the user, value of the token is only returned here.
+func CreateToken(user string, token *api.APIToken) (*api.APIToken, error) {
+	if token.ExpirationTime != "" {
+		if _, err := time.Parse(time.RFC3339, token.ExpirationTime); err != nil {
+			return nil, fmt.Errorf("invalid expiration time '%s', it should be in RFC3339 format", token.ExpirationTime)
+		}
+	}
+
+	id, err := randomBytes(tokenIDSize)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := randomBytes(tokenSecretSize)
+	if err != nil {
+		return nil, err
+	}
+	secretValue := base64.RawURLEncoding.EncodeToString(secret)
+
+	stored := &storedToken{
+		ID:             hex.EncodeToString(id),
+		User:           user,
+		Description:    token.Description,
+		Hash:           hashTokenSecret(secretValue),
+		CreationTime:   time.Now().Format(time.RFC3339),
+		ExpirationTime: token.ExpirationTime,
+	}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = handler.K8sClient.CoreV1().Secrets(config.SystemNamespace).Create(&core_v1.Secret{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: tokenSecretName(stored.ID),
+			Labels: map[string]string{
+				common.LabelOwner: common.OwnerCyclone,
+				common.LabelUser:  user,
+			},
+		},
+		Data: map[string][]byte{
+			common.SecretKeyToken: data,
+		},
+	})
+	if err != nil {
+		log.Errorf("Create secret for token of user %s error: %v", user, err)
+		return nil, err
+	}
+
+	created := stored.toAPIToken()
+	created.Token = stored.ID + "." + secretValue
+	return created, nil
+}
+
+// ListTokens lists API tokens of the user, values of tokens are not returned.
+func ListTokens(user string) ([]api.APIToken, error) {
+	secrets, err := handler.K8sClient.CoreV1().Secrets(config.SystemNamespace).List(meta_v1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", common.LabelUser, user),
+	})
+	if err != nil {
+		log.Errorf("List token secrets of user %s error: %v", user, err)
+		return nil, err
+	}
+
+	tokens := []api.APIToken{}
+	for _, secret := range secrets.Items {
+		data, ok := secret.Data[common.SecretKeyToken]
+		if !ok {
+			continue
+		}
+		token := &storedToken{}
+		if err := json.Unmarshal(data, token); err != nil {
+			log.Warningf("Unmarshal token secret %s error: %v", secret.Name, err)
+			continue
+		}
+		tokens = append(tokens, *token.toAPIToken())
+	}
+	return tokens, nil
+}
+
+// DeleteToken deletes the API token of the user.
+func DeleteToken(user, id string) error {
+	token, err := getToken(id)
+	if err != nil {
+		return err
+	}
+	if token.User != user {
+		return fmt.Errorf("token %s not found for user %s", id, user)
+	}
+
+	return handler.K8sClient.CoreV1().Secrets(config.SystemNamespace).Delete(tokenSecretName(id), &meta_v1.DeleteOptions{})
+}
+
+// deleteTokens deletes all API tokens of the user.
+func deleteTokens(user string) error {
+	tokens, err := ListTokens(user)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tokens {
+		if err := handler.K8sClient.CoreV1().Secrets(config.SystemNamespace).Delete(tokenSecretName(t.ID), &meta_v1.DeleteOptions{}); err != nil {
+			log.Warningf("Delete token %s of user %s error: %v", t.ID, user, err)
+		}
+	}
+	return nil
+}
+
+// deleteExpiredTokens deletes expired API tokens of the user.
+func deleteExpiredTokens(user string) {
+	tokens, err := ListTokens(user)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	for _, t := range tokens {
+		stored := &storedToken{ExpirationTime: t.ExpirationTime}
+		if !stored.expired(now) {
+			continue
+		}
+		if err := handler.K8sClient.CoreV1().Secrets(config.SystemNamespace).Delete(tokenSecretName(t.ID), &meta_v1.DeleteOptions{}); err != nil {
+			log.Warningf("Delete expired token %s of user %s error: %v", t.ID, user, err)
+		}
+	}
+}
+
+// verifyToken verifies the API token and returns the user it belongs to.
+func verifyToken(value string) (string, error) {
+	id, secret, ok := parseToken(value)
+	if !ok {
+		return "", fmt.Errorf("malformed token")
+	}
+
+	token, err := getToken(id)
+	if err != nil {
+		log.Warningf("Get token %s error: %v", id, err)
+		return "", fmt.Errorf("invalid token")
+	}
+	if subtle.ConstantTimeCompare([]byte(token.Hash), []byte(hashTokenSecret(secret))) != 1 {
+		return "", fmt.Errorf("invalid token")
+	}
+	if token.expired(time.Now()) {
+		return "", fmt.Errorf("token expired")
+	}
+
+	return token.User, nil
+}
@@ -0,0 +1,272 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/caicloud/nirvana/log"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/server/common"
+	"github.com/caicloud/cyclone/pkg/server/config"
+	"github.com/caicloud/cyclone/pkg/server/handler"
+)
+
+// namePattern is pattern of user names, they are used in secret names and label values.
+var namePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// maxNameLength is max length of user names.
+const maxNameLength = 40
+
+// storedUser is the user account stored in secret, password is stored as hash.
+type storedUser struct {
+	Name         string            `json:"name"`
+	Description  string            `json:"description,omitempty"`
+	PasswordHash string            `json:"passwordHash,omitempty"`
+	Provider     string            `json:"provider,omitempty"`
+	ExternalID   string            `json:"externalID,omitempty"`
+	Roles        []api.RoleBinding `json:"roles"`
+}
+
+func userSecretName(name string) string {
+	return common.UserSecretPrefix + name
+}
+
+// validateUser validates name and roles of the user.
+func validateUser(user *api.User) error {
+	name := user.Metadata.Name
+	if len(name) > maxNameLength || !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid user name '%s', it should consist of lower case alphanumeric characters or '-', and at most %d characters", name, maxNameLength)
+	}
+
+	for _, b := range user.Spec.Roles {
+		switch b.Role {
+		case api.RoleSystemAdmin:
+		case api.RoleTenantAdmin, api.RoleProjectDeveloper, api.RoleViewer:
+			if b.Tenant == "" {
+				return fmt.Errorf("tenant of role %s is required", b.Role)
+			}
+		default:
+			return fmt.Errorf("unsupported role '%s'", b.Role)
+		}
+	}
+	return nil
+}
+
+// secretToUser translates secret to user, password hash is not returned.
+func secretToUser(secret *core_v1.Secret) (*api.User, *storedUser, error) {
+	stored := &storedUser{}
+	if err := json.Unmarshal(secret.Data[common.SecretKeyUser], stored); err != nil {
+		return nil, nil, err
+	}
+
+	return &api.User{
+		Metadata: api.Metadata{
+			Name:         stored.Name,
+			Description:  stored.Description,
+			CreationTime: secret.CreationTimestamp.Format(time.RFC3339),
+		},
+		Spec: api.UserSpec{
+			Provider: stored.Provider,
+			Roles:    stored.Roles,
+		},
+	}, stored, nil
+}
+
+func getUser(name string) (*api.User, *storedUser, error) {
+	secret, err := handler.K8sClient.CoreV1().Secrets(config.SystemNamespace).Get(userSecretName(name), meta_v1.GetOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return secretToUser(secret)
+}
+
+// createUserSecret creates secret to store the user.
+func createUserSecret(stored *storedUser) (*core_v1.Secret, error) {
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := map[string]string{
+		common.LabelOwner: common.OwnerCyclone,
+		common.LabelUser:  stored.Name,
+	}
+	if stored.ExternalID != "" {
+		labels[common.LabelUserID] = externalIDHash(stored.Provider, stored.ExternalID)
+	}
+	return handler.K8sClient.CoreV1().Secrets(config.SystemNamespace).Create(&core_v1.Secret{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:   userSecretName(stored.Name),
+			Labels: labels,
+		},
+		Data: map[string][]byte{
+			common.SecretKeyUser: data,
+		},
+	})
+}
+
+// GetUser gets the user account.
+func GetUser(name string) (*api.User, error) {
+	user, _, err := getUser(name)
+	return user, err
+}
+
+// ListUsers lists all user accounts.
+func ListUsers() ([]api.User, error) {
+	secrets, err := handler.K8sClient.CoreV1().Secrets(config.SystemNamespace).List(meta_v1.ListOptions{
+		LabelSelector: common.LabelUser,
+	})
+	if err != nil {
+		log.Errorf("List user secrets error: %v", err)
+		return nil, err
+	}
+
+	users := []api.User{}
+	for _, secret := range secrets.Items {
+		if _, ok := secret.Data[common.SecretKeyUser]; !ok {
+			continue
+		}
+		user, _, err := secretToUser(&secret)
+		if err != nil {
+			log.Warningf("Unmarshal user secret %s error: %v", secret.Name, err)
+			continue
+		}
+		users = append(users, *user)
+	}
+	return users, nil
+}
+
+// CreateUser creates a user account, password of the user is hashed before stored.
+func CreateUser(user *api.User) (*api.User, error) {
+	if err := validateUser(user); err != nil {
+		return nil, err
+	}
+
+	stored := &storedUser{
+		Name:        user.Metadata.Name,
+		Description: user.Metadata.Description,
+		Roles:       user.Spec.Roles,
+	}
+	if user.Spec.Password != "" {
+		hash, err := hashPassword(user.Spec.Password)
+		if err != nil {
+			return nil, err
+		}
+		stored.PasswordHash = hash
+	}
+	secret, err := createUserSecret(stored)
+	if err != nil {
+		log.Errorf("Create secret for user %s error: %v", stored.Name, err)
+		return nil, err
+	}
+
+	created, _, err := secretToUser(secret)
+	return created, err
+}
+
+// UpdateUser updates description, roles and password of the user, password is kept if not given.
+// Roles of users from external identity providers are overridden when they login again.
+func UpdateUser(name string, user *api.User) (*api.User, error) {
+	user.Metadata.Name = name
+	if err := validateUser(user); err != nil {
+		return nil, err
+	}
+
+	var updated *api.User
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		secret, err := handler.K8sClient.CoreV1().Secrets(config.SystemNamespace).Get(userSecretName(name), meta_v1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		_, stored, err := secretToUser(secret)
+		if err != nil {
+			return err
+		}
+
+		stored.Description = user.Metadata.Description
+		stored.Roles = user.Spec.Roles
+		if user.Spec.Password != "" && stored.Provider != "" {
+			return fmt.Errorf("password of users from %s can't be set", stored.Provider)
+		}
+		if user.Spec.Password != "" {
+			if stored.PasswordHash, err = hashPassword(user.Spec.Password); err != nil {
+				return err
+			}
+		}
+		if secret.Data[common.SecretKeyUser], err = json.Marshal(stored); err != nil {
+			return err
+		}
+
+		secret, err = handler.K8sClient.CoreV1().Secrets(config.SystemNamespace).Update(secret)
+		if err != nil {
+			return err
+		}
+		updated, _, err = secretToUser(secret)
+		return err
+	})
+	if err != nil {
+		log.Errorf("Update user %s error: %v", name, err)
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// DeleteUser deletes the user account and all its API tokens.
+func DeleteUser(name string) error {
+	err := handler.K8sClient.CoreV1().Secrets(config.SystemNamespace).Delete(userSecretName(name), &meta_v1.DeleteOptions{})
+	if err != nil {
+		log.Errorf("Delete secret for user %s error: %v", name, err)
+		return err
+	}
+
+	return deleteTokens(name)
+}
+
+// EnsureAdminUser creates the admin user with system admin role if it doesn't exist, so that
+// there is always a way to access Cyclone when authentication enabled. If password is not given,
+// a random one is generated and logged.
+func EnsureAdminUser(password string) error {
+	_, err := GetUser(common.AdminUser)
+	if err == nil {
+		log.Infof("Admin user %s already exist", common.AdminUser)
+		return nil
+	}
+	if !errors.IsNotFound(err) {
+		return err
+	}
+
+	if password == "" {
+		b, err := randomBytes(12)
+		if err != nil {
+			return err
+		}
+		password = fmt.Sprintf("%x", b)
+		log.Warningf("Admin user %s created with generated password %s, please change it", common.AdminUser, password)
+	}
+
+	_, err = CreateUser(&api.User{
+		Metadata: api.Metadata{
+			Name:        common.AdminUser,
+			Description: "System admin",
+		},
+		Spec: api.UserSpec{
+			Password: password,
+			Roles: []api.RoleBinding{
+				{Role: api.RoleSystemAdmin},
+			},
+		},
+	})
+	if errors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
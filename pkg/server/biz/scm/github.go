@@ -0,0 +1,123 @@
+package scm
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+)
+
+// githubAPI is the API endpoint of github.com.
+const githubAPI = "https://api.github.com"
+
+// gitHub operates repositories with GitHub REST API v3, GitHub Enterprise is also supported.
+type gitHub struct {
+	client
+}
+
+func newGitHub(source *api.SCMSource) *gitHub {
+	baseURL := githubAPI
+	server := strings.TrimSuffix(source.Server, "/")
+	switch strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://") {
+	case "", "github.com", "api.github.com":
+	default:
+		if !strings.Contains(server, "://") {
+			server = "https://" + server
+		}
+		// GitHub Enterprise serves API under '/api/v3'.
+		baseURL = server + "/api/v3"
+	}
+
+	return &gitHub{client{
+		baseURL: baseURL,
+		auth: func(req *http.Request) {
+			if source.Token != "" {
+				req.Header.Set("Authorization", "token "+source.Token)
+			} else {
+				req.SetBasicAuth(source.User, source.Password)
+			}
+		},
+		http: &http.Client{Timeout: timeout},
+	}}
+}
+
+type githubHook struct {
+	Name   string           `json:"name"`
+	Active bool             `json:"active"`
+	Events []string         `json:"events"`
+	Config githubHookConfig `json:"config"`
+}
+
+type githubHookConfig struct {
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"`
+	Secret      string `json:"secret"`
+	InsecureSSL string `json:"insecure_ssl"`
+}
+
+func toGitHubHook(hook *Hook) *githubHook {
+	var events []string
+	// Tags are pushed in push events.
+	if hook.Push || hook.Tag {
+		events = append(events, "push")
+	}
+	if hook.PullRequest {
+		events = append(events, "pull_request")
+	}
+
+	return &githubHook{
+		Name:   "web",
+		Active: true,
+		Events: events,
+		Config: githubHookConfig{
+			URL:         hook.URL,
+			ContentType: "json",
+			Secret:      hook.Secret,
+			InsecureSSL: "0",
+		},
+	}
+}
+
+// CreateWebhook ...
+func (g *gitHub) CreateWebhook(repo string, hook *Hook) (string, error) {
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	if err := g.do(http.MethodPost, fmt.Sprintf("/repos/%s/hooks", repo), toGitHubHook(hook), &created); err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(created.ID, 10), nil
+}
+
+// UpdateWebhook ...
+func (g *gitHub) UpdateWebhook(repo, id string, hook *Hook) error {
+	return g.do(http.MethodPatch, fmt.Sprintf("/repos/%s/hooks/%s", repo, id), toGitHubHook(hook), nil)
+}
+
+// DeleteWebhook ...
+func (g *gitHub) DeleteWebhook(repo, id string) error {
+	err := g.do(http.MethodDelete, fmt.Sprintf("/repos/%s/hooks/%s", repo, id), nil, nil)
+	if IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// CreateStatus ...
+func (g *gitHub) CreateStatus(repo, commit string, status *CommitStatus) error {
+	body := map[string]string{
+		"state":       string(status.State),
+		"target_url":  status.TargetURL,
+		"description": status.Description,
+		"context":     status.Context,
+	}
+	return g.do(http.MethodPost, fmt.Sprintf("/repos/%s/statuses/%s", repo, commit), body, nil)
+}
+
+// CreateComment ...
+func (g *gitHub) CreateComment(repo string, pullRequest int, body string) error {
+	// Pull requests are issues in GitHub, their comments are issue comments.
+	return g.do(http.MethodPost, fmt.Sprintf("/repos/%s/issues/%d/comments", repo, pullRequest), map[string]string{"body": body}, nil)
+}
@@ -0,0 +1,114 @@
+package scm
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+)
+
+// gitlabServer is the address of gitlab.com.
+const gitlabServer = "https://gitlab.com"
+
+// gitLab operates repositories with GitLab REST API v4.
+type gitLab struct {
+	client
+}
+
+func newGitLab(source *api.SCMSource) *gitLab {
+	server := strings.TrimSuffix(source.Server, "/")
+	if server == "" {
+		server = gitlabServer
+	} else if !strings.Contains(server, "://") {
+		server = "https://" + server
+	}
+
+	return &gitLab{client{
+		baseURL: server + "/api/v4",
+		auth: func(req *http.Request) {
+			if source.Token != "" {
+				req.Header.Set("PRIVATE-TOKEN", source.Token)
+			} else {
+				req.SetBasicAuth(source.User, source.Password)
+			}
+		},
+		http: &http.Client{Timeout: timeout},
+	}}
+}
+
+type gitlabHook struct {
+	URL                   string `json:"url"`
+	Token                 string `json:"token"`
+	PushEvents            bool   `json:"push_events"`
+	TagPushEvents         bool   `json:"tag_push_events"`
+	MergeRequestsEvents   bool   `json:"merge_requests_events"`
+	EnableSSLVerification bool   `json:"enable_ssl_verification"`
+}
+
+func toGitLabHook(hook *Hook) *gitlabHook {
+	return &gitlabHook{
+		URL:                   hook.URL,
+		Token:                 hook.Secret,
+		PushEvents:            hook.Push,
+		TagPushEvents:         hook.Tag,
+		MergeRequestsEvents:   hook.PullRequest,
+		EnableSSLVerification: true,
+	}
+}
+
+// projectPath gets API path of the project, project is identified by its URL encoded full path.
+func projectPath(repo string) string {
+	return "/projects/" + url.PathEscape(repo)
+}
+
+// CreateWebhook ...
+func (g *gitLab) CreateWebhook(repo string, hook *Hook) (string, error) {
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	if err := g.do(http.MethodPost, projectPath(repo)+"/hooks", toGitLabHook(hook), &created); err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(created.ID, 10), nil
+}
+
+// UpdateWebhook ...
+func (g *gitLab) UpdateWebhook(repo, id string, hook *Hook) error {
+	return g.do(http.MethodPut, fmt.Sprintf("%s/hooks/%s", projectPath(repo), id), toGitLabHook(hook), nil)
+}
+
+// DeleteWebhook ...
+func (g *gitLab) DeleteWebhook(repo, id string) error {
+	err := g.do(http.MethodDelete, fmt.Sprintf("%s/hooks/%s", projectPath(repo), id), nil, nil)
+	if IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// gitlabStates maps states of commit statuses to GitLab ones.
+var gitlabStates = map[State]string{
+	StatePending: "running",
+	StateSuccess: "success",
+	StateFailure: "failed",
+	StateError:   "canceled",
+}
+
+// CreateStatus ...
+func (g *gitLab) CreateStatus(repo, commit string, status *CommitStatus) error {
+	body := map[string]string{
+		"state":       gitlabStates[status.State],
+		"name":        status.Context,
+		"target_url":  status.TargetURL,
+		"description": status.Description,
+	}
+	return g.do(http.MethodPost, fmt.Sprintf("%s/statuses/%s", projectPath(repo), commit), body, nil)
+}
+
+// CreateComment ...
+func (g *gitLab) CreateComment(repo string, pullRequest int, body string) error {
+	return g.do(http.MethodPost, fmt.Sprintf("%s/merge_requests/%d/notes", projectPath(repo), pullRequest), map[string]string{"body": body}, nil)
+}
@@ -0,0 +1,182 @@
+package scm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+)
+
+// timeout is the timeout of requests to SCM APIs.
+const timeout = 30 * time.Second
+
+// maxResponseSize limits size of responses read from SCM APIs.
+const maxResponseSize = 1 << 20
+
+// Hook is a repository webhook.
+type Hook struct {
+	// URL is where the webhooks are sent to.
+	URL string
+	// Secret is used to sign webhooks, or sent as token.
+	Secret string
+	// Push, Tag and PullRequest indicate which events to subscribe.
+	Push        bool
+	Tag         bool
+	PullRequest bool
+}
+
+// State is state of a commit status.
+type State string
+
+const (
+	// StatePending means the commit is being checked.
+	StatePending State = "pending"
+	// StateSuccess means the check of the commit succeeded.
+	StateSuccess State = "success"
+	// StateFailure means the check of the commit failed.
+	StateFailure State = "failure"
+	// StateError means the check of the commit was interrupted, e.g. cancelled.
+	StateError State = "error"
+)
+
+// CommitStatus is status of a check reported to a commit.
+type CommitStatus struct {
+	State State
+	// Context distinguishes statuses of different checks on the same commit.
+	Context string
+	// TargetURL links to details of the check, it's optional.
+	TargetURL   string
+	Description string
+}
+
+// Provider operates repositories in a SCM, repositories are given by full names, e.g.
+// 'caicloud/cyclone'.
+type Provider interface {
+	// CreateWebhook creates webhook in the repository, ID of the webhook is returned.
+	CreateWebhook(repo string, hook *Hook) (string, error)
+	// UpdateWebhook updates the webhook in the repository.
+	UpdateWebhook(repo, id string, hook *Hook) error
+	// DeleteWebhook deletes the webhook from the repository, it's not an error if the webhook
+	// doesn't exist.
+	DeleteWebhook(repo, id string) error
+	// CreateStatus reports status of a check to the commit in the repository.
+	CreateStatus(repo, commit string, status *CommitStatus) error
+	// CreateComment comments on the pull request (merge request in GitLab) in the repository.
+	CreateComment(repo string, pullRequest int, body string) error
+}
+
+// NewProvider creates provider of the SCM, GitHub and GitLab are supported.
+func NewProvider(source *api.SCMSource) (Provider, error) {
+	switch source.Type {
+	case api.GitHub:
+		return newGitHub(source), nil
+	case api.GitLab:
+		return newGitLab(source), nil
+	}
+	return nil, fmt.Errorf("unsupported SCM type '%s'", source.Type)
+}
+
+// Repository gets full name of the repository from its Git URL, both HTTPS and SSH URLs are
+// supported, e.g. 'https://github.com/caicloud/cyclone.git' and 'git@github.com:caicloud/cyclone.git'.
+func Repository(url string) string {
+	u := strings.TrimSuffix(strings.TrimSuffix(url, "/"), ".git")
+	if i := strings.Index(u, "://"); i != -1 {
+		u = u[i+3:]
+		// Remove host
+		if j := strings.Index(u, "/"); j != -1 {
+			return u[j+1:]
+		}
+		return ""
+	}
+	if i := strings.Index(u, ":"); i != -1 {
+		return u[i+1:]
+	}
+	return u
+}
+
+// StatusError is error responded by SCM APIs.
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("SCM responds %d: %s", e.StatusCode, e.Message)
+}
+
+// IsPermissionDenied checks whether the error is caused by lack of permissions. Not found is also
+// regarded as denied, since SCMs hide private repositories from users without permissions.
+func IsPermissionDenied(err error) bool {
+	if e, ok := err.(*StatusError); ok {
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden || e.StatusCode == http.StatusNotFound
+	}
+	return false
+}
+
+// IsNotFound checks whether the error is caused by resource not found.
+func IsNotFound(err error) bool {
+	if e, ok := err.(*StatusError); ok {
+		return e.StatusCode == http.StatusNotFound
+	}
+	return false
+}
+
+// client is a JSON API client, auth sets credentials to requests.
+type client struct {
+	baseURL string
+	auth    func(req *http.Request)
+	http    *http.Client
+}
+
+// do sends request with the JSON body, and decodes JSON response into out if it's not nil.
+func (c *client) do(method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	c.auth(req)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		var e struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &e) != nil || e.Message == "" {
+			e.Message = http.StatusText(resp.StatusCode)
+		}
+		return &StatusError{StatusCode: resp.StatusCode, Message: e.Message}
+	}
+
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
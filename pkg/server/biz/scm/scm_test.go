@@ -0,0 +1,163 @@
+package scm
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+)
+
+// request is a request received by the fake SCM server.
+type request struct {
+	method string
+	path   string
+	header http.Header
+	body   map[string]interface{}
+}
+
+// fakeServer records requests and responds with the status and body.
+func fakeServer(t *testing.T, requests *[]request, status int, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := request{method: r.Method, path: r.URL.EscapedPath(), header: r.Header}
+		data, _ := ioutil.ReadAll(r.Body)
+		if len(data) > 0 {
+			assert.Nil(t, json.Unmarshal(data, &req.body))
+		}
+		*requests = append(*requests, req)
+
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+}
+
+func TestNewProvider(t *testing.T) {
+	p, err := NewProvider(&api.SCMSource{Type: api.GitHub})
+	assert.Nil(t, err)
+	assert.Equal(t, githubAPI, p.(*gitHub).baseURL)
+
+	p, err = NewProvider(&api.SCMSource{Type: api.GitHub, Server: "github.example.com/"})
+	assert.Nil(t, err)
+	assert.Equal(t, "https://github.example.com/api/v3", p.(*gitHub).baseURL)
+
+	p, err = NewProvider(&api.SCMSource{Type: api.GitLab})
+	assert.Nil(t, err)
+	assert.Equal(t, "https://gitlab.com/api/v4", p.(*gitLab).baseURL)
+
+	_, err = NewProvider(&api.SCMSource{Type: api.SVN})
+	assert.NotNil(t, err)
+}
+
+func TestGitHubWebhook(t *testing.T) {
+	var requests []request
+	s := fakeServer(t, &requests, http.StatusCreated, `{"id": 123}`)
+	defer s.Close()
+
+	p, _ := NewProvider(&api.SCMSource{Type: api.GitHub, Server: s.URL, Token: "t0ken"})
+	hook := &Hook{URL: "https://cyclone.example.com/apis/v1alpha1/webhooks/t1", Secret: "s3cret", Tag: true, PullRequest: true}
+	id, err := p.CreateWebhook("caicloud/cyclone", hook)
+	assert.Nil(t, err)
+	assert.Equal(t, "123", id)
+
+	assert.Nil(t, p.UpdateWebhook("caicloud/cyclone", id, hook))
+
+	assert.Equal(t, 2, len(requests))
+	assert.Equal(t, "POST", requests[0].method)
+	assert.Equal(t, "/api/v3/repos/caicloud/cyclone/hooks", requests[0].path)
+	assert.Equal(t, "token t0ken", requests[0].header.Get("Authorization"))
+	assert.Equal(t, []interface{}{"push", "pull_request"}, requests[0].body["events"])
+	assert.Equal(t, map[string]interface{}{
+		"url":          "https://cyclone.example.com/apis/v1alpha1/webhooks/t1",
+		"content_type": "json",
+		"secret":       "s3cret",
+		"insecure_ssl": "0",
+	}, requests[0].body["config"])
+	assert.Equal(t, "PATCH", requests[1].method)
+	assert.Equal(t, "/api/v3/repos/caicloud/cyclone/hooks/123", requests[1].path)
+}
+
+func TestGitLabWebhook(t *testing.T) {
+	var requests []request
+	s := fakeServer(t, &requests, http.StatusCreated, `{"id": 7}`)
+	defer s.Close()
+
+	p, _ := NewProvider(&api.SCMSource{Type: api.GitLab, Server: s.URL, Token: "t0ken"})
+	id, err := p.CreateWebhook("group/sub/project", &Hook{URL: "https://cyclone.example.com", Secret: "s3cret", Push: true})
+	assert.Nil(t, err)
+	assert.Equal(t, "7", id)
+
+	assert.Equal(t, "/api/v4/projects/group%2Fsub%2Fproject/hooks", requests[0].path)
+	assert.Equal(t, "t0ken", requests[0].header.Get("PRIVATE-TOKEN"))
+	assert.Equal(t, map[string]interface{}{
+		"url":                     "https://cyclone.example.com",
+		"token":                   "s3cret",
+		"push_events":             true,
+		"tag_push_events":         false,
+		"merge_requests_events":   false,
+		"enable_ssl_verification": true,
+	}, requests[0].body)
+}
+
+func TestErrors(t *testing.T) {
+	var requests []request
+	s := fakeServer(t, &requests, http.StatusNotFound, `{"message": "Not Found"}`)
+	defer s.Close()
+
+	p, _ := NewProvider(&api.SCMSource{Type: api.GitHub, Server: s.URL, User: "u", Password: "p"})
+	_, err := p.CreateWebhook("caicloud/cyclone", &Hook{})
+	assert.True(t, IsPermissionDenied(err))
+	assert.True(t, IsNotFound(err))
+	assert.Equal(t, "SCM responds 404: Not Found", err.Error())
+	user, password, ok := (&http.Request{Header: requests[0].header}).BasicAuth()
+	assert.True(t, ok)
+	assert.Equal(t, "u", user)
+	assert.Equal(t, "p", password)
+
+	// Webhooks already deleted
+	assert.Nil(t, p.DeleteWebhook("caicloud/cyclone", "123"))
+}
+
+func TestStatusAndComment(t *testing.T) {
+	var requests []request
+	s := fakeServer(t, &requests, http.StatusCreated, `{}`)
+	defer s.Close()
+
+	status := &CommitStatus{State: StateFailure, Context: "cyclone/ci", TargetURL: "https://cyclone.example.com/r1", Description: "failed"}
+	github, _ := NewProvider(&api.SCMSource{Type: api.GitHub, Server: s.URL, Token: "t0ken"})
+	assert.Nil(t, github.CreateStatus("caicloud/cyclone", "abc123", status))
+	assert.Nil(t, github.CreateComment("caicloud/cyclone", 12, "Done"))
+	gitlab, _ := NewProvider(&api.SCMSource{Type: api.GitLab, Server: s.URL, Token: "t0ken"})
+	assert.Nil(t, gitlab.CreateStatus("group/project", "abc123", status))
+	assert.Nil(t, gitlab.CreateComment("group/project", 3, "Done"))
+
+	assert.Equal(t, 4, len(requests))
+	assert.Equal(t, "/api/v3/repos/caicloud/cyclone/statuses/abc123", requests[0].path)
+	assert.Equal(t, map[string]interface{}{
+		"state":       "failure",
+		"context":     "cyclone/ci",
+		"target_url":  "https://cyclone.example.com/r1",
+		"description": "failed",
+	}, requests[0].body)
+	assert.Equal(t, "/api/v3/repos/caicloud/cyclone/issues/12/comments", requests[1].path)
+	assert.Equal(t, map[string]interface{}{"body": "Done"}, requests[1].body)
+	assert.Equal(t, "/api/v4/projects/group%2Fproject/statuses/abc123", requests[2].path)
+	assert.Equal(t, map[string]interface{}{
+		"state":       "failed",
+		"name":        "cyclone/ci",
+		"target_url":  "https://cyclone.example.com/r1",
+		"description": "failed",
+	}, requests[2].body)
+	assert.Equal(t, "/api/v4/projects/group%2Fproject/merge_requests/3/notes", requests[3].path)
+}
+
+func TestRepository(t *testing.T) {
+	assert.Equal(t, "caicloud/cyclone", Repository("https://github.com/caicloud/cyclone.git"))
+	assert.Equal(t, "group/sub/project", Repository("https://gitlab.example.com:8443/group/sub/project/"))
+	assert.Equal(t, "caicloud/cyclone", Repository("git@github.com:caicloud/cyclone.git"))
+	assert.Equal(t, "group/project", Repository("ssh://git@gitlab.example.com:2222/group/project.git"))
+	assert.Equal(t, "", Repository("https://github.com"))
+}
@@ -0,0 +1,183 @@
+package webhook
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/server/biz/scm"
+)
+
+// Parameters of Webhook type WorkflowTriggers, list values are separated by commas.
+const (
+	// ParamSecret is the secret to verify webhooks, it's required on creation. It's moved to a
+	// Secret in the tenant namespace when the trigger is saved, see SaveSecret.
+	ParamSecret = "secret"
+	// ParamSecretRef is name of the Secret holding the secret, it's set by Cyclone.
+	ParamSecretRef = "secretRef"
+	// ParamEvents are event types that trigger the workflow, defaults to 'push'.
+	ParamEvents = "events"
+	// ParamRepository is full name of the repository, e.g. 'caicloud/cyclone'. Events from other
+	// repositories are ignored if it's set.
+	ParamRepository = "repository"
+	// ParamBranches are glob patterns of branches, they apply to pushed branches and target branches
+	// of pull requests.
+	ParamBranches = "branches"
+	// ParamTags are glob patterns of pushed tags.
+	ParamTags = "tags"
+	// ParamPaths are glob patterns of changed files, workflow is triggered if any changed file
+	// matches. Patterns ending with '/**' match all files under the directory.
+	ParamPaths = "paths"
+	// ParamGitResources are Git resources to pull the revision of events. By default, Git resources
+	// of the workflow with URL of the event repository are used.
+	ParamGitResources = "gitResources"
+	// ParamSCMIntegration is the SCM integration to register webhook in the repository, repository
+	// is required if it's set.
+	ParamSCMIntegration = "scmIntegration"
+	// ParamComment determines whether to comment results of WorkflowRuns on pull requests, it's
+	// 'true' or 'false' (default). Commit statuses and comments are reported with the SCM integration.
+	ParamComment = "comment"
+)
+
+// Config is config of a Webhook type WorkflowTrigger.
+type Config struct {
+	Secret         string
+	SecretRef      string
+	Events         []EventType
+	Repository     string
+	Branches       []string
+	Tags           []string
+	Paths          []string
+	GitResources   []string
+	SCMIntegration string
+	Comment        bool
+}
+
+// NewConfig gets config from parameters of the WorkflowTrigger.
+func NewConfig(params []v1alpha1.ParameterItem) *Config {
+	c := &Config{Events: []EventType{PushEvent}}
+	for _, p := range params {
+		switch p.Name {
+		case ParamSecret:
+			c.Secret = p.Value
+		case ParamSecretRef:
+			c.SecretRef = p.Value
+		case ParamEvents:
+			if events := splitList(p.Value); len(events) > 0 {
+				c.Events = nil
+				for _, e := range events {
+					c.Events = append(c.Events, EventType(e))
+				}
+			}
+		case ParamRepository:
+			c.Repository = strings.TrimSpace(p.Value)
+		case ParamBranches:
+			c.Branches = splitList(p.Value)
+		case ParamTags:
+			c.Tags = splitList(p.Value)
+		case ParamPaths:
+			c.Paths = splitList(p.Value)
+		case ParamGitResources:
+			c.GitResources = splitList(p.Value)
+		case ParamSCMIntegration:
+			c.SCMIntegration = strings.TrimSpace(p.Value)
+		case ParamComment:
+			c.Comment = strings.TrimSpace(p.Value) == "true"
+		}
+	}
+	return c
+}
+
+// Validate validates the config, secret or reference of it is required and patterns should be valid.
+func (c *Config) Validate() error {
+	if c.Secret == "" && c.SecretRef == "" {
+		return fmt.Errorf("parameter %s is required", ParamSecret)
+	}
+	if c.SCMIntegration != "" && c.Repository == "" {
+		return fmt.Errorf("parameter %s is required to register webhook", ParamRepository)
+	}
+	for _, e := range c.Events {
+		switch e {
+		case PushEvent, TagEvent, PullRequestEvent:
+		default:
+			return fmt.Errorf("unsupported event type '%s'", e)
+		}
+	}
+	for _, patterns := range [][]string{c.Branches, c.Tags, c.Paths} {
+		for _, p := range patterns {
+			if _, err := path.Match(strings.TrimSuffix(p, "/**"), ""); err != nil {
+				return fmt.Errorf("invalid pattern '%s'", p)
+			}
+		}
+	}
+	return nil
+}
+
+// Match checks whether the event matches filters of the config. Branch filters don't apply to tag
+// events, and tag filters apply to tag events only. Path filters are skipped if changed files of
+// the event are unknown.
+func (c *Config) Match(e *Event) bool {
+	found := false
+	for _, t := range c.Events {
+		if t == e.Type {
+			found = true
+		}
+	}
+	if !found {
+		return false
+	}
+
+	if c.Repository != "" && !strings.EqualFold(c.Repository, e.Repo) {
+		return false
+	}
+	if e.Type == TagEvent {
+		if len(c.Tags) > 0 && !matchAny(c.Tags, e.Tag) {
+			return false
+		}
+	} else if len(c.Branches) > 0 && !matchAny(c.Branches, e.Branch) {
+		return false
+	}
+
+	if len(c.Paths) > 0 && e.ChangedFiles != nil {
+		for _, f := range e.ChangedFiles {
+			if matchAny(c.Paths, f) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// matchAny checks whether the value matches any of the glob patterns.
+func matchAny(patterns []string, value string) bool {
+	for _, p := range patterns {
+		if strings.HasSuffix(p, "/**") {
+			if dir := strings.TrimSuffix(p, "**"); strings.HasPrefix(value, dir) {
+				return true
+			}
+			continue
+		}
+		if ok, _ := path.Match(p, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchRepository checks whether the Git URL refers to the repository, both HTTPS and SSH URLs are
+// supported, e.g. 'https://github.com/caicloud/cyclone.git' and 'git@github.com:caicloud/cyclone.git'.
+func MatchRepository(url, repo string) bool {
+	return repo != "" && strings.EqualFold(scm.Repository(url), repo)
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,165 @@
+package webhook
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Source is the sender of webhooks.
+type Source string
+
+const (
+	// GitHub indicates webhooks from GitHub.
+	GitHub Source = "GitHub"
+	// GitLab indicates webhooks from GitLab.
+	GitLab Source = "GitLab"
+	// Generic indicates webhooks from other systems, they send events in Cyclone's format.
+	Generic Source = "Generic"
+)
+
+// TokenHeader is the header of secret token in generic webhooks.
+const TokenHeader = "X-Cyclone-Token"
+
+// EventType is type of webhook events that trigger workflows.
+type EventType string
+
+const (
+	// PushEvent is event of commits pushed to a branch.
+	PushEvent EventType = "push"
+	// TagEvent is event of a tag pushed.
+	TagEvent EventType = "tag"
+	// PullRequestEvent is event of a pull request (merge request in GitLab) opened or updated.
+	PullRequestEvent EventType = "pullRequest"
+)
+
+// Event is a SCM event that triggers workflows.
+type Event struct {
+	// Type is type of the event.
+	Type EventType `json:"type"`
+	// Repo is full name of the repository, e.g. 'caicloud/cyclone'.
+	Repo string `json:"repo"`
+	// Ref is the reference to pull, e.g. 'refs/heads/master', 'refs/tags/v1.0' and 'refs/pull/1/head'.
+	Ref string `json:"ref"`
+	// Branch is the branch pushed to, or target branch of the pull request.
+	Branch string `json:"branch,omitempty"`
+	// Tag is the tag pushed.
+	Tag string `json:"tag,omitempty"`
+	// Commit is SHA of the head commit.
+	Commit string `json:"commit,omitempty"`
+	// PullRequest is number of the pull request.
+	PullRequest int `json:"pullRequest,omitempty"`
+	// ChangedFiles are files changed by the event, nil means they are unknown, e.g. pull request
+	// events don't carry changed files.
+	ChangedFiles []string `json:"changedFiles,omitempty"`
+	// Sender is the user who caused the event.
+	Sender string `json:"sender,omitempty"`
+}
+
+// Revision gets the revision to pull for the event. Commit is preferred for push and tag events
+// to pin the revision, while reference is used for pull requests since their commits may be in
+// forked repositories.
+func (e *Event) Revision() string {
+	if e.Type != PullRequestEvent && e.Commit != "" {
+		return e.Commit
+	}
+	return e.Ref
+}
+
+// Payload is a received webhook request.
+type Payload struct {
+	// Source is sender of the webhook, detected from headers.
+	Source Source
+	header http.Header
+	body   []byte
+}
+
+// NewPayload creates a payload from the webhook request.
+func NewPayload(header http.Header, body []byte) *Payload {
+	source := Generic
+	if header.Get(githubEventHeader) != "" {
+		source = GitHub
+	} else if header.Get(gitlabEventHeader) != "" {
+		source = GitLab
+	}
+
+	return &Payload{
+		Source: source,
+		header: header,
+		body:   body,
+	}
+}
+
+// Verify verifies the payload with the secret, GitHub webhooks are signed with the secret, while
+// GitLab and generic webhooks carry the secret as token.
+func (p *Payload) Verify(secret string) bool {
+	if secret == "" {
+		return false
+	}
+
+	switch p.Source {
+	case GitHub:
+		return verifyGitHubSignature(p.header, p.body, secret)
+	case GitLab:
+		return equal(p.header.Get(gitlabTokenHeader), secret)
+	default:
+		return equal(p.header.Get(TokenHeader), secret)
+	}
+}
+
+// Event parses event from the payload. Nil is returned for events that don't trigger workflows,
+// e.g. GitHub ping events and branch deletions.
+func (p *Payload) Event() (*Event, error) {
+	switch p.Source {
+	case GitHub:
+		return parseGitHubEvent(p.header.Get(githubEventHeader), p.body)
+	case GitLab:
+		return parseGitLabEvent(p.header.Get(gitlabEventHeader), p.body)
+	default:
+		return parseGenericEvent(p.body)
+	}
+}
+
+// parseGenericEvent parses event in Cyclone's format, type and either ref or commit are required.
+func parseGenericEvent(body []byte) (*Event, error) {
+	e := &Event{}
+	if err := json.Unmarshal(body, e); err != nil {
+		return nil, fmt.Errorf("unmarshal event error: %v", err)
+	}
+
+	switch e.Type {
+	case PushEvent, TagEvent, PullRequestEvent:
+	default:
+		return nil, fmt.Errorf("unsupported event type '%s'", e.Type)
+	}
+	if e.Ref == "" && e.Commit == "" {
+		return nil, fmt.Errorf("ref or commit of event is required")
+	}
+	if e.Branch == "" && e.Type == PushEvent {
+		e.Branch = strings.TrimPrefix(e.Ref, branchRefPrefix)
+	}
+	if e.Tag == "" && e.Type == TagEvent {
+		e.Tag = strings.TrimPrefix(e.Ref, tagRefPrefix)
+	}
+	return e, nil
+}
+
+const (
+	branchRefPrefix = "refs/heads/"
+	tagRefPrefix    = "refs/tags/"
+)
+
+// refEvent creates push or tag event from the pushed reference.
+func refEvent(ref string) *Event {
+	if strings.HasPrefix(ref, tagRefPrefix) {
+		return &Event{Type: TagEvent, Ref: ref, Tag: strings.TrimPrefix(ref, tagRefPrefix)}
+	}
+	return &Event{Type: PushEvent, Ref: ref, Branch: strings.TrimPrefix(ref, branchRefPrefix)}
+}
+
+// equal compares secrets in constant time.
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
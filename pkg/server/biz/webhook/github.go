@@ -0,0 +1,131 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+const (
+	githubEventHeader        = "X-GitHub-Event"
+	githubSignatureHeader    = "X-Hub-Signature"
+	githubSignature256Header = "X-Hub-Signature-256"
+)
+
+// verifyGitHubSignature verifies HMAC signature of the body, SHA256 signature is preferred if given.
+func verifyGitHubSignature(header http.Header, body []byte, secret string) bool {
+	if sig := header.Get(githubSignature256Header); sig != "" {
+		return verifyHMAC(sha256.New, "sha256=", sig, body, secret)
+	}
+	return verifyHMAC(sha1.New, "sha1=", header.Get(githubSignatureHeader), body, secret)
+}
+
+func verifyHMAC(h func() hash.Hash, prefix, signature string, body []byte, secret string) bool {
+	if !strings.HasPrefix(signature, prefix) {
+		return false
+	}
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, prefix))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+type githubRepository struct {
+	FullName string `json:"full_name"`
+}
+
+type githubUser struct {
+	Login string `json:"login"`
+}
+
+type githubCommit struct {
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+	Modified []string `json:"modified"`
+}
+
+// githubPushEvent is payload of GitHub push events, both branches and tags.
+type githubPushEvent struct {
+	Ref        string           `json:"ref"`
+	After      string           `json:"after"`
+	Deleted    bool             `json:"deleted"`
+	Repository githubRepository `json:"repository"`
+	Sender     githubUser       `json:"sender"`
+	Commits    []githubCommit   `json:"commits"`
+}
+
+// githubPullRequestEvent is payload of GitHub pull request events.
+type githubPullRequestEvent struct {
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	PullRequest struct {
+		Head struct {
+			SHA string `json:"sha"`
+		} `json:"head"`
+		Base struct {
+			Ref string `json:"ref"`
+		} `json:"base"`
+	} `json:"pull_request"`
+	Repository githubRepository `json:"repository"`
+	Sender     githubUser       `json:"sender"`
+}
+
+// parseGitHubEvent parses push and pull_request events, pull requests trigger workflows only
+// when they are opened, reopened or have new commits.
+func parseGitHubEvent(eventType string, body []byte) (*Event, error) {
+	switch eventType {
+	case "push":
+		p := &githubPushEvent{}
+		if err := json.Unmarshal(body, p); err != nil {
+			return nil, fmt.Errorf("unmarshal GitHub push event error: %v", err)
+		}
+		if p.Deleted {
+			return nil, nil
+		}
+
+		e := refEvent(p.Ref)
+		e.Repo = p.Repository.FullName
+		e.Commit = p.After
+		e.Sender = p.Sender.Login
+		e.ChangedFiles = []string{}
+		for _, c := range p.Commits {
+			e.ChangedFiles = append(e.ChangedFiles, c.Added...)
+			e.ChangedFiles = append(e.ChangedFiles, c.Removed...)
+			e.ChangedFiles = append(e.ChangedFiles, c.Modified...)
+		}
+		return e, nil
+	case "pull_request":
+		p := &githubPullRequestEvent{}
+		if err := json.Unmarshal(body, p); err != nil {
+			return nil, fmt.Errorf("unmarshal GitHub pull request event error: %v", err)
+		}
+		switch p.Action {
+		case "opened", "reopened", "synchronize":
+		default:
+			return nil, nil
+		}
+
+		return &Event{
+			Type:        PullRequestEvent,
+			Repo:        p.Repository.FullName,
+			Ref:         fmt.Sprintf("refs/pull/%d/head", p.Number),
+			Branch:      p.PullRequest.Base.Ref,
+			Commit:      p.PullRequest.Head.SHA,
+			PullRequest: p.Number,
+			Sender:      p.Sender.Login,
+		}, nil
+	}
+
+	// Other events such as ping are ignored.
+	return nil, nil
+}
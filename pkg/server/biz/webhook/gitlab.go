@@ -0,0 +1,102 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	gitlabEventHeader = "X-Gitlab-Event"
+	gitlabTokenHeader = "X-Gitlab-Token"
+)
+
+type gitlabProject struct {
+	PathWithNamespace string `json:"path_with_namespace"`
+}
+
+type gitlabCommit struct {
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+	Modified []string `json:"modified"`
+}
+
+// gitlabPushEvent is payload of GitLab push and tag push events.
+type gitlabPushEvent struct {
+	Ref          string         `json:"ref"`
+	After        string         `json:"after"`
+	CheckoutSHA  string         `json:"checkout_sha"`
+	UserUsername string         `json:"user_username"`
+	Project      gitlabProject  `json:"project"`
+	Commits      []gitlabCommit `json:"commits"`
+}
+
+// gitlabMergeRequestEvent is payload of GitLab merge request events.
+type gitlabMergeRequestEvent struct {
+	User struct {
+		Username string `json:"username"`
+	} `json:"user"`
+	Project          gitlabProject `json:"project"`
+	ObjectAttributes struct {
+		IID          int    `json:"iid"`
+		Action       string `json:"action"`
+		TargetBranch string `json:"target_branch"`
+		// OldRev is set when new commits are pushed to the merge request.
+		OldRev     string `json:"oldrev"`
+		LastCommit struct {
+			ID string `json:"id"`
+		} `json:"last_commit"`
+	} `json:"object_attributes"`
+}
+
+// parseGitLabEvent parses push, tag push and merge request events, merge requests trigger workflows
+// only when they are opened, reopened or have new commits.
+func parseGitLabEvent(eventType string, body []byte) (*Event, error) {
+	switch eventType {
+	case "Push Hook", "Tag Push Hook":
+		p := &gitlabPushEvent{}
+		if err := json.Unmarshal(body, p); err != nil {
+			return nil, fmt.Errorf("unmarshal GitLab push event error: %v", err)
+		}
+		// Checkout SHA is null when branches or tags are deleted.
+		if p.CheckoutSHA == "" || strings.Trim(p.After, "0") == "" {
+			return nil, nil
+		}
+
+		e := refEvent(p.Ref)
+		e.Repo = p.Project.PathWithNamespace
+		e.Commit = p.CheckoutSHA
+		e.Sender = p.UserUsername
+		e.ChangedFiles = []string{}
+		for _, c := range p.Commits {
+			e.ChangedFiles = append(e.ChangedFiles, c.Added...)
+			e.ChangedFiles = append(e.ChangedFiles, c.Removed...)
+			e.ChangedFiles = append(e.ChangedFiles, c.Modified...)
+		}
+		return e, nil
+	case "Merge Request Hook":
+		p := &gitlabMergeRequestEvent{}
+		if err := json.Unmarshal(body, p); err != nil {
+			return nil, fmt.Errorf("unmarshal GitLab merge request event error: %v", err)
+		}
+		attrs := p.ObjectAttributes
+		switch {
+		case attrs.Action == "open" || attrs.Action == "reopen":
+		case attrs.Action == "update" && attrs.OldRev != "":
+		default:
+			return nil, nil
+		}
+
+		return &Event{
+			Type:        PullRequestEvent,
+			Repo:        p.Project.PathWithNamespace,
+			Ref:         fmt.Sprintf("refs/merge-requests/%d/head", attrs.IID),
+			Branch:      attrs.TargetBranch,
+			Commit:      attrs.LastCommit.ID,
+			PullRequest: attrs.IID,
+			Sender:      p.User.Username,
+		}, nil
+	}
+
+	return nil, nil
+}
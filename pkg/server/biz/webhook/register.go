@@ -0,0 +1,119 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/caicloud/nirvana/log"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/server/biz/scm"
+	"github.com/caicloud/cyclone/pkg/server/common"
+	"github.com/caicloud/cyclone/pkg/server/config"
+	"github.com/caicloud/cyclone/pkg/server/handler"
+)
+
+// URL gets URL of the webhook receiver of the tenant.
+func URL(tenant string) (string, error) {
+	if config.ExternalURL == "" {
+		return "", fmt.Errorf("%s is required to register webhooks", config.EnvExternalURL)
+	}
+	return fmt.Sprintf("%s/apis/v1alpha1/webhooks/%s", strings.TrimSuffix(config.ExternalURL, "/"), tenant), nil
+}
+
+// Register registers webhook in the SCM repository for Webhook type WorkflowTrigger that references
+// a SCM integration. Registered is the webhook registered for the trigger before, it's updated if
+// integration and repository are not changed, otherwise it's removed and a new one is registered.
+// Status of the registered webhook is returned, nil if the trigger doesn't need a webhook. Secret is
+// the webhook secret of the trigger.
+func Register(tenant string, wft *v1alpha1.WorkflowTrigger, secret string, registered *v1alpha1.WebhookStatus) (*v1alpha1.WebhookStatus, error) {
+	c := NewConfig(wft.Spec.Parameters)
+	needed := wft.Spec.Type == v1alpha1.WebhookTrigger && c.SCMIntegration != ""
+	if registered != nil && (!needed || registered.Integration != c.SCMIntegration || !strings.EqualFold(registered.Repository, c.Repository)) {
+		// Failure to remove the old webhook doesn't block the trigger, webhooks it sends would
+		// be rejected since they don't match the trigger any more.
+		if err := Unregister(tenant, registered); err != nil {
+			log.Warningf("Remove webhook %s from %s error: %v", registered.ID, registered.Repository, err)
+		}
+		registered = nil
+	}
+	if !needed {
+		return nil, nil
+	}
+
+	provider, err := getProvider(tenant, c.SCMIntegration)
+	if err != nil {
+		return nil, err
+	}
+	url, err := URL(tenant)
+	if err != nil {
+		return nil, err
+	}
+	hook := &scm.Hook{URL: url, Secret: secret}
+	for _, e := range c.Events {
+		switch e {
+		case PushEvent:
+			hook.Push = true
+		case TagEvent:
+			hook.Tag = true
+		case PullRequestEvent:
+			hook.PullRequest = true
+		}
+	}
+
+	if registered != nil {
+		err := provider.UpdateWebhook(c.Repository, registered.ID, hook)
+		if err == nil {
+			return registered, nil
+		}
+		// Webhook may be removed in SCM by users, register a new one.
+		if !scm.IsNotFound(err) {
+			return nil, err
+		}
+	}
+
+	id, err := provider.CreateWebhook(c.Repository, hook)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Webhook %s registered in %s for trigger %s/%s", id, c.Repository, tenant, wft.Name)
+	return &v1alpha1.WebhookStatus{
+		Integration: c.SCMIntegration,
+		Repository:  c.Repository,
+		ID:          id,
+	}, nil
+}
+
+// Unregister removes the webhook registered for a trigger.
+func Unregister(tenant string, registered *v1alpha1.WebhookStatus) error {
+	if registered == nil {
+		return nil
+	}
+
+	provider, err := getProvider(tenant, registered.Integration)
+	if err != nil {
+		return err
+	}
+	return provider.DeleteWebhook(registered.Repository, registered.ID)
+}
+
+// getProvider gets provider of the SCM integration in the tenant.
+func getProvider(tenant, integration string) (scm.Provider, error) {
+	secret, err := handler.K8sClient.CoreV1().Secrets(common.TenantNamespace(tenant)).Get(
+		common.IntegrationSecret(integration), meta_v1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	in := &api.Integration{}
+	if err := json.Unmarshal(secret.Data[common.SecretKeyIntegration], in); err != nil {
+		return nil, err
+	}
+	if in.Spec.Type != api.SCM || in.Spec.SCM == nil {
+		return nil, fmt.Errorf("integration %s is not a SCM integration", integration)
+	}
+	return scm.NewProvider(in.Spec.SCM)
+}
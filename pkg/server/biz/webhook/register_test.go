@@ -0,0 +1,108 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/k8s/clientset/fake"
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/server/common"
+	"github.com/caicloud/cyclone/pkg/server/config"
+	"github.com/caicloud/cyclone/pkg/server/handler"
+)
+
+func integrationSecret(t *testing.T, name string, source *api.SCMSource) *core_v1.Secret {
+	data, err := json.Marshal(&api.Integration{
+		Metadata: api.Metadata{Name: name},
+		Spec: api.IntegrationSpec{
+			Type:              api.SCM,
+			IntegrationSource: api.IntegrationSource{SCM: source},
+		},
+	})
+	assert.Nil(t, err)
+	return &core_v1.Secret{
+		ObjectMeta: meta_v1.ObjectMeta{Name: common.IntegrationSecret(name), Namespace: common.TenantNamespace("t1")},
+		Data:       map[string][]byte{common.SecretKeyIntegration: data},
+	}
+}
+
+func TestRegister(t *testing.T) {
+	var requests []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch {
+		case r.Method == http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id": 1}`))
+		case r.URL.Path == "/api/v3/repos/caicloud/removed/hooks/1":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer s.Close()
+
+	handler.InitHandlers(fake.NewSimpleClientset(
+		integrationSecret(t, "github", &api.SCMSource{Type: api.GitHub, Server: s.URL, Token: "t0ken"}),
+	))
+	config.ExternalURL = ""
+	defer func() { config.ExternalURL = "" }()
+
+	wft := &v1alpha1.WorkflowTrigger{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "wft"},
+		Spec: v1alpha1.WorkflowTriggerSpec{
+			Type: v1alpha1.WebhookTrigger,
+			Parameters: []v1alpha1.ParameterItem{
+				{Name: ParamSecret, Value: "s3cret"},
+				{Name: ParamRepository, Value: "caicloud/cyclone"},
+				{Name: ParamSCMIntegration, Value: "github"},
+			},
+		},
+	}
+	_, err := Register("t1", wft, "s3cret", nil)
+	assert.NotNil(t, err)
+
+	config.ExternalURL = "https://cyclone.example.com/"
+	hook, err := Register("t1", wft, "s3cret", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, &v1alpha1.WebhookStatus{Integration: "github", Repository: "caicloud/cyclone", ID: "1"}, hook)
+
+	// Update the registered webhook
+	hook, err = Register("t1", wft, "s3cret", hook)
+	assert.Nil(t, err)
+	assert.Equal(t, "1", hook.ID)
+
+	// Repository changed, webhook is moved
+	hook, err = Register("t1", wft, "s3cret", &v1alpha1.WebhookStatus{Integration: "github", Repository: "caicloud/old", ID: "2"})
+	assert.Nil(t, err)
+	assert.Equal(t, "caicloud/cyclone", hook.Repository)
+
+	// Webhook removed by users in SCM
+	wft.Spec.Parameters[1].Value = "caicloud/removed"
+	hook, err = Register("t1", wft, "s3cret", &v1alpha1.WebhookStatus{Integration: "github", Repository: "caicloud/removed", ID: "1"})
+	assert.Nil(t, err)
+	assert.Equal(t, "caicloud/removed", hook.Repository)
+
+	// Webhook no longer needed
+	wft.Spec.Type = v1alpha1.ScheduledTrigger
+	hook, err = Register("t1", wft, "s3cret", hook)
+	assert.Nil(t, err)
+	assert.Nil(t, hook)
+
+	assert.Equal(t, []string{
+		"POST /api/v3/repos/caicloud/cyclone/hooks",
+		"PATCH /api/v3/repos/caicloud/cyclone/hooks/1",
+		"DELETE /api/v3/repos/caicloud/old/hooks/2",
+		"POST /api/v3/repos/caicloud/cyclone/hooks",
+		"PATCH /api/v3/repos/caicloud/removed/hooks/1",
+		"POST /api/v3/repos/caicloud/removed/hooks",
+		"DELETE /api/v3/repos/caicloud/removed/hooks/1",
+	}, requests)
+}
@@ -0,0 +1,95 @@
+package webhook
+
+import (
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/server/common"
+	"github.com/caicloud/cyclone/pkg/server/handler"
+)
+
+// secretKey is key of the webhook secret in the Secret data.
+const secretKey = "secret"
+
+// SecretName gets name of the Secret holding webhook secret of the trigger.
+func SecretName(trigger string) string {
+	return "cyclone-webhook-" + trigger
+}
+
+// TakeSecret removes secret from parameters of the trigger and references the Secret of the
+// trigger instead, so that the secret isn't exposed by the trigger. The secret removed is returned,
+// it's empty if not given, and it should be saved by SaveSecret.
+func TakeSecret(wft *v1alpha1.WorkflowTrigger) string {
+	var params []v1alpha1.ParameterItem
+	for _, p := range wft.Spec.Parameters {
+		if p.Name != ParamSecret && p.Name != ParamSecretRef {
+			params = append(params, p)
+		}
+	}
+	secret := NewConfig(wft.Spec.Parameters).Secret
+	wft.Spec.Parameters = append(params, v1alpha1.ParameterItem{Name: ParamSecretRef, Value: SecretName(wft.Name)})
+	return secret
+}
+
+// SaveSecret saves webhook secret of the trigger to Secret in the tenant namespace.
+func SaveSecret(tenant, trigger, value string) error {
+	secrets := handler.K8sClient.CoreV1().Secrets(common.TenantNamespace(tenant))
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		secret, err := secrets.Get(SecretName(trigger), meta_v1.GetOptions{})
+		if errors.IsNotFound(err) {
+			_, err = secrets.Create(&core_v1.Secret{
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name: SecretName(trigger),
+					Labels: map[string]string{
+						common.LabelOwner: common.OwnerCyclone,
+					},
+				},
+				Data: map[string][]byte{secretKey: []byte(value)},
+			})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		secret.Data = map[string][]byte{secretKey: []byte(value)}
+		_, err = secrets.Update(secret)
+		return err
+	})
+}
+
+// DeleteSecret deletes Secret holding webhook secret of the trigger.
+func DeleteSecret(tenant, trigger string) error {
+	err := handler.K8sClient.CoreV1().Secrets(common.TenantNamespace(tenant)).Delete(SecretName(trigger), &meta_v1.DeleteOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// Secret gets webhook secret of the trigger from the referenced Secret, or from parameters for
+// triggers saved before secrets are moved to Secrets.
+func Secret(tenant string, wft *v1alpha1.WorkflowTrigger) (string, error) {
+	c := NewConfig(wft.Spec.Parameters)
+	if c.SecretRef == "" {
+		return c.Secret, nil
+	}
+
+	secret, err := handler.K8sClient.CoreV1().Secrets(common.TenantNamespace(tenant)).Get(c.SecretRef, meta_v1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	return string(secret.Data[secretKey]), nil
+}
+
+// RedactSecret clears secret in parameters of triggers saved before secrets are moved to Secrets,
+// it's used before triggers are returned to users.
+func RedactSecret(wft *v1alpha1.WorkflowTrigger) {
+	for i := range wft.Spec.Parameters {
+		if wft.Spec.Parameters[i].Name == ParamSecret {
+			wft.Spec.Parameters[i].Value = ""
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package webhook
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/caicloud/nirvana/log"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/server/common"
+	"github.com/caicloud/cyclone/pkg/server/handler"
+	wfcommon "github.com/caicloud/cyclone/pkg/workflow/common"
+	gitresolver "github.com/caicloud/cyclone/pkg/workflow/resolver/git"
+)
+
+// ErrUnverified is returned if no WorkflowTrigger accepts the webhook, since none of their
+// secrets verifies it.
+var ErrUnverified = fmt.Errorf("webhook isn't verified by any workflow trigger")
+
+// Trigger triggers workflows for the webhook by Webhook type WorkflowTriggers in the tenant. The
+// webhook is verified by secret of each trigger, and workflows are triggered only if the event
+// matches filters of the trigger. Names of created WorkflowRuns are returned.
+func Trigger(tenant string, payload *Payload) ([]string, error) {
+	wfts, err := handler.K8sClient.CycloneV1alpha1().WorkflowTriggers(common.TenantNamespace(tenant)).List(meta_v1.ListOptions{})
+	if err != nil {
+		log.Errorf("List workflowtriggers in tenant %s error: %v", tenant, err)
+		return nil, err
+	}
+
+	var verified []v1alpha1.WorkflowTrigger
+	for _, wft := range wfts.Items {
+		if wft.Spec.Type != v1alpha1.WebhookTrigger {
+			continue
+		}
+		secret, err := Secret(tenant, &wft)
+		if err != nil {
+			log.Warningf("Get secret of workflowtrigger %s/%s error: %v", tenant, wft.Name, err)
+			continue
+		}
+		if payload.Verify(secret) {
+			verified = append(verified, wft)
+		}
+	}
+	if len(verified) == 0 {
+		return nil, ErrUnverified
+	}
+
+	event, err := payload.Event()
+	if err != nil {
+		return nil, err
+	}
+	// Events such as ping don't trigger workflows.
+	if event == nil {
+		return []string{}, nil
+	}
+
+	runs := []string{}
+	for i := range verified {
+		wft := &verified[i]
+		if wft.Spec.Disabled || !NewConfig(wft.Spec.Parameters).Match(event) {
+			continue
+		}
+
+		wfr, err := createWorkflowRun(wft, event)
+		if err != nil {
+			log.Errorf("Trigger workflow by %s/%s error: %v", tenant, wft.Name, err)
+			continue
+		}
+		log.Infof("WorkflowRun %s/%s created by %s event of %s", tenant, wfr.Name, event.Type, event.Repo)
+		runs = append(runs, wfr.Name)
+	}
+	return runs, nil
+}
+
+// createWorkflowRun creates WorkflowRun from the trigger, revision of the event is set to Git
+// resources as GIT_REVISION parameter.
+func createWorkflowRun(wft *v1alpha1.WorkflowTrigger, event *Event) (*v1alpha1.WorkflowRun, error) {
+	if wft.Spec.WorkflowRef == nil {
+		return nil, fmt.Errorf("workflowRef of trigger is required")
+	}
+
+	wfr := &v1alpha1.WorkflowRun{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Labels: map[string]string{
+				common.LabelProject:               wft.Labels[common.LabelProject],
+				wfcommon.WorkflowRunLabelName:     wft.Spec.WorkflowRef.Name,
+				wfcommon.WorkflowTriggerLabelName: wft.Name,
+			},
+			Annotations: map[string]string{
+				wfcommon.SCMEventAnnotationName:  string(event.Type),
+				wfcommon.SCMRepoAnnotationName:   event.Repo,
+				wfcommon.SCMCommitAnnotationName: event.Commit,
+			},
+		},
+		Spec: *wft.Spec.WorkflowRunSpec.DeepCopy(),
+	}
+	c := NewConfig(wft.Spec.Parameters)
+	if c.SCMIntegration != "" {
+		wfr.Annotations[wfcommon.SCMIntegrationAnnotationName] = c.SCMIntegration
+	}
+	if event.Type == PullRequestEvent {
+		wfr.Annotations[wfcommon.SCMPullRequestAnnotationName] = strconv.Itoa(event.PullRequest)
+		if c.Comment {
+			wfr.Annotations[wfcommon.SCMCommentAnnotationName] = "true"
+		}
+	}
+
+	resources, err := gitResources(wft, event)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range resources {
+		setParameter(&wfr.Spec.Resources, r, gitresolver.EnvRevision, event.Revision())
+	}
+
+	for {
+		wfr.Name = fmt.Sprintf("%s-%s", wft.Name, rand.String(5))
+		created, err := handler.K8sClient.CycloneV1alpha1().WorkflowRuns(wft.Namespace).Create(wfr)
+		if errors.IsAlreadyExists(err) {
+			continue
+		}
+		return created, err
+	}
+}
+
+// gitResources gets Git resources to set revision of the event. Resources given in trigger
+// parameters are used if any, otherwise Git resources in the workflow whose URL refers to the
+// event repository are used.
+func gitResources(wft *v1alpha1.WorkflowTrigger, event *Event) ([]string, error) {
+	if resources := NewConfig(wft.Spec.Parameters).GitResources; len(resources) > 0 {
+		return resources, nil
+	}
+
+	client := handler.K8sClient.CycloneV1alpha1()
+	wf, err := client.Workflows(wft.Namespace).Get(wft.Spec.WorkflowRef.Name, meta_v1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []string
+	visited := make(map[string]bool)
+	for _, s := range wf.Spec.Stages {
+		stage, err := client.Stages(wft.Namespace).Get(s.Name, meta_v1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		if stage.Spec.Pod == nil {
+			continue
+		}
+
+		for _, item := range stage.Spec.Pod.Inputs.Resources {
+			if visited[item.Name] {
+				continue
+			}
+			visited[item.Name] = true
+
+			resource, err := client.Resources(wft.Namespace).Get(item.Name, meta_v1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			if resource.Spec.Type != v1alpha1.GitResourceType {
+				continue
+			}
+			for _, p := range resource.Spec.Parameters {
+				if p.Name == gitresolver.EnvURL && MatchRepository(p.Value, event.Repo) {
+					resources = append(resources, item.Name)
+				}
+			}
+		}
+	}
+	return resources, nil
+}
+
+// setParameter sets parameter of the resource or stage in the parameter configs.
+func setParameter(configs *[]v1alpha1.ParameterConfig, name, key, value string) {
+	for i := range *configs {
+		c := &(*configs)[i]
+		if c.Name != name {
+			continue
+		}
+		for j := range c.Parameters {
+			if c.Parameters[j].Name == key {
+				c.Parameters[j].Value = value
+				return
+			}
+		}
+		c.Parameters = append(c.Parameters, v1alpha1.ParameterItem{Name: key, Value: value})
+		return
+	}
+	*configs = append(*configs, v1alpha1.ParameterConfig{
+		Name:       name,
+		Parameters: []v1alpha1.ParameterItem{{Name: key, Value: value}},
+	})
+}
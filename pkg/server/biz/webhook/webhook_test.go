@@ -0,0 +1,295 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/k8s/clientset/fake"
+	"github.com/caicloud/cyclone/pkg/server/common"
+	"github.com/caicloud/cyclone/pkg/server/handler"
+	wfcommon "github.com/caicloud/cyclone/pkg/workflow/common"
+)
+
+const githubPush = `{
+  "ref": "refs/heads/master",
+  "after": "0123456789abcdef0123456789abcdef01234567",
+  "deleted": false,
+  "repository": {"full_name": "caicloud/cyclone"},
+  "sender": {"login": "alice"},
+  "commits": [
+    {"added": ["docs/a.md"], "removed": [], "modified": ["pkg/server/server.go"]}
+  ]
+}`
+
+const githubPullRequest = `{
+  "action": "synchronize",
+  "number": 12,
+  "pull_request": {"head": {"sha": "89abcdef0123456789abcdef0123456789abcdef"}, "base": {"ref": "master"}},
+  "repository": {"full_name": "caicloud/cyclone"},
+  "sender": {"login": "bob"}
+}`
+
+const gitlabTagPush = `{
+  "ref": "refs/tags/v1.0.0",
+  "after": "0123456789abcdef0123456789abcdef01234567",
+  "checkout_sha": "0123456789abcdef0123456789abcdef01234567",
+  "user_username": "alice",
+  "project": {"path_with_namespace": "group/sub/project"},
+  "commits": []
+}`
+
+const gitlabMergeRequest = `{
+  "user": {"username": "bob"},
+  "project": {"path_with_namespace": "group/project"},
+  "object_attributes": {"iid": 3, "action": "update", "target_branch": "develop", "oldrev": "aaaa", "last_commit": {"id": "bbbb"}}
+}`
+
+func sign(body, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestGitHubPayload(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-GitHub-Event", "push")
+	header.Set("X-Hub-Signature-256", sign(githubPush, "s3cret"))
+	p := NewPayload(header, []byte(githubPush))
+	assert.Equal(t, GitHub, p.Source)
+	assert.True(t, p.Verify("s3cret"))
+	assert.False(t, p.Verify("other"))
+	assert.False(t, p.Verify(""))
+
+	e, err := p.Event()
+	assert.Nil(t, err)
+	assert.Equal(t, &Event{
+		Type:         PushEvent,
+		Repo:         "caicloud/cyclone",
+		Ref:          "refs/heads/master",
+		Branch:       "master",
+		Commit:       "0123456789abcdef0123456789abcdef01234567",
+		ChangedFiles: []string{"docs/a.md", "pkg/server/server.go"},
+		Sender:       "alice",
+	}, e)
+	assert.Equal(t, "0123456789abcdef0123456789abcdef01234567", e.Revision())
+
+	header.Set("X-GitHub-Event", "pull_request")
+	e, err = NewPayload(header, []byte(githubPullRequest)).Event()
+	assert.Nil(t, err)
+	assert.Equal(t, PullRequestEvent, e.Type)
+	assert.Equal(t, "master", e.Branch)
+	assert.Equal(t, 12, e.PullRequest)
+	assert.Nil(t, e.ChangedFiles)
+	assert.Equal(t, "refs/pull/12/head", e.Revision())
+
+	header.Set("X-GitHub-Event", "ping")
+	e, err = NewPayload(header, []byte(`{}`)).Event()
+	assert.Nil(t, err)
+	assert.Nil(t, e)
+}
+
+func TestGitLabPayload(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-Gitlab-Event", "Tag Push Hook")
+	header.Set("X-Gitlab-Token", "s3cret")
+	p := NewPayload(header, []byte(gitlabTagPush))
+	assert.Equal(t, GitLab, p.Source)
+	assert.True(t, p.Verify("s3cret"))
+	assert.False(t, p.Verify("other"))
+
+	e, err := p.Event()
+	assert.Nil(t, err)
+	assert.Equal(t, TagEvent, e.Type)
+	assert.Equal(t, "v1.0.0", e.Tag)
+	assert.Equal(t, "group/sub/project", e.Repo)
+
+	header.Set("X-Gitlab-Event", "Merge Request Hook")
+	e, err = NewPayload(header, []byte(gitlabMergeRequest)).Event()
+	assert.Nil(t, err)
+	assert.Equal(t, PullRequestEvent, e.Type)
+	assert.Equal(t, "develop", e.Branch)
+	assert.Equal(t, "refs/merge-requests/3/head", e.Revision())
+
+	// Branch deleted
+	header.Set("X-Gitlab-Event", "Push Hook")
+	e, err = NewPayload(header, []byte(`{"ref": "refs/heads/a", "after": "0000000000000000000000000000000000000000"}`)).Event()
+	assert.Nil(t, err)
+	assert.Nil(t, e)
+}
+
+func TestGenericPayload(t *testing.T) {
+	header := http.Header{}
+	header.Set(TokenHeader, "s3cret")
+	p := NewPayload(header, []byte(`{"type": "push", "repo": "a/b", "ref": "refs/heads/dev"}`))
+	assert.Equal(t, Generic, p.Source)
+	assert.True(t, p.Verify("s3cret"))
+
+	e, err := p.Event()
+	assert.Nil(t, err)
+	assert.Equal(t, "dev", e.Branch)
+	assert.Equal(t, "refs/heads/dev", e.Revision())
+
+	_, err = NewPayload(header, []byte(`{"type": "unknown", "ref": "master"}`)).Event()
+	assert.NotNil(t, err)
+	_, err = NewPayload(header, []byte(`{"type": "push"}`)).Event()
+	assert.NotNil(t, err)
+}
+
+func TestConfigMatch(t *testing.T) {
+	c := NewConfig([]v1alpha1.ParameterItem{
+		{Name: ParamSecret, Value: "s3cret"},
+		{Name: ParamEvents, Value: "push, tag"},
+		{Name: ParamRepository, Value: "Caicloud/Cyclone"},
+		{Name: ParamBranches, Value: "master,release-*"},
+		{Name: ParamTags, Value: "v*"},
+		{Name: ParamPaths, Value: "pkg/**,*.go"},
+	})
+	assert.Nil(t, c.Validate())
+
+	cases := []struct {
+		event    Event
+		expected bool
+	}{
+		{Event{Type: PushEvent, Repo: "caicloud/cyclone", Branch: "master", ChangedFiles: []string{"pkg/a/b.go"}}, true},
+		{Event{Type: PushEvent, Repo: "caicloud/cyclone", Branch: "release-1.0", ChangedFiles: []string{"main.go"}}, true},
+		{Event{Type: PushEvent, Repo: "caicloud/cyclone", Branch: "master", ChangedFiles: []string{"docs/a.md"}}, false},
+		{Event{Type: PushEvent, Repo: "caicloud/cyclone", Branch: "feature/a", ChangedFiles: []string{"main.go"}}, false},
+		{Event{Type: PushEvent, Repo: "caicloud/other", Branch: "master", ChangedFiles: []string{"main.go"}}, false},
+		{Event{Type: TagEvent, Repo: "caicloud/cyclone", Tag: "v1.0", ChangedFiles: []string{"main.go"}}, true},
+		{Event{Type: TagEvent, Repo: "caicloud/cyclone", Tag: "latest", ChangedFiles: []string{"main.go"}}, false},
+		{Event{Type: PullRequestEvent, Repo: "caicloud/cyclone", Branch: "master"}, false},
+	}
+	for i, c2 := range cases {
+		assert.Equal(t, c2.expected, c.Match(&c2.event), "case %d", i)
+	}
+
+	// Push events by default, paths are skipped if changed files are unknown.
+	c = NewConfig([]v1alpha1.ParameterItem{{Name: ParamSecret, Value: "s"}, {Name: ParamPaths, Value: "pkg/**"}})
+	assert.True(t, c.Match(&Event{Type: PushEvent, Branch: "any"}))
+	assert.False(t, c.Match(&Event{Type: PullRequestEvent, Branch: "any"}))
+
+	assert.NotNil(t, NewConfig(nil).Validate())
+	assert.NotNil(t, NewConfig([]v1alpha1.ParameterItem{{Name: ParamSecret, Value: "s"}, {Name: ParamEvents, Value: "merge"}}).Validate())
+	assert.NotNil(t, NewConfig([]v1alpha1.ParameterItem{{Name: ParamSecret, Value: "s"}, {Name: ParamBranches, Value: "[a"}}).Validate())
+}
+
+func TestMatchRepository(t *testing.T) {
+	assert.True(t, MatchRepository("https://github.com/caicloud/cyclone.git", "caicloud/cyclone"))
+	assert.True(t, MatchRepository("https://gitlab.example.com:8443/group/sub/project", "group/sub/project"))
+	assert.True(t, MatchRepository("git@github.com:caicloud/cyclone.git", "caicloud/cyclone"))
+	assert.True(t, MatchRepository("ssh://git@gitlab.example.com:2222/group/project.git", "group/project"))
+	assert.False(t, MatchRepository("https://github.com/caicloud/cyclone.git", "caicloud/other"))
+	assert.False(t, MatchRepository("https://github.com/caicloud/cyclone.git", ""))
+}
+
+func TestTrigger(t *testing.T) {
+	ns := common.TenantNamespace("t1")
+	trigger := func(name, secret, branches string, disabled bool) *v1alpha1.WorkflowTrigger {
+		// Secret is referenced if not given in parameters.
+		param := v1alpha1.ParameterItem{Name: ParamSecret, Value: secret}
+		if secret == "" {
+			param = v1alpha1.ParameterItem{Name: ParamSecretRef, Value: SecretName(name)}
+		}
+		return &v1alpha1.WorkflowTrigger{
+			ObjectMeta: meta_v1.ObjectMeta{Name: name, Namespace: ns, Labels: map[string]string{common.LabelProject: "p1"}},
+			Spec: v1alpha1.WorkflowTriggerSpec{
+				Type: v1alpha1.WebhookTrigger,
+				Parameters: []v1alpha1.ParameterItem{
+					param,
+					{Name: ParamBranches, Value: branches},
+				},
+				Disabled: disabled,
+				WorkflowRunSpec: v1alpha1.WorkflowRunSpec{
+					WorkflowRef: &corev1.ObjectReference{Name: "wf"},
+				},
+			},
+		}
+	}
+	client := fake.NewSimpleClientset(
+		trigger("master", "s3cret", "master", false),
+		trigger("develop", "s3cret", "develop", false),
+		trigger("disabled", "s3cret", "master", true),
+		trigger("other", "other", "master", false),
+		trigger("ref", "", "master", false),
+		&corev1.Secret{
+			ObjectMeta: meta_v1.ObjectMeta{Name: SecretName("ref"), Namespace: ns},
+			Data:       map[string][]byte{secretKey: []byte("s3cret")},
+		},
+		&v1alpha1.Workflow{
+			ObjectMeta: meta_v1.ObjectMeta{Name: "wf", Namespace: ns},
+			Spec:       v1alpha1.WorkflowSpec{Stages: []v1alpha1.StageItem{{Name: "build"}}},
+		},
+		&v1alpha1.Stage{
+			ObjectMeta: meta_v1.ObjectMeta{Name: "build", Namespace: ns},
+			Spec: v1alpha1.StageSpec{Pod: &v1alpha1.PodWorkload{Inputs: v1alpha1.Inputs{
+				Resources: []v1alpha1.ResourceItem{{Name: "code"}, {Name: "tools"}},
+			}}},
+		},
+		&v1alpha1.Resource{
+			ObjectMeta: meta_v1.ObjectMeta{Name: "code", Namespace: ns},
+			Spec: v1alpha1.ResourceSpec{Type: v1alpha1.GitResourceType, Parameters: []v1alpha1.ParameterItem{
+				{Name: "GIT_URL", Value: "https://github.com/caicloud/cyclone.git"},
+			}},
+		},
+		&v1alpha1.Resource{
+			ObjectMeta: meta_v1.ObjectMeta{Name: "tools", Namespace: ns},
+			Spec: v1alpha1.ResourceSpec{Type: v1alpha1.GitResourceType, Parameters: []v1alpha1.ParameterItem{
+				{Name: "GIT_URL", Value: "https://github.com/caicloud/tools.git"},
+			}},
+		},
+	)
+	handler.InitHandlers(client)
+
+	header := http.Header{}
+	header.Set("X-GitHub-Event", "push")
+	header.Set("X-Hub-Signature-256", sign(githubPush, "wrong"))
+	_, err := Trigger("t1", NewPayload(header, []byte(githubPush)))
+	assert.Equal(t, ErrUnverified, err)
+
+	header.Set("X-Hub-Signature-256", sign(githubPush, "s3cret"))
+	runs, err := Trigger("t1", NewPayload(header, []byte(githubPush)))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(runs))
+	sort.Strings(runs)
+	assert.True(t, strings.HasPrefix(runs[1], "ref-"))
+
+	wfr, err := client.CycloneV1alpha1().WorkflowRuns(ns).Get(runs[0], meta_v1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "master", wfr.Labels[wfcommon.WorkflowTriggerLabelName])
+	assert.Equal(t, "wf", wfr.Labels[wfcommon.WorkflowRunLabelName])
+	assert.Equal(t, "p1", wfr.Labels[common.LabelProject])
+	assert.Equal(t, "0123456789abcdef0123456789abcdef01234567", wfr.Annotations[wfcommon.SCMCommitAnnotationName])
+	assert.Equal(t, []v1alpha1.ParameterConfig{{
+		Name:       "code",
+		Parameters: []v1alpha1.ParameterItem{{Name: "GIT_REVISION", Value: "0123456789abcdef0123456789abcdef01234567"}},
+	}}, wfr.Spec.Resources)
+
+	header.Set("X-GitHub-Event", "ping")
+	header.Set("X-Hub-Signature-256", sign(`{}`, "s3cret"))
+	runs, err = Trigger("t1", NewPayload(header, []byte(`{}`)))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(runs))
+}
+
+func TestSetParameter(t *testing.T) {
+	configs := []v1alpha1.ParameterConfig{{
+		Name:       "code",
+		Parameters: []v1alpha1.ParameterItem{{Name: "GIT_REVISION", Value: "master"}, {Name: "GIT_DEPTH", Value: "1"}},
+	}}
+	setParameter(&configs, "code", "GIT_REVISION", "dev")
+	setParameter(&configs, "other", "GIT_REVISION", "dev")
+	assert.Equal(t, []v1alpha1.ParameterConfig{
+		{Name: "code", Parameters: []v1alpha1.ParameterItem{{Name: "GIT_REVISION", Value: "dev"}, {Name: "GIT_DEPTH", Value: "1"}}},
+		{Name: "other", Parameters: []v1alpha1.ParameterItem{{Name: "GIT_REVISION", Value: "dev"}}},
+	}, configs)
+}
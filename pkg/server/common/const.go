@@ -51,6 +51,28 @@ const (
 	// SecretKeyIntegration is the key of the secret dada to indicate its value is about integration information.
 	SecretKeyIntegration = "integration"
 
+	// AdminUser is name of the system admin user created when Cyclone start.
+	AdminUser = "admin"
+
+	// UserSecretPrefix is name prefix of secrets holding user accounts in system namespace.
+	UserSecretPrefix = "cyclone-user-"
+
+	// TokenSecretPrefix is name prefix of secrets holding API tokens in system namespace.
+	TokenSecretPrefix = "cyclone-token-"
+
+	// LabelUser is the label key used to indicate the user which the user account or token belongs to
+	LabelUser = "cyclone.io/user"
+
+	// LabelUserID is the label key used to indicate hash of the ID in identity provider which the user
+	// account is bound to
+	LabelUserID = "cyclone.io/user-id"
+
+	// SecretKeyUser is the key of the secret data to indicate its value is about user account.
+	SecretKeyUser = "user"
+
+	// SecretKeyToken is the key of the secret data to indicate its value is about API token.
+	SecretKeyToken = "token"
+
 	// ControlClusterName is the name of control cluster
 	ControlClusterName = "control-cluster"
 )
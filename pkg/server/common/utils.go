@@ -2,6 +2,7 @@ package common
 
 import (
 	"fmt"
+	"strings"
 )
 
 // TenantNamespace gets namespace from given tenant name.
@@ -9,6 +10,11 @@ func TenantNamespace(tenant string) string {
 	return fmt.Sprintf("cyclone--%s", tenant)
 }
 
+// NamespaceTenant gets tenant name from given namespace, it's the reverse of TenantNamespace.
+func NamespaceTenant(namespace string) string {
+	return strings.TrimPrefix(namespace, "cyclone--")
+}
+
 // TenantPVC returns pvc name related the tenant
 func TenantPVC(tenant string) string {
 	return TenantPVCPrefix + tenant
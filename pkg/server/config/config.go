@@ -17,6 +17,22 @@ const (
 	EnvKubeConfig = "ENV_KUBE_CONFIG"
 	// EnvLogLevel is environment variable name defining log level
 	EnvLogLevel = "ENV_LOG_LEVEL"
+	// EnvSystemNamespace is environment variable name defining namespace where Cyclone components run
+	EnvSystemNamespace = "ENV_SYSTEM_NAMESPACE"
+	// EnvWorkflowControllerConfigMap is environment variable name defining ConfigMap of workflow controller
+	EnvWorkflowControllerConfigMap = "ENV_WORKFLOW_CONTROLLER_CONFIGMAP"
+	// EnvAuthEnabled is environment variable name defining whether API requests are authenticated
+	EnvAuthEnabled = "ENV_AUTH_ENABLED"
+	// EnvAdminPassword is environment variable name defining initial password of the admin user
+	EnvAdminPassword = "ENV_ADMIN_PASSWORD"
+	// EnvIdentityProviders is environment variable name defining path of external identity providers config file
+	EnvIdentityProviders = "ENV_IDENTITY_PROVIDERS"
+	// EnvAuditSink is environment variable name defining where audit records go, 'file', 'stdout' or 'none'
+	EnvAuditSink = "ENV_AUDIT_SINK"
+	// EnvAuditFile is environment variable name defining path of audit log file
+	EnvAuditFile = "ENV_AUDIT_FILE"
+	// EnvExternalURL is environment variable name defining URL of Cyclone-Server accessed from outside, e.g. by SCM webhooks
+	EnvExternalURL = "ENV_EXTERNAL_URL"
 
 	// FlagCycloneServerPort ...
 	FlagCycloneServerPort = "cyclone-server-port"
@@ -43,6 +59,21 @@ const (
 
 	// DefaultLogLevel ...
 	DefaultLogLevel = "info"
+
+	// DefaultSystemNamespace ...
+	DefaultSystemNamespace = "default"
+
+	// DefaultWorkflowControllerConfigMap ...
+	DefaultWorkflowControllerConfigMap = "workflow-controller-config"
+
+	// DefaultAuthEnabled ...
+	DefaultAuthEnabled = "false"
+
+	// DefaultAuditSink ...
+	DefaultAuditSink = "file"
+
+	// DefaultAuditFile ...
+	DefaultAuditFile = "/var/lib/cyclone/audit/audit.log"
 )
 
 var (
@@ -61,6 +92,29 @@ var (
 
 	// StorageClass defines which storageclass used to create pvc for default tenant
 	StorageClass string
+
+	// SystemNamespace defines namespace where Cyclone components run
+	SystemNamespace string
+	// WorkflowControllerConfigMap defines ConfigMap of workflow controller, Cyclone Server reads
+	// artifact store config from it.
+	WorkflowControllerConfigMap string
+
+	// AuthEnabled defines whether API requests are authenticated and authorized.
+	AuthEnabled bool
+	// AdminPassword defines initial password of the admin user, a random one is generated if empty.
+	AdminPassword string
+	// IdentityProviders defines path of the config file of external identity providers, e.g. LDAP
+	// and OIDC, only local users are supported if empty.
+	IdentityProviders string
+
+	// AuditSink defines where audit records of mutating API calls go.
+	AuditSink string
+	// AuditFile defines path of audit log file used by file sink.
+	AuditFile string
+
+	// ExternalURL defines URL of Cyclone Server accessed from outside, e.g. 'https://cyclone.example.com',
+	// it's used in webhooks registered to SCM.
+	ExternalURL string
 )
 
 func init() {
@@ -75,6 +129,22 @@ func init() {
 
 	// log
 	LogLevel = LoadEnvVar(EnvLogLevel, DefaultLogLevel, true)
+
+	// workflow
+	SystemNamespace = LoadEnvVar(EnvSystemNamespace, DefaultSystemNamespace, true)
+	WorkflowControllerConfigMap = LoadEnvVar(EnvWorkflowControllerConfigMap, DefaultWorkflowControllerConfigMap, true)
+
+	// auth
+	AuthEnabled = LoadEnvVar(EnvAuthEnabled, DefaultAuthEnabled, true) == "true"
+	AdminPassword = LoadEnvVar(EnvAdminPassword, "", true)
+	IdentityProviders = LoadEnvVar(EnvIdentityProviders, "", true)
+
+	// audit
+	AuditSink = LoadEnvVar(EnvAuditSink, DefaultAuditSink, true)
+	AuditFile = LoadEnvVar(EnvAuditFile, DefaultAuditFile, true)
+
+	// webhook
+	ExternalURL = LoadEnvVar(EnvExternalURL, "", true)
 }
 
 // GetStringEnvWithDefault retrieves the value of the environment variable named
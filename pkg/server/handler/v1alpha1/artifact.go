@@ -0,0 +1,397 @@
+package v1alpha1
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/caicloud/nirvana/log"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/server/common"
+	"github.com/caicloud/cyclone/pkg/server/config"
+	"github.com/caicloud/cyclone/pkg/server/handler"
+	"github.com/caicloud/cyclone/pkg/server/types"
+	"github.com/caicloud/cyclone/pkg/util/cerr"
+	httputil "github.com/caicloud/cyclone/pkg/util/http"
+	"github.com/caicloud/cyclone/pkg/workflow/artifact"
+	workflowcommon "github.com/caicloud/cyclone/pkg/workflow/common"
+	"github.com/caicloud/cyclone/pkg/workflow/controller"
+)
+
+// ListWorkflowRunArtifacts lists artifacts produced by stages of the workflowrun, if stage is given,
+// only artifacts of the stage are listed.
+func ListWorkflowRunArtifacts(ctx context.Context, project, workflow, workflowrun, tenant, stage string) (*types.ListResponse, error) {
+	wfr, err := getWorkflowRunOfWorkflow(project, workflow, workflowrun, tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := artifactStore(tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := artifact.WorkflowRunPrefix(workflowrun)
+	if stage != "" {
+		prefix = artifact.StagePrefix(workflowrun, stage)
+	}
+	objects, err := store.List(prefix)
+	if err != nil {
+		log.Errorf("List artifacts of workflowrun %s error: %v", workflowrun, err)
+		return nil, cerr.ErrorListFailed.Error("artifacts", err)
+	}
+	if len(objects) == 0 && wfr.Status.Cleaned {
+		return nil, cerr.ErrorContentNotFound.Error(fmt.Sprintf("artifacts of workflowrun %s", workflowrun))
+	}
+
+	artifacts := []api.Artifact{}
+	for _, obj := range objects {
+		_, stg, name, err := artifact.ParseKey(obj.Key)
+		if err != nil {
+			log.Warningf("Skip artifact %s: %v", obj.Key, err)
+			continue
+		}
+		artifacts = append(artifacts, api.Artifact{
+			Stage:        stg,
+			Name:         name,
+			FileName:     obj.Name,
+			Directory:    obj.Dir,
+			Size:         obj.Size,
+			Checksum:     obj.Checksum,
+			CreationTime: obj.ModTime.Format(time.RFC3339),
+		})
+	}
+
+	return types.NewListResponse(len(artifacts), artifacts), nil
+}
+
+// DownloadWorkflowRunArtifact downloads an artifact of the workflowrun stage. File artifacts are downloaded
+// as they are, while directory artifacts are downloaded as tar.gz archive. If archive is true, file artifacts
+// are also downloaded as tar.gz archive.
+func DownloadWorkflowRunArtifact(ctx context.Context, project, workflow, workflowrun, tenant, stage, name string, archive bool) (io.ReadCloser, map[string]string, error) {
+	if _, err := getWorkflowRunOfWorkflow(project, workflow, workflowrun, tenant); err != nil {
+		return nil, nil, err
+	}
+
+	store, err := artifactStore(tenant)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reader, obj, err := store.Open(artifact.Key(workflowrun, stage, name))
+	if err != nil {
+		if artifact.IsNotFound(err) {
+			return nil, nil, cerr.ErrorContentNotFound.Error(fmt.Sprintf("artifact %s of stage %s", name, stage))
+		}
+		log.Errorf("Open artifact %s of workflowrun %s error: %v", name, workflowrun, err)
+		return nil, nil, cerr.ErrorGetFailed.Error("artifact", err)
+	}
+
+	headers := make(map[string]string)
+	if !obj.Dir && !archive {
+		headers[httputil.HeaderContentType] = "application/octet-stream"
+		headers["Content-Length"] = strconv.FormatInt(obj.Size, 10)
+		headers["Content-Disposition"] = attachment(obj.Name)
+		return reader, headers, nil
+	}
+
+	headers[httputil.HeaderContentType] = "application/gzip"
+	headers["Content-Disposition"] = attachment(obj.Name + ".tar.gz")
+	return gzipArtifact(reader, obj), headers, nil
+}
+
+// getWorkflowRunOfWorkflow gets the workflowrun and checks it's run of the workflow.
+func getWorkflowRunOfWorkflow(project, workflow, workflowrun, tenant string) (*v1alpha1.WorkflowRun, error) {
+	wfr, err := getWorkflowRun(project, workflowrun, tenant)
+	if err != nil {
+		return nil, err
+	}
+	if wfr.Spec.WorkflowRef == nil || wfr.Spec.WorkflowRef.Name != workflow {
+		return nil, errors.NewNotFound(schema.GroupResource{Group: v1alpha1.APIVersion, Resource: "workflowruns"}, workflowrun)
+	}
+	return wfr, nil
+}
+
+// attachment builds Content-Disposition header value of the file name, the name is quoted or
+// encoded when needed.
+func attachment(filename string) string {
+	if v := mime.FormatMediaType("attachment", map[string]string{"filename": filename}); v != "" {
+		return v
+	}
+	return "attachment"
+}
+
+// gzipArtifact compresses artifact data to tar.gz stream, data of directory artifacts is already tar
+// stream, and file artifacts are archived as a tar containing the single file.
+func gzipArtifact(reader io.ReadCloser, obj *artifact.Object) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		defer reader.Close()
+		gw := gzip.NewWriter(pw)
+		err := func() error {
+			if obj.Dir {
+				_, err := io.Copy(gw, reader)
+				return err
+			}
+
+			tw := tar.NewWriter(gw)
+			if err := tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeReg,
+				Name:     obj.Name,
+				Size:     obj.Size,
+				Mode:     0644,
+				ModTime:  obj.ModTime,
+			}); err != nil {
+				return err
+			}
+			if _, err := io.Copy(tw, reader); err != nil {
+				return err
+			}
+			return tw.Close()
+		}()
+		if err == nil {
+			err = gw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	return pr
+}
+
+// artifactStore gets artifact store of the tenant, it's replaced in tests.
+var artifactStore = getArtifactStore
+
+// getArtifactStore gets artifact store of the tenant. Store config is read from workflow controller
+// ConfigMap, so that artifacts are read from where the coordinator stored them. Artifacts in PVC are
+// read through the artifact reader pod in the tenant namespace.
+func getArtifactStore(tenant string) (artifact.Store, error) {
+	cm, err := handler.K8sClient.CoreV1().ConfigMaps(config.SystemNamespace).Get(config.WorkflowControllerConfigMap, meta_v1.GetOptions{})
+	if err != nil {
+		log.Errorf("Get workflow controller ConfigMap %s error: %v", config.WorkflowControllerConfigMap, err)
+		return nil, cerr.ErrorGetFailed.Error("workflow controller config", err)
+	}
+
+	c := &controller.WorkflowControllerConfig{}
+	if err := json.Unmarshal([]byte(cm.Data[controller.ConfigFileKey]), c); err != nil {
+		log.Errorf("Unmarshal workflow controller config error: %v", err)
+		return nil, cerr.ErrorGetFailed.Error("workflow controller config", err)
+	}
+
+	namespace := common.TenantNamespace(tenant)
+	storeConfig := c.Artifact.ForNamespace(namespace)
+	if storeConfig.StoreType() == artifact.PVCStore {
+		if c.PVC == "" {
+			return nil, cerr.ErrorUnsupported.Error("artifact store", "pvc without PVC configured")
+		}
+		token, err := ensureArtifactReaderToken(namespace)
+		if err != nil {
+			log.Errorf("Ensure artifact reader token in namespace %s error: %v", namespace, err)
+			return nil, cerr.ErrorGetFailed.Error("artifact reader", err)
+		}
+		if err := ensureArtifactReader(namespace, c); err != nil {
+			log.Errorf("Ensure artifact reader in namespace %s error: %v", namespace, err)
+			return nil, cerr.ErrorGetFailed.Error("artifact reader", err)
+		}
+		return artifact.NewRemoteStore(artifactReaderGet(namespace, token)), nil
+	}
+
+	return artifact.NewStore(storeConfig, "", "")
+}
+
+// artifactReaderTimeout is the max time to wait artifact reader pod ready.
+const artifactReaderTimeout = time.Minute
+
+// ensureArtifactReaderToken ensures the secret holding artifact reader token exists in the namespace
+// and returns the token. Artifact reader only serves requests carrying the token.
+func ensureArtifactReaderToken(namespace string) (string, error) {
+	secrets := handler.K8sClient.CoreV1().Secrets(namespace)
+	secret, err := secrets.Get(workflowcommon.ArtifactReaderName, meta_v1.GetOptions{})
+	if err == nil {
+		return string(secret.Data[workflowcommon.ArtifactReaderTokenKey]), nil
+	}
+	if !errors.IsNotFound(err) {
+		return "", err
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	secret, err = secrets.Create(&core_v1.Secret{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      workflowcommon.ArtifactReaderName,
+			Namespace: namespace,
+			Labels: map[string]string{
+				workflowcommon.WorkflowLabelName: "true",
+			},
+		},
+		Data: map[string][]byte{
+			workflowcommon.ArtifactReaderTokenKey: []byte(hex.EncodeToString(b)),
+		},
+	})
+	if errors.IsAlreadyExists(err) {
+		// Created by another request concurrently, use the existing one.
+		secret, err = secrets.Get(workflowcommon.ArtifactReaderName, meta_v1.GetOptions{})
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(secret.Data[workflowcommon.ArtifactReaderTokenKey]), nil
+}
+
+// ensureArtifactReader ensures the artifact reader pod is ready in the namespace. The pod mounts
+// the PVC read-only and serves artifacts in it, it's created on first access and kept for later
+// reads. The pod exits when it's idle for a while, then it's deleted by workflow controller as GC
+// pods, and will be recreated on next access.
+func ensureArtifactReader(namespace string, c *controller.WorkflowControllerConfig) error {
+	pods := handler.K8sClient.CoreV1().Pods(namespace)
+	pod, err := pods.Get(workflowcommon.ArtifactReaderName, meta_v1.GetOptions{})
+	if err == nil && (pod.Status.Phase == core_v1.PodFailed || pod.Status.Phase == core_v1.PodSucceeded || pod.DeletionTimestamp != nil) {
+		// Terminated pod can't be restarted, recreate it.
+		if err := pods.Delete(pod.Name, &meta_v1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		err = wait.PollImmediate(time.Second, artifactReaderTimeout, func() (bool, error) {
+			_, err := pods.Get(pod.Name, meta_v1.GetOptions{})
+			return errors.IsNotFound(err), nil
+		})
+		if err != nil {
+			return fmt.Errorf("wait terminated artifact reader deleted error: %v", err)
+		}
+		err = errors.NewNotFound(core_v1.Resource("pods"), pod.Name)
+	}
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		if _, err := pods.Create(artifactReaderPod(namespace, c)); err != nil && !errors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+
+	return wait.PollImmediate(time.Second, artifactReaderTimeout, func() (bool, error) {
+		pod, err := pods.Get(workflowcommon.ArtifactReaderName, meta_v1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.Ready {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+}
+
+// artifactReaderPod builds the artifact reader pod, it runs coordinator image with the PVC mounted
+// read-only, and token is read from the artifact reader secret.
+func artifactReaderPod(namespace string, c *controller.WorkflowControllerConfig) *core_v1.Pod {
+	return &core_v1.Pod{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      workflowcommon.ArtifactReaderName,
+			Namespace: namespace,
+			Labels: map[string]string{
+				workflowcommon.WorkflowLabelName: "true",
+			},
+			Annotations: map[string]string{
+				workflowcommon.GCAnnotationName: "true",
+			},
+		},
+		Spec: core_v1.PodSpec{
+			RestartPolicy: core_v1.RestartPolicyNever,
+			Containers: []core_v1.Container{
+				{
+					Name:    "reader",
+					Image:   c.Images[controller.CoordinatorImage],
+					Command: []string{"/workspace/coordinator", workflowcommon.ArtifactReaderCommand},
+					Env: []core_v1.EnvVar{
+						{
+							Name: workflowcommon.EnvArtifactReaderToken,
+							ValueFrom: &core_v1.EnvVarSource{
+								SecretKeyRef: &core_v1.SecretKeySelector{
+									LocalObjectReference: core_v1.LocalObjectReference{
+										Name: workflowcommon.ArtifactReaderName,
+									},
+									Key: workflowcommon.ArtifactReaderTokenKey,
+								},
+							},
+						},
+					},
+					Ports: []core_v1.ContainerPort{
+						{
+							ContainerPort: workflowcommon.ArtifactReaderPort,
+						},
+					},
+					ReadinessProbe: &core_v1.Probe{
+						Handler: core_v1.Handler{
+							TCPSocket: &core_v1.TCPSocketAction{
+								Port: intstr.FromInt(workflowcommon.ArtifactReaderPort),
+							},
+						},
+					},
+					VolumeMounts: []core_v1.VolumeMount{
+						{
+							Name:      workflowcommon.DefaultPvVolumeName,
+							MountPath: workflowcommon.ArtifactReaderPVCPath,
+							ReadOnly:  true,
+						},
+					},
+					Resources:       c.ResourceRequirements,
+					ImagePullPolicy: controller.ImagePullPolicy(),
+				},
+			},
+			Volumes: []core_v1.Volume{
+				{
+					Name: workflowcommon.DefaultPvVolumeName,
+					VolumeSource: core_v1.VolumeSource{
+						PersistentVolumeClaim: &core_v1.PersistentVolumeClaimVolumeSource{
+							ClaimName: c.PVC,
+							ReadOnly:  true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// artifactReaderGet sends requests to the artifact reader in the namespace through pod proxy of
+// Kubernetes API server, with the token set in header.
+func artifactReaderGet(namespace, token string) artifact.GetFunc {
+	return func(path string, query url.Values) (io.ReadCloser, error) {
+		req := handler.K8sClient.CoreV1().RESTClient().Get().
+			Namespace(namespace).
+			Resource("pods").
+			Name(fmt.Sprintf("%s:%d", workflowcommon.ArtifactReaderName, workflowcommon.ArtifactReaderPort)).
+			SubResource("proxy").
+			Suffix(path).
+			SetHeader(artifact.TokenHeader, token)
+		for k, values := range query {
+			for _, v := range values {
+				req = req.Param(k, v)
+			}
+		}
+		reader, err := req.Stream()
+		if errors.IsNotFound(err) {
+			return nil, &artifact.ErrNotFound{}
+		}
+		return reader, err
+	}
+}
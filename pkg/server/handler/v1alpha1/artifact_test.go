@@ -0,0 +1,166 @@
+package v1alpha1
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/k8s/clientset/fake"
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/server/common"
+	"github.com/caicloud/cyclone/pkg/server/handler"
+	"github.com/caicloud/cyclone/pkg/util/cerr"
+	"github.com/caicloud/cyclone/pkg/workflow/artifact"
+	workflowcommon "github.com/caicloud/cyclone/pkg/workflow/common"
+	"github.com/caicloud/cyclone/pkg/workflow/controller"
+)
+
+// fakeArtifactStore replaces artifact store of tenants with a PVC store in a temp directory, and
+// creates WorkflowRuns with the fake client.
+func fakeArtifactStore(t *testing.T, wfrs ...*v1alpha1.WorkflowRun) (artifact.Store, func()) {
+	dir, _ := ioutil.TempDir("", "artifacts")
+	store := artifact.NewPVCStore(dir, "")
+
+	client := fake.NewSimpleClientset()
+	for _, wfr := range wfrs {
+		_, err := client.CycloneV1alpha1().WorkflowRuns(wfr.Namespace).Create(wfr)
+		assert.Nil(t, err)
+	}
+	originClient, originStore := handler.K8sClient, artifactStore
+	handler.K8sClient = client
+	artifactStore = func(tenant string) (artifact.Store, error) {
+		return store, nil
+	}
+
+	return store, func() {
+		handler.K8sClient, artifactStore = originClient, originStore
+		os.RemoveAll(dir)
+	}
+}
+
+func TestWorkflowRunArtifacts(t *testing.T) {
+	namespace := common.TenantNamespace("t1")
+	labels := map[string]string{common.LabelProject: "p1"}
+	spec := v1alpha1.WorkflowRunSpec{WorkflowRef: &core_v1.ObjectReference{Name: "wf"}}
+	store, cleanup := fakeArtifactStore(t,
+		&v1alpha1.WorkflowRun{ObjectMeta: meta_v1.ObjectMeta{Name: "wfr", Namespace: namespace, Labels: labels}, Spec: spec},
+		&v1alpha1.WorkflowRun{
+			ObjectMeta: meta_v1.ObjectMeta{Name: "cleaned", Namespace: namespace, Labels: labels},
+			Spec:       spec,
+			Status:     v1alpha1.WorkflowRunStatus{Cleaned: true},
+		},
+	)
+	defer cleanup()
+
+	src, _ := ioutil.TempDir("", "src")
+	defer os.RemoveAll(src)
+	os.MkdirAll(filepath.Join(src, "dist"), 0755)
+	ioutil.WriteFile(filepath.Join(src, "app"), []byte("app"), 0644)
+	ioutil.WriteFile(filepath.Join(src, "dist", "a.txt"), []byte("a"), 0644)
+	_, err := store.Put(artifact.Key("wfr", "build", "app"), filepath.Join(src, "app"))
+	assert.Nil(t, err)
+	_, err = store.Put(artifact.Key("wfr", "package", "dist"), filepath.Join(src, "dist"))
+	assert.Nil(t, err)
+
+	// List
+	resp, err := ListWorkflowRunArtifacts(context.TODO(), "p1", "wf", "wfr", "t1", "")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, resp.Metadata.Total)
+	resp, err = ListWorkflowRunArtifacts(context.TODO(), "p1", "wf", "wfr", "t1", "build")
+	assert.Nil(t, err)
+	artifacts := resp.Items.([]api.Artifact)
+	assert.Equal(t, 1, len(artifacts))
+	assert.Equal(t, "app", artifacts[0].Name)
+	assert.False(t, artifacts[0].Directory)
+
+	// Download file as it is
+	reader, headers, err := DownloadWorkflowRunArtifact(context.TODO(), "p1", "wf", "wfr", "t1", "build", "app", false)
+	assert.Nil(t, err)
+	data, _ := ioutil.ReadAll(reader)
+	reader.Close()
+	assert.Equal(t, "app", string(data))
+	assert.Equal(t, "3", headers["Content-Length"])
+	assert.Equal(t, "attachment; filename=app", headers["Content-Disposition"])
+	assert.Equal(t, `attachment; filename="a b;.txt"`, attachment("a b;.txt"))
+
+	// Download file and directory as tar.gz archive
+	for _, c := range []struct {
+		stage, name string
+		files       map[string]string
+	}{
+		{"build", "app", map[string]string{"app": "app"}},
+		{"package", "dist", map[string]string{"dist/": "", "dist/a.txt": "a"}},
+	} {
+		reader, headers, err = DownloadWorkflowRunArtifact(context.TODO(), "p1", "wf", "wfr", "t1", c.stage, c.name, true)
+		assert.Nil(t, err)
+		assert.Equal(t, "application/gzip", headers["Content-Type"])
+		files := make(map[string]string)
+		gr, err := gzip.NewReader(reader)
+		assert.Nil(t, err)
+		tr := tar.NewReader(gr)
+		for {
+			header, err := tr.Next()
+			if err != nil {
+				break
+			}
+			data, _ := ioutil.ReadAll(tr)
+			files[header.Name] = string(data)
+		}
+		reader.Close()
+		assert.Equal(t, c.files, files)
+	}
+
+	// Not found
+	_, _, err = DownloadWorkflowRunArtifact(context.TODO(), "p1", "wf", "wfr", "t1", "build", "missing", false)
+	assert.True(t, cerr.ErrorContentNotFound.Derived(err))
+
+	// Artifacts of workflowruns in other projects are not accessible.
+	_, err = ListWorkflowRunArtifacts(context.TODO(), "p2", "wf", "wfr", "t1", "")
+	assert.True(t, errors.IsNotFound(err))
+	_, _, err = DownloadWorkflowRunArtifact(context.TODO(), "p2", "wf", "wfr", "t1", "build", "app", false)
+	assert.True(t, errors.IsNotFound(err))
+
+	// Artifacts are only accessible through the workflow of the workflowrun.
+	_, err = ListWorkflowRunArtifacts(context.TODO(), "p1", "other", "wfr", "t1", "")
+	assert.True(t, errors.IsNotFound(err))
+	_, _, err = DownloadWorkflowRunArtifact(context.TODO(), "p1", "other", "wfr", "t1", "build", "app", false)
+	assert.True(t, errors.IsNotFound(err))
+
+	// Artifacts removed by GC
+	_, err = ListWorkflowRunArtifacts(context.TODO(), "p1", "wf", "cleaned", "t1", "")
+	assert.True(t, cerr.ErrorContentNotFound.Derived(err))
+	_, _, err = DownloadWorkflowRunArtifact(context.TODO(), "p1", "wf", "cleaned", "t1", "build", "app", false)
+	assert.True(t, cerr.ErrorContentNotFound.Derived(err))
+}
+
+func TestEnsureArtifactReaderToken(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	origin := handler.K8sClient
+	handler.K8sClient = client
+	defer func() {
+		handler.K8sClient = origin
+	}()
+
+	namespace := common.TenantNamespace("t1")
+	token, err := ensureArtifactReaderToken(namespace)
+	assert.Nil(t, err)
+	assert.Equal(t, 64, len(token))
+	again, err := ensureArtifactReaderToken(namespace)
+	assert.Nil(t, err)
+	assert.Equal(t, token, again)
+
+	pod := artifactReaderPod(namespace, &controller.WorkflowControllerConfig{PVC: "pvc"})
+	assert.Equal(t, core_v1.RestartPolicyNever, pod.Spec.RestartPolicy)
+	assert.Equal(t, "true", pod.Annotations[workflowcommon.GCAnnotationName])
+	assert.Equal(t, workflowcommon.ArtifactReaderName, pod.Spec.Containers[0].Env[0].ValueFrom.SecretKeyRef.Name)
+}
@@ -0,0 +1,46 @@
+package v1alpha1
+
+import (
+	"context"
+	"time"
+
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/server/biz/audit"
+	"github.com/caicloud/cyclone/pkg/server/types"
+	"github.com/caicloud/cyclone/pkg/util/cerr"
+	httputil "github.com/caicloud/cyclone/pkg/util/http"
+)
+
+// ListAuditRecords lists audit records filtered by tenant, actor and time range, newest first.
+// Time range is given in RFC3339 format.
+func ListAuditRecords(ctx context.Context, tenant, actor, since, until string, pagination *types.Pagination) (*types.ListResponse, error) {
+	filter := &audit.Filter{Tenant: tenant, Actor: actor}
+	var err error
+	if since != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			return nil, cerr.ErrorValidationFailed.Error(httputil.SinceQueryParameter, err)
+		}
+	}
+	if until != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			return nil, cerr.ErrorValidationFailed.Error(httputil.UntilQueryParameter, err)
+		}
+	}
+
+	records, err := audit.Query(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	size := int64(len(records))
+	if pagination.Start >= size {
+		return types.NewListResponse(int(size), []api.AuditRecord{}), nil
+	}
+
+	end := pagination.Start + pagination.Limit
+	if end > size {
+		end = size
+	}
+
+	return types.NewListResponse(int(size), records[pagination.Start:end]), nil
+}
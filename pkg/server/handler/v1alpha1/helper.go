@@ -21,6 +21,9 @@ const (
 
 	// logsFolderName is the folder name for logs files.
 	logsFolderName = "logs"
+
+	// reportsFolderName is the folder name for test report files.
+	reportsFolderName = "reports"
 )
 
 func getLogFilePath(workflowrun, stage, container, namespace string) (string, error) {
@@ -39,6 +42,65 @@ func getLogFolder(workflowrun, stage, namespace string) (string, error) {
 	return strings.Join([]string{cycloneHome, namespace, workflowrun, stage, logsFolderName}, string(os.PathSeparator)), nil
 }
 
+// getReportFolder gets folder of test reports of the stage, reports of all stages are returned
+// by globbing '*' as the stage.
+func getReportFolder(workflowrun, stage, namespace string) (string, error) {
+	if workflowrun == "" || stage == "" || namespace == "" {
+		return "", fmt.Errorf("workflowrun/stage/namespace can not be empty")
+	}
+	return strings.Join([]string{cycloneHome, namespace, workflowrun, stage, reportsFolderName}, string(os.PathSeparator)), nil
+}
+
+func getReportFilePath(workflowrun, stage, report, namespace string) (string, error) {
+	if report == "" {
+		return "", fmt.Errorf("report can not be empty")
+	}
+
+	rf, err := getReportFolder(workflowrun, stage, namespace)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join([]string{rf, report + ".json"}, string(os.PathSeparator)), nil
+}
+
+// checkProject checks whether the object belongs to the project by its project label. Objects are
+// got by name from the tenant namespace, so without the check, users bound to some projects could
+// access objects of other projects in the tenant. Objects of other projects are reported as not
+// found, as if they don't exist in the project.
+func checkProject(project, resource string, obj meta_v1.Object) error {
+	if obj.GetLabels()[common.LabelProject] != project {
+		return errors.NewNotFound(schema.GroupResource{Group: v1alpha1.APIVersion, Resource: resource}, obj.GetName())
+	}
+	return nil
+}
+
+// getWorkflow gets the workflow in the project.
+func getWorkflow(project, workflow, tenant string) (*v1alpha1.Workflow, error) {
+	wf, err := handler.K8sClient.CycloneV1alpha1().Workflows(common.TenantNamespace(tenant)).Get(workflow, meta_v1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return wf, checkProject(project, "workflows", wf)
+}
+
+// getWorkflowRun gets the workflowrun in the project.
+func getWorkflowRun(project, workflowrun, tenant string) (*v1alpha1.WorkflowRun, error) {
+	wfr, err := handler.K8sClient.CycloneV1alpha1().WorkflowRuns(common.TenantNamespace(tenant)).Get(workflowrun, meta_v1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return wfr, checkProject(project, "workflowruns", wfr)
+}
+
+// getWorkflowTrigger gets the workflowtrigger in the project.
+func getWorkflowTrigger(project, workflowtrigger, tenant string) (*v1alpha1.WorkflowTrigger, error) {
+	wft, err := handler.K8sClient.CycloneV1alpha1().WorkflowTriggers(common.TenantNamespace(tenant)).Get(workflowtrigger, meta_v1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return wft, checkProject(project, "workflowtriggers", wft)
+}
+
 // GetMetadata gets metadata of a type of k8s resources
 type GetMetadata func(string, string) (meta_v1.ObjectMeta, error)
 
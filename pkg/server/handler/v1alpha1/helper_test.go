@@ -0,0 +1,79 @@
+package v1alpha1
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/k8s/clientset/fake"
+	"github.com/caicloud/cyclone/pkg/server/common"
+	"github.com/caicloud/cyclone/pkg/server/handler"
+)
+
+func TestCrossProjectAccess(t *testing.T) {
+	namespace := common.TenantNamespace("t1")
+	meta := func(name string) meta_v1.ObjectMeta {
+		return meta_v1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{common.LabelProject: "p1"},
+		}
+	}
+	client := fake.NewSimpleClientset(
+		&v1alpha1.Workflow{ObjectMeta: meta("wf")},
+		&v1alpha1.WorkflowRun{ObjectMeta: meta("wfr")},
+		&v1alpha1.WorkflowTrigger{ObjectMeta: meta("wft")},
+	)
+	origin := handler.K8sClient
+	handler.K8sClient = client
+	defer func() { handler.K8sClient = origin }()
+
+	// Objects are accessible in their own project.
+	_, err := GetWorkflow(context.TODO(), "p1", "wf", "t1")
+	assert.Nil(t, err)
+	_, err = GetWorkflowRun(context.TODO(), "p1", "wf", "wfr", "t1")
+	assert.Nil(t, err)
+	_, err = GetWorkflowTrigger(context.TODO(), "p1", "wft", "t1")
+	assert.Nil(t, err)
+
+	// Objects in other projects of the tenant are rejected as not found.
+	_, err = GetWorkflow(context.TODO(), "p2", "wf", "t1")
+	assert.True(t, errors.IsNotFound(err))
+	_, err = GetWorkflowRun(context.TODO(), "p2", "wf", "wfr", "t1")
+	assert.True(t, errors.IsNotFound(err))
+	_, err = GetWorkflowTrigger(context.TODO(), "p2", "wft", "t1")
+	assert.True(t, errors.IsNotFound(err))
+	_, err = ListTestReports(context.TODO(), "p2", "wf", "wfr", "t1", "")
+	assert.True(t, errors.IsNotFound(err))
+	_, err = GetTestReport(context.TODO(), "p2", "wf", "wfr", "t1", "build", "junit")
+	assert.True(t, errors.IsNotFound(err))
+	_, err = ListFailedTests(context.TODO(), "p2", "wf", "wfr", "t1", "")
+	assert.True(t, errors.IsNotFound(err))
+
+	// Objects in other projects are not changed.
+	assert.True(t, errors.IsNotFound(DeleteWorkflow(context.TODO(), "p2", "wf", "t1")))
+	assert.True(t, errors.IsNotFound(DeleteWorkflowRun(context.TODO(), "p2", "wf", "wfr", "t1")))
+	assert.True(t, errors.IsNotFound(DeleteWorkflowTrigger(context.TODO(), "p2", "wft", "t1")))
+	_, err = PauseWorkflowRun(context.TODO(), "p2", "wf", "wfr", "t1")
+	assert.True(t, errors.IsNotFound(err))
+	_, err = UpdateWorkflow(context.TODO(), "p2", "wf", "t1", &v1alpha1.Workflow{})
+	assert.True(t, errors.IsNotFound(err))
+	_, err = client.CycloneV1alpha1().Workflows(namespace).Get("wf", meta_v1.GetOptions{})
+	assert.Nil(t, err)
+	_, err = client.CycloneV1alpha1().WorkflowRuns(namespace).Get("wfr", meta_v1.GetOptions{})
+	assert.Nil(t, err)
+
+	// Workflows in other projects can't be run.
+	_, err = CreateWorkflowRun(context.TODO(), "p2", "wf", "t1", &v1alpha1.WorkflowRun{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "wfr2"},
+		Spec: v1alpha1.WorkflowRunSpec{
+			WorkflowRef: &core_v1.ObjectReference{Name: "wf"},
+		},
+	})
+	assert.True(t, errors.IsNotFound(err))
+}
@@ -0,0 +1,108 @@
+package v1alpha1
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+
+	websocketutil "github.com/caicloud/cyclone/pkg/util/websocket"
+)
+
+func TestAppendLogChunk(t *testing.T) {
+	file, err := ioutil.TempFile("", "log")
+	assert.Nil(t, err)
+	defer os.Remove(file.Name())
+	file.Close()
+
+	file, err = os.OpenFile(file.Name(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	assert.Nil(t, err)
+	defer file.Close()
+
+	cases := []struct {
+		offset int64
+		data   string
+		size   int64
+	}{
+		{offset: 0, data: "line 1\n", size: 7},
+		// Chunk sent again after reconnecting.
+		{offset: 0, data: "line 1\n", size: 7},
+		// Chunk partially stored.
+		{offset: 0, data: "line 1\nline 2\n", size: 14},
+		// Chunk beyond the end.
+		{offset: 20, data: "line 4\n", size: 14},
+		{offset: 14, data: "line 3\n", size: 21},
+	}
+	for _, c := range cases {
+		size, err := appendLogChunk(file, c.offset, []byte(c.data))
+		assert.Nil(t, err)
+		assert.Equal(t, c.size, size)
+	}
+
+	content, err := ioutil.ReadFile(file.Name())
+	assert.Nil(t, err)
+	assert.Equal(t, "line 1\nline 2\nline 3\n", string(content))
+}
+
+func TestLogFileLocks(t *testing.T) {
+	lock := acquireLogFileLock("a.log")
+	assert.Equal(t, lock, acquireLogFileLock("a.log"))
+	releaseLogFileLock("a.log")
+	assert.Equal(t, 1, len(logFileLocks))
+
+	// Lock is removed when the last connection closes.
+	releaseLogFileLock("a.log")
+	assert.Equal(t, 0, len(logFileLocks))
+	releaseLogFileLock("a.log")
+	assert.Equal(t, 0, len(logFileLocks))
+}
+
+func TestReceiveLogChunkBeyondEnd(t *testing.T) {
+	file, err := ioutil.TempFile("", "log")
+	assert.Nil(t, err)
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	errs := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := websocketutil.Upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			errs <- err
+			return
+		}
+		defer ws.Close()
+		errs <- receiveLogChunks(file, &logFileLock{}, ws)
+	}))
+	defer server.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	assert.Nil(t, err)
+	defer ws.Close()
+
+	var offset websocketutil.LogOffset
+	assert.Nil(t, ws.ReadJSON(&offset))
+	assert.Equal(t, int64(0), offset.Offset)
+	for _, c := range []struct {
+		offset int64
+		data   string
+	}{
+		{0, "line 1\n"},
+		{20, "line 4\n"},
+	} {
+		message, err := websocketutil.EncodeLogChunk(c.offset, []byte(c.data))
+		assert.Nil(t, err)
+		assert.Nil(t, ws.WriteMessage(websocket.BinaryMessage, message))
+	}
+
+	// The connection is closed with error after the chunk beyond the end.
+	assert.Nil(t, ws.ReadJSON(&offset))
+	assert.Equal(t, int64(7), offset.Offset)
+	err = ws.ReadJSON(&offset)
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseProtocolError), "%v", err)
+	assert.Error(t, <-errs)
+}
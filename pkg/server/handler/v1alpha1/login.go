@@ -0,0 +1,63 @@
+package v1alpha1
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"path"
+
+	"github.com/caicloud/nirvana/errors"
+	"github.com/caicloud/nirvana/log"
+
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/server/biz/auth"
+	contextutil "github.com/caicloud/cyclone/pkg/util/context"
+)
+
+// loginStateCookie is the cookie to hold state of login with identity providers, state in
+// callback must match it, to protect from CSRF.
+const loginStateCookie = "cyclone-login-state"
+
+// Login redirects users to login with the identity provider.
+func Login(ctx context.Context, provider string) error {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	state := base64.RawURLEncoding.EncodeToString(b)
+
+	u, err := auth.LoginURL(provider, state)
+	if err != nil {
+		log.Errorf("Get login URL of %s error: %v", provider, err)
+		return err
+	}
+
+	request := contextutil.GetHTTPRequest(ctx)
+	writer := contextutil.GetHTTPResponseWriter(ctx)
+	http.SetCookie(writer, &http.Cookie{
+		Name:     loginStateCookie,
+		Value:    state,
+		Path:     path.Dir(request.URL.Path),
+		MaxAge:   600,
+		HttpOnly: true,
+		Secure:   request.TLS != nil,
+	})
+	http.Redirect(writer, request, u, http.StatusFound)
+	return nil
+}
+
+// LoginCallback handles callback from the identity provider after users login, an API token is
+// returned for the user.
+func LoginCallback(ctx context.Context, provider, code, state string) (*api.APIToken, error) {
+	cookie, err := contextutil.GetHTTPRequest(ctx).Cookie(loginStateCookie)
+	if err != nil || state == "" || cookie.Value != state {
+		return nil, errors.BadRequest.Error("invalid login state")
+	}
+
+	token, err := auth.LoginCallback(provider, code, state)
+	if err != nil {
+		return nil, errors.Unauthorized.Error("login with ${provider} failed: ${error}", provider, err.Error())
+	}
+	return token, nil
+}
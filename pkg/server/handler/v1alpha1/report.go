@@ -0,0 +1,267 @@
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/caicloud/nirvana/log"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/server/common"
+	"github.com/caicloud/cyclone/pkg/server/handler"
+	"github.com/caicloud/cyclone/pkg/server/types"
+	"github.com/caicloud/cyclone/pkg/util/cerr"
+	fileutil "github.com/caicloud/cyclone/pkg/util/file"
+)
+
+// CreateTestReport stores test report of a stage in the workflowrun, it's sent by coordinator after
+// the workload finished. Existing report with the same name is replaced, so it's safe to retry.
+func CreateTestReport(ctx context.Context, project, workflow, workflowrun, tenant string, report *api.TestReport) (*api.TestReport, error) {
+	if err := validateReportName(report.Stage); err != nil {
+		return nil, cerr.ErrorValidationFailed.Error("stage", err)
+	}
+	if err := validateReportName(report.Name); err != nil {
+		return nil, cerr.ErrorValidationFailed.Error("name", err)
+	}
+	if _, err := getWorkflowRun(project, workflowrun, tenant); err != nil {
+		return nil, err
+	}
+
+	namespace := common.TenantNamespace(tenant)
+	folder, err := getReportFolder(workflowrun, report.Stage, namespace)
+	if err != nil {
+		return nil, cerr.ErrorValidationFailed.Error("report", err)
+	}
+	fileutil.CreateDirectory(folder)
+
+	filePath, err := getReportFilePath(workflowrun, report.Stage, report.Name, namespace)
+	if err != nil {
+		return nil, cerr.ErrorValidationFailed.Error("report", err)
+	}
+
+	report.CreationTime = time.Now().Format(time.RFC3339)
+	data, err := json.Marshal(report)
+	if err != nil {
+		return nil, cerr.ErrorCreateFailed.Error("report", err)
+	}
+
+	// Write to a temporary file and rename it, so that readers never see a partial report.
+	tmp := filePath + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		log.Errorf("Write report %s of workflowrun %s error: %v", report.Name, workflowrun, err)
+		return nil, cerr.ErrorCreateFailed.Error("report", err)
+	}
+	if err := os.Rename(tmp, filePath); err != nil {
+		log.Errorf("Write report %s of workflowrun %s error: %v", report.Name, workflowrun, err)
+		return nil, cerr.ErrorCreateFailed.Error("report", err)
+	}
+
+	return report, nil
+}
+
+// ListTestReports lists test reports of the workflowrun without test cases, if stage is given, only
+// reports of the stage are listed.
+func ListTestReports(ctx context.Context, project, workflow, workflowrun, tenant, stage string) (*types.ListResponse, error) {
+	if stage != "" {
+		if err := validateReportName(stage); err != nil {
+			return nil, cerr.ErrorValidationFailed.Error("stage", err)
+		}
+	}
+	if _, err := getWorkflowRun(project, workflowrun, tenant); err != nil {
+		return nil, err
+	}
+
+	reports, err := loadTestReports(workflowrun, stage, common.TenantNamespace(tenant))
+	if err != nil {
+		return nil, cerr.ErrorListFailed.Error("reports", err)
+	}
+
+	for i := range reports {
+		reports[i].Cases = nil
+	}
+	return types.NewListResponse(len(reports), reports), nil
+}
+
+// GetTestReport gets a test report of the workflowrun stage with all test cases.
+func GetTestReport(ctx context.Context, project, workflow, workflowrun, tenant, stage, report string) (*api.TestReport, error) {
+	if err := validateReportName(stage); err != nil {
+		return nil, cerr.ErrorValidationFailed.Error("stage", err)
+	}
+	if err := validateReportName(report); err != nil {
+		return nil, cerr.ErrorValidationFailed.Error("report", err)
+	}
+	if _, err := getWorkflowRun(project, workflowrun, tenant); err != nil {
+		return nil, err
+	}
+
+	filePath, err := getReportFilePath(workflowrun, stage, report, common.TenantNamespace(tenant))
+	if err != nil {
+		return nil, cerr.ErrorValidationFailed.Error("report", err)
+	}
+	if !fileutil.Exists(filePath) {
+		return nil, cerr.ErrorContentNotFound.Error(fmt.Sprintf("report %s of stage %s", report, stage))
+	}
+
+	r, err := loadTestReport(filePath)
+	if err != nil {
+		return nil, cerr.ErrorGetFailed.Error("report", err)
+	}
+	return r, nil
+}
+
+// ListFailedTests lists failed test cases in all test reports of the workflowrun.
+func ListFailedTests(ctx context.Context, project, workflow, workflowrun, tenant, stage string) (*types.ListResponse, error) {
+	if stage != "" {
+		if err := validateReportName(stage); err != nil {
+			return nil, cerr.ErrorValidationFailed.Error("stage", err)
+		}
+	}
+	if _, err := getWorkflowRun(project, workflowrun, tenant); err != nil {
+		return nil, err
+	}
+
+	reports, err := loadTestReports(workflowrun, stage, common.TenantNamespace(tenant))
+	if err != nil {
+		return nil, cerr.ErrorListFailed.Error("reports", err)
+	}
+
+	failed := []api.FailedTest{}
+	for _, r := range reports {
+		for _, c := range r.Cases {
+			if c.Status == api.TestFailed || c.Status == api.TestErrored {
+				failed = append(failed, api.FailedTest{
+					Stage:    r.Stage,
+					Report:   r.Name,
+					TestCase: c,
+				})
+			}
+		}
+	}
+	return types.NewListResponse(len(failed), failed), nil
+}
+
+// ListTestTrends lists test results of workflowruns of the workflow, latest first, so that trends of
+// test cases and coverage can be seen across runs.
+func ListTestTrends(ctx context.Context, project, workflow, tenant string, pagination *types.Pagination) (*types.ListResponse, error) {
+	namespace := common.TenantNamespace(tenant)
+	workflowruns, err := handler.K8sClient.CycloneV1alpha1().WorkflowRuns(namespace).List(meta_v1.ListOptions{
+		LabelSelector: common.ProjectSelector(project),
+	})
+	if err != nil {
+		log.Errorf("Get workflowruns from k8s with tenant %s, project %s error: %v", tenant, project, err)
+		return nil, cerr.ErrorListFailed.Error("workflowruns", err)
+	}
+
+	var items []v1alpha1.WorkflowRun
+	for _, wfr := range workflowruns.Items {
+		if wfr.Spec.WorkflowRef != nil && wfr.Spec.WorkflowRef.Name == workflow {
+			items = append(items, wfr)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[j].CreationTimestamp.Before(&items[i].CreationTimestamp)
+	})
+
+	size := int64(len(items))
+	if pagination.Start >= size {
+		return types.NewListResponse(int(size), []api.TestTrend{}), nil
+	}
+	end := pagination.Start + pagination.Limit
+	if end > size {
+		end = size
+	}
+
+	trends := []api.TestTrend{}
+	for _, wfr := range items[pagination.Start:end] {
+		reports, err := loadTestReports(wfr.Name, "", namespace)
+		if err != nil {
+			log.Warningf("Load reports of workflowrun %s error: %v", wfr.Name, err)
+			continue
+		}
+		trends = append(trends, testTrend(wfr.Name, wfr.CreationTimestamp.Format(time.RFC3339), reports))
+	}
+
+	return types.NewListResponse(int(size), trends), nil
+}
+
+// testTrend merges test results of all reports of a workflowrun.
+func testTrend(workflowrun, creationTime string, reports []api.TestReport) api.TestTrend {
+	trend := api.TestTrend{
+		WorkflowRun:  workflowrun,
+		CreationTime: creationTime,
+	}
+	for _, r := range reports {
+		trend.Summary.Merge(r.Summary)
+		if r.Coverage == nil {
+			continue
+		}
+		if trend.Coverage == nil {
+			trend.Coverage = &api.Coverage{}
+		}
+		trend.Coverage.Covered += r.Coverage.Covered
+		trend.Coverage.Total += r.Coverage.Total
+	}
+	if trend.Coverage != nil && trend.Coverage.Total > 0 {
+		trend.Coverage.Rate = float64(trend.Coverage.Covered) / float64(trend.Coverage.Total)
+	}
+
+	return trend
+}
+
+// loadTestReports loads test reports of the workflowrun, sorted by stage and name. If stage is empty,
+// reports of all stages are loaded.
+func loadTestReports(workflowrun, stage, namespace string) ([]api.TestReport, error) {
+	if stage == "" {
+		stage = "*"
+	}
+	folder, err := getReportFolder(workflowrun, stage, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := filepath.Glob(filepath.Join(folder, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	reports := []api.TestReport{}
+	for _, f := range files {
+		r, err := loadTestReport(f)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, *r)
+	}
+	return reports, nil
+}
+
+func loadTestReport(filePath string) (*api.TestReport, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &api.TestReport{}
+	if err := json.Unmarshal(data, report); err != nil {
+		return nil, fmt.Errorf("decode report %s error: %v", filePath, err)
+	}
+	return report, nil
+}
+
+// validateReportName checks the name can be used as a path segment of report files.
+func validateReportName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\*?[`) {
+		return fmt.Errorf("invalid name '%s'", name)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+)
+
+func TestTestTrend(t *testing.T) {
+	reports := []api.TestReport{
+		{
+			Name:    "unit",
+			Summary: api.TestSummary{Total: 3, Passed: 2, Failed: 1, Duration: 1.5},
+		},
+		{
+			Name:     "unit-coverage",
+			Coverage: &api.Coverage{Covered: 30, Total: 40, Rate: 0.75},
+		},
+		{
+			Name:     "e2e-coverage",
+			Coverage: &api.Coverage{Covered: 10, Total: 60, Rate: 1.0 / 6},
+		},
+	}
+
+	trend := testTrend("wfr", "2018-12-01T00:00:00Z", reports)
+	assert.Equal(t, "wfr", trend.WorkflowRun)
+	assert.Equal(t, api.TestSummary{Total: 3, Passed: 2, Failed: 1, Duration: 1.5}, trend.Summary)
+	assert.Equal(t, &api.Coverage{Covered: 40, Total: 100, Rate: 0.4}, trend.Coverage)
+
+	assert.Nil(t, testTrend("wfr", "", reports[:1]).Coverage)
+}
+
+func TestValidateReportName(t *testing.T) {
+	for _, name := range []string{"unit", "unit-test.v1"} {
+		assert.Nil(t, validateReportName(name))
+	}
+	for _, name := range []string{"", ".", "..", "../a", "a/b", "*"} {
+		assert.Error(t, validateReportName(name), name)
+	}
+}
@@ -2,19 +2,30 @@ package v1alpha1
 
 import (
 	"context"
+	"fmt"
+	"sort"
 
 	"github.com/caicloud/nirvana/log"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/util/retry"
 
 	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
 	"github.com/caicloud/cyclone/pkg/server/common"
 	"github.com/caicloud/cyclone/pkg/server/handler"
 	"github.com/caicloud/cyclone/pkg/server/types"
+	"github.com/caicloud/cyclone/pkg/util/cerr"
+	workflowcommon "github.com/caicloud/cyclone/pkg/workflow/common"
 )
 
 // CreateStage ...
 func CreateStage(ctx context.Context, project, tenant string, stg *v1alpha1.Stage) (*v1alpha1.Stage, error) {
+	if err := validateStage(stg); err != nil {
+		return nil, err
+	}
+
 	err := ModifyResource(project, tenant, stg)
 	if err != nil {
 		return nil, err
@@ -54,6 +65,10 @@ func GetStage(ctx context.Context, project, stage, tenant string) (*v1alpha1.Sta
 
 // UpdateStage ...
 func UpdateStage(ctx context.Context, project, stage, tenant string, stg *v1alpha1.Stage) (*v1alpha1.Stage, error) {
+	if err := validateStage(stg); err != nil {
+		return nil, err
+	}
+
 	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		origin, err := handler.K8sClient.CycloneV1alpha1().Stages(common.TenantNamespace(tenant)).Get(stage, metav1.GetOptions{})
 		if err != nil {
@@ -73,7 +88,119 @@ func UpdateStage(ctx context.Context, project, stage, tenant string, stg *v1alph
 	return stg, nil
 }
 
+// validateStage validates workload sidecars of the stage. Command of the workload container is
+// required if there are workload sidecars, since it's wrapped to wait sidecars ready, while
+// entrypoint of the image is unknown.
+func validateStage(stg *v1alpha1.Stage) error {
+	if stg.Spec.Pod == nil {
+		return nil
+	}
+
+	var hasSidecars bool
+	for _, c := range stg.Spec.Pod.Spec.Containers {
+		if workflowcommon.OnlyWorkloadSidecar(c.Name) {
+			hasSidecars = true
+			break
+		}
+	}
+	if !hasSidecars {
+		return nil
+	}
+
+	for _, c := range stg.Spec.Pod.Spec.Containers {
+		if workflowcommon.OnlyWorkload(c.Name) && len(c.Command) == 0 {
+			return cerr.ErrorValidationFailed.Error("spec.pod.spec.containers", fmt.Errorf("command of workload container '%s' is required with workload sidecars", c.Name))
+		}
+	}
+	return nil
+}
+
 // DeleteStage ...
 func DeleteStage(ctx context.Context, project, stage, tenant string) error {
 	return handler.K8sClient.CycloneV1alpha1().Stages(common.TenantNamespace(tenant)).Delete(stage, nil)
 }
+
+const (
+	// defaultSuggestionRuns is the default number of recent runs to suggest resources from.
+	defaultSuggestionRuns = 10
+	// limitHeadroomPercent is the headroom added to peak usage for suggested limits, in percent.
+	limitHeadroomPercent = 25
+)
+
+// GetStageResourceSuggestion suggests requests and limits of containers in the stage from resource
+// usage recorded in recent runs of the stage.
+func GetStageResourceSuggestion(ctx context.Context, project, stage, tenant string, runs int) (*api.ResourceSuggestion, error) {
+	if runs <= 0 {
+		runs = defaultSuggestionRuns
+	}
+
+	workflowruns, err := handler.K8sClient.CycloneV1alpha1().WorkflowRuns(common.TenantNamespace(tenant)).List(metav1.ListOptions{
+		LabelSelector: common.ProjectSelector(project),
+	})
+	if err != nil {
+		log.Errorf("Get workflowruns from k8s with tenant %s, project %s error: %v", tenant, project, err)
+		return nil, err
+	}
+
+	items := workflowruns.Items
+	sort.Slice(items, func(i, j int) bool {
+		return items[j].CreationTimestamp.Before(&items[i].CreationTimestamp)
+	})
+
+	var usages []map[string]v1alpha1.ContainerUsage
+	for _, wfr := range items {
+		if len(usages) >= runs {
+			break
+		}
+		if status, ok := wfr.Status.Stages[stage]; ok && status != nil && len(status.Usage) > 0 {
+			usages = append(usages, status.Usage)
+		}
+	}
+
+	return suggestResources(stage, usages), nil
+}
+
+// suggestResources suggests resources of containers from their usage in runs. Requests are the max
+// average usage among runs, and limits are the max peak usage with headroom added.
+func suggestResources(stage string, usages []map[string]v1alpha1.ContainerUsage) *api.ResourceSuggestion {
+	suggestion := &api.ResourceSuggestion{
+		Stage:      stage,
+		Runs:       len(usages),
+		Containers: make(map[string]corev1.ResourceRequirements),
+	}
+
+	for _, usage := range usages {
+		for container, u := range usage {
+			requirements, ok := suggestion.Containers[container]
+			if !ok {
+				requirements = corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{},
+					Limits:   corev1.ResourceList{},
+				}
+			}
+
+			for name, q := range u.Average {
+				if current, ok := requirements.Requests[name]; !ok || q.Cmp(current) > 0 {
+					requirements.Requests[name] = q.DeepCopy()
+				}
+			}
+			for name, q := range u.Peak {
+				limit := withHeadroom(name, q)
+				if current, ok := requirements.Limits[name]; !ok || limit.Cmp(current) > 0 {
+					requirements.Limits[name] = limit
+				}
+			}
+			suggestion.Containers[container] = requirements
+		}
+	}
+
+	return suggestion
+}
+
+// withHeadroom adds limitHeadroomPercent headroom to the quantity.
+func withHeadroom(name corev1.ResourceName, q resource.Quantity) resource.Quantity {
+	if name == corev1.ResourceCPU {
+		return *resource.NewMilliQuantity(q.MilliValue()*(100+limitHeadroomPercent)/100, resource.DecimalSI)
+	}
+	return *resource.NewQuantity(q.Value()*(100+limitHeadroomPercent)/100, resource.BinarySI)
+}
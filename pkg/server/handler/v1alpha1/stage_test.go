@@ -0,0 +1,80 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/util/cerr"
+	workflowcommon "github.com/caicloud/cyclone/pkg/workflow/common"
+)
+
+func TestSuggestResources(t *testing.T) {
+	usages := []map[string]v1alpha1.ContainerUsage{
+		{
+			"main": {
+				Peak: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("800m"),
+					corev1.ResourceMemory: resource.MustParse("400Mi"),
+				},
+				Average: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("300m"),
+					corev1.ResourceMemory: resource.MustParse("200Mi"),
+				},
+				Samples: 6,
+			},
+		},
+		{
+			"main": {
+				Peak: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("1"),
+					corev1.ResourceMemory: resource.MustParse("256Mi"),
+				},
+				Average: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("500m"),
+					corev1.ResourceMemory: resource.MustParse("128Mi"),
+				},
+				Samples: 3,
+			},
+		},
+	}
+
+	suggestion := suggestResources("build", usages)
+	assert.Equal(t, "build", suggestion.Stage)
+	assert.Equal(t, 2, suggestion.Runs)
+
+	main := suggestion.Containers["main"]
+	assert.Equal(t, 0, main.Requests.Cpu().Cmp(resource.MustParse("500m")))
+	assert.Equal(t, 0, main.Requests.Memory().Cmp(resource.MustParse("200Mi")))
+	assert.Equal(t, 0, main.Limits.Cpu().Cmp(resource.MustParse("1250m")))
+	assert.Equal(t, 0, main.Limits.Memory().Cmp(resource.MustParse("500Mi")))
+
+	empty := suggestResources("build", nil)
+	assert.Equal(t, 0, empty.Runs)
+	assert.Equal(t, 0, len(empty.Containers))
+}
+
+func TestValidateStage(t *testing.T) {
+	sidecar := corev1.Container{Name: workflowcommon.WorkloadSidecarPrefix + "db", Image: "mysql:5.7"}
+	cases := []struct {
+		containers []corev1.Container
+		valid      bool
+	}{
+		{[]corev1.Container{{Name: "main", Image: "golang"}}, true},
+		{[]corev1.Container{sidecar, {Name: "main", Image: "golang", Command: []string{"go"}}}, true},
+		{[]corev1.Container{sidecar, {Name: "main", Image: "golang"}}, false},
+	}
+	for i, c := range cases {
+		stg := &v1alpha1.Stage{Spec: v1alpha1.StageSpec{Pod: &v1alpha1.PodWorkload{Spec: corev1.PodSpec{Containers: c.containers}}}}
+		err := validateStage(stg)
+		if c.valid {
+			assert.Nil(t, err, "case %d", i)
+		} else {
+			assert.True(t, cerr.ErrorValidationFailed.Derived(err), "case %d", i)
+		}
+	}
+	assert.Nil(t, validateStage(&v1alpha1.Stage{}))
+}
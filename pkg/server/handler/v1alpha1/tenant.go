@@ -12,13 +12,19 @@ import (
 	"k8s.io/client-go/util/retry"
 
 	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/server/biz/auth"
 	"github.com/caicloud/cyclone/pkg/server/common"
 	"github.com/caicloud/cyclone/pkg/server/handler"
 	"github.com/caicloud/cyclone/pkg/server/types"
+	"github.com/caicloud/cyclone/pkg/util/cerr"
 )
 
 // CreateTenant creates a cyclone tenant
 func CreateTenant(ctx context.Context, tenant *api.Tenant) (*api.Tenant, error) {
+	if err := auth.ValidateGroupRoles(tenant.Spec.GroupRoles); err != nil {
+		return nil, cerr.ErrorValidationFailed.Error("groupRoles", err)
+	}
+
 	return tenant, createTenant(tenant)
 }
 
@@ -32,6 +38,15 @@ func ListTenants(ctx context.Context, pagination *types.Pagination) (*types.List
 		return nil, err
 	}
 
+	// Only tenants the caller belongs to are listed, unless the caller is system admin.
+	var visible map[string]bool
+	if id := auth.IdentityFrom(ctx); id != nil && !auth.IsSystemAdmin(id) {
+		visible = make(map[string]bool)
+		for _, t := range auth.Tenants(id) {
+			visible[t] = true
+		}
+	}
+
 	tenants := []api.Tenant{}
 	for _, namespace := range namespaces.Items {
 		t, err := NamespaceToTenant(&namespace)
@@ -39,6 +54,9 @@ func ListTenants(ctx context.Context, pagination *types.Pagination) (*types.List
 			log.Errorf("Unmarshal tenant annotation error %v", err)
 			continue
 		}
+		if visible != nil && !visible[t.Metadata.Name] {
+			continue
+		}
 		tenants = append(tenants, *t)
 	}
 
@@ -87,6 +105,10 @@ func NamespaceToTenant(namespace *core_v1.Namespace) (*api.Tenant, error) {
 
 // UpdateTenant updates information for a specific tenant
 func UpdateTenant(ctx context.Context, name string, newTenant *api.Tenant) (*api.Tenant, error) {
+	if err := auth.ValidateGroupRoles(newTenant.Spec.GroupRoles); err != nil {
+		return nil, cerr.ErrorValidationFailed.Error("groupRoles", err)
+	}
+
 	// get old tenant
 	tenant, err := getTenant(name)
 	if err != nil {
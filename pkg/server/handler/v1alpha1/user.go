@@ -0,0 +1,82 @@
+package v1alpha1
+
+import (
+	"context"
+
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/server/biz/auth"
+	"github.com/caicloud/cyclone/pkg/server/types"
+)
+
+// ListUsers lists user accounts.
+func ListUsers(ctx context.Context, pagination *types.Pagination) (*types.ListResponse, error) {
+	users, err := auth.ListUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	size := int64(len(users))
+	if pagination.Start >= size {
+		return types.NewListResponse(int(size), []api.User{}), nil
+	}
+
+	end := pagination.Start + pagination.Limit
+	if end > size {
+		end = size
+	}
+
+	return types.NewListResponse(int(size), users[pagination.Start:end]), nil
+}
+
+// CreateUser creates a user account.
+func CreateUser(ctx context.Context, user *api.User) (*api.User, error) {
+	return auth.CreateUser(user)
+}
+
+// GetUser gets a user account.
+func GetUser(ctx context.Context, name string) (*api.User, error) {
+	return auth.GetUser(name)
+}
+
+// UpdateUser updates a user account. Users can update themselves except their roles, which can
+// only be changed by system admin.
+func UpdateUser(ctx context.Context, name string, user *api.User) (*api.User, error) {
+	if id := auth.IdentityFrom(ctx); id != nil && !auth.IsSystemAdmin(id) {
+		user.Spec.Roles = id.Roles
+	}
+
+	return auth.UpdateUser(name, user)
+}
+
+// DeleteUser deletes a user account and its API tokens.
+func DeleteUser(ctx context.Context, name string) error {
+	return auth.DeleteUser(name)
+}
+
+// ListAPITokens lists API tokens of the user.
+func ListAPITokens(ctx context.Context, user string) ([]api.APIToken, error) {
+	return auth.ListTokens(user)
+}
+
+// CreateAPIToken creates an API token for the user, value of the token is only returned once.
+func CreateAPIToken(ctx context.Context, user string, token *api.APIToken) (*api.APIToken, error) {
+	if _, err := auth.GetUser(user); err != nil {
+		return nil, err
+	}
+
+	return auth.CreateToken(user, token)
+}
+
+// DeleteAPIToken deletes an API token of the user.
+func DeleteAPIToken(ctx context.Context, user, id string) error {
+	return auth.DeleteToken(user, id)
+}
+
+// GetIdentity gets identity of the API caller, it's empty if authentication is disabled.
+func GetIdentity(ctx context.Context) (*api.Identity, error) {
+	if id := auth.IdentityFrom(ctx); id != nil {
+		return id, nil
+	}
+
+	return &api.Identity{}, nil
+}
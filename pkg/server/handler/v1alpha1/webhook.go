@@ -0,0 +1,39 @@
+package v1alpha1
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+
+	"github.com/caicloud/nirvana/errors"
+	"github.com/caicloud/nirvana/log"
+
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/server/biz/webhook"
+	contextutil "github.com/caicloud/cyclone/pkg/util/context"
+)
+
+// maxWebhookSize limits size of webhook payloads, GitHub caps payloads at 25MB.
+const maxWebhookSize = 25 << 20
+
+// ReceiveWebhook receives webhooks from GitHub, GitLab or other systems, and triggers workflows by
+// Webhook type WorkflowTriggers in the tenant.
+func ReceiveWebhook(ctx context.Context, tenant string) (*api.WebhookResponse, error) {
+	request := contextutil.GetHTTPRequest(ctx)
+	body, err := ioutil.ReadAll(io.LimitReader(request.Body, maxWebhookSize))
+	if err != nil {
+		return nil, errors.BadRequest.Error("read webhook error: ${error}", err.Error())
+	}
+
+	payload := webhook.NewPayload(request.Header, body)
+	runs, err := webhook.Trigger(tenant, payload)
+	if err == webhook.ErrUnverified {
+		log.Warningf("Unverified %s webhook received for tenant %s", payload.Source, tenant)
+		return nil, errors.Unauthorized.Error("${error}", err.Error())
+	}
+	if err != nil {
+		return nil, errors.BadRequest.Error("handle webhook error: ${error}", err.Error())
+	}
+
+	return &api.WebhookResponse{WorkflowRuns: runs}, nil
+}
@@ -2,6 +2,7 @@ package v1alpha1
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/caicloud/nirvana/log"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -11,10 +12,16 @@ import (
 	"github.com/caicloud/cyclone/pkg/server/common"
 	"github.com/caicloud/cyclone/pkg/server/handler"
 	"github.com/caicloud/cyclone/pkg/server/types"
+	"github.com/caicloud/cyclone/pkg/util/cerr"
+	"github.com/caicloud/cyclone/pkg/workflow/notification"
 )
 
 // CreateWorkflow ...
 func CreateWorkflow(ctx context.Context, project, tenant string, wf *v1alpha1.Workflow) (*v1alpha1.Workflow, error) {
+	if err := validateWorkflow(wf); err != nil {
+		return nil, err
+	}
+
 	err := ModifyResource(project, tenant, wf)
 	if err != nil {
 		return nil, err
@@ -49,13 +56,17 @@ func ListWorkflows(ctx context.Context, project, tenant string, pagination *type
 
 // GetWorkflow ...
 func GetWorkflow(ctx context.Context, project, workflow, tenant string) (*v1alpha1.Workflow, error) {
-	return handler.K8sClient.CycloneV1alpha1().Workflows(common.TenantNamespace(tenant)).Get(workflow, metav1.GetOptions{})
+	return getWorkflow(project, workflow, tenant)
 }
 
 // UpdateWorkflow ...
 func UpdateWorkflow(ctx context.Context, project, workflow, tenant string, wf *v1alpha1.Workflow) (*v1alpha1.Workflow, error) {
+	if err := validateWorkflow(wf); err != nil {
+		return nil, err
+	}
+
 	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-		origin, err := handler.K8sClient.CycloneV1alpha1().Workflows(common.TenantNamespace(tenant)).Get(workflow, metav1.GetOptions{})
+		origin, err := getWorkflow(project, workflow, tenant)
 		if err != nil {
 			return err
 		}
@@ -75,5 +86,26 @@ func UpdateWorkflow(ctx context.Context, project, workflow, tenant string, wf *v
 
 // DeleteWorkflow ...
 func DeleteWorkflow(ctx context.Context, project, workflow, tenant string) error {
+	if _, err := getWorkflow(project, workflow, tenant); err != nil {
+		return err
+	}
 	return handler.K8sClient.CycloneV1alpha1().Workflows(common.TenantNamespace(tenant)).Delete(workflow, nil)
 }
+
+// validateWorkflow validates notification rules of the workflow.
+func validateWorkflow(wf *v1alpha1.Workflow) error {
+	for _, rule := range wf.Spec.Notifications {
+		if rule.Integration == "" {
+			return cerr.ErrorValidationFailed.Error("spec.notifications", fmt.Errorf("integration of rule '%s' is required", rule.Name))
+		}
+		for _, s := range rule.Statuses {
+			if s != v1alpha1.StatusCompleted && s != v1alpha1.StatusError && s != v1alpha1.StatusCancelled {
+				return cerr.ErrorValidationFailed.Error("spec.notifications", fmt.Errorf("'%s' of rule '%s' is not a terminal status", s, rule.Name))
+			}
+		}
+		if _, err := notification.ParseTemplate(rule.Template); err != nil {
+			return cerr.ErrorValidationFailed.Error("spec.notifications", fmt.Errorf("invalid template of rule '%s': %v", rule.Name, err))
+		}
+	}
+	return nil
+}
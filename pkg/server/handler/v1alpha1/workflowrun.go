@@ -7,6 +7,7 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/caicloud/nirvana/log"
@@ -28,6 +29,12 @@ import (
 
 // CreateWorkflowRun ...
 func CreateWorkflowRun(ctx context.Context, project, workflow, tenant string, wfr *v1alpha1.WorkflowRun) (*v1alpha1.WorkflowRun, error) {
+	if wfr.Spec.WorkflowRef != nil {
+		if _, err := getWorkflow(project, wfr.Spec.WorkflowRef.Name, tenant); err != nil {
+			return nil, err
+		}
+	}
+
 	err := ModifyResource(project, tenant, wfr)
 	if err != nil {
 		return nil, err
@@ -62,13 +69,13 @@ func ListWorkflowRuns(ctx context.Context, project, workflow, tenant string, pag
 
 // GetWorkflowRun ...
 func GetWorkflowRun(ctx context.Context, project, workflow, workflowrun, tenant string) (*v1alpha1.WorkflowRun, error) {
-	return handler.K8sClient.CycloneV1alpha1().WorkflowRuns(common.TenantNamespace(tenant)).Get(workflowrun, metav1.GetOptions{})
+	return getWorkflowRun(project, workflowrun, tenant)
 }
 
 // UpdateWorkflowRun ...
 func UpdateWorkflowRun(ctx context.Context, project, workflow, workflowrun, tenant string, wfr *v1alpha1.WorkflowRun) (*v1alpha1.WorkflowRun, error) {
 	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-		origin, err := handler.K8sClient.CycloneV1alpha1().WorkflowRuns(common.TenantNamespace(tenant)).Get(workflowrun, metav1.GetOptions{})
+		origin, err := getWorkflowRun(project, workflowrun, tenant)
 		if err != nil {
 			return err
 		}
@@ -88,11 +95,18 @@ func UpdateWorkflowRun(ctx context.Context, project, workflow, workflowrun, tena
 
 // DeleteWorkflowRun ...
 func DeleteWorkflowRun(ctx context.Context, project, workflow, workflowrun, tenant string) error {
+	if _, err := getWorkflowRun(project, workflowrun, tenant); err != nil {
+		return err
+	}
 	return handler.K8sClient.CycloneV1alpha1().WorkflowRuns(common.TenantNamespace(tenant)).Delete(workflowrun, nil)
 }
 
 // PauseWorkflowRun updates the workflowrun overall status to Waiting.
 func PauseWorkflowRun(ctx context.Context, project, workflow, workflowrun, tenant string) (*v1alpha1.WorkflowRun, error) {
+	if _, err := getWorkflowRun(project, workflowrun, tenant); err != nil {
+		return nil, err
+	}
+
 	data, err := handler.BuildWfrStatusPatch(v1alpha1.StatusWaiting)
 	if err != nil {
 		log.Errorf("pause workflowrun %s error %s", workflowrun, err)
@@ -104,6 +118,10 @@ func PauseWorkflowRun(ctx context.Context, project, workflow, workflowrun, tenan
 
 // ContinueWorkflowRun updates the workflowrun overall status to Running.
 func ContinueWorkflowRun(ctx context.Context, project, workflow, workflowrun, tenant string) (*v1alpha1.WorkflowRun, error) {
+	if _, err := getWorkflowRun(project, workflowrun, tenant); err != nil {
+		return nil, err
+	}
+
 	data, err := handler.BuildWfrStatusPatch(v1alpha1.StatusRunning)
 	if err != nil {
 		log.Errorf("continue workflowrun %s error %s", workflowrun, err)
@@ -113,15 +131,50 @@ func ContinueWorkflowRun(ctx context.Context, project, workflow, workflowrun, te
 	return handler.K8sClient.CycloneV1alpha1().WorkflowRuns(common.TenantNamespace(tenant)).Patch(workflowrun, k8s_types.JSONPatchType, data)
 }
 
+// ReplayWorkflowRun creates a new workflowrun with the same spec as the given one, resources in
+// the new workflowrun are pinned to the versions resolved in the given one.
+func ReplayWorkflowRun(ctx context.Context, project, workflow, workflowrun, tenant string) (*v1alpha1.WorkflowRun, error) {
+	origin, err := getWorkflowRun(project, workflowrun, tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	alias := origin.Name
+	if origin.Annotations != nil && origin.Annotations[common.AnnotationAlias] != "" {
+		alias = origin.Annotations[common.AnnotationAlias]
+	}
+	wfr := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: origin.Labels,
+			Annotations: map[string]string{
+				common.AnnotationAlias: alias + "-replay",
+			},
+		},
+		Spec: *origin.Spec.DeepCopy(),
+	}
+	wfr.Spec.Replay = origin.Name
+
+	err = ModifyResource(project, tenant, wfr)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler.K8sClient.CycloneV1alpha1().WorkflowRuns(common.TenantNamespace(tenant)).Create(wfr)
+}
+
 // ReceiveContainerLogStream receives real-time log of container within workflowrun stage.
 func ReceiveContainerLogStream(ctx context.Context, project, workflow, workflowrun, tenant, stage, container string) error {
+	if _, err := getWorkflowRun(project, workflowrun, tenant); err != nil {
+		return err
+	}
+
 	request := contextutil.GetHTTPRequest(ctx)
 	writer := contextutil.GetHTTPResponseWriter(ctx)
 
 	//upgrade HTTP rest API --> socket connection
 	ws, err := websocketutil.Upgrader.Upgrade(writer, request, nil)
 	if err != nil {
-		log.Errorf(fmt.Sprintf("Unable to upgrade websocket for err: %v", err))
+		log.Errorf("Unable to upgrade websocket for err: %v", err)
 		return cerr.ErrorUnknownInternal.Error(err)
 	}
 	defer ws.Close()
@@ -136,8 +189,58 @@ func ReceiveContainerLogStream(ctx context.Context, project, workflow, workflowr
 	return nil
 }
 
+// logFileLock serializes appends to a log file from connections of the same container, e.g. a
+// stale connection and a new one after the coordinator reconnected. refs is the number of
+// connections using it.
+type logFileLock struct {
+	sync.Mutex
+	refs int
+}
+
+var (
+	// logFileLocksMutex protects logFileLocks.
+	logFileLocksMutex sync.Mutex
+	// logFileLocks holds locks of log files being received, keyed by path of the log file. Locks
+	// are removed once no connection uses them.
+	logFileLocks = make(map[string]*logFileLock)
+)
+
+// acquireLogFileLock gets lock of the log file, it must be released by releaseLogFileLock when
+// the connection closes.
+func acquireLogFileLock(path string) *logFileLock {
+	logFileLocksMutex.Lock()
+	defer logFileLocksMutex.Unlock()
+
+	lock, ok := logFileLocks[path]
+	if !ok {
+		lock = &logFileLock{}
+		logFileLocks[path] = lock
+	}
+	lock.refs++
+	return lock
+}
+
+// releaseLogFileLock releases lock of the log file, the lock is removed if it's the last
+// connection using it.
+func releaseLogFileLock(path string) {
+	logFileLocksMutex.Lock()
+	defer logFileLocksMutex.Unlock()
+
+	lock, ok := logFileLocks[path]
+	if !ok {
+		return
+	}
+	lock.refs--
+	if lock.refs <= 0 {
+		delete(logFileLocks, path)
+	}
+}
+
 // receiveContainerLogStream receives the log stream for
 // one stage of the workflowrun, and stores it into log files.
+// Log is appended to the file if it already exists, the current size of the file is sent to the
+// coordinator once connected and after each chunk received, so that it can resume from there
+// after reconnecting, and chunks already stored are skipped.
 func receiveContainerLogStream(workflowrun, stage, container, namespace string, ws *websocket.Conn) error {
 	logFolder, err := getLogFolder(workflowrun, stage, namespace)
 	if err != nil {
@@ -154,20 +257,38 @@ func receiveContainerLogStream(workflowrun, stage, container, namespace string,
 		return err
 	}
 
-	if fileutil.Exists(logFilePath) {
-		return fmt.Errorf("log file %s already exists", logFilePath)
-	}
-
-	file, err := os.OpenFile(logFilePath, os.O_RDWR|os.O_CREATE, 0666)
+	file, err := os.OpenFile(logFilePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
 	if err != nil {
 		log.Errorf("fail to open the log file %s as %v", logFilePath, err)
 		return err
 	}
 	defer file.Close()
 
-	var message []byte
+	mutex := acquireLogFileLock(logFilePath)
+	defer releaseLogFileLock(logFilePath)
+	return receiveLogChunks(file, mutex, ws)
+}
+
+// receiveLogChunks receives log chunks from the connection and appends them to the log file. If a
+// chunk starts beyond the end of the file, some log is lost in the connection, it's closed with
+// error, and the coordinator will reconnect and resend from the size of the file.
+func receiveLogChunks(file *os.File, mutex *logFileLock, ws *websocket.Conn) error {
+	ws.SetReadLimit(websocketutil.MaxLogMessageSize)
+
+	mutex.Lock()
+	size, err := appendLogChunk(file, 0, nil)
+	mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
 	for {
-		_, message, err = ws.ReadMessage()
+		ws.SetWriteDeadline(time.Now().Add(websocketutil.WriteWait))
+		if err := ws.WriteJSON(websocketutil.LogOffset{Offset: size}); err != nil {
+			return err
+		}
+
+		messageType, message, err := ws.ReadMessage()
 		if err != nil {
 			if !websocket.IsUnexpectedCloseError(err, websocket.CloseAbnormalClosure) {
 				return nil
@@ -176,15 +297,56 @@ func receiveContainerLogStream(workflowrun, stage, container, namespace string,
 			log.Infoln(err)
 			return err
 		}
-		_, err = file.Write(message)
+		if messageType != websocket.BinaryMessage {
+			log.Warningf("Ignore message of type %d for log file %s", messageType, file.Name())
+			continue
+		}
+
+		offset, data, err := websocketutil.DecodeLogChunk(message)
+		if err != nil {
+			return err
+		}
+
+		mutex.Lock()
+		size, err = appendLogChunk(file, offset, data)
+		mutex.Unlock()
 		if err != nil {
 			return err
 		}
+		if offset > size {
+			// Reason of close message is limited to 123 bytes, so the file path is not included.
+			reason := fmt.Sprintf("log chunk at %d is beyond the end of log, expect %d", offset, size)
+			ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseProtocolError, reason),
+				time.Now().Add(websocketutil.WriteWait))
+			return fmt.Errorf("%s of log file %s", reason, file.Name())
+		}
+	}
+}
+
+// appendLogChunk appends the part of the log chunk starting at offset not stored yet to the
+// log file, and returns size of the log file after that. Nothing is appended if the chunk starts
+// beyond the end of the file.
+func appendLogChunk(file *os.File, offset int64, data []byte) (int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
 	}
+
+	size := info.Size()
+	if offset > size || offset+int64(len(data)) <= size {
+		return size, nil
+	}
+
+	n, err := file.Write(data[size-offset:])
+	return size + int64(n), err
 }
 
 // GetContainerLogStream gets real-time log of container within stage.
 func GetContainerLogStream(ctx context.Context, project, workflow, workflowrun, tenant, stage, container string) error {
+	if _, err := getWorkflowRun(project, workflowrun, tenant); err != nil {
+		return err
+	}
+
 	request := contextutil.GetHTTPRequest(ctx)
 	writer := contextutil.GetHTTPResponseWriter(ctx)
 
@@ -265,6 +427,10 @@ func getContainerLogStream(workflowrun, stage, container, namespace string, ws *
 
 // GetContainerLogs handles the request to get container logs, only supports finished stage records.
 func GetContainerLogs(ctx context.Context, project, workflow, workflowrun, tenant, stage, container string, download bool) ([]byte, map[string]string, error) {
+	if _, err := getWorkflowRun(project, workflowrun, tenant); err != nil {
+		return nil, nil, err
+	}
+
 	namespace := common.TenantNamespace(tenant)
 
 	logs, err := getContainerLogs(workflowrun, stage, container, namespace)
@@ -8,19 +8,54 @@ import (
 	"k8s.io/client-go/util/retry"
 
 	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/server/biz/scm"
+	"github.com/caicloud/cyclone/pkg/server/biz/webhook"
 	"github.com/caicloud/cyclone/pkg/server/common"
 	"github.com/caicloud/cyclone/pkg/server/handler"
 	"github.com/caicloud/cyclone/pkg/server/types"
+	"github.com/caicloud/cyclone/pkg/util/cerr"
 )
 
 // CreateWorkflowTrigger ...
 func CreateWorkflowTrigger(ctx context.Context, project, tenant string, wft *v1alpha1.WorkflowTrigger) (*v1alpha1.WorkflowTrigger, error) {
+	if err := validateWorkflowTrigger(wft, nil); err != nil {
+		return nil, err
+	}
+	if wft.Spec.WorkflowRef != nil {
+		if _, err := getWorkflow(project, wft.Spec.WorkflowRef.Name, tenant); err != nil {
+			return nil, err
+		}
+	}
+
 	err := ModifyResource(project, tenant, wft)
 	if err != nil {
 		return nil, err
 	}
 
-	return handler.K8sClient.CycloneV1alpha1().WorkflowTriggers(common.TenantNamespace(tenant)).Create(wft)
+	var secret string
+	if wft.Spec.Type == v1alpha1.WebhookTrigger {
+		secret = webhook.TakeSecret(wft)
+	}
+	wft.Status.Webhook, err = registerWebhook(tenant, wft, secret, nil)
+	if err != nil {
+		return nil, err
+	}
+	created, err := handler.K8sClient.CycloneV1alpha1().WorkflowTriggers(common.TenantNamespace(tenant)).Create(wft)
+	if err != nil {
+		unregisterWebhook(tenant, wft.Status.Webhook)
+		return nil, err
+	}
+	// Secret is saved after the trigger created, so that Secret of an existing trigger with the
+	// same name isn't overwritten.
+	if secret != "" {
+		if err := webhook.SaveSecret(tenant, wft.Name, secret); err != nil {
+			log.Errorf("Save secret of workflowtrigger %s/%s error: %v", tenant, wft.Name, err)
+			handler.K8sClient.CycloneV1alpha1().WorkflowTriggers(common.TenantNamespace(tenant)).Delete(wft.Name, nil)
+			unregisterWebhook(tenant, wft.Status.Webhook)
+			return nil, err
+		}
+	}
+	return created, nil
 }
 
 // ListWorkflowTriggers ...
@@ -34,6 +69,9 @@ func ListWorkflowTriggers(ctx context.Context, project, tenant string, paginatio
 	}
 
 	items := workflowTriggers.Items
+	for i := range items {
+		webhook.RedactSecret(&items[i])
+	}
 	size := int64(len(items))
 	if pagination.Start >= size {
 		return types.NewListResponse(int(size), []v1alpha1.WorkflowTrigger{}), nil
@@ -49,18 +87,59 @@ func ListWorkflowTriggers(ctx context.Context, project, tenant string, paginatio
 
 // GetWorkflowTrigger ...
 func GetWorkflowTrigger(ctx context.Context, project, workflowtrigger, tenant string) (*v1alpha1.WorkflowTrigger, error) {
-	return handler.K8sClient.CycloneV1alpha1().WorkflowTriggers(common.TenantNamespace(tenant)).Get(workflowtrigger, metav1.GetOptions{})
+	wft, err := getWorkflowTrigger(project, workflowtrigger, tenant)
+	if err != nil {
+		return nil, err
+	}
+	webhook.RedactSecret(wft)
+	return wft, nil
 }
 
 // UpdateWorkflowTrigger ...
 func UpdateWorkflowTrigger(ctx context.Context, project, workflowtrigger, tenant string, wft *v1alpha1.WorkflowTrigger) (*v1alpha1.WorkflowTrigger, error) {
-	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+	origin, err := getWorkflowTrigger(project, workflowtrigger, tenant)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateWorkflowTrigger(wft, origin); err != nil {
+		return nil, err
+	}
+	if wft.Spec.WorkflowRef != nil {
+		if _, err := getWorkflow(project, wft.Spec.WorkflowRef.Name, tenant); err != nil {
+			return nil, err
+		}
+	}
+	wft.Name = workflowtrigger
+
+	// Secret saved before is kept if it's not given, it's also moved to Secret if it's in parameters.
+	var secret string
+	if wft.Spec.Type == v1alpha1.WebhookTrigger {
+		if secret = webhook.TakeSecret(wft); secret == "" {
+			if secret, err = webhook.Secret(tenant, origin); err != nil {
+				log.Errorf("Get secret of workflowtrigger %s/%s error: %v", tenant, workflowtrigger, err)
+				return nil, err
+			}
+		}
+		if err := webhook.SaveSecret(tenant, workflowtrigger, secret); err != nil {
+			log.Errorf("Save secret of workflowtrigger %s/%s error: %v", tenant, workflowtrigger, err)
+			return nil, err
+		}
+	} else if err := webhook.DeleteSecret(tenant, workflowtrigger); err != nil {
+		log.Warningf("Delete secret of workflowtrigger %s/%s error: %v", tenant, workflowtrigger, err)
+	}
+	hook, err := registerWebhook(tenant, wft, secret, origin.Status.Webhook)
+	if err != nil {
+		return nil, err
+	}
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		origin, err := handler.K8sClient.CycloneV1alpha1().WorkflowTriggers(common.TenantNamespace(tenant)).Get(workflowtrigger, metav1.GetOptions{})
 		if err != nil {
 			return err
 		}
 		newWft := origin.DeepCopy()
 		newWft.Spec = wft.Spec
+		newWft.Status.Webhook = hook
 		newWft.Annotations = UpdateAnnotations(wft.Annotations, newWft.Annotations)
 		_, err = handler.K8sClient.CycloneV1alpha1().WorkflowTriggers(common.TenantNamespace(tenant)).Update(newWft)
 		return err
@@ -75,5 +154,58 @@ func UpdateWorkflowTrigger(ctx context.Context, project, workflowtrigger, tenant
 
 // DeleteWorkflowTrigger ...
 func DeleteWorkflowTrigger(ctx context.Context, project, workflowtrigger, tenant string) error {
-	return handler.K8sClient.CycloneV1alpha1().WorkflowTriggers(common.TenantNamespace(tenant)).Delete(workflowtrigger, nil)
+	wft, err := getWorkflowTrigger(project, workflowtrigger, tenant)
+	if err != nil {
+		return err
+	}
+
+	if err := handler.K8sClient.CycloneV1alpha1().WorkflowTriggers(common.TenantNamespace(tenant)).Delete(workflowtrigger, nil); err != nil {
+		return err
+	}
+	unregisterWebhook(tenant, wft.Status.Webhook)
+	if err := webhook.DeleteSecret(tenant, workflowtrigger); err != nil {
+		log.Warningf("Delete secret of workflowtrigger %s/%s error: %v", tenant, workflowtrigger, err)
+	}
+	return nil
+}
+
+// registerWebhook registers webhook in SCM for the trigger if it references a SCM integration,
+// see webhook.Register.
+func registerWebhook(tenant string, wft *v1alpha1.WorkflowTrigger, secret string, registered *v1alpha1.WebhookStatus) (*v1alpha1.WebhookStatus, error) {
+	hook, err := webhook.Register(tenant, wft, secret, registered)
+	if err != nil {
+		log.Errorf("Register webhook for workflowtrigger %s/%s error: %v", tenant, wft.Name, err)
+		if scm.IsPermissionDenied(err) {
+			return nil, cerr.ErrorCreateWebhookPermissionDenied.Error(wft.Name)
+		}
+		return nil, cerr.ErrorCreateFailed.Error("webhook", err)
+	}
+	return hook, nil
+}
+
+// unregisterWebhook removes webhook registered for the trigger, failures are only logged since
+// webhooks sent by it would be rejected without the trigger.
+func unregisterWebhook(tenant string, registered *v1alpha1.WebhookStatus) {
+	if err := webhook.Unregister(tenant, registered); err != nil {
+		log.Warningf("Remove webhook %s from %s error: %v", registered.ID, registered.Repository, err)
+	}
+}
+
+// validateWorkflowTrigger validates parameters of Webhook type WorkflowTriggers. Secret can be
+// omitted when updating triggers, secret of the origin trigger is kept then.
+func validateWorkflowTrigger(wft, origin *v1alpha1.WorkflowTrigger) error {
+	if wft.Spec.Type != v1alpha1.WebhookTrigger {
+		return nil
+	}
+	c := webhook.NewConfig(wft.Spec.Parameters)
+	// Reference of the secret is set by Cyclone, it can't be given by users.
+	c.SecretRef = ""
+	if c.Secret == "" && origin != nil {
+		oc := webhook.NewConfig(origin.Spec.Parameters)
+		c.Secret, c.SecretRef = oc.Secret, oc.SecretRef
+	}
+	if err := c.Validate(); err != nil {
+		return cerr.ErrorValidationFailed.Error("spec.parameters", err)
+	}
+	return nil
 }
@@ -0,0 +1,103 @@
+package v1alpha1
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/k8s/clientset/fake"
+	"github.com/caicloud/cyclone/pkg/server/biz/webhook"
+	"github.com/caicloud/cyclone/pkg/server/common"
+	"github.com/caicloud/cyclone/pkg/server/handler"
+	"github.com/caicloud/cyclone/pkg/server/types"
+)
+
+func TestWorkflowTriggerSecret(t *testing.T) {
+	namespace := common.TenantNamespace("t1")
+	client := fake.NewSimpleClientset(&v1alpha1.WorkflowTrigger{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "legacy", Namespace: namespace, Labels: map[string]string{common.LabelProject: "p1"}},
+		Spec: v1alpha1.WorkflowTriggerSpec{
+			Type:       v1alpha1.WebhookTrigger,
+			Parameters: []v1alpha1.ParameterItem{{Name: webhook.ParamSecret, Value: "legacy-s3cret"}},
+		},
+	})
+	origin := handler.K8sClient
+	handler.K8sClient = client
+	defer func() {
+		handler.K8sClient = origin
+	}()
+
+	secretOf := func(name string) string {
+		secret, err := client.CoreV1().Secrets(namespace).Get(webhook.SecretName(name), meta_v1.GetOptions{})
+		if err != nil {
+			return ""
+		}
+		return string(secret.Data["secret"])
+	}
+	hasSecret := func(wft *v1alpha1.WorkflowTrigger) bool {
+		for _, p := range wft.Spec.Parameters {
+			if p.Name == webhook.ParamSecret && p.Value != "" {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Secret is moved to Secret on creation.
+	_, err := CreateWorkflowTrigger(context.TODO(), "p1", "t1", &v1alpha1.WorkflowTrigger{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "wft"},
+		Spec: v1alpha1.WorkflowTriggerSpec{
+			Type:       v1alpha1.WebhookTrigger,
+			Parameters: []v1alpha1.ParameterItem{{Name: webhook.ParamSecret, Value: "s3cret"}},
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "s3cret", secretOf("wft"))
+	wft, err := GetWorkflowTrigger(context.TODO(), "p1", "wft", "t1")
+	assert.Nil(t, err)
+	assert.False(t, hasSecret(wft))
+	assert.Equal(t, []v1alpha1.ParameterItem{{Name: webhook.ParamSecretRef, Value: webhook.SecretName("wft")}}, wft.Spec.Parameters)
+
+	// Secret is required on creation, and reference can't be given by users.
+	_, err = CreateWorkflowTrigger(context.TODO(), "p1", "t1", &v1alpha1.WorkflowTrigger{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "other"},
+		Spec: v1alpha1.WorkflowTriggerSpec{
+			Type:       v1alpha1.WebhookTrigger,
+			Parameters: []v1alpha1.ParameterItem{{Name: webhook.ParamSecretRef, Value: webhook.SecretName("wft")}},
+		},
+	})
+	assert.Error(t, err)
+
+	// Secret in parameters of triggers saved before is redacted, and moved to Secret on update.
+	resp, err := ListWorkflowTriggers(context.TODO(), "p1", "t1", &types.Pagination{Limit: 10})
+	assert.Nil(t, err)
+	for _, item := range resp.Items.([]v1alpha1.WorkflowTrigger) {
+		assert.False(t, hasSecret(&item), item.Name)
+	}
+	legacy, err := GetWorkflowTrigger(context.TODO(), "p1", "legacy", "t1")
+	assert.Nil(t, err)
+	assert.False(t, hasSecret(legacy))
+	_, err = UpdateWorkflowTrigger(context.TODO(), "p1", "legacy", "t1", legacy)
+	assert.Nil(t, err)
+	assert.Equal(t, "legacy-s3cret", secretOf("legacy"))
+	stored, err := client.CycloneV1alpha1().WorkflowTriggers(namespace).Get("legacy", meta_v1.GetOptions{})
+	assert.Nil(t, err)
+	assert.False(t, hasSecret(stored))
+
+	// Secret is kept if not given on update, and changed if given.
+	_, err = UpdateWorkflowTrigger(context.TODO(), "p1", "wft", "t1", wft)
+	assert.Nil(t, err)
+	assert.Equal(t, "s3cret", secretOf("wft"))
+	wft.Spec.Parameters = []v1alpha1.ParameterItem{{Name: webhook.ParamSecret, Value: "n3w"}}
+	_, err = UpdateWorkflowTrigger(context.TODO(), "p1", "wft", "t1", wft)
+	assert.Nil(t, err)
+	assert.Equal(t, "n3w", secretOf("wft"))
+
+	// Secret is deleted with the trigger.
+	assert.Nil(t, DeleteWorkflowTrigger(context.TODO(), "p1", "wft", "t1"))
+	_, err = client.CoreV1().Secrets(namespace).Get(webhook.SecretName("wft"), meta_v1.GetOptions{})
+	assert.Error(t, err)
+}
@@ -55,7 +55,7 @@ var (
 
 	// ErrorCreateWebhookPermissionDenied defines error that failed creating webhook as permission denied.
 	ErrorCreateWebhookPermissionDenied = nerror.InternalServerError.Build("ReasonCreateWebhookPermissionDenied",
-		"failed to create webhook of pipeline ${pipeline}, please check your account permissions.")
+		"failed to create webhook of workflow trigger ${trigger}, please check your account permissions.")
 
 	// ErrorUnsupported defines some feature/field not supported yet.
 	ErrorUnsupported = nerror.BadRequest.Build("ReasonUnsupported", "unsupported ${resource}: ${type}")
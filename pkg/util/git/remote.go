@@ -0,0 +1,136 @@
+/*
+Copyright 2018 caicloud authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var commitPattern = regexp.MustCompile("^[0-9a-f]{40}$")
+
+// IsCommit checks whether the revision is a full commit SHA.
+func IsCommit(revision string) bool {
+	return commitPattern.MatchString(revision)
+}
+
+// LsRemote lists references in a remote repository through git smart HTTP protocol, it works
+// like 'git ls-remote'. If token is provided, it's used as the username in basic auth, which is
+// the same way as git resource resolver clones the repository.
+func LsRemote(url, token string) (map[string]string, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(url, "/")+"/info/refs?service=git-upload-pack", nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.SetBasicAuth(token, "")
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("list references of %s error: %s", url, resp.Status)
+	}
+
+	return parseRefs(resp.Body)
+}
+
+// parseRefs parses reference advertisement in pkt-line format, each line is prefixed with 4 hex
+// digits of line length, and '0000' is flush packet.
+func parseRefs(r io.Reader) (map[string]string, error) {
+	refs := make(map[string]string)
+	reader := bufio.NewReader(r)
+	for {
+		prefix := make([]byte, 4)
+		if _, err := io.ReadFull(reader, prefix); err != nil {
+			if err == io.EOF {
+				return refs, nil
+			}
+			return nil, err
+		}
+		length, err := strconv.ParseUint(string(prefix), 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pkt-line length: %q", prefix)
+		}
+		// Flush packet
+		if length == 0 {
+			continue
+		}
+		if length < 4 {
+			return nil, fmt.Errorf("invalid pkt-line length: %d", length)
+		}
+
+		data := make([]byte, length-4)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		line := strings.TrimSuffix(string(data), "\n")
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		// Capabilities are appended to the first reference after a NUL byte.
+		if i := strings.IndexByte(line, 0); i >= 0 {
+			line = line[:i]
+		}
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) != 2 || !IsCommit(parts[0]) {
+			continue
+		}
+		refs[parts[1]] = parts[0]
+	}
+}
+
+// ResolveRevision resolves the revision (commit SHA, branch or tag) to a commit SHA in the
+// remote repository. For annotated tags, the commit it points to is returned.
+func ResolveRevision(url, token, revision string) (string, error) {
+	if IsCommit(revision) {
+		return revision, nil
+	}
+
+	refs, err := LsRemote(url, token)
+	if err != nil {
+		return "", err
+	}
+	return lookupRevision(refs, revision)
+}
+
+func lookupRevision(refs map[string]string, revision string) (string, error) {
+	candidates := []string{
+		revision,
+		"refs/heads/" + revision,
+		"refs/tags/" + revision + "^{}",
+		"refs/tags/" + revision,
+	}
+	for _, c := range candidates {
+		if sha, ok := refs[c]; ok {
+			return sha, nil
+		}
+	}
+
+	return "", fmt.Errorf("revision %s not found", revision)
+}
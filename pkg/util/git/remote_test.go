@@ -0,0 +1,99 @@
+/*
+Copyright 2018 caicloud authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const (
+	master = "1111111111111111111111111111111111111111"
+	tag    = "2222222222222222222222222222222222222222"
+	tagged = "3333333333333333333333333333333333333333"
+)
+
+func pktLine(s string) string {
+	return fmt.Sprintf("%04x%s", len(s)+4, s)
+}
+
+func advertisement() string {
+	return pktLine("# service=git-upload-pack\n") + "0000" +
+		pktLine(master+" HEAD\x00multi_ack side-band-64k\n") +
+		pktLine(master+" refs/heads/master\n") +
+		pktLine(tag+" refs/tags/v1.0\n") +
+		pktLine(tagged+" refs/tags/v1.0^{}\n") +
+		"0000"
+}
+
+func TestResolveRevision(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/info/refs") || r.URL.Query().Get("service") != "git-upload-pack" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if user, _, _ := r.BasicAuth(); user != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, advertisement())
+	}))
+	defer server.Close()
+
+	d := map[string]struct {
+		revision string
+		expect   string
+	}{
+		"branch": {
+			"master",
+			master,
+		},
+		"annotated tag": {
+			"v1.0",
+			tagged,
+		},
+		"head": {
+			"HEAD",
+			master,
+		},
+		"commit": {
+			"4444444444444444444444444444444444444444",
+			"4444444444444444444444444444444444444444",
+		},
+	}
+
+	for k, v := range d {
+		r, err := ResolveRevision(server.URL+"/repo.git", "token", v.revision)
+		if err != nil {
+			t.Errorf("%s failed: %v", k, err)
+			continue
+		}
+		if r != v.expect {
+			t.Errorf("%s failed as expect %s but got %s", k, v.expect, r)
+		}
+	}
+
+	if _, err := ResolveRevision(server.URL+"/repo.git", "token", "unknown"); err == nil {
+		t.Error("unknown revision should fail")
+	}
+	if _, err := ResolveRevision(server.URL+"/repo.git", "", "master"); err == nil {
+		t.Error("unauthorized request should fail")
+	}
+}
@@ -49,6 +49,18 @@ const (
 	// WorkflowRunNamePathParameterName represents the name of the path parameter for workflowrun name.
 	WorkflowRunNamePathParameterName = "workflowrun"
 
+	// ReportNamePathParameterName represents the name of the path parameter for test report name.
+	ReportNamePathParameterName = "report"
+
+	// UserNamePathParameterName represents the name of the path parameter for user name.
+	UserNamePathParameterName = "user"
+
+	// TokenIDPathParameterName represents the name of the path parameter for API token ID.
+	TokenIDPathParameterName = "token"
+
+	// ProviderPathParameterName represents the name of the path parameter for identity provider.
+	ProviderPathParameterName = "provider"
+
 	// WorkflowTriggerNamePathParameterName represents the name of the path parameter for workflowtrigger name.
 	WorkflowTriggerNamePathParameterName = "workflowtrigger"
 
@@ -58,6 +70,27 @@ const (
 	// ContainerNameQueryParameter represents the query param container name.
 	ContainerNameQueryParameter = "container"
 
+	// RunsQueryParameter represents the query param for number of recent runs.
+	RunsQueryParameter = "runs"
+
+	// CodeQueryParameter represents the query param for authorization code of identity providers.
+	CodeQueryParameter = "code"
+
+	// StateQueryParameter represents the query param for state of login with identity providers.
+	StateQueryParameter = "state"
+
+	// TenantQueryParameter represents the query param for tenant name.
+	TenantQueryParameter = "tenant"
+
+	// ActorQueryParameter represents the query param for actor of audit records.
+	ActorQueryParameter = "actor"
+
+	// SinceQueryParameter represents the query param for start of time range, in RFC3339 format.
+	SinceQueryParameter = "since"
+
+	// UntilQueryParameter represents the query param for end of time range, in RFC3339 format.
+	UntilQueryParameter = "until"
+
 	// PaginationAutoParameter represents the auto param pagination.
 	PaginationAutoParameter = "pagination"
 
@@ -73,6 +106,12 @@ const (
 	// DownloadQueryParameter represents a download flag of the query parameter.
 	DownloadQueryParameter = "download"
 
+	// ArtifactNamePathParameterName represents the name of the path parameter for artifact name.
+	ArtifactNamePathParameterName = "artifact"
+
+	// ArchiveQueryParameter indicates whether to download artifact as tar.gz archive.
+	ArchiveQueryParameter = "archive"
+
 	// StatusQueryParameter represents a status of the query parameter.
 	StatusQueryParameter = "status"
 
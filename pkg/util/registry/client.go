@@ -0,0 +1,609 @@
+/*
+Copyright 2018 caicloud authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Media types of manifests and blobs.
+const (
+	MediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeDockerConfig       = "application/vnd.docker.container.image.v1+json"
+	MediaTypeDockerLayer        = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+	MediaTypeOCIManifest        = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeOCIIndex           = "application/vnd.oci.image.index.v1+json"
+)
+
+// acceptedManifests are media types of manifests accepted when getting manifests.
+var acceptedManifests = []string{
+	MediaTypeDockerManifest,
+	MediaTypeDockerManifestList,
+	MediaTypeOCIManifest,
+	MediaTypeOCIIndex,
+}
+
+// Descriptor describes a blob or manifest.
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Size        int64             `json:"size"`
+	Digest      string            `json:"digest"`
+	URLs        []string          `json:"urls,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *Platform         `json:"platform,omitempty"`
+}
+
+// Platform describes platform of an image in manifest list.
+type Platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// String gets platform in format '<os>/<arch>[/<variant>]'.
+func (p *Platform) String() string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// ImageManifest is manifest of a single image, either docker manifest v2 schema 2 or OCI manifest.
+type ImageManifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType,omitempty"`
+	Config        Descriptor   `json:"config"`
+	Layers        []Descriptor `json:"layers"`
+}
+
+// Index is manifest of a multi-platform image, either docker manifest list or OCI index.
+type Index struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType,omitempty"`
+	Manifests     []Descriptor `json:"manifests"`
+}
+
+// Manifest is raw content of a manifest.
+type Manifest struct {
+	MediaType string
+	Digest    string
+	Content   []byte
+}
+
+// IsIndex checks whether the media type is a manifest list or OCI index.
+func IsIndex(mediaType string) bool {
+	return mediaType == MediaTypeDockerManifestList || mediaType == MediaTypeOCIIndex
+}
+
+// Digest computes sha256 digest of the content.
+func Digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Credential is username and password to access a registry.
+type Credential struct {
+	Username string
+	Password string
+}
+
+// Client accesses registries through Docker Registry HTTP API V2, it supports both basic auth
+// and token auth.
+type Client struct {
+	// HTTPClient sends requests, http.DefaultClient is used if nil.
+	HTTPClient *http.Client
+	// Credentials of registries, keyed by registry, e.g. 'docker.io', 'localhost:5000'.
+	Credentials map[string]Credential
+	// Insecure indicates to access registries over plain HTTP.
+	Insecure bool
+
+	lock sync.Mutex
+	// auths caches Authorization header values, keyed by registry host and scope.
+	auths map[string]string
+}
+
+// NewClient creates a registry client with the credentials.
+func NewClient(credentials map[string]Credential) *Client {
+	if credentials == nil {
+		credentials = make(map[string]Credential)
+	}
+	return &Client{
+		Credentials: credentials,
+	}
+}
+
+// LoadDockerConfig loads registry credentials from docker config.json. Empty credentials are
+// returned if the file doesn't exist.
+func LoadDockerConfig(path string) (map[string]Credential, error) {
+	credentials := make(map[string]Credential)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return credentials, nil
+		}
+		return nil, err
+	}
+
+	config := struct {
+		Auths map[string]struct {
+			Auth     string `json:"auth"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"auths"`
+	}{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("parse docker config %s error: %v", path, err)
+	}
+
+	for server, auth := range config.Auths {
+		credential := Credential{
+			Username: auth.Username,
+			Password: auth.Password,
+		}
+		if auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("invalid auth of %s in docker config: %v", server, err)
+			}
+			parts := strings.SplitN(string(decoded), ":", 2)
+			if len(parts) == 2 {
+				credential.Username, credential.Password = parts[0], parts[1]
+			}
+		}
+		credentials[NormalizeRegistry(server)] = credential
+	}
+
+	return credentials, nil
+}
+
+// NormalizeRegistry converts server address, e.g. in docker config, to registry used as key of
+// credentials, for example, 'https://index.docker.io/v1/' is converted to 'docker.io'.
+func NormalizeRegistry(server string) string {
+	server = strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+	server = strings.SplitN(server, "/", 2)[0]
+	if server == "index.docker.io" || server == defaultRegistryHost {
+		return DefaultRegistry
+	}
+	return server
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// url gets URL of the registry API path for the repository.
+func (c *Client) url(ref *Reference, path string) string {
+	scheme := "https"
+	if c.Insecure {
+		scheme = "http"
+	}
+	return fmt.Sprintf("%s://%s/v2/%s/%s", scheme, ref.host(), ref.Repository, path)
+}
+
+// request describes a registry API request. Body is a function so that the request can be
+// retried after authorization.
+type request struct {
+	method string
+	url    string
+	header http.Header
+	body   func() (io.ReadCloser, error)
+	size   int64
+}
+
+// do sends the request to the registry. If the registry challenges for authorization, the client
+// authorizes against it with the scope and sends the request again.
+func (c *Client) do(ref *Reference, scope string, r *request) (*http.Response, error) {
+	key := ref.host() + "|" + scope
+	resp, err := c.send(r, c.auth(key))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+	auth, err := c.authorize(ref, scope, challenge)
+	if err != nil {
+		return nil, err
+	}
+	c.lock.Lock()
+	if c.auths == nil {
+		c.auths = make(map[string]string)
+	}
+	c.auths[key] = auth
+	c.lock.Unlock()
+
+	return c.send(r, auth)
+}
+
+func (c *Client) auth(key string) string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.auths[key]
+}
+
+func (c *Client) send(r *request, auth string) (*http.Response, error) {
+	var body io.ReadCloser
+	if r.body != nil {
+		var err error
+		if body, err = r.body(); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequest(r.method, r.url, body)
+	if err != nil {
+		if body != nil {
+			body.Close()
+		}
+		return nil, err
+	}
+	for k, v := range r.header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.ContentLength = r.size
+	}
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+
+	return c.httpClient().Do(req)
+}
+
+// authorize gets Authorization header value according to the challenge in WWW-Authenticate.
+func (c *Client) authorize(ref *Reference, scope, challenge string) (string, error) {
+	credential, hasCredential := c.Credentials[ref.Registry]
+	scheme, params := parseChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if !hasCredential {
+			return "", fmt.Errorf("no credential for registry %s", ref.Registry)
+		}
+		return "Basic " + basicAuth(credential), nil
+	case "bearer":
+		u, err := url.Parse(params["realm"])
+		if err != nil || params["realm"] == "" {
+			return "", fmt.Errorf("invalid token realm '%s' of registry %s", params["realm"], ref.Registry)
+		}
+		q := u.Query()
+		if params["service"] != "" {
+			q.Set("service", params["service"])
+		}
+		q.Set("scope", scope)
+		u.RawQuery = q.Encode()
+
+		req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+		if err != nil {
+			return "", err
+		}
+		if hasCredential {
+			req.SetBasicAuth(credential.Username, credential.Password)
+		}
+		resp, err := c.httpClient().Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", responseError("get token", resp)
+		}
+
+		token := struct {
+			Token       string `json:"token"`
+			AccessToken string `json:"access_token"`
+		}{}
+		if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+			return "", fmt.Errorf("decode token error: %v", err)
+		}
+		if token.Token == "" {
+			token.Token = token.AccessToken
+		}
+		return "Bearer " + token.Token, nil
+	default:
+		return "", fmt.Errorf("unauthorized by registry %s, unsupported challenge '%s'", ref.Registry, challenge)
+	}
+}
+
+// parseChallenge parses WWW-Authenticate header like 'Bearer realm="...",service="..."'.
+func parseChallenge(challenge string) (string, map[string]string) {
+	params := make(map[string]string)
+	parts := strings.SplitN(strings.TrimSpace(challenge), " ", 2)
+	if len(parts) < 2 {
+		return parts[0], params
+	}
+
+	for _, kv := range splitParams(parts[1]) {
+		pair := strings.SplitN(kv, "=", 2)
+		if len(pair) == 2 {
+			params[strings.ToLower(strings.TrimSpace(pair[0]))] = strings.Trim(strings.TrimSpace(pair[1]), `"`)
+		}
+	}
+	return parts[0], params
+}
+
+// splitParams splits challenge parameters by commas outside of quotes, since scope may contain
+// commas, e.g. 'repository:foo:pull,push'.
+func splitParams(s string) []string {
+	var params []string
+	var quoted bool
+	start := 0
+	for i, c := range s {
+		switch c {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				params = append(params, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(params, s[start:])
+}
+
+func basicAuth(credential Credential) string {
+	return base64.StdEncoding.EncodeToString([]byte(credential.Username + ":" + credential.Password))
+}
+
+func responseError(action string, resp *http.Response) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("%s error, status %d: %s", action, resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+func pullScope(ref *Reference) string {
+	return fmt.Sprintf("repository:%s:pull", ref.Repository)
+}
+
+func pushScope(ref *Reference) string {
+	return fmt.Sprintf("repository:%s:pull,push", ref.Repository)
+}
+
+func bytesBody(content []byte) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(content)), nil
+	}
+}
+
+// Resolve resolves the image reference to digest of its manifest.
+func (c *Client) Resolve(ref *Reference) (string, error) {
+	resp, err := c.do(ref, pullScope(ref), &request{
+		method: http.MethodHead,
+		url:    c.url(ref, "manifests/"+ref.reference()),
+		header: http.Header{"Accept": acceptedManifests},
+	})
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		if digest := resp.Header.Get("Docker-Content-Digest"); IsDigest(digest) {
+			return digest, nil
+		}
+	}
+
+	// Some registries don't return digest in HEAD responses, get the manifest instead.
+	manifest, err := c.GetManifest(ref)
+	if err != nil {
+		return "", err
+	}
+	return manifest.Digest, nil
+}
+
+// GetManifest gets manifest of the image.
+func (c *Client) GetManifest(ref *Reference) (*Manifest, error) {
+	resp, err := c.do(ref, pullScope(ref), &request{
+		method: http.MethodGet,
+		url:    c.url(ref, "manifests/"+ref.reference()),
+		header: http.Header{"Accept": acceptedManifests},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError("get manifest of "+ref.String(), resp)
+	}
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{
+		MediaType: resp.Header.Get("Content-Type"),
+		Digest:    Digest(content),
+		Content:   content,
+	}
+	if ref.Digest != "" && ref.Digest != manifest.Digest {
+		return nil, fmt.Errorf("digest of manifest %s mismatch, got %s", ref.String(), manifest.Digest)
+	}
+
+	// Media type in manifest takes precedence, registries may respond with generic content types.
+	mediaType := struct {
+		MediaType string `json:"mediaType"`
+	}{}
+	if err := json.Unmarshal(content, &mediaType); err == nil && mediaType.MediaType != "" {
+		manifest.MediaType = mediaType.MediaType
+	}
+
+	return manifest, nil
+}
+
+// PutManifest puts the manifest to the image reference, which is usually a tag. Digest of the
+// manifest is returned.
+func (c *Client) PutManifest(ref *Reference, manifest *Manifest) (string, error) {
+	resp, err := c.do(ref, pushScope(ref), &request{
+		method: http.MethodPut,
+		url:    c.url(ref, "manifests/"+ref.reference()),
+		header: http.Header{"Content-Type": []string{manifest.MediaType}},
+		body:   bytesBody(manifest.Content),
+		size:   int64(len(manifest.Content)),
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return "", responseError("put manifest of "+ref.String(), resp)
+	}
+	return Digest(manifest.Content), nil
+}
+
+// BlobExists checks whether the blob exists in the repository.
+func (c *Client) BlobExists(ref *Reference, digest string) (bool, error) {
+	resp, err := c.do(ref, pullScope(ref), &request{
+		method: http.MethodHead,
+		url:    c.url(ref, "blobs/"+digest),
+	})
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("check blob %s in %s error, status %d", digest, ref.Name(), resp.StatusCode)
+	}
+}
+
+// GetBlob gets content of the blob in the repository, the caller should close it.
+func (c *Client) GetBlob(ref *Reference, digest string) (io.ReadCloser, error) {
+	resp, err := c.do(ref, pullScope(ref), &request{
+		method: http.MethodGet,
+		url:    c.url(ref, "blobs/"+digest),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, responseError(fmt.Sprintf("get blob %s from %s", digest, ref.Name()), resp)
+	}
+	return resp.Body, nil
+}
+
+// PutBlob uploads the blob to the repository in a single request, it's skipped if the blob
+// already exists. Content of the blob is opened by the open function, it may be called more than
+// once if authorization is required.
+func (c *Client) PutBlob(ref *Reference, desc Descriptor, open func() (io.ReadCloser, error)) error {
+	exist, err := c.BlobExists(ref, desc.Digest)
+	if err != nil {
+		return err
+	}
+	if exist {
+		return nil
+	}
+
+	resp, err := c.do(ref, pushScope(ref), &request{
+		method: http.MethodPost,
+		url:    c.url(ref, "blobs/uploads/"),
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("start uploading blob %s to %s error, status %d", desc.Digest, ref.Name(), resp.StatusCode)
+	}
+
+	location, err := resp.Request.URL.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return fmt.Errorf("invalid upload location: %v", err)
+	}
+	q := location.Query()
+	q.Set("digest", desc.Digest)
+	location.RawQuery = q.Encode()
+
+	resp, err = c.do(ref, pushScope(ref), &request{
+		method: http.MethodPut,
+		url:    location.String(),
+		header: http.Header{"Content-Type": []string{"application/octet-stream"}},
+		body:   open,
+		size:   desc.Size,
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return responseError(fmt.Sprintf("upload blob %s to %s", desc.Digest, ref.Name()), resp)
+	}
+	return nil
+}
+
+// Copy copies the image from source to destination, multi-platform images are copied with all
+// platforms. Digest of the copied manifest is returned, it's the same as the source.
+func (c *Client) Copy(src, dst *Reference) (string, error) {
+	manifest, err := c.GetManifest(src)
+	if err != nil {
+		return "", err
+	}
+
+	if IsIndex(manifest.MediaType) {
+		index := &Index{}
+		if err := json.Unmarshal(manifest.Content, index); err != nil {
+			return "", fmt.Errorf("parse index of %s error: %v", src.String(), err)
+		}
+		for _, m := range index.Manifests {
+			if _, err := c.Copy(src.WithDigest(m.Digest), dst.WithDigest(m.Digest)); err != nil {
+				return "", err
+			}
+		}
+	} else {
+		image := &ImageManifest{}
+		if err := json.Unmarshal(manifest.Content, image); err != nil {
+			return "", fmt.Errorf("parse manifest of %s error: %v", src.String(), err)
+		}
+		for _, desc := range append([]Descriptor{image.Config}, image.Layers...) {
+			// Foreign layers, e.g. Windows base layers, are not distributed by registries.
+			if len(desc.URLs) > 0 {
+				continue
+			}
+			d := desc
+			err := c.PutBlob(dst, d, func() (io.ReadCloser, error) {
+				return c.GetBlob(src, d.Digest)
+			})
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+
+	return c.PutManifest(dst, manifest)
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2018 caicloud authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// DefaultRegistry is the registry used when image name contains no registry.
+	DefaultRegistry = "docker.io"
+	// defaultRegistryHost is the host serving registry API of DefaultRegistry.
+	defaultRegistryHost = "registry-1.docker.io"
+	// defaultTag is the tag used when image name contains neither tag nor digest.
+	defaultTag = "latest"
+)
+
+var digestPattern = regexp.MustCompile("^sha256:[0-9a-f]{64}$")
+
+// IsDigest checks whether the string is a sha256 digest, e.g. 'sha256:<64 hex>'.
+func IsDigest(s string) bool {
+	return digestPattern.MatchString(s)
+}
+
+// Reference references an image in a registry, by tag or by digest.
+type Reference struct {
+	// Registry is host of the registry, e.g. 'docker.io', 'localhost:5000'.
+	Registry string
+	// Repository is the repository in the registry, e.g. 'library/alpine'.
+	Repository string
+	// Tag of the image, it's ignored if digest is set.
+	Tag string
+	// Digest of the image manifest.
+	Digest string
+}
+
+// ParseReference parses image name like '[<registry>/]<repository>[:<tag>][@<digest>]'. As what
+// docker does, the first component is regarded as registry only when it contains '.' or ':', or
+// it's 'localhost'. Images without registry are in docker.io, and official images are in the
+// 'library' namespace.
+func ParseReference(image string) (*Reference, error) {
+	ref := &Reference{}
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		ref.Digest = name[i+1:]
+		name = name[:i]
+		if !IsDigest(ref.Digest) {
+			return nil, fmt.Errorf("invalid digest in image %s", image)
+		}
+	}
+
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		ref.Tag = name[i+1:]
+		name = name[:i]
+	}
+
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		ref.Registry = parts[0]
+		ref.Repository = parts[1]
+	} else {
+		ref.Registry = DefaultRegistry
+		ref.Repository = name
+	}
+	if ref.Repository == "" || ref.Repository != strings.ToLower(ref.Repository) {
+		return nil, fmt.Errorf("invalid repository in image %s", image)
+	}
+	if ref.Registry == "index.docker.io" {
+		ref.Registry = DefaultRegistry
+	}
+	if ref.Registry == DefaultRegistry && !strings.Contains(ref.Repository, "/") {
+		ref.Repository = "library/" + ref.Repository
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = defaultTag
+	}
+
+	return ref, nil
+}
+
+// Name gets the full repository name, e.g. 'docker.io/library/alpine'.
+func (r *Reference) Name() string {
+	return r.Registry + "/" + r.Repository
+}
+
+// String gets the full image name.
+func (r *Reference) String() string {
+	s := r.Name()
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// WithDigest gets reference to the image in the same repository with the given digest.
+func (r *Reference) WithDigest(digest string) *Reference {
+	return &Reference{
+		Registry:   r.Registry,
+		Repository: r.Repository,
+		Digest:     digest,
+	}
+}
+
+// reference gets the manifest reference used in registry API, digest takes precedence over tag.
+func (r *Reference) reference() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
+
+// host gets host of the registry API.
+func (r *Reference) host() string {
+	if r.Registry == DefaultRegistry {
+		return defaultRegistryHost
+	}
+	return r.Registry
+}
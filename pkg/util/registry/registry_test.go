@@ -0,0 +1,250 @@
+package registry
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseReference(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	cases := []struct {
+		image    string
+		expected Reference
+	}{
+		{"alpine", Reference{Registry: "docker.io", Repository: "library/alpine", Tag: "latest"}},
+		{"alpine:3.8", Reference{Registry: "docker.io", Repository: "library/alpine", Tag: "3.8"}},
+		{"caicloud/cyclone:v1", Reference{Registry: "docker.io", Repository: "caicloud/cyclone", Tag: "v1"}},
+		{"index.docker.io/caicloud/cyclone", Reference{Registry: "docker.io", Repository: "caicloud/cyclone", Tag: "latest"}},
+		{"localhost/cyclone", Reference{Registry: "localhost", Repository: "cyclone", Tag: "latest"}},
+		{"localhost:5000/a/b:v1", Reference{Registry: "localhost:5000", Repository: "a/b", Tag: "v1"}},
+		{"cargo.caicloud.io/release/cyclone@" + digest, Reference{Registry: "cargo.caicloud.io", Repository: "release/cyclone", Digest: digest}},
+		{"cargo.caicloud.io/release/cyclone:v1@" + digest, Reference{Registry: "cargo.caicloud.io", Repository: "release/cyclone", Tag: "v1", Digest: digest}},
+	}
+	for _, c := range cases {
+		ref, err := ParseReference(c.image)
+		assert.Nil(t, err, c.image)
+		assert.Equal(t, c.expected, *ref, c.image)
+	}
+
+	for _, image := range []string{"alpine@sha256:123", "Caicloud/cyclone", "docker.io/"} {
+		_, err := ParseReference(image)
+		assert.NotNil(t, err, image)
+	}
+
+	ref, _ := ParseReference("cyclone:v1")
+	assert.Equal(t, "docker.io/library/cyclone:v1", ref.String())
+	assert.Equal(t, "docker.io/library/cyclone@"+digest, ref.WithDigest(digest).String())
+	assert.Equal(t, "registry-1.docker.io", ref.host())
+}
+
+func TestLoadDockerConfig(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "docker")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.json")
+	credentials, err := LoadDockerConfig(path)
+	assert.Nil(t, err)
+	assert.Empty(t, credentials)
+
+	ioutil.WriteFile(path, []byte(`{"auths":{
+		"https://index.docker.io/v1/":{"auth":"dXNlcjpwYXNz"},
+		"cargo.caicloud.io":{"username":"u","password":"p"}}}`), 0644)
+	credentials, err = LoadDockerConfig(path)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]Credential{
+		"docker.io":         {Username: "user", Password: "pass"},
+		"cargo.caicloud.io": {Username: "u", Password: "p"},
+	}, credentials)
+}
+
+func TestParseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:a/b:pull,push"`)
+	assert.Equal(t, "Bearer", scheme)
+	assert.Equal(t, map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:a/b:pull,push",
+	}, params)
+
+	scheme, _ = parseChallenge(`Basic realm="registry"`)
+	assert.Equal(t, "Basic", scheme)
+}
+
+// fakeRegistry is an in-memory registry requiring token auth.
+type fakeRegistry struct {
+	lock      sync.Mutex
+	url       string
+	blobs     map[string][]byte
+	manifests map[string]*Manifest
+	uploads   int
+}
+
+func newFakeRegistry() (*fakeRegistry, *httptest.Server) {
+	r := &fakeRegistry{
+		blobs:     make(map[string][]byte),
+		manifests: make(map[string]*Manifest),
+	}
+	server := httptest.NewServer(r)
+	r.url = server.URL
+	return r, server
+}
+
+func (r *fakeRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if req.URL.Path == "/token" {
+		if user, pass, _ := req.BasicAuth(); user != "user" || pass != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"token":"secret"}`)
+		return
+	}
+	if req.Header.Get("Authorization") != "Bearer secret" {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="fake"`, r.url))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	switch {
+	case strings.Contains(path, "/blobs/uploads/"):
+		if req.Method == http.MethodPost {
+			r.uploads++
+			w.Header().Set("Location", fmt.Sprintf("/v2/%s%d", path, r.uploads))
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		content, _ := ioutil.ReadAll(req.Body)
+		if Digest(content) != req.URL.Query().Get("digest") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.blobs[Digest(content)] = content
+		w.WriteHeader(http.StatusCreated)
+	case strings.Contains(path, "/blobs/"):
+		content, ok := r.blobs[path[strings.LastIndex(path, "/")+1:]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(content)
+	case strings.Contains(path, "/manifests/"):
+		if req.Method == http.MethodPut {
+			content, _ := ioutil.ReadAll(req.Body)
+			m := &Manifest{MediaType: req.Header.Get("Content-Type"), Digest: Digest(content), Content: content}
+			r.manifests[path] = m
+			r.manifests[path[:strings.LastIndex(path, "/")+1]+m.Digest] = m
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		m, ok := r.manifests[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", m.MediaType)
+		w.Header().Set("Docker-Content-Digest", m.Digest)
+		w.Write(m.Content)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// writeDockerTarball writes an image tarball in 'docker save' format with uncompressed layers.
+func writeDockerTarball(t *testing.T, path, tag string) {
+	var layer bytes.Buffer
+	lw := tar.NewWriter(&layer)
+	lw.WriteHeader(&tar.Header{Name: "hello.txt", Mode: 0644, Size: 5, Typeflag: tar.TypeReg})
+	lw.Write([]byte("hello"))
+	lw.Close()
+
+	config := []byte(`{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":["` + Digest(layer.Bytes()) + `"]}}`)
+	manifest, _ := json.Marshal([]dockerManifest{{
+		Config:   "config.json",
+		RepoTags: []string{tag},
+		Layers:   []string{"layer/layer.tar"},
+	}})
+
+	f, err := os.Create(path)
+	assert.Nil(t, err)
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	for _, file := range []struct {
+		name    string
+		content []byte
+	}{
+		{"config.json", config},
+		{"layer/layer.tar", layer.Bytes()},
+		{"manifest.json", manifest},
+	} {
+		assert.Nil(t, writeTarFile(tw, file.name, int64(len(file.content)), bytes.NewReader(file.content)))
+	}
+	assert.Nil(t, tw.Close())
+}
+
+func TestTarballAndCopy(t *testing.T) {
+	registry, server := newFakeRegistry()
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	dir, _ := ioutil.TempDir("", "image")
+	defer os.RemoveAll(dir)
+	tarball := filepath.Join(dir, "image.tar")
+	writeDockerTarball(t, tarball, host+"/cyclone/app:v1")
+
+	// Unauthorized without credentials.
+	client := NewClient(nil)
+	client.Insecure = true
+	ref, _ := ParseReference(host + "/cyclone/app:v1")
+	_, err := client.PushTarball(ref, tarball)
+	assert.NotNil(t, err)
+
+	client.Credentials[host] = Credential{Username: "user", Password: "pass"}
+	digest, err := client.PushTarball(ref, tarball)
+	assert.Nil(t, err)
+	assert.True(t, IsDigest(digest))
+	assert.Len(t, registry.blobs, 2)
+	// Temporary directory for extraction is removed.
+	files, _ := ioutil.ReadDir(dir)
+	assert.Len(t, files, 1)
+
+	resolved, err := client.Resolve(ref)
+	assert.Nil(t, err)
+	assert.Equal(t, digest, resolved)
+
+	// Image saved from registry can be pushed back unchanged.
+	saved := filepath.Join(dir, "saved.tar")
+	resolved, err = client.SaveTarball(ref, DefaultPlatform, saved)
+	assert.Nil(t, err)
+	assert.Equal(t, digest, resolved)
+	ref2, _ := ParseReference(host + "/cyclone/app:v2")
+	pushed, err := client.PushTarball(ref2, saved)
+	assert.Nil(t, err)
+	assert.Equal(t, digest, pushed)
+
+	// Copy between repositories.
+	ref3, _ := ParseReference(host + "/other/app:v1")
+	copied, err := client.Copy(ref.WithDigest(digest), ref3)
+	assert.Nil(t, err)
+	assert.Equal(t, digest, copied)
+	resolved, err = client.Resolve(ref3)
+	assert.Nil(t, err)
+	assert.Equal(t, digest, resolved)
+
+	_, err = client.Resolve(ref.WithDigest("sha256:" + strings.Repeat("0", 64)))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,440 @@
+/*
+Copyright 2018 caicloud authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultPlatform is the platform selected from multi-platform images when saving tarballs.
+const DefaultPlatform = "linux/amd64"
+
+// dockerManifest is an entry of manifest.json in tarballs created by 'docker save'.
+type dockerManifest struct {
+	Config   string   `json:"Config"`
+	RepoTags []string `json:"RepoTags"`
+	Layers   []string `json:"Layers"`
+}
+
+// layout is an image loaded from tarball, blobs are files in the layout directory.
+type layout struct {
+	dir string
+	// blobs maps digests to files, blobs not in it are in 'blobs/<algorithm>/<hex>' as what OCI
+	// image layout defines.
+	blobs map[string]string
+}
+
+func (l *layout) blobPath(digest string) string {
+	if path, ok := l.blobs[digest]; ok {
+		return path
+	}
+	return filepath.Join(l.dir, "blobs", strings.Replace(digest, ":", string(os.PathSeparator), 1))
+}
+
+func (l *layout) open(digest string) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return os.Open(l.blobPath(digest))
+	}
+}
+
+// PushTarball pushes image in the tarball to the image reference, digest of the pushed manifest
+// is returned. Both tarballs created by 'docker save' and OCI image layout tarballs are supported,
+// and they can be gzip compressed. Path can also be a directory of extracted tarball.
+func (c *Client) PushTarball(ref *Reference, path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	dir := path
+	if !info.IsDir() {
+		if dir, err = ioutil.TempDir(filepath.Dir(path), ".image-"); err != nil {
+			return "", err
+		}
+		defer os.RemoveAll(dir)
+		if err := extract(path, dir); err != nil {
+			return "", fmt.Errorf("extract %s error: %v", path, err)
+		}
+	}
+
+	l := &layout{dir: dir, blobs: make(map[string]string)}
+	manifest, err := l.load(ref)
+	if err != nil {
+		return "", err
+	}
+	return c.pushManifest(ref, l, manifest)
+}
+
+// load loads the top level manifest of the image to push.
+func (l *layout) load(ref *Reference) (*Manifest, error) {
+	if content, err := ioutil.ReadFile(filepath.Join(l.dir, "index.json")); err == nil {
+		index := &Index{}
+		if err := json.Unmarshal(content, index); err != nil {
+			return nil, fmt.Errorf("parse index.json error: %v", err)
+		}
+		if len(index.Manifests) != 1 {
+			return &Manifest{MediaType: MediaTypeOCIIndex, Digest: Digest(content), Content: content}, nil
+		}
+		desc := index.Manifests[0]
+		content, err := ioutil.ReadFile(l.blobPath(desc.Digest))
+		if err != nil {
+			return nil, err
+		}
+		return &Manifest{MediaType: desc.MediaType, Digest: desc.Digest, Content: content}, nil
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(l.dir, "manifest.json"))
+	if err != nil {
+		return nil, fmt.Errorf("neither index.json nor manifest.json found in image tarball")
+	}
+	var entries []dockerManifest
+	if err := json.Unmarshal(content, &entries); err != nil {
+		return nil, fmt.Errorf("parse manifest.json error: %v", err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no image found in manifest.json")
+	}
+	return l.convert(selectEntry(entries, ref))
+}
+
+// selectEntry selects the image tagged as the reference from images in tarball, the first image
+// is selected if none matches.
+func selectEntry(entries []dockerManifest, ref *Reference) dockerManifest {
+	for _, e := range entries {
+		for _, tag := range e.RepoTags {
+			if r, err := ParseReference(tag); err == nil && r.Name() == ref.Name() && r.Tag == ref.Tag {
+				return e
+			}
+		}
+	}
+	return entries[0]
+}
+
+// convert converts image in 'docker save' format to docker manifest v2 schema 2. Uncompressed
+// layers are compressed as registries expect.
+func (l *layout) convert(entry dockerManifest) (*Manifest, error) {
+	config, err := l.addBlob(filepath.Join(l.dir, entry.Config), MediaTypeDockerConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	image := &ImageManifest{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeDockerManifest,
+		Config:        config,
+	}
+	for i, layer := range entry.Layers {
+		path := filepath.Join(l.dir, layer)
+		compressed, err := isGzip(path)
+		if err != nil {
+			return nil, err
+		}
+		if !compressed {
+			gz := filepath.Join(l.dir, fmt.Sprintf("layer-%d.tar.gz", i))
+			if err := compress(path, gz); err != nil {
+				return nil, err
+			}
+			path = gz
+		}
+
+		desc, err := l.addBlob(path, MediaTypeDockerLayer)
+		if err != nil {
+			return nil, err
+		}
+		image.Layers = append(image.Layers, desc)
+	}
+
+	content, err := json.Marshal(image)
+	if err != nil {
+		return nil, err
+	}
+	return &Manifest{MediaType: MediaTypeDockerManifest, Digest: Digest(content), Content: content}, nil
+}
+
+// addBlob computes descriptor of the file and records it as a blob.
+func (l *layout) addBlob(path, mediaType string) (Descriptor, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Descriptor{}, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return Descriptor{}, err
+	}
+	desc := Descriptor{
+		MediaType: mediaType,
+		Size:      size,
+		Digest:    "sha256:" + hex.EncodeToString(h.Sum(nil)),
+	}
+	l.blobs[desc.Digest] = path
+	return desc, nil
+}
+
+// pushManifest pushes the manifest and blobs it references, manifests in index are pushed by
+// digest before the index.
+func (c *Client) pushManifest(ref *Reference, l *layout, manifest *Manifest) (string, error) {
+	if IsIndex(manifest.MediaType) {
+		index := &Index{}
+		if err := json.Unmarshal(manifest.Content, index); err != nil {
+			return "", fmt.Errorf("parse index error: %v", err)
+		}
+		for _, desc := range index.Manifests {
+			content, err := ioutil.ReadFile(l.blobPath(desc.Digest))
+			if err != nil {
+				return "", err
+			}
+			child := &Manifest{MediaType: desc.MediaType, Digest: desc.Digest, Content: content}
+			if _, err := c.pushManifest(ref.WithDigest(desc.Digest), l, child); err != nil {
+				return "", err
+			}
+		}
+	} else {
+		image := &ImageManifest{}
+		if err := json.Unmarshal(manifest.Content, image); err != nil {
+			return "", fmt.Errorf("parse manifest error: %v", err)
+		}
+		for _, desc := range append([]Descriptor{image.Config}, image.Layers...) {
+			if len(desc.URLs) > 0 {
+				continue
+			}
+			if err := c.PutBlob(ref, desc, l.open(desc.Digest)); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	return c.PutManifest(ref, manifest)
+}
+
+// SaveTarball saves the image to a tarball in 'docker save' format, which can be loaded by
+// 'docker load' or pushed by PushTarball. For multi-platform images, the image of the platform,
+// e.g. 'linux/amd64', is saved. Digest of the image manifest referenced by ref is returned.
+func (c *Client) SaveTarball(ref *Reference, platform, path string) (string, error) {
+	manifest, err := c.GetManifest(ref)
+	if err != nil {
+		return "", err
+	}
+	digest := manifest.Digest
+
+	if IsIndex(manifest.MediaType) {
+		index := &Index{}
+		if err := json.Unmarshal(manifest.Content, index); err != nil {
+			return "", fmt.Errorf("parse index of %s error: %v", ref.String(), err)
+		}
+		var selected string
+		for _, m := range index.Manifests {
+			if m.Platform != nil && (m.Platform.String() == platform || m.Platform.OS+"/"+m.Platform.Architecture == platform) {
+				selected = m.Digest
+				break
+			}
+		}
+		if selected == "" {
+			return "", fmt.Errorf("platform %s not found in image %s", platform, ref.String())
+		}
+		if manifest, err = c.GetManifest(ref.WithDigest(selected)); err != nil {
+			return "", err
+		}
+	}
+
+	image := &ImageManifest{}
+	if err := json.Unmarshal(manifest.Content, image); err != nil {
+		return "", fmt.Errorf("parse manifest of %s error: %v", ref.String(), err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	tw := tar.NewWriter(f)
+
+	entry := dockerManifest{
+		Config: strings.TrimPrefix(image.Config.Digest, "sha256:") + ".json",
+	}
+	if ref.Tag != "" {
+		entry.RepoTags = []string{ref.Name() + ":" + ref.Tag}
+	}
+	if err := c.saveBlob(tw, ref, image.Config, entry.Config); err != nil {
+		return "", err
+	}
+	for _, layer := range image.Layers {
+		if len(layer.URLs) > 0 {
+			return "", fmt.Errorf("foreign layer %s not supported", layer.Digest)
+		}
+		name := strings.TrimPrefix(layer.Digest, "sha256:") + "/layer.tar"
+		if err := c.saveBlob(tw, ref, layer, name); err != nil {
+			return "", err
+		}
+		entry.Layers = append(entry.Layers, name)
+	}
+
+	content, err := json.Marshal([]dockerManifest{entry})
+	if err != nil {
+		return "", err
+	}
+	if err := writeTarFile(tw, "manifest.json", int64(len(content)), bytes.NewReader(content)); err != nil {
+		return "", err
+	}
+	if err := tw.Close(); err != nil {
+		return "", err
+	}
+
+	return digest, nil
+}
+
+// saveBlob downloads the blob to the tarball, and verifies its digest.
+func (c *Client) saveBlob(tw *tar.Writer, ref *Reference, desc Descriptor, name string) error {
+	blob, err := c.GetBlob(ref, desc.Digest)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+
+	h := sha256.New()
+	if err := writeTarFile(tw, name, desc.Size, io.TeeReader(blob, h)); err != nil {
+		return err
+	}
+	if digest := "sha256:" + hex.EncodeToString(h.Sum(nil)); digest != desc.Digest {
+		return fmt.Errorf("digest of blob %s mismatch, got %s", desc.Digest, digest)
+	}
+	return nil
+}
+
+func writeTarFile(tw *tar.Writer, name string, size int64, r io.Reader) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     size,
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return err
+	}
+	_, err := io.CopyN(tw, r, size)
+	return err
+}
+
+// extract extracts the tarball, which may be gzip compressed, to the directory. Entries are kept
+// inside the directory, links are extracted as symbolic links and those pointing outside are
+// ignored.
+func extract(path, dir string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = bufio.NewReader(f)
+	if magic, err := r.(*bufio.Reader).Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dir, filepath.Clean("/"+hdr.Name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(out, tr)
+			out.Close()
+			if err != nil {
+				return err
+			}
+		case tar.TypeSymlink, tar.TypeLink:
+			source := filepath.Join(dir, filepath.Clean("/"+hdr.Linkname))
+			if hdr.Typeflag == tar.TypeSymlink && !filepath.IsAbs(hdr.Linkname) {
+				source = filepath.Join(filepath.Dir(target), hdr.Linkname)
+			}
+			if !strings.HasPrefix(source, filepath.Clean(dir)+string(os.PathSeparator)) {
+				continue
+			}
+			if err := os.Symlink(source, target); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func isGzip(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	magic := make([]byte, 2)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return false, nil
+	}
+	return magic[0] == 0x1f && magic[1] == 0x8b, nil
+}
+
+func compress(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		return err
+	}
+	return gz.Close()
+}
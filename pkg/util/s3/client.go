@@ -0,0 +1,163 @@
+/*
+Copyright 2018 caicloud authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package s3 is a minimal client of S3 compatible object storages. Path style addressing is
+// used, so that it works with both AWS S3 and self-hosted storages like MinIO.
+package s3
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// DefaultRegion is the default region of S3 buckets.
+const DefaultRegion = "us-east-1"
+
+// Client sends signed requests to S3 compatible object storage.
+type Client struct {
+	// Endpoint of the object storage, e.g. s3.amazonaws.com, minio.default:9000
+	Endpoint string
+	// Region of the bucket, DefaultRegion is used if empty.
+	Region string
+	// AccessKey to access the object storage
+	AccessKey string
+	// SecretKey to access the object storage
+	SecretKey string
+	// Insecure indicates whether to use plain HTTP instead of HTTPS
+	Insecure bool
+	// HTTPClient sends requests, http.DefaultClient is used if nil.
+	HTTPClient *http.Client
+}
+
+// Error is error returned from object storage.
+type Error struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("object storage error (%d): %s %s", e.StatusCode, e.Code, e.Message)
+}
+
+// IsNotFound checks whether the error is caused by object or bucket not found.
+func IsNotFound(err error) bool {
+	e, ok := err.(*Error)
+	return ok && e.StatusCode == http.StatusNotFound
+}
+
+// GetRegion gets region of the bucket.
+func (c *Client) GetRegion() string {
+	if c.Region == "" {
+		return DefaultRegion
+	}
+	return c.Region
+}
+
+// DoBytes sends request with bytes payload.
+func (c *Client) DoBytes(method, bucket, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	sum := sha256.Sum256(body)
+	return c.Do(method, bucket, key, query, header, bytes.NewReader(body), int64(len(body)), hex.EncodeToString(sum[:]))
+}
+
+// Do sends a signed request to the object storage, 'key' is the object key, and if it's empty,
+// the request is sent to the bucket. Error is returned for non 2xx responses.
+func (c *Client) Do(method, bucket, key string, query url.Values, header http.Header, body io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	scheme := "https"
+	if c.Insecure {
+		scheme = "http"
+	}
+	uri := "/" + bucket
+	if key != "" {
+		uri += "/" + key
+	}
+	escaped := Escape(uri, false)
+	target := fmt.Sprintf("%s://%s%s", scheme, c.Endpoint, escaped)
+	if len(query) > 0 {
+		target += "?" + CanonicalQuery(query)
+	}
+
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	SignV4(req, escaped, payloadHash, c.AccessKey, c.SecretKey, c.GetRegion(), time.Now())
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		e := &Error{StatusCode: resp.StatusCode}
+		if data, err := ioutil.ReadAll(resp.Body); err == nil && len(data) > 0 {
+			xml.Unmarshal(data, e)
+		}
+		return nil, e
+	}
+
+	return resp, nil
+}
+
+// GetObject gets the object, 'query' can be used to select object version. The caller should
+// close the response body.
+func (c *Client) GetObject(bucket, key string, query url.Values) (*http.Response, error) {
+	return c.Do(http.MethodGet, bucket, key, query, nil, nil, 0, EmptyPayloadHash)
+}
+
+// PutFile uploads the file as the object. Sha256 checksum of the file is computed for signing,
+// and is returned together with the response headers.
+func (c *Client) PutFile(bucket, key, path string, header http.Header) (http.Header, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil))
+	resp, err := c.Do(http.MethodPut, bucket, key, nil, header, f, size, sum)
+	if err != nil {
+		return nil, "", err
+	}
+	resp.Body.Close()
+	return resp.Header, sum, nil
+}
@@ -0,0 +1,70 @@
+package websocket
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// LogOffset is sent by the log receiver in text messages, once the connection established and
+// after each chunk handled, it tells the sender how many bytes of the log have been stored,
+// so the sender can resume from there.
+type LogOffset struct {
+	Offset int64 `json:"offset"`
+}
+
+// logChunkHeaderSize is size of the header of log chunks, it's the big-endian offset of the
+// chunk in the whole log.
+const logChunkHeaderSize = 8
+
+const (
+	// MaxLogChunkSize is max size of log data in a chunk, larger chunks are rejected when decoded.
+	MaxLogChunkSize = 1024 * 1024
+	// MaxLogMessageSize is max size of log chunk messages, receivers should limit size of messages
+	// read from the connection with it. It leaves room for gzip overhead of incompressible data.
+	MaxLogMessageSize = 2 * MaxLogChunkSize
+)
+
+// EncodeLogChunk encodes a chunk of log starting at the offset to a binary message, data is
+// gzip compressed.
+func EncodeLogChunk(offset int64, data []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(make([]byte, logChunkHeaderSize))
+	binary.BigEndian.PutUint64(buf.Bytes(), uint64(offset))
+
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// DecodeLogChunk decodes a binary message encoded by EncodeLogChunk. Error is returned if the
+// decompressed data is larger than MaxLogChunkSize.
+func DecodeLogChunk(message []byte) (int64, []byte, error) {
+	if len(message) < logChunkHeaderSize {
+		return 0, nil, fmt.Errorf("log chunk too short: %d bytes", len(message))
+	}
+	offset := int64(binary.BigEndian.Uint64(message[:logChunkHeaderSize]))
+
+	r, err := gzip.NewReader(bytes.NewReader(message[logChunkHeaderSize:]))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer r.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(r, MaxLogChunkSize+1))
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(data) > MaxLogChunkSize {
+		return 0, nil, fmt.Errorf("log chunk too large: more than %d bytes", MaxLogChunkSize)
+	}
+
+	return offset, data, nil
+}
@@ -0,0 +1,27 @@
+package websocket
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogChunk(t *testing.T) {
+	message, err := EncodeLogChunk(1024, []byte("line 1\n"))
+	assert.Nil(t, err)
+	offset, data, err := DecodeLogChunk(message)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1024), offset)
+	assert.Equal(t, "line 1\n", string(data))
+
+	_, _, err = DecodeLogChunk(message[:logChunkHeaderSize-1])
+	assert.NotNil(t, err)
+
+	// Chunks decompressed to more than the limit are rejected, though the message is small.
+	message, err = EncodeLogChunk(0, bytes.Repeat([]byte("a"), MaxLogChunkSize+1))
+	assert.Nil(t, err)
+	assert.True(t, len(message) < MaxLogMessageSize)
+	_, _, err = DecodeLogChunk(message)
+	assert.NotNil(t, err)
+}
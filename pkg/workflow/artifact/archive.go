@@ -0,0 +1,184 @@
+package artifact
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Tar archives the file or directory 'src' to the writer. Entries in the archive are
+// relative to parent directory of 'src', so the base name of 'src' is kept. Files are
+// walked in lexical order, so the same content always results in the same archive.
+func Tar(src string, w io.Writer) error {
+	tw := tar.NewWriter(w)
+	if err := writeTar(tw, src); err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+// writeTar writes the file or directory 'src' to the tar writer, entries are relative to parent
+// directory of 'src'.
+func writeTar(tw *tar.Writer, src string) error {
+	parent := filepath.Dir(filepath.Clean(src))
+	return filepath.Walk(src, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name, err := filepath.Rel(parent, file)
+		if err != nil {
+			return err
+		}
+
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(file); err != nil {
+				return err
+			}
+		}
+
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		// Clear fields that would make archives of the same content differ.
+		header.ModTime = header.ModTime.Truncate(1e9)
+		header.AccessTime = header.ModTime
+		header.ChangeTime = header.ModTime
+		header.Uid, header.Gid, header.Uname, header.Gname = 0, 0, "", ""
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
+
+// Untar extracts tar stream from the reader to directory 'dst'. Entries that would be
+// extracted out of 'dst' are rejected. Symbolic links in the archive may point anywhere, but
+// real paths are verified before each entry is written, so no entry is written through a link
+// to outside of 'dst'.
+func Untar(r io.Reader, dst string) error {
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+	root, err := filepath.EvalSymlinks(dst)
+	if err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(root, filepath.FromSlash(header.Name))
+		if !within(root, target) {
+			return fmt.Errorf("invalid entry %s in archive", header.Name)
+		}
+		// Parent directories may be links created by previous entries, verify where they really are.
+		if err := verifyRealPath(root, filepath.Dir(target)); err != nil {
+			return fmt.Errorf("invalid entry %s in archive: %v", header.Name, err)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := verifyRealPath(root, target); err != nil {
+				return fmt.Errorf("invalid entry %s in archive: %v", header.Name, err)
+			}
+			if err := os.MkdirAll(target, os.FileMode(header.Mode)|0700); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := removeLink(target); err != nil {
+				return err
+			}
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			// Replace existing link instead of writing through it.
+			if err := removeLink(target); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_RDWR|os.O_TRUNC, os.FileMode(header.Mode))
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// within checks whether path is 'root' or inside it lexically.
+func within(root, path string) bool {
+	return path == root || strings.HasPrefix(path, root+string(os.PathSeparator))
+}
+
+// verifyRealPath verifies that real path of 'path' is inside 'root', links are resolved from
+// the deepest existing ancestor of 'path'.
+func verifyRealPath(root, path string) error {
+	existing, rest := path, ""
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+
+	real, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return err
+	}
+	if !within(root, filepath.Join(real, rest)) {
+		return fmt.Errorf("%s is out of %s", path, root)
+	}
+	return nil
+}
+
+// removeLink removes the file if it's a symbolic link.
+func removeLink(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return nil
+	}
+	return os.Remove(path)
+}
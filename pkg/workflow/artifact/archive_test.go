@@ -0,0 +1,95 @@
+package artifact
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tarEntry struct {
+	name     string
+	typeflag byte
+	link     string
+	content  string
+}
+
+func tarball(entries []tarEntry) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, e := range entries {
+		tw.WriteHeader(&tar.Header{
+			Name:     e.name,
+			Typeflag: e.typeflag,
+			Linkname: e.link,
+			Mode:     0644,
+			Size:     int64(len(e.content)),
+		})
+		tw.Write([]byte(e.content))
+	}
+	tw.Close()
+	return buf
+}
+
+func TestUntar(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "untar")
+	defer os.RemoveAll(dir)
+	outside := filepath.Join(dir, "outside")
+	dst := filepath.Join(dir, "dst")
+	os.MkdirAll(outside, 0755)
+
+	err := Untar(tarball([]tarEntry{
+		{name: "out/", typeflag: tar.TypeDir},
+		{name: "out/a.txt", typeflag: tar.TypeReg, content: "a"},
+		{name: "out/lib", typeflag: tar.TypeSymlink, link: "."},
+		{name: "out/lib/b.txt", typeflag: tar.TypeReg, content: "b"},
+	}), dst)
+	assert.Nil(t, err)
+	data, _ := ioutil.ReadFile(filepath.Join(dst, "out/a.txt"))
+	assert.Equal(t, "a", string(data))
+	data, _ = ioutil.ReadFile(filepath.Join(dst, "out/b.txt"))
+	assert.Equal(t, "b", string(data))
+
+	cases := [][]tarEntry{
+		{{name: "../x.txt", typeflag: tar.TypeReg, content: "x"}},
+		{
+			{name: "link", typeflag: tar.TypeSymlink, link: outside},
+			{name: "link/x.txt", typeflag: tar.TypeReg, content: "x"},
+		},
+		{
+			{name: "link", typeflag: tar.TypeSymlink, link: "../outside"},
+			{name: "link/sub/", typeflag: tar.TypeDir},
+		},
+		{
+			{name: "a", typeflag: tar.TypeSymlink, link: "."},
+			{name: "a/b", typeflag: tar.TypeSymlink, link: ".."},
+			{name: "a/b/outside/x.txt", typeflag: tar.TypeReg, content: "x"},
+		},
+	}
+	for i, c := range cases {
+		assert.NotNil(t, Untar(tarball(c), filepath.Join(dir, "dst"+string(rune('0'+i)))), "case %d", i)
+	}
+	files, _ := ioutil.ReadDir(outside)
+	assert.Empty(t, files)
+}
+
+func TestUntarReplaceLink(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "untar")
+	defer os.RemoveAll(dir)
+	outside := filepath.Join(dir, "outside.txt")
+	ioutil.WriteFile(outside, []byte("outside"), 0644)
+
+	err := Untar(tarball([]tarEntry{
+		{name: "x.txt", typeflag: tar.TypeSymlink, link: outside},
+		{name: "x.txt", typeflag: tar.TypeReg, content: "x"},
+	}), filepath.Join(dir, "dst"))
+	assert.Nil(t, err)
+	data, _ := ioutil.ReadFile(outside)
+	assert.Equal(t, "outside", string(data))
+	data, _ = ioutil.ReadFile(filepath.Join(dir, "dst", "x.txt"))
+	assert.Equal(t, "x", string(data))
+}
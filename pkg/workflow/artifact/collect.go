@@ -0,0 +1,304 @@
+package artifact
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+)
+
+// CopyFunc copies file or directory 'src' in workload container to local directory 'dst'.
+type CopyFunc func(src, dst string) error
+
+// Paths gets all paths of the output data.
+func Paths(p string, options v1alpha1.OutputOptions) []string {
+	var paths []string
+	if p != "" {
+		paths = append(paths, p)
+	}
+	for _, p := range options.Paths {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// IsSimple checks whether the output is a single path without glob patterns, excludes and archive,
+// in which case, the file or directory is collected as it is.
+func IsSimple(p string, options v1alpha1.OutputOptions) bool {
+	paths := Paths(p, options)
+	return len(paths) == 1 && !HasGlob(paths[0]) && len(options.Excludes) == 0 && options.Archive == nil
+}
+
+// OutputName gets name of the collected file or directory of an output. For simple output, it's
+// base name of the path, for archived output, it's name of the archive, otherwise, it's name of the
+// output, where all matched files are collected into.
+func OutputName(name, p string, options v1alpha1.OutputOptions) string {
+	if options.Archive != nil {
+		return ArchiveName(name, options.Archive)
+	}
+	if IsSimple(p, options) {
+		return path.Base(p)
+	}
+	return name
+}
+
+// ArchiveName gets name of the archive file.
+func ArchiveName(name string, archive *v1alpha1.Archive) string {
+	if archive.Name != "" {
+		return archive.Name
+	}
+	if archive.Format == v1alpha1.ArchiveTar {
+		return name + ".tar"
+	}
+	return name + ".tar.gz"
+}
+
+// ArchiveFormat gets format of the archive.
+func ArchiveFormat(archive *v1alpha1.Archive) v1alpha1.ArchiveFormat {
+	if archive.Format != "" {
+		return archive.Format
+	}
+	if strings.HasSuffix(archive.Name, ".tar") {
+		return v1alpha1.ArchiveTar
+	}
+	return v1alpha1.ArchiveTarGz
+}
+
+// HasGlob checks whether the path contains glob pattern.
+func HasGlob(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
+
+// StaticPrefix gets the leading part of the path that contains no glob pattern.
+func StaticPrefix(pattern string) string {
+	parts := strings.Split(pattern, "/")
+	for i, part := range parts {
+		if HasGlob(part) {
+			prefix := strings.Join(parts[:i], "/")
+			if prefix == "" && strings.HasPrefix(pattern, "/") {
+				return "/"
+			}
+			if prefix == "" {
+				return "."
+			}
+			return prefix
+		}
+	}
+	return pattern
+}
+
+// Match checks whether the slash separated path matches the pattern, '**' in pattern matches zero
+// or more path segments, and other segments are matched with path.Match.
+func Match(pattern, name string) bool {
+	return matchSegments(strings.Split(path.Clean(pattern), "/"), strings.Split(path.Clean(name), "/"))
+}
+
+func matchSegments(patterns, names []string) bool {
+	for len(patterns) > 0 {
+		if patterns[0] == "**" {
+			for i := 0; i <= len(names); i++ {
+				if matchSegments(patterns[1:], names[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(names) == 0 {
+			return false
+		}
+		if ok, err := path.Match(patterns[0], names[0]); err != nil || !ok {
+			return false
+		}
+		patterns, names = patterns[1:], names[1:]
+	}
+
+	return len(names) == 0
+}
+
+// excluded checks whether a file should be excluded, 'rel' is the slash separated path relative to
+// static part of the pattern.
+func excluded(excludes []string, rel string) bool {
+	for _, e := range excludes {
+		if Match(e, rel) || Match(e, path.Base(rel)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Collect collects output data from workload container to local directory 'dst' with the copy
+// function, path of the collected file or directory is returned, which is <dst>/<output name>.
+func Collect(copy CopyFunc, name, p string, options v1alpha1.OutputOptions, dst string) (string, error) {
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return "", err
+	}
+
+	if IsSimple(p, options) {
+		if err := copy(p, dst); err != nil {
+			return "", err
+		}
+		return filepath.Join(dst, path.Base(p)), nil
+	}
+
+	// Stage files in 'dst', so that the collected directory can be renamed to the target, 'dst'
+	// is usually on a volume other than the container root file system, e.g. PVC.
+	staging, err := ioutil.TempDir(dst, ".collect-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(staging)
+
+	collected := filepath.Join(staging, "collected")
+	if err := os.MkdirAll(collected, 0755); err != nil {
+		return "", err
+	}
+
+	for i, pattern := range Paths(p, options) {
+		prefix := StaticPrefix(pattern)
+		copied := filepath.Join(staging, fmt.Sprintf("%d", i))
+		if err := os.MkdirAll(copied, 0755); err != nil {
+			return "", err
+		}
+		if err := copy(prefix, copied); err != nil {
+			return "", err
+		}
+
+		if err := collect(filepath.Join(copied, path.Base(prefix)), prefix, pattern, options.Excludes, collected); err != nil {
+			return "", err
+		}
+	}
+
+	if options.Archive == nil {
+		target := filepath.Join(dst, name)
+		if err := os.RemoveAll(target); err != nil {
+			return "", err
+		}
+		return target, os.Rename(collected, target)
+	}
+
+	target := filepath.Join(dst, ArchiveName(name, options.Archive))
+	f, err := os.Create(target)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	return target, Pack(collected, ArchiveFormat(options.Archive), f)
+}
+
+// collect copies files under 'root' that match the pattern to 'dst', 'prefix' is static part of the
+// pattern, which 'root' corresponds to.
+func collect(root, prefix, pattern string, excludes []string, dst string) error {
+	glob := HasGlob(pattern)
+	base := root
+	if !glob {
+		// Plain path is collected together with its base name.
+		base = filepath.Dir(root)
+	}
+
+	var matched int
+	err := filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(base, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			return nil
+		}
+
+		if excluded(excludes, rel) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if glob && !Match(pattern, path.Join(prefix, rel)) {
+			return nil
+		}
+
+		matched++
+		target := filepath.Join(dst, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		return CopyPath(file, filepath.Dir(target))
+	})
+	if err != nil {
+		return err
+	}
+
+	if matched == 0 {
+		log.WithField("pattern", pattern).Warn("No file matched")
+	}
+	return nil
+}
+
+// Pack archives contents of the directory 'dir' to the writer, entries are relative to 'dir'.
+func Pack(dir string, format v1alpha1.ArchiveFormat, w io.Writer) error {
+	var gw *gzip.Writer
+	if format != v1alpha1.ArchiveTar {
+		gw = gzip.NewWriter(w)
+		w = gw
+	}
+
+	tw := tar.NewWriter(w)
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := writeTar(tw, filepath.Join(dir, f.Name())); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	if gw != nil {
+		return gw.Close()
+	}
+	return nil
+}
+
+// Unpack extracts tar or tar.gz archive to directory 'dst', gzip compression is detected from data.
+func Unpack(r io.Reader, dst string) error {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	var reader io.Reader = br
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		reader = gr
+	}
+
+	return Untar(reader, dst)
+}
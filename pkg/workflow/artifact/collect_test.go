@@ -0,0 +1,122 @@
+package artifact
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+)
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		pattern string
+		name    string
+		matched bool
+	}{
+		{"dist/*.tar.gz", "dist/a.tar.gz", true},
+		{"dist/*.tar.gz", "dist/sub/a.tar.gz", false},
+		{"reports/**/*.xml", "reports/a.xml", true},
+		{"reports/**/*.xml", "reports/x/y/a.xml", true},
+		{"reports/**/*.xml", "reports/x/a.txt", false},
+		{"/out/**", "/out/a/b", true},
+		{"**/*.log", "a/b/c.log", true},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.matched, Match(c.pattern, c.name), "%s ~ %s", c.pattern, c.name)
+	}
+}
+
+func TestStaticPrefix(t *testing.T) {
+	assert.Equal(t, "/workspace/dist", StaticPrefix("/workspace/dist/*.tar.gz"))
+	assert.Equal(t, "/workspace/reports", StaticPrefix("/workspace/reports/**/*.xml"))
+	assert.Equal(t, "/workspace/out", StaticPrefix("/workspace/out"))
+	assert.Equal(t, "/", StaticPrefix("/*.txt"))
+}
+
+func TestOutputName(t *testing.T) {
+	assert.Equal(t, "out", OutputName("art", "/workspace/out", v1alpha1.OutputOptions{}))
+	assert.Equal(t, "art", OutputName("art", "/workspace/*.txt", v1alpha1.OutputOptions{}))
+	assert.Equal(t, "art", OutputName("art", "/a", v1alpha1.OutputOptions{Paths: []string{"/b"}}))
+	assert.Equal(t, "art.tar.gz", OutputName("art", "/a", v1alpha1.OutputOptions{Archive: &v1alpha1.Archive{}}))
+	assert.Equal(t, "dist.tar", OutputName("art", "/a", v1alpha1.OutputOptions{Archive: &v1alpha1.Archive{Name: "dist.tar"}}))
+	assert.Equal(t, v1alpha1.ArchiveTar, ArchiveFormat(&v1alpha1.Archive{Name: "dist.tar"}))
+	assert.Equal(t, v1alpha1.ArchiveTarGz, ArchiveFormat(&v1alpha1.Archive{}))
+}
+
+func TestCollect(t *testing.T) {
+	// Fake container file system
+	container, _ := ioutil.TempDir("", "container")
+	defer os.RemoveAll(container)
+	files := map[string]string{
+		"dist/a.tar.gz":         "a",
+		"dist/b.tar.gz":         "b",
+		"dist/c.txt":            "c",
+		"reports/unit/x.xml":    "x",
+		"reports/e2e/y.xml":     "y",
+		"reports/e2e/skip.xml":  "skip",
+		"reports/e2e/debug.log": "log",
+		"README.md":             "readme",
+	}
+	for f, content := range files {
+		os.MkdirAll(filepath.Dir(filepath.Join(container, f)), 0755)
+		ioutil.WriteFile(filepath.Join(container, f), []byte(content), 0644)
+	}
+	copyFunc := func(src, dst string) error {
+		return CopyPath(filepath.Join(container, src), dst)
+	}
+
+	options := v1alpha1.OutputOptions{
+		Paths:    []string{"/reports/**/*.xml", "/README.md"},
+		Excludes: []string{"skip.xml"},
+	}
+
+	dst, _ := ioutil.TempDir("", "dst")
+	defer os.RemoveAll(dst)
+	collected, err := Collect(copyFunc, "art", "/dist/*.tar.gz", options, dst)
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(dst, "art"), collected)
+
+	var got []string
+	filepath.Walk(collected, func(file string, info os.FileInfo, err error) error {
+		if !info.IsDir() {
+			rel, _ := filepath.Rel(collected, file)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	assert.ElementsMatch(t, []string{"a.tar.gz", "b.tar.gz", "unit/x.xml", "e2e/y.xml", "README.md"}, got)
+
+	// Pack into archive and unpack it
+	options.Archive = &v1alpha1.Archive{}
+	collected, err = Collect(copyFunc, "art", "/dist/*.tar.gz", options, dst)
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(dst, "art.tar.gz"), collected)
+
+	unpacked, _ := ioutil.TempDir("", "unpacked")
+	defer os.RemoveAll(unpacked)
+	f, _ := os.Open(collected)
+	defer f.Close()
+	assert.Nil(t, Unpack(f, unpacked))
+	data, _ := ioutil.ReadFile(filepath.Join(unpacked, "e2e", "y.xml"))
+	assert.Equal(t, "y", string(data))
+
+	// Simple path is collected as it is
+	collected, err = Collect(copyFunc, "art", "/reports", v1alpha1.OutputOptions{}, dst)
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(dst, "reports"), collected)
+	data, _ = ioutil.ReadFile(filepath.Join(collected, "e2e", "skip.xml"))
+	assert.Equal(t, "skip", string(data))
+
+	// Staging directories are removed
+	entries, _ := ioutil.ReadDir(dst)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	assert.ElementsMatch(t, []string{"art", "art.tar.gz", "reports"}, names)
+}
@@ -0,0 +1,114 @@
+package artifact
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Input describes an input artifact to fetch.
+type Input struct {
+	// Name of the input artifact
+	Name string `json:"name"`
+	// Key of the artifact in the store
+	Key string `json:"key"`
+	// Unpack indicates the artifact is an archive and should be unpacked
+	Unpack bool `json:"unpack,omitempty"`
+}
+
+// EncodeInputs encodes input artifacts to string, so that they can be passed to fetcher
+// via environment variable.
+func EncodeInputs(inputs []Input) (string, error) {
+	data, err := json.Marshal(inputs)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// DecodeInputs decodes input artifacts encoded by EncodeInputs.
+func DecodeInputs(data string) ([]Input, error) {
+	var inputs []Input
+	if data == "" {
+		return inputs, nil
+	}
+	if err := json.Unmarshal([]byte(data), &inputs); err != nil {
+		return nil, err
+	}
+	return inputs, nil
+}
+
+// EncodeConfig encodes artifact store config to string.
+func EncodeConfig(c *Config) (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// DecodeConfig decodes artifact store config encoded by EncodeConfig, empty string
+// results in the default PVC store config.
+func DecodeConfig(data string) (*Config, error) {
+	c := &Config{}
+	if data == "" {
+		return c, nil
+	}
+	if err := json.Unmarshal([]byte(data), c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Fetch fetches input artifacts from the store, each artifact is placed at <dst>/<name>/<object name>,
+// and for archives to unpack, they are unpacked to <dst>/<name>.
+func Fetch(store Store, inputs []Input, dst string) error {
+	for _, input := range inputs {
+		target := filepath.Join(dst, input.Name)
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return err
+		}
+
+		var obj *Object
+		var err error
+		if input.Unpack {
+			obj, err = fetchAndUnpack(store, input.Key, target)
+		} else {
+			obj, err = store.Get(input.Key, target)
+		}
+		if err != nil {
+			log.WithField("artifact", input.Name).WithField("key", input.Key).Error("Fetch artifact error: ", err)
+			return fmt.Errorf("fetch artifact %s error: %v", input.Name, err)
+		}
+		log.WithField("artifact", input.Name).
+			WithField("key", input.Key).
+			WithField("checksum", obj.Checksum).
+			Info("Artifact fetched")
+	}
+
+	return nil
+}
+
+// fetchAndUnpack fetches an archive artifact and unpacks it to directory 'dst'.
+func fetchAndUnpack(store Store, key, dst string) (*Object, error) {
+	reader, obj, err := store.Open(key)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	if obj.Dir {
+		return obj, extract(obj, reader, dst)
+	}
+	if err := Unpack(reader, dst); err != nil {
+		return nil, err
+	}
+	// Drain the reader to make sure the checksum verified.
+	_, err = io.Copy(ioutil.Discard, reader)
+	return obj, err
+}
@@ -0,0 +1,246 @@
+package artifact
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// metaFile is the file to hold artifact information in PVC store, it's placed in the
+// artifact directory together with the artifact data.
+const metaFile = ".cyclone-artifact.json"
+
+// pvcStore stores artifacts in a local directory where the PVC mounted. For an artifact
+// with key <key>, the data is placed at <root>/<key without base>/<name>, this layout is
+// kept the same as before the artifact store introduced, so artifacts can be mounted to
+// stages with PVC sub path directly.
+type pvcStore struct {
+	root string
+	base string
+}
+
+// NewPVCStore creates a PVC store, 'root' is the local directory where the PVC mounted, and
+// 'base' is the key prefix the root directory corresponds to.
+func NewPVCStore(root, base string) Store {
+	return &pvcStore{
+		root: root,
+		base: base,
+	}
+}
+
+func (s *pvcStore) local(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(strings.TrimPrefix(key, s.base)))
+}
+
+// Put saves the file or directory to PVC, if it's already in place, only the artifact
+// information is recorded.
+func (s *pvcStore) Put(key, src string) (*Object, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := s.local(key)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	target := filepath.Join(dir, filepath.Base(src))
+	if filepath.Clean(src) != target {
+		if err := os.RemoveAll(target); err != nil {
+			return nil, err
+		}
+		if err := CopyPath(src, dir); err != nil {
+			return nil, err
+		}
+	}
+
+	obj := &Object{
+		Key:     key,
+		Name:    info.Name(),
+		Dir:     info.IsDir(),
+		ModTime: time.Now(),
+	}
+	reader, err := openData(target, info.IsDir())
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	cr := newChecksumReader(reader, "")
+	if obj.Size, err = io.Copy(ioutil.Discard, cr); err != nil {
+		return nil, err
+	}
+	obj.Checksum = cr.Sum()
+
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, metaFile), data, 0644); err != nil {
+		return nil, err
+	}
+
+	return obj, nil
+}
+
+// Get copies the artifact to the local directory.
+func (s *pvcStore) Get(key, dst string) (*Object, error) {
+	reader, obj, err := s.Open(key)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return obj, extract(obj, reader, dst)
+}
+
+// Open opens the artifact data, checksum is verified while reading.
+func (s *pvcStore) Open(key string) (io.ReadCloser, *Object, error) {
+	obj, err := s.Stat(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reader, err := openData(filepath.Join(s.local(key), obj.Name), obj.Dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &readCloser{
+		Reader: newChecksumReader(reader, obj.Checksum),
+		Closer: reader,
+	}, obj, nil
+}
+
+// Stat gets artifact information. For artifacts stored before artifact information recorded,
+// information is collected from the data directly and checksum is left empty.
+func (s *pvcStore) Stat(key string) (*Object, error) {
+	dir := s.local(key)
+	data, err := ioutil.ReadFile(filepath.Join(dir, metaFile))
+	if err == nil {
+		obj := &Object{}
+		if err := json.Unmarshal(data, obj); err != nil {
+			return nil, err
+		}
+		obj.Key = key
+		return obj, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, &ErrNotFound{Key: key}
+		}
+		return nil, err
+	}
+	for _, f := range files {
+		if f.Name() == metaFile {
+			continue
+		}
+		return &Object{
+			Key:     key,
+			Name:    f.Name(),
+			Dir:     f.IsDir(),
+			Size:    f.Size(),
+			ModTime: f.ModTime(),
+		}, nil
+	}
+
+	return nil, &ErrNotFound{Key: key}
+}
+
+// List lists artifacts with the key prefix.
+func (s *pvcStore) List(prefix string) ([]*Object, error) {
+	var objects []*Object
+	root := s.local(prefix)
+	err := filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || info.Name() != metaFile {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.root, filepath.Dir(file))
+		if err != nil {
+			return err
+		}
+		obj, err := s.Stat(s.base + filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+		objects = append(objects, obj)
+
+		return nil
+	})
+
+	return objects, err
+}
+
+// Delete removes all artifacts with the key prefix.
+func (s *pvcStore) Delete(prefix string) error {
+	return os.RemoveAll(s.local(prefix))
+}
+
+// readCloser combines a reader and a closer.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// openData opens data of an artifact, directories are opened as tar stream.
+func openData(file string, dir bool) (io.ReadCloser, error) {
+	if !dir {
+		return os.Open(file)
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(Tar(file, writer))
+	}()
+	return reader, nil
+}
+
+// CopyPath copies a file or directory into the directory 'dst'.
+func CopyPath(src, dst string) error {
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(Tar(src, writer))
+	}()
+	defer reader.Close()
+
+	return Untar(reader, dst)
+}
+
+// extract places artifact data read from the reader to the local directory 'dst'.
+func extract(obj *Object, reader io.Reader, dst string) error {
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	if obj.Dir {
+		if err := Untar(reader, dst); err != nil {
+			return err
+		}
+		// Drain the reader to make sure the checksum verified.
+		_, err := io.Copy(ioutil.Discard, reader)
+		return err
+	}
+
+	f, err := os.Create(filepath.Join(dst, obj.Name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(f, reader)
+	return err
+}
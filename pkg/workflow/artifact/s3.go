@@ -0,0 +1,370 @@
+package artifact
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/caicloud/cyclone/pkg/util/s3"
+)
+
+const (
+	// Object metadata headers used to record artifact information.
+	metaName     = "X-Amz-Meta-Cyclone-Name"
+	metaDir      = "X-Amz-Meta-Cyclone-Dir"
+	metaChecksum = "X-Amz-Meta-Cyclone-Checksum"
+)
+
+// s3Store stores artifacts in S3 compatible object storage. Each artifact is stored as one
+// object, directories are archived with tar. Path style addressing is used, so that it works
+// with both AWS S3 and self-hosted storages like MinIO.
+type s3Store struct {
+	config *S3Config
+	client *s3.Client
+}
+
+// NewS3Store creates a S3 store.
+func NewS3Store(c *S3Config) Store {
+	return &s3Store{
+		config: c,
+		client: &s3.Client{
+			Endpoint:  c.Endpoint,
+			Region:    c.Region,
+			AccessKey: c.AccessKey,
+			SecretKey: c.SecretKey,
+			Insecure:  c.Insecure,
+		},
+	}
+}
+
+// objectKey gets the object key of an artifact key, prefix is added.
+func (s *s3Store) objectKey(key string) string {
+	k := path.Join(s.config.Prefix, key)
+	if strings.HasSuffix(key, "/") {
+		k += "/"
+	}
+	return strings.TrimPrefix(k, "/")
+}
+
+// Put archives the file or directory to a temp file to get its size and checksum, then
+// uploads it to the object storage.
+func (s *s3Store) Put(key, src string) (*Object, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := ioutil.TempFile("", "artifact-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	reader, err := openData(src, info.IsDir())
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	cr := newChecksumReader(reader, "")
+	size, err := io.Copy(tmp, cr)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	obj := &Object{
+		Key:      key,
+		Name:     info.Name(),
+		Dir:      info.IsDir(),
+		Size:     size,
+		Checksum: cr.Sum(),
+		ModTime:  time.Now(),
+	}
+	header := http.Header{}
+	header.Set(metaName, obj.Name)
+	header.Set(metaDir, strconv.FormatBool(obj.Dir))
+	header.Set(metaChecksum, obj.Checksum)
+	header.Set("Content-Type", "application/octet-stream")
+	resp, err := s.do(http.MethodPut, s.objectKey(key), nil, header, tmp, size, strings.TrimPrefix(obj.Checksum, "sha256:"))
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	return obj, nil
+}
+
+// Get downloads the artifact to the local directory.
+func (s *s3Store) Get(key, dst string) (*Object, error) {
+	reader, obj, err := s.Open(key)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return obj, extract(obj, reader, dst)
+}
+
+// Open opens the object data, checksum is verified while reading.
+func (s *s3Store) Open(key string) (io.ReadCloser, *Object, error) {
+	resp, err := s.do(http.MethodGet, s.objectKey(key), nil, nil, nil, 0, s3.EmptyPayloadHash)
+	if err != nil {
+		if s3.IsNotFound(err) {
+			return nil, nil, &ErrNotFound{Key: key}
+		}
+		return nil, nil, err
+	}
+
+	obj := objectFromHeader(key, resp.Header)
+	return &readCloser{
+		Reader: newChecksumReader(resp.Body, obj.Checksum),
+		Closer: resp.Body,
+	}, obj, nil
+}
+
+// Stat gets artifact information from object metadata.
+func (s *s3Store) Stat(key string) (*Object, error) {
+	resp, err := s.do(http.MethodHead, s.objectKey(key), nil, nil, nil, 0, s3.EmptyPayloadHash)
+	if err != nil {
+		if s3.IsNotFound(err) {
+			return nil, &ErrNotFound{Key: key}
+		}
+		return nil, err
+	}
+	resp.Body.Close()
+
+	return objectFromHeader(key, resp.Header), nil
+}
+
+// List lists artifacts with the key prefix.
+func (s *s3Store) List(prefix string) ([]*Object, error) {
+	keys, err := s.listKeys(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []*Object
+	for _, k := range keys {
+		obj, err := s.Stat(k)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, obj)
+	}
+
+	return objects, nil
+}
+
+// Delete deletes all objects with the key prefix.
+func (s *s3Store) Delete(prefix string) error {
+	keys, err := s.listKeys(prefix)
+	if err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		resp, err := s.do(http.MethodDelete, s.objectKey(k), nil, nil, nil, 0, s3.EmptyPayloadHash)
+		if err != nil {
+			if s3.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		resp.Body.Close()
+	}
+
+	return nil
+}
+
+// listBucketResult is response of ListObjectsV2.
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// listKeys lists artifact keys (without store prefix) with the key prefix.
+func (s *s3Store) listKeys(prefix string) ([]string, error) {
+	objectPrefix := s.objectKey(prefix)
+	storePrefix := strings.TrimSuffix(s.objectKey(""), "/")
+	if storePrefix != "" {
+		storePrefix += "/"
+	}
+
+	var keys []string
+	var token string
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", objectPrefix)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do(http.MethodGet, "", query, nil, nil, 0, s3.EmptyPayloadHash)
+		if err != nil {
+			return nil, err
+		}
+		result := &listBucketResult{}
+		err = xml.NewDecoder(resp.Body).Decode(result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range result.Contents {
+			keys = append(keys, strings.TrimPrefix(c.Key, storePrefix))
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// lifecycleRuleIDPrefix is ID prefix of lifecycle rules set by Cyclone, the store prefix follows.
+const lifecycleRuleIDPrefix = "cyclone-artifacts:"
+
+// lifecycleConfiguration is the bucket lifecycle configuration, rules are kept as raw XML so that
+// rules not set by Cyclone are written back as they are.
+type lifecycleConfiguration struct {
+	Rules []struct {
+		ID    string `xml:"ID"`
+		Inner string `xml:",innerxml"`
+	} `xml:"Rule"`
+}
+
+// EnsureBucket creates the bucket if not exist. If retention days given, a lifecycle rule would
+// be set to the bucket to expire objects under the store prefix. Stores of different namespaces
+// may share the bucket with different prefixes, so the rule is merged into existing lifecycle
+// configuration by its ID, which is derived from the prefix, and other rules are kept.
+func (s *s3Store) EnsureBucket(retentionDays int) error {
+	resp, err := s.do(http.MethodHead, "", nil, nil, nil, 0, s3.EmptyPayloadHash)
+	if err == nil {
+		resp.Body.Close()
+	} else {
+		if !s3.IsNotFound(err) {
+			return err
+		}
+
+		var body []byte
+		if s.client.GetRegion() != s3.DefaultRegion {
+			body = []byte(fmt.Sprintf(`<CreateBucketConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><LocationConstraint>%s</LocationConstraint></CreateBucketConfiguration>`, s.client.GetRegion()))
+		}
+		if resp, err = s.doBytes(http.MethodPut, "", nil, nil, body); err != nil {
+			return err
+		}
+		resp.Body.Close()
+	}
+
+	if retentionDays <= 0 {
+		return nil
+	}
+
+	// Prefix ends with '/', otherwise the rule would also match prefixes of other namespaces, e.g.
+	// 'cyclone--a' matches 'cyclone--ab'.
+	prefix := strings.TrimSuffix(s.objectKey(""), "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	id := lifecycleRuleIDPrefix + prefix
+	rule := fmt.Sprintf(`<ID>%s</ID><Filter><Prefix>%s</Prefix></Filter><Status>Enabled</Status><Expiration><Days>%d</Days></Expiration>`,
+		id, prefix, retentionDays)
+
+	query := url.Values{}
+	query.Set("lifecycle", "")
+	current := &lifecycleConfiguration{}
+	resp, err = s.do(http.MethodGet, "", query, nil, nil, 0, s3.EmptyPayloadHash)
+	if err == nil {
+		err = xml.NewDecoder(resp.Body).Decode(current)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("decode bucket lifecycle error: %v", err)
+		}
+	} else if !s3.IsNotFound(err) {
+		return err
+	}
+
+	var b bytes.Buffer
+	b.WriteString("<LifecycleConfiguration>")
+	for _, r := range current.Rules {
+		if r.ID == id {
+			if strings.TrimSpace(r.Inner) == rule {
+				return nil
+			}
+			continue
+		}
+		b.WriteString("<Rule>" + r.Inner + "</Rule>")
+	}
+	b.WriteString("<Rule>" + rule + "</Rule>")
+	b.WriteString("</LifecycleConfiguration>")
+
+	body := []byte(b.String())
+	sum := md5.Sum(body)
+	header := http.Header{}
+	header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+	resp, err = s.doBytes(http.MethodPut, "", query, header, body)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// EnsureBucket prepares the store with the config, for example, creates bucket for S3 store.
+func EnsureBucket(c *Config) error {
+	if c.StoreType() != S3Store {
+		return nil
+	}
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	return NewS3Store(c.S3).(*s3Store).EnsureBucket(c.RetentionDays)
+}
+
+// doBytes sends request with bytes payload.
+func (s *s3Store) doBytes(method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	return s.client.DoBytes(method, s.config.Bucket, key, query, header, body)
+}
+
+// do sends a signed request to the object storage, 'key' is the object key, and if it's
+// empty, the request is sent to the bucket.
+func (s *s3Store) do(method, key string, query url.Values, header http.Header, body io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	return s.client.Do(method, s.config.Bucket, key, query, header, body, size, payloadHash)
+}
+
+// objectFromHeader gets artifact information from response headers.
+func objectFromHeader(key string, header http.Header) *Object {
+	obj := &Object{
+		Key:      key,
+		Name:     header.Get(metaName),
+		Checksum: header.Get(metaChecksum),
+	}
+	obj.Dir, _ = strconv.ParseBool(header.Get(metaDir))
+	obj.Size, _ = strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	if t, err := http.ParseTime(header.Get("Last-Modified")); err == nil {
+		obj.ModTime = t
+	}
+	if obj.Name == "" {
+		obj.Name = path.Base(key)
+	}
+
+	return obj
+}
@@ -0,0 +1,235 @@
+package artifact
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeS3 is a minimal in-memory S3 server supporting object operations used by the store.
+type fakeS3 struct {
+	lock      sync.Mutex
+	objects   map[string][]byte
+	headers   map[string]http.Header
+	lifecycle []byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+	if _, ok := r.URL.Query()["lifecycle"]; ok {
+		switch r.Method {
+		case http.MethodPut:
+			f.lifecycle, _ = ioutil.ReadAll(r.Body)
+		case http.MethodGet:
+			if f.lifecycle == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(f.lifecycle)
+		}
+		return
+	}
+	if r.URL.Path == "/bucket" && r.URL.Query().Get("list-type") == "2" {
+		var keys []string
+		for k := range f.objects {
+			if strings.HasPrefix(k, r.URL.Query().Get("prefix")) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		body := "<ListBucketResult>"
+		for _, k := range keys {
+			body += "<Contents><Key>" + k + "</Key></Contents>"
+		}
+		body += "<IsTruncated>false</IsTruncated></ListBucketResult>"
+		w.Write([]byte(body))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := ioutil.ReadAll(r.Body)
+		f.objects[key] = data
+		f.headers[key] = r.Header
+	case http.MethodGet, http.MethodHead:
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for k, v := range f.headers[key] {
+			if strings.HasPrefix(k, "X-Amz-Meta-") {
+				w.Header()[k] = v
+			}
+		}
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	fake := &fakeS3{objects: map[string][]byte{}, headers: map[string]http.Header{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	config := &Config{
+		Type: S3Store,
+		S3: &S3Config{
+			Endpoint:  strings.TrimPrefix(server.URL, "http://"),
+			AccessKey: "ak",
+			SecretKey: "sk",
+			Insecure:  true,
+			Location:  Location{Bucket: "bucket"},
+		},
+	}
+	store, err := NewStore(config.ForNamespace("cyclone--t"), "", "")
+	assert.Nil(t, err)
+
+	src, _ := ioutil.TempDir("", "src")
+	defer os.RemoveAll(src)
+	os.MkdirAll(filepath.Join(src, "out", "sub"), 0755)
+	ioutil.WriteFile(filepath.Join(src, "out", "sub", "a.txt"), []byte("a"), 0644)
+	ioutil.WriteFile(filepath.Join(src, "b.txt"), []byte("b"), 0644)
+
+	key1 := Key("wfr", "stg", "dir")
+	obj, err := store.Put(key1, filepath.Join(src, "out"))
+	assert.Nil(t, err)
+	assert.True(t, obj.Dir)
+	assert.Contains(t, fake.objects, "cyclone--t/"+key1)
+
+	key2 := Key("wfr", "stg", "file")
+	_, err = store.Put(key2, filepath.Join(src, "b.txt"))
+	assert.Nil(t, err)
+
+	objects, err := store.List(StagePrefix("wfr", "stg"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(objects))
+	assert.Equal(t, key1, objects[0].Key)
+	assert.Equal(t, "out", objects[0].Name)
+	assert.Equal(t, obj.Checksum, objects[0].Checksum)
+
+	dst, _ := ioutil.TempDir("", "dst")
+	defer os.RemoveAll(dst)
+	_, err = store.Get(key1, dst)
+	assert.Nil(t, err)
+	data, _ := ioutil.ReadFile(filepath.Join(dst, "out", "sub", "a.txt"))
+	assert.Equal(t, "a", string(data))
+	_, err = store.Get(key2, dst)
+	assert.Nil(t, err)
+	data, _ = ioutil.ReadFile(filepath.Join(dst, "b.txt"))
+	assert.Equal(t, "b", string(data))
+
+	// Corrupted data should be detected.
+	fake.objects["cyclone--t/"+key2] = []byte("c")
+	_, err = store.Get(key2, dst)
+	assert.NotNil(t, err)
+
+	assert.Nil(t, store.Delete(WorkflowRunPrefix("wfr")))
+	assert.Equal(t, 0, len(fake.objects))
+	_, err = store.Stat(key1)
+	assert.True(t, IsNotFound(err))
+}
+
+func TestPVCStore(t *testing.T) {
+	root, _ := ioutil.TempDir("", "pvc")
+	defer os.RemoveAll(root)
+	base := "workflowruns/wfr/stages/stg/artifacts/"
+	store := NewPVCStore(root, base)
+
+	src, _ := ioutil.TempDir("", "src")
+	defer os.RemoveAll(src)
+	ioutil.WriteFile(filepath.Join(src, "a.txt"), []byte("a"), 0644)
+
+	key := Key("wfr", "stg", "art")
+	obj, err := store.Put(key, filepath.Join(src, "a.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, "a.txt", obj.Name)
+	data, _ := ioutil.ReadFile(filepath.Join(root, "art", "a.txt"))
+	assert.Equal(t, "a", string(data))
+
+	objects, err := store.List(base)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(objects))
+	assert.Equal(t, key, objects[0].Key)
+	assert.Equal(t, obj.Checksum, objects[0].Checksum)
+
+	dst, _ := ioutil.TempDir("", "dst")
+	defer os.RemoveAll(dst)
+	_, err = store.Get(key, dst)
+	assert.Nil(t, err)
+	data, _ = ioutil.ReadFile(filepath.Join(dst, "a.txt"))
+	assert.Equal(t, "a", string(data))
+}
+
+func TestEnsureBucketLifecycle(t *testing.T) {
+	fake := &fakeS3{objects: map[string][]byte{}, headers: map[string]http.Header{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	// Rule set by operator is kept.
+	operatorRule := "<ID>logs</ID><Filter><Prefix>logs/</Prefix></Filter><Status>Enabled</Status><Expiration><Days>7</Days></Expiration>"
+	fake.lifecycle = []byte(`<LifecycleConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Rule>` + operatorRule + `</Rule></LifecycleConfiguration>`)
+
+	config := &Config{
+		Type: S3Store,
+		S3: &S3Config{
+			Endpoint:  strings.TrimPrefix(server.URL, "http://"),
+			AccessKey: "ak",
+			SecretKey: "sk",
+			Insecure:  true,
+			Location:  Location{Bucket: "bucket"},
+		},
+		RetentionDays: 30,
+	}
+	// Two namespaces share the bucket, ensure the first one again to check it's idempotent.
+	for _, ns := range []string{"cyclone--a", "cyclone--b", "cyclone--a"} {
+		assert.Nil(t, EnsureBucket(config.ForNamespace(ns)))
+	}
+
+	lifecycle := string(fake.lifecycle)
+	assert.Equal(t, 3, strings.Count(lifecycle, "<Rule>"), lifecycle)
+	assert.Contains(t, lifecycle, operatorRule)
+	for _, ns := range []string{"cyclone--a", "cyclone--b"} {
+		assert.Contains(t, lifecycle, "<ID>"+lifecycleRuleIDPrefix+ns+"/</ID><Filter><Prefix>"+ns+"/</Prefix></Filter>")
+	}
+	assert.Equal(t, 2, strings.Count(lifecycle, "<Days>30</Days>"))
+}
+
+func TestConfigForNamespace(t *testing.T) {
+	config := &Config{
+		Type: S3Store,
+		S3: &S3Config{
+			Endpoint: "minio:9000",
+			Location: Location{Bucket: "artifacts", Prefix: "cyclone"},
+		},
+		Namespaces: map[string]Location{
+			"cyclone--a": {Bucket: "tenant-a"},
+		},
+	}
+
+	assert.Equal(t, "cyclone/cyclone--b", config.ForNamespace("cyclone--b").S3.Prefix)
+	assert.Equal(t, "artifacts", config.ForNamespace("cyclone--b").S3.Bucket)
+	assert.Equal(t, "tenant-a", config.ForNamespace("cyclone--a").S3.Bucket)
+	assert.Equal(t, "", config.ForNamespace("cyclone--a").S3.Prefix)
+	assert.Equal(t, "cyclone", config.S3.Prefix)
+}
@@ -0,0 +1,247 @@
+package artifact
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Paths served by the artifact handler.
+const (
+	listPath = "/list"
+	statPath = "/stat/"
+	dataPath = "/data/"
+)
+
+// TokenHeader is the header that carries token of the artifact handler. Authorization header can't be
+// used since it's consumed by Kubernetes API server when requests are proxied to the pod.
+const TokenHeader = "X-Cyclone-Artifact-Token"
+
+// validKey checks whether the key or key prefix is under WorkflowRuns, and won't escape the store
+// root, for example, with '..'.
+func validKey(key string) bool {
+	return strings.HasPrefix(key, "workflowruns/") && path.Clean(key) == strings.TrimSuffix(key, "/") &&
+		!strings.Contains(key, "..")
+}
+
+// NewHandler serves artifacts in the store read-only over HTTP. It's run in a pod where PVC is
+// mounted, so that Cyclone Server can read artifacts in PVC, see NewRemoteStore. Only artifacts of
+// WorkflowRuns are served, and requests without the token are rejected.
+func NewHandler(store Store, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(listPath, func(w http.ResponseWriter, r *http.Request) {
+		prefix := r.URL.Query().Get("prefix")
+		if !validKey(prefix) {
+			http.Error(w, fmt.Sprintf("invalid prefix %s", prefix), http.StatusBadRequest)
+			return
+		}
+		objects, err := store.List(prefix)
+		if err != nil {
+			log.WithField("prefix", prefix).Warn("List artifacts error: ", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, objects)
+	})
+	mux.HandleFunc(statPath, func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, statPath)
+		if _, _, _, err := ParseKey(key); err != nil || !validKey(key) {
+			http.Error(w, fmt.Sprintf("invalid key %s", key), http.StatusBadRequest)
+			return
+		}
+		obj, err := store.Stat(key)
+		if err != nil {
+			writeError(w, key, err)
+			return
+		}
+		writeJSON(w, obj)
+	})
+	mux.HandleFunc(dataPath, func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, dataPath)
+		if _, _, _, err := ParseKey(key); err != nil || !validKey(key) {
+			http.Error(w, fmt.Sprintf("invalid key %s", key), http.StatusBadRequest)
+			return
+		}
+		reader, _, err := store.Open(key)
+		if err != nil {
+			writeError(w, key, err)
+			return
+		}
+		defer reader.Close()
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if _, err := io.Copy(w, reader); err != nil {
+			log.WithField("key", key).Warn("Serve artifact error: ", err)
+		}
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get(TokenHeader)), []byte(token)) != 1 {
+			http.Error(w, "invalid artifact token", http.StatusForbidden)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// Serve serves the handler on the address until no request is received in the idle duration, then
+// it returns nil, so that the artifact reader pod exits and won't be left running.
+func Serve(addr string, handler http.Handler, idle time.Duration) error {
+	var lock sync.Mutex
+	var active int
+	last := time.Now()
+	server := &http.Server{
+		Addr: addr,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			active++
+			lock.Unlock()
+			defer func() {
+				lock.Lock()
+				active--
+				last = time.Now()
+				lock.Unlock()
+			}()
+			handler.ServeHTTP(w, r)
+		}),
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(idle / 10)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				lock.Lock()
+				idled := active == 0 && time.Since(last) >= idle
+				lock.Unlock()
+				if idled {
+					log.Infof("No request in %s, stop serving artifacts", idle)
+					server.Shutdown(context.Background())
+					return
+				}
+			}
+		}
+	}()
+
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Warn("Write response error: ", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, key string, err error) {
+	if IsNotFound(err) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	log.WithField("key", key).Warn("Read artifact error: ", err)
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// GetFunc sends GET request to the artifact handler with the path and query, and returns the
+// response body. It should return ErrNotFound if the handler responds 404.
+type GetFunc func(path string, query url.Values) (io.ReadCloser, error)
+
+// remoteStore reads artifacts served by the artifact handler, it's read-only.
+type remoteStore struct {
+	get GetFunc
+}
+
+// NewRemoteStore creates a read-only store that reads artifacts from the artifact handler, see
+// NewHandler.
+func NewRemoteStore(get GetFunc) Store {
+	return &remoteStore{get: get}
+}
+
+// Put is not supported by remote store.
+func (s *remoteStore) Put(key, src string) (*Object, error) {
+	return nil, fmt.Errorf("remote artifact store is read-only")
+}
+
+// Get copies the artifact to the local directory.
+func (s *remoteStore) Get(key, dst string) (*Object, error) {
+	reader, obj, err := s.Open(key)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return obj, extract(obj, reader, dst)
+}
+
+// Open opens the artifact data, checksum is verified while reading.
+func (s *remoteStore) Open(key string) (io.ReadCloser, *Object, error) {
+	obj, err := s.Stat(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reader, err := s.get(dataPath+key, nil)
+	if err != nil {
+		return nil, nil, s.notFound(key, err)
+	}
+	return &readCloser{
+		Reader: newChecksumReader(reader, obj.Checksum),
+		Closer: reader,
+	}, obj, nil
+}
+
+// Stat gets artifact information.
+func (s *remoteStore) Stat(key string) (*Object, error) {
+	obj := &Object{}
+	if err := s.getJSON(statPath+key, nil, obj); err != nil {
+		return nil, s.notFound(key, err)
+	}
+	return obj, nil
+}
+
+// List lists artifacts with the key prefix.
+func (s *remoteStore) List(prefix string) ([]*Object, error) {
+	var objects []*Object
+	if err := s.getJSON(listPath, url.Values{"prefix": {prefix}}, &objects); err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// Delete is not supported by remote store.
+func (s *remoteStore) Delete(prefix string) error {
+	return fmt.Errorf("remote artifact store is read-only")
+}
+
+func (s *remoteStore) getJSON(path string, query url.Values, v interface{}) error {
+	reader, err := s.get(path, query)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	return json.NewDecoder(reader).Decode(v)
+}
+
+// notFound sets key of ErrNotFound returned by the GetFunc.
+func (s *remoteStore) notFound(key string, err error) error {
+	if IsNotFound(err) {
+		return &ErrNotFound{Key: key}
+	}
+	return err
+}
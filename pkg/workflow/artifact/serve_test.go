@@ -0,0 +1,120 @@
+package artifact
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoteStore(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "serve")
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "src")
+	os.MkdirAll(filepath.Join(src, "dist"), 0755)
+	ioutil.WriteFile(filepath.Join(src, "app"), []byte("app"), 0644)
+	ioutil.WriteFile(filepath.Join(src, "dist", "a.txt"), []byte("a"), 0644)
+
+	pvc := NewPVCStore(filepath.Join(dir, "pvc"), "")
+	_, err := pvc.Put(Key("wfr", "build", "app"), filepath.Join(src, "app"))
+	assert.Nil(t, err)
+	_, err = pvc.Put(Key("wfr", "build", "dist"), filepath.Join(src, "dist"))
+	assert.Nil(t, err)
+
+	server := httptest.NewServer(NewHandler(pvc, "token"))
+	defer server.Close()
+	store := NewRemoteStore(func(path string, query url.Values) (io.ReadCloser, error) {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+path+"?"+query.Encode(), nil)
+		req.Header.Set(TokenHeader, "token")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			resp.Body.Close()
+			return nil, &ErrNotFound{}
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("status %d", resp.StatusCode)
+		}
+		return resp.Body, nil
+	})
+
+	objects, err := store.List(WorkflowRunPrefix("wfr"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(objects))
+
+	reader, obj, err := store.Open(Key("wfr", "build", "app"))
+	assert.Nil(t, err)
+	data, err := ioutil.ReadAll(reader)
+	reader.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, "app", string(data))
+	assert.Equal(t, "app", obj.Name)
+
+	obj, err = store.Get(Key("wfr", "build", "dist"), filepath.Join(dir, "dst"))
+	assert.Nil(t, err)
+	assert.True(t, obj.Dir)
+	data, _ = ioutil.ReadFile(filepath.Join(dir, "dst", "dist", "a.txt"))
+	assert.Equal(t, "a", string(data))
+
+	_, _, err = store.Open(Key("wfr", "build", "missing"))
+	assert.True(t, IsNotFound(err))
+
+	for _, key := range []string{"caches/key/artifacts/app", "workflowruns/../../etc/passwd", "workflowruns/wfr/stages/build/artifacts/.."} {
+		_, err := store.Stat(key)
+		assert.Error(t, err, key)
+	}
+	_, err = store.List("caches/")
+	assert.Error(t, err)
+	assert.Error(t, store.Delete(WorkflowRunPrefix("wfr")))
+}
+
+func TestHandlerToken(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "serve")
+	defer os.RemoveAll(dir)
+	server := httptest.NewServer(NewHandler(NewPVCStore(dir, ""), "token"))
+	defer server.Close()
+
+	for _, token := range []string{"", "wrong"} {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+listPath+"?prefix=workflowruns/wfr/", nil)
+		if token != "" {
+			req.Header.Set(TokenHeader, token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode, token)
+	}
+
+	// Handler without token rejects all requests.
+	noToken := httptest.NewServer(NewHandler(NewPVCStore(dir, ""), ""))
+	defer noToken.Close()
+	resp, err := http.Get(noToken.URL + listPath)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestServeIdle(t *testing.T) {
+	done := make(chan error)
+	go func() {
+		done <- Serve("127.0.0.1:0", http.NotFoundHandler(), 100*time.Millisecond)
+	}()
+
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Error("Serve should return after idle")
+	}
+}
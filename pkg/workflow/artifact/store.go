@@ -0,0 +1,238 @@
+package artifact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// StoreType is type of the artifact store.
+type StoreType string
+
+const (
+	// PVCStore stores artifacts in the PVC shared by all stages, it's the default store.
+	PVCStore StoreType = "pvc"
+	// S3Store stores artifacts in S3 compatible object storage, e.g. AWS S3, MinIO.
+	S3Store StoreType = "s3"
+)
+
+// Config configures artifact store.
+type Config struct {
+	// Type of the store, 'pvc' or 's3', default to 'pvc'.
+	Type StoreType `json:"type"`
+	// S3 configures S3 compatible object storage, required when type is 's3'.
+	S3 *S3Config `json:"s3,omitempty"`
+	// RetentionDays is days to keep artifacts after they are stored. 0 means artifacts
+	// will be removed together with the WorkflowRun in garbage collection.
+	RetentionDays int `json:"retention_days"`
+	// Namespaces overrides bucket and prefix of the object storage for some namespaces,
+	// so that tenants can have their own buckets or prefixes.
+	Namespaces map[string]Location `json:"namespaces,omitempty"`
+}
+
+// Location describes where artifacts located in object storage.
+type Location struct {
+	// Bucket to hold the artifacts
+	Bucket string `json:"bucket"`
+	// Prefix of object keys
+	Prefix string `json:"prefix"`
+}
+
+// S3Config configures S3 compatible object storage.
+type S3Config struct {
+	// Endpoint of the object storage, e.g. s3.amazonaws.com, minio.default:9000
+	Endpoint string `json:"endpoint"`
+	// Region of the bucket, default to 'us-east-1'
+	Region string `json:"region"`
+	// AccessKey to access the object storage
+	AccessKey string `json:"access_key"`
+	// SecretKey to access the object storage
+	SecretKey string `json:"secret_key"`
+	// Insecure indicates whether to use plain HTTP instead of HTTPS
+	Insecure bool `json:"insecure"`
+	// Location is the default bucket and prefix, if prefix is empty, namespace name will
+	// be used as the prefix.
+	Location `json:",inline"`
+}
+
+// StoreType gets type of the store, PVC store is used by default.
+func (c *Config) StoreType() StoreType {
+	if c == nil || c.Type == "" {
+		return PVCStore
+	}
+	return c.Type
+}
+
+// ForNamespace resolves the artifact store config for the given namespace, bucket and prefix
+// of the object storage are determined here.
+func (c *Config) ForNamespace(namespace string) *Config {
+	resolved := &Config{
+		Type:          c.StoreType(),
+		RetentionDays: c.RetentionDays,
+	}
+	if c.S3 == nil {
+		return resolved
+	}
+
+	s3 := *c.S3
+	if location, ok := c.Namespaces[namespace]; ok {
+		if location.Bucket != "" {
+			s3.Bucket = location.Bucket
+		}
+		s3.Prefix = location.Prefix
+	} else {
+		s3.Prefix = path.Join(s3.Prefix, namespace)
+	}
+	resolved.S3 = &s3
+
+	return resolved
+}
+
+// Validate validates the artifact store config.
+func (c *Config) Validate() error {
+	switch c.StoreType() {
+	case PVCStore:
+		return nil
+	case S3Store:
+		if c.S3 == nil || c.S3.Endpoint == "" || c.S3.Bucket == "" {
+			return fmt.Errorf("endpoint and bucket are required for s3 artifact store")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported artifact store type: %s", c.Type)
+	}
+}
+
+// Object describes an artifact in the store.
+type Object struct {
+	// Key of the artifact
+	Key string `json:"key"`
+	// Name of the file or directory
+	Name string `json:"name"`
+	// Whether the artifact is a directory, directories are stored as tar archive.
+	Dir bool `json:"dir"`
+	// Size of the stored data in bytes
+	Size int64 `json:"size"`
+	// Checksum of the stored data, in format sha256:<hex>
+	Checksum string `json:"checksum"`
+	// Time the artifact stored
+	ModTime time.Time `json:"modTime"`
+}
+
+// Store saves and retrieves artifacts. Artifacts are identified by keys in format
+// 'workflowruns/<wfr>/stages/<stage>/artifacts/<artifact>'.
+type Store interface {
+	// Put saves a local file or directory as artifact with the given key.
+	Put(key, src string) (*Object, error)
+	// Get retrieves the artifact to a local directory, data is verified against its checksum.
+	// The file or directory would be placed at <dst>/<object name>.
+	Get(key, dst string) (*Object, error)
+	// Open opens the stored data of the artifact, for a directory artifact, it's a tar stream.
+	Open(key string) (io.ReadCloser, *Object, error)
+	// Stat gets information of the artifact.
+	Stat(key string) (*Object, error)
+	// List lists artifacts with the given key prefix.
+	List(prefix string) ([]*Object, error)
+	// Delete deletes all artifacts with the given key prefix.
+	Delete(prefix string) error
+}
+
+// ErrNotFound indicates the artifact not exist in the store.
+type ErrNotFound struct {
+	Key string
+}
+
+func (e *ErrNotFound) Error() string {
+	return fmt.Sprintf("artifact %s not found", e.Key)
+}
+
+// IsNotFound checks whether the error is caused by artifact not found.
+func IsNotFound(err error) bool {
+	_, ok := err.(*ErrNotFound)
+	return ok
+}
+
+// Key gets key of an artifact.
+func Key(wfr, stage, artifact string) string {
+	return fmt.Sprintf("workflowruns/%s/stages/%s/artifacts/%s", wfr, stage, artifact)
+}
+
+// StagePrefix gets key prefix of all artifacts of a stage.
+func StagePrefix(wfr, stage string) string {
+	return fmt.Sprintf("workflowruns/%s/stages/%s/artifacts/", wfr, stage)
+}
+
+// WorkflowRunPrefix gets key prefix of all artifacts of a WorkflowRun.
+func WorkflowRunPrefix(wfr string) string {
+	return fmt.Sprintf("workflowruns/%s/", wfr)
+}
+
+// CacheKey gets key of an artifact cached under the stage cache key.
+func CacheKey(cacheKey, artifact string) string {
+	return fmt.Sprintf("caches/%s/artifacts/%s", cacheKey, artifact)
+}
+
+// CachePrefix gets key prefix of all artifacts cached under the stage cache key.
+func CachePrefix(cacheKey string) string {
+	return fmt.Sprintf("caches/%s/artifacts/", cacheKey)
+}
+
+// NewStore creates an artifact store from the config. 'root' is the local directory where
+// the PVC is mounted, and 'base' is the key prefix that the root directory corresponds to,
+// they are only used by PVC store.
+func NewStore(c *Config, root, base string) (Store, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch c.StoreType() {
+	case S3Store:
+		return NewS3Store(c.S3), nil
+	default:
+		return NewPVCStore(root, base), nil
+	}
+}
+
+// checksumReader computes checksum of data read, and verify it at EOF if expected
+// checksum provided.
+type checksumReader struct {
+	reader   io.Reader
+	hash     hash.Hash
+	expected string
+}
+
+func newChecksumReader(r io.Reader, expected string) *checksumReader {
+	return &checksumReader{
+		reader:   r,
+		hash:     sha256.New(),
+		expected: expected,
+	}
+}
+
+func (r *checksumReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF && r.expected != "" && r.Sum() != r.expected {
+		return n, fmt.Errorf("checksum mismatch, expected %s, got %s", r.expected, r.Sum())
+	}
+	return n, err
+}
+
+// Sum returns checksum of data read so far.
+func (r *checksumReader) Sum() string {
+	return "sha256:" + hex.EncodeToString(r.hash.Sum(nil))
+}
+
+// ParseKey parses WorkflowRun name, stage name and artifact name from an artifact key.
+func ParseKey(key string) (wfr, stage, artifact string, err error) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 6 || parts[0] != "workflowruns" || parts[2] != "stages" || parts[4] != "artifacts" {
+		return "", "", "", fmt.Errorf("invalid artifact key: %s", key)
+	}
+	return parts[1], parts[3], parts[5], nil
+}
@@ -0,0 +1,87 @@
+package cloudevents
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+)
+
+// SpecVersion is the CloudEvents specification version of events.
+const SpecVersion = "1.0"
+
+// Types of WorkflowRun lifecycle events.
+const (
+	// WorkflowRunCreated is sent when a WorkflowRun is observed by workflow controller for the first time.
+	WorkflowRunCreated = "io.cyclone.workflowrun.created"
+	// WorkflowRunStarted is sent when a WorkflowRun starts running.
+	WorkflowRunStarted = "io.cyclone.workflowrun.started"
+	// WorkflowRunStageChanged is sent when status of a stage changes.
+	WorkflowRunStageChanged = "io.cyclone.workflowrun.stage.changed"
+	// WorkflowRunWaiting is sent when a WorkflowRun is waiting for external events, e.g. approval.
+	WorkflowRunWaiting = "io.cyclone.workflowrun.waiting"
+	// WorkflowRunFinished is sent when a WorkflowRun terminates, i.e. Completed, Error or Cancelled.
+	WorkflowRunFinished = "io.cyclone.workflowrun.finished"
+)
+
+// Event is a CloudEvent in structured content mode.
+type Event struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// WorkflowRunData is data of WorkflowRun lifecycle events.
+type WorkflowRunData struct {
+	Tenant      string `json:"tenant"`
+	Project     string `json:"project"`
+	Workflow    string `json:"workflow"`
+	WorkflowRun string `json:"workflowRun"`
+	// Trigger is the WorkflowTrigger that created the WorkflowRun, empty if it's created by users.
+	Trigger string `json:"trigger,omitempty"`
+	// Status is the overall status of the WorkflowRun.
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+	// Stage is the changed stage, it's only set in stage changed events.
+	Stage *StageData `json:"stage,omitempty"`
+	// Stages are statuses of all stages, keyed by stage name.
+	Stages map[string]string `json:"stages,omitempty"`
+	// Resources are resolved versions of input resources, keyed by resource name.
+	Resources map[string]*v1alpha1.ResourceStatus `json:"resources,omitempty"`
+}
+
+// StageData describes the changed stage.
+type StageData struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// NewEvent creates an event of the WorkflowRun. ID of the event is derived from key, so that the
+// same event emitted again has the same ID and receivers can deduplicate it.
+func NewEvent(eventType, source, subject, key string, data *WorkflowRunData) (*Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s/%s/%s/%s", eventType, source, subject, key)))
+	return &Event{
+		SpecVersion:     SpecVersion,
+		ID:              hex.EncodeToString(sum[:]),
+		Source:          source,
+		Type:            eventType,
+		Subject:         subject,
+		Time:            time.Now().UTC(),
+		DataContentType: "application/json",
+		Data:            raw,
+	}, nil
+}
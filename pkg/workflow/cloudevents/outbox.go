@@ -0,0 +1,248 @@
+package cloudevents
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/cyclone/pkg/k8s/clientset"
+)
+
+const (
+	// LabelName is label of ConfigMaps that hold events to deliver.
+	LabelName = "cyclone.io/cloudevent"
+	// configMapPrefix is name prefix of ConfigMaps that hold events to deliver.
+	configMapPrefix = "cloudevent-"
+
+	// Keys of ConfigMap data.
+	eventKey     = "event"
+	deliveredKey = "delivered"
+	attemptsKey  = "attempts"
+	nextKey      = "next"
+
+	// interval is the interval to check events to deliver.
+	interval = 5 * time.Second
+	// timeout is the timeout of delivering an event to a sink.
+	timeout = 10 * time.Second
+	// maxBackoff is the maximum time to wait before retrying delivery.
+	maxBackoff = 5 * time.Minute
+)
+
+// Config configures publishing WorkflowRun lifecycle events as CloudEvents.
+type Config struct {
+	// Sinks are HTTP endpoints to post events to, no event is published if there is no sink.
+	Sinks []Sink `json:"sinks"`
+	// RetryCount is how many times to retry delivering an event to sinks, 0 means no retry.
+	RetryCount int `json:"retry"`
+}
+
+// Sink is an HTTP endpoint that receives events.
+type Sink struct {
+	URL string `json:"url"`
+	// Headers are extra headers sent to the sink, e.g. Authorization.
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Enabled checks whether events should be published.
+func (c *Config) Enabled() bool {
+	return len(c.Sinks) > 0
+}
+
+// Outbox delivers events to sinks. Events are saved in ConfigMaps before they are delivered,
+// and removed after all sinks received them, so that events survive controller restarts. Events
+// are delivered at least once in the order they are added, a failed event is retried with
+// exponential backoff and doesn't block following events.
+type Outbox struct {
+	client    clientset.Interface
+	namespace string
+	// config gets the latest config, since config may be reloaded.
+	config func() *Config
+	http   *http.Client
+	notify chan struct{}
+}
+
+// NewOutbox creates an outbox that saves events in the namespace.
+func NewOutbox(client clientset.Interface, namespace string, config func() *Config) *Outbox {
+	return &Outbox{
+		client:    client,
+		namespace: namespace,
+		config:    config,
+		http:      &http.Client{Timeout: timeout},
+		notify:    make(chan struct{}, 1),
+	}
+}
+
+// Add saves events to the outbox. Events already in the outbox are skipped.
+func (o *Outbox) Add(events ...*Event) error {
+	for _, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		_, err = o.client.CoreV1().ConfigMaps(o.namespace).Create(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   configMapPrefix + e.ID,
+				Labels: map[string]string{LabelName: "true"},
+			},
+			Data: map[string]string{
+				eventKey: string(data),
+			},
+		})
+		if err != nil && !errors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+
+	select {
+	case o.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Start starts delivering events in background.
+func (o *Outbox) Start() {
+	go func() {
+		ticker := time.NewTicker(interval)
+		for {
+			select {
+			case <-ticker.C:
+			case <-o.notify:
+			}
+			o.Flush()
+		}
+	}()
+}
+
+// Flush delivers events that are due in the outbox. Events are kept if there is no sink, they
+// are delivered after sinks are configured.
+func (o *Outbox) Flush() {
+	config := o.config()
+	if !config.Enabled() {
+		return
+	}
+
+	cms, err := o.client.CoreV1().ConfigMaps(o.namespace).List(metav1.ListOptions{
+		LabelSelector: LabelName + "=true",
+	})
+	if err != nil {
+		log.Warn("List CloudEvents in outbox error: ", err)
+		return
+	}
+
+	items := cms.Items
+	events := make(map[string]*Event)
+	for i := range items {
+		e := &Event{}
+		if err := json.Unmarshal([]byte(items[i].Data[eventKey]), e); err != nil {
+			log.WithField("configmap", items[i].Name).Warn("Invalid CloudEvent in outbox: ", err)
+		}
+		events[items[i].Name] = e
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return events[items[i].Name].Time.Before(events[items[j].Name].Time)
+	})
+
+	for i := range items {
+		o.deliver(config, &items[i], events[items[i].Name])
+	}
+}
+
+// deliver delivers the event to sinks that haven't received it.
+func (o *Outbox) deliver(config *Config, cm *corev1.ConfigMap, e *Event) {
+	if next, err := time.Parse(time.RFC3339, cm.Data[nextKey]); err == nil && time.Now().Before(next) {
+		return
+	}
+
+	var delivered []string
+	if cm.Data[deliveredKey] != "" {
+		delivered = strings.Split(cm.Data[deliveredKey], ",")
+	}
+	var failure error
+	if e.ID != "" {
+		for _, sink := range config.Sinks {
+			if contains(delivered, sink.URL) {
+				continue
+			}
+			if err := o.send(&sink, e); err != nil {
+				log.WithField("id", e.ID).WithField("sink", sink.URL).Warn("Deliver CloudEvent error: ", err)
+				failure = err
+				continue
+			}
+			delivered = append(delivered, sink.URL)
+		}
+	}
+
+	attempts, _ := strconv.Atoi(cm.Data[attemptsKey])
+	attempts++
+	if failure == nil || attempts > config.RetryCount {
+		if failure != nil {
+			log.WithField("id", e.ID).WithField("type", e.Type).Error("Give up delivering CloudEvent: ", failure)
+		}
+		if err := o.client.CoreV1().ConfigMaps(o.namespace).Delete(cm.Name, &metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			log.WithField("configmap", cm.Name).Warn("Remove CloudEvent from outbox error: ", err)
+		}
+		return
+	}
+
+	backoff := interval << uint(attempts-1)
+	if backoff > maxBackoff || backoff <= 0 {
+		backoff = maxBackoff
+	}
+	cm = cm.DeepCopy()
+	cm.Data[deliveredKey] = strings.Join(delivered, ",")
+	cm.Data[attemptsKey] = strconv.Itoa(attempts)
+	cm.Data[nextKey] = time.Now().Add(backoff).Format(time.RFC3339)
+	if _, err := o.client.CoreV1().ConfigMaps(o.namespace).Update(cm); err != nil {
+		log.WithField("configmap", cm.Name).Warn("Update CloudEvent in outbox error: ", err)
+	}
+}
+
+// send posts the event to the sink in structured content mode.
+func (o *Outbox) send(sink *Sink, e *Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, sink.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range sink.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/cloudevents+json; charset=utf-8")
+
+	resp, err := o.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("sink responds %d: %s", resp.StatusCode, bytes.TrimSpace(data))
+	}
+	return nil
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package cloudevents
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/cyclone/pkg/k8s/clientset/fake"
+)
+
+func TestNewEvent(t *testing.T) {
+	e1, err := NewEvent(WorkflowRunFinished, "/tenants/t1/projects/p1/workflows/wf", "wfr", "uid/Completed/1", &WorkflowRunData{WorkflowRun: "wfr"})
+	assert.Nil(t, err)
+	e2, _ := NewEvent(WorkflowRunFinished, "/tenants/t1/projects/p1/workflows/wf", "wfr", "uid/Completed/1", &WorkflowRunData{WorkflowRun: "wfr"})
+	e3, _ := NewEvent(WorkflowRunFinished, "/tenants/t1/projects/p1/workflows/wf", "wfr", "uid/Error/1", &WorkflowRunData{WorkflowRun: "wfr"})
+	assert.Equal(t, e1.ID, e2.ID)
+	assert.NotEqual(t, e1.ID, e3.ID)
+	assert.Equal(t, "1.0", e1.SpecVersion)
+	assert.Equal(t, `{"tenant":"","project":"","workflow":"","workflowRun":"wfr","status":""}`, string(e1.Data))
+}
+
+func TestOutbox(t *testing.T) {
+	var received []*Event
+	var headers []http.Header
+	failed := map[string]bool{}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failed[r.URL.Path] {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		e := &Event{}
+		data, _ := ioutil.ReadAll(r.Body)
+		assert.Nil(t, json.Unmarshal(data, e))
+		received = append(received, e)
+		headers = append(headers, r.Header)
+	}))
+	defer s.Close()
+
+	client := fake.NewSimpleClientset()
+	config := &Config{}
+	outbox := NewOutbox(client, "cyclone-system", func() *Config { return config })
+	count := func() int {
+		cms, err := client.CoreV1().ConfigMaps("cyclone-system").List(metav1.ListOptions{LabelSelector: LabelName + "=true"})
+		assert.Nil(t, err)
+		return len(cms.Items)
+	}
+
+	e1, _ := NewEvent(WorkflowRunCreated, "/s", "wfr", "1", &WorkflowRunData{})
+	e2, _ := NewEvent(WorkflowRunStarted, "/s", "wfr", "2", &WorkflowRunData{})
+	assert.Nil(t, outbox.Add(e1, e2))
+	assert.Nil(t, outbox.Add(e1))
+	assert.Equal(t, 2, count())
+
+	// Events are kept until sinks are configured.
+	outbox.Flush()
+	assert.Equal(t, 2, count())
+
+	config.Sinks = []Sink{{URL: s.URL + "/a", Headers: map[string]string{"Authorization": "Bearer t0ken"}}}
+	outbox.Flush()
+	assert.Equal(t, 0, count())
+	assert.Equal(t, 2, len(received))
+	assert.Equal(t, e1.ID, received[0].ID)
+	assert.Equal(t, e2.ID, received[1].ID)
+	assert.Equal(t, "Bearer t0ken", headers[0].Get("Authorization"))
+	assert.Equal(t, "application/cloudevents+json; charset=utf-8", headers[0].Get("Content-Type"))
+
+	// Failed deliveries are retried, sinks already received the event are skipped.
+	received = nil
+	failed["/b"] = true
+	config.Sinks = append(config.Sinks, Sink{URL: s.URL + "/b"})
+	config.RetryCount = 1
+	e3, _ := NewEvent(WorkflowRunFinished, "/s", "wfr", "3", &WorkflowRunData{})
+	assert.Nil(t, outbox.Add(e3))
+	outbox.Flush()
+	assert.Equal(t, 1, len(received))
+	cm, err := client.CoreV1().ConfigMaps("cyclone-system").Get(configMapPrefix+e3.ID, metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, s.URL+"/a", cm.Data[deliveredKey])
+	assert.Equal(t, "1", cm.Data[attemptsKey])
+
+	// Not due yet
+	outbox.Flush()
+	assert.Equal(t, 1, count())
+
+	cm.Data[nextKey] = ""
+	_, err = client.CoreV1().ConfigMaps("cyclone-system").Update(cm)
+	assert.Nil(t, err)
+	failed["/b"] = false
+	outbox.Flush()
+	assert.Equal(t, 0, count())
+	assert.Equal(t, 2, len(received))
+
+	// Give up after retries
+	failed["/a"] = true
+	config.RetryCount = 0
+	assert.Nil(t, outbox.Add(e1))
+	outbox.Flush()
+	assert.Equal(t, 0, count())
+}
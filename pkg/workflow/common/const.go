@@ -22,6 +22,23 @@ const (
 	// EnvCycloneServerAddr is an environment which represents cyclone server address.
 	EnvCycloneServerAddr = "CYCLONE_SERVER_ADDR"
 
+	// EnvArtifactStore is an environment which represents the artifact store config in JSON.
+	EnvArtifactStore = "ARTIFACT_STORE"
+	// EnvInputArtifacts is an environment which represents input artifacts to fetch in JSON.
+	EnvInputArtifacts = "INPUT_ARTIFACTS"
+	// EnvStageCacheKey is an environment which represents cache key of the stage, it's only set
+	// when cache is enabled for the stage.
+	EnvStageCacheKey = "STAGE_CACHE_KEY"
+	// EnvExecutor is an environment which represents the runtime executor used by coordinator.
+	EnvExecutor = "EXECUTOR"
+
+	// ExecutorKubernetes is the runtime executor talks to Kubernetes API only, outputs are
+	// collected from volumes shared between workload containers and coordinator.
+	ExecutorKubernetes = "k8sapi"
+	// ExecutorDocker is the runtime executor collects outputs with 'docker cp', it needs docker
+	// socket of the node mounted to coordinator.
+	ExecutorDocker = "docker"
+
 	// DefaultCycloneServerAddr defines default Cyclone Server address
 	DefaultCycloneServerAddr = "native-cyclone-server"
 
@@ -37,6 +54,46 @@ const (
 	// CoordinatorSidecarName defines name of coordinator container.
 	CoordinatorSidecarName = CycloneSidecarPrefix + "coordinator"
 
+	// ArtifactFetcherName defines name of the init container that fetches input artifacts from
+	// artifact store. It's only used when artifacts are not stored in PVC.
+	ArtifactFetcherName = "cyclone-artifact-fetcher"
+	// ArtifactFetcherCommand is the coordinator command to fetch input artifacts.
+	ArtifactFetcherCommand = "fetch"
+
+	// ArtifactReaderName defines name of the pod that serves artifacts in PVC to Cyclone Server,
+	// there is one in each tenant namespace where artifacts are stored in PVC.
+	ArtifactReaderName = "cyclone-artifact-reader"
+	// ArtifactReaderCommand is the coordinator command to serve artifacts in PVC.
+	ArtifactReaderCommand = "serve-artifacts"
+	// ArtifactReaderPort is the port artifact reader listens on.
+	ArtifactReaderPort = 8080
+	// ArtifactReaderPVCPath is path where PVC mounted to artifact reader container.
+	ArtifactReaderPVCPath = "/workspace/pvc"
+	// ArtifactReaderTokenKey is key of the token in the artifact reader secret, which has the same
+	// name as the artifact reader pod. Requests to artifact reader must carry the token.
+	ArtifactReaderTokenKey = "token"
+	// EnvArtifactReaderToken is an environment which represents token required by artifact reader.
+	EnvArtifactReaderToken = "ARTIFACT_READER_TOKEN"
+	// ArtifactReaderIdleTimeout is the time after which artifact reader exits if no request received.
+	ArtifactReaderIdleTimeout = 10 * time.Minute
+
+	// SidecarGateName defines name of the init container that copies coordinator binary to the
+	// sidecar gate volume, it's only added to stages with workload sidecars.
+	SidecarGateName = "cyclone-sidecar-gate"
+	// SidecarGateCommand is the coordinator command to wait workload sidecars ready and then run
+	// the workload command, it wraps command of the workload container.
+	SidecarGateCommand = "wait-sidecars"
+	// SidecarGateVolumeName is name of the emptyDir volume shared between coordinator and the
+	// workload container, coordinator notifies the workload that sidecars are ready through it.
+	SidecarGateVolumeName = "sidecar-gate"
+	// SidecarGatePath is path where the sidecar gate volume mounted to the workload container.
+	SidecarGatePath = "/cyclone/sidecar-gate"
+	// SidecarReadyFile is name of the file created by coordinator when workload sidecars are ready.
+	SidecarReadyFile = "ready"
+	// SidecarAbortFile is name of the file created by coordinator when workload sidecars fail to
+	// get ready, the workload would exit without running.
+	SidecarAbortFile = "abort"
+
 	// ResolverDefaultWorkspacePath is workspace path in resource resolver containers.
 	// Following files or directories will be in this workspace.
 	// - ${WORKFLOWRUN_NAME}-pulling.lock File lock determine which stage to pull the resource
@@ -49,6 +106,13 @@ const (
 	ResolverNotifyDir = "notify"
 	// ResolverNotifyDirPath is notify directory path in resource resolver container.
 	ResolverNotifyDirPath = "/workspace/notify"
+	// ResolverNotifyOkFile is name of the file created by coordinator in notify directory.
+	ResolverNotifyOkFile = "ok"
+	// ResolverTerminationLog is path of the termination message of resource resolvers, resolvers
+	// write key-value outputs there, e.g. resolved revision of the resource.
+	ResolverTerminationLog = "/dev/termination-log"
+	// EnvResolverWorkDir is an environment which represents workspace path in resource resolver containers.
+	EnvResolverWorkDir = "WORKDIR"
 
 	// ResourcePullCommand indicates pull resource
 	ResourcePullCommand = "pull"
@@ -61,12 +125,46 @@ const (
 	WorkflowRunLabelName = "cyclone.io/workflow-name"
 	// PodLabelSelector is selector used to select pod created by Cyclone stages
 	PodLabelSelector = "cyclone.io/workflow==true"
+	// WorkflowTriggerLabelName is label applied to WorkflowRun to specify WorkflowTrigger that created it
+	WorkflowTriggerLabelName = "cyclone.io/workflow-trigger"
 	// WorkflowRunAnnotationName is annotation applied to pod to specify WorkflowRun the pod belongs to
 	WorkflowRunAnnotationName = "cyclone.io/workflowrun"
 	// GCAnnotationName is annotation applied to pod to indicate whether the pod is used for GC purpose
 	GCAnnotationName = "cyclone.io/gc"
 	// StageAnnotationName is annotation applied to pod to indicate which stage it related to
 	StageAnnotationName = "cyclone.io/stage"
+	// StageCacheLabelName is label applied to ConfigMaps that hold cached stage results
+	StageCacheLabelName = "cyclone.io/stage-cache"
+	// StageCacheExpireAnnotationName is annotation applied to ConfigMaps of cached stage results, it
+	// holds the time in RFC3339 format when the results expire
+	StageCacheExpireAnnotationName = "cyclone.io/stage-cache-expire"
+	// SCMEventAnnotationName is annotation applied to WorkflowRun triggered by webhooks, it holds the
+	// event type, e.g. push, tag and pullRequest
+	SCMEventAnnotationName = "cyclone.io/scm-event"
+	// SCMRepoAnnotationName is annotation applied to WorkflowRun triggered by webhooks, it holds the
+	// repository of the event, e.g. 'caicloud/cyclone'
+	SCMRepoAnnotationName = "cyclone.io/scm-repo"
+	// SCMCommitAnnotationName is annotation applied to WorkflowRun triggered by webhooks, it holds
+	// SHA of the commit that triggered the run
+	SCMCommitAnnotationName = "cyclone.io/scm-commit"
+	// SCMPullRequestAnnotationName is annotation applied to WorkflowRun triggered by pull request or
+	// merge request events, it holds number of the pull request
+	SCMPullRequestAnnotationName = "cyclone.io/scm-pull-request"
+	// SCMIntegrationAnnotationName is annotation applied to WorkflowRun triggered by webhooks, it holds
+	// the SCM integration used to report statuses of the run to the commit
+	SCMIntegrationAnnotationName = "cyclone.io/scm-integration"
+	// SCMCommentAnnotationName is annotation applied to WorkflowRun triggered by pull request or merge
+	// request events, it's 'true' if results of the run should be commented on the pull request
+	SCMCommentAnnotationName = "cyclone.io/scm-comment"
+	// SCMReportedAnnotationName is annotation applied to WorkflowRun by workflow controller, it records
+	// the last commit status reported to SCM, so that statuses are not reported repeatedly
+	SCMReportedAnnotationName = "cyclone.io/scm-reported"
+	// NotifiedAnnotationName is annotation applied to WorkflowRun by workflow controller after
+	// notifications of the terminated run are sent, it holds the terminal status
+	NotifiedAnnotationName = "cyclone.io/notified"
+	// CloudEventsAnnotationName is annotation applied to WorkflowRun by workflow controller, it records
+	// statuses of the run and its stages that lifecycle events have been emitted for
+	CloudEventsAnnotationName = "cyclone.io/cloudevents"
 	// StageTemplateLabelName indicates whether a stage is used as stage template
 	StageTemplateLabelName = "cyclone.io/stage-template"
 	// StageTemplateLabelSelector is label selector to select stage templates
@@ -82,6 +180,16 @@ const (
 	CoordinatorResolverNotifyOkPath = "/workspace/resolvers/notify/ok"
 	// CoordinatorArtifactsPath ...
 	CoordinatorArtifactsPath = "/workspace/artifacts"
+	// CoordinatorVolumesPath is path where volumes of the workload container mounted to
+	// coordinator, so that outputs on them can be collected without container runtime.
+	CoordinatorVolumesPath = "/workspace/volumes"
+	// CoordinatorCachePath is path where cache directory of the stage in PVC mounted to coordinator.
+	CoordinatorCachePath = "/workspace/cache"
+	// CoordinatorSidecarGatePath is path where the sidecar gate volume mounted to coordinator.
+	CoordinatorSidecarGatePath = "/workspace/sidecar-gate"
+	// CoordinatorTerminationLog is path of the coordinator termination message, checksums of
+	// output artifacts are written there for Workflow Controller to record.
+	CoordinatorTerminationLog = "/dev/termination-log"
 
 	// DefaultPvVolumeName is name of the default PV used by all workflow stages.
 	DefaultPvVolumeName = "default-pv"
@@ -89,7 +197,15 @@ const (
 	// sidecar containers, e.g. image resolvers. Coordinator would notify resolvers that workload
 	// containers have finished their work, so that resource resolvers can push resources.
 	CoordinatorSidecarVolumeName = "coordinator-sidecar-volume"
-	// DockerSockVolume is volume name to mount host /var/run/docker.sock to container, it's used by coordinator.
+	// InputArtifactsVolumeName is name of the emptyDir volume holding input artifacts fetched
+	// from artifact store.
+	InputArtifactsVolumeName = "input-artifacts"
+	// InputArtifactsPath is path where input artifacts fetched to in artifact fetcher container.
+	InputArtifactsPath = "/workspace/inputs"
+	// ArtifactFetcherPVCPath is path where PVC mounted to artifact fetcher container.
+	ArtifactFetcherPVCPath = "/workspace/pvc"
+	// DockerSockVolume is volume name to mount host /var/run/docker.sock to workload containers, it's
+	// only mounted when stage asks for it.
 	DockerSockVolume = "docker-sock"
 	// DockerConfigJSONVolume is volume for config.json in secret.
 	DockerConfigJSONVolume = "cyclone-docker-secret-volume"
@@ -102,10 +218,44 @@ const (
 	// DockerConfigJSONFile is name of docker config file
 	DockerConfigJSONFile = "config.json"
 
+	// RegistrySecretName is name of the secret holding docker registry auth of a tenant. It's
+	// generated from DockerRegistry integrations in the tenant namespace, and is used both to
+	// mount config.json to resource resolvers and as imagePullSecrets of stage pods.
+	RegistrySecretName = "cyclone-registry-auth"
+
+	// SCMSecretPrefix is name prefix of secrets holding credentials of SCM integrations, they are
+	// generated from SCM integrations and mounted to git resource resolvers.
+	SCMSecretPrefix = "cyclone-scm-"
+	// SCMSecretTokenKey is key of the token in SCM secrets.
+	SCMSecretTokenKey = "token"
+	// SCMSecretKnownHostsKey is key of the SSH known_hosts in SCM secrets.
+	SCMSecretKnownHostsKey = "known_hosts"
+	// SCMSecretPath is path where SCM secret mounted to git resource resolvers.
+	SCMSecretPath = "/etc/cyclone/scm"
+
+	// IntegrationSecretPrefix is name prefix of secrets holding environment variables converted
+	// from integrations, they are injected into resource resolvers of resources that have the
+	// 'INTEGRATION' parameter.
+	IntegrationSecretPrefix = "cyclone-integration-"
+
+	// ArtifactStoreSecretName is name of the secret holding artifact store config of a tenant,
+	// including credentials of object storage. It's injected into coordinator and artifact fetcher
+	// as environment variable EnvArtifactStore.
+	ArtifactStoreSecretName = "cyclone-artifact-store"
+
+	// IntegrationTypeLabelName is label applied to integration secrets to indicate integration
+	// type, it should be consistent with Cyclone Server.
+	IntegrationTypeLabelName = "cyclone.io/integration-type"
+	// IntegrationSecretKey is key of integration data in integration secrets, it should be
+	// consistent with Cyclone Server.
+	IntegrationSecretKey = "integration"
+
 	// ContainerStateTerminated represents container is stopped.
 	ContainerStateTerminated ContainerState = "Terminated"
 	// ContainerStateInitialized represents container is Running or Stopped, not Init or Creating.
 	ContainerStateInitialized ContainerState = "Initialized"
+	// ContainerStateReady represents container is Running and ready, i.e. passed its readiness probe.
+	ContainerStateReady ContainerState = "Ready"
 )
 
 const (
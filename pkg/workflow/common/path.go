@@ -30,7 +30,36 @@ func ArtifactPath(wfr, stage, artifact string) string {
 	return fmt.Sprintf("workflowruns/%s/stages/%s/artifacts/%s", wfr, stage, artifact)
 }
 
+// CachesPath indicates stage caches data path in PV
+func CachesPath() string {
+	return "caches"
+}
+
+// CacheArtifactsPath gets the path of artifacts cached under the stage cache key in PV.
+func CacheArtifactsPath(key string) string {
+	return fmt.Sprintf("caches/%s/artifacts/", key)
+}
+
 // ResourcePath gets the path of a resource in PV
 func ResourcePath(wfr, resource string) string {
 	return fmt.Sprintf("workflowruns/%s/resources/%s", wfr, resource)
 }
+
+// PullingLockFile gets name of the lock file in resolver workspace, it's used to ensure only one
+// resolver pulls the resource in a WorkflowRun.
+func PullingLockFile(wfr string) string {
+	return fmt.Sprintf("%s-pulling.lock", wfr)
+}
+
+// PulledMarkerFile gets name of the file in resolver workspace indicating that the resource has
+// been pulled in the WorkflowRun. Data of persistent resources are kept across WorkflowRuns, so
+// existence of data doesn't mean it's pulled for the current WorkflowRun.
+func PulledMarkerFile(wfr string) string {
+	return fmt.Sprintf("%s-pulled", wfr)
+}
+
+// PulledOutputsFile gets name of the file in resolver workspace holding key-value outputs of
+// pulling, so that stages in the WorkflowRun that don't pull the resource can get them.
+func PulledOutputsFile(wfr string) string {
+	return fmt.Sprintf("%s-outputs.json", wfr)
+}
@@ -0,0 +1,36 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+)
+
+// CoordinatorResult is result of a stage reported by coordinator in its termination message, it's
+// written whether the stage succeeded or not.
+type CoordinatorResult struct {
+	// Message describes result of the stage, e.g. why it failed.
+	Message string `json:"message,omitempty"`
+	// Checksums of collected output artifacts, keyed by artifact name.
+	Artifacts map[string]string `json:"artifacts,omitempty"`
+	// Compute resource usage of containers in the stage pod, keyed by container name.
+	Usage map[string]v1alpha1.ContainerUsage `json:"usage,omitempty"`
+}
+
+// ParseCoordinatorResult parses termination message of coordinator. Message of old coordinators,
+// which only contains checksums of output artifacts, is also supported.
+func ParseCoordinatorResult(message string) (*CoordinatorResult, error) {
+	result := &CoordinatorResult{}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(message)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(result); err == nil {
+		return result, nil
+	}
+
+	artifacts := make(map[string]string)
+	if err := json.Unmarshal([]byte(message), &artifacts); err != nil {
+		return nil, err
+	}
+	return &CoordinatorResult{Artifacts: artifacts}, nil
+}
@@ -29,6 +29,11 @@ func NonWorkloadSidecar(name string) bool {
 	return true
 }
 
+// OnlyWorkloadSidecar selects only workload sidecars.
+func OnlyWorkloadSidecar(name string) bool {
+	return strings.HasPrefix(name, WorkloadSidecarPrefix)
+}
+
 // NonCoordinator selects all containers except coordinator.
 func NonCoordinator(name string) bool {
 	return name != CoordinatorSidecarName
@@ -10,6 +10,9 @@ import (
 	corev1 "k8s.io/api/core/v1"
 
 	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/workflow/artifact"
+	"github.com/caicloud/cyclone/pkg/workflow/cloudevents"
+	"github.com/caicloud/cyclone/pkg/workflow/common"
 )
 
 const (
@@ -23,10 +26,20 @@ const (
 
 	// GitResolverImage is key of git source resolver image in config file
 	GitResolverImage = "git-resolver"
+	// SVNResolverImage is key of svn source resolver image in config file
+	SVNResolverImage = "svn-resolver"
 	// ImageResolverImage is key of image source resolver image in config file
 	ImageResolverImage = "image-resolver"
 	// KvResolverImage is key of kv source resolver image in config file
 	KvResolverImage = "kv-resolver"
+	// HTTPResolverImage is key of HTTP file resolver image in config file
+	HTTPResolverImage = "http-resolver"
+	// S3ResolverImage is key of S3 object resolver image in config file
+	S3ResolverImage = "s3-resolver"
+	// HelmResolverImage is key of Helm chart resolver image in config file
+	HelmResolverImage = "helm-resolver"
+	// MavenResolverImage is key of Maven artifact resolver image in config file
+	MavenResolverImage = "maven-resolver"
 	// CoordinatorImage is key of coordinator image in config file
 	CoordinatorImage = "coordinator"
 	// GCImage is key of the GC image in config file
@@ -36,8 +49,13 @@ const (
 // ResolverImageKeys maps resource type to resolver images
 var ResolverImageKeys = map[v1alpha1.ResourceType]string{
 	v1alpha1.GitResourceType:   GitResolverImage,
+	v1alpha1.SVNResourceType:   SVNResolverImage,
 	v1alpha1.ImageResourceType: ImageResolverImage,
 	v1alpha1.KVResourceType:    KvResolverImage,
+	v1alpha1.HTTPResourceType:  HTTPResolverImage,
+	v1alpha1.S3ResourceType:    S3ResolverImage,
+	v1alpha1.HelmResourceType:  HelmResolverImage,
+	v1alpha1.MavenResourceType: MavenResolverImage,
 }
 
 // WorkflowControllerConfig configures Workflow Controller
@@ -55,14 +73,36 @@ type WorkflowControllerConfig struct {
 	// PVC used to transfer artifacts in WorkflowRun, and also to help share resources
 	// among stages within WorkflowRun. If no PVC is given here, input resources won't be
 	// shared among stages, but need to be pulled every time it's needed. And also if no
-	// PVC given, artifacts are not supported.
+	// PVC given, artifacts are supported only with object storage artifact store.
 	// TODO(ChenDe): Remove it when Cyclone can manage PVC for namespaces.
 	PVC string `json:"pvc"`
 	// Secret is default secret used for Cyclone, auth of registry can be placed here. It's optional.
-	// TODO(ChenDe): Remove it when Cyclone can manage secrets for namespaces.
+	// Registry auth is generated from DockerRegistry integrations of each tenant, this secret is
+	// only used for tenants that have no DockerRegistry integrated.
 	Secret string `json:"secret"`
 	// CycloneServerAddr is address of the Cyclone Server
 	CycloneServerAddr string `json:"cyclone_server_addr"`
+	// Artifact configures the store to save artifacts, PVC is used by default.
+	Artifact artifact.Config `json:"artifact"`
+	// Executor is the runtime executor used by coordinator to collect outputs, 'docker' (default)
+	// or 'k8sapi'. Docker executor mounts docker socket of the node to coordinator, k8sapi executor
+	// needs no docker, but can only collect outputs placed on volumes.
+	Executor string `json:"executor"`
+	// RunURL is the URL of WorkflowRun details linked in commit statuses and notifications,
+	// placeholders {tenant}, {project}, {workflow} and {workflowrun} are replaced. No link is
+	// given if it's empty.
+	RunURL string `json:"run_url"`
+	// CloudEvents configures publishing lifecycle events of WorkflowRuns.
+	CloudEvents cloudevents.Config `json:"cloud_events"`
+}
+
+// ExecutorType gets the runtime executor used by coordinator, default to docker, since outputs
+// may be placed anywhere in workload containers.
+func (c *WorkflowControllerConfig) ExecutorType() string {
+	if c.Executor == "" {
+		return common.ExecutorDocker
+	}
+	return c.Executor
 }
 
 // LoggingConfig configures logging
@@ -113,11 +153,24 @@ func LoadConfig(cm *corev1.ConfigMap) error {
 // validate validates some required configurations.
 func validate(config *WorkflowControllerConfig) bool {
 	if config.PVC == "" {
-		log.Warn("PVC not configured, resources won't be shared among stages and artifacts unsupported.")
+		log.Warn("PVC not configured, resources won't be shared among stages.")
+		if config.Artifact.StoreType() == artifact.PVCStore {
+			log.Warn("Neither PVC nor object storage configured, artifacts unsupported.")
+		}
+	}
+
+	if err := config.Artifact.Validate(); err != nil {
+		log.Error("Invalid artifact store config: ", err)
+		return false
+	}
+
+	if t := config.ExecutorType(); t != common.ExecutorKubernetes && t != common.ExecutorDocker {
+		log.WithField("executor", t).Error("Unsupported executor")
+		return false
 	}
 
 	if config.Secret == "" {
-		log.Warn("Secret not configured, no auth information would be available, e.g. docker registry auth.")
+		log.Warn("Secret not configured, only DockerRegistry integrations of tenants would provide docker registry auth.")
 	}
 
 	for _, k := range []string{GitResolverImage, ImageResolverImage, KvResolverImage, CoordinatorImage} {
@@ -127,6 +180,14 @@ func validate(config *WorkflowControllerConfig) bool {
 		}
 	}
 
+	// Resolvers of these resource types are optional, resources of the types can't be resolved
+	// without them configured.
+	for _, k := range []string{SVNResolverImage, HTTPResolverImage, S3ResolverImage, HelmResolverImage, MavenResolverImage} {
+		if _, ok := config.Images[k]; !ok {
+			log.WithField("key", k).Warn("Resolver image not configured")
+		}
+	}
+
 	return true
 }
 
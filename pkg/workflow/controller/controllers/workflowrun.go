@@ -12,8 +12,9 @@ import (
 	"github.com/caicloud/cyclone/pkg/workflow/workflowrun"
 )
 
-// NewWorkflowRunController ...
-func NewWorkflowRunController(client clientset.Interface) *Controller {
+// NewWorkflowRunController creates WorkflowRun controller, namespace is where the controller runs,
+// CloudEvents to deliver are saved there.
+func NewWorkflowRunController(client clientset.Interface, namespace string) *Controller {
 	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
 	factory := informers.NewSharedInformerFactory(
 		client,
@@ -56,6 +57,9 @@ func NewWorkflowRunController(client clientset.Interface) *Controller {
 			TimeoutProcessor: workflowrun.NewTimeoutProcessor(client),
 			GCProcessor:      workflowrun.NewGCProcessor(client, controller.Config.GC.Enabled),
 			LimitedQueues:    workflowrun.NewLimitedQueues(client, controller.Config.Limits.MaxWorkflowRuns),
+			SCMReporter:      workflowrun.NewSCMReporter(client),
+			Notifier:         workflowrun.NewNotifier(client),
+			EventEmitter:     workflowrun.NewEventEmitter(client, namespace),
 		},
 	}
 }
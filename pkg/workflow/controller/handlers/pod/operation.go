@@ -1,7 +1,10 @@
 package pod
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
@@ -92,6 +95,15 @@ func (p *Operator) OnUpdated() error {
 
 	switch p.pod.Status.Phase {
 	case corev1.PodFailed:
+		// Workload sidecars are stopped by coordinator after workload finished, the pod fails if
+		// they exit with non-zero code, stage status is determined by coordinator in that case,
+		// since exit codes of workload sidecars don't affect stage result.
+		if terminated := coordinatorState(p.pod); terminated != nil && terminated.ExitCode == 0 {
+			if !ok || status.Status.Status != v1alpha1.StatusCompleted {
+				p.DetermineStatus(wfrOperator)
+			}
+			break
+		}
 		if !ok || status.Status.Status != v1alpha1.StatusError {
 			log.WithField("wfr", wfr.Name).
 				WithField("stg", p.stage).
@@ -102,6 +114,7 @@ func (p *Operator) OnUpdated() error {
 				LastTransitionTime: metav1.Time{Time: time.Now()},
 				Reason:             "PodFailed",
 			})
+			p.recordUsage(wfrOperator, coordinatorResult(coordinatorState(p.pod)))
 		}
 	case corev1.PodSucceeded:
 		if !ok || status.Status.Status != v1alpha1.StatusCompleted {
@@ -114,6 +127,9 @@ func (p *Operator) OnUpdated() error {
 				LastTransitionTime: metav1.Time{Time: time.Now()},
 				Reason:             "PodSucceed",
 			})
+			result := coordinatorResult(coordinatorState(p.pod))
+			p.recordUsage(wfrOperator, result)
+			p.onStageCompleted(wfrOperator, result)
 		}
 	default:
 		p.DetermineStatus(wfrOperator)
@@ -150,10 +166,18 @@ func (p *Operator) DetermineStatus(wfrOperator workflowrun.Operator) {
 	}
 
 	// Now the workload containers and coordinator container have all been finished. We then:
+	// - Record versions of input resources resolved in the stage.
 	// - Update the stage status in WorkflowRun based on coordinator's exit code.
 	// - TODO(ChenDe): Delete pod
+	p.recordResources(wfrOperator)
+	result := coordinatorResult(terminatedCoordinatorState)
+	p.recordUsage(wfrOperator, result)
 
 	if terminatedCoordinatorState.ExitCode != 0 {
+		message := terminatedCoordinatorState.Message
+		if result != nil {
+			message = result.Message
+		}
 		log.WithField("wfr", wfrOperator.GetWorkflowRun().Name).
 			WithField("stg", p.stage).
 			WithField("status", v1alpha1.StatusError).
@@ -162,7 +186,7 @@ func (p *Operator) DetermineStatus(wfrOperator workflowrun.Operator) {
 			Status:             v1alpha1.StatusError,
 			LastTransitionTime: metav1.Time{Time: time.Now()},
 			Reason:             terminatedCoordinatorState.Reason,
-			Message:            terminatedCoordinatorState.Message,
+			Message:            message,
 		})
 	} else {
 		log.WithField("wfr", wfrOperator.GetWorkflowRun().Name).
@@ -175,5 +199,141 @@ func (p *Operator) DetermineStatus(wfrOperator workflowrun.Operator) {
 			Reason:             "CoordinatorCompleted",
 			Message:            "Coordinator completed",
 		})
+		p.onStageCompleted(wfrOperator, result)
+	}
+}
+
+// onStageCompleted records checksums of output artifacts reported by coordinator and key-value
+// outputs reported by resource resolvers in their termination messages, and saves results of
+// the stage to cache if cache enabled.
+func (p *Operator) onStageCompleted(wfrOperator workflowrun.Operator, result *common.CoordinatorResult) {
+	if outputs := resolverOutputs(p.pod); len(outputs) > 0 {
+		wfrOperator.UpdateStageOutputs(p.stage, outputs)
+	}
+
+	if result != nil && len(result.Artifacts) > 0 {
+		wfrOperator.UpdateStageArtifacts(p.stage, result.Artifacts)
+	}
+
+	if err := wfrOperator.SaveStageCache(p.stage); err != nil {
+		log.WithField("wfr", wfrOperator.GetWorkflowRun().Name).
+			WithField("stg", p.stage).
+			Warn("Save stage cache error: ", err)
+	}
+}
+
+// recordResources records versions of input resources resolved in the stage, they are reported
+// by input resolvers in their termination messages.
+func (p *Operator) recordResources(wfrOperator workflowrun.Operator) {
+	for _, containerStatus := range p.pod.Status.InitContainerStatuses {
+		if !isResolver(containerStatus.Name) {
+			continue
+		}
+		values := terminationValues(containerStatus.Name, containerStatus.State.Terminated)
+		if len(values) == 0 {
+			continue
+		}
+		if err := wfrOperator.UpdateResourceStatus(p.stage, containerStatus.Name, values); err != nil {
+			log.WithField("wfr", wfrOperator.GetWorkflowRun().Name).
+				WithField("stg", p.stage).
+				WithField("resource", containerStatus.Name).
+				Warn("Update resource status error: ", err)
+		}
+	}
+}
+
+// recordUsage records resource usage of containers sampled by coordinator, it's recorded whether
+// the stage succeeded or not.
+func (p *Operator) recordUsage(wfrOperator workflowrun.Operator, result *common.CoordinatorResult) {
+	if result != nil && len(result.Usage) > 0 {
+		wfrOperator.UpdateStageUsage(p.stage, result.Usage)
+	}
+}
+
+// coordinatorResult parses result reported by coordinator in its termination message, nil is
+// returned if coordinator not terminated or nothing reported.
+func coordinatorResult(terminated *corev1.ContainerStateTerminated) *common.CoordinatorResult {
+	if terminated == nil || terminated.Message == "" {
+		return nil
+	}
+
+	result, err := common.ParseCoordinatorResult(terminated.Message)
+	if err != nil {
+		log.Warn("Parse coordinator termination message error: ", err)
+		return nil
+	}
+	return result
+}
+
+// coordinatorState gets terminated state of the coordinator container in the pod, nil is
+// returned if coordinator not terminated.
+func coordinatorState(pod *corev1.Pod) *corev1.ContainerStateTerminated {
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		if containerStatus.Name == common.CoordinatorSidecarName {
+			return containerStatus.State.Terminated
+		}
+	}
+	return nil
+}
+
+// resolverOutputs collects key-value outputs written by resource resolvers. Input resolvers run as
+// init containers named after resources, and output resolvers run as sidecars named with prefix
+// common.CycloneSidecarPrefix, keys are prefixed with the resource name.
+func resolverOutputs(pod *corev1.Pod) []v1alpha1.KeyValue {
+	var outputs []v1alpha1.KeyValue
+	for _, containerStatus := range pod.Status.InitContainerStatuses {
+		if !isResolver(containerStatus.Name) {
+			continue
+		}
+		outputs = append(outputs, terminationOutputs(containerStatus.Name, containerStatus.State.Terminated)...)
+	}
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		if !strings.HasPrefix(containerStatus.Name, common.CycloneSidecarPrefix) || containerStatus.Name == common.CoordinatorSidecarName {
+			continue
+		}
+		resource := strings.TrimPrefix(containerStatus.Name, common.CycloneSidecarPrefix)
+		outputs = append(outputs, terminationOutputs(resource, containerStatus.State.Terminated)...)
+	}
+
+	return outputs
+}
+
+// isResolver checks whether the init container is an input resolver, other init containers are
+// added by Cyclone for artifacts and workload sidecars.
+func isResolver(name string) bool {
+	return name != common.ArtifactFetcherName && name != common.SidecarGateName
+}
+
+// terminationOutputs parses key-value outputs in termination message of the resolver container.
+func terminationOutputs(resource string, terminated *corev1.ContainerStateTerminated) []v1alpha1.KeyValue {
+	values := terminationValues(resource, terminated)
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var outputs []v1alpha1.KeyValue
+	for _, k := range keys {
+		outputs = append(outputs, v1alpha1.KeyValue{
+			Key:   resource + "." + k,
+			Value: values[k],
+		})
+	}
+	return outputs
+}
+
+// terminationValues parses termination message of the resolver container as key-value map, nil
+// is returned if the container is not terminated or the message is not key-value outputs.
+func terminationValues(resource string, terminated *corev1.ContainerStateTerminated) map[string]string {
+	if terminated == nil || terminated.Message == "" {
+		return nil
+	}
+
+	values := make(map[string]string)
+	if err := json.Unmarshal([]byte(terminated.Message), &values); err != nil {
+		log.WithField("resource", resource).Debug("Termination message is not key-value outputs: ", err)
+		return nil
 	}
+	return values
 }
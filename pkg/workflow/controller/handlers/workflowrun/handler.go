@@ -15,6 +15,9 @@ type Handler struct {
 	TimeoutProcessor *workflowrun.TimeoutProcessor
 	GCProcessor      *workflowrun.GCProcessor
 	LimitedQueues    *workflowrun.LimitedQueues
+	SCMReporter      *workflowrun.SCMReporter
+	Notifier         *workflowrun.Notifier
+	EventEmitter     *workflowrun.EventEmitter
 }
 
 // Ensure *Handler has implemented handlers.Interface interface.
@@ -38,6 +41,15 @@ func (h *Handler) ObjectCreated(obj interface{}) {
 	// the GC queue.
 	h.GCProcessor.Add(originWfr)
 
+	// Report status of the WorkflowRun to SCM in background if it's changed.
+	h.SCMReporter.Report(originWfr)
+
+	// Send notifications in background if the WorkflowRun has terminated.
+	h.Notifier.Notify(originWfr)
+
+	// Emit lifecycle events for changes of the WorkflowRun, they are delivered in background.
+	h.EventEmitter.Emit(originWfr)
+
 	// If the WorkflowRun has already been terminated or waiting for external events, skip it.
 	if originWfr.Status.Overall.Status == v1alpha1.StatusCompleted ||
 		originWfr.Status.Overall.Status == v1alpha1.StatusError ||
@@ -76,6 +88,15 @@ func (h *Handler) ObjectUpdated(obj interface{}) {
 	// the GC queue.
 	h.GCProcessor.Add(originWfr)
 
+	// Report status of the WorkflowRun to SCM in background if it's changed.
+	h.SCMReporter.Report(originWfr)
+
+	// Send notifications in background if the WorkflowRun has terminated.
+	h.Notifier.Notify(originWfr)
+
+	// Emit lifecycle events for changes of the WorkflowRun, they are delivered in background.
+	h.EventEmitter.Emit(originWfr)
+
 	// If the WorkflowRun has already been terminated(Completed, Error, Cancel) or waiting for external events, skip it.
 	if originWfr.Status.Overall.Status == v1alpha1.StatusCompleted ||
 		originWfr.Status.Overall.Status == v1alpha1.StatusError ||
@@ -8,10 +8,12 @@ import (
 
 	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
 	"github.com/caicloud/cyclone/pkg/k8s/clientset"
+	servercommon "github.com/caicloud/cyclone/pkg/server/common"
 	"github.com/caicloud/cyclone/pkg/workflow/common"
 	"github.com/robfig/cron"
 	log "github.com/sirupsen/logrus"
 	errors2 "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/rand"
 )
 
@@ -80,6 +82,13 @@ func (m *CronTriggerManager) DeleteTrigger(wftKey string) {
 	}
 }
 
+func (m *CronTriggerManager) hasTrigger(wftKey string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	_, ok := m.CronTriggerMap[wftKey]
+	return ok
+}
+
 // ToWorkflowTrigger ...
 func ToWorkflowTrigger(obj interface{}) (*v1alpha1.WorkflowTrigger, error) {
 
@@ -132,6 +141,10 @@ func getParamValue(items []v1alpha1.ParameterItem, key string) (string, bool) {
 
 // CreateCron ...
 func (m *CronTriggerManager) CreateCron(wft *v1alpha1.WorkflowTrigger) {
+	// Webhook triggers are handled by Cyclone Server when webhooks received.
+	if wft.Spec.Type == v1alpha1.WebhookTrigger {
+		return
+	}
 
 	schedule, has := getParamValue(wft.Spec.Parameters, ParamSchedule)
 	if !has {
@@ -156,6 +169,11 @@ func (m *CronTriggerManager) CreateCron(wft *v1alpha1.WorkflowTrigger) {
 	}
 
 	wfr := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				servercommon.LabelProject: wft.Labels[servercommon.LabelProject],
+			},
+		},
 		Spec: wft.Spec.WorkflowRunSpec,
 	}
 
@@ -187,6 +205,10 @@ func (m *CronTriggerManager) UpdateCron(wft *v1alpha1.WorkflowTrigger) {
 // DeleteCron ...
 func (m *CronTriggerManager) DeleteCron(wft *v1alpha1.WorkflowTrigger) {
 	wftKey := getKeyFromWorkflowTrigger(wft)
+	// Webhook triggers have no cron job, unless they are updated from Schedule triggers.
+	if wft.Spec.Type == v1alpha1.WebhookTrigger && !m.hasTrigger(wftKey) {
+		return
+	}
 	m.DeleteTrigger(wftKey)
 }
 
@@ -1,9 +1,13 @@
 package coordinator
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path"
+	"strings"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -15,8 +19,12 @@ import (
 	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
 	"github.com/caicloud/cyclone/pkg/k8s/clientset"
 	fileutil "github.com/caicloud/cyclone/pkg/util/file"
+	"github.com/caicloud/cyclone/pkg/workflow/artifact"
 	"github.com/caicloud/cyclone/pkg/workflow/common"
+	"github.com/caicloud/cyclone/pkg/workflow/coordinator/cycloneserver"
+	"github.com/caicloud/cyclone/pkg/workflow/coordinator/docker"
 	"github.com/caicloud/cyclone/pkg/workflow/coordinator/k8sapi"
+	"github.com/caicloud/cyclone/pkg/workflow/report"
 )
 
 // Coordinator is a struct which contains infomations
@@ -24,6 +32,21 @@ import (
 type Coordinator struct {
 	runtimeExec       RuntimeExecutor
 	workloadContainer string
+	artifactStore     artifact.Store
+	// Store to cache output artifacts, nil if cache not enabled for the stage.
+	cacheStore artifact.Store
+	cacheKey   string
+	// Checksums of collected output artifacts, keyed by artifact name.
+	checksums map[string]string
+	// logs tracks goroutines collecting container logs.
+	logs sync.WaitGroup
+	// Client to send test reports to Cyclone server.
+	cycloneClient cycloneserver.Client
+	// usage records resource usage samples of containers.
+	usage *usageRecorder
+	// stopSampling stops sampling resource usage, sampling tracks the sampling goroutine.
+	stopSampling chan struct{}
+	sampling     sync.WaitGroup
 	// Stage which this run pod belonged to.
 	Stage *v1alpha1.Stage
 	// WorkflowRun which triggered this run pod.
@@ -32,14 +55,18 @@ type Coordinator struct {
 	Recorder record.EventRecorder
 }
 
+// maxTerminationMessageLength is the max length of termination message kept by Kubernetes.
+const maxTerminationMessageLength = 4096
+
 // RuntimeExecutor is an interface defined some methods
 // to communicate with k8s container runtime.
 type RuntimeExecutor interface {
 	WaitContainers(state common.ContainerState, selectors ...common.ContainerSelector) error
-	CollectLog(container, wrorkflowrun, stage string) error
+	CollectLog(container string, wfr *v1alpha1.WorkflowRun, stage string) error
 	CopyFromContainer(container, path, dst string) error
 	GetPod() (*core_v1.Pod, error)
 	GetResource(name string) (*v1alpha1.Resource, error)
+	SampleUsage() (map[string]core_v1.ResourceList, error)
 }
 
 // NewCoordinator create a coordinator instance.
@@ -80,9 +107,38 @@ func NewCoordinator(client clientset.Interface, kubecfg string) (*Coordinator, e
 		return nil, err
 	}
 
+	store, err := getArtifactStore(wfrName, stageName)
+	if err != nil {
+		log.WithField("error", err).Error("Create artifact store failed")
+		return nil, err
+	}
+
+	cacheKey := getStageCacheKey()
+	cacheStore, err := getCacheStore(cacheKey)
+	if err != nil {
+		log.WithField("error", err).Error("Create cache store failed")
+		return nil, err
+	}
+
+	var runtimeExec RuntimeExecutor
+	switch e := getExecutor(); e {
+	case common.ExecutorKubernetes:
+		runtimeExec = k8sapi.NewK8sapiExecutor(namespace, getPodName(), client, getCycloneServerAddr(), kubecfg)
+	case common.ExecutorDocker:
+		runtimeExec = docker.NewDockerExecutor(namespace, getPodName(), client, getCycloneServerAddr(), kubecfg)
+	default:
+		return nil, fmt.Errorf("unsupported executor %s", e)
+	}
+
 	return &Coordinator{
-		runtimeExec:       k8sapi.NewK8sapiExecutor(namespace, getPodName(), client, getCycloneServerAddr(), kubecfg),
+		runtimeExec:       runtimeExec,
 		workloadContainer: getWorkloadContainer(),
+		artifactStore:     store,
+		cacheStore:        cacheStore,
+		cacheKey:          cacheKey,
+		checksums:         make(map[string]string),
+		cycloneClient:     cycloneserver.NewClient(getCycloneServerAddr()),
+		usage:             newUsageRecorder(),
 		Stage:             stage,
 		Wfr:               wfr,
 		Recorder:          common.GetEventRecorder(client, common.EventSourceCoordinator),
@@ -97,42 +153,61 @@ func (co *Coordinator) CollectLogs() {
 	}
 
 	for _, c := range cs {
-		go func(container, workflowrun, stage string) {
-			err := co.runtimeExec.CollectLog(container, workflowrun, stage)
+		co.logs.Add(1)
+		go func(container string) {
+			defer co.logs.Done()
+			err := co.runtimeExec.CollectLog(container, co.Wfr, co.Stage.Name)
 			if err != nil {
 				log.Errorf("Collect %s log failed:%v", container, err)
 			}
-		}(c, co.Wfr.Name, co.Stage.Name)
+		}(c)
 	}
 
 }
 
+// WaitLogs waits logs of all containers to be pushed to Cyclone server, at most the timeout.
+// It returns false if timed out.
+func (co *Coordinator) WaitLogs(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		co.logs.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
 // WaitRunning waits all containers to start run.
-func (co *Coordinator) WaitRunning() {
+func (co *Coordinator) WaitRunning() error {
 	err := co.runtimeExec.WaitContainers(common.ContainerStateInitialized)
 	if err != nil {
 		log.Errorf("Wait containers to running error: %v", err)
-		return
 	}
+	return err
 }
 
 // WaitWorkloadTerminate waits all workload containers to be Terminated status.
-func (co *Coordinator) WaitWorkloadTerminate() {
+func (co *Coordinator) WaitWorkloadTerminate() error {
 	err := co.runtimeExec.WaitContainers(common.ContainerStateTerminated, common.OnlyWorkload)
 	if err != nil {
 		log.Errorf("Wait containers to completion error: %v", err)
-		return
 	}
+	return err
 }
 
 // WaitAllOthersTerminate waits all containers except for
 // the coordinator container itself to become Terminated status.
-func (co *Coordinator) WaitAllOthersTerminate() {
+func (co *Coordinator) WaitAllOthersTerminate() error {
 	err := co.runtimeExec.WaitContainers(common.ContainerStateTerminated, common.NonWorkloadSidecar, common.NonCoordinator)
 	if err != nil {
 		log.Errorf("Wait containers to completion error: %v", err)
-		return
 	}
+	return err
 }
 
 // StageSuccess checks if the workload and resolver containers are succeeded.
@@ -213,26 +288,118 @@ func (co *Coordinator) CollectArtifacts() error {
 	// Create the artifacts directory if not exist.
 	fileutil.CreateDirectory(common.CoordinatorArtifactsPath)
 
-	for _, artifact := range artifacts {
-		dst := path.Join(common.CoordinatorArtifactsPath, artifact.Name)
+	for _, a := range artifacts {
+		dst := path.Join(common.CoordinatorArtifactsPath, a.Name)
 		fileutil.CreateDirectory(dst)
 
-		id, err := co.getContainerID(co.workloadContainer)
+		collected, err := artifact.Collect(co.copyFunc(co.workloadContainer), a.Name, strings.TrimSuffix(a.Path, "/"), a.OutputOptions, dst)
 		if err != nil {
-			log.Errorf("get container %s's id failed: %v", co.workloadContainer, err)
+			log.Errorf("Collect container %s artifact %s failed: %v", co.workloadContainer, a.Name, err)
 			return err
 		}
 
-		err = co.runtimeExec.CopyFromContainer(id, artifact.Path, dst)
+		obj, err := co.artifactStore.Put(artifact.Key(co.Wfr.Name, co.Stage.Name, a.Name), collected)
 		if err != nil {
-			log.Errorf("Copy container %s artifact %s failed: %v", co.workloadContainer, artifact.Name, err)
+			log.Errorf("Store artifact %s failed: %v", a.Name, err)
 			return err
 		}
+		log.WithField("artifact", a.Name).WithField("checksum", obj.Checksum).Info("Artifact stored")
+		co.checksums[a.Name] = obj.Checksum
+
+		if co.cacheStore != nil {
+			if _, err := co.cacheStore.Put(artifact.CacheKey(co.cacheKey, a.Name), collected); err != nil {
+				log.Errorf("Cache artifact %s failed: %v", a.Name, err)
+				return err
+			}
+			log.WithField("artifact", a.Name).WithField("key", co.cacheKey).Info("Artifact cached")
+		}
 	}
 
 	return nil
 }
 
+// CollectReports collects test reports of the workload, parses them and sends results to Cyclone
+// server. It's called regardless of the workload result, since reports of failed tests are the
+// most useful ones. Failure of a report doesn't stop collecting others, the last error is returned.
+func (co *Coordinator) CollectReports() error {
+	if co.Stage.Spec.Pod == nil {
+		return fmt.Errorf("get stage output reports failed, stage pod nil")
+	}
+
+	reports := co.Stage.Spec.Pod.Outputs.Reports
+	if len(reports) == 0 {
+		return nil
+	}
+
+	dir, err := ioutil.TempDir("", "reports-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	var lastErr error
+	for _, r := range reports {
+		if err := co.collectReport(r, dir); err != nil {
+			log.WithField("report", r.Name).WithField("error", err).Error("Collect report failed")
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
+
+func (co *Coordinator) collectReport(r v1alpha1.ReportItem, dir string) error {
+	options := r.OutputOptions
+	options.Archive = nil
+	collected, err := artifact.Collect(co.copyFunc(co.workloadContainer), r.Name, strings.TrimSuffix(r.Path, "/"), options, path.Join(dir, r.Name))
+	if err != nil {
+		return err
+	}
+
+	result, err := report.Parse(r.Format, collected)
+	if err != nil {
+		return err
+	}
+	result.Stage = co.Stage.Name
+	result.Name = r.Name
+	log.WithField("report", r.Name).WithField("summary", result.Summary).WithField("coverage", result.Coverage).Info("Report parsed")
+
+	return co.cycloneClient.SendTestReport(cycloneserver.NewWorkflowRunRef(co.Wfr), result)
+}
+
+// WriteTerminationMessage writes result of the stage to the termination message of coordinator
+// container, including the message, checksums of collected output artifacts and resource usage
+// of containers, Workflow Controller records them in WorkflowRun status.
+func (co *Coordinator) WriteTerminationMessage(message string) error {
+	result := &common.CoordinatorResult{
+		Message:   message,
+		Artifacts: co.checksums,
+		Usage:     co.usage.usage(),
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	// Kubernetes truncates termination message longer than the limit, which breaks the JSON, so
+	// the message is shortened to fit.
+	for len(data) > maxTerminationMessageLength && result.Message != "" {
+		result.Message = result.Message[:len(result.Message)/2]
+		if data, err = json.Marshal(result); err != nil {
+			return err
+		}
+	}
+
+	return ioutil.WriteFile(common.CoordinatorTerminationLog, data, 0644)
+}
+
+// copyFunc gets function to copy file or directory from the container.
+func (co *Coordinator) copyFunc(container string) artifact.CopyFunc {
+	return func(src, dst string) error {
+		return co.runtimeExec.CopyFromContainer(container, src, dst)
+	}
+}
+
 // CollectResources collects workload resources.
 func (co *Coordinator) CollectResources() error {
 	if co.Stage.Spec.Pod == nil {
@@ -266,13 +433,7 @@ func (co *Coordinator) CollectResources() error {
 		dst := path.Join(common.CoordinatorResourcesPath, resource.Name)
 		fileutil.CreateDirectory(dst)
 
-		id, err := co.getContainerID(co.workloadContainer)
-		if err != nil {
-			log.Errorf("get container %s's id failed: %v", co.workloadContainer, err)
-			return err
-		}
-
-		err = co.runtimeExec.CopyFromContainer(id, resource.Path, dst)
+		_, err = artifact.Collect(co.copyFunc(co.workloadContainer), resource.Name, strings.TrimSuffix(resource.Path, "/"), resource.OutputOptions, dst)
 		if err != nil {
 			log.Errorf("Copy container %s resources %s failed: %v", co.workloadContainer, resource.Name, err)
 			return err
@@ -323,18 +484,3 @@ func (co *Coordinator) getAllOtherContainers() ([]string, error) {
 
 	return cs, nil
 }
-
-func (co *Coordinator) getContainerID(name string) (string, error) {
-	pod, err := co.runtimeExec.GetPod()
-	if err != nil {
-		return "", err
-	}
-
-	for _, cs := range pod.Status.ContainerStatuses {
-		if cs.Name == name {
-			return refineContainerID(cs.ContainerID), nil
-		}
-	}
-
-	return "", fmt.Errorf("container %s not found", name)
-}
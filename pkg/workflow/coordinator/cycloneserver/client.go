@@ -1,11 +1,11 @@
 package cycloneserver
 
 import (
-	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strings"
@@ -14,23 +14,82 @@ import (
 	"github.com/gorilla/websocket"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+	servercommon "github.com/caicloud/cyclone/pkg/server/common"
+	httputil "github.com/caicloud/cyclone/pkg/util/http"
 	websocketutil "github.com/caicloud/cyclone/pkg/util/websocket"
 )
 
 const (
 	cycloneAPIVersion = "/apis/v1alpha1"
 
-	apiPathForLogStream = "/workflowruns/%s/stages/%s/streamlogs"
+	apiPathForLogStream = "/projects/%s/workflows/%s/workflowruns/%s/streamlogs"
+
+	apiPathForReports = "/projects/%s/workflows/%s/workflowruns/%s/reports"
+
+	// logBatchSize is the max size of log sent to Cyclone server in one message, it must not
+	// exceed websocketutil.MaxLogChunkSize.
+	logBatchSize = 256 * 1024
+	// logFlushInterval is the max time log is buffered before sent to Cyclone server.
+	logFlushInterval = time.Second
+	// logAckTimeout is the time to wait Cyclone server to acknowledge a message.
+	logAckTimeout = 30 * time.Second
+	// minReconnectBackoff and maxReconnectBackoff bound the interval between reconnections.
+	minReconnectBackoff = time.Second
+	maxReconnectBackoff = 30 * time.Second
+
+	// serviceAccountTokenPath is path of the service account token mounted to pods, it's used
+	// to authenticate to Cyclone server.
+	serviceAccountTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
 )
 
+// WorkflowRunRef identifies a WorkflowRun in Cyclone server.
+type WorkflowRunRef struct {
+	Tenant      string
+	Project     string
+	Workflow    string
+	WorkflowRun string
+}
+
+// NewWorkflowRunRef gets reference of the WorkflowRun in Cyclone server.
+func NewWorkflowRunRef(wfr *v1alpha1.WorkflowRun) WorkflowRunRef {
+	ref := WorkflowRunRef{
+		Tenant:      servercommon.NamespaceTenant(wfr.Namespace),
+		Project:     wfr.Labels[servercommon.LabelProject],
+		WorkflowRun: wfr.Name,
+	}
+	if wfr.Spec.WorkflowRef != nil {
+		ref.Workflow = wfr.Spec.WorkflowRef.Name
+	}
+	return ref
+}
+
+// LogStream identifies log of a container in a stage of WorkflowRun.
+type LogStream struct {
+	WorkflowRunRef
+	Stage     string
+	Container string
+}
+
 // Client ...
 type Client interface {
-	PushLogStream(workflowrun, stage, container string, reader io.Reader, close chan struct{}) error
+	// PushLogStream pushes log read from the reader to Cyclone server until EOF of the reader.
+	// Log is buffered in local file, and resumed from where Cyclone server stored after
+	// reconnection, so it's not lost if the connection breaks.
+	PushLogStream(stream LogStream, reader io.Reader) error
+	// SendTestReport sends test report of a stage in the WorkflowRun to Cyclone server.
+	SendTestReport(wfr WorkflowRunRef, report *api.TestReport) error
 }
 
 type client struct {
 	baseURL string
 	client  *http.Client
+	dialer  *websocket.Dialer
+	// spoolDir is directory of local files to buffer logs.
+	spoolDir string
+	// token is bearer token to authenticate to Cyclone server, no authentication if empty.
+	token string
 }
 
 // NewClient ...
@@ -40,14 +99,29 @@ func NewClient(cycloneServer string) Client {
 		baseURL = "http://" + baseURL
 	}
 
+	token, err := ioutil.ReadFile(serviceAccountTokenPath)
+	if err != nil {
+		log.Warningf("Read service account token error: %v", err)
+	}
+
 	return &client{
 		baseURL: baseURL,
 		client:  http.DefaultClient,
+		dialer:  websocket.DefaultDialer,
+		token:   strings.TrimSpace(string(token)),
+	}
+}
+
+// setHeaders sets tenant and credential headers of requests to Cyclone server.
+func (c *client) setHeaders(header http.Header, tenant string) {
+	header.Set(httputil.TenantHeaderName, tenant)
+	if c.token != "" {
+		header.Set("Authorization", "Bearer "+c.token)
 	}
 }
 
 // do sends the request to Cyclone and returns an HTTP response.
-func (c *client) do(method, relativePath string, bodyObject interface{}) (*http.Response, error) {
+func (c *client) do(method, relativePath, tenant string, bodyObject interface{}) (*http.Response, error) {
 	url := c.baseURL + cycloneAPIVersion + relativePath
 	log.Infof("Request for Cyclone server: %s %s", method, url)
 
@@ -66,74 +140,147 @@ func (c *client) do(method, relativePath string, bodyObject interface{}) (*http.
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	c.setHeaders(req.Header, tenant)
 	resp, err := c.client.Do(req)
 	if err != nil {
-		log.Errorf(err.Error())
+		log.Error(err)
 		return nil, err
 	}
 
 	return resp, nil
 }
 
-// PushLogStream ...
-func (c *client) PushLogStream(workflowrun, stage, container string, reader io.Reader, close chan struct{}) error {
-	path := fmt.Sprintf(apiPathForLogStream, workflowrun, stage)
-	host := strings.TrimPrefix(c.baseURL, "http://")
-	host = strings.TrimPrefix(host, "https://")
-	requestURL := url.URL{
-		Host:     host,
-		Path:     cycloneAPIVersion + path,
-		RawQuery: fmt.Sprintf("container=%s", container),
-		Scheme:   "ws",
+// SendTestReport ...
+func (c *client) SendTestReport(wfr WorkflowRunRef, report *api.TestReport) error {
+	path := fmt.Sprintf(apiPathForReports, wfr.Project, wfr.Workflow, wfr.WorkflowRun)
+	resp, err := c.do(http.MethodPost, path, wfr.Tenant, report)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("send test report %s error: %s, %s", report.Name, resp.Status, string(body))
 	}
+	return nil
+}
 
-	log.Infof("Path: %s", requestURL.String())
+// logStreamURL gets URL of the websocket to push the log stream, wss is used for https server.
+func (c *client) logStreamURL(stream LogStream) (string, error) {
+	u, err := url.Parse(c.baseURL)
+	if err != nil {
+		return "", err
+	}
 
-	header := http.Header{
-		"Connection":            []string{"Upgrade"},
-		"Upgrade":               []string{"websocket"},
-		"Host":                  []string{host},
-		"Sec-Websocket-Key":     []string{"SGVsbG8sIHdvcmxkIQ=="},
-		"Sec-Websocket-Version": []string{"13"},
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	default:
+		u.Scheme = "ws"
 	}
-	filteredHeader := websocketutil.FilterHeader(header)
+	u.Path = strings.TrimRight(u.Path, "/") + cycloneAPIVersion +
+		fmt.Sprintf(apiPathForLogStream, stream.Project, stream.Workflow, stream.WorkflowRun)
+	u.RawQuery = url.Values{
+		httputil.StageNameQueryParameter:     []string{stream.Stage},
+		httputil.ContainerNameQueryParameter: []string{stream.Container},
+	}.Encode()
 
-	ws, _, err := websocket.DefaultDialer.Dial(requestURL.String(), filteredHeader)
+	return u.String(), nil
+}
+
+// PushLogStream ...
+func (c *client) PushLogStream(stream LogStream, reader io.Reader) error {
+	spool, err := newLogSpool(c.spoolDir)
 	if err != nil {
-		log.Errorf("Fail to new the WebSocket connection as %s", err.Error())
 		return err
 	}
-	defer ws.Close()
+	defer spool.remove()
+	go spool.readFrom(reader)
 
-	return watchLogs(ws, reader, close)
+	logger := log.WithField("stage", stream.Stage).WithField("container", stream.Container)
+	backoff := minReconnectBackoff
+	for {
+		connected, err := c.shipLogs(stream, spool)
+		if err == nil {
+			return spool.err()
+		}
+
+		if connected {
+			backoff = minReconnectBackoff
+		}
+		logger.WithField("error", err).Warningf("Push log stream interrupted, reconnect in %s", backoff)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > maxReconnectBackoff {
+			backoff = maxReconnectBackoff
+		}
+	}
 }
 
-func watchLogs(ws *websocket.Conn, reader io.Reader, close chan struct{}) error {
-	//logFile, err := os.Open(filePath)
-	//if err != nil {
-	//	log.Error(err.Error())
-	//	return err
-	//}
-	//defer logFile.Close()
+// shipLogs connects to Cyclone server and sends logs in the spool from where the server has
+// stored, until all logs sent or error happens. It tells whether connection was established.
+func (c *client) shipLogs(stream LogStream, spool *logSpool) (bool, error) {
+	requestURL, err := c.logStreamURL(stream)
+	if err != nil {
+		return false, err
+	}
+
+	header := http.Header{}
+	c.setHeaders(header, stream.Tenant)
+	ws, _, err := c.dialer.Dial(requestURL, header)
+	if err != nil {
+		return false, err
+	}
+	defer ws.Close()
+
+	offset, err := readLogOffset(ws)
+	if err != nil {
+		return true, err
+	}
+	log.WithField("url", requestURL).WithField("offset", offset).Info("Log stream connected")
 
-	buf := bufio.NewReader(reader)
-	ticker := time.NewTicker(10 * time.Millisecond)
 	for {
-		select {
-		case <-ticker.C:
-			line, errRead := buf.ReadBytes('\n')
-			if errRead != nil {
-				if errRead == io.EOF {
-					continue
-				}
-				log.Errorf("watch log file errs: %v", errRead)
-				ws.WriteMessage(websocket.CloseMessage, []byte(errRead.Error()))
-				return errRead
+		spool.wait(offset, logFlushInterval)
+		size, done := spool.state()
+		if size <= offset {
+			if done {
+				ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return true, nil
 			}
-			ws.WriteMessage(websocket.TextMessage, line)
-		case <-close:
-			log.Info("Close the watch of log file")
-			return nil
+
+			// Keep the connection alive while there is no log.
+			if err := ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(websocketutil.WriteWait)); err != nil {
+				return true, err
+			}
+			continue
+		}
+
+		data, err := spool.read(offset, logBatchSize)
+		if err != nil {
+			return true, err
+		}
+		message, err := websocketutil.EncodeLogChunk(offset, data)
+		if err != nil {
+			return true, err
+		}
+
+		ws.SetWriteDeadline(time.Now().Add(websocketutil.WriteWait))
+		if err := ws.WriteMessage(websocket.BinaryMessage, message); err != nil {
+			return true, err
+		}
+		if offset, err = readLogOffset(ws); err != nil {
+			return true, err
 		}
 	}
 }
+
+// readLogOffset reads offset of the log stored by Cyclone server.
+func readLogOffset(ws *websocket.Conn) (int64, error) {
+	ws.SetReadDeadline(time.Now().Add(logAckTimeout))
+	var offset websocketutil.LogOffset
+	if err := ws.ReadJSON(&offset); err != nil {
+		return 0, err
+	}
+
+	return offset.Offset, nil
+}
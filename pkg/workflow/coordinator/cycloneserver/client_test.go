@@ -0,0 +1,130 @@
+package cycloneserver
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	websocketutil "github.com/caicloud/cyclone/pkg/util/websocket"
+)
+
+// logServer stores logs pushed to it, and drops the first connection after a chunk received
+// without acknowledging it.
+type logServer struct {
+	lock        sync.Mutex
+	logs        bytes.Buffer
+	connections int
+	paths       []string
+	tenants     []string
+}
+
+func (s *logServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ws, err := websocketutil.Upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer ws.Close()
+
+	s.lock.Lock()
+	s.connections++
+	drop := s.connections == 1
+	s.paths = append(s.paths, r.URL.String())
+	s.tenants = append(s.tenants, r.Header.Get("X-Tenant"))
+	s.lock.Unlock()
+
+	for {
+		s.lock.Lock()
+		size := int64(s.logs.Len())
+		s.lock.Unlock()
+		if err := ws.WriteJSON(websocketutil.LogOffset{Offset: size}); err != nil {
+			return
+		}
+
+		_, message, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		offset, data, err := websocketutil.DecodeLogChunk(message)
+		if err != nil {
+			return
+		}
+
+		s.lock.Lock()
+		if offset <= size && offset+int64(len(data)) > size {
+			s.logs.Write(data[size-offset:])
+		}
+		s.lock.Unlock()
+		if drop {
+			return
+		}
+	}
+}
+
+func TestPushLogStream(t *testing.T) {
+	server := &logServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.Write([]byte("line 1\n"))
+		time.Sleep(2 * logFlushInterval)
+		writer.Write([]byte("line 2\n"))
+		writer.Write([]byte("line 3\n"))
+		writer.Close()
+	}()
+
+	c := NewClient(ts.URL)
+	err := c.PushLogStream(LogStream{
+		WorkflowRunRef: WorkflowRunRef{
+			Tenant:      "devops",
+			Project:     "p",
+			Workflow:    "wf",
+			WorkflowRun: "wfr",
+		},
+		Stage:     "build",
+		Container: "main",
+	}, reader)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "line 1\nline 2\nline 3\n", server.logs.String())
+	assert.Equal(t, 2, server.connections)
+	assert.Equal(t, "/apis/v1alpha1/projects/p/workflows/wf/workflowruns/wfr/streamlogs?container=main&stage=build", server.paths[0])
+	assert.Equal(t, "devops", server.tenants[0])
+}
+
+func TestLogStreamURL(t *testing.T) {
+	stream := LogStream{
+		WorkflowRunRef: WorkflowRunRef{Project: "p", Workflow: "wf", WorkflowRun: "wfr"},
+		Stage:          "build",
+		Container:      "main",
+	}
+	cases := map[string]string{
+		"cyclone-server:7099":                "ws://cyclone-server:7099/apis/v1alpha1/projects/p/workflows/wf/workflowruns/wfr/streamlogs?container=main&stage=build",
+		"https://cyclone.example.com/":       "wss://cyclone.example.com/apis/v1alpha1/projects/p/workflows/wf/workflowruns/wfr/streamlogs?container=main&stage=build",
+		"http://gateway.example.com/cyclone": "ws://gateway.example.com/cyclone/apis/v1alpha1/projects/p/workflows/wf/workflowruns/wfr/streamlogs?container=main&stage=build",
+	}
+	for server, expected := range cases {
+		u, err := NewClient(server).(*client).logStreamURL(stream)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, u)
+	}
+}
+
+func TestLogChunk(t *testing.T) {
+	message, err := websocketutil.EncodeLogChunk(1024, []byte("hello"))
+	assert.Nil(t, err)
+	offset, data, err := websocketutil.DecodeLogChunk(message)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1024), offset)
+	assert.Equal(t, "hello", string(data))
+
+	_, _, err = websocketutil.DecodeLogChunk([]byte{0, 1})
+	assert.Error(t, err)
+}
@@ -0,0 +1,139 @@
+package cycloneserver
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// logSpool buffers a log stream in a local file, so that logs can be sent again from any offset
+// after reconnecting to Cyclone server.
+type logSpool struct {
+	file *os.File
+
+	lock    sync.Mutex
+	size    int64
+	done    bool
+	readErr error
+	// notify is signaled when logs are appended to the spool.
+	notify chan struct{}
+}
+
+// newLogSpool creates a spool with a temporary file in the directory, default temporary
+// directory is used if dir is empty.
+func newLogSpool(dir string) (*logSpool, error) {
+	file, err := ioutil.TempFile(dir, "log-spool-")
+	if err != nil {
+		return nil, err
+	}
+
+	return &logSpool{
+		file:   file,
+		notify: make(chan struct{}, 1),
+	}, nil
+}
+
+// readFrom copies logs from the reader to the spool until EOF or error.
+func (s *logSpool) readFrom(reader io.Reader) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := reader.Read(buf)
+		if n > 0 {
+			if _, werr := s.file.Write(buf[:n]); werr != nil {
+				s.finish(werr)
+				return
+			}
+			s.lock.Lock()
+			s.size += int64(n)
+			s.lock.Unlock()
+			s.signal()
+		}
+		if err == io.EOF {
+			s.finish(nil)
+			return
+		}
+		if err != nil {
+			s.finish(err)
+			return
+		}
+	}
+}
+
+func (s *logSpool) finish(err error) {
+	if err != nil {
+		log.Errorf("Read log stream error: %v", err)
+	}
+	s.lock.Lock()
+	s.done = true
+	s.readErr = err
+	s.lock.Unlock()
+	s.signal()
+}
+
+func (s *logSpool) signal() {
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// state returns size of logs in the spool, and whether the log stream is finished.
+func (s *logSpool) state() (int64, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.size, s.done
+}
+
+// err returns the error happened when reading the log stream.
+func (s *logSpool) err() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.readErr
+}
+
+// wait waits until a batch of logs after the offset is available, the log stream is finished,
+// or the timeout expires.
+func (s *logSpool) wait(offset int64, timeout time.Duration) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		size, done := s.state()
+		if done || size-offset >= logBatchSize {
+			return
+		}
+
+		select {
+		case <-s.notify:
+		case <-timer.C:
+			return
+		}
+	}
+}
+
+// read reads at most limit bytes of logs starting at the offset.
+func (s *logSpool) read(offset int64, limit int) ([]byte, error) {
+	size, _ := s.state()
+	if size-offset < int64(limit) {
+		limit = int(size - offset)
+	}
+
+	data := make([]byte, limit)
+	n, err := s.file.ReadAt(data, offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return data[:n], nil
+}
+
+// remove closes and deletes the spool file.
+func (s *logSpool) remove() {
+	s.file.Close()
+	if err := os.Remove(s.file.Name()); err != nil {
+		log.Warningf("Remove log spool %s error: %v", s.file.Name(), err)
+	}
+}
@@ -0,0 +1,67 @@
+package docker
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/caicloud/cyclone/pkg/k8s/clientset"
+	"github.com/caicloud/cyclone/pkg/workflow/coordinator/k8sapi"
+)
+
+// Executor copies outputs from containers with 'docker cp', so that outputs not placed on volumes
+// can also be collected. It requires docker socket of the node mounted to coordinator, other
+// operations are performed through Kubernetes API.
+type Executor struct {
+	*k8sapi.Executor
+}
+
+// NewDockerExecutor ...
+func NewDockerExecutor(n string, pod string, client clientset.Interface, cycloneServer string, kubecfg string) *Executor {
+	return &Executor{
+		Executor: k8sapi.NewK8sapiExecutor(n, pod, client, cycloneServer, kubecfg),
+	}
+}
+
+// CopyFromContainer copy a file/directory frome container:path to dst. Docker is used instead of
+// kubectl since kubectl can not cp a file from a stopped container.
+func (d *Executor) CopyFromContainer(container, path, dst string) error {
+	id, err := d.containerID(container)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"cp", fmt.Sprintf("%s:%s", id, path), dst}
+	cmd := exec.Command("docker", args...)
+	log.WithField("args", args).Info()
+	ret, err := cmd.CombinedOutput()
+	log.WithField("message", string(ret)).WithField("error", err).Info("copy file result")
+	return err
+}
+
+// containerID gets docker container id of the container in the pod.
+func (d *Executor) containerID(name string) (string, error) {
+	pod, err := d.GetPod()
+	if err != nil {
+		return "", err
+	}
+
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.Name == name {
+			return refineContainerID(cs.ContainerID), nil
+		}
+	}
+
+	return "", fmt.Errorf("container %s not found", name)
+}
+
+// refineContainerID strips the 'docker://' prefix from k8s ContainerID string
+func refineContainerID(id string) string {
+	schemeIndex := strings.Index(id, "://")
+	if schemeIndex == -1 {
+		return id
+	}
+	return id[schemeIndex+3:]
+}
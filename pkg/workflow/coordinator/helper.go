@@ -2,8 +2,8 @@ package coordinator
 
 import (
 	"os"
-	"strings"
 
+	"github.com/caicloud/cyclone/pkg/workflow/artifact"
 	"github.com/caicloud/cyclone/pkg/workflow/common"
 )
 
@@ -31,6 +31,45 @@ func getCycloneServerAddr() string {
 	return addr
 }
 
+// getArtifactStore creates artifact store from config in environment variable. For PVC store, the
+// artifacts directory of the stage in PVC is mounted to coordinator.
+func getArtifactStore(wfr, stage string) (artifact.Store, error) {
+	config, err := artifact.DecodeConfig(os.Getenv(common.EnvArtifactStore))
+	if err != nil {
+		return nil, err
+	}
+
+	return artifact.NewStore(config, common.CoordinatorArtifactsPath, artifact.StagePrefix(wfr, stage))
+}
+
+func getStageCacheKey() string {
+	return os.Getenv(common.EnvStageCacheKey)
+}
+
+// getCacheStore creates artifact store to cache output artifacts under the stage cache key, nil
+// is returned if cache is not enabled for the stage. For PVC store, the cache directory in PVC is
+// mounted to coordinator.
+func getCacheStore(key string) (artifact.Store, error) {
+	if key == "" {
+		return nil, nil
+	}
+
+	config, err := artifact.DecodeConfig(os.Getenv(common.EnvArtifactStore))
+	if err != nil {
+		return nil, err
+	}
+
+	return artifact.NewStore(config, common.CoordinatorCachePath, artifact.CachePrefix(key))
+}
+
+func getExecutor() string {
+	e := os.Getenv(common.EnvExecutor)
+	if e == "" {
+		return common.ExecutorDocker
+	}
+	return e
+}
+
 func getNamespace() string {
 	n := os.Getenv(common.EnvNamespace)
 	if n == "" {
@@ -39,12 +78,3 @@ func getNamespace() string {
 
 	return n
 }
-
-// refineContainerID strips the 'docker://' prefix from k8s ContainerID string
-func refineContainerID(id string) string {
-	schemeIndex := strings.Index(id, "://")
-	if schemeIndex == -1 {
-		return id
-	}
-	return id[schemeIndex+3:]
-}
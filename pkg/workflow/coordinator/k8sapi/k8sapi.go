@@ -2,15 +2,22 @@ package k8sapi
 
 import (
 	"fmt"
-	"os/exec"
+	"path"
+	"strings"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 	core_v1 "k8s.io/api/core/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
 
 	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
 	"github.com/caicloud/cyclone/pkg/k8s/clientset"
+	"github.com/caicloud/cyclone/pkg/workflow/artifact"
 	"github.com/caicloud/cyclone/pkg/workflow/common"
 	"github.com/caicloud/cyclone/pkg/workflow/coordinator/cycloneserver"
 )
@@ -22,6 +29,10 @@ type Executor struct {
 	namespace     string
 	podName       string
 	cycloneClient cycloneserver.Client
+	// nodeName is name of the node where the pod runs, it's used to get stats from kubelet.
+	nodeName string
+	// noKubeletStats is set if stats can't be got from kubelet, metrics API is used instead.
+	noKubeletStats bool
 }
 
 // NewK8sapiExecutor ...
@@ -35,55 +46,150 @@ func NewK8sapiExecutor(n string, pod string, client clientset.Interface, cyclone
 	}
 }
 
-// WaitContainers waits containers that pass selectors.
-func (k *Executor) WaitContainers(expectState common.ContainerState, selectors ...common.ContainerSelector) error {
-	ticker := time.NewTicker(time.Second * 1)
-	defer ticker.Stop()
+// resyncPeriod is resync period of the pod informer used to wait containers, status of the pod
+// is checked at least once in the period even if no change is observed.
+const resyncPeriod = time.Second * 30
+
+// unrecoverableWaitingReasons are reasons of waiting containers that won't start without changes
+// to the pod spec.
+var unrecoverableWaitingReasons = map[string]bool{
+	"ErrImageNeverPull":          true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+}
 
+// WaitContainers waits containers that pass selectors. Instead of polling the pod, it watches
+// the stage pod with field selector, and stops waiting with error if the pod terminates or is
+// deleted before the containers reach expected state, e.g. evicted.
+func (k *Executor) WaitContainers(expectState common.ContainerState, selectors ...common.ContainerSelector) error {
 	log.Infof("Starting to wait for containers of pod %s to be %s ...", k.podName, expectState)
+
+	// Informer handlers are called sequentially, only the latest pod is kept in the channel.
+	pods := make(chan *core_v1.Pod, 1)
+	deleted := make(chan struct{})
+	var once sync.Once
+	notify := func(obj interface{}) {
+		pod, ok := obj.(*core_v1.Pod)
+		if !ok {
+			return
+		}
+		select {
+		case pods <- pod:
+		default:
+			select {
+			case <-pods:
+			default:
+			}
+			pods <- pod
+		}
+	}
+
+	selector := fields.OneTermEqualSelector("metadata.name", k.podName).String()
+	_, informer := cache.NewInformer(&cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = selector
+			return k.client.CoreV1().Pods(k.namespace).List(options)
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = selector
+			return k.client.CoreV1().Pods(k.namespace).Watch(options)
+		},
+	}, &core_v1.Pod{}, resyncPeriod, cache.ResourceEventHandlerFuncs{
+		AddFunc: notify,
+		UpdateFunc: func(old, new interface{}) {
+			notify(new)
+		},
+		DeleteFunc: func(obj interface{}) {
+			once.Do(func() { close(deleted) })
+		},
+	})
+	stop := make(chan struct{})
+	defer close(stop)
+	go informer.Run(stop)
+
 	for {
 		select {
-		case <-ticker.C:
-			pod, err := k.client.CoreV1().Pods(k.namespace).Get(k.podName, meta_v1.GetOptions{})
+		case pod := <-pods:
+			reached, err := ContainersReached(pod, expectState, selectors...)
 			if err != nil {
 				return err
 			}
+			if reached {
+				log.WithField("pod", pod.Name).WithField("expected", expectState).Info("All containers reached expected status")
+				return nil
+			}
+		case <-deleted:
+			return fmt.Errorf("pod %s deleted", k.podName)
+		}
+	}
+}
 
-			var unexpectedCount int
-			for _, c := range pod.Spec.Containers {
-				// Skip containers that are not selected.
-				if !common.Pass(c.Name, selectors) {
-					continue
-				}
-
-				var s *core_v1.ContainerStatus
-				for _, cs := range pod.Status.ContainerStatuses {
-					if c.Name == cs.Name {
-						s = &cs
-						break
-					}
-				}
-
-				switch expectState {
-				case common.ContainerStateTerminated:
-					if s == nil || s.State.Terminated == nil {
-						log.WithField("container", c.Name).WithField("expected", expectState).Debugf("Container not expected status")
-						unexpectedCount++
-					}
-				case common.ContainerStateInitialized:
-					if s == nil || (s.State.Running == nil && s.State.Terminated == nil) {
-						log.WithField("container", c.Name).WithField("expected", expectState).Debugf("Container not in expected status")
-						unexpectedCount++
-					}
-				}
+// ContainersReached checks whether containers that pass selectors have reached the expected
+// state. Error is returned if they would never reach it, for example, the pod has terminated
+// or a container can't be created.
+func ContainersReached(pod *core_v1.Pod, expectState common.ContainerState, selectors ...common.ContainerSelector) (bool, error) {
+	var unexpected []string
+	for _, c := range pod.Spec.Containers {
+		// Skip containers that are not selected.
+		if !common.Pass(c.Name, selectors) {
+			continue
+		}
+
+		var s *core_v1.ContainerStatus
+		for i, cs := range pod.Status.ContainerStatuses {
+			if c.Name == cs.Name {
+				s = &pod.Status.ContainerStatuses[i]
+				break
 			}
+		}
 
-			if unexpectedCount == 0 {
-				log.WithField("pod", pod.Name).WithField("expected", expectState).Info("All containers reached expected status")
-				return nil
+		if s != nil && s.State.Terminated != nil && s.State.Terminated.Reason == "OOMKilled" {
+			log.WithField("container", c.Name).Warn("Container OOMKilled")
+		}
+		if s != nil && s.State.Waiting != nil && unrecoverableWaitingReasons[s.State.Waiting.Reason] {
+			return false, fmt.Errorf("container %s can't start, %s: %s", c.Name, s.State.Waiting.Reason, s.State.Waiting.Message)
+		}
+
+		switch expectState {
+		case common.ContainerStateTerminated:
+			if s == nil || s.State.Terminated == nil {
+				log.WithField("container", c.Name).WithField("expected", expectState).Debugf("Container not expected status")
+				unexpected = append(unexpected, c.Name)
+			}
+		case common.ContainerStateInitialized:
+			if s == nil || (s.State.Running == nil && s.State.Terminated == nil) {
+				log.WithField("container", c.Name).WithField("expected", expectState).Debugf("Container not in expected status")
+				unexpected = append(unexpected, c.Name)
 			}
+		case common.ContainerStateReady:
+			if s != nil && s.State.Terminated != nil {
+				return false, fmt.Errorf("container %s terminated before ready, exit code: %d", c.Name, s.State.Terminated.ExitCode)
+			}
+			if s == nil || !s.Ready {
+				log.WithField("container", c.Name).WithField("expected", expectState).Debugf("Container not in expected status")
+				unexpected = append(unexpected, c.Name)
+			}
+		}
+	}
+
+	if len(unexpected) == 0 {
+		return true, nil
+	}
+
+	// Containers would never reach expected state if the pod has terminated, e.g. evicted.
+	if pod.Status.Phase == core_v1.PodFailed || pod.Status.Phase == core_v1.PodSucceeded {
+		reason := pod.Status.Reason
+		if reason == "" {
+			reason = string(pod.Status.Phase)
 		}
+		return false, fmt.Errorf("pod %s terminated (%s: %s) while waiting containers %v", pod.Name, reason, pod.Status.Message, unexpected)
 	}
+	if pod.DeletionTimestamp != nil {
+		return false, fmt.Errorf("pod %s is being deleted while waiting containers %v", pod.Name, unexpected)
+	}
+
+	return false, nil
 }
 
 // GetPod get the stage pod.
@@ -96,8 +202,8 @@ func (k *Executor) GetResource(name string) (*v1alpha1.Resource, error) {
 	return k.client.CycloneV1alpha1().Resources(k.namespace).Get(name, meta_v1.GetOptions{})
 }
 
-// CollectLog collects container logs.
-func (k *Executor) CollectLog(container, workflowrun, stage string) error {
+// CollectLog collects container logs and pushes them to Cyclone server.
+func (k *Executor) CollectLog(container string, wfr *v1alpha1.WorkflowRun, stage string) error {
 	log.Infof("Start to collect %s log", container)
 	stream, err := k.client.CoreV1().Pods(k.namespace).GetLogs(k.podName, &core_v1.PodLogOptions{
 		Container: container,
@@ -106,34 +212,66 @@ func (k *Executor) CollectLog(container, workflowrun, stage string) error {
 	if err != nil {
 		return err
 	}
+	defer stream.Close()
+
+	return k.cycloneClient.PushLogStream(cycloneserver.LogStream{
+		WorkflowRunRef: cycloneserver.NewWorkflowRunRef(wfr),
+		Stage:          stage,
+		Container:      container,
+	}, stream)
+}
 
-	closeLog := make(chan struct{})
-	defer func() {
-		stream.Close()
-		close(closeLog)
-	}()
+// CopyFromContainer copies a file or directory from container:path to the local directory dst.
+// Since workload containers have terminated when outputs are collected, it's done through volumes
+// of the container that are also mounted to coordinator, see PodBuilder.AddCoordinator. Outputs
+// not placed on volumes can't be collected by this executor.
+func (k *Executor) CopyFromContainer(container, path, dst string) error {
+	pod, err := k.GetPod()
+	if err != nil {
+		return err
+	}
 
-	k.cycloneClient.PushLogStream(workflowrun, stage, container, stream, closeLog)
+	local, err := LocalPath(pod, container, path)
 	if err != nil {
 		return err
 	}
-	return nil
+	log.WithField("container", container).WithField("path", path).WithField("local", local).Info("Copy from shared volume")
+	return artifact.CopyPath(local, dst)
 }
 
-// CopyFromContainer copy a file/directory frome container:path to dst.
-func (k *Executor) CopyFromContainer(container, path, dst string) error {
-	//args := []string{"--kubeconfig", k.kubeconfig, "cp", fmt.Sprintf("%s/%s:%s", k.namespace, k.podName, path), "-c", container, dst}
-	//
-	//cmd := exec.Command("kubectl", args...)
-	//return cmd.Run()
-
-	// Fixme, use docker instead of kubectl since
-	// kubectl can not cp a file from a stopped container.
-	args := []string{"cp", fmt.Sprintf("%s:%s", container, path), dst}
-
-	cmd := exec.Command("docker", args...)
-	log.WithField("args", args).Info()
-	ret, err := cmd.CombinedOutput()
-	log.WithField("message", string(ret)).WithField("error", err).Info("copy file result")
-	return err
+// LocalPath maps path in the container to path in coordinator, using the volume mount of the
+// container that holds the path and the same volume mounted to coordinator.
+func LocalPath(pod *core_v1.Pod, container, p string) (string, error) {
+	var mounts, coordinatorMounts []core_v1.VolumeMount
+	for _, c := range pod.Spec.Containers {
+		switch c.Name {
+		case container:
+			mounts = c.VolumeMounts
+		case common.CoordinatorSidecarName:
+			coordinatorMounts = c.VolumeMounts
+		}
+	}
+
+	// Find the innermost volume mount containing the path.
+	p = path.Clean(p)
+	var found *core_v1.VolumeMount
+	for i, vm := range mounts {
+		mountPath := path.Clean(vm.MountPath)
+		if p != mountPath && !strings.HasPrefix(p, strings.TrimSuffix(mountPath, "/")+"/") {
+			continue
+		}
+		if found == nil || len(mountPath) > len(path.Clean(found.MountPath)) {
+			found = &mounts[i]
+		}
+	}
+	if found == nil {
+		return "", fmt.Errorf("%s is not on any volume of container %s, outputs must be placed on volumes with %s executor", p, container, common.ExecutorKubernetes)
+	}
+
+	for _, vm := range coordinatorMounts {
+		if vm.Name == found.Name && vm.SubPath == found.SubPath && strings.HasPrefix(vm.MountPath, common.CoordinatorVolumesPath+"/") {
+			return path.Join(vm.MountPath, strings.TrimPrefix(p, path.Clean(found.MountPath))), nil
+		}
+	}
+	return "", fmt.Errorf("volume %s of container %s is not shared with coordinator", found.Name, container)
 }
@@ -0,0 +1,188 @@
+package k8sapi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/cyclone/pkg/k8s/clientset/fake"
+	"github.com/caicloud/cyclone/pkg/workflow/common"
+)
+
+func stagePod(phase core_v1.PodPhase, statuses ...core_v1.ContainerStatus) *core_v1.Pod {
+	return &core_v1.Pod{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "stage-pod",
+			Namespace: "default",
+		},
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "main"},
+				{Name: common.CoordinatorSidecarName},
+			},
+		},
+		Status: core_v1.PodStatus{
+			Phase:             phase,
+			ContainerStatuses: statuses,
+		},
+	}
+}
+
+func running(name string) core_v1.ContainerStatus {
+	return core_v1.ContainerStatus{
+		Name:  name,
+		State: core_v1.ContainerState{Running: &core_v1.ContainerStateRunning{}},
+	}
+}
+
+func terminated(name, reason string) core_v1.ContainerStatus {
+	return core_v1.ContainerStatus{
+		Name:  name,
+		State: core_v1.ContainerState{Terminated: &core_v1.ContainerStateTerminated{Reason: reason}},
+	}
+}
+
+func TestContainersReached(t *testing.T) {
+	reached, err := ContainersReached(stagePod(core_v1.PodPending), common.ContainerStateInitialized)
+	assert.Nil(t, err)
+	assert.False(t, reached)
+
+	reached, err = ContainersReached(stagePod(core_v1.PodRunning, running("main"), running(common.CoordinatorSidecarName)), common.ContainerStateInitialized)
+	assert.Nil(t, err)
+	assert.True(t, reached)
+
+	reached, err = ContainersReached(stagePod(core_v1.PodRunning, running("main"), running(common.CoordinatorSidecarName)), common.ContainerStateTerminated, common.OnlyWorkload)
+	assert.Nil(t, err)
+	assert.False(t, reached)
+
+	reached, err = ContainersReached(stagePod(core_v1.PodRunning, terminated("main", "OOMKilled"), running(common.CoordinatorSidecarName)), common.ContainerStateTerminated, common.OnlyWorkload)
+	assert.Nil(t, err)
+	assert.True(t, reached)
+
+	evicted := stagePod(core_v1.PodFailed, running("main"), running(common.CoordinatorSidecarName))
+	evicted.Status.Reason = "Evicted"
+	_, err = ContainersReached(evicted, common.ContainerStateTerminated, common.OnlyWorkload)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Evicted")
+
+	sidecarName := common.WorkloadSidecarPrefix + "db"
+	sidecarPod := func(status core_v1.ContainerStatus) *core_v1.Pod {
+		pod := stagePod(core_v1.PodRunning, running("main"), status)
+		pod.Spec.Containers = append(pod.Spec.Containers, core_v1.Container{Name: sidecarName})
+		return pod
+	}
+	sidecar := running(sidecarName)
+	reached, err = ContainersReached(sidecarPod(sidecar), common.ContainerStateReady, common.OnlyWorkloadSidecar)
+	assert.Nil(t, err)
+	assert.False(t, reached)
+
+	sidecar.Ready = true
+	reached, err = ContainersReached(sidecarPod(sidecar), common.ContainerStateReady, common.OnlyWorkloadSidecar)
+	assert.Nil(t, err)
+	assert.True(t, reached)
+
+	_, err = ContainersReached(sidecarPod(terminated(sidecarName, "Error")), common.ContainerStateReady, common.OnlyWorkloadSidecar)
+	assert.Error(t, err)
+
+	_, err = ContainersReached(stagePod(core_v1.PodPending, core_v1.ContainerStatus{
+		Name:  "main",
+		State: core_v1.ContainerState{Waiting: &core_v1.ContainerStateWaiting{Reason: "InvalidImageName"}},
+	}), common.ContainerStateInitialized)
+	assert.Error(t, err)
+}
+
+func TestWaitContainers(t *testing.T) {
+	pod := stagePod(core_v1.PodRunning, running("main"), running(common.CoordinatorSidecarName))
+	client := fake.NewSimpleClientset(pod)
+	executor := NewK8sapiExecutor("default", "stage-pod", client, "", "")
+
+	done := make(chan error)
+	go func() {
+		done <- executor.WaitContainers(common.ContainerStateTerminated, common.OnlyWorkload)
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("wait returned before workload terminated: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	pod = pod.DeepCopy()
+	pod.Status.ContainerStatuses[0] = terminated("main", "Completed")
+	_, err := client.CoreV1().Pods("default").Update(pod)
+	assert.Nil(t, err)
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait not returned after workload terminated")
+	}
+
+	// Fake clientset keeps stopped watchers, use a new one to avoid sending events to them.
+	client = fake.NewSimpleClientset(pod)
+	executor = NewK8sapiExecutor("default", "stage-pod", client, "", "")
+	go func() {
+		done <- executor.WaitContainers(common.ContainerStateTerminated)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	assert.Nil(t, client.CoreV1().Pods("default").Delete("stage-pod", nil))
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait not returned after pod deleted")
+	}
+}
+
+func TestLocalPath(t *testing.T) {
+	pod := &core_v1.Pod{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{
+					Name: "main",
+					VolumeMounts: []core_v1.VolumeMount{
+						{Name: "default-pv", MountPath: "/__cyclone__workspace", SubPath: "workflowruns/wfr/stages/build"},
+						{Name: "default-pv", MountPath: "/workspace/src", SubPath: "workflowruns/wfr/resources/src"},
+						{Name: "cache", MountPath: "/cache/"},
+						{Name: "private", MountPath: "/private"},
+					},
+				},
+				{
+					Name: common.CoordinatorSidecarName,
+					VolumeMounts: []core_v1.VolumeMount{
+						{Name: common.CoordinatorSidecarVolumeName, MountPath: common.CoordinatorResolverPath},
+						{Name: "default-pv", MountPath: "/workspace/volumes/0", SubPath: "workflowruns/wfr/stages/build"},
+						{Name: "default-pv", MountPath: "/workspace/volumes/1", SubPath: "workflowruns/wfr/resources/src"},
+						{Name: "cache", MountPath: "/workspace/volumes/2"},
+					},
+				},
+			},
+		},
+	}
+
+	cases := []struct {
+		path  string
+		local string
+		err   bool
+	}{
+		{path: "/workspace/src/bin/app", local: "/workspace/volumes/1/bin/app"},
+		{path: "/workspace/src/", local: "/workspace/volumes/1"},
+		{path: "/__cyclone__workspace/out", local: "/workspace/volumes/0/out"},
+		{path: "/cache/m2", local: "/workspace/volumes/2/m2"},
+		{path: "/workspace/srcx/bin", err: true},
+		{path: "/private/key", err: true},
+		{path: "/tmp/out", err: true},
+	}
+	for _, c := range cases {
+		local, err := LocalPath(pod, "main", c.path)
+		if c.err {
+			assert.Error(t, err, c.path)
+			continue
+		}
+		assert.Nil(t, err, c.path)
+		assert.Equal(t, c.local, local)
+	}
+}
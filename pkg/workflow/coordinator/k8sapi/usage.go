@@ -0,0 +1,136 @@
+package k8sapi
+
+import (
+	"encoding/json"
+	"net/url"
+
+	log "github.com/sirupsen/logrus"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// kubeletSummary is part of the stats summary served by kubelet at /stats/summary.
+type kubeletSummary struct {
+	Pods []struct {
+		PodRef struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"podRef"`
+		Containers []struct {
+			Name string `json:"name"`
+			CPU  *struct {
+				UsageNanoCores *uint64 `json:"usageNanoCores"`
+			} `json:"cpu"`
+			Memory *struct {
+				WorkingSetBytes *uint64 `json:"workingSetBytes"`
+			} `json:"memory"`
+		} `json:"containers"`
+	} `json:"pods"`
+}
+
+// podMetrics is part of PodMetrics served by the metrics API.
+type podMetrics struct {
+	Containers []struct {
+		Name  string               `json:"name"`
+		Usage core_v1.ResourceList `json:"usage"`
+	} `json:"containers"`
+}
+
+// SampleUsage samples compute resource usage of containers in the pod. The kubelet summary API is
+// used, which serves cgroup stats of containers collected by kubelet, and the metrics API is used
+// as a fallback if kubelet is not accessible, e.g. no permission to proxy nodes. Other errors of
+// kubelet are considered transient, the sample is skipped and kubelet is tried again next time.
+// Both of them need metrics-server or kubelet to be available, error is returned otherwise.
+func (k *Executor) SampleUsage() (map[string]core_v1.ResourceList, error) {
+	if !k.noKubeletStats {
+		if k.nodeName == "" {
+			pod, err := k.GetPod()
+			if err != nil {
+				return nil, err
+			}
+			k.nodeName = pod.Spec.NodeName
+		}
+
+		data, err := k.client.CoreV1().RESTClient().Get().
+			AbsPath("/api/v1/nodes", k.nodeName, "proxy/stats/summary").DoRaw()
+		if err == nil {
+			return parseKubeletSummary(data, k.namespace, k.podName)
+		}
+		if !kubeletUnavailable(err) {
+			return nil, err
+		}
+		log.WithField("error", err).Warning("Kubelet stats not accessible, fallback to metrics API")
+		k.noKubeletStats = true
+	}
+
+	return k.metricsUsage()
+}
+
+// kubeletUnavailable checks whether the error of requesting kubelet through node proxy means that
+// kubelet stats can't be accessed at all, that's, no permission to proxy nodes (403), the proxy or
+// stats API not found (404), or the API server can't be reached.
+func kubeletUnavailable(err error) bool {
+	if errors.IsForbidden(err) || errors.IsNotFound(err) {
+		return true
+	}
+	_, ok := err.(*url.Error)
+	return ok
+}
+
+func (k *Executor) metricsUsage() (map[string]core_v1.ResourceList, error) {
+	data, err := k.client.CoreV1().RESTClient().Get().
+		AbsPath("/apis/metrics.k8s.io/v1beta1/namespaces", k.namespace, "pods", k.podName).DoRaw()
+	if err != nil {
+		return nil, err
+	}
+
+	return parsePodMetrics(data)
+}
+
+// parseKubeletSummary gets usage of containers in the pod from kubelet stats summary. If the pod is
+// not in the summary yet, e.g. kubelet hasn't collected stats of it, empty usage is returned.
+func parseKubeletSummary(data []byte, namespace, pod string) (map[string]core_v1.ResourceList, error) {
+	summary := &kubeletSummary{}
+	if err := json.Unmarshal(data, summary); err != nil {
+		return nil, err
+	}
+
+	for _, p := range summary.Pods {
+		if p.PodRef.Namespace != namespace || p.PodRef.Name != pod {
+			continue
+		}
+
+		usage := make(map[string]core_v1.ResourceList)
+		for _, c := range p.Containers {
+			list := core_v1.ResourceList{}
+			if c.CPU != nil && c.CPU.UsageNanoCores != nil {
+				list[core_v1.ResourceCPU] = *resource.NewScaledQuantity(int64(*c.CPU.UsageNanoCores), resource.Nano)
+			}
+			if c.Memory != nil && c.Memory.WorkingSetBytes != nil {
+				list[core_v1.ResourceMemory] = *resource.NewQuantity(int64(*c.Memory.WorkingSetBytes), resource.BinarySI)
+			}
+			if len(list) > 0 {
+				usage[c.Name] = list
+			}
+		}
+		return usage, nil
+	}
+
+	log.WithField("pod", pod).Debug("Pod not found in kubelet stats summary, skip")
+	return map[string]core_v1.ResourceList{}, nil
+}
+
+// parsePodMetrics gets usage of containers from PodMetrics.
+func parsePodMetrics(data []byte) (map[string]core_v1.ResourceList, error) {
+	metrics := &podMetrics{}
+	if err := json.Unmarshal(data, metrics); err != nil {
+		return nil, err
+	}
+
+	usage := make(map[string]core_v1.ResourceList)
+	for _, c := range metrics.Containers {
+		usage[c.Name] = c.Usage
+	}
+	return usage, nil
+}
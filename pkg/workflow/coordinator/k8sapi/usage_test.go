@@ -0,0 +1,73 @@
+package k8sapi
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestParseKubeletSummary(t *testing.T) {
+	data := []byte(`{
+  "pods": [
+    {
+      "podRef": {"name": "other-pod", "namespace": "default"},
+      "containers": [{"name": "main", "cpu": {"usageNanoCores": 1}}]
+    },
+    {
+      "podRef": {"name": "stage-pod", "namespace": "default"},
+      "containers": [
+        {"name": "main", "cpu": {"usageNanoCores": 250000000}, "memory": {"workingSetBytes": 104857600}},
+        {"name": "sidecar", "memory": {"workingSetBytes": 1048576}},
+        {"name": "idle"}
+      ]
+    }
+  ]
+}`)
+
+	usage, err := parseKubeletSummary(data, "default", "stage-pod")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(usage))
+
+	cpu := usage["main"][core_v1.ResourceCPU]
+	assert.Equal(t, int64(250), cpu.MilliValue())
+	memory := usage["main"][core_v1.ResourceMemory]
+	assert.Equal(t, int64(104857600), memory.Value())
+	_, ok := usage["sidecar"][core_v1.ResourceCPU]
+	assert.False(t, ok)
+
+	// Pod not collected by kubelet yet.
+	usage, err = parseKubeletSummary(data, "default", "missing-pod")
+	assert.Nil(t, err)
+	assert.Empty(t, usage)
+}
+
+func TestKubeletUnavailable(t *testing.T) {
+	nodes := schema.GroupResource{Resource: "nodes"}
+	assert.True(t, kubeletUnavailable(errors.NewForbidden(nodes, "node1", fmt.Errorf("forbidden"))))
+	assert.True(t, kubeletUnavailable(errors.NewNotFound(nodes, "node1")))
+	assert.True(t, kubeletUnavailable(&url.Error{Op: "Get", URL: "https://10.0.0.1", Err: fmt.Errorf("connection refused")}))
+	assert.False(t, kubeletUnavailable(errors.NewServiceUnavailable("kubelet restarting")))
+	assert.False(t, kubeletUnavailable(errors.NewInternalError(fmt.Errorf("timeout"))))
+}
+
+func TestParsePodMetrics(t *testing.T) {
+	data := []byte(`{
+  "metadata": {"name": "stage-pod", "namespace": "default"},
+  "containers": [
+    {"name": "main", "usage": {"cpu": "250m", "memory": "100Mi"}}
+  ]
+}`)
+
+	usage, err := parsePodMetrics(data)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(usage))
+	main := usage["main"]
+	assert.Equal(t, 0, main.Cpu().Cmp(resource.MustParse("250m")))
+	assert.Equal(t, 0, main.Memory().Cmp(resource.MustParse("100Mi")))
+}
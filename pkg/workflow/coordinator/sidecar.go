@@ -0,0 +1,160 @@
+package coordinator
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/caicloud/cyclone/pkg/workflow/common"
+)
+
+// procPath is mount path of proc filesystem, processes of all containers in the pod are visible
+// in it since process namespace is shared in stages with workload sidecars.
+const procPath = "/proc"
+
+// sidecarStopTimeout is the time to wait workload sidecars to exit after SIGTERM sent, they are
+// killed with SIGKILL if they don't exit in time.
+const sidecarStopTimeout = 30 * time.Second
+
+// hasWorkloadSidecars checks whether there are workload sidecars in the stage.
+func (co *Coordinator) hasWorkloadSidecars() bool {
+	if co.Stage.Spec.Pod == nil {
+		return false
+	}
+
+	for _, c := range co.Stage.Spec.Pod.Spec.Containers {
+		if common.OnlyWorkloadSidecar(c.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// WaitSidecarsReady waits all workload sidecars to be ready, and then notifies the workload
+// container to start running through the sidecar gate volume. If sidecars would never be ready,
+// the workload container is notified to exit.
+func (co *Coordinator) WaitSidecarsReady() error {
+	if !co.hasWorkloadSidecars() {
+		return nil
+	}
+
+	err := co.runtimeExec.WaitContainers(common.ContainerStateReady, common.OnlyWorkloadSidecar)
+	gate := common.SidecarReadyFile
+	if err != nil {
+		log.Errorf("Wait workload sidecars to be ready error: %v", err)
+		gate = common.SidecarAbortFile
+	}
+
+	if _, e := os.Create(path.Join(common.CoordinatorSidecarGatePath, gate)); e != nil {
+		log.WithField("file", gate).Error("Create sidecar gate file error: ", e)
+		if err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// StopSidecars stops workload sidecars that are still running. Sidecars usually serve the
+// workload and never exit, they are stopped after the workload finished by sending signals to
+// their processes, SIGTERM first and SIGKILL if they don't exit in time.
+func (co *Coordinator) StopSidecars() error {
+	if !co.hasWorkloadSidecars() {
+		return nil
+	}
+
+	pod, err := co.runtimeExec.GetPod()
+	if err != nil {
+		return err
+	}
+
+	var ids []string
+	for _, cs := range pod.Status.ContainerStatuses {
+		if common.OnlyWorkloadSidecar(cs.Name) && cs.State.Terminated == nil && cs.ContainerID != "" {
+			ids = append(ids, containerID(cs.ContainerID))
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	for _, sig := range []syscall.Signal{syscall.SIGTERM, syscall.SIGKILL} {
+		if err := signalContainers(procPath, ids, sig); err != nil {
+			return err
+		}
+
+		done := make(chan error, 1)
+		go func() {
+			done <- co.runtimeExec.WaitContainers(common.ContainerStateTerminated, common.OnlyWorkloadSidecar)
+		}()
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(sidecarStopTimeout):
+			log.WithField("signal", sig).Warning("Workload sidecars not stopped in time")
+		}
+	}
+
+	return fmt.Errorf("workload sidecars not stopped in %s after killed", sidecarStopTimeout)
+}
+
+// containerID strips the runtime scheme, e.g. 'docker://', from k8s ContainerID string.
+func containerID(id string) string {
+	if i := strings.Index(id, "://"); i != -1 {
+		return id[i+3:]
+	}
+	return id
+}
+
+// signalContainers sends the signal to all processes of the containers.
+func signalContainers(proc string, ids []string, sig syscall.Signal) error {
+	pids, err := containerProcesses(proc, ids)
+	if err != nil {
+		return err
+	}
+
+	log.WithField("pids", pids).WithField("signal", sig).Info("Signal workload sidecars")
+	for _, pid := range pids {
+		if err := syscall.Kill(pid, sig); err != nil && err != syscall.ESRCH {
+			log.WithField("pid", pid).Warning("Signal process error: ", err)
+		}
+	}
+	return nil
+}
+
+// containerProcesses finds processes of the containers by container IDs, a process belongs to a
+// container if the container ID appears in its cgroup paths.
+func containerProcesses(proc string, ids []string) ([]int, error) {
+	files, err := ioutil.ReadDir(proc)
+	if err != nil {
+		return nil, err
+	}
+
+	var pids []int
+	for _, f := range files {
+		pid, err := strconv.Atoi(f.Name())
+		if err != nil || !f.IsDir() || pid == os.Getpid() {
+			continue
+		}
+
+		// Processes may exit while scanning, just skip them.
+		cgroup, err := ioutil.ReadFile(path.Join(proc, f.Name(), "cgroup"))
+		if err != nil {
+			continue
+		}
+		for _, id := range ids {
+			if id != "" && strings.Contains(string(cgroup), id) {
+				pids = append(pids, pid)
+				break
+			}
+		}
+	}
+
+	return pids, nil
+}
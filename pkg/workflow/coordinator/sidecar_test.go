@@ -0,0 +1,44 @@
+package coordinator
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainerProcesses(t *testing.T) {
+	proc, err := ioutil.TempDir("", "proc-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(proc)
+
+	cgroups := map[string]string{
+		"1":  "12:pids:/kubepods/besteffort/pod1234/pause\n",
+		"7":  "12:pids:/kubepods/besteffort/pod1234/aaaa1111\n1:name=systemd:/kubepods/besteffort/pod1234/aaaa1111\n",
+		"8":  "0::/kubepods.slice/cri-containerd-bbbb2222.scope\n",
+		"9":  "12:pids:/kubepods/besteffort/pod1234/cccc3333\n",
+		"fd": "",
+	}
+	for pid, cgroup := range cgroups {
+		assert.Nil(t, os.MkdirAll(path.Join(proc, pid), 0755))
+		assert.Nil(t, ioutil.WriteFile(path.Join(proc, pid, "cgroup"), []byte(cgroup), 0644))
+	}
+	// Process exited while scanning.
+	assert.Nil(t, os.MkdirAll(path.Join(proc, "10"), 0755))
+
+	pids, err := containerProcesses(proc, []string{"aaaa1111", "bbbb2222"})
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []int{7, 8}, pids)
+
+	pids, err = containerProcesses(proc, []string{""})
+	assert.Nil(t, err)
+	assert.Empty(t, pids)
+}
+
+func TestContainerID(t *testing.T) {
+	assert.Equal(t, "aaaa1111", containerID("docker://aaaa1111"))
+	assert.Equal(t, "bbbb2222", containerID("containerd://bbbb2222"))
+	assert.Equal(t, "cccc3333", containerID("cccc3333"))
+}
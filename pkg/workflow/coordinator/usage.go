@@ -0,0 +1,128 @@
+package coordinator
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+)
+
+// usageSampleInterval is interval to sample resource usage of containers.
+const usageSampleInterval = 10 * time.Second
+
+// usageRecorder accumulates usage samples of containers to get peak and average usage.
+type usageRecorder struct {
+	lock sync.Mutex
+	peak map[string]core_v1.ResourceList
+	// sum of samples, CPU is in millicores, others are in their base units, e.g. bytes.
+	sum     map[string]map[core_v1.ResourceName]int64
+	samples map[string]int
+}
+
+func newUsageRecorder() *usageRecorder {
+	return &usageRecorder{
+		peak:    make(map[string]core_v1.ResourceList),
+		sum:     make(map[string]map[core_v1.ResourceName]int64),
+		samples: make(map[string]int),
+	}
+}
+
+// value gets value of the quantity in unit used to sum samples.
+func value(name core_v1.ResourceName, q resource.Quantity) int64 {
+	if name == core_v1.ResourceCPU {
+		return q.MilliValue()
+	}
+	return q.Value()
+}
+
+// add adds a sample of containers' usage.
+func (r *usageRecorder) add(sample map[string]core_v1.ResourceList) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for container, list := range sample {
+		if _, ok := r.peak[container]; !ok {
+			r.peak[container] = core_v1.ResourceList{}
+			r.sum[container] = make(map[core_v1.ResourceName]int64)
+		}
+
+		r.samples[container]++
+		for name, q := range list {
+			if peak, ok := r.peak[container][name]; !ok || q.Cmp(peak) > 0 {
+				r.peak[container][name] = q.DeepCopy()
+			}
+			r.sum[container][name] += value(name, q)
+		}
+	}
+}
+
+// usage gets peak and average usage of containers from samples added.
+func (r *usageRecorder) usage() map[string]v1alpha1.ContainerUsage {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if len(r.samples) == 0 {
+		return nil
+	}
+
+	usage := make(map[string]v1alpha1.ContainerUsage)
+	for container, n := range r.samples {
+		average := core_v1.ResourceList{}
+		for name, sum := range r.sum[container] {
+			if name == core_v1.ResourceCPU {
+				average[name] = *resource.NewMilliQuantity(sum/int64(n), resource.DecimalSI)
+			} else {
+				average[name] = *resource.NewQuantity(sum/int64(n), resource.BinarySI)
+			}
+		}
+
+		usage[container] = v1alpha1.ContainerUsage{
+			Peak:    r.peak[container],
+			Average: average,
+			Samples: n,
+		}
+	}
+	return usage
+}
+
+// StartSampling starts to sample resource usage of containers in the stage pod periodically,
+// until StopSampling is called.
+func (co *Coordinator) StartSampling() {
+	co.stopSampling = make(chan struct{})
+	co.sampling.Add(1)
+	go func() {
+		defer co.sampling.Done()
+
+		ticker := time.NewTicker(usageSampleInterval)
+		defer ticker.Stop()
+		for {
+			sample, err := co.runtimeExec.SampleUsage()
+			if err != nil {
+				log.WithField("error", err).Warning("Sample resource usage failed")
+			} else {
+				co.usage.add(sample)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-co.stopSampling:
+				return
+			}
+		}
+	}()
+}
+
+// StopSampling stops sampling resource usage, it returns after the sampling goroutine exited.
+func (co *Coordinator) StopSampling() {
+	if co.stopSampling == nil {
+		return
+	}
+
+	close(co.stopSampling)
+	co.sampling.Wait()
+	co.stopSampling = nil
+}
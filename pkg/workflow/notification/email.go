@@ -0,0 +1,110 @@
+package notification
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+)
+
+// smtpTimeout is the max time to send an email, including connecting to the SMTP server, it's
+// a variable so that it can be replaced in tests.
+var smtpTimeout = 30 * time.Second
+
+// sendMail sends the email, it's replaced in tests.
+var sendMail = sendMailWithTimeout
+
+// sendMailWithTimeout sends the email the same way as smtp.SendMail, but fails if it can't be done
+// in smtpTimeout, so that unresponsive SMTP servers won't block notifying.
+func sendMailWithTimeout(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	conn, err := net.DialTimeout("tcp", addr, smtpTimeout)
+	if err != nil {
+		return err
+	}
+	if err := conn.SetDeadline(time.Now().Add(smtpTimeout)); err != nil {
+		conn.Close()
+		return err
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if a != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return fmt.Errorf("smtp: server doesn't support AUTH")
+		}
+		if err := c.Auth(a); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err := c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// email sends messages by emails through SMTP server.
+type email struct {
+	source *api.EmailSource
+}
+
+// Send ...
+func (e *email) Send(msg *Message) error {
+	if len(e.source.To) == 0 {
+		return fmt.Errorf("no recipients")
+	}
+
+	var auth smtp.Auth
+	if e.source.User != "" {
+		host, _, err := net.SplitHostPort(e.source.Server)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", e.source.User, e.source.Password, host)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", e.source.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.source.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.Replace(msg.Text, "\n", "\r\n", -1))
+	b.WriteString("\r\n")
+
+	return sendMail(e.source.Server, auth, e.source.From, e.source.To, b.Bytes())
+}
@@ -0,0 +1,122 @@
+package notification
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"text/template"
+	"time"
+
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+)
+
+// timeout is the timeout of sending a notification over HTTP.
+const timeout = 30 * time.Second
+
+// DefaultTemplate is the message template used when notification rules have no template.
+const DefaultTemplate = `WorkflowRun {{.WorkflowRun}} of workflow {{.Project}}/{{.Workflow}} {{.Status}} in {{.Duration}}.` +
+	`{{if .Message}} {{.Message}}{{end}}{{if .URL}} {{.URL}}{{end}}`
+
+// Data is the result of a WorkflowRun, message templates are rendered with it.
+type Data struct {
+	Tenant      string `json:"tenant"`
+	Project     string `json:"project"`
+	Workflow    string `json:"workflow"`
+	WorkflowRun string `json:"workflowRun"`
+	// Trigger is the WorkflowTrigger that created the WorkflowRun, empty if it's created by users.
+	Trigger string `json:"trigger,omitempty"`
+	// Status is the terminal status of the WorkflowRun, Completed, Error or Cancelled.
+	Status string `json:"status"`
+	// Message describes why the WorkflowRun failed.
+	Message   string    `json:"message,omitempty"`
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+	Duration  string    `json:"duration"`
+	// Stages are statuses of all stages, keyed by stage name.
+	Stages map[string]string `json:"stages"`
+	// URL links to details of the WorkflowRun.
+	URL string `json:"url,omitempty"`
+}
+
+// Message is a notification message.
+type Message struct {
+	// Subject is a short summary of the message, used as subject of emails.
+	Subject string
+	// Text is the message rendered from template.
+	Text string
+	// Data is what the message is rendered from.
+	Data *Data
+}
+
+// ParseTemplate parses the message template, default template is used if it's empty.
+func ParseTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = DefaultTemplate
+	}
+	return template.New("notification").Option("missingkey=zero").Parse(text)
+}
+
+// NewMessage renders the message from template with the data.
+func NewMessage(text string, data *Data) (*Message, error) {
+	tmpl, err := ParseTemplate(text)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		Subject: fmt.Sprintf("[Cyclone] WorkflowRun %s %s", data.WorkflowRun, data.Status),
+		Text:    b.String(),
+		Data:    data,
+	}, nil
+}
+
+// Sender sends notification messages to a channel.
+type Sender interface {
+	Send(msg *Message) error
+}
+
+// NewSender creates sender of the notification integration, Slack, Email and Webhook
+// integrations are supported.
+func NewSender(integration *api.Integration) (Sender, error) {
+	spec := integration.Spec
+	switch {
+	case spec.Type == api.Slack && spec.Slack != nil:
+		return &slack{source: spec.Slack}, nil
+	case spec.Type == api.Email && spec.Email != nil:
+		return &email{source: spec.Email}, nil
+	case spec.Type == api.Webhook && spec.Webhook != nil:
+		return &webhook{source: spec.Webhook}, nil
+	}
+	return nil, fmt.Errorf("integration %s is not a notification integration", integration.Metadata.Name)
+}
+
+var httpClient = &http.Client{Timeout: timeout}
+
+// post posts the body to the URL, responses with non-2xx codes are regarded as failures.
+func post(url string, header http.Header, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s responds %d: %s", url, resp.StatusCode, bytes.TrimSpace(data))
+	}
+	return nil
+}
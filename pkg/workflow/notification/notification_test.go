@@ -0,0 +1,220 @@
+package notification
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+)
+
+func testData() *Data {
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	return &Data{
+		Tenant:      "t1",
+		Project:     "p1",
+		Workflow:    "wf",
+		WorkflowRun: "wfr",
+		Status:      "Error",
+		Message:     "Stage build failed.",
+		StartTime:   start,
+		EndTime:     start.Add(90 * time.Second),
+		Duration:    "1m30s",
+		Stages:      map[string]string{"build": "Error"},
+		URL:         "https://cyclone.example.com/wfr",
+	}
+}
+
+func TestNewMessage(t *testing.T) {
+	msg, err := NewMessage("", testData())
+	assert.Nil(t, err)
+	assert.Equal(t, "[Cyclone] WorkflowRun wfr Error", msg.Subject)
+	assert.Equal(t, "WorkflowRun wfr of workflow p1/wf Error in 1m30s. Stage build failed. https://cyclone.example.com/wfr", msg.Text)
+
+	msg, err = NewMessage(`{{.Workflow}}: {{index .Stages "build"}}`, testData())
+	assert.Nil(t, err)
+	assert.Equal(t, "wf: Error", msg.Text)
+
+	_, err = NewMessage("{{.Workflow", testData())
+	assert.NotNil(t, err)
+	_, err = NewMessage("{{.Unknown}}", testData())
+	assert.NotNil(t, err)
+}
+
+func TestNewSender(t *testing.T) {
+	_, err := NewSender(&api.Integration{Spec: api.IntegrationSpec{Type: api.Slack}})
+	assert.NotNil(t, err)
+	_, err = NewSender(&api.Integration{Spec: api.IntegrationSpec{Type: api.SCM, IntegrationSource: api.IntegrationSource{SCM: &api.SCMSource{}}}})
+	assert.NotNil(t, err)
+}
+
+func TestSlackAndWebhook(t *testing.T) {
+	var headers []http.Header
+	var bodies [][]byte
+	status := http.StatusOK
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		headers = append(headers, r.Header)
+		bodies = append(bodies, data)
+		w.WriteHeader(status)
+		w.Write([]byte("invalid_token"))
+	}))
+	defer s.Close()
+
+	msg, _ := NewMessage("", testData())
+	slack, err := NewSender(&api.Integration{Spec: api.IntegrationSpec{
+		Type:              api.Slack,
+		IntegrationSource: api.IntegrationSource{Slack: &api.SlackSource{URL: s.URL}},
+	}})
+	assert.Nil(t, err)
+	assert.Nil(t, slack.Send(msg))
+	assert.Equal(t, `{"text":"`+msg.Text+`"}`, string(bodies[0]))
+
+	webhook, err := NewSender(&api.Integration{Spec: api.IntegrationSpec{
+		Type:              api.Webhook,
+		IntegrationSource: api.IntegrationSource{Webhook: &api.WebhookSource{URL: s.URL, Secret: "s3cret"}},
+	}})
+	assert.Nil(t, err)
+	assert.Nil(t, webhook.Send(msg))
+	body := make(map[string]interface{})
+	assert.Nil(t, json.Unmarshal(bodies[1], &body))
+	assert.Equal(t, "wfr", body["workflowRun"])
+	assert.Equal(t, "Error", body["status"])
+	assert.Equal(t, msg.Text, body["text"])
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(bodies[1])
+	assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), headers[1].Get(SignatureHeader))
+
+	status = http.StatusForbidden
+	err = slack.Send(msg)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "403: invalid_token")
+}
+
+func TestEmail(t *testing.T) {
+	var addr, from string
+	var to []string
+	var content []byte
+	var auth smtp.Auth
+	sendMail = func(a string, au smtp.Auth, f string, t []string, msg []byte) error {
+		addr, auth, from, to, content = a, au, f, t, msg
+		return nil
+	}
+	defer func() { sendMail = sendMailWithTimeout }()
+
+	msg, _ := NewMessage("Line 1\nLine 2", testData())
+	sender, err := NewSender(&api.Integration{Spec: api.IntegrationSpec{
+		Type: api.Email,
+		IntegrationSource: api.IntegrationSource{Email: &api.EmailSource{
+			Server:   "smtp.example.com:587",
+			User:     "cyclone",
+			Password: "******",
+			From:     "cyclone@example.com",
+			To:       []string{"a@example.com", "b@example.com"},
+		}},
+	}})
+	assert.Nil(t, err)
+	assert.Nil(t, sender.Send(msg))
+	assert.Equal(t, "smtp.example.com:587", addr)
+	assert.NotNil(t, auth)
+	assert.Equal(t, "cyclone@example.com", from)
+	assert.Equal(t, []string{"a@example.com", "b@example.com"}, to)
+	assert.Equal(t, "From: cyclone@example.com\r\n"+
+		"To: a@example.com, b@example.com\r\n"+
+		"Subject: [Cyclone] WorkflowRun wfr Error\r\n"+
+		"MIME-Version: 1.0\r\n"+
+		"Content-Type: text/plain; charset=utf-8\r\n"+
+		"\r\n"+
+		"Line 1\r\nLine 2\r\n", string(content))
+}
+
+func TestSendMailTimeout(t *testing.T) {
+	origin := smtpTimeout
+	smtpTimeout = 100 * time.Millisecond
+	defer func() { smtpTimeout = origin }()
+
+	// SMTP server accepts connections but never responds.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	start := time.Now()
+	err = sendMailWithTimeout(l.Addr().String(), nil, "cyclone@example.com", []string{"a@example.com"}, []byte("hello"))
+	assert.NotNil(t, err)
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
+
+func TestSendMail(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+
+	// Minimal SMTP server records commands and data it received.
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		var lines []string
+		fmt.Fprint(conn, "220 localhost ESMTP\r\n")
+		data := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				break
+			}
+			line = strings.TrimRight(line, "\r\n")
+			lines = append(lines, line)
+			switch {
+			case data && line == ".":
+				data = false
+				fmt.Fprint(conn, "250 OK\r\n")
+			case data:
+			case strings.HasPrefix(line, "EHLO"):
+				fmt.Fprint(conn, "250 localhost\r\n")
+			case line == "DATA":
+				data = true
+				fmt.Fprint(conn, "354 Go ahead\r\n")
+			case line == "QUIT":
+				fmt.Fprint(conn, "221 Bye\r\n")
+				received <- lines
+				return
+			default:
+				fmt.Fprint(conn, "250 OK\r\n")
+			}
+		}
+		received <- lines
+	}()
+
+	err = sendMailWithTimeout(l.Addr().String(), nil, "cyclone@example.com", []string{"a@example.com"}, []byte("Subject: hi\r\n\r\nhello\r\n"))
+	assert.Nil(t, err)
+	lines := <-received
+	assert.Contains(t, lines, "MAIL FROM:<cyclone@example.com>")
+	assert.Contains(t, lines, "RCPT TO:<a@example.com>")
+	assert.Contains(t, lines, "hello")
+}
@@ -0,0 +1,28 @@
+package notification
+
+import (
+	"encoding/json"
+	"net/http"
+
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+)
+
+// slack sends messages to Slack compatible incoming webhooks, e.g. Slack, Mattermost and
+// Rocket.Chat.
+type slack struct {
+	source *api.SlackSource
+}
+
+type slackBody struct {
+	Text    string `json:"text"`
+	Channel string `json:"channel,omitempty"`
+}
+
+// Send ...
+func (s *slack) Send(msg *Message) error {
+	body, err := json.Marshal(&slackBody{Text: msg.Text, Channel: s.source.Channel})
+	if err != nil {
+		return err
+	}
+	return post(s.source.URL, http.Header{"Content-Type": {"application/json"}}, body)
+}
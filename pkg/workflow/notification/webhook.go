@@ -0,0 +1,41 @@
+package notification
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+)
+
+// SignatureHeader is header of the HMAC-SHA256 signature of webhook bodies, e.g. 'sha256=<hex>'.
+const SignatureHeader = "X-Cyclone-Signature"
+
+// webhook posts messages to generic HTTP webhooks, the body is the result of the WorkflowRun
+// together with the rendered text.
+type webhook struct {
+	source *api.WebhookSource
+}
+
+type webhookBody struct {
+	*Data
+	Text string `json:"text"`
+}
+
+// Send ...
+func (w *webhook) Send(msg *Message) error {
+	body, err := json.Marshal(&webhookBody{Data: msg.Data, Text: msg.Text})
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{"Content-Type": {"application/json"}}
+	if w.source.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.source.Secret))
+		mac.Write(body)
+		header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	return post(w.source.URL, header, body)
+}
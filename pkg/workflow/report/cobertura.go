@@ -0,0 +1,77 @@
+package report
+
+import (
+	"encoding/xml"
+	"io"
+
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+)
+
+type coberturaCoverage struct {
+	XMLName         xml.Name           `xml:"coverage"`
+	LinesCovered    int64              `xml:"lines-covered,attr"`
+	LinesValid      int64              `xml:"lines-valid,attr"`
+	BranchesCovered int64              `xml:"branches-covered,attr"`
+	BranchesValid   int64              `xml:"branches-valid,attr"`
+	Packages        []coberturaPackage `xml:"packages>package"`
+}
+
+type coberturaPackage struct {
+	Classes []coberturaClass `xml:"classes>class"`
+}
+
+type coberturaClass struct {
+	Lines []coberturaLine `xml:"lines>line"`
+}
+
+type coberturaLine struct {
+	Hits int64 `xml:"hits,attr"`
+}
+
+// coberturaParser parses Cobertura XML coverage reports. Line counts are taken from attributes
+// of <coverage>, and counted from <line> elements if the attributes are absent, which is the case
+// for reports generated by old versions of Cobertura.
+type coberturaParser struct {
+	linesCovered    int64
+	linesValid      int64
+	branchesCovered int64
+	branchesValid   int64
+}
+
+func (p *coberturaParser) parse(r io.Reader) error {
+	var coverage coberturaCoverage
+	if err := xml.NewDecoder(r).Decode(&coverage); err != nil {
+		return err
+	}
+
+	if coverage.LinesValid == 0 {
+		for _, pkg := range coverage.Packages {
+			for _, class := range pkg.Classes {
+				for _, line := range class.Lines {
+					coverage.LinesValid++
+					if line.Hits > 0 {
+						coverage.LinesCovered++
+					}
+				}
+			}
+		}
+	}
+
+	p.linesCovered += coverage.LinesCovered
+	p.linesValid += coverage.LinesValid
+	p.branchesCovered += coverage.BranchesCovered
+	p.branchesValid += coverage.BranchesValid
+	return nil
+}
+
+func (p *coberturaParser) result(report *api.TestReport) {
+	report.Coverage = &api.Coverage{
+		Covered: p.linesCovered,
+		Total:   p.linesValid,
+		Rate:    rate(p.linesCovered, p.linesValid),
+	}
+	if p.branchesValid > 0 {
+		branchRate := rate(p.branchesCovered, p.branchesValid)
+		report.Coverage.BranchRate = &branchRate
+	}
+}
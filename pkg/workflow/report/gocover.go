@@ -0,0 +1,79 @@
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+)
+
+type goCoverBlock struct {
+	statements int64
+	covered    bool
+}
+
+// goCoverParser parses coverage profiles generated by 'go test -coverprofile'. Each line of the
+// profile is a block in format '<file>:<start line>.<column>,<end line>.<column> <statements> <count>',
+// blocks in multiple profiles are merged, a block is covered if it's covered in any profile.
+type goCoverParser struct {
+	blocks map[string]*goCoverBlock
+}
+
+func (p *goCoverParser) parse(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if line == 1 {
+			if !strings.HasPrefix(text, "mode:") {
+				return fmt.Errorf("mode line expected, got '%s'", text)
+			}
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 3 {
+			return fmt.Errorf("invalid block at line %d: '%s'", line, text)
+		}
+		statements, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid statements at line %d: %v", line, err)
+		}
+		count, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid count at line %d: %v", line, err)
+		}
+
+		block, ok := p.blocks[fields[0]]
+		if !ok {
+			block = &goCoverBlock{statements: statements}
+			p.blocks[fields[0]] = block
+		}
+		block.covered = block.covered || count > 0
+	}
+
+	return scanner.Err()
+}
+
+func (p *goCoverParser) result(report *api.TestReport) {
+	var covered, total int64
+	for _, b := range p.blocks {
+		total += b.statements
+		if b.covered {
+			covered += b.statements
+		}
+	}
+
+	report.Coverage = &api.Coverage{
+		Covered: covered,
+		Total:   total,
+		Rate:    rate(covered, total),
+	}
+}
@@ -0,0 +1,119 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+)
+
+type junitTestSuites struct {
+	Suites []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name   string           `xml:"name,attr"`
+	Cases  []junitTestCase  `xml:"testcase"`
+	Suites []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestCase struct {
+	Name      string       `xml:"name,attr"`
+	ClassName string       `xml:"classname,attr"`
+	Time      string       `xml:"time,attr"`
+	Failure   *junitResult `xml:"failure"`
+	Error     *junitResult `xml:"error"`
+	Skipped   *junitResult `xml:"skipped"`
+}
+
+type junitResult struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// junitParser parses JUnit XML reports, root element of which is either <testsuites> or
+// <testsuite>. Nested test suites are flattened.
+type junitParser struct {
+	cases []api.TestCase
+}
+
+func (p *junitParser) parse(r io.Reader) error {
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "testsuites":
+			var suites junitTestSuites
+			if err := decoder.DecodeElement(&suites, &start); err != nil {
+				return err
+			}
+			for _, s := range suites.Suites {
+				p.addSuite(s)
+			}
+		case "testsuite":
+			var suite junitTestSuite
+			if err := decoder.DecodeElement(&suite, &start); err != nil {
+				return err
+			}
+			p.addSuite(suite)
+		default:
+			return fmt.Errorf("unexpected root element <%s>", start.Name.Local)
+		}
+		return nil
+	}
+}
+
+func (p *junitParser) addSuite(suite junitTestSuite) {
+	for _, c := range suite.Cases {
+		tc := api.TestCase{
+			Suite:     suite.Name,
+			ClassName: c.ClassName,
+			Name:      c.Name,
+			Status:    api.TestPassed,
+			Duration:  parseDuration(c.Time),
+		}
+		switch {
+		case c.Failure != nil:
+			tc.Status = api.TestFailed
+			tc.Message, tc.Details = c.Failure.Message, strings.TrimSpace(c.Failure.Body)
+		case c.Error != nil:
+			tc.Status = api.TestErrored
+			tc.Message, tc.Details = c.Error.Message, strings.TrimSpace(c.Error.Body)
+		case c.Skipped != nil:
+			tc.Status = api.TestSkipped
+			tc.Message = c.Skipped.Message
+		}
+		p.cases = append(p.cases, tc)
+	}
+
+	for _, s := range suite.Suites {
+		p.addSuite(s)
+	}
+}
+
+func (p *junitParser) result(report *api.TestReport) {
+	report.Cases = p.cases
+	for _, c := range p.cases {
+		report.Summary.Add(c)
+	}
+}
+
+// parseDuration parses time attribute in seconds, some tools write thousands separators.
+func parseDuration(t string) float64 {
+	d, err := strconv.ParseFloat(strings.Replace(t, ",", "", -1), 64)
+	if err != nil {
+		return 0
+	}
+	return d
+}
@@ -0,0 +1,81 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+)
+
+// parser parses report files of a format, results of multiple files are merged.
+type parser interface {
+	// parse parses a report file.
+	parse(r io.Reader) error
+	// result fills results of all parsed files to the report.
+	result(report *api.TestReport)
+}
+
+func newParser(format v1alpha1.ReportFormat) (parser, error) {
+	switch format {
+	case v1alpha1.ReportJUnit:
+		return &junitParser{}, nil
+	case v1alpha1.ReportCobertura:
+		return &coberturaParser{}, nil
+	case v1alpha1.ReportGoCover:
+		return &goCoverParser{blocks: make(map[string]*goCoverBlock)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported report format '%s'", format)
+	}
+}
+
+// Parse parses report files of the format at the path, which is a report file or a directory
+// containing report files, results of all files are merged into one report.
+func Parse(format v1alpha1.ReportFormat, p string) (*api.TestReport, error) {
+	parser, err := newParser(format)
+	if err != nil {
+		return nil, err
+	}
+
+	var files int
+	err = filepath.Walk(p, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if err := parser.parse(f); err != nil {
+			return fmt.Errorf("parse %s report %s error: %v", format, file, err)
+		}
+		files++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if files == 0 {
+		return nil, fmt.Errorf("no report file found in %s", p)
+	}
+
+	report := &api.TestReport{Format: string(format)}
+	parser.result(report)
+	return report, nil
+}
+
+// rate calculates ratio of covered to total, 0 if total is 0.
+func rate(covered, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(covered) / float64(total)
+}
@@ -0,0 +1,108 @@
+package report
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "report")
+	assert.Nil(t, err)
+	for name, content := range files {
+		assert.Nil(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755))
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestParseJUnit(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="pkg/a" tests="3">
+    <testcase classname="pkg/a" name="TestOK" time="0.5"></testcase>
+    <testcase classname="pkg/a" name="TestFail" time="1,000.25">
+      <failure message="expected 1, got 2">a_test.go:10: expected 1, got 2</failure>
+    </testcase>
+    <testcase classname="pkg/a" name="TestSkip"><skipped message="short mode"/></testcase>
+  </testsuite>
+</testsuites>`,
+		"sub/b.xml": `<testsuite name="pkg/b">
+  <testcase classname="pkg/b" name="TestPanic" time="0.1"><error message="panic">stack</error></testcase>
+</testsuite>`,
+	})
+	defer os.RemoveAll(dir)
+
+	report, err := Parse(v1alpha1.ReportJUnit, dir)
+	assert.Nil(t, err)
+	assert.Equal(t, api.TestSummary{Total: 4, Passed: 1, Failed: 2, Skipped: 1, Duration: 1000.85}, report.Summary)
+	assert.Nil(t, report.Coverage)
+	assert.Equal(t, api.TestCase{
+		Suite:     "pkg/a",
+		ClassName: "pkg/a",
+		Name:      "TestFail",
+		Status:    api.TestFailed,
+		Duration:  1000.25,
+		Message:   "expected 1, got 2",
+		Details:   "a_test.go:10: expected 1, got 2",
+	}, report.Cases[1])
+	assert.Equal(t, api.TestErrored, report.Cases[3].Status)
+
+	_, err = Parse(v1alpha1.ReportJUnit, writeFiles(t, map[string]string{"c.xml": "<coverage/>"}))
+	assert.Error(t, err)
+}
+
+func TestParseCobertura(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"new.xml": `<coverage line-rate="0.75" lines-covered="3" lines-valid="4" branches-covered="1" branches-valid="2"></coverage>`,
+		"old.xml": `<coverage line-rate="0.5">
+  <packages><package><classes>
+    <class><lines><line number="1" hits="2"/><line number="2" hits="0"/></lines></class>
+  </classes></package></packages>
+</coverage>`,
+	})
+	defer os.RemoveAll(dir)
+
+	report, err := Parse(v1alpha1.ReportCobertura, dir)
+	assert.Nil(t, err)
+	branchRate := 0.5
+	assert.Equal(t, &api.Coverage{Covered: 4, Total: 6, Rate: 4.0 / 6, BranchRate: &branchRate}, report.Coverage)
+}
+
+func TestParseGoCover(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"unit.out": `mode: set
+github.com/a/b/c.go:3.10,5.2 2 1
+github.com/a/b/c.go:7.10,9.2 3 0
+`,
+		"e2e.out": `mode: set
+github.com/a/b/c.go:7.10,9.2 3 1
+github.com/a/b/d.go:1.10,2.2 5 0
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	report, err := Parse(v1alpha1.ReportGoCover, dir)
+	assert.Nil(t, err)
+	assert.Equal(t, &api.Coverage{Covered: 5, Total: 10, Rate: 0.5}, report.Coverage)
+
+	_, err = Parse(v1alpha1.ReportGoCover, writeFiles(t, map[string]string{"bad.out": "github.com/a/b/c.go:3.10,5.2 2 1"}))
+	assert.Error(t, err)
+}
+
+func TestParseErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{})
+	defer os.RemoveAll(dir)
+
+	_, err := Parse(v1alpha1.ReportJUnit, dir)
+	assert.Error(t, err)
+	_, err = Parse("unknown", dir)
+	assert.Error(t, err)
+}
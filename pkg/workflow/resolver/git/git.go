@@ -0,0 +1,331 @@
+package git
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/caicloud/cyclone/pkg/workflow/resolver"
+)
+
+const (
+	// EnvURL is parameter of the git repository url, both HTTPS and SSH urls are supported.
+	EnvURL = "GIT_URL"
+	// EnvRevision is parameter of the revision to pull, it can be branch, tag, commit SHA or
+	// other references such as 'refs/pull/1/head'.
+	EnvRevision = "GIT_REVISION"
+	// EnvToken is parameter of the token to access the repository over HTTPS.
+	EnvToken = "GIT_TOKEN"
+	// EnvDepth is parameter of the depth of shallow clone, by default, full history is pulled.
+	EnvDepth = "GIT_DEPTH"
+	// EnvSubmodules is parameter of whether to pull submodules, 'true' to pull.
+	EnvSubmodules = "GIT_SUBMODULES"
+	// EnvSSHKeyFile is parameter of the SSH private key file to access the repository over SSH.
+	EnvSSHKeyFile = "GIT_SSH_KEY_FILE"
+	// EnvSSHKnownHostsFile is parameter of the SSH known_hosts file used to verify host key of the
+	// git server, if unset, known hosts in the image are used.
+	EnvSSHKnownHostsFile = "GIT_SSH_KNOWN_HOSTS_FILE"
+	// EnvSSHInsecureSkipHostKeyCheck is parameter of whether to skip host key checking for SSH urls,
+	// 'true' to skip. It's vulnerable to man-in-the-middle attacks and should only be used for testing.
+	EnvSSHInsecureSkipHostKeyCheck = "GIT_SSH_INSECURE_SKIP_HOST_KEY_CHECK"
+	// EnvSCMIntegration is parameter of the SCM integration whose credentials are used to access
+	// the repository. Token and SSH private key of the integration are passed to the resolver
+	// as GIT_TOKEN, GIT_SSH_KEY_FILE and GIT_SSH_KNOWN_HOSTS_FILE by Workflow Controller.
+	EnvSCMIntegration = "SCM_INTEGRATION"
+	// EnvPullPolicy is parameter of the pull policy, if set to IfNotExist, existing data is
+	// updated incrementally, otherwise it's removed before pulling.
+	EnvPullPolicy = resolver.EnvPullPolicy
+
+	// PullPolicyIfNotPresent indicates to make use of existing data.
+	PullPolicyIfNotPresent = resolver.PullPolicyIfNotPresent
+
+	// OutputCommit is key of the output of the resolved commit SHA.
+	OutputCommit = "commit"
+	// OutputAuthor is key of the output of the commit author.
+	OutputAuthor = "author"
+	// OutputMessage is key of the output of the commit message.
+	OutputMessage = "message"
+
+	// maxMessageLength is max length of the commit message in outputs, since the termination
+	// message of container is limited to 4096 bytes.
+	maxMessageLength = 1024
+)
+
+const usage = `This tool is used to resolve git resources, here git resource
+stands for a revision in a git repository.
+
+Usage:
+    $ docker run -it --rm \
+        -e GIT_URL=https://github.com/caicloud/cyclone.git \
+        -e GIT_REVISION=master \
+        -e GIT_TOKEN=xxxx \
+        -e GIT_DEPTH=1 \
+        -e GIT_SUBMODULES=true \
+        -e PULL_POLICY=IfNotExist \
+        git-resource-resolver:latest <COMMAND>
+
+Supported commands are:
+- help Print out this help messages.
+- pull Pull git source to $WORKDIR/data, "/workspace/data" by default.
+- push Push git source to remote git server. (Not implemented yet)
+
+Environment variables GIT_URL, GIT_REVISION must be set. GIT_URL can be
+HTTPS url or SSH url such as git@github.com:caicloud/cyclone.git, for SSH
+url, private key file should be given by GIT_SSH_KEY_FILE, and host key of
+the server is verified against GIT_SSH_KNOWN_HOSTS_FILE, or known hosts in
+the image if unset. Host key checking can be disabled by setting
+GIT_SSH_INSECURE_SKIP_HOST_KEY_CHECK to true, which is insecure. Revision can be
+branch, tag, commit SHA or references like refs/pull/1/head for GitHub pull
+requests and refs/merge-requests/1/head for GitLab merge requests. GIT_DEPTH
+gives depth of shallow clone, and set GIT_SUBMODULES to true to pull
+submodules. PULL_POLICY indicates whether pull resources when there already
+are old data, if set to IfNotExist, will make use of the old data and
+perform incremental pull, otherwise old data would be removed.
+
+The resolved commit SHA, author and message are written as key-value outputs
+'commit', 'author' and 'message'.`
+
+var commitPattern = regexp.MustCompile("^[0-9a-f]{40}$")
+
+// Resolver resolves git resources.
+type Resolver struct{}
+
+// Ensure *Resolver has implemented resolver.Resolver interface.
+var _ resolver.Resolver = (*Resolver)(nil)
+
+// Describe ...
+func (r *Resolver) Describe() string {
+	return usage
+}
+
+// Pull pulls the revision of git repository to data directory of the workspace.
+func (r *Resolver) Pull(ws *resolver.Workspace) error {
+	if err := ws.RequireParams(EnvURL, EnvRevision); err != nil {
+		return err
+	}
+
+	url := ws.Param(EnvURL)
+	if IsSSH(url) {
+		if err := setupSSH(ws.Param(EnvSSHKeyFile), ws.Param(EnvSSHKnownHostsFile), ws.Param(EnvSSHInsecureSkipHostKeyCheck) == "true"); err != nil {
+			return err
+		}
+	} else if ws.Param(EnvToken) == "" {
+		log.Warn("GIT_TOKEN is unset")
+	}
+
+	err := ws.PullOnce(func() error {
+		return pull(ws)
+	})
+	if err != nil {
+		return err
+	}
+
+	outputs, err := commitInfo(ws.DataDir())
+	if err != nil {
+		log.Warn("Get commit information error: ", err)
+		return nil
+	}
+	log.WithField("commit", outputs[OutputCommit]).Info("Revision pulled")
+	return ws.WriteOutputs(outputs)
+}
+
+func pull(ws *resolver.Workspace) error {
+	url := ws.Param(EnvURL)
+	revision := ws.Param(EnvRevision)
+	data := ws.DataDir()
+
+	// If data existed and pull policy is IfNotExist, perform incremental pull.
+	if ws.Incremental() {
+		// Ensure existed data come from the git repo
+		remotes, err := resolver.Output(data, "git", "remote", "-v")
+		if err != nil {
+			return fmt.Errorf("get remotes error: %v, %s", err, remotes)
+		}
+		if !strings.Contains(remotes, trimScheme(url)) {
+			return fmt.Errorf("existed data not a valid git repo for %s", trimScheme(url))
+		}
+	} else {
+		if _, err := os.Stat(data); err == nil {
+			log.Infof("Clean old data (%s) when pull policy is Always", data)
+			if err := os.RemoveAll(data); err != nil {
+				return err
+			}
+		}
+		if err := os.MkdirAll(data, 0755); err != nil {
+			return err
+		}
+		if err := resolver.Exec(data, "git", "init", "-q"); err != nil {
+			return err
+		}
+		if err := resolver.Exec(data, "git", "remote", "add", "origin", AuthURL(url, ws.Param(EnvToken))); err != nil {
+			return err
+		}
+	}
+
+	depth, err := depth(ws.Param(EnvDepth))
+	if err != nil {
+		return err
+	}
+	if err := fetch(data, revision, depth); err != nil {
+		return err
+	}
+
+	if ws.Param(EnvSubmodules) == "true" {
+		log.Info("Update submodules")
+		args := []string{"submodule", "update", "--init", "--recursive"}
+		if depth > 0 {
+			args = append(args, "--depth", strconv.Itoa(depth))
+		}
+		return resolver.Exec(data, "git", args...)
+	}
+
+	return nil
+}
+
+// fetch fetches the revision from origin and checkout it. Commit SHA can be fetched directly only
+// if the server allows it, otherwise all branches are fetched and the commit is checked out.
+func fetch(data, revision string, depth int) error {
+	args := []string{"fetch", "-v", "origin", revision}
+	if depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(depth))
+	}
+
+	log.Infof("Fetch %s from origin", revision)
+	err := resolver.Exec(data, "git", args...)
+	if err == nil {
+		return resolver.Exec(data, "git", "checkout", "-q", "FETCH_HEAD")
+	}
+	if !IsCommit(revision) {
+		return err
+	}
+
+	log.Infof("Fetch commit %s directly failed, fetch all branches instead", revision)
+	if err := resolver.Exec(data, "git", "fetch", "-v", "origin"); err != nil {
+		return err
+	}
+	return resolver.Exec(data, "git", "checkout", "-q", revision)
+}
+
+func depth(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := strconv.Atoi(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s: %s", EnvDepth, value)
+	}
+	return d, nil
+}
+
+// commitInfo gets SHA, author and message of the checked out commit.
+func commitInfo(data string) (map[string]string, error) {
+	out, err := resolver.Output(data, "git", "log", "-1", "--format=%H%n%an <%ae>%n%B")
+	if err != nil {
+		return nil, fmt.Errorf("%v, %s", err, out)
+	}
+	return parseCommitInfo(out)
+}
+
+func parseCommitInfo(out string) (map[string]string, error) {
+	lines := strings.SplitN(out, "\n", 3)
+	if len(lines) < 2 || !IsCommit(lines[0]) {
+		return nil, fmt.Errorf("unexpected git log output: %s", out)
+	}
+
+	var message string
+	if len(lines) == 3 {
+		message = strings.TrimSpace(lines[2])
+	}
+	if len(message) > maxMessageLength {
+		message = message[:maxMessageLength]
+	}
+
+	return map[string]string{
+		OutputCommit:  lines[0],
+		OutputAuthor:  lines[1],
+		OutputMessage: message,
+	}, nil
+}
+
+// IsCommit checks whether the revision is a full commit SHA.
+func IsCommit(revision string) bool {
+	return commitPattern.MatchString(revision)
+}
+
+// IsSSH checks whether the url is a SSH url, e.g. 'ssh://git@github.com/a/b.git' and
+// 'git@github.com:a/b.git'.
+func IsSSH(url string) bool {
+	if strings.HasPrefix(url, "ssh://") {
+		return true
+	}
+	return !strings.Contains(url, "://") && strings.Contains(url, "@") && strings.Contains(url, ":")
+}
+
+// setupSSH sets ssh command used by git with the private key and known hosts. The key is copied
+// to a temporary file since ssh requires the key file not accessible by others. Host key is always
+// checked unless insecure is true.
+func setupSSH(keyFile, knownHostsFile string, insecure bool) error {
+	command := "ssh"
+	if keyFile == "" {
+		log.Warn("GIT_SSH_KEY_FILE is unset for SSH url")
+	} else {
+		key, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return fmt.Errorf("read SSH key error: %v", err)
+		}
+		f, err := ioutil.TempFile("", "ssh-key")
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := f.Chmod(0600); err != nil {
+			return err
+		}
+		if _, err := f.Write(key); err != nil {
+			return err
+		}
+		command += " -i " + f.Name()
+	}
+
+	switch {
+	case insecure:
+		log.Warn("Host key checking is disabled for SSH url")
+		command += " -o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null"
+	case knownHostsFile != "":
+		if _, err := os.Stat(knownHostsFile); err != nil {
+			return fmt.Errorf("stat SSH known hosts file error: %v", err)
+		}
+		command += " -o StrictHostKeyChecking=yes -o UserKnownHostsFile=" + knownHostsFile
+	default:
+		command += " -o StrictHostKeyChecking=yes"
+	}
+
+	return os.Setenv("GIT_SSH_COMMAND", command)
+}
+
+// AuthURL adds token to the repository url, SSH urls are kept as they are.
+func AuthURL(url, token string) string {
+	if token == "" || IsSSH(url) {
+		return url
+	}
+	return strings.Replace(url, "//", "//"+token+"@", 1)
+}
+
+// trimScheme removes scheme from the url, e.g. 'https://github.com/a/b' to 'github.com/a/b'.
+func trimScheme(url string) string {
+	if i := strings.Index(url, "//"); i >= 0 {
+		return url[i+2:]
+	}
+	return url
+}
+
+// Push ...
+func (r *Resolver) Push(ws *resolver.Workspace) error {
+	log.Info("Not implemented yet")
+	return nil
+}
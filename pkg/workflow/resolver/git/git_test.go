@@ -0,0 +1,147 @@
+package git
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/caicloud/cyclone/pkg/workflow/resolver"
+)
+
+func TestAuthURL(t *testing.T) {
+	assert.Equal(t, "https://github.com/caicloud/cyclone.git", AuthURL("https://github.com/caicloud/cyclone.git", ""))
+	assert.Equal(t, "https://token@github.com/caicloud/cyclone.git", AuthURL("https://github.com/caicloud/cyclone.git", "token"))
+	assert.Equal(t, "git@github.com:caicloud/cyclone.git", AuthURL("git@github.com:caicloud/cyclone.git", "token"))
+	assert.Equal(t, "github.com/caicloud/cyclone.git", trimScheme("https://github.com/caicloud/cyclone.git"))
+}
+
+func TestSetupSSH(t *testing.T) {
+	origin, ok := os.LookupEnv("GIT_SSH_COMMAND")
+	defer func() {
+		if ok {
+			os.Setenv("GIT_SSH_COMMAND", origin)
+		} else {
+			os.Unsetenv("GIT_SSH_COMMAND")
+		}
+	}()
+
+	dir, _ := ioutil.TempDir("", "ssh")
+	defer os.RemoveAll(dir)
+	keyFile := filepath.Join(dir, "ssh-privatekey")
+	knownHostsFile := filepath.Join(dir, "known_hosts")
+	ioutil.WriteFile(keyFile, []byte("key"), 0400)
+	ioutil.WriteFile(knownHostsFile, []byte("github.com ssh-rsa AAAA"), 0400)
+
+	// Host key is checked by default.
+	assert.Nil(t, setupSSH(keyFile, "", false))
+	command := os.Getenv("GIT_SSH_COMMAND")
+	assert.True(t, strings.HasPrefix(command, "ssh -i "))
+	assert.True(t, strings.HasSuffix(command, " -o StrictHostKeyChecking=yes"))
+
+	assert.Nil(t, setupSSH(keyFile, knownHostsFile, false))
+	assert.True(t, strings.HasSuffix(os.Getenv("GIT_SSH_COMMAND"), " -o StrictHostKeyChecking=yes -o UserKnownHostsFile="+knownHostsFile))
+	assert.NotNil(t, setupSSH(keyFile, filepath.Join(dir, "missing"), false))
+
+	// Host key checking is skipped only if asked explicitly.
+	assert.Nil(t, setupSSH("", knownHostsFile, true))
+	assert.Equal(t, "ssh -o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null", os.Getenv("GIT_SSH_COMMAND"))
+}
+
+func TestIsSSH(t *testing.T) {
+	assert.True(t, IsSSH("git@github.com:caicloud/cyclone.git"))
+	assert.True(t, IsSSH("ssh://git@github.com/caicloud/cyclone.git"))
+	assert.False(t, IsSSH("https://github.com/caicloud/cyclone.git"))
+	assert.False(t, IsSSH("https://token@github.com/caicloud/cyclone.git"))
+}
+
+func TestParseCommitInfo(t *testing.T) {
+	sha := strings.Repeat("a", 40)
+	info, err := parseCommitInfo(sha + "\nJohn <john@example.com>\nFix bug\n\nDetails\n")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		OutputCommit:  sha,
+		OutputAuthor:  "John <john@example.com>",
+		OutputMessage: "Fix bug\n\nDetails",
+	}, info)
+
+	_, err = parseCommitInfo("fatal: not a git repository")
+	assert.NotNil(t, err)
+}
+
+func run(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=tester", "GIT_AUTHOR_EMAIL=tester@example.com",
+		"GIT_COMMITTER_NAME=tester", "GIT_COMMITTER_EMAIL=tester@example.com")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v error: %v, %s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestPull(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	// Prepare a repository with two commits and a pull request ref.
+	repo, _ := ioutil.TempDir("", "repo")
+	defer os.RemoveAll(repo)
+	run(t, repo, "init", "-q")
+	run(t, repo, "checkout", "-q", "-b", "master")
+	ioutil.WriteFile(filepath.Join(repo, "a.txt"), []byte("v1"), 0644)
+	run(t, repo, "add", ".")
+	run(t, repo, "commit", "-q", "-m", "First commit")
+	first := run(t, repo, "rev-parse", "HEAD")
+	ioutil.WriteFile(filepath.Join(repo, "a.txt"), []byte("v2"), 0644)
+	run(t, repo, "commit", "-q", "-am", "Second commit")
+	second := run(t, repo, "rev-parse", "HEAD")
+	run(t, repo, "update-ref", "refs/pull/1/head", first)
+	run(t, repo, "config", "uploadpack.allowReachableSHA1InWant", "true")
+
+	cases := []struct {
+		revision string
+		depth    string
+		commit   string
+		content  string
+	}{
+		{"master", "", second, "v2"},
+		{"master", "1", second, "v2"},
+		{"refs/pull/1/head", "", first, "v1"},
+		{first, "", first, "v1"},
+	}
+
+	for _, c := range cases {
+		workspace, _ := ioutil.TempDir("", "workspace")
+		ws := &resolver.Workspace{
+			Dir:         workspace,
+			WorkflowRun: "wfr",
+			Params: map[string]string{
+				EnvURL:      "file://" + repo,
+				EnvRevision: c.revision,
+				EnvDepth:    c.depth,
+			},
+			OutputFile: filepath.Join(workspace, "outputs"),
+		}
+
+		r := &Resolver{}
+		assert.Nil(t, r.Pull(ws), c.revision)
+		data, _ := ioutil.ReadFile(filepath.Join(ws.DataDir(), "a.txt"))
+		assert.Equal(t, c.content, string(data), c.revision)
+
+		outputs := make(map[string]string)
+		data, _ = ioutil.ReadFile(ws.OutputFile)
+		json.Unmarshal(data, &outputs)
+		assert.Equal(t, c.commit, outputs[OutputCommit], c.revision)
+		assert.Equal(t, "tester <tester@example.com>", outputs[OutputAuthor], c.revision)
+
+		os.RemoveAll(workspace)
+	}
+}
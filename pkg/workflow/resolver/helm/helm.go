@@ -0,0 +1,325 @@
+package helm
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/caicloud/cyclone/pkg/workflow/resolver"
+)
+
+const (
+	// EnvRepo is parameter of the chart repository URL, e.g. https://charts.example.com.
+	EnvRepo = "HELM_REPO"
+	// EnvChart is parameter of the chart name.
+	EnvChart = "HELM_CHART"
+	// EnvVersion is parameter of the chart version to pull, latest stable version is pulled if
+	// not set.
+	EnvVersion = "HELM_VERSION"
+	// EnvUntar is parameter to extract the chart archive when pulling, 'true' to enable.
+	EnvUntar = "HELM_UNTAR"
+	// EnvPushURL is parameter of the URL to upload charts, default to ChartMuseum API
+	// '<repo>/api/charts'.
+	EnvPushURL = "HELM_PUSH_URL"
+	// EnvChartFile is parameter of the chart archive in data directory. When pulling, it
+	// defaults to '<chart>-<version>.tgz'. When pushing, the only '.tgz' file in data directory
+	// is used if not set.
+	EnvChartFile = "HELM_CHART_FILE"
+
+	// OutputVersion is key of the chart version in outputs.
+	OutputVersion = "version"
+	// OutputDigest is key of the chart archive digest in outputs.
+	OutputDigest = "digest"
+)
+
+const usage = `This tool is used to resolve Helm resources, here Helm resource
+stands for a chart in a chart repository.
+
+Usage:
+    $ docker run -it --rm \
+        -e HELM_REPO=https://charts.example.com \
+        -e HELM_CHART=app \
+        -e HELM_VERSION=1.2.0 \
+        helm-resource-resolver:latest <COMMAND>
+
+Supported commands are:
+- help Print out this help messages.
+- pull Download the chart archive to data directory.
+- push Upload the chart archive in data directory to the repository.
+
+Environment variables HELM_REPO and HELM_CHART must be set for pulling,
+HELM_REPO or HELM_PUSH_URL must be set for pushing. Other optional variables:
+- HELM_VERSION Version of the chart, latest stable version if not set.
+- HELM_UNTAR Set to 'true' to extract the chart archive when pulling.
+- HELM_PUSH_URL URL to upload chart, default <HELM_REPO>/api/charts (ChartMuseum).
+- HELM_CHART_FILE Chart archive in data directory.
+- AUTH_USERNAME, AUTH_PASSWORD Credentials sent in basic auth.
+- AUTH_TOKEN Token sent as bearer token.
+
+Version and sha256 digest of the chart archive are written as 'version' and
+'digest' outputs.`
+
+// Resolver resolves Helm resources.
+type Resolver struct{}
+
+// Ensure *Resolver has implemented resolver.Resolver interface.
+var _ resolver.Resolver = (*Resolver)(nil)
+
+// Describe ...
+func (r *Resolver) Describe() string {
+	return usage
+}
+
+// index is the index.yaml of a chart repository.
+type index struct {
+	Entries map[string][]chartVersion `json:"entries"`
+}
+
+// chartVersion describes a chart version in repository index.
+type chartVersion struct {
+	Name    string   `json:"name"`
+	Version string   `json:"version"`
+	Digest  string   `json:"digest"`
+	URLs    []string `json:"urls"`
+}
+
+// Pull downloads the chart archive to data directory.
+func (r *Resolver) Pull(ws *resolver.Workspace) error {
+	if err := ws.RequireParams(EnvRepo, EnvChart); err != nil {
+		return err
+	}
+
+	return ws.PullWithOutputs(func() (map[string]string, error) {
+		o, err := pull(ws)
+		if err != nil {
+			os.RemoveAll(ws.DataDir())
+			return nil, err
+		}
+		return o, nil
+	})
+}
+
+func pull(ws *resolver.Workspace) (map[string]string, error) {
+	repo := strings.TrimSuffix(ws.Param(EnvRepo), "/")
+	chart, err := findChart(ws, repo, ws.Param(EnvChart), ws.Param(EnvVersion))
+	if err != nil {
+		return nil, err
+	}
+	if len(chart.URLs) == 0 {
+		return nil, fmt.Errorf("no url for chart %s-%s", chart.Name, chart.Version)
+	}
+	chartURL, err := resolveURL(repo, chart.URLs[0])
+	if err != nil {
+		return nil, err
+	}
+
+	file := filepath.Join(ws.DataDir(), filepath.Clean("/"+ws.ParamOrDefault(EnvChartFile, fmt.Sprintf("%s-%s.tgz", chart.Name, chart.Version))))
+	log.WithField("chart", chart.Name).WithField("version", chart.Version).Info("Download chart")
+	digest, _, err := ws.Download(chartURL, file)
+	if err != nil {
+		return nil, err
+	}
+	if chart.Digest != "" && "sha256:"+strings.TrimPrefix(chart.Digest, "sha256:") != digest {
+		return nil, fmt.Errorf("checksum mismatch, expected %s, got %s", chart.Digest, digest)
+	}
+
+	if ws.Param(EnvUntar) == "true" {
+		if err := resolver.Extract(file, ws.DataDir()); err != nil {
+			return nil, fmt.Errorf("extract %s error: %v", filepath.Base(file), err)
+		}
+	}
+
+	return map[string]string{
+		OutputVersion: chart.Version,
+		OutputDigest:  digest,
+	}, nil
+}
+
+// findChart finds the chart version in repository index. If version is empty, the latest
+// version without pre-release part is selected.
+func findChart(ws *resolver.Workspace, repo, name, version string) (*chartVersion, error) {
+	resp, err := ws.Get(repo + "/index.yaml")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	idx := &index{}
+	if err := yaml.Unmarshal(data, idx); err != nil {
+		return nil, fmt.Errorf("parse index.yaml error: %v", err)
+	}
+
+	var found *chartVersion
+	for i, c := range idx.Entries[name] {
+		if version != "" {
+			if strings.TrimPrefix(c.Version, "v") == strings.TrimPrefix(version, "v") {
+				return &idx.Entries[name][i], nil
+			}
+			continue
+		}
+		if strings.Contains(c.Version, "-") {
+			continue
+		}
+		if found == nil || compareVersions(c.Version, found.Version) > 0 {
+			found = &idx.Entries[name][i]
+		}
+	}
+	if found == nil {
+		if version != "" {
+			return nil, fmt.Errorf("chart %s with version %s not found", name, version)
+		}
+		return nil, fmt.Errorf("no stable version found for chart %s", name)
+	}
+	return found, nil
+}
+
+// resolveURL resolves chart URL in index, which may be relative to the repository.
+func resolveURL(repo, ref string) (string, error) {
+	base, err := url.Parse(repo + "/")
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(u).String(), nil
+}
+
+// compareVersions compares two semantic versions without pre-release part, it returns 1 if a is
+// greater than b, -1 if a is less than b, otherwise 0.
+func compareVersions(a, b string) int {
+	pa := strings.Split(strings.SplitN(strings.TrimPrefix(a, "v"), "+", 2)[0], ".")
+	pb := strings.Split(strings.SplitN(strings.TrimPrefix(b, "v"), "+", 2)[0], ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y int
+		if i < len(pa) {
+			x, _ = strconv.Atoi(pa[i])
+		}
+		if i < len(pb) {
+			y, _ = strconv.Atoi(pb[i])
+		}
+		if x > y {
+			return 1
+		}
+		if x < y {
+			return -1
+		}
+	}
+	return 0
+}
+
+// Push uploads the chart archive in data directory after workload finished.
+func (r *Resolver) Push(ws *resolver.Workspace) error {
+	pushURL := ws.Param(EnvPushURL)
+	if pushURL == "" {
+		if err := ws.RequireParams(EnvRepo); err != nil {
+			return fmt.Errorf("either %s or %s should be set", EnvRepo, EnvPushURL)
+		}
+		pushURL = strings.TrimSuffix(ws.Param(EnvRepo), "/") + "/api/charts"
+	}
+
+	ws.WaitNotify()
+	file, err := chartFile(ws)
+	if err != nil {
+		return err
+	}
+	meta, err := readChartMeta(file)
+	if err != nil {
+		return err
+	}
+	digest, err := resolver.FileDigest(file)
+	if err != nil {
+		return err
+	}
+
+	log.WithField("chart", meta.Name).WithField("version", meta.Version).Info("Upload chart")
+	header := map[string][]string{"Content-Type": {"application/octet-stream"}}
+	if _, err := ws.Upload("POST", pushURL, file, header); err != nil {
+		return err
+	}
+
+	return ws.WriteOutputs(map[string]string{
+		OutputVersion: meta.Version,
+		OutputDigest:  digest,
+	})
+}
+
+// chartFile gets the chart archive to push.
+func chartFile(ws *resolver.Workspace) (string, error) {
+	if name := ws.Param(EnvChartFile); name != "" {
+		return filepath.Join(ws.DataDir(), filepath.Clean("/"+name)), nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(ws.DataDir(), "*.tgz"))
+	if err != nil {
+		return "", err
+	}
+	if len(files) != 1 {
+		return "", fmt.Errorf("expect exactly one chart archive in data directory, found %d, set %s to choose one", len(files), EnvChartFile)
+	}
+	return files[0], nil
+}
+
+// chartMeta is metadata of a chart in Chart.yaml.
+type chartMeta struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// readChartMeta reads Chart.yaml from the chart archive.
+func readChartMeta(file string) (*chartMeta, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("read chart %s error: %v", filepath.Base(file), err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("Chart.yaml not found in %s", filepath.Base(file))
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Chart.yaml is under the top level directory of the archive, e.g. 'app/Chart.yaml'.
+		name := strings.TrimPrefix(path.Clean(hdr.Name), "/")
+		if path.Base(name) != "Chart.yaml" || strings.Count(name, "/") != 1 {
+			continue
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		meta := &chartMeta{}
+		if err := yaml.Unmarshal(data, meta); err != nil {
+			return nil, fmt.Errorf("parse Chart.yaml error: %v", err)
+		}
+		if meta.Version == "" {
+			return nil, fmt.Errorf("version not found in Chart.yaml")
+		}
+		return meta, nil
+	}
+}
@@ -0,0 +1,157 @@
+package helm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/caicloud/cyclone/pkg/workflow/common"
+	"github.com/caicloud/cyclone/pkg/workflow/resolver"
+)
+
+func newWorkspace(params map[string]string) *resolver.Workspace {
+	dir, _ := ioutil.TempDir("", "workspace")
+	os.MkdirAll(filepath.Join(dir, common.ResolverNotifyDir), 0755)
+	ioutil.WriteFile(filepath.Join(dir, common.ResolverNotifyDir, common.ResolverNotifyOkFile), nil, 0644)
+	return &resolver.Workspace{
+		Dir:         dir,
+		WorkflowRun: "wfr",
+		Params:      params,
+		OutputFile:  filepath.Join(dir, "termination-log"),
+	}
+}
+
+func readOutputs(t *testing.T, ws *resolver.Workspace) map[string]string {
+	data, err := ioutil.ReadFile(ws.OutputFile)
+	assert.Nil(t, err)
+	outputs := make(map[string]string)
+	assert.Nil(t, json.Unmarshal(data, &outputs))
+	return outputs
+}
+
+// chartArchive builds a chart archive with only Chart.yaml.
+func chartArchive(name, version string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	content := fmt.Sprintf("name: %s\nversion: %s\n", name, version)
+	tw.WriteHeader(&tar.Header{Name: name + "/Chart.yaml", Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+	tw.Write([]byte(content))
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestPull(t *testing.T) {
+	chart := chartArchive("app", "1.10.0")
+	sum := sha256.Sum256(chart)
+	index := fmt.Sprintf(`apiVersion: v1
+entries:
+  app:
+  - name: app
+    version: 2.0.0-rc.1
+    urls: [charts/app-2.0.0-rc.1.tgz]
+  - name: app
+    version: 1.10.0
+    digest: %s
+    urls: [charts/app-1.10.0.tgz]
+  - name: app
+    version: 1.9.0
+    digest: 0000
+    urls: [charts/app-1.9.0.tgz]
+`, hex.EncodeToString(sum[:]))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/stable/index.yaml":
+			w.Write([]byte(index))
+		case "/stable/charts/app-1.10.0.tgz", "/stable/charts/app-1.9.0.tgz":
+			w.Write(chart)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	// Digest mismatch
+	ws := newWorkspace(map[string]string{
+		EnvRepo:    server.URL + "/stable/",
+		EnvChart:   "app",
+		EnvVersion: "1.9.0",
+	})
+	defer os.RemoveAll(ws.Dir)
+	assert.NotNil(t, (&Resolver{}).Pull(ws))
+
+	// Latest stable version
+	ws = newWorkspace(map[string]string{
+		EnvRepo:  server.URL + "/stable/",
+		EnvChart: "app",
+		EnvUntar: "true",
+	})
+	defer os.RemoveAll(ws.Dir)
+	assert.Nil(t, (&Resolver{}).Pull(ws))
+	_, err := os.Stat(filepath.Join(ws.DataDir(), "app-1.10.0.tgz"))
+	assert.Nil(t, err)
+	_, err = os.Stat(filepath.Join(ws.DataDir(), "app", "Chart.yaml"))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		OutputVersion: "1.10.0",
+		OutputDigest:  "sha256:" + hex.EncodeToString(sum[:]),
+	}, readOutputs(t, ws))
+}
+
+func TestPush(t *testing.T) {
+	var uploaded []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/charts" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		uploaded, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	ws := newWorkspace(map[string]string{EnvRepo: server.URL})
+	defer os.RemoveAll(ws.Dir)
+	// No chart archive
+	assert.NotNil(t, (&Resolver{}).Push(ws))
+
+	chart := chartArchive("app", "0.2.0")
+	sum := sha256.Sum256(chart)
+	os.MkdirAll(ws.DataDir(), 0755)
+	ioutil.WriteFile(filepath.Join(ws.DataDir(), "app-0.2.0.tgz"), chart, 0644)
+	assert.Nil(t, (&Resolver{}).Push(ws))
+	assert.Equal(t, chart, uploaded)
+	assert.Equal(t, map[string]string{
+		OutputVersion: "0.2.0",
+		OutputDigest:  "sha256:" + hex.EncodeToString(sum[:]),
+	}, readOutputs(t, ws))
+}
+
+func TestCompareVersions(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.10.0", "1.9.0", 1},
+		{"v1.2.0", "1.2.0", 0},
+		{"1.2", "1.2.1", -1},
+		{"2.0.0+build.1", "1.99.99", 1},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, compareVersions(c.a, c.b), "%s vs %s", c.a, c.b)
+	}
+}
@@ -0,0 +1,254 @@
+package resolver
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// EnvIntegration is parameter of the integration whose credentials are injected into the
+	// resolver as environment variables by Workflow Controller, e.g. S3_ACCESS_KEY from
+	// ObjectStorage integrations, AUTH_USERNAME and AUTH_PASSWORD from Repository integrations.
+	// Parameters given in the resource take precedence over the injected ones.
+	EnvIntegration = "INTEGRATION"
+	// EnvAuthUsername is username used by resolvers accessing HTTP servers, e.g. HTTP, Helm and
+	// Maven resolvers, it's sent in basic auth together with EnvAuthPassword. Credentials are
+	// usually injected from integrations.
+	EnvAuthUsername = "AUTH_USERNAME"
+	// EnvAuthPassword is password used together with EnvAuthUsername.
+	EnvAuthPassword = "AUTH_PASSWORD"
+	// EnvAuthToken is token sent as bearer token, it takes precedence over username and password.
+	EnvAuthToken = "AUTH_TOKEN"
+)
+
+// NewRequest creates HTTP request with credentials in workspace parameters.
+func (w *Workspace) NewRequest(method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if token := w.Param(EnvAuthToken); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if user := w.Param(EnvAuthUsername); user != "" {
+		req.SetBasicAuth(user, w.Param(EnvAuthPassword))
+	}
+	return req, nil
+}
+
+// Get sends GET request to the URL, error is returned if response status is not 200. The caller
+// should close the response body.
+func (w *Workspace) Get(url string) (*http.Response, error) {
+	req, err := w.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := w.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, responseError(req, resp)
+	}
+	return resp, nil
+}
+
+// Do sends the request, error is returned if response status is not 2xx. The caller should
+// close the response body.
+func (w *Workspace) Do(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, responseError(req, resp)
+	}
+	return resp, nil
+}
+
+// Download downloads content of the URL to the file, sha256 digest of the content in format
+// 'sha256:<hex>' and response headers are returned.
+func (w *Workspace) Download(url, file string) (string, http.Header, error) {
+	resp, err := w.Get(url)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return "", nil, err
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), resp.Body); err != nil {
+		os.Remove(file)
+		return "", nil, err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), resp.Header, nil
+}
+
+// Upload uploads the file to the URL with the method, e.g. PUT, POST. Response headers are
+// returned.
+func (w *Workspace) Upload(method, url, file string, header http.Header) (http.Header, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := w.NewRequest(method, url, f)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = info.Size()
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := w.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp.Header, nil
+}
+
+// responseError builds error from the response, user info and query are removed from the URL
+// since they may contain credentials.
+func responseError(req *http.Request, resp *http.Response) error {
+	u := *req.URL
+	u.User = nil
+	u.RawQuery = ""
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("%s %s error, status %d: %s", req.Method, u.String(), resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+// FileDigest computes sha256 digest of the file in format 'sha256:<hex>'.
+func FileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Extract extracts the archive file to the directory, tar, gzip compressed tar and zip archives
+// are supported. Entries are kept inside the directory, links are ignored.
+func Extract(file, dir string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	magic, _ := r.Peek(2)
+	if len(magic) == 2 && magic[0] == 'P' && magic[1] == 'K' {
+		return extractZip(file, dir)
+	}
+
+	var reader io.Reader = r
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	tr := tar.NewReader(reader)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dir, filepath.Clean("/"+hdr.Name))
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if err := writeFile(target, os.FileMode(hdr.Mode), tr); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func extractZip(file, dir string) error {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, entry := range zr.File {
+		target := filepath.Join(dir, filepath.Clean("/"+entry.Name))
+		if entry.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if !entry.Mode().IsRegular() {
+			continue
+		}
+
+		rc, err := entry.Open()
+		if err != nil {
+			return err
+		}
+		err = writeFile(target, entry.Mode(), rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFile(path string, mode os.FileMode, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm()|0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(f, r)
+	return err
+}
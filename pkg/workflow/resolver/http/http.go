@@ -0,0 +1,159 @@
+package http
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/caicloud/cyclone/pkg/workflow/resolver"
+)
+
+const (
+	// EnvURL is parameter of the file URL.
+	EnvURL = "HTTP_URL"
+	// EnvFile is parameter of the file name in data directory, default to the last element of
+	// the URL path.
+	EnvFile = "HTTP_FILE"
+	// EnvSHA256 is parameter of the expected sha256 checksum of the file, file is verified
+	// against it when pulling if set.
+	EnvSHA256 = "HTTP_SHA256"
+	// EnvExtract is parameter to extract the file when pulling, 'true' to enable. Tar, gzip
+	// compressed tar and zip archives are supported.
+	EnvExtract = "HTTP_EXTRACT"
+	// EnvMethod is parameter of the HTTP method to upload the file when pushing, default to PUT.
+	EnvMethod = "HTTP_METHOD"
+
+	// OutputDigest is key of the file digest in outputs.
+	OutputDigest = "digest"
+	// OutputETag is key of the ETag returned by server in outputs.
+	OutputETag = "etag"
+)
+
+const usage = `This tool is used to resolve HTTP resources, here HTTP resource
+stands for a file served by HTTP server, e.g. a tarball.
+
+Usage:
+    $ docker run -it --rm \
+        -e HTTP_URL=https://example.com/files/app.tar.gz \
+        -e HTTP_EXTRACT=true \
+        http-resource-resolver:latest <COMMAND>
+
+Supported commands are:
+- help Print out this help messages.
+- pull Download the file to data directory.
+- push Upload the file in data directory to the URL.
+
+Environment variable HTTP_URL must be set. Other optional variables:
+- HTTP_FILE File name in data directory, default to last element of URL path.
+- HTTP_SHA256 Expected sha256 checksum of the file to verify when pulling.
+- HTTP_EXTRACT Set to 'true' to extract tar, tar.gz or zip archive when pulling.
+- HTTP_METHOD HTTP method to upload the file, default PUT.
+- AUTH_USERNAME, AUTH_PASSWORD Credentials sent in basic auth.
+- AUTH_TOKEN Token sent as bearer token.
+
+Sha256 digest of the file and ETag are written as 'digest' and 'etag' outputs.`
+
+// Resolver resolves HTTP resources.
+type Resolver struct{}
+
+// Ensure *Resolver has implemented resolver.Resolver interface.
+var _ resolver.Resolver = (*Resolver)(nil)
+
+// Describe ...
+func (r *Resolver) Describe() string {
+	return usage
+}
+
+// Pull downloads the file to data directory.
+func (r *Resolver) Pull(ws *resolver.Workspace) error {
+	file, err := filePath(ws)
+	if err != nil {
+		return err
+	}
+
+	return ws.PullWithOutputs(func() (map[string]string, error) {
+		log.WithField("url", redact(ws.Param(EnvURL))).Info("Download file")
+		digest, header, err := ws.Download(ws.Param(EnvURL), file)
+		if err != nil {
+			os.RemoveAll(ws.DataDir())
+			return nil, err
+		}
+		if expected := ws.Param(EnvSHA256); expected != "" && "sha256:"+strings.TrimPrefix(expected, "sha256:") != digest {
+			os.RemoveAll(ws.DataDir())
+			return nil, fmt.Errorf("checksum mismatch, expected %s, got %s", expected, digest)
+		}
+
+		if ws.Param(EnvExtract) == "true" {
+			if err := resolver.Extract(file, ws.DataDir()); err != nil {
+				os.RemoveAll(ws.DataDir())
+				return nil, fmt.Errorf("extract %s error: %v", filepath.Base(file), err)
+			}
+		}
+
+		return outputs(digest, header.Get("ETag")), nil
+	})
+}
+
+// Push uploads the file in data directory to the URL after workload finished.
+func (r *Resolver) Push(ws *resolver.Workspace) error {
+	file, err := filePath(ws)
+	if err != nil {
+		return err
+	}
+
+	ws.WaitNotify()
+	digest, err := resolver.FileDigest(file)
+	if err != nil {
+		return err
+	}
+	log.WithField("url", redact(ws.Param(EnvURL))).Info("Upload file ", filepath.Base(file))
+	header, err := ws.Upload(strings.ToUpper(ws.ParamOrDefault(EnvMethod, "PUT")), ws.Param(EnvURL), file, nil)
+	if err != nil {
+		return err
+	}
+
+	return ws.WriteOutputs(outputs(digest, header.Get("ETag")))
+}
+
+// filePath gets path of the file in data directory.
+func filePath(ws *resolver.Workspace) (string, error) {
+	if err := ws.RequireParams(EnvURL); err != nil {
+		return "", err
+	}
+	u, err := url.Parse(ws.Param(EnvURL))
+	if err != nil {
+		return "", fmt.Errorf("invalid %s: %v", EnvURL, err)
+	}
+
+	name := ws.ParamOrDefault(EnvFile, path.Base(u.Path))
+	if name == "" || name == "/" || name == "." {
+		return "", fmt.Errorf("%s not set and can't be determined from url", EnvFile)
+	}
+	return filepath.Join(ws.DataDir(), filepath.Clean("/"+name)), nil
+}
+
+func outputs(digest, etag string) map[string]string {
+	o := map[string]string{
+		OutputDigest: digest,
+	}
+	if etag != "" {
+		o[OutputETag] = strings.Trim(etag, `"`)
+	}
+	return o
+}
+
+// redact removes user info and query from the URL for logging, since they may contain credentials.
+func redact(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	u.User = nil
+	u.RawQuery = ""
+	return u.String()
+}
@@ -0,0 +1,121 @@
+package resolver
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtract(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "extract")
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"app/bin/run.sh": "run",
+		"../escape.txt":  "escape",
+	}
+
+	// Gzip compressed tar
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	ioutil.WriteFile(filepath.Join(dir, "app.tgz"), buf.Bytes(), 0644)
+
+	// Zip
+	buf.Reset()
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, _ := zw.Create(name)
+		w.Write([]byte(content))
+	}
+	zw.Close()
+	ioutil.WriteFile(filepath.Join(dir, "app.zip"), buf.Bytes(), 0644)
+
+	for _, archive := range []string{"app.tgz", "app.zip"} {
+		out := filepath.Join(dir, archive+".out")
+		assert.Nil(t, Extract(filepath.Join(dir, archive), out))
+
+		data, err := ioutil.ReadFile(filepath.Join(out, "app/bin/run.sh"))
+		assert.Nil(t, err)
+		assert.Equal(t, "run", string(data))
+		// Entries escaping the directory are kept inside it.
+		data, err = ioutil.ReadFile(filepath.Join(out, "escape.txt"))
+		assert.Nil(t, err)
+		assert.Equal(t, "escape", string(data))
+	}
+	_, err := os.Stat(filepath.Join(filepath.Dir(dir), "escape.txt"))
+	assert.True(t, os.IsNotExist(err))
+
+	assert.NotNil(t, Extract(filepath.Join(dir, "not-exist"), dir))
+}
+
+func TestDownload(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "workspace")
+	defer os.RemoveAll(dir)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "admin" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("ETag", `"abc"`)
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	ws := &Workspace{Dir: dir, Params: map[string]string{}}
+	_, _, err := ws.Download(server.URL+"/file", filepath.Join(dir, "file"))
+	assert.NotNil(t, err)
+
+	ws.Params[EnvAuthUsername] = "admin"
+	ws.Params[EnvAuthPassword] = "secret"
+	digest, header, err := ws.Download(server.URL+"/file", filepath.Join(dir, "file"))
+	assert.Nil(t, err)
+	assert.Equal(t, "sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", digest)
+	assert.Equal(t, `"abc"`, header.Get("ETag"))
+
+	sum, err := FileDigest(filepath.Join(dir, "file"))
+	assert.Nil(t, err)
+	assert.Equal(t, digest, sum)
+}
+
+func TestPullWithOutputs(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "workspace")
+	defer os.RemoveAll(dir)
+
+	for i, expected := range []bool{true, false} {
+		output := filepath.Join(dir, "termination-log")
+		ws := &Workspace{Dir: dir, WorkflowRun: "wfr", OutputFile: output}
+
+		var pulled bool
+		err := ws.PullWithOutputs(func() (map[string]string, error) {
+			pulled = true
+			return map[string]string{"version": "v1"}, os.MkdirAll(ws.DataDir(), 0755)
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, expected, pulled, "case %d", i)
+
+		// Stage that doesn't pull the resource gets the same outputs.
+		data, _ := ioutil.ReadFile(output)
+		outputs := make(map[string]string)
+		assert.Nil(t, json.Unmarshal(data, &outputs))
+		assert.Equal(t, map[string]string{"version": "v1"}, outputs)
+		os.Remove(output)
+	}
+}
@@ -0,0 +1,179 @@
+package image
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/caicloud/cyclone/pkg/util/registry"
+	"github.com/caicloud/cyclone/pkg/workflow/common"
+	"github.com/caicloud/cyclone/pkg/workflow/resolver"
+)
+
+const (
+	// EnvImage is parameter of the image, it should be a full name in format
+	// <domain>/<project>/<repo>:<tag>.
+	EnvImage = "IMAGE"
+	// EnvImageFile is parameter of the image tarball in data directory. When pulling, image is
+	// saved to it, and when pushing, image is loaded from it.
+	EnvImageFile = "IMAGE_FILE"
+	// EnvSourceImage is parameter of the image to copy from when pushing, it's used when image
+	// tarball is not given or not found.
+	EnvSourceImage = "SOURCE_IMAGE"
+	// EnvPlatform is parameter of the platform to pull from multi-platform images.
+	EnvPlatform = "IMAGE_PLATFORM"
+	// EnvInsecure is parameter to access registries over plain HTTP, 'true' to enable.
+	EnvInsecure = "REGISTRY_INSECURE"
+	// EnvDockerConfig is directory of docker config.json, as what docker uses.
+	EnvDockerConfig = "DOCKER_CONFIG"
+
+	// OutputDigest is key of the image digest in outputs.
+	OutputDigest = "digest"
+
+	// defaultImageFile is the image tarball saved when pulling if IMAGE_FILE not set.
+	defaultImageFile = "image.tar"
+)
+
+const usage = `This tool is used to resolve image resources, here image resource
+stands for an image in docker registry. It talks to registries directly,
+no docker daemon is needed.
+
+Usage:
+    $ docker run -it --rm \
+        -e IMAGE=docker.io/library/alpine:3.6 \
+        -e IMAGE_FILE=image.tar.gz \
+        -v /config.json:/root/.docker/config.json \
+        image-resource-resolver:latest <COMMAND>
+
+Supported commands are:
+- help Print out this help messages.
+- pull Pull image from registry and save it to IMAGE_FILE (default image.tar)
+  in 'docker save' format.
+- push Push image to registry, image is loaded from IMAGE_FILE, which can be
+  created by 'docker save' or be an OCI image layout tarball. If IMAGE_FILE
+  is not found, image is copied from SOURCE_IMAGE.
+
+Environment variables IMAGE must be set, and it should be a full name
+in format <domain>/<project>/<repo>:<tag>. Other optional variables:
+- IMAGE_PLATFORM Platform to pull from multi-platform images, default linux/amd64.
+- REGISTRY_INSECURE Set to 'true' to access registries over plain HTTP.
+
+Digest of the image is written as 'digest' output. Registry credentials
+are read from config.json in DOCKER_CONFIG, default /root/.docker.`
+
+// Resolver resolves image resources.
+type Resolver struct{}
+
+// Ensure *Resolver has implemented resolver.Resolver interface.
+var _ resolver.Resolver = (*Resolver)(nil)
+
+// Describe ...
+func (r *Resolver) Describe() string {
+	return usage
+}
+
+// Pull pulls the image from registry and saves it to the image tarball in data directory.
+func (r *Resolver) Pull(ws *resolver.Workspace) error {
+	if err := ws.RequireParams(EnvImage); err != nil {
+		return err
+	}
+	ref, err := registry.ParseReference(ws.Param(EnvImage))
+	if err != nil {
+		return err
+	}
+	client, err := newClient(ws)
+	if err != nil {
+		return err
+	}
+
+	var digest string
+	err = ws.PullOnce(func() error {
+		if err := os.MkdirAll(ws.DataDir(), 0755); err != nil {
+			return err
+		}
+		file := filepath.Join(ws.DataDir(), ws.ParamOrDefault(EnvImageFile, defaultImageFile))
+		log.WithField("image", ref.String()).Info("Save image to ", file)
+		digest, err = client.SaveTarball(ref, ws.ParamOrDefault(EnvPlatform, registry.DefaultPlatform), file)
+		if err != nil {
+			os.RemoveAll(ws.DataDir())
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// Image was pulled by other stages, resolve the digest.
+	if digest == "" {
+		if digest, err = client.Resolve(ref); err != nil {
+			return err
+		}
+	}
+	return ws.WriteOutputs(map[string]string{OutputDigest: digest})
+}
+
+// Push pushes the image to registry after workload finished, image is loaded from the image
+// tarball if exists, otherwise copied from the source image.
+func (r *Resolver) Push(ws *resolver.Workspace) error {
+	if err := ws.RequireParams(EnvImage); err != nil {
+		return err
+	}
+	ref, err := registry.ParseReference(ws.Param(EnvImage))
+	if err != nil {
+		return err
+	}
+	client, err := newClient(ws)
+	if err != nil {
+		return err
+	}
+
+	ws.WaitNotify()
+	var digest string
+	if file := imageFile(ws); file != "" {
+		log.WithField("image", ref.String()).Info("Push image from file ", ws.Param(EnvImageFile))
+		if digest, err = client.PushTarball(ref, file); err != nil {
+			return err
+		}
+	} else if source := ws.Param(EnvSourceImage); source != "" {
+		src, err := registry.ParseReference(source)
+		if err != nil {
+			return err
+		}
+		log.WithField("image", ref.String()).Info("Copy image from ", src.String())
+		if digest, err = client.Copy(src, ref); err != nil {
+			return err
+		}
+	} else {
+		return fmt.Errorf("image file '%s' not found and %s not set", ws.Param(EnvImageFile), EnvSourceImage)
+	}
+
+	log.WithField("image", ref.String()).Info("Image pushed, digest: ", digest)
+	return ws.WriteOutputs(map[string]string{OutputDigest: digest})
+}
+
+// imageFile gets path of the image tarball to push, empty string is returned if not found.
+func imageFile(ws *resolver.Workspace) string {
+	if ws.Param(EnvImageFile) == "" {
+		return ""
+	}
+	file := filepath.Join(ws.DataDir(), ws.Param(EnvImageFile))
+	if _, err := os.Stat(file); err != nil {
+		return ""
+	}
+	return file
+}
+
+// newClient creates registry client with credentials in docker config.json.
+func newClient(ws *resolver.Workspace) (*registry.Client, error) {
+	dir := ws.ParamOrDefault(EnvDockerConfig, common.DockerConfigPath)
+	credentials, err := registry.LoadDockerConfig(filepath.Join(dir, common.DockerConfigJSONFile))
+	if err != nil {
+		return nil, err
+	}
+
+	client := registry.NewClient(credentials)
+	client.Insecure = ws.Param(EnvInsecure) == "true"
+	return client, nil
+}
@@ -0,0 +1,40 @@
+package image
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/caicloud/cyclone/pkg/workflow/common"
+	"github.com/caicloud/cyclone/pkg/workflow/resolver"
+)
+
+func TestPushWithoutImage(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "workspace")
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, common.ResolverNotifyDir), 0755)
+	ioutil.WriteFile(filepath.Join(dir, common.ResolverNotifyDir, common.ResolverNotifyOkFile), nil, 0644)
+
+	ws := &resolver.Workspace{
+		Dir: dir,
+		Params: map[string]string{
+			EnvImage:        "cargo.caicloud.io/release/app:v1",
+			EnvImageFile:    "image.tar",
+			EnvDockerConfig: dir,
+		},
+	}
+	assert.Equal(t, "", imageFile(ws))
+	assert.NotNil(t, (&Resolver{}).Push(ws))
+
+	os.MkdirAll(ws.DataDir(), 0755)
+	ioutil.WriteFile(filepath.Join(ws.DataDir(), "image.tar"), nil, 0644)
+	assert.Equal(t, filepath.Join(ws.DataDir(), "image.tar"), imageFile(ws))
+
+	// Invalid docker config.
+	ioutil.WriteFile(filepath.Join(dir, common.DockerConfigJSONFile), []byte("{"), 0644)
+	_, err := newClient(ws)
+	assert.NotNil(t, err)
+}
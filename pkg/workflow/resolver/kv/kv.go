@@ -0,0 +1,152 @@
+package kv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/workflow/resolver"
+)
+
+const (
+	// EnvPath is parameter of the key-value file path.
+	EnvPath = "KV_PATH"
+	// EnvServerURL is parameter of the Cyclone Server url.
+	EnvServerURL = "CYCLONE_SERVER_URL"
+	// EnvWorkflowRun is parameter of the WorkflowRun to put values to.
+	EnvWorkflowRun = "WORKFLOWRUN"
+	// EnvStage is parameter of the stage to put values to.
+	EnvStage = "STAGE"
+
+	// DefaultPath is the default key-value file path.
+	DefaultPath = "/workspace/data/kv.txt"
+	// DefaultServerURL is the default Cyclone Server url.
+	DefaultServerURL = "http://cyclone-server"
+)
+
+const usage = `This tool is used to resolve key-value resources, here kv resource
+stands for a set of key-values.
+
+Usage:
+    $ docker run -it --rm \
+        -e KV_PATH=/workspace/data/kv.txt \
+        -e CYCLONE_SERVER_URL=cyclone-server \
+        -e WORKFLOWRUN=workflowrun \
+        -e STAGE=stage1 \
+        kv-resource-resolver:latest <COMMAND>
+
+Supported commands are:
+- help Print out this help messages.
+- pull Pull key-values from WorkflowRun status field. (Not implemented yet)
+- push Push key-values to WorkflowRun status field.
+
+Command pull of this tool is not used, it's kept here just to keep consistent
+of other resolvers. pull action for this resource is handled by Cyclone Controller.
+When Cyclone Controller starts a new stage, it would retrieve key-values from
+stages it depends and pass them to the new stage with environment variables.
+
+Environment variables KV_PATH, CYCLONE_SERVER_URL, WORKFLOWRUN, STAGE are used in
+push command. KV_PATH is the path to the key-value file, it's /workspace/data/kv.txt
+by default. CYCLONE_SERVER_URL gives the url of the Cyclone Server, this tool would
+send all data to Cyclone Server, who would update related WorkflowRun status. And
+WORKFLOWRUN specify which WorkflowRun instance to put values to.
+
+The key-value file have line format:
+    <key>: <value>`
+
+// Resolver resolves key-value resources.
+type Resolver struct{}
+
+// Ensure *Resolver has implemented resolver.Resolver interface.
+var _ resolver.Resolver = (*Resolver)(nil)
+
+// Describe ...
+func (r *Resolver) Describe() string {
+	return usage
+}
+
+// Pull is not used, key-values are passed to stages by Workflow Controller.
+func (r *Resolver) Pull(ws *resolver.Workspace) error {
+	log.Info("Command pull is not used.")
+	return nil
+}
+
+// Push sends key-values in the file to Cyclone Server after workload finished.
+func (r *Resolver) Push(ws *resolver.Workspace) error {
+	if err := ws.RequireParams(EnvWorkflowRun, EnvStage); err != nil {
+		return err
+	}
+
+	ws.WaitNotify()
+	f, err := os.Open(ws.ParamOrDefault(EnvPath, DefaultPath))
+	if err != nil {
+		return fmt.Errorf("open key-value file error: %v", err)
+	}
+	defer f.Close()
+
+	items, err := Parse(f)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/workflowruns/%s/stages/%s/kv", ws.ParamOrDefault(EnvServerURL, DefaultServerURL), ws.Param(EnvWorkflowRun), ws.Param(EnvStage))
+	return Send(url, items)
+}
+
+// Parse parses key-values from the reader, each line is in format '<key>: <value>', and empty
+// lines are skipped.
+func Parse(r io.Reader) ([]v1alpha1.KeyValue, error) {
+	var items []v1alpha1.KeyValue
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid key-value line: %s", line)
+		}
+		items = append(items, v1alpha1.KeyValue{
+			Key:   strings.TrimSpace(parts[0]),
+			Value: strings.TrimSpace(parts[1]),
+		})
+	}
+
+	return items, scanner.Err()
+}
+
+// Send puts the key-values to Cyclone Server.
+func Send(url string, items []v1alpha1.KeyValue) error {
+	data, err := json.Marshal(map[string][]v1alpha1.KeyValue{"items": items})
+	if err != nil {
+		return err
+	}
+
+	log.Infof("[PUT] %s", url)
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("put key-values error: %s, %s", resp.Status, body)
+	}
+	return nil
+}
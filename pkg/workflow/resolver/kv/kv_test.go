@@ -0,0 +1,43 @@
+package kv
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+)
+
+func TestParse(t *testing.T) {
+	items, err := Parse(strings.NewReader("version: v1.0\n\n url : http://a.b/c \n"))
+	assert.Nil(t, err)
+	assert.Equal(t, []v1alpha1.KeyValue{
+		{Key: "version", Value: "v1.0"},
+		{Key: "url", Value: "http://a.b/c"},
+	}, items)
+
+	_, err = Parse(strings.NewReader("invalid"))
+	assert.NotNil(t, err)
+}
+
+func TestSend(t *testing.T) {
+	var received map[string][]v1alpha1.KeyValue
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/workflowruns/wfr/stages/stg/kv" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	items := []v1alpha1.KeyValue{{Key: "k", Value: "v"}}
+	assert.Nil(t, Send(server.URL+"/workflowruns/wfr/stages/stg/kv", items))
+	assert.Equal(t, items, received["items"])
+
+	assert.NotNil(t, Send(server.URL+"/notfound", items))
+}
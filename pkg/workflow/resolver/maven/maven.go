@@ -0,0 +1,334 @@
+package maven
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/caicloud/cyclone/pkg/workflow/resolver"
+)
+
+const (
+	// EnvRepo is parameter of the Maven repository URL, e.g. https://repo.maven.apache.org/maven2.
+	EnvRepo = "MAVEN_REPO"
+	// EnvGroupID is parameter of the artifact group id.
+	EnvGroupID = "MAVEN_GROUP_ID"
+	// EnvArtifactID is parameter of the artifact id.
+	EnvArtifactID = "MAVEN_ARTIFACT_ID"
+	// EnvVersion is parameter of the artifact version. It can be an exact version, a SNAPSHOT
+	// version, or 'LATEST' and 'RELEASE'. Latest release is pulled if not set.
+	EnvVersion = "MAVEN_VERSION"
+	// EnvPackaging is parameter of the artifact packaging, default to jar.
+	EnvPackaging = "MAVEN_PACKAGING"
+	// EnvClassifier is parameter of the artifact classifier, e.g. sources.
+	EnvClassifier = "MAVEN_CLASSIFIER"
+	// EnvFile is parameter of the artifact file in data directory, default to
+	// '<artifactId>-<version>[-<classifier>].<packaging>'.
+	EnvFile = "MAVEN_FILE"
+
+	// OutputVersion is key of the resolved artifact version in outputs, for SNAPSHOT versions,
+	// it's the timestamped version, e.g. 1.0-20181010.123456-1.
+	OutputVersion = "version"
+	// OutputDigest is key of the artifact digest in outputs.
+	OutputDigest = "digest"
+)
+
+const usage = `This tool is used to resolve Maven resources, here Maven resource
+stands for an artifact in Maven repository.
+
+Usage:
+    $ docker run -it --rm \
+        -e MAVEN_REPO=https://repo.maven.apache.org/maven2 \
+        -e MAVEN_GROUP_ID=org.example \
+        -e MAVEN_ARTIFACT_ID=app \
+        -e MAVEN_VERSION=1.0.0 \
+        maven-resource-resolver:latest <COMMAND>
+
+Supported commands are:
+- help Print out this help messages.
+- pull Download the artifact to data directory.
+- push Deploy the artifact in data directory to the repository.
+
+Environment variables MAVEN_REPO, MAVEN_GROUP_ID and MAVEN_ARTIFACT_ID must be
+set. Other optional variables:
+- MAVEN_VERSION Exact version, SNAPSHOT version, LATEST or RELEASE. Latest
+  release is pulled if not set, it must be an exact version for pushing.
+- MAVEN_PACKAGING Packaging of the artifact, default jar.
+- MAVEN_CLASSIFIER Classifier of the artifact, e.g. sources.
+- MAVEN_FILE Artifact file in data directory.
+- AUTH_USERNAME, AUTH_PASSWORD Credentials sent in basic auth.
+- AUTH_TOKEN Token sent as bearer token.
+
+Resolved version and sha256 digest of the artifact are written as 'version'
+and 'digest' outputs.`
+
+// timestampedSnapshot matches timestamped snapshot versions, e.g. 1.0-20181010.123456-1.
+var timestampedSnapshot = regexp.MustCompile(`^(.*)-\d{8}\.\d{6}-\d+$`)
+
+// Resolver resolves Maven resources.
+type Resolver struct{}
+
+// Ensure *Resolver has implemented resolver.Resolver interface.
+var _ resolver.Resolver = (*Resolver)(nil)
+
+// Describe ...
+func (r *Resolver) Describe() string {
+	return usage
+}
+
+// metadata is maven-metadata.xml of an artifact or a SNAPSHOT version.
+type metadata struct {
+	Versioning struct {
+		Latest           string   `xml:"latest"`
+		Release          string   `xml:"release"`
+		Versions         []string `xml:"versions>version"`
+		SnapshotVersions []struct {
+			Classifier string `xml:"classifier"`
+			Extension  string `xml:"extension"`
+			Value      string `xml:"value"`
+		} `xml:"snapshotVersions>snapshotVersion"`
+	} `xml:"versioning"`
+}
+
+// artifact identifies an artifact file in repository.
+type artifact struct {
+	repo       string
+	groupID    string
+	artifactID string
+	packaging  string
+	classifier string
+}
+
+func newArtifact(ws *resolver.Workspace) (*artifact, error) {
+	if err := ws.RequireParams(EnvRepo, EnvGroupID, EnvArtifactID); err != nil {
+		return nil, err
+	}
+	return &artifact{
+		repo:       strings.TrimSuffix(ws.Param(EnvRepo), "/"),
+		groupID:    ws.Param(EnvGroupID),
+		artifactID: ws.Param(EnvArtifactID),
+		packaging:  ws.ParamOrDefault(EnvPackaging, "jar"),
+		classifier: ws.Param(EnvClassifier),
+	}, nil
+}
+
+// baseURL gets URL of the artifact directory.
+func (a *artifact) baseURL() string {
+	return fmt.Sprintf("%s/%s/%s", a.repo, strings.Replace(a.groupID, ".", "/", -1), a.artifactID)
+}
+
+// fileName gets name of the artifact file with the version.
+func (a *artifact) fileName(version string) string {
+	name := a.artifactID + "-" + version
+	if a.classifier != "" {
+		name += "-" + a.classifier
+	}
+	return name + "." + a.packaging
+}
+
+// fileURL gets URL of the artifact file, timestamped snapshot versions are located in the
+// SNAPSHOT version directory.
+func (a *artifact) fileURL(version string) string {
+	dir := version
+	if m := timestampedSnapshot.FindStringSubmatch(version); m != nil {
+		dir = m[1] + "-SNAPSHOT"
+	}
+	return fmt.Sprintf("%s/%s/%s", a.baseURL(), dir, a.fileName(version))
+}
+
+// Pull downloads the artifact to data directory.
+func (r *Resolver) Pull(ws *resolver.Workspace) error {
+	a, err := newArtifact(ws)
+	if err != nil {
+		return err
+	}
+
+	return ws.PullWithOutputs(func() (map[string]string, error) {
+		o, err := pull(ws, a)
+		if err != nil {
+			os.RemoveAll(ws.DataDir())
+			return nil, err
+		}
+		return o, nil
+	})
+}
+
+func pull(ws *resolver.Workspace, a *artifact) (map[string]string, error) {
+	version, err := resolveVersion(ws, a, ws.Param(EnvVersion))
+	if err != nil {
+		return nil, err
+	}
+
+	fileURL := a.fileURL(version)
+	file := filepath.Join(ws.DataDir(), filepath.Clean("/"+ws.ParamOrDefault(EnvFile, a.fileName(version))))
+	log.WithField("artifact", a.groupID+":"+a.artifactID).WithField("version", version).Info("Download artifact")
+	digest, _, err := ws.Download(fileURL, file)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySHA1(ws, fileURL+".sha1", file); err != nil {
+		return nil, err
+	}
+
+	return map[string]string{
+		OutputVersion: version,
+		OutputDigest:  digest,
+	}, nil
+}
+
+// resolveVersion resolves LATEST, RELEASE and SNAPSHOT versions with maven-metadata.xml.
+func resolveVersion(ws *resolver.Workspace, a *artifact, version string) (string, error) {
+	switch {
+	case version == "" || version == "RELEASE" || version == "LATEST":
+		m, err := getMetadata(ws, a.baseURL()+"/maven-metadata.xml")
+		if err != nil {
+			return "", err
+		}
+		v := m.Versioning.Release
+		if version == "LATEST" && m.Versioning.Latest != "" {
+			v = m.Versioning.Latest
+		}
+		if v == "" && len(m.Versioning.Versions) > 0 {
+			v = m.Versioning.Versions[len(m.Versioning.Versions)-1]
+		}
+		if v == "" {
+			return "", fmt.Errorf("no version found in maven-metadata.xml")
+		}
+		if strings.HasSuffix(v, "-SNAPSHOT") {
+			return resolveVersion(ws, a, v)
+		}
+		return v, nil
+	case strings.HasSuffix(version, "-SNAPSHOT"):
+		m, err := getMetadata(ws, fmt.Sprintf("%s/%s/maven-metadata.xml", a.baseURL(), version))
+		if err != nil {
+			return "", err
+		}
+		for _, s := range m.Versioning.SnapshotVersions {
+			if s.Extension == a.packaging && s.Classifier == a.classifier {
+				return s.Value, nil
+			}
+		}
+		// Non-unique snapshots are stored without timestamp.
+		return version, nil
+	default:
+		return version, nil
+	}
+}
+
+func getMetadata(ws *resolver.Workspace, url string) (*metadata, error) {
+	resp, err := ws.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	m := &metadata{}
+	if err := xml.NewDecoder(resp.Body).Decode(m); err != nil {
+		return nil, fmt.Errorf("parse maven-metadata.xml error: %v", err)
+	}
+	return m, nil
+}
+
+// verifySHA1 verifies the file against the sha1 checksum file in repository, it's skipped if
+// the checksum file is not available.
+func verifySHA1(ws *resolver.Workspace, url, file string) error {
+	resp, err := ws.Get(url)
+	if err != nil {
+		log.Warn("Skip verifying sha1 checksum: ", err)
+		return nil
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return nil
+	}
+
+	actual, err := checksum(sha1.New(), file)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(fields[0], actual) {
+		return fmt.Errorf("sha1 checksum mismatch, expected %s, got %s", fields[0], actual)
+	}
+	return nil
+}
+
+func checksum(h hash.Hash, file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Push deploys the artifact in data directory with sha1 and md5 checksums after workload
+// finished. Repository managers like Nexus and Artifactory update maven-metadata.xml on deploy.
+func (r *Resolver) Push(ws *resolver.Workspace) error {
+	a, err := newArtifact(ws)
+	if err != nil {
+		return err
+	}
+	version := ws.Param(EnvVersion)
+	if version == "" || version == "RELEASE" || version == "LATEST" {
+		return fmt.Errorf("%s must be an exact version for pushing", EnvVersion)
+	}
+
+	ws.WaitNotify()
+	file := filepath.Join(ws.DataDir(), filepath.Clean("/"+ws.ParamOrDefault(EnvFile, a.fileName(version))))
+	digest, err := resolver.FileDigest(file)
+	if err != nil {
+		return err
+	}
+
+	fileURL := a.fileURL(version)
+	log.WithField("artifact", a.groupID+":"+a.artifactID).WithField("version", version).Info("Deploy artifact")
+	if _, err := ws.Upload("PUT", fileURL, file, nil); err != nil {
+		return err
+	}
+	for ext, h := range map[string]hash.Hash{".sha1": sha1.New(), ".md5": md5.New()} {
+		sum, err := checksum(h, file)
+		if err != nil {
+			return err
+		}
+		if err := putChecksum(ws, fileURL+ext, sum); err != nil {
+			return err
+		}
+	}
+
+	return ws.WriteOutputs(map[string]string{
+		OutputVersion: version,
+		OutputDigest:  digest,
+	})
+}
+
+func putChecksum(ws *resolver.Workspace, url, sum string) error {
+	req, err := ws.NewRequest("PUT", url, strings.NewReader(sum))
+	if err != nil {
+		return err
+	}
+	resp, err := ws.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
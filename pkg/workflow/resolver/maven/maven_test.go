@@ -0,0 +1,149 @@
+package maven
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/caicloud/cyclone/pkg/workflow/common"
+	"github.com/caicloud/cyclone/pkg/workflow/resolver"
+)
+
+const helloDigest = "sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+const (
+	artifactMetadata = `<metadata>
+  <groupId>org.example</groupId>
+  <artifactId>app</artifactId>
+  <versioning>
+    <latest>1.1-SNAPSHOT</latest>
+    <release>1.0</release>
+    <versions><version>1.0</version><version>1.1-SNAPSHOT</version></versions>
+  </versioning>
+</metadata>`
+	snapshotMetadata = `<metadata>
+  <versioning>
+    <snapshotVersions>
+      <snapshotVersion><extension>pom</extension><value>1.1-20181010.123456-2</value></snapshotVersion>
+      <snapshotVersion><extension>jar</extension><value>1.1-20181010.123456-2</value></snapshotVersion>
+    </snapshotVersions>
+  </versioning>
+</metadata>`
+)
+
+func newWorkspace(params map[string]string) *resolver.Workspace {
+	dir, _ := ioutil.TempDir("", "workspace")
+	os.MkdirAll(filepath.Join(dir, common.ResolverNotifyDir), 0755)
+	ioutil.WriteFile(filepath.Join(dir, common.ResolverNotifyDir, common.ResolverNotifyOkFile), nil, 0644)
+	return &resolver.Workspace{
+		Dir:         dir,
+		WorkflowRun: "wfr",
+		Params:      params,
+		OutputFile:  filepath.Join(dir, "termination-log"),
+	}
+}
+
+func readOutputs(t *testing.T, ws *resolver.Workspace) map[string]string {
+	data, err := ioutil.ReadFile(ws.OutputFile)
+	assert.Nil(t, err)
+	outputs := make(map[string]string)
+	assert.Nil(t, json.Unmarshal(data, &outputs))
+	return outputs
+}
+
+func newRepository(files map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			data, _ := ioutil.ReadAll(r.Body)
+			files[r.URL.Path] = string(data)
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		content, ok := files[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(content))
+	}))
+}
+
+func TestPull(t *testing.T) {
+	server := newRepository(map[string]string{
+		"/maven2/org/example/app/maven-metadata.xml":                         artifactMetadata,
+		"/maven2/org/example/app/1.0/app-1.0.jar":                            "hello",
+		"/maven2/org/example/app/1.0/app-1.0.jar.sha1":                       "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d  app-1.0.jar",
+		"/maven2/org/example/app/1.1-SNAPSHOT/maven-metadata.xml":            snapshotMetadata,
+		"/maven2/org/example/app/1.1-SNAPSHOT/app-1.1-20181010.123456-2.jar": "hello",
+		"/maven2/org/example/app/0.9/app-0.9.jar":                            "hello",
+		"/maven2/org/example/app/0.9/app-0.9.jar.sha1":                       "0000",
+	})
+	defer server.Close()
+
+	cases := []struct {
+		version  string
+		expected string
+	}{
+		{"", "1.0"},
+		{"RELEASE", "1.0"},
+		{"LATEST", "1.1-20181010.123456-2"},
+		{"1.1-SNAPSHOT", "1.1-20181010.123456-2"},
+		{"1.1-20181010.123456-2", "1.1-20181010.123456-2"},
+		// Checksum mismatch
+		{"0.9", ""},
+		// Not found
+		{"2.0", ""},
+	}
+	for _, c := range cases {
+		ws := newWorkspace(map[string]string{
+			EnvRepo:       server.URL + "/maven2/",
+			EnvGroupID:    "org.example",
+			EnvArtifactID: "app",
+			EnvVersion:    c.version,
+		})
+		err := (&Resolver{}).Pull(ws)
+		if c.expected == "" {
+			assert.NotNil(t, err, c.version)
+		} else {
+			assert.Nil(t, err, c.version)
+			_, err = os.Stat(filepath.Join(ws.DataDir(), "app-"+c.expected+".jar"))
+			assert.Nil(t, err, c.version)
+			assert.Equal(t, map[string]string{OutputVersion: c.expected, OutputDigest: helloDigest}, readOutputs(t, ws), c.version)
+		}
+		os.RemoveAll(ws.Dir)
+	}
+}
+
+func TestPush(t *testing.T) {
+	files := make(map[string]string)
+	server := newRepository(files)
+	defer server.Close()
+
+	ws := newWorkspace(map[string]string{
+		EnvRepo:       server.URL,
+		EnvGroupID:    "org.example",
+		EnvArtifactID: "app",
+		EnvVersion:    "RELEASE",
+		EnvClassifier: "sources",
+		EnvFile:       "target/app.jar",
+	})
+	defer os.RemoveAll(ws.Dir)
+	assert.NotNil(t, (&Resolver{}).Push(ws))
+
+	ws.Params[EnvVersion] = "1.0"
+	os.MkdirAll(filepath.Join(ws.DataDir(), "target"), 0755)
+	ioutil.WriteFile(filepath.Join(ws.DataDir(), "target", "app.jar"), []byte("hello"), 0644)
+	assert.Nil(t, (&Resolver{}).Push(ws))
+	assert.Equal(t, map[string]string{
+		"/org/example/app/1.0/app-1.0-sources.jar":      "hello",
+		"/org/example/app/1.0/app-1.0-sources.jar.sha1": "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d",
+		"/org/example/app/1.0/app-1.0-sources.jar.md5":  "5d41402abc4b2a76b9719d911017c592",
+	}, files)
+	assert.Equal(t, map[string]string{OutputVersion: "1.0", OutputDigest: helloDigest}, readOutputs(t, ws))
+}
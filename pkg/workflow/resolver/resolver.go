@@ -0,0 +1,64 @@
+// Package resolver is the SDK to build resource resolvers. A resource resolver pulls resource
+// data to the workspace before workload containers run, and pushes resource data out after
+// workload containers finished. Resolver images specified in ResourceSpec.Resolver can be built
+// on this package by implementing the Resolver interface:
+//
+//	func main() {
+//	    resolver.Main(&MyResolver{})
+//	}
+//
+// The resolver binary accepts one command argument, 'pull', 'push' or 'help'.
+package resolver
+
+import (
+	"fmt"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/caicloud/cyclone/pkg/workflow/common"
+)
+
+// Resolver resolves a kind of resource.
+type Resolver interface {
+	// Describe gives usage of the resolver, for example, parameters it accepts.
+	Describe() string
+	// Pull pulls resource data to data directory of the workspace. It's run as init container
+	// of the stage pod, and data is shared between stages in the same WorkflowRun.
+	Pull(ws *Workspace) error
+	// Push pushes resource data in data directory of the workspace. It's run as sidecar of the
+	// stage pod, and should wait for the data to be ready with Workspace.WaitNotify.
+	Push(ws *Workspace) error
+}
+
+// Run runs the resolver with the command, workspace is created from environment variables.
+func Run(r Resolver, command string) error {
+	ws := NewWorkspace()
+
+	switch command {
+	case common.ResourcePullCommand:
+		return r.Pull(ws)
+	case common.ResourcePushCommand:
+		return r.Push(ws)
+	case "help":
+		fmt.Println(r.Describe())
+		return nil
+	default:
+		fmt.Println(r.Describe())
+		return fmt.Errorf("unsupported command '%s'", command)
+	}
+}
+
+// Main is entry of resolver binaries, it runs the resolver with command given in the first
+// argument and exits with non-zero code on error.
+func Main(r Resolver) {
+	command := "help"
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+
+	if err := Run(r, command); err != nil {
+		log.WithField("command", command).Error("Resolve resource error: ", err)
+		os.Exit(1)
+	}
+}
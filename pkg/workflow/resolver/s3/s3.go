@@ -0,0 +1,193 @@
+package s3
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/caicloud/cyclone/pkg/util/s3"
+	"github.com/caicloud/cyclone/pkg/workflow/resolver"
+)
+
+const (
+	// EnvEndpoint is parameter of the object storage endpoint, e.g. s3.amazonaws.com.
+	EnvEndpoint = "S3_ENDPOINT"
+	// EnvRegion is parameter of the bucket region, default to us-east-1.
+	EnvRegion = "S3_REGION"
+	// EnvAccessKey is parameter of the access key.
+	EnvAccessKey = "S3_ACCESS_KEY"
+	// EnvSecretKey is parameter of the secret key.
+	EnvSecretKey = "S3_SECRET_KEY"
+	// EnvInsecure is parameter to access the object storage over plain HTTP, 'true' to enable.
+	EnvInsecure = "S3_INSECURE"
+	// EnvBucket is parameter of the bucket.
+	EnvBucket = "S3_BUCKET"
+	// EnvKey is parameter of the object key.
+	EnvKey = "S3_KEY"
+	// EnvVersionID is parameter of the object version to pull, latest version is pulled if not set.
+	EnvVersionID = "S3_VERSION_ID"
+	// EnvFile is parameter of the file name in data directory, default to the last element of
+	// the object key.
+	EnvFile = "S3_FILE"
+	// EnvExtract is parameter to extract the object when pulling, 'true' to enable. Tar, gzip
+	// compressed tar and zip archives are supported.
+	EnvExtract = "S3_EXTRACT"
+
+	// OutputDigest is key of the object digest in outputs.
+	OutputDigest = "digest"
+	// OutputETag is key of the object ETag in outputs.
+	OutputETag = "etag"
+	// OutputVersionID is key of the object version in outputs, it's set only when versioning is
+	// enabled for the bucket.
+	OutputVersionID = "versionId"
+)
+
+const usage = `This tool is used to resolve S3 resources, here S3 resource
+stands for an object in S3 compatible object storage.
+
+Usage:
+    $ docker run -it --rm \
+        -e S3_ENDPOINT=s3.amazonaws.com \
+        -e S3_BUCKET=releases \
+        -e S3_KEY=app/app.tar.gz \
+        -e S3_ACCESS_KEY=<access key> \
+        -e S3_SECRET_KEY=<secret key> \
+        s3-resource-resolver:latest <COMMAND>
+
+Supported commands are:
+- help Print out this help messages.
+- pull Download the object to data directory.
+- push Upload the file in data directory as the object.
+
+Environment variables S3_ENDPOINT, S3_BUCKET, S3_KEY must be set. Other
+optional variables:
+- S3_REGION Region of the bucket, default us-east-1.
+- S3_ACCESS_KEY, S3_SECRET_KEY Credentials of the object storage.
+- S3_INSECURE Set to 'true' to access object storage over plain HTTP.
+- S3_VERSION_ID Version of the object to pull.
+- S3_FILE File name in data directory, default to last element of the key.
+- S3_EXTRACT Set to 'true' to extract tar, tar.gz or zip archive when pulling.
+
+Sha256 digest, ETag and version id of the object are written as 'digest',
+'etag' and 'versionId' outputs.`
+
+// Resolver resolves S3 resources.
+type Resolver struct{}
+
+// Ensure *Resolver has implemented resolver.Resolver interface.
+var _ resolver.Resolver = (*Resolver)(nil)
+
+// Describe ...
+func (r *Resolver) Describe() string {
+	return usage
+}
+
+// Pull downloads the object to data directory.
+func (r *Resolver) Pull(ws *resolver.Workspace) error {
+	if err := ws.RequireParams(EnvEndpoint, EnvBucket, EnvKey); err != nil {
+		return err
+	}
+	client := newClient(ws)
+	file := filePath(ws)
+
+	return ws.PullWithOutputs(func() (map[string]string, error) {
+		o, err := download(ws, client, file)
+		if err != nil {
+			os.RemoveAll(ws.DataDir())
+			return nil, err
+		}
+
+		if ws.Param(EnvExtract) == "true" {
+			if err := resolver.Extract(file, ws.DataDir()); err != nil {
+				os.RemoveAll(ws.DataDir())
+				return nil, fmt.Errorf("extract %s error: %v", filepath.Base(file), err)
+			}
+		}
+		return o, nil
+	})
+}
+
+func download(ws *resolver.Workspace, client *s3.Client, file string) (map[string]string, error) {
+	query := url.Values{}
+	if v := ws.Param(EnvVersionID); v != "" {
+		query.Set("versionId", v)
+	}
+	log.WithField("bucket", ws.Param(EnvBucket)).WithField("key", ws.Param(EnvKey)).Info("Download object")
+	resp, err := client.GetObject(ws.Param(EnvBucket), ws.Param(EnvKey), query)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(f, resp.Body)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	digest, err := resolver.FileDigest(file)
+	if err != nil {
+		return nil, err
+	}
+	return outputs(digest, resp.Header.Get("ETag"), resp.Header.Get("X-Amz-Version-Id")), nil
+}
+
+// Push uploads the file in data directory as the object after workload finished.
+func (r *Resolver) Push(ws *resolver.Workspace) error {
+	if err := ws.RequireParams(EnvEndpoint, EnvBucket, EnvKey); err != nil {
+		return err
+	}
+	client := newClient(ws)
+	file := filePath(ws)
+
+	ws.WaitNotify()
+	log.WithField("bucket", ws.Param(EnvBucket)).WithField("key", ws.Param(EnvKey)).Info("Upload object")
+	header, sum, err := client.PutFile(ws.Param(EnvBucket), ws.Param(EnvKey), file, nil)
+	if err != nil {
+		return err
+	}
+
+	return ws.WriteOutputs(outputs("sha256:"+sum, header.Get("ETag"), header.Get("X-Amz-Version-Id")))
+}
+
+func newClient(ws *resolver.Workspace) *s3.Client {
+	return &s3.Client{
+		Endpoint:  ws.Param(EnvEndpoint),
+		Region:    ws.Param(EnvRegion),
+		AccessKey: ws.Param(EnvAccessKey),
+		SecretKey: ws.Param(EnvSecretKey),
+		Insecure:  ws.Param(EnvInsecure) == "true",
+	}
+}
+
+// filePath gets path of the file in data directory.
+func filePath(ws *resolver.Workspace) string {
+	name := ws.ParamOrDefault(EnvFile, path.Base(ws.Param(EnvKey)))
+	return filepath.Join(ws.DataDir(), filepath.Clean("/"+name))
+}
+
+func outputs(digest, etag, versionID string) map[string]string {
+	o := map[string]string{
+		OutputDigest: digest,
+	}
+	if etag != "" {
+		o[OutputETag] = strings.Trim(etag, `"`)
+	}
+	if versionID != "" && versionID != "null" {
+		o[OutputVersionID] = versionID
+	}
+	return o
+}
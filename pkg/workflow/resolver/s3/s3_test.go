@@ -0,0 +1,111 @@
+package s3
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/caicloud/cyclone/pkg/workflow/common"
+	"github.com/caicloud/cyclone/pkg/workflow/resolver"
+)
+
+const helloDigest = "sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+// fakeStorage is a fake object storage that keeps objects in memory.
+type fakeStorage struct {
+	objects map[string]string
+}
+
+func (s *fakeStorage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=ak/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := ioutil.ReadAll(r.Body)
+		s.objects[r.URL.Path] = string(data)
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("X-Amz-Version-Id", "v2")
+	case http.MethodGet:
+		data, ok := s.objects[r.URL.Path]
+		if !ok || r.URL.Query().Get("versionId") != "v1" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("<Error><Code>NoSuchKey</Code></Error>"))
+			return
+		}
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("X-Amz-Version-Id", "v1")
+		w.Write([]byte(data))
+	}
+}
+
+func newWorkspace(endpoint string) *resolver.Workspace {
+	dir, _ := ioutil.TempDir("", "workspace")
+	os.MkdirAll(filepath.Join(dir, common.ResolverNotifyDir), 0755)
+	ioutil.WriteFile(filepath.Join(dir, common.ResolverNotifyDir, common.ResolverNotifyOkFile), nil, 0644)
+	return &resolver.Workspace{
+		Dir:         dir,
+		WorkflowRun: "wfr",
+		Params: map[string]string{
+			EnvEndpoint:  endpoint,
+			EnvInsecure:  "true",
+			EnvAccessKey: "ak",
+			EnvSecretKey: "sk",
+			EnvBucket:    "releases",
+			EnvKey:       "app/hello.txt",
+		},
+		OutputFile: filepath.Join(dir, "termination-log"),
+	}
+}
+
+func readOutputs(t *testing.T, ws *resolver.Workspace) map[string]string {
+	data, err := ioutil.ReadFile(ws.OutputFile)
+	assert.Nil(t, err)
+	outputs := make(map[string]string)
+	assert.Nil(t, json.Unmarshal(data, &outputs))
+	return outputs
+}
+
+func TestPushAndPull(t *testing.T) {
+	storage := &fakeStorage{objects: make(map[string]string)}
+	server := httptest.NewServer(storage)
+	defer server.Close()
+
+	ws := newWorkspace(strings.TrimPrefix(server.URL, "http://"))
+	defer os.RemoveAll(ws.Dir)
+	os.MkdirAll(ws.DataDir(), 0755)
+	ioutil.WriteFile(filepath.Join(ws.DataDir(), "hello.txt"), []byte("hello"), 0644)
+	assert.Nil(t, (&Resolver{}).Push(ws))
+	assert.Equal(t, "hello", storage.objects["/releases/app/hello.txt"])
+	assert.Equal(t, map[string]string{
+		OutputDigest:    helloDigest,
+		OutputETag:      "etag",
+		OutputVersionID: "v2",
+	}, readOutputs(t, ws))
+
+	ws = newWorkspace(strings.TrimPrefix(server.URL, "http://"))
+	defer os.RemoveAll(ws.Dir)
+	// Version not found
+	assert.NotNil(t, (&Resolver{}).Pull(ws))
+
+	ws.Params[EnvVersionID] = "v1"
+	ws.Params[EnvFile] = "data.txt"
+	assert.Nil(t, (&Resolver{}).Pull(ws))
+	data, err := ioutil.ReadFile(filepath.Join(ws.DataDir(), "data.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", string(data))
+	assert.Equal(t, map[string]string{
+		OutputDigest:    helloDigest,
+		OutputETag:      "etag",
+		OutputVersionID: "v1",
+	}, readOutputs(t, ws))
+}
@@ -0,0 +1,187 @@
+package svn
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/caicloud/cyclone/pkg/workflow/resolver"
+)
+
+const (
+	// EnvURL is parameter of the SVN url, e.g. https://svn.example.com/repo/trunk.
+	EnvURL = "SVN_URL"
+	// EnvRevision is parameter of the revision to pull, it can be a revision number, HEAD or a
+	// date like {2018-10-10}, default to HEAD.
+	EnvRevision = "SVN_REVISION"
+	// EnvUsername is parameter of the username to access the repository.
+	EnvUsername = "SVN_USERNAME"
+	// EnvPassword is parameter of the password to access the repository.
+	EnvPassword = "SVN_PASSWORD"
+	// EnvTrustServerCert is parameter to accept untrusted server certificates, 'true' to enable.
+	EnvTrustServerCert = "SVN_TRUST_SERVER_CERT"
+	// EnvSCMIntegration is parameter of the SVN SCM integration whose credentials are used to
+	// access the repository. User and password of the integration are passed to the resolver
+	// as AUTH_USERNAME and AUTH_PASSWORD by Workflow Controller.
+	EnvSCMIntegration = "SCM_INTEGRATION"
+
+	// OutputRevision is key of the output of the resolved revision number.
+	OutputRevision = "revision"
+	// OutputAuthor is key of the output of the author of the last change.
+	OutputAuthor = "author"
+)
+
+const usage = `This tool is used to resolve SVN resources, here SVN resource
+stands for a revision of a path in a SVN repository.
+
+Usage:
+    $ docker run -it --rm \
+        -e SVN_URL=https://svn.example.com/repo/trunk \
+        -e SVN_REVISION=1024 \
+        -e SVN_USERNAME=xxxx \
+        -e SVN_PASSWORD=xxxx \
+        -e PULL_POLICY=IfNotExist \
+        svn-resource-resolver:latest <COMMAND>
+
+Supported commands are:
+- help Print out this help messages.
+- pull Checkout SVN source to $WORKDIR/data, "/workspace/data" by default.
+- push Commit SVN source to remote SVN server. (Not implemented yet)
+
+Environment variable SVN_URL must be set. SVN_REVISION can be revision
+number, HEAD or date like {2018-10-10}, default HEAD. Credentials are given
+by SVN_USERNAME and SVN_PASSWORD, or AUTH_USERNAME and AUTH_PASSWORD from
+SCM integration. Set SVN_TRUST_SERVER_CERT to true to accept untrusted
+server certificates. PULL_POLICY indicates whether pull resources when there
+already are old data, if set to IfNotExist, the working copy would be updated,
+otherwise old data would be removed and a fresh checkout is performed.
+
+The resolved revision number and author of the last change are written as
+key-value outputs 'revision' and 'author'.`
+
+// Resolver resolves SVN resources.
+type Resolver struct{}
+
+// Ensure *Resolver has implemented resolver.Resolver interface.
+var _ resolver.Resolver = (*Resolver)(nil)
+
+// Describe ...
+func (r *Resolver) Describe() string {
+	return usage
+}
+
+// Pull checks out the revision to data directory of the workspace.
+func (r *Resolver) Pull(ws *resolver.Workspace) error {
+	if err := ws.RequireParams(EnvURL); err != nil {
+		return err
+	}
+
+	err := ws.PullOnce(func() error {
+		return pull(ws)
+	})
+	if err != nil {
+		return err
+	}
+
+	entry, err := info(ws.DataDir())
+	if err != nil {
+		log.Warn("Get working copy information error: ", err)
+		return nil
+	}
+	log.WithField("revision", entry.Revision).Info("Revision pulled")
+	return ws.WriteOutputs(map[string]string{
+		OutputRevision: entry.Revision,
+		OutputAuthor:   entry.Commit.Author,
+	})
+}
+
+func pull(ws *resolver.Workspace) error {
+	url := strings.TrimSuffix(ws.Param(EnvURL), "/")
+	revision := ws.ParamOrDefault(EnvRevision, "HEAD")
+	data := ws.DataDir()
+
+	// If data existed and pull policy is IfNotExist, update the working copy.
+	if ws.Incremental() {
+		entry, err := info(data)
+		if err != nil {
+			return fmt.Errorf("existed data not a valid working copy: %v", err)
+		}
+		if strings.TrimSuffix(entry.URL, "/") != url {
+			return fmt.Errorf("existed data is working copy of %s, not %s", entry.URL, url)
+		}
+
+		log.Infof("Update working copy to %s", revision)
+		if err := resolver.Exec(data, "svn", "cleanup"); err != nil {
+			return err
+		}
+		return resolver.Exec(data, "svn", append(options(ws), "update", "-r", revision)...)
+	}
+
+	if _, err := os.Stat(data); err == nil {
+		log.Infof("Clean old data (%s) when pull policy is Always", data)
+		if err := os.RemoveAll(data); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("Checkout %s", revision)
+	// Revision is given as peg revision, so that paths moved or deleted after it still work.
+	return resolver.Exec(ws.Dir, "svn", append(options(ws), "checkout", url+"@"+revision, data)...)
+}
+
+// options gets global options of svn commands, including credentials.
+func options(ws *resolver.Workspace) []string {
+	opts := []string{"--non-interactive", "--no-auth-cache"}
+	if user := ws.ParamOrDefault(EnvUsername, ws.Param(resolver.EnvAuthUsername)); user != "" {
+		opts = append(opts, "--username", user)
+	}
+	if password := ws.ParamOrDefault(EnvPassword, ws.Param(resolver.EnvAuthPassword)); password != "" {
+		opts = append(opts, "--password", password)
+	}
+	if ws.Param(EnvTrustServerCert) == "true" {
+		opts = append(opts, "--trust-server-cert-failures=unknown-ca,cn-mismatch,expired,not-yet-valid,other")
+	}
+	return opts
+}
+
+// Entry is the working copy information given by 'svn info --xml'.
+type Entry struct {
+	Revision string `xml:"revision,attr"`
+	URL      string `xml:"url"`
+	Commit   struct {
+		Revision string `xml:"revision,attr"`
+		Author   string `xml:"author"`
+		Date     string `xml:"date"`
+	} `xml:"commit"`
+}
+
+// info gets information of the working copy.
+func info(dir string) (*Entry, error) {
+	out, err := resolver.Output(dir, "svn", "info", "--xml")
+	if err != nil {
+		return nil, fmt.Errorf("%v, %s", err, out)
+	}
+	return parseInfo(out)
+}
+
+func parseInfo(out string) (*Entry, error) {
+	var result struct {
+		Entries []Entry `xml:"entry"`
+	}
+	if err := xml.Unmarshal([]byte(out), &result); err != nil {
+		return nil, fmt.Errorf("unexpected svn info output: %s", out)
+	}
+	if len(result.Entries) == 0 || result.Entries[0].Revision == "" {
+		return nil, fmt.Errorf("no entry found in svn info output: %s", out)
+	}
+	return &result.Entries[0], nil
+}
+
+// Push ...
+func (r *Resolver) Push(ws *resolver.Workspace) error {
+	log.Info("Not implemented yet")
+	return nil
+}
@@ -0,0 +1,118 @@
+package svn
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/caicloud/cyclone/pkg/workflow/resolver"
+)
+
+func TestParseInfo(t *testing.T) {
+	entry, err := parseInfo(`<?xml version="1.0" encoding="UTF-8"?>
+<info>
+<entry kind="dir" path="." revision="1024">
+<url>https://svn.example.com/repo/trunk</url>
+<repository>
+<root>https://svn.example.com/repo</root>
+</repository>
+<commit revision="1020">
+<author>john</author>
+<date>2018-10-10T08:00:00.000000Z</date>
+</commit>
+</entry>
+</info>`)
+	assert.Nil(t, err)
+	assert.Equal(t, "1024", entry.Revision)
+	assert.Equal(t, "https://svn.example.com/repo/trunk", entry.URL)
+	assert.Equal(t, "1020", entry.Commit.Revision)
+	assert.Equal(t, "john", entry.Commit.Author)
+
+	_, err = parseInfo("svn: E155007: '/workspace/data' is not a working copy")
+	assert.NotNil(t, err)
+}
+
+func TestOptions(t *testing.T) {
+	ws := &resolver.Workspace{Params: map[string]string{
+		resolver.EnvAuthUsername: "integration",
+		resolver.EnvAuthPassword: "secret",
+	}}
+	assert.Equal(t, []string{"--non-interactive", "--no-auth-cache", "--username", "integration", "--password", "secret"}, options(ws))
+
+	// Credentials in resource parameters take precedence.
+	ws.Params[EnvUsername] = "john"
+	ws.Params[EnvPassword] = "pwd"
+	ws.Params[EnvTrustServerCert] = "true"
+	opts := options(ws)
+	assert.Equal(t, []string{"--username", "john", "--password", "pwd"}, opts[2:6])
+	assert.Equal(t, 7, len(opts))
+}
+
+func run(t *testing.T, dir, name string, args ...string) {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("%s %v error: %v, %s", name, args, err, out)
+	}
+}
+
+func TestPull(t *testing.T) {
+	if _, err := exec.LookPath("svnadmin"); err != nil {
+		t.Skip("svnadmin not found")
+	}
+
+	// Prepare a repository with two revisions.
+	root, _ := ioutil.TempDir("", "svn")
+	defer os.RemoveAll(root)
+	repo := filepath.Join(root, "repo")
+	run(t, root, "svnadmin", "create", repo)
+	url := "file://" + repo
+	run(t, root, "svn", "checkout", "-q", url, "wc")
+	wc := filepath.Join(root, "wc")
+	ioutil.WriteFile(filepath.Join(wc, "a.txt"), []byte("v1"), 0644)
+	run(t, wc, "svn", "add", "-q", "a.txt")
+	run(t, wc, "svn", "commit", "-q", "-m", "First", "--username", "tester")
+	ioutil.WriteFile(filepath.Join(wc, "a.txt"), []byte("v2"), 0644)
+	run(t, wc, "svn", "commit", "-q", "-m", "Second", "--username", "tester")
+
+	workspace, _ := ioutil.TempDir("", "workspace")
+	defer os.RemoveAll(workspace)
+	cases := []struct {
+		wfr      string
+		revision string
+		policy   string
+		content  string
+	}{
+		{"wfr1", "1", "", "v1"},
+		{"wfr2", "", "IfNotExist", "v2"},
+		{"wfr3", "1", "Always", "v1"},
+	}
+	for _, c := range cases {
+		ws := &resolver.Workspace{
+			Dir:         workspace,
+			WorkflowRun: c.wfr,
+			Params: map[string]string{
+				EnvURL:                 url,
+				EnvRevision:            c.revision,
+				resolver.EnvPullPolicy: c.policy,
+			},
+			OutputFile: filepath.Join(workspace, "outputs"),
+		}
+
+		assert.Nil(t, (&Resolver{}).Pull(ws), c.wfr)
+		data, _ := ioutil.ReadFile(filepath.Join(ws.DataDir(), "a.txt"))
+		assert.Equal(t, c.content, string(data), c.wfr)
+
+		outputs := make(map[string]string)
+		data, _ = ioutil.ReadFile(ws.OutputFile)
+		json.Unmarshal(data, &outputs)
+		assert.Equal(t, strings.TrimPrefix(c.content, "v"), outputs[OutputRevision], c.wfr)
+		assert.Equal(t, "tester", outputs[OutputAuthor], c.wfr)
+	}
+}
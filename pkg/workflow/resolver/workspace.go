@@ -0,0 +1,254 @@
+package resolver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/workflow/common"
+)
+
+// waitInterval is interval to check the pulling lock and the notify file.
+var waitInterval = 3 * time.Second
+
+const (
+	// EnvPullPolicy is parameter of the pull policy of persistent resources, Workflow Controller
+	// sets it from pull policy in persistent spec of the resource. If set to IfNotExist, existing
+	// data is updated incrementally, otherwise it's removed before pulling.
+	EnvPullPolicy = "PULL_POLICY"
+	// PullPolicyIfNotPresent is an alias of the IfNotExist pull policy, it's kept for
+	// compatibility.
+	PullPolicyIfNotPresent = "IfNotPresent"
+)
+
+// Workspace is the working directory shared between resolvers and coordinator. It contains:
+// - <wfr>-pulling.lock Lock file to ensure only one stage pulls the resource in a WorkflowRun.
+// - <wfr>-pulled Marker file indicating the resource has been pulled in a WorkflowRun.
+// - <wfr>-outputs.json Key-value outputs of pulling, see PullWithOutputs.
+// - notify Directory where coordinator creates 'ok' file when workload finished.
+// - data Directory holding data of the resource, for example, source code.
+type Workspace struct {
+	// Dir is path of the workspace.
+	Dir string
+	// WorkflowRun is name of the WorkflowRun that the resolver runs for.
+	WorkflowRun string
+	// Params are parameters of the resource, resource parameters are passed to resolvers as
+	// environment variables.
+	Params map[string]string
+	// OutputFile is the file where key-value outputs are written to, outputs are discarded
+	// if it's empty.
+	OutputFile string
+}
+
+// NewWorkspace creates workspace from environment variables.
+func NewWorkspace() *Workspace {
+	params := make(map[string]string)
+	for _, env := range os.Environ() {
+		parts := strings.SplitN(env, "=", 2)
+		if len(parts) == 2 {
+			params[parts[0]] = parts[1]
+		}
+	}
+
+	dir := params[common.EnvResolverWorkDir]
+	if dir == "" {
+		dir = common.ResolverDefaultWorkspacePath
+	}
+
+	return &Workspace{
+		Dir:         dir,
+		WorkflowRun: params[common.EnvWorkflowrunName],
+		Params:      params,
+		OutputFile:  common.ResolverTerminationLog,
+	}
+}
+
+// DataDir gets the directory holding resource data.
+func (w *Workspace) DataDir() string {
+	return filepath.Join(w.Dir, "data")
+}
+
+// Param gets value of a resource parameter.
+func (w *Workspace) Param(name string) string {
+	return w.Params[name]
+}
+
+// ParamOrDefault gets value of a resource parameter, default value is returned if not set.
+func (w *Workspace) ParamOrDefault(name, value string) string {
+	if v, ok := w.Params[name]; ok && v != "" {
+		return v
+	}
+	return value
+}
+
+// RequireParams checks that the parameters are all set.
+func (w *Workspace) RequireParams(names ...string) error {
+	for _, name := range names {
+		if _, ok := w.Params[name]; !ok {
+			return fmt.Errorf("%s is unset", name)
+		}
+	}
+	return nil
+}
+
+// Incremental checks whether to pull the resource incrementally, it's true when data exists and
+// pull policy is IfNotExist.
+func (w *Workspace) Incremental() bool {
+	policy := w.Param(EnvPullPolicy)
+	if policy != v1alpha1.PullIfNotExist && policy != PullPolicyIfNotPresent {
+		return false
+	}
+	return w.exists(w.DataDir())
+}
+
+// lockFile gets path of the pulling lock file.
+func (w *Workspace) lockFile() string {
+	return filepath.Join(w.Dir, common.PullingLockFile(w.WorkflowRun))
+}
+
+// markerFile gets path of the file indicating the resource has been pulled.
+func (w *Workspace) markerFile() string {
+	return filepath.Join(w.Dir, common.PulledMarkerFile(w.WorkflowRun))
+}
+
+// PullOnce runs the pull function with the pulling lock held, so that the resource is pulled
+// only once in a WorkflowRun. If the resource has been pulled or other stage is pulling it, it
+// waits for the pulling to finish instead. Data of persistent resources may exist before the
+// pulling, pull function should handle it according to the pull policy, see Incremental.
+func (w *Workspace) PullOnce(pull func() error) error {
+	if w.exists(w.markerFile()) {
+		log.Info("Resource already pulled")
+		return nil
+	}
+
+	log.Info("Trying to acquire lock and pull resource")
+	f, err := os.OpenFile(w.lockFile(), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		log.Info("Lock acquired by others, wait for the pulling to finish...")
+		w.waitUnlocked()
+		return nil
+	}
+
+	// The lock file is removed after pulling, others waiting for it can then go on.
+	defer func() {
+		os.Remove(w.lockFile())
+		f.Close()
+	}()
+
+	// Resource may be pulled by the last lock holder just now.
+	if w.exists(w.markerFile()) {
+		return nil
+	}
+
+	log.Info("Got the lock, start to pulling...")
+	if err := pull(); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(w.markerFile(), nil, 0644)
+}
+
+// PullWithOutputs pulls the resource with PullOnce and writes key-value outputs returned by the
+// pull function. Outputs are also saved in the workspace, so that stages that don't pull the
+// resource themselves get the same outputs.
+func (w *Workspace) PullWithOutputs(pull func() (map[string]string, error)) error {
+	var outputs map[string]string
+	err := w.PullOnce(func() error {
+		var err error
+		if outputs, err = pull(); err != nil {
+			return err
+		}
+		data, err := json.Marshal(outputs)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(w.outputsFile(), data, 0644)
+	})
+	if err != nil {
+		return err
+	}
+
+	if outputs == nil {
+		data, err := ioutil.ReadFile(w.outputsFile())
+		if err != nil {
+			log.Warn("Read outputs saved in workspace error: ", err)
+			return nil
+		}
+		if err := json.Unmarshal(data, &outputs); err != nil {
+			return err
+		}
+	}
+	return w.WriteOutputs(outputs)
+}
+
+// outputsFile gets path of the file holding outputs of pulling.
+func (w *Workspace) outputsFile() string {
+	return filepath.Join(w.Dir, common.PulledOutputsFile(w.WorkflowRun))
+}
+
+// waitUnlocked waits until the pulling lock file removed.
+func (w *Workspace) waitUnlocked() {
+	for w.exists(w.lockFile()) {
+		time.Sleep(waitInterval)
+	}
+}
+
+// WaitNotify waits until coordinator notifies that resource data is ready, it should be called
+// before pushing resources.
+func (w *Workspace) WaitNotify() {
+	ok := filepath.Join(w.Dir, common.ResolverNotifyDir, common.ResolverNotifyOkFile)
+	for !w.exists(ok) {
+		time.Sleep(waitInterval)
+	}
+}
+
+// WriteOutputs writes key-value outputs of the resource to the termination message of resolver
+// container, Workflow Controller records them in stage outputs of WorkflowRun status, keys are
+// prefixed with name of the resource, e.g. '<resource>.commit'. Termination message is limited to
+// 4096 bytes, so outputs should be small.
+func (w *Workspace) WriteOutputs(outputs map[string]string) error {
+	if w.OutputFile == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(outputs)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(w.OutputFile, data, 0644)
+}
+
+func (w *Workspace) exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Exec runs the command in directory 'dir' with output streamed to stdout and stderr. Arguments
+// are not logged since they may contain credentials.
+func Exec(dir, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// Output runs the command in directory 'dir' and returns its output.
+func Output(dir, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
@@ -0,0 +1,127 @@
+package resolver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/caicloud/cyclone/pkg/workflow/common"
+)
+
+func TestPullOnce(t *testing.T) {
+	waitInterval = 10 * time.Millisecond
+	dir, _ := ioutil.TempDir("", "workspace")
+	defer os.RemoveAll(dir)
+
+	var pulled int32
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ws := &Workspace{Dir: dir, WorkflowRun: "wfr"}
+			err := ws.PullOnce(func() error {
+				atomic.AddInt32(&pulled, 1)
+				time.Sleep(50 * time.Millisecond)
+				return os.MkdirAll(ws.DataDir(), 0755)
+			})
+			assert.Nil(t, err)
+			// Data should be ready when PullOnce returned.
+			_, err = os.Stat(ws.DataDir())
+			assert.Nil(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), pulled)
+	_, err := os.Stat(filepath.Join(dir, common.PullingLockFile("wfr")))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestWaitNotify(t *testing.T) {
+	waitInterval = 10 * time.Millisecond
+	dir, _ := ioutil.TempDir("", "workspace")
+	defer os.RemoveAll(dir)
+
+	ws := &Workspace{Dir: dir}
+	done := make(chan struct{})
+	go func() {
+		ws.WaitNotify()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("should wait for notify")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	os.MkdirAll(filepath.Join(dir, common.ResolverNotifyDir), 0755)
+	ioutil.WriteFile(filepath.Join(dir, common.ResolverNotifyDir, common.ResolverNotifyOkFile), nil, 0644)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("should be notified")
+	}
+}
+
+type fakeResolver struct {
+	pulled, pushed bool
+}
+
+func (r *fakeResolver) Describe() string { return "fake" }
+func (r *fakeResolver) Pull(ws *Workspace) error {
+	r.pulled = true
+	return nil
+}
+func (r *fakeResolver) Push(ws *Workspace) error {
+	r.pushed = true
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	r := &fakeResolver{}
+	assert.Nil(t, Run(r, common.ResourcePullCommand))
+	assert.True(t, r.pulled)
+	assert.Nil(t, Run(r, common.ResourcePushCommand))
+	assert.True(t, r.pushed)
+	assert.Nil(t, Run(r, "help"))
+	assert.NotNil(t, Run(r, "unknown"))
+}
+
+func TestPullOnceWithExistingData(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "workspace")
+	defer os.RemoveAll(dir)
+
+	// Data of persistent resources pulled by previous WorkflowRuns.
+	ws := &Workspace{Dir: dir, Params: map[string]string{}}
+	os.MkdirAll(ws.DataDir(), 0755)
+	assert.False(t, ws.Incremental())
+	ws.Params[EnvPullPolicy] = "IfNotExist"
+	assert.True(t, ws.Incremental())
+
+	// Resource is pulled once in each WorkflowRun even if data exists.
+	cases := []struct {
+		wfr    string
+		pulled bool
+	}{
+		{"wfr1", true},
+		{"wfr1", false},
+		{"wfr2", true},
+	}
+	for _, c := range cases {
+		ws.WorkflowRun = c.wfr
+		var pulled bool
+		assert.Nil(t, ws.PullOnce(func() error {
+			pulled = true
+			return nil
+		}))
+		assert.Equal(t, c.pulled, pulled, c.wfr)
+	}
+}
@@ -0,0 +1,116 @@
+package workflowrun
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/caicloud/cyclone/pkg/k8s/clientset"
+	"github.com/caicloud/cyclone/pkg/workflow/artifact"
+	"github.com/caicloud/cyclone/pkg/workflow/common"
+	"github.com/caicloud/cyclone/pkg/workflow/controller"
+)
+
+// preparedStores records artifact stores that have been prepared, so that buckets won't be
+// checked every time a stage pod created. Key is in format <endpoint>/<bucket>/<prefix>.
+var preparedStores sync.Map
+
+// ensureArtifactStoreSecret saves the encoded artifact store config for the given namespace in
+// a secret, which would be passed to coordinator and artifact fetcher. The config contains
+// credentials of object storage, so it's not put in pod spec directly. For object storage,
+// bucket is created and retention rule is applied if not done before. Name of the secret is
+// returned.
+func ensureArtifactStoreSecret(client clientset.Interface, namespace string) (string, error) {
+	config := controller.Config.Artifact.ForNamespace(namespace)
+	if config.StoreType() != artifact.PVCStore {
+		key := fmt.Sprintf("%s/%s/%s", config.S3.Endpoint, config.S3.Bucket, config.S3.Prefix)
+		if _, ok := preparedStores.Load(key); !ok {
+			if err := artifact.EnsureBucket(config); err != nil {
+				log.WithField("ns", namespace).WithField("bucket", config.S3.Bucket).Error("Prepare artifact store error: ", err)
+				return "", fmt.Errorf("prepare artifact store error: %v", err)
+			}
+			preparedStores.Store(key, true)
+		}
+	}
+
+	encoded, err := artifact.EncodeConfig(config)
+	if err != nil {
+		return "", err
+	}
+	data := map[string][]byte{
+		common.EnvArtifactStore: []byte(encoded),
+	}
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		origin, err := client.CoreV1().Secrets(namespace).Get(common.ArtifactStoreSecretName, metav1.GetOptions{})
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				return err
+			}
+
+			_, err = client.CoreV1().Secrets(namespace).Create(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      common.ArtifactStoreSecretName,
+					Namespace: namespace,
+					Labels: map[string]string{
+						common.WorkflowLabelName: "true",
+					},
+				},
+				Data: data,
+			})
+			return err
+		}
+
+		if reflect.DeepEqual(origin.Data, data) {
+			return nil
+		}
+
+		secret := origin.DeepCopy()
+		secret.Data = data
+		_, err = client.CoreV1().Secrets(namespace).Update(secret)
+		return err
+	})
+	if err != nil {
+		log.WithField("ns", namespace).Error("Ensure artifact store secret error: ", err)
+		return "", err
+	}
+
+	return common.ArtifactStoreSecretName, nil
+}
+
+// artifactStoreEnv refers the artifact store config in the secret as environment variable.
+func artifactStoreEnv(secret string) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: common.EnvArtifactStore,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: secret,
+				},
+				Key: common.EnvArtifactStore,
+			},
+		},
+	}
+}
+
+// deleteArtifacts deletes artifacts of the WorkflowRun from object storage. Artifacts in PVC
+// are cleaned together with other data of the WorkflowRun, and if retention days configured,
+// artifacts are expired by the object storage.
+func deleteArtifacts(namespace, wfr string) error {
+	config := controller.Config.Artifact.ForNamespace(namespace)
+	if config.StoreType() == artifact.PVCStore || config.RetentionDays > 0 {
+		return nil
+	}
+
+	store, err := artifact.NewStore(config, "", "")
+	if err != nil {
+		return err
+	}
+	return store.Delete(artifact.WorkflowRunPrefix(wfr))
+}
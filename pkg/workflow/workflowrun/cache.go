@@ -0,0 +1,522 @@
+package workflowrun
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/k8s/clientset"
+	"github.com/caicloud/cyclone/pkg/util/git"
+	"github.com/caicloud/cyclone/pkg/util/registry"
+	"github.com/caicloud/cyclone/pkg/workflow/artifact"
+	"github.com/caicloud/cyclone/pkg/workflow/common"
+	"github.com/caicloud/cyclone/pkg/workflow/controller"
+	gitresolver "github.com/caicloud/cyclone/pkg/workflow/resolver/git"
+	helmresolver "github.com/caicloud/cyclone/pkg/workflow/resolver/helm"
+	httpresolver "github.com/caicloud/cyclone/pkg/workflow/resolver/http"
+	imageresolver "github.com/caicloud/cyclone/pkg/workflow/resolver/image"
+	mavenresolver "github.com/caicloud/cyclone/pkg/workflow/resolver/maven"
+	s3resolver "github.com/caicloud/cyclone/pkg/workflow/resolver/s3"
+	svnresolver "github.com/caicloud/cyclone/pkg/workflow/resolver/svn"
+)
+
+const (
+	// cacheConfigMapPrefix is name prefix of ConfigMaps holding cached stage results.
+	cacheConfigMapPrefix = "stage-cache-"
+	// cacheDataKey is key of the cached results in ConfigMap data.
+	cacheDataKey = "cache"
+)
+
+// resolveRevision resolves git revision to commit SHA, it's a variable so that it can be
+// replaced in tests.
+var resolveRevision = git.ResolveRevision
+
+// resolveImageDigest resolves image to digest of its manifest, it's a variable so that it can be
+// replaced in tests.
+var resolveImageDigest = defaultResolveImageDigest
+
+func defaultResolveImageDigest(ref *registry.Reference, credentials map[string]registry.Credential, insecure bool) (string, error) {
+	client := registry.NewClient(credentials)
+	client.Insecure = insecure
+	return client.Resolve(ref)
+}
+
+// cacheEntry is results of a stage cached under the cache key.
+type cacheEntry struct {
+	// WorkflowRun that produced the results
+	Source string `json:"source"`
+	// Stage that produced the results
+	Stage string `json:"stage"`
+	// Key-value outputs of the stage
+	Outputs []v1alpha1.KeyValue `json:"outputs,omitempty"`
+	// Checksums of output artifacts of the stage
+	Artifacts map[string]string `json:"artifacts,omitempty"`
+}
+
+// cachePolicy gets cache policy of the stage in Workflow, nil is returned if cache is not enabled.
+func cachePolicy(wf *v1alpha1.Workflow, stage string) *v1alpha1.CachePolicy {
+	if wf == nil {
+		return nil
+	}
+	for _, s := range wf.Spec.Stages {
+		if s.Name == stage && s.Cache != nil && s.Cache.Enabled {
+			return s.Cache
+		}
+	}
+	return nil
+}
+
+// cacheConfigMapName gets name of the ConfigMap holding results cached under the key.
+func cacheConfigMapName(key string) string {
+	return cacheConfigMapPrefix + key
+}
+
+// StageCacheKey computes cache key of a stage. The key is a SHA256 digest of the rendered pod
+// spec, inputs and outputs of the stage, the stage arguments, resolved revisions of input
+// resources and checksums of input artifacts. Error is returned if the stage is not cacheable,
+// for example, it has input resources whose revision can't be determined.
+func StageCacheKey(client clientset.Interface, wf *v1alpha1.Workflow, wfr *v1alpha1.WorkflowRun, stage string) (string, error) {
+	m := NewPodBuilder(client, wf, wfr, stage)
+	if err := m.Prepare(); err != nil {
+		return "", err
+	}
+	if err := m.ResolveArguments(); err != nil {
+		return "", err
+	}
+	arguments, err := m.arguments()
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	if err := hashField(h, "pod", m.pod.Spec); err != nil {
+		return "", err
+	}
+	if err := hashField(h, "inputs", m.stg.Spec.Pod.Inputs); err != nil {
+		return "", err
+	}
+	if err := hashField(h, "outputs", m.stg.Spec.Pod.Outputs); err != nil {
+		return "", err
+	}
+	// Keys of map are sorted when marshalled to JSON.
+	if err := hashField(h, "arguments", arguments); err != nil {
+		return "", err
+	}
+
+	for _, r := range m.stg.Spec.Pod.Inputs.Resources {
+		resource, err := client.CycloneV1alpha1().Resources(wfr.Namespace).Get(r.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		parameters := m.resourceParameters(resource)
+		// Token of the SCM integration is needed to resolve revisions of private repositories.
+		if integration := parameters[gitresolver.EnvSCMIntegration]; integration != "" && parameters[gitresolver.EnvToken] == "" {
+			if scm, err := GetSCMIntegration(client, wfr.Namespace, integration); err == nil {
+				parameters[gitresolver.EnvToken] = scm.Token
+			}
+		}
+		revision, err := resourceRevision(client, wfr.Namespace, resource, parameters)
+		if err != nil {
+			return "", fmt.Errorf("resolve revision of resource %s error: %v", r.Name, err)
+		}
+		if err := hashField(h, "resource/"+r.Name, map[string]interface{}{
+			"type":       resource.Spec.Type,
+			"parameters": m.resourceParameters(resource),
+			"revision":   revision,
+		}); err != nil {
+			return "", err
+		}
+	}
+
+	for _, a := range m.stg.Spec.Pod.Inputs.Artifacts {
+		checksum, err := inputArtifactChecksum(wf, wfr, stage, a.Name)
+		if err != nil {
+			return "", err
+		}
+		if err := hashField(h, "artifact/"+a.Name, checksum); err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashField(h hash.Hash, name string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(h, "%s:%s\n", name, data)
+	return nil
+}
+
+// resourceRevision gets the revision that identifies content of the input resource. Git revisions
+// are resolved to commit SHA, image tags are resolved to digest, and KV resources are identified
+// by their parameters. SVN resources are cacheable when revision number is given, HTTP and S3
+// resources are cacheable when checksum or version is pinned, Helm and Maven resources are
+// cacheable when an exact version is given. Persistent resources and other resources are not
+// cacheable.
+func resourceRevision(client clientset.Interface, namespace string, resource *v1alpha1.Resource, parameters map[string]string) (string, error) {
+	if resource.Spec.Persistent != nil {
+		return "", fmt.Errorf("persistent resource is not cacheable")
+	}
+
+	switch resource.Spec.Type {
+	case v1alpha1.GitResourceType:
+		return resolveRevision(parameters[gitresolver.EnvURL], parameters[gitresolver.EnvToken], parameters[gitresolver.EnvRevision])
+	case v1alpha1.ImageResourceType:
+		ref, err := registry.ParseReference(parameters[imageresolver.EnvImage])
+		if err != nil {
+			return "", err
+		}
+		if ref.Digest != "" {
+			return ref.Digest, nil
+		}
+		credentials, err := registryCredentials(client, namespace)
+		if err != nil {
+			return "", err
+		}
+		return resolveImageDigest(ref, credentials, parameters[imageresolver.EnvInsecure] == "true")
+	case v1alpha1.SVNResourceType:
+		// Only revision numbers are cacheable, HEAD and dates need the repository to resolve.
+		if revision := parameters[svnresolver.EnvRevision]; isNumber(revision) {
+			return revision, nil
+		}
+		return "", fmt.Errorf("resource without %s number is not cacheable", svnresolver.EnvRevision)
+	case v1alpha1.KVResourceType:
+		return "", nil
+	case v1alpha1.HTTPResourceType:
+		if sum := parameters[httpresolver.EnvSHA256]; sum != "" {
+			return sum, nil
+		}
+		return "", fmt.Errorf("resource without %s is not cacheable", httpresolver.EnvSHA256)
+	case v1alpha1.S3ResourceType:
+		if version := parameters[s3resolver.EnvVersionID]; version != "" {
+			return version, nil
+		}
+		return "", fmt.Errorf("resource without %s is not cacheable", s3resolver.EnvVersionID)
+	case v1alpha1.HelmResourceType:
+		// Chart versions are immutable in chart repositories.
+		if parameters[helmresolver.EnvVersion] != "" {
+			return "", nil
+		}
+		return "", fmt.Errorf("resource without %s is not cacheable", helmresolver.EnvVersion)
+	case v1alpha1.MavenResourceType:
+		// Release versions are immutable in Maven repositories, while SNAPSHOT, LATEST and
+		// RELEASE change over time.
+		version := parameters[mavenresolver.EnvVersion]
+		if version != "" && version != "LATEST" && version != "RELEASE" && !strings.HasSuffix(version, "-SNAPSHOT") {
+			return "", nil
+		}
+		return "", fmt.Errorf("resource with version '%s' is not cacheable", version)
+	default:
+		return "", fmt.Errorf("resource type %s is not cacheable", resource.Spec.Type)
+	}
+}
+
+// isNumber checks whether the string consists of only digits.
+func isNumber(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// registryCredentials gets registry credentials from DockerRegistry integrations in the namespace.
+func registryCredentials(client clientset.Interface, namespace string) (map[string]registry.Credential, error) {
+	registries, err := GetRegistryIntegrations(client, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := make(map[string]registry.Credential)
+	for _, r := range registries {
+		credentials[registry.NormalizeRegistry(r.Server)] = registry.Credential{
+			Username: r.User,
+			Password: r.Password,
+		}
+	}
+	return credentials, nil
+}
+
+// inputArtifactChecksum gets checksum of an input artifact of the stage from status of the stage
+// that produces it.
+func inputArtifactChecksum(wf *v1alpha1.Workflow, wfr *v1alpha1.WorkflowRun, stage, name string) (string, error) {
+	var source string
+	for _, s := range wf.Spec.Stages {
+		if s.Name != stage {
+			continue
+		}
+		for _, a := range s.Artifacts {
+			if a.Name == name {
+				source = a.Source
+			}
+		}
+	}
+	parts := strings.Split(source, "/")
+	if len(parts) != 2 {
+		return "", fmt.Errorf("invalid source '%s' of input artifact %s", source, name)
+	}
+
+	status, ok := wfr.Status.Stages[parts[0]]
+	if !ok || status == nil || status.Artifacts[parts[1]] == "" {
+		return "", fmt.Errorf("checksum of input artifact %s unknown", name)
+	}
+	return status.Artifacts[parts[1]], nil
+}
+
+// lookupCache gets results cached under the key, nil is returned if no results cached. If the
+// cached results have expired according to the TTL, they are deleted.
+func lookupCache(client clientset.Interface, namespace, key string, policy *v1alpha1.CachePolicy) (*cacheEntry, error) {
+	cm, err := client.CoreV1().ConfigMaps(namespace).Get(cacheConfigMapName(key), metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if policy.TTL != "" {
+		ttl, err := time.ParseDuration(policy.TTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cache ttl %s: %v", policy.TTL, err)
+		}
+		if cm.CreationTimestamp.Add(ttl).Before(time.Now()) {
+			log.WithField("key", key).Info("Cached results expired")
+			return nil, deleteCache(client, namespace, key)
+		}
+	}
+
+	entry := &cacheEntry{}
+	if err := json.Unmarshal([]byte(cm.Data[cacheDataKey]), entry); err != nil {
+		return nil, fmt.Errorf("invalid cache entry %s: %v", cm.Name, err)
+	}
+	return entry, nil
+}
+
+// deleteCache deletes results cached under the key. Cached artifacts in object storage are deleted
+// too, while those in PVC would be overwritten when the key is cached again.
+func deleteCache(client clientset.Interface, namespace, key string) error {
+	config := controller.Config.Artifact.ForNamespace(namespace)
+	if config.StoreType() != artifact.PVCStore {
+		store, err := artifact.NewStore(config, "", "")
+		if err != nil {
+			return err
+		}
+		if err := store.Delete(artifact.CachePrefix(key)); err != nil {
+			return err
+		}
+	}
+
+	err := client.CoreV1().ConfigMaps(namespace).Delete(cacheConfigMapName(key), &metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// SweepCaches deletes expired cached results in all namespaces. Expired results are also deleted
+// when they are looked up, but cache keys change with inputs of stages, so most of them would
+// never be looked up again. Results cached without TTL never expire.
+func SweepCaches(client clientset.Interface) error {
+	cms, err := client.CoreV1().ConfigMaps(metav1.NamespaceAll).List(metav1.ListOptions{
+		LabelSelector: common.StageCacheLabelName + "=true",
+	})
+	if err != nil {
+		return err
+	}
+
+	// Keys of deleted caches in each namespace.
+	deleted := make(map[string][]string)
+	now := time.Now()
+	for _, cm := range cms.Items {
+		expire, ok := cm.Annotations[common.StageCacheExpireAnnotationName]
+		if !ok {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, expire)
+		if err != nil {
+			log.WithField("cm", cm.Name).Warn("Invalid cache expire time: ", expire)
+			continue
+		}
+		if t.After(now) {
+			continue
+		}
+
+		key := strings.TrimPrefix(cm.Name, cacheConfigMapPrefix)
+		if err := deleteCache(client, cm.Namespace, key); err != nil {
+			log.WithField("key", key).WithField("ns", cm.Namespace).Warn("Delete expired cache error: ", err)
+			continue
+		}
+		log.WithField("key", key).WithField("ns", cm.Namespace).Info("Expired cache deleted")
+		deleted[cm.Namespace] = append(deleted[cm.Namespace], key)
+	}
+
+	// Cached artifacts in PVC are cleaned by GC pod.
+	if controller.Config.PVC == "" {
+		return nil
+	}
+	for namespace, keys := range deleted {
+		if controller.Config.Artifact.ForNamespace(namespace).StoreType() != artifact.PVCStore {
+			continue
+		}
+		if _, err := client.CoreV1().Pods(namespace).Create(cacheGCPod(namespace, keys)); err != nil {
+			log.WithField("ns", namespace).Warn("Create cache GC pod error: ", err)
+		}
+	}
+	return nil
+}
+
+// cacheGCPod builds a GC pod to clean artifacts cached under the keys in PVC. GC pods are deleted
+// by pod handler once they are terminated.
+func cacheGCPod(namespace string, keys []string) *corev1.Pod {
+	command := []string{"rm", "-rf"}
+	for _, key := range keys {
+		command = append(command, common.GCDataPath+"/"+key)
+	}
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "cachegc--",
+			Namespace:    namespace,
+			Labels: map[string]string{
+				common.WorkflowLabelName: "true",
+			},
+			Annotations: map[string]string{
+				common.GCAnnotationName: "true",
+			},
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers: []corev1.Container{
+				{
+					Name:    common.GCContainerName,
+					Image:   controller.Config.Images[controller.GCImage],
+					Command: command,
+					VolumeMounts: []corev1.VolumeMount{
+						{
+							Name:      common.DefaultPvVolumeName,
+							MountPath: common.GCDataPath,
+							SubPath:   common.CachesPath(),
+						},
+					},
+				},
+			},
+			Volumes: []corev1.Volume{
+				{
+					Name: common.DefaultPvVolumeName,
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+							ClaimName: controller.Config.PVC,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// saveCache saves results of a completed stage under its cache key. If the cache policy has TTL,
+// expire time is recorded in annotation of the ConfigMap, so that expired results can be swept,
+// see SweepCaches.
+func saveCache(client clientset.Interface, wfr *v1alpha1.WorkflowRun, stage string, policy *v1alpha1.CachePolicy) error {
+	status, ok := wfr.Status.Stages[stage]
+	if !ok || status == nil || status.Cache == nil || status.Cache.Key == "" || status.Cache.Hit {
+		return nil
+	}
+
+	data, err := json.Marshal(&cacheEntry{
+		Source:    wfr.Name,
+		Stage:     stage,
+		Outputs:   status.Outputs,
+		Artifacts: status.Artifacts,
+	})
+	if err != nil {
+		return err
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cacheConfigMapName(status.Cache.Key),
+			Namespace: wfr.Namespace,
+			Labels: map[string]string{
+				common.StageCacheLabelName: "true",
+			},
+		},
+		Data: map[string]string{
+			cacheDataKey: string(data),
+		},
+	}
+	if policy != nil && policy.TTL != "" {
+		ttl, err := time.ParseDuration(policy.TTL)
+		if err != nil {
+			return fmt.Errorf("invalid cache ttl %s: %v", policy.TTL, err)
+		}
+		cm.Annotations = map[string]string{
+			common.StageCacheExpireAnnotationName: time.Now().Add(ttl).UTC().Format(time.RFC3339),
+		}
+	}
+	_, err = client.CoreV1().ConfigMaps(wfr.Namespace).Create(cm)
+	if errors.IsAlreadyExists(err) {
+		_, err = client.CoreV1().ConfigMaps(wfr.Namespace).Update(cm)
+	}
+	return err
+}
+
+// restoreFromCache tries to restore results of the stage from cache. It returns true if results
+// are restored, in which case, the stage is marked as completed and no pod need to be created.
+// If cache is enabled but no results cached, cache key is recorded in stage status, so that
+// results would be cached when the stage completed.
+func (o *operator) restoreFromCache(stage string) bool {
+	policy := cachePolicy(o.wf, stage)
+	if policy == nil {
+		return false
+	}
+
+	key, err := StageCacheKey(o.client, o.wf, o.wfr, stage)
+	if err != nil {
+		log.WithField("wfr", o.wfr.Name).WithField("stg", stage).Warn("Stage not cacheable: ", err)
+		return false
+	}
+	o.wfr.Status.Stages[stage].Cache = &v1alpha1.StageCacheStatus{Key: key}
+
+	entry, err := lookupCache(o.client, o.wfr.Namespace, key, policy)
+	if err != nil {
+		log.WithField("wfr", o.wfr.Name).WithField("stg", stage).Warn("Lookup cache error: ", err)
+		return false
+	}
+	if entry == nil {
+		log.WithField("stg", stage).WithField("key", key).Info("No cached results found")
+		return false
+	}
+
+	log.WithField("stg", stage).WithField("key", key).WithField("source", entry.Source).Info("Restore stage results from cache")
+	o.recorder.Eventf(o.wfr, corev1.EventTypeNormal, "StageCacheHit", "Results of stage '%s' restored from WorkflowRun '%s'", stage, entry.Source)
+	o.UpdateStageStatus(stage, &v1alpha1.Status{
+		Status:             v1alpha1.StatusCompleted,
+		Reason:             "CacheHit",
+		LastTransitionTime: metav1.Time{Time: time.Now()},
+		Message:            fmt.Sprintf("Completed (cached), results restored from WorkflowRun %s", entry.Source),
+	})
+	o.wfr.Status.Stages[stage].Outputs = entry.Outputs
+	o.wfr.Status.Stages[stage].Artifacts = entry.Artifacts
+	o.wfr.Status.Stages[stage].Cache = &v1alpha1.StageCacheStatus{
+		Key:    key,
+		Hit:    true,
+		Source: entry.Source,
+	}
+
+	return true
+}
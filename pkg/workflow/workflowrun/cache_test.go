@@ -0,0 +1,366 @@
+package workflowrun
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/k8s/clientset/fake"
+	"github.com/caicloud/cyclone/pkg/util/git"
+	"github.com/caicloud/cyclone/pkg/util/registry"
+	"github.com/caicloud/cyclone/pkg/workflow/common"
+	"github.com/caicloud/cyclone/pkg/workflow/controller"
+)
+
+var cacheWf = &v1alpha1.Workflow{
+	ObjectMeta: metav1.ObjectMeta{
+		Name:      "wf",
+		Namespace: "default",
+	},
+	Spec: v1alpha1.WorkflowSpec{
+		Stages: []v1alpha1.StageItem{
+			{
+				Name: "build",
+				Cache: &v1alpha1.CachePolicy{
+					Enabled: true,
+					TTL:     "24h",
+				},
+			},
+			{
+				Name: "test",
+				Artifacts: []v1alpha1.ArtifactItem{
+					{
+						Name:   "bin",
+						Source: "build/bin",
+					},
+				},
+				Cache: &v1alpha1.CachePolicy{
+					Enabled: true,
+				},
+			},
+		},
+	},
+}
+
+var cacheStages = []*v1alpha1.Stage{
+	{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "build",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.StageSpec{
+			Pod: &v1alpha1.PodWorkload{
+				Inputs: v1alpha1.Inputs{
+					Arguments: []v1alpha1.ArgumentValue{
+						{
+							Name:  "cmd",
+							Value: "make",
+						},
+					},
+					Resources: []v1alpha1.ResourceItem{
+						{
+							Name: "code",
+							Path: "/workspace/code",
+						},
+					},
+				},
+				Outputs: v1alpha1.Outputs{
+					Artifacts: []v1alpha1.ArtifactItem{
+						{
+							Name: "bin",
+							Path: "/workspace/code/bin",
+						},
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:    "main",
+							Image:   "golang:1.10",
+							Command: []string{"{{{cmd}}}"},
+						},
+					},
+				},
+			},
+		},
+	},
+	{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.StageSpec{
+			Pod: &v1alpha1.PodWorkload{
+				Inputs: v1alpha1.Inputs{
+					Artifacts: []v1alpha1.ArtifactItem{
+						{
+							Name: "bin",
+							Path: "/workspace/bin",
+						},
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  "main",
+							Image: "alpine:3.8",
+						},
+					},
+				},
+			},
+		},
+	},
+}
+
+var codeResource = &v1alpha1.Resource{
+	ObjectMeta: metav1.ObjectMeta{
+		Name:      "code",
+		Namespace: "default",
+	},
+	Spec: v1alpha1.ResourceSpec{
+		Type: v1alpha1.GitResourceType,
+		Parameters: []v1alpha1.ParameterItem{
+			{
+				Name:  "GIT_URL",
+				Value: "https://github.com/caicloud/cyclone.git",
+			},
+			{
+				Name:  "GIT_REVISION",
+				Value: "master",
+			},
+		},
+	},
+}
+
+func newCacheWfr(name string) *v1alpha1.WorkflowRun {
+	return &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		},
+		Spec: v1alpha1.WorkflowRunSpec{
+			WorkflowRef: &corev1.ObjectReference{
+				Name: "wf",
+			},
+		},
+		Status: v1alpha1.WorkflowRunStatus{
+			Stages: map[string]*v1alpha1.StageStatus{
+				"build": {
+					Status: v1alpha1.Status{Status: v1alpha1.StatusRunning},
+				},
+			},
+		},
+	}
+}
+
+func TestStageCacheKey(t *testing.T) {
+	commit := "1111111111111111111111111111111111111111"
+	resolveRevision = func(url, token, revision string) (string, error) {
+		return commit, nil
+	}
+	defer func() { resolveRevision = git.ResolveRevision }()
+
+	client := fake.NewSimpleClientset(cacheStages[0], cacheStages[1], codeResource)
+	key1, err := StageCacheKey(client, cacheWf, newCacheWfr("wfr1"), "build")
+	assert.Nil(t, err)
+	key2, err := StageCacheKey(client, cacheWf, newCacheWfr("wfr2"), "build")
+	assert.Nil(t, err)
+	assert.Equal(t, key1, key2)
+
+	// Key changes when the resolved revision changes.
+	commit = "2222222222222222222222222222222222222222"
+	key3, err := StageCacheKey(client, cacheWf, newCacheWfr("wfr3"), "build")
+	assert.Nil(t, err)
+	assert.NotEqual(t, key1, key3)
+
+	// Key changes when arguments change.
+	wfr := newCacheWfr("wfr4")
+	wfr.Spec.Stages = []v1alpha1.ParameterConfig{
+		{
+			Name: "build",
+			Parameters: []v1alpha1.ParameterItem{
+				{
+					Name:  "cmd",
+					Value: "make test",
+				},
+			},
+		},
+	}
+	key4, err := StageCacheKey(client, cacheWf, wfr, "build")
+	assert.Nil(t, err)
+	assert.NotEqual(t, key3, key4)
+
+	// Stage with input artifacts of unknown checksum is not cacheable.
+	wfr = newCacheWfr("wfr5")
+	_, err = StageCacheKey(client, cacheWf, wfr, "test")
+	assert.NotNil(t, err)
+
+	wfr.Status.Stages["build"].Artifacts = map[string]string{"bin": "checksum1"}
+	key5, err := StageCacheKey(client, cacheWf, wfr, "test")
+	assert.Nil(t, err)
+	wfr.Status.Stages["build"].Artifacts = map[string]string{"bin": "checksum2"}
+	key6, err := StageCacheKey(client, cacheWf, wfr, "test")
+	assert.Nil(t, err)
+	assert.NotEqual(t, key5, key6)
+}
+
+func TestRestoreFromCache(t *testing.T) {
+	resolveRevision = func(url, token, revision string) (string, error) {
+		return "1111111111111111111111111111111111111111", nil
+	}
+	defer func() { resolveRevision = git.ResolveRevision }()
+
+	client := fake.NewSimpleClientset(cacheStages[0], cacheStages[1], codeResource)
+	recorder := new(MockedRecorder)
+	recorder.On("Event", mock.Anything).Return()
+
+	// No results cached yet, cache key is recorded.
+	wfr1 := newCacheWfr("wfr1")
+	o := &operator{
+		client:   client,
+		recorder: recorder,
+		wf:       cacheWf,
+		wfr:      wfr1,
+	}
+	assert.False(t, o.restoreFromCache("build"))
+	cache := wfr1.Status.Stages["build"].Cache
+	assert.NotNil(t, cache)
+	assert.False(t, cache.Hit)
+
+	// Stage completed and results cached.
+	wfr1.Status.Stages["build"].Status.Status = v1alpha1.StatusCompleted
+	wfr1.Status.Stages["build"].Outputs = []v1alpha1.KeyValue{{Key: "version", Value: "v1"}}
+	o.UpdateStageArtifacts("build", map[string]string{"bin": "checksum"})
+	assert.Nil(t, o.SaveStageCache("build"))
+	cm, err := client.CoreV1().ConfigMaps("default").Get(cacheConfigMapName(cache.Key), metav1.GetOptions{})
+	assert.Nil(t, err)
+	_, err = time.Parse(time.RFC3339, cm.Annotations[common.StageCacheExpireAnnotationName])
+	assert.Nil(t, err)
+	cm.CreationTimestamp = metav1.Time{Time: time.Now()}
+	client.CoreV1().ConfigMaps("default").Update(cm)
+
+	// Results restored in another WorkflowRun.
+	wfr2 := newCacheWfr("wfr2")
+	o = &operator{
+		client:   client,
+		recorder: recorder,
+		wf:       cacheWf,
+		wfr:      wfr2,
+	}
+	assert.True(t, o.restoreFromCache("build"))
+	status := wfr2.Status.Stages["build"]
+	assert.Equal(t, v1alpha1.StatusCompleted, status.Status.Status)
+	assert.Equal(t, "CacheHit", status.Status.Reason)
+	assert.Equal(t, "wfr1", status.Cache.Source)
+	assert.True(t, status.Cache.Hit)
+	assert.Equal(t, cache.Key, status.Cache.Key)
+	assert.Equal(t, "checksum", status.Artifacts["bin"])
+	assert.Equal(t, "v1", status.Outputs[0].Value)
+
+	// Results restored from cache are not cached again.
+	assert.Nil(t, o.SaveStageCache("build"))
+
+	// Expired results are deleted.
+	cm.CreationTimestamp = metav1.Time{Time: time.Now().Add(-48 * time.Hour)}
+	client.CoreV1().ConfigMaps("default").Update(cm)
+	o.wfr = newCacheWfr("wfr3")
+	assert.False(t, o.restoreFromCache("build"))
+	_, err = client.CoreV1().ConfigMaps("default").Get(cacheConfigMapName(cache.Key), metav1.GetOptions{})
+	assert.NotNil(t, err)
+}
+
+func TestSweepCaches(t *testing.T) {
+	controller.Config = controller.WorkflowControllerConfig{PVC: "pvc1"}
+	defer func() {
+		controller.Config = controller.WorkflowControllerConfig{}
+	}()
+
+	newCacheConfigMap := func(key, expire string) *corev1.ConfigMap {
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      cacheConfigMapName(key),
+				Namespace: "default",
+				Labels: map[string]string{
+					common.StageCacheLabelName: "true",
+				},
+			},
+		}
+		if expire != "" {
+			cm.Annotations = map[string]string{
+				common.StageCacheExpireAnnotationName: expire,
+			}
+		}
+		return cm
+	}
+	client := fake.NewSimpleClientset(
+		newCacheConfigMap("expired", time.Now().Add(-time.Hour).Format(time.RFC3339)),
+		newCacheConfigMap("valid", time.Now().Add(time.Hour).Format(time.RFC3339)),
+		newCacheConfigMap("forever", ""),
+	)
+
+	assert.Nil(t, SweepCaches(client))
+	_, err := client.CoreV1().ConfigMaps("default").Get(cacheConfigMapName("expired"), metav1.GetOptions{})
+	assert.NotNil(t, err)
+	for _, key := range []string{"valid", "forever"} {
+		_, err := client.CoreV1().ConfigMaps("default").Get(cacheConfigMapName(key), metav1.GetOptions{})
+		assert.Nil(t, err, key)
+	}
+
+	// Cached artifacts in PVC are cleaned by GC pod.
+	pods, err := client.CoreV1().Pods("default").List(metav1.ListOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(pods.Items))
+	assert.Equal(t, []string{"rm", "-rf", common.GCDataPath + "/expired"}, pods.Items[0].Spec.Containers[0].Command)
+	assert.Equal(t, common.CachesPath(), pods.Items[0].Spec.Containers[0].VolumeMounts[0].SubPath)
+}
+
+func TestImageResourceRevision(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	resolveImageDigest = func(ref *registry.Reference, credentials map[string]registry.Credential, insecure bool) (string, error) {
+		assert.Equal(t, "cargo.caicloud.io/release/app:v1", ref.String())
+		return digest, nil
+	}
+	defer func() { resolveImageDigest = defaultResolveImageDigest }()
+
+	client := fake.NewSimpleClientset()
+	resource := &v1alpha1.Resource{
+		Spec: v1alpha1.ResourceSpec{
+			Type: v1alpha1.ImageResourceType,
+		},
+	}
+	revision, err := resourceRevision(client, "default", resource, map[string]string{"IMAGE": "cargo.caicloud.io/release/app:v1"})
+	assert.Nil(t, err)
+	assert.Equal(t, digest, revision)
+
+	// Image referenced by digest needs no resolving.
+	other := "sha256:" + strings.Repeat("b", 64)
+	revision, err = resourceRevision(client, "default", resource, map[string]string{"IMAGE": "alpine@" + other})
+	assert.Nil(t, err)
+	assert.Equal(t, other, revision)
+
+	_, err = resourceRevision(client, "default", resource, map[string]string{"IMAGE": "Invalid"})
+	assert.NotNil(t, err)
+}
+
+func TestSVNResourceRevision(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	resource := &v1alpha1.Resource{
+		Spec: v1alpha1.ResourceSpec{
+			Type: v1alpha1.SVNResourceType,
+		},
+	}
+	revision, err := resourceRevision(client, "default", resource, map[string]string{"SVN_REVISION": "1024"})
+	assert.Nil(t, err)
+	assert.Equal(t, "1024", revision)
+
+	for _, r := range []string{"", "HEAD", "{2018-10-10}"} {
+		_, err = resourceRevision(client, "default", resource, map[string]string{"SVN_REVISION": r})
+		assert.NotNil(t, err, r)
+	}
+}
@@ -0,0 +1,197 @@
+package workflowrun
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/k8s/clientset"
+	servercommon "github.com/caicloud/cyclone/pkg/server/common"
+	"github.com/caicloud/cyclone/pkg/workflow/cloudevents"
+	"github.com/caicloud/cyclone/pkg/workflow/common"
+	"github.com/caicloud/cyclone/pkg/workflow/controller"
+)
+
+// EventEmitter emits lifecycle events of WorkflowRuns as CloudEvents. Events are saved to the
+// outbox synchronously, and posted to sinks by the outbox worker, so that slow sinks don't block
+// the controller. Statuses already emitted are recorded in annotation of the WorkflowRun, so that
+// events are not emitted again after controller restarts.
+type EventEmitter struct {
+	client clientset.Interface
+	outbox *cloudevents.Outbox
+}
+
+// NewEventEmitter creates an event emitter, and starts the outbox worker to deliver events in
+// background. Events are saved in the namespace before delivery.
+func NewEventEmitter(client clientset.Interface, namespace string) *EventEmitter {
+	outbox := cloudevents.NewOutbox(client, namespace, func() *cloudevents.Config {
+		return &controller.Config.CloudEvents
+	})
+	outbox.Start()
+
+	return &EventEmitter{
+		client: client,
+		outbox: outbox,
+	}
+}
+
+// emittedState is the state of WorkflowRun that events have been emitted for.
+type emittedState struct {
+	Overall string            `json:"overall"`
+	Stages  map[string]string `json:"stages,omitempty"`
+}
+
+func currentState(wfr *v1alpha1.WorkflowRun) *emittedState {
+	state := &emittedState{Overall: wfr.Status.Overall.Status}
+	for stage, status := range wfr.Status.Stages {
+		if state.Stages == nil {
+			state.Stages = make(map[string]string)
+		}
+		state.Stages[stage] = status.Status.Status
+	}
+	return state
+}
+
+// Emit saves events for changes of the WorkflowRun since last emission to the outbox, it doesn't
+// wait for delivery.
+func (e *EventEmitter) Emit(wfr *v1alpha1.WorkflowRun) {
+	if !controller.Config.CloudEvents.Enabled() {
+		return
+	}
+	current, _ := json.Marshal(currentState(wfr))
+	if wfr.Annotations[common.CloudEventsAnnotationName] == string(current) {
+		return
+	}
+
+	// The WorkflowRun observed may be out of date, emit events with the latest one.
+	latest, err := e.client.CycloneV1alpha1().WorkflowRuns(wfr.Namespace).Get(wfr.Name, metav1.GetOptions{})
+	if err != nil {
+		log.WithField("wfr", wfr.Name).Warn("Get WorkflowRun error: ", err)
+		return
+	}
+	state := currentState(latest)
+	current, _ = json.Marshal(state)
+	recorded, ok := latest.Annotations[common.CloudEventsAnnotationName]
+	if recorded == string(current) {
+		return
+	}
+
+	var emitted *emittedState
+	if ok {
+		emitted = &emittedState{}
+		if err := json.Unmarshal([]byte(recorded), emitted); err != nil {
+			log.WithField("wfr", wfr.Name).Warn("Invalid emitted state: ", err)
+		}
+	} else if terminated(state.Overall) {
+		// WorkflowRuns terminated before events are enabled.
+		return
+	}
+
+	events, err := lifecycleEvents(latest, emitted, state)
+	if err != nil {
+		log.WithField("wfr", wfr.Name).Warn("Generate CloudEvents error: ", err)
+		return
+	}
+	if err := e.outbox.Add(events...); err != nil {
+		log.WithField("wfr", wfr.Name).Warn("Save CloudEvents to outbox error: ", err)
+		return
+	}
+	if err := setAnnotation(e.client, latest, common.CloudEventsAnnotationName, string(current)); err != nil {
+		log.WithField("wfr", wfr.Name).Warn("Record emitted CloudEvents error: ", err)
+	}
+}
+
+// lifecycleEvents generates events for changes from the emitted state to the current state,
+// emitted is nil if no event has been emitted for the WorkflowRun.
+func lifecycleEvents(wfr *v1alpha1.WorkflowRun, emitted, current *emittedState) ([]*cloudevents.Event, error) {
+	data := eventData(wfr)
+	source := fmt.Sprintf("/tenants/%s/projects/%s/workflows/%s", data.Tenant, data.Project, data.Workflow)
+	// Events are identified by the WorkflowRun, the status and when it changed.
+	key := func(status *v1alpha1.Status) string {
+		return fmt.Sprintf("%s/%s/%s", wfr.UID, status.Status, strconv.FormatInt(status.LastTransitionTime.Unix(), 10))
+	}
+
+	var events []*cloudevents.Event
+	add := func(eventType, subject, key string, data *cloudevents.WorkflowRunData) error {
+		event, err := cloudevents.NewEvent(eventType, source, subject, key, data)
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+		return nil
+	}
+
+	if emitted == nil {
+		if err := add(cloudevents.WorkflowRunCreated, wfr.Name, string(wfr.UID), data); err != nil {
+			return nil, err
+		}
+		emitted = &emittedState{}
+	}
+
+	overall := &wfr.Status.Overall
+	changed := current.Overall != emitted.Overall
+	if changed && current.Overall == v1alpha1.StatusRunning && emitted.Overall != v1alpha1.StatusWaiting {
+		if err := add(cloudevents.WorkflowRunStarted, wfr.Name, key(overall), data); err != nil {
+			return nil, err
+		}
+	}
+
+	var stages []string
+	for stage, status := range current.Stages {
+		if emitted.Stages[stage] != status {
+			stages = append(stages, stage)
+		}
+	}
+	sort.Strings(stages)
+	for _, stage := range stages {
+		status := &wfr.Status.Stages[stage].Status
+		stageData := *data
+		stageData.Stage = &cloudevents.StageData{
+			Name:    stage,
+			Status:  status.Status,
+			Message: status.Message,
+		}
+		if err := add(cloudevents.WorkflowRunStageChanged, wfr.Name+"/stages/"+stage, key(status), &stageData); err != nil {
+			return nil, err
+		}
+	}
+
+	if changed && current.Overall == v1alpha1.StatusWaiting {
+		if err := add(cloudevents.WorkflowRunWaiting, wfr.Name, key(overall), data); err != nil {
+			return nil, err
+		}
+	}
+	if changed && terminated(current.Overall) {
+		if err := add(cloudevents.WorkflowRunFinished, wfr.Name, key(overall), data); err != nil {
+			return nil, err
+		}
+	}
+
+	return events, nil
+}
+
+// eventData gets data of lifecycle events of the WorkflowRun.
+func eventData(wfr *v1alpha1.WorkflowRun) *cloudevents.WorkflowRunData {
+	data := &cloudevents.WorkflowRunData{
+		Tenant:      servercommon.NamespaceTenant(wfr.Namespace),
+		Project:     wfr.Labels[servercommon.LabelProject],
+		Workflow:    workflowName(wfr),
+		WorkflowRun: wfr.Name,
+		Trigger:     wfr.Labels[common.WorkflowTriggerLabelName],
+		Status:      wfr.Status.Overall.Status,
+		Message:     wfr.Status.Overall.Message,
+		Resources:   wfr.Status.Resources,
+	}
+	for stage, status := range wfr.Status.Stages {
+		if data.Stages == nil {
+			data.Stages = make(map[string]string)
+		}
+		data.Stages[stage] = status.Status.Status
+	}
+	return data
+}
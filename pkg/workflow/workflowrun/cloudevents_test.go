@@ -0,0 +1,172 @@
+package workflowrun
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/k8s/clientset/fake"
+	"github.com/caicloud/cyclone/pkg/workflow/cloudevents"
+	"github.com/caicloud/cyclone/pkg/workflow/common"
+	"github.com/caicloud/cyclone/pkg/workflow/controller"
+)
+
+func eventTypes(events []*cloudevents.Event) []string {
+	var types []string
+	for _, e := range events {
+		types = append(types, e.Type+" "+e.Subject)
+	}
+	return types
+}
+
+func TestLifecycleEvents(t *testing.T) {
+	wfr := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "wfr", Namespace: "cyclone--t1", UID: "uid", Labels: map[string]string{"cyclone.io/project": "p1"}},
+		Spec:       v1alpha1.WorkflowRunSpec{WorkflowRef: &corev1.ObjectReference{Name: "wf"}},
+		Status: v1alpha1.WorkflowRunStatus{
+			Overall: v1alpha1.Status{Status: v1alpha1.StatusRunning},
+			Stages: map[string]*v1alpha1.StageStatus{
+				"build": {Status: v1alpha1.Status{Status: v1alpha1.StatusRunning}},
+			},
+			Resources: map[string]*v1alpha1.ResourceStatus{
+				"src": {Type: v1alpha1.GitResourceType, Revision: "abc"},
+			},
+		},
+	}
+
+	events, err := lifecycleEvents(wfr, nil, currentState(wfr))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		cloudevents.WorkflowRunCreated + " wfr",
+		cloudevents.WorkflowRunStarted + " wfr",
+		cloudevents.WorkflowRunStageChanged + " wfr/stages/build",
+	}, eventTypes(events))
+	assert.Equal(t, "/tenants/t1/projects/p1/workflows/wf", events[0].Source)
+	data := &cloudevents.WorkflowRunData{}
+	assert.Nil(t, json.Unmarshal(events[2].Data, data))
+	assert.Equal(t, &cloudevents.StageData{Name: "build", Status: v1alpha1.StatusRunning}, data.Stage)
+	assert.Equal(t, "abc", data.Resources["src"].Revision)
+
+	emitted := currentState(wfr)
+	wfr.Status.Stages["build"].Status.Status = v1alpha1.StatusCompleted
+	wfr.Status.Stages["approve"] = &v1alpha1.StageStatus{Status: v1alpha1.Status{Status: v1alpha1.StatusWaiting}}
+	wfr.Status.Overall.Status = v1alpha1.StatusWaiting
+	events, err = lifecycleEvents(wfr, emitted, currentState(wfr))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		cloudevents.WorkflowRunStageChanged + " wfr/stages/approve",
+		cloudevents.WorkflowRunStageChanged + " wfr/stages/build",
+		cloudevents.WorkflowRunWaiting + " wfr",
+	}, eventTypes(events))
+
+	// Resumed from waiting, it's not regarded as started.
+	emitted = currentState(wfr)
+	wfr.Status.Overall.Status = v1alpha1.StatusRunning
+	events, err = lifecycleEvents(wfr, emitted, currentState(wfr))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(events))
+
+	emitted = currentState(wfr)
+	wfr.Status.Stages["approve"].Status.Status = v1alpha1.StatusError
+	wfr.Status.Overall.Status = v1alpha1.StatusError
+	events, err = lifecycleEvents(wfr, emitted, currentState(wfr))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		cloudevents.WorkflowRunStageChanged + " wfr/stages/approve",
+		cloudevents.WorkflowRunFinished + " wfr",
+	}, eventTypes(events))
+}
+
+func TestEventEmitter(t *testing.T) {
+	controller.Config.CloudEvents = cloudevents.Config{Sinks: []cloudevents.Sink{{URL: "http://127.0.0.1:0"}}}
+	defer func() { controller.Config.CloudEvents = cloudevents.Config{} }()
+
+	wfr := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "wfr", Namespace: "cyclone--t1"},
+		Spec:       v1alpha1.WorkflowRunSpec{WorkflowRef: &corev1.ObjectReference{Name: "wf"}},
+	}
+	terminated := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "old", Namespace: "cyclone--t1"},
+		Status:     v1alpha1.WorkflowRunStatus{Overall: v1alpha1.Status{Status: v1alpha1.StatusCompleted}},
+	}
+	client := fake.NewSimpleClientset(wfr, terminated)
+	emitter := &EventEmitter{
+		client: client,
+		outbox: cloudevents.NewOutbox(client, "cyclone-system", func() *cloudevents.Config { return &cloudevents.Config{} }),
+	}
+	outbox := func() int {
+		cms, err := client.CoreV1().ConfigMaps("cyclone-system").List(metav1.ListOptions{})
+		assert.Nil(t, err)
+		return len(cms.Items)
+	}
+
+	emitter.Emit(wfr)
+	assert.Equal(t, 1, outbox())
+	latest, err := client.CycloneV1alpha1().WorkflowRuns("cyclone--t1").Get("wfr", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"overall":""}`, latest.Annotations[common.CloudEventsAnnotationName])
+
+	// The observed WorkflowRun is out of date, events are not emitted again.
+	emitter.Emit(wfr)
+	assert.Equal(t, 1, outbox())
+
+	latest.Status.Overall.Status = v1alpha1.StatusRunning
+	_, err = client.CycloneV1alpha1().WorkflowRuns("cyclone--t1").Update(latest)
+	assert.Nil(t, err)
+	emitter.Emit(latest)
+	assert.Equal(t, 2, outbox())
+
+	// WorkflowRuns terminated before events enabled are skipped.
+	emitter.Emit(terminated)
+	assert.Equal(t, 2, outbox())
+}
+
+func TestEventEmitterNotBlockedBySinks(t *testing.T) {
+	received := make(chan struct{}, 1)
+	release := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-release
+	}))
+	defer s.Close()
+
+	config := cloudevents.Config{Sinks: []cloudevents.Sink{{URL: s.URL}}}
+	controller.Config.CloudEvents = config
+	defer func() { controller.Config.CloudEvents = cloudevents.Config{} }()
+
+	wfr := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "wfr", Namespace: "cyclone--t1"},
+		Spec:       v1alpha1.WorkflowRunSpec{WorkflowRef: &corev1.ObjectReference{Name: "wf"}},
+	}
+	client := fake.NewSimpleClientset(wfr)
+	emitter := &EventEmitter{
+		client: client,
+		outbox: cloudevents.NewOutbox(client, "cyclone-system", func() *cloudevents.Config { return &config }),
+	}
+	emitter.outbox.Start()
+	outbox := func() int {
+		cms, err := client.CoreV1().ConfigMaps("cyclone-system").List(metav1.ListOptions{})
+		assert.Nil(t, err)
+		return len(cms.Items)
+	}
+
+	// Events are saved to the outbox, Emit doesn't wait for the sink.
+	emitter.Emit(wfr)
+	assert.Equal(t, 1, outbox())
+	<-received
+
+	// The worker delivers the event and removes it from the outbox.
+	close(release)
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return outbox() == 0, nil
+	})
+	assert.Nil(t, err)
+}
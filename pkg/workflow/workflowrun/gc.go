@@ -69,12 +69,20 @@ func (p *GCProcessor) Enable() {
 	go p.run()
 }
 
+// cacheSweepInterval is interval to sweep expired stage caches.
+const cacheSweepInterval = time.Minute * 10
+
 func (p *GCProcessor) run() {
 	ticker := time.NewTicker(time.Second * 5)
+	sweepTicker := time.NewTicker(cacheSweepInterval)
 	for {
 		select {
 		case <-ticker.C:
 			p.process()
+		case <-sweepTicker.C:
+			if err := SweepCaches(p.client); err != nil {
+				log.Warn("Sweep expired stage caches error: ", err)
+			}
 		}
 	}
 }
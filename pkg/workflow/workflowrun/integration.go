@@ -0,0 +1,134 @@
+package workflowrun
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/caicloud/cyclone/pkg/k8s/clientset"
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/workflow/common"
+	"github.com/caicloud/cyclone/pkg/workflow/resolver"
+	s3resolver "github.com/caicloud/cyclone/pkg/workflow/resolver/s3"
+)
+
+// GetIntegration gets the integration with the given name in the namespace.
+func GetIntegration(client clientset.Interface, namespace, name string) (*api.Integration, error) {
+	secret, err := client.CoreV1().Secrets(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	integration := &api.Integration{}
+	if err := json.Unmarshal(secret.Data[common.IntegrationSecretKey], integration); err != nil {
+		return nil, fmt.Errorf("unmarshal integration %s error: %v", name, err)
+	}
+	return integration, nil
+}
+
+// IntegrationEnvs converts credentials of the integration to environment variables of resource
+// resolvers. ObjectStorage integrations are converted to S3 resolver parameters, and Repository,
+// DockerRegistry and SCM integrations are converted to AUTH_USERNAME, AUTH_PASSWORD and
+// AUTH_TOKEN. Items of General integrations are used as they are.
+func IntegrationEnvs(integration *api.Integration) (map[string]string, error) {
+	envs := make(map[string]string)
+	set := func(name, value string) {
+		if value != "" {
+			envs[name] = value
+		}
+	}
+
+	spec := integration.Spec
+	switch {
+	case spec.Type == api.ObjectStorage && spec.ObjectStorage != nil:
+		set(s3resolver.EnvEndpoint, spec.ObjectStorage.Endpoint)
+		set(s3resolver.EnvRegion, spec.ObjectStorage.Region)
+		set(s3resolver.EnvAccessKey, spec.ObjectStorage.AccessKey)
+		set(s3resolver.EnvSecretKey, spec.ObjectStorage.SecretKey)
+		if spec.ObjectStorage.Insecure {
+			set(s3resolver.EnvInsecure, "true")
+		}
+	case spec.Type == api.Repository && spec.Repository != nil:
+		set(resolver.EnvAuthUsername, spec.Repository.User)
+		set(resolver.EnvAuthPassword, spec.Repository.Password)
+		set(resolver.EnvAuthToken, spec.Repository.Token)
+	case spec.Type == api.DockerRegistry && spec.DockerRegistry != nil:
+		set(resolver.EnvAuthUsername, spec.DockerRegistry.User)
+		set(resolver.EnvAuthPassword, spec.DockerRegistry.Password)
+	case spec.Type == api.SCM && spec.SCM != nil:
+		set(resolver.EnvAuthUsername, spec.SCM.User)
+		set(resolver.EnvAuthPassword, spec.SCM.Password)
+		set(resolver.EnvAuthToken, spec.SCM.Token)
+	case spec.Type == api.GeneralIntegration:
+		for _, item := range spec.General {
+			set(item.Name, item.Value)
+		}
+	default:
+		return nil, fmt.Errorf("integration %s of type %s can't be used by resources", integration.Metadata.Name, spec.Type)
+	}
+
+	return envs, nil
+}
+
+// EnsureIntegrationSecret generates secret holding environment variables converted from the
+// integration, see IntegrationEnvs. The secret is injected into resource resolvers with envFrom,
+// name of the secret is returned.
+func EnsureIntegrationSecret(client clientset.Interface, namespace, name string) (string, error) {
+	integration, err := GetIntegration(client, namespace, name)
+	if err != nil {
+		log.WithField("ns", namespace).WithField("integration", name).Error("Get integration error: ", err)
+		return "", err
+	}
+	envs, err := IntegrationEnvs(integration)
+	if err != nil {
+		return "", err
+	}
+
+	data := make(map[string][]byte)
+	for k, v := range envs {
+		data[k] = []byte(v)
+	}
+
+	secretName := common.IntegrationSecretPrefix + name
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		origin, err := client.CoreV1().Secrets(namespace).Get(secretName, metav1.GetOptions{})
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				return err
+			}
+
+			_, err = client.CoreV1().Secrets(namespace).Create(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      secretName,
+					Namespace: namespace,
+					Labels: map[string]string{
+						common.WorkflowLabelName: "true",
+					},
+				},
+				Data: data,
+			})
+			return err
+		}
+
+		if reflect.DeepEqual(origin.Data, data) {
+			return nil
+		}
+
+		secret := origin.DeepCopy()
+		secret.Data = data
+		_, err = client.CoreV1().Secrets(namespace).Update(secret)
+		return err
+	})
+	if err != nil {
+		log.WithField("ns", namespace).Error("Ensure integration secret error: ", err)
+		return "", err
+	}
+
+	return secretName, nil
+}
@@ -0,0 +1,176 @@
+package workflowrun
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/k8s/clientset/fake"
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/workflow/common"
+)
+
+func integrationSecret(namespace string, integration *api.Integration) *corev1.Secret {
+	data, _ := json.Marshal(integration)
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      integration.Metadata.Name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				common.IntegrationTypeLabelName: string(integration.Spec.Type),
+			},
+		},
+		Data: map[string][]byte{
+			common.IntegrationSecretKey: data,
+		},
+	}
+}
+
+func TestIntegrationEnvs(t *testing.T) {
+	cases := []struct {
+		spec     api.IntegrationSpec
+		expected map[string]string
+	}{
+		{
+			spec: api.IntegrationSpec{
+				Type: api.ObjectStorage,
+				IntegrationSource: api.IntegrationSource{
+					ObjectStorage: &api.ObjectStorageSource{
+						Endpoint:  "minio:9000",
+						AccessKey: "ak",
+						SecretKey: "sk",
+						Insecure:  true,
+					},
+				},
+			},
+			expected: map[string]string{
+				"S3_ENDPOINT":   "minio:9000",
+				"S3_ACCESS_KEY": "ak",
+				"S3_SECRET_KEY": "sk",
+				"S3_INSECURE":   "true",
+			},
+		},
+		{
+			spec: api.IntegrationSpec{
+				Type: api.Repository,
+				IntegrationSource: api.IntegrationSource{
+					Repository: &api.RepositorySource{
+						Server:   "https://nexus.example.com",
+						User:     "admin",
+						Password: "secret",
+					},
+				},
+			},
+			expected: map[string]string{
+				"AUTH_USERNAME": "admin",
+				"AUTH_PASSWORD": "secret",
+			},
+		},
+		{
+			spec: api.IntegrationSpec{
+				Type: api.GeneralIntegration,
+				IntegrationSource: api.IntegrationSource{
+					General: []api.ParameterItem{{Name: "AUTH_TOKEN", Value: "token"}},
+				},
+			},
+			expected: map[string]string{
+				"AUTH_TOKEN": "token",
+			},
+		},
+		{
+			spec: api.IntegrationSpec{
+				Type: api.Cluster,
+				IntegrationSource: api.IntegrationSource{
+					Cluster: &api.ClusterSource{},
+				},
+			},
+		},
+		{
+			// Type mismatch
+			spec: api.IntegrationSpec{
+				Type: api.ObjectStorage,
+			},
+		},
+	}
+
+	for i, c := range cases {
+		envs, err := IntegrationEnvs(&api.Integration{Spec: c.spec})
+		if c.expected == nil {
+			assert.NotNil(t, err, "case %d", i)
+			continue
+		}
+		assert.Nil(t, err, "case %d", i)
+		assert.Equal(t, c.expected, envs, "case %d", i)
+	}
+}
+
+func TestEnsureIntegrationSecret(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	_, err := EnsureIntegrationSecret(client, "ns", "nexus")
+	assert.NotNil(t, err)
+
+	integration := &api.Integration{
+		Metadata: api.Metadata{
+			Name: "nexus",
+		},
+		Spec: api.IntegrationSpec{
+			Type: api.Repository,
+			IntegrationSource: api.IntegrationSource{
+				Repository: &api.RepositorySource{
+					Token: "t1",
+				},
+			},
+		},
+	}
+	client = fake.NewSimpleClientset(integrationSecret("ns", integration))
+	name, err := EnsureIntegrationSecret(client, "ns", "nexus")
+	assert.Nil(t, err)
+	assert.Equal(t, common.IntegrationSecretPrefix+"nexus", name)
+	s, err := client.CoreV1().Secrets("ns").Get(name, metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"AUTH_TOKEN": []byte("t1")}, s.Data)
+
+	// Integration changed, secret should be updated.
+	integration.Spec.Repository.Token = "t2"
+	_, err = client.CoreV1().Secrets("ns").Update(integrationSecret("ns", integration))
+	assert.Nil(t, err)
+	_, err = EnsureIntegrationSecret(client, "ns", "nexus")
+	assert.Nil(t, err)
+	s, err = client.CoreV1().Secrets("ns").Get(name, metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"AUTH_TOKEN": []byte("t2")}, s.Data)
+}
+
+func TestInjectIntegration(t *testing.T) {
+	client := fake.NewSimpleClientset(integrationSecret("ns", &api.Integration{
+		Metadata: api.Metadata{
+			Name: "minio",
+		},
+		Spec: api.IntegrationSpec{
+			Type: api.ObjectStorage,
+			IntegrationSource: api.IntegrationSource{
+				ObjectStorage: &api.ObjectStorageSource{
+					Endpoint: "minio:9000",
+				},
+			},
+		},
+	}))
+	builder := NewPodBuilder(client, wf, &v1alpha1.WorkflowRun{ObjectMeta: metav1.ObjectMeta{Name: "wfr", Namespace: "ns"}}, "stage1")
+
+	container := &corev1.Container{}
+	assert.Nil(t, builder.injectIntegration(container, ""))
+	assert.Nil(t, container.EnvFrom)
+	assert.NotNil(t, builder.injectIntegration(container, "not-exist"))
+	assert.Nil(t, builder.injectIntegration(container, "minio"))
+	assert.Equal(t, []corev1.EnvFromSource{{
+		SecretRef: &corev1.SecretEnvSource{
+			LocalObjectReference: corev1.LocalObjectReference{
+				Name: common.IntegrationSecretPrefix + "minio",
+			},
+		},
+	}}, container.EnvFrom)
+}
@@ -0,0 +1,182 @@
+package workflowrun
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/k8s/clientset"
+	servercommon "github.com/caicloud/cyclone/pkg/server/common"
+	"github.com/caicloud/cyclone/pkg/workflow/common"
+	"github.com/caicloud/cyclone/pkg/workflow/notification"
+)
+
+// Notifier sends notifications of terminated WorkflowRuns by notification rules of their
+// Workflows. Delivery results are recorded as events of the WorkflowRuns.
+type Notifier struct {
+	client   clientset.Interface
+	recorder record.EventRecorder
+	queue    *retryQueue
+	// sent records rules that have been sent for each WorkflowRun key, so that they are not sent
+	// again when other rules are retried. It's only accessed by the queue worker.
+	sent map[string]map[string]bool
+}
+
+// NewNotifier creates a notifier, and starts the worker to send notifications in background.
+func NewNotifier(client clientset.Interface) *Notifier {
+	notifier := newNotifier(client)
+	go notifier.queue.run()
+	return notifier
+}
+
+func newNotifier(client clientset.Interface) *Notifier {
+	notifier := &Notifier{
+		client:   client,
+		recorder: common.GetEventRecorder(client, common.EventSourceWfrController),
+		sent:     make(map[string]map[string]bool),
+	}
+	notifier.queue = newRetryQueue(notifier.process)
+	return notifier
+}
+
+// Notify queues the WorkflowRun to send notifications if it has terminated. Notifications are
+// sent in background, so that slow receivers don't block the controller.
+func (n *Notifier) Notify(wfr *v1alpha1.WorkflowRun) {
+	if !terminated(wfr.Status.Overall.Status) || wfr.Annotations[common.NotifiedAnnotationName] != "" || wfr.Spec.WorkflowRef == nil {
+		return
+	}
+	n.queue.Add(wfr)
+}
+
+// process sends notifications of the latest WorkflowRun. Failed deliveries are retried, and
+// rules already sent are skipped in retries. Notifications of a WorkflowRun are sent only once,
+// it's recorded in annotation of the WorkflowRun after all notifications are delivered or
+// retries are exhausted.
+func (n *Notifier) process(key string, lastAttempt bool) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return nil
+	}
+	// The WorkflowRun observed may be out of date, check the latest one to avoid notifying twice.
+	wfr, err := n.client.CycloneV1alpha1().WorkflowRuns(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			delete(n.sent, key)
+			return nil
+		}
+		log.WithField("wfr", name).Warn("Get WorkflowRun error: ", err)
+		return err
+	}
+	status := wfr.Status.Overall.Status
+	if !terminated(status) || wfr.Annotations[common.NotifiedAnnotationName] != "" || wfr.Spec.WorkflowRef == nil {
+		delete(n.sent, key)
+		return nil
+	}
+
+	wf, err := n.client.CycloneV1alpha1().Workflows(wfr.Namespace).Get(wfr.Spec.WorkflowRef.Name, metav1.GetOptions{})
+	if err != nil {
+		log.WithField("wfr", wfr.Name).Warn("Get Workflow error: ", err)
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if len(wf.Spec.Notifications) == 0 {
+		return nil
+	}
+
+	if n.sent[key] == nil {
+		n.sent[key] = make(map[string]bool)
+	}
+	var failed bool
+	data := notificationData(wfr)
+	for _, rule := range wf.Spec.Notifications {
+		if !matchStatus(rule.Statuses, status) || n.sent[key][rule.Name] {
+			continue
+		}
+		if err := n.send(wfr, &rule, data); err != nil {
+			failed = true
+			log.WithField("wfr", wfr.Name).WithField("rule", rule.Name).Warn("Send notification error: ", err)
+			if lastAttempt {
+				n.recorder.Eventf(wfr, corev1.EventTypeWarning, "NotificationFailed", "Send notification '%s' to %s error: %v", rule.Name, rule.Integration, err)
+			}
+			continue
+		}
+		n.sent[key][rule.Name] = true
+		n.recorder.Eventf(wfr, corev1.EventTypeNormal, "NotificationSent", "Notification '%s' sent to %s", rule.Name, rule.Integration)
+	}
+	if failed && !lastAttempt {
+		return fmt.Errorf("send notifications of %s failed", key)
+	}
+
+	if err := setAnnotation(n.client, wfr, common.NotifiedAnnotationName, status); err != nil {
+		log.WithField("wfr", wfr.Name).Warn("Record notified WorkflowRun error: ", err)
+		return err
+	}
+	delete(n.sent, key)
+	return nil
+}
+
+// send renders the message of the rule and sends it to the integration.
+func (n *Notifier) send(wfr *v1alpha1.WorkflowRun, rule *v1alpha1.NotificationRule, data *notification.Data) error {
+	integration, err := GetIntegration(n.client, wfr.Namespace, rule.Integration)
+	if err != nil {
+		return err
+	}
+	sender, err := notification.NewSender(integration)
+	if err != nil {
+		return err
+	}
+	msg, err := notification.NewMessage(rule.Template, data)
+	if err != nil {
+		return err
+	}
+	return sender.Send(msg)
+}
+
+// notificationData gets result of the WorkflowRun to render notification messages.
+func notificationData(wfr *v1alpha1.WorkflowRun) *notification.Data {
+	data := &notification.Data{
+		Tenant:      servercommon.NamespaceTenant(wfr.Namespace),
+		Project:     wfr.Labels[servercommon.LabelProject],
+		Workflow:    workflowName(wfr),
+		WorkflowRun: wfr.Name,
+		Trigger:     wfr.Labels[common.WorkflowTriggerLabelName],
+		Status:      wfr.Status.Overall.Status,
+		Message:     wfr.Status.Overall.Message,
+		StartTime:   wfr.CreationTimestamp.Time,
+		EndTime:     wfr.Status.Overall.LastTransitionTime.Time,
+		Stages:      make(map[string]string),
+		URL:         runURL(wfr),
+	}
+	data.Duration = data.EndTime.Sub(data.StartTime).Round(time.Second).String()
+	for stage, status := range wfr.Status.Stages {
+		data.Stages[stage] = status.Status.Status
+	}
+	return data
+}
+
+// terminated checks whether the status is a terminal status of WorkflowRun.
+func terminated(status string) bool {
+	return status == v1alpha1.StatusCompleted || status == v1alpha1.StatusError || status == v1alpha1.StatusCancelled
+}
+
+// matchStatus checks whether the status is one of the statuses, empty statuses match all.
+func matchStatus(statuses []string, status string) bool {
+	if len(statuses) == 0 {
+		return true
+	}
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
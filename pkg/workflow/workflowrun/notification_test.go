@@ -0,0 +1,98 @@
+package workflowrun
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/k8s/clientset/fake"
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/workflow/common"
+)
+
+func TestNotifier(t *testing.T) {
+	var texts []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := make(map[string]string)
+		data, _ := ioutil.ReadAll(r.Body)
+		assert.Nil(t, json.Unmarshal(data, &body))
+		texts = append(texts, body["text"])
+	}))
+	defer s.Close()
+
+	wfr := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "wfr", Namespace: "cyclone--t1"},
+		Spec: v1alpha1.WorkflowRunSpec{
+			WorkflowRef: &corev1.ObjectReference{Name: "wf"},
+		},
+		Status: v1alpha1.WorkflowRunStatus{
+			Overall: v1alpha1.Status{Status: v1alpha1.StatusRunning},
+		},
+	}
+	client := fake.NewSimpleClientset(
+		wfr,
+		&v1alpha1.Workflow{
+			ObjectMeta: metav1.ObjectMeta{Name: "wf", Namespace: "cyclone--t1"},
+			Spec: v1alpha1.WorkflowSpec{
+				Notifications: []v1alpha1.NotificationRule{
+					{Name: "failure", Statuses: []string{v1alpha1.StatusError}, Integration: "slack", Template: "{{.WorkflowRun}} failed"},
+					{Name: "all", Integration: "slack", Template: "{{.WorkflowRun}} {{.Status}}"},
+					{Name: "missing", Integration: "missing"},
+				},
+			},
+		},
+		integrationSecret("cyclone--t1", &api.Integration{
+			Metadata: api.Metadata{Name: "slack"},
+			Spec: api.IntegrationSpec{
+				Type:              api.Slack,
+				IntegrationSource: api.IntegrationSource{Slack: &api.SlackSource{URL: s.URL}},
+			},
+		}),
+	)
+	notifier := newNotifier(client)
+	notify := func(wfr *v1alpha1.WorkflowRun) {
+		notifier.Notify(wfr)
+		for notifier.queue.queue.Len() > 0 {
+			notifier.queue.processNext()
+		}
+	}
+
+	// Not terminated
+	notify(wfr)
+	assert.Equal(t, 0, len(texts))
+
+	// Notification 'missing' fails and is retried, 'all' is not sent again.
+	wfr.Status.Overall.Status = v1alpha1.StatusCompleted
+	_, err := client.CycloneV1alpha1().WorkflowRuns("cyclone--t1").Update(wfr)
+	assert.Nil(t, err)
+	notify(wfr)
+	assert.Equal(t, []string{"wfr Completed"}, texts)
+	latest, err := client.CycloneV1alpha1().WorkflowRuns("cyclone--t1").Get("wfr", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "", latest.Annotations[common.NotifiedAnnotationName])
+	assert.Equal(t, 1, notifier.queue.queue.NumRequeues("cyclone--t1/wfr"))
+
+	// Give up after retries.
+	assert.Nil(t, notifier.process("cyclone--t1/wfr", true))
+	assert.Equal(t, []string{"wfr Completed"}, texts)
+	latest, err = client.CycloneV1alpha1().WorkflowRuns("cyclone--t1").Get("wfr", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, v1alpha1.StatusCompleted, latest.Annotations[common.NotifiedAnnotationName])
+
+	// Already notified, the WorkflowRun observed is out of date.
+	notify(wfr)
+	assert.Equal(t, 1, len(texts))
+}
+
+func TestMatchStatus(t *testing.T) {
+	assert.True(t, matchStatus(nil, v1alpha1.StatusCancelled))
+	assert.True(t, matchStatus([]string{v1alpha1.StatusError, v1alpha1.StatusCancelled}, v1alpha1.StatusCancelled))
+	assert.False(t, matchStatus([]string{v1alpha1.StatusError}, v1alpha1.StatusCompleted))
+}
@@ -29,6 +29,16 @@ type Operator interface {
 	UpdateStageStatus(stage string, status *v1alpha1.Status)
 	// Update stage pod info.
 	UpdateStagePodInfo(stage string, podInfo *v1alpha1.PodInfo)
+	// Update key-value outputs of stage, outputs with the same key are replaced.
+	UpdateStageOutputs(stage string, outputs []v1alpha1.KeyValue)
+	// Update checksums of stage output artifacts.
+	UpdateStageArtifacts(stage string, artifacts map[string]string)
+	// Update resource usage of containers in the stage pod.
+	UpdateStageUsage(stage string, usage map[string]v1alpha1.ContainerUsage)
+	// Record version of an input resource resolved in the stage from outputs of its resolver.
+	UpdateResourceStatus(stage, resource string, outputs map[string]string) error
+	// Save results of a completed stage to cache if cache enabled for it.
+	SaveStageCache(stage string) error
 	// Decide overall status of the WorkflowRun from stage status.
 	OverallStatus() (*v1alpha1.Status, error)
 	// Garbage collection on the WorkflowRun based on GC policy configured
@@ -140,6 +150,23 @@ func (o *operator) Update() error {
 			if len(s.Outputs) == 0 {
 				combined.Status.Stages[stage].Outputs = status.Outputs
 			}
+			if len(s.Artifacts) == 0 {
+				combined.Status.Stages[stage].Artifacts = status.Artifacts
+			}
+			if len(s.Usage) == 0 {
+				combined.Status.Stages[stage].Usage = status.Usage
+			}
+			if s.Cache == nil || (status.Cache != nil && status.Cache.Hit) {
+				combined.Status.Stages[stage].Cache = status.Cache
+			}
+		}
+		for resource, status := range o.wfr.Status.Resources {
+			if combined.Status.Resources == nil {
+				combined.Status.Resources = make(map[string]*v1alpha1.ResourceStatus)
+			}
+			if _, ok := combined.Status.Resources[resource]; !ok {
+				combined.Status.Resources[resource] = status
+			}
 		}
 
 		if !reflect.DeepEqual(staticStatus(&latest.Status), staticStatus(&combined.Status)) ||
@@ -188,6 +215,88 @@ func (o *operator) UpdateStagePodInfo(stage string, podInfo *v1alpha1.PodInfo) {
 	o.wfr.Status.Stages[stage].Pod = podInfo
 }
 
+// UpdateStageOutputs updates key-value outputs of stage to WorkflowRun, outputs with the same key
+// are replaced.
+func (o *operator) UpdateStageOutputs(stage string, outputs []v1alpha1.KeyValue) {
+	if o.wfr.Status.Stages == nil {
+		o.wfr.Status.Stages = make(map[string]*v1alpha1.StageStatus)
+	}
+
+	if _, ok := o.wfr.Status.Stages[stage]; !ok {
+		o.wfr.Status.Stages[stage] = &v1alpha1.StageStatus{}
+	}
+
+	status := o.wfr.Status.Stages[stage]
+	for _, output := range outputs {
+		replaced := false
+		for i := range status.Outputs {
+			if status.Outputs[i].Key == output.Key {
+				status.Outputs[i].Value = output.Value
+				replaced = true
+			}
+		}
+		if !replaced {
+			status.Outputs = append(status.Outputs, output)
+		}
+	}
+}
+
+// UpdateStageArtifacts updates checksums of stage output artifacts to WorkflowRun.
+func (o *operator) UpdateStageArtifacts(stage string, artifacts map[string]string) {
+	if o.wfr.Status.Stages == nil {
+		o.wfr.Status.Stages = make(map[string]*v1alpha1.StageStatus)
+	}
+
+	if _, ok := o.wfr.Status.Stages[stage]; !ok {
+		o.wfr.Status.Stages[stage] = &v1alpha1.StageStatus{}
+	}
+
+	o.wfr.Status.Stages[stage].Artifacts = artifacts
+}
+
+// UpdateStageUsage updates resource usage of containers in the stage pod to WorkflowRun.
+func (o *operator) UpdateStageUsage(stage string, usage map[string]v1alpha1.ContainerUsage) {
+	if o.wfr.Status.Stages == nil {
+		o.wfr.Status.Stages = make(map[string]*v1alpha1.StageStatus)
+	}
+
+	if _, ok := o.wfr.Status.Stages[stage]; !ok {
+		o.wfr.Status.Stages[stage] = &v1alpha1.StageStatus{}
+	}
+
+	o.wfr.Status.Stages[stage].Usage = usage
+}
+
+// UpdateResourceStatus records version of an input resource resolved in the stage. A resource
+// may be used as input in several stages, only the version resolved first is recorded.
+func (o *operator) UpdateResourceStatus(stage, resource string, outputs map[string]string) error {
+	if _, ok := o.wfr.Status.Resources[resource]; ok {
+		return nil
+	}
+
+	r, err := o.client.CycloneV1alpha1().Resources(o.wfr.Namespace).Get(resource, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	if o.wfr.Status.Resources == nil {
+		o.wfr.Status.Resources = make(map[string]*v1alpha1.ResourceStatus)
+	}
+	o.wfr.Status.Resources[resource] = resourceStatus(r, stage, ResourceParameters(o.wfr, r), outputs)
+	return nil
+}
+
+// SaveStageCache saves results of a completed stage under its cache key, so that later runs
+// with the same cache key can skip the stage. It does nothing if cache is not enabled for the
+// stage or the results are restored from cache.
+func (o *operator) SaveStageCache(stage string) error {
+	err := saveCache(o.client, o.wfr, stage, cachePolicy(o.wf, stage))
+	if err != nil {
+		o.recorder.Eventf(o.wfr, corev1.EventTypeWarning, "StageCacheError", "Save results of stage '%s' to cache error: %v", stage, err)
+	}
+	return err
+}
+
 // OverallStatus calculates the overall status of the WorkflowRun. When a stage has its status
 // changed, the change will be updated in WorkflowRun stage status, but the overall status is
 // not calculated. So when we observed a WorkflowRun updated, we need to calculate its overall
@@ -276,6 +385,11 @@ func (o *operator) Reconcile() error {
 		o.wfr.Status.Stages = make(map[string]*v1alpha1.StageStatus)
 	}
 
+	// Load resource versions to pin from the replayed WorkflowRun.
+	if err := o.loadReplay(); err != nil {
+		return err
+	}
+
 	// Get next stages that need to be run.
 	nextStages := NextStages(o.wf, o.wfr)
 	if len(nextStages) == 0 {
@@ -309,6 +423,11 @@ func (o *operator) Reconcile() error {
 
 	// Create pod to run stages.
 	for _, stage := range nextStages {
+		// Skip the stage if its results can be restored from cache.
+		if o.restoreFromCache(stage) {
+			continue
+		}
+
 		log.WithField("stg", stage).Info("Start to run stage")
 
 		// Generate pod for this stage.
@@ -367,12 +486,51 @@ func (o *operator) Reconcile() error {
 	return nil
 }
 
+// loadReplay copies resource versions recorded in the replayed WorkflowRun to status, so that
+// input resources are pulled at the same versions. It does nothing if the WorkflowRun is not
+// a replay or versions have been loaded.
+func (o *operator) loadReplay() error {
+	if o.wfr.Spec.Replay == "" || len(o.wfr.Status.Resources) > 0 {
+		return nil
+	}
+
+	origin, err := o.client.CycloneV1alpha1().WorkflowRuns(o.wfr.Namespace).Get(o.wfr.Spec.Replay, metav1.GetOptions{})
+	if err != nil {
+		log.WithField("wfr", o.wfr.Name).WithField("replay", o.wfr.Spec.Replay).Error("Get replayed WorkflowRun error: ", err)
+		if !errors.IsNotFound(err) {
+			return err
+		}
+
+		o.recorder.Eventf(o.wfr, corev1.EventTypeWarning, "ReplaySourceNotFound", "WorkflowRun '%s' to replay not found", o.wfr.Spec.Replay)
+		o.wfr.Status.Overall = v1alpha1.Status{
+			Status:             v1alpha1.StatusError,
+			Reason:             "ReplaySourceNotFound",
+			LastTransitionTime: metav1.Time{Time: time.Now()},
+			Message:            fmt.Sprintf("WorkflowRun %s to replay not found", o.wfr.Spec.Replay),
+		}
+		if updateErr := o.Update(); updateErr != nil {
+			log.WithField("wfr", o.wfr.Name).Error("Update status error: ", updateErr)
+		}
+		return err
+	}
+
+	o.wfr.Status.Resources = make(map[string]*v1alpha1.ResourceStatus)
+	for name, status := range origin.Status.Resources {
+		o.wfr.Status.Resources[name] = status.DeepCopy()
+	}
+	return nil
+}
+
 // Garbage collection of WorkflowRun. When it's terminated, we will cleanup the pods created by it.
 // 'lastTry' indicates whether this is the last try to perform GC on this WorkflowRun object,
 // if set to true, the WorkflowRun would be marked as cleaned regardless whether the GC succeeded or not.
 func (o *operator) GC(lastTry bool) error {
 	// For each pod created, delete it.
 	for stg, status := range o.wfr.Status.Stages {
+		// Stages restored from cache have no pod created.
+		if status.Cache != nil && status.Cache.Hit {
+			continue
+		}
 		if status.Pod == nil {
 			log.WithField("wfr", o.wfr.Name).
 				WithField("stg", stg).
@@ -393,6 +551,12 @@ func (o *operator) GC(lastTry bool) error {
 		}
 	}
 
+	// Delete artifacts stored in object storage.
+	if err := deleteArtifacts(o.wfr.Namespace, o.wfr.Name); err != nil {
+		log.WithField("wfr", o.wfr.Name).Warn("Delete artifacts error: ", err)
+		o.recorder.Eventf(o.wfr, corev1.EventTypeWarning, "GC", "Delete artifacts error: %v", err)
+	}
+
 	// Create a gc pod to clean data on tmp PV.
 	gcPod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
@@ -10,8 +10,12 @@ import (
 
 	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
 	"github.com/caicloud/cyclone/pkg/k8s/clientset"
+	"github.com/caicloud/cyclone/pkg/workflow/artifact"
 	"github.com/caicloud/cyclone/pkg/workflow/common"
 	"github.com/caicloud/cyclone/pkg/workflow/controller"
+	"github.com/caicloud/cyclone/pkg/workflow/resolver"
+	gitresolver "github.com/caicloud/cyclone/pkg/workflow/resolver/git"
+	svnresolver "github.com/caicloud/cyclone/pkg/workflow/resolver/svn"
 	"github.com/cbroglie/mustache"
 	"github.com/satori/go.uuid"
 	log "github.com/sirupsen/logrus"
@@ -29,6 +33,10 @@ type PodBuilder struct {
 	stage      string
 	pod        *corev1.Pod
 	pvcVolumes map[string]string
+	// Secret holding docker config.json used by resource resolvers, and key
+	// of the config.json in the secret.
+	dockerSecret    string
+	dockerSecretKey string
 }
 
 // NewPodBuilder creates a new pod builder.
@@ -104,6 +112,29 @@ func (m *PodBuilder) ApplyTemplate() error {
 
 // ResolveArguments ...
 func (m *PodBuilder) ResolveArguments() error {
+	parameters, err := m.arguments()
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(m.stg.Spec.Pod.Spec)
+	if err != nil {
+		return err
+	}
+	rendered, err := mustache.Render(string(raw), parameters)
+	if err != nil {
+		return err
+	}
+	renderedSpec := corev1.PodSpec{}
+	json.Unmarshal([]byte(rendered), &renderedSpec)
+	m.pod.Spec = renderedSpec
+	m.pod.Spec.RestartPolicy = corev1.RestartPolicyNever
+
+	return nil
+}
+
+// arguments collects arguments of the stage, values given in WorkflowRun take precedence over
+// default values in stage spec.
+func (m *PodBuilder) arguments() (map[string]string, error) {
 	parameters := make(map[string]string)
 	for _, s := range m.wfr.Spec.Stages {
 		if s.Name == m.stage {
@@ -118,26 +149,14 @@ func (m *PodBuilder) ResolveArguments() error {
 				log.WithField("arg", a.Name).
 					WithField("stg", m.stg.Name).
 					Error("Argument not set and without default value")
-				return fmt.Errorf("argument '%s' not set in stage '%s' and without default value", a.Name, m.stg.Name)
+				return nil, fmt.Errorf("argument '%s' not set in stage '%s' and without default value", a.Name, m.stg.Name)
 			}
 			parameters[a.Name] = a.Value
 		}
 	}
 	log.WithField("params", parameters).Debug("Parameters collected")
-	raw, err := json.Marshal(m.stg.Spec.Pod.Spec)
-	if err != nil {
-		return err
-	}
-	rendered, err := mustache.Render(string(raw), parameters)
-	if err != nil {
-		return err
-	}
-	renderedSpec := corev1.PodSpec{}
-	json.Unmarshal([]byte(rendered), &renderedSpec)
-	m.pod.Spec = renderedSpec
-	m.pod.Spec.RestartPolicy = corev1.RestartPolicyNever
 
-	return nil
+	return parameters, nil
 }
 
 // CreateVolumes ...
@@ -158,28 +177,34 @@ func (m *PodBuilder) CreateVolumes() error {
 		}
 	}
 
-	// Create hostPath volume for /var/run/docker.sock
-	var hostPathSocket = corev1.HostPathSocket
-	m.pod.Spec.Volumes = append(m.pod.Spec.Volumes, corev1.Volume{
-		Name: common.DockerSockVolume,
-		VolumeSource: corev1.VolumeSource{
-			HostPath: &corev1.HostPathVolumeSource{
-				Path: common.DockerSockPath,
-				Type: &hostPathSocket,
+	// Create hostPath volume for /var/run/docker.sock only if the stage asks for it, or docker
+	// executor is used by coordinator.
+	if m.stg.Spec.Pod.DockerSock || controller.Config.ExecutorType() == common.ExecutorDocker {
+		var hostPathSocket = corev1.HostPathSocket
+		m.pod.Spec.Volumes = append(m.pod.Spec.Volumes, corev1.Volume{
+			Name: common.DockerSockVolume,
+			VolumeSource: corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{
+					Path: common.DockerSockPath,
+					Type: &hostPathSocket,
+				},
 			},
-		},
-	})
+		})
+	}
 
 	// Create secret volume for use in resource resolvers.
-	if controller.Config.Secret != "" {
+	if err := m.resolveDockerSecret(); err != nil {
+		return err
+	}
+	if m.dockerSecret != "" {
 		m.pod.Spec.Volumes = append(m.pod.Spec.Volumes, corev1.Volume{
 			Name: common.DockerConfigJSONVolume,
 			VolumeSource: corev1.VolumeSource{
 				Secret: &corev1.SecretVolumeSource{
-					SecretName: controller.Config.Secret,
+					SecretName: m.dockerSecret,
 					Items: []corev1.KeyToPath{
 						{
-							Key:  common.DockerConfigJSONFile,
+							Key:  m.dockerSecretKey,
 							Path: common.DockerConfigJSONFile,
 						},
 					},
@@ -191,6 +216,34 @@ func (m *PodBuilder) CreateVolumes() error {
 	return nil
 }
 
+// resolveDockerSecret determines the secret holding docker registry auth. Auth is generated from
+// DockerRegistry integrations in the tenant namespace, so that each tenant uses its own registry
+// credentials. Only when the tenant has no registry integrated, the default secret configured in
+// Workflow Controller would be used. Tenant generated secret will also be added to the pod as
+// imagePullSecrets, so that private stage images can be pulled.
+func (m *PodBuilder) resolveDockerSecret() error {
+	secret, err := EnsureRegistrySecret(m.client, m.wfr.Namespace)
+	if err != nil {
+		return err
+	}
+
+	if secret != "" {
+		m.dockerSecret = secret
+		m.dockerSecretKey = corev1.DockerConfigJsonKey
+		m.pod.Spec.ImagePullSecrets = append(m.pod.Spec.ImagePullSecrets, corev1.LocalObjectReference{
+			Name: secret,
+		})
+		return nil
+	}
+
+	if controller.Config.Secret != "" {
+		m.dockerSecret = controller.Config.Secret
+		m.dockerSecretKey = common.DockerConfigJSONFile
+	}
+
+	return nil
+}
+
 // CreatePVCVolume tries to create a PVC volume for the given volume name and PVC name.
 // If no volume available for the PVC, a new volume would be created and the volume name
 // will be returned. If a volume of the given PVC already exists, return name of  the volume,
@@ -258,7 +311,7 @@ func (m *PodBuilder) ResolveInputResources() error {
 			subPath = ""
 		}
 
-		// Get resource resolver image, if the resource is build-in resource (Git, Image, KV), use
+		// Get resource resolver image, if the resource is build-in resource (Git, SVN, Image, KV, HTTP, S3, Helm, Maven), use
 		// the images configured, otherwise use images given in the resource spec.
 		var image string
 		if key, ok := controller.ResolverImageKeys[resource.Spec.Type]; ok {
@@ -272,16 +325,17 @@ func (m *PodBuilder) ResolveInputResources() error {
 		// spec and the WorkflowRun spec.
 		envsMap := make(map[string]string)
 		envsMap[common.EnvWorkflowrunName] = m.wfr.Name
-		for _, p := range resource.Spec.Parameters {
+		for k, v := range m.resourceParameters(resource) {
+			envsMap[k] = v
+		}
+		// When replaying a WorkflowRun, pin the resource to the version resolved in it.
+		for _, p := range replayParameters(m.wfr, r.Name) {
 			envsMap[p.Name] = p.Value
-
 		}
-		for _, p := range m.wfr.Spec.Resources {
-			if p.Name == r.Name {
-				for _, c := range p.Parameters {
-					envsMap[c.Name] = c.Value
-				}
-			}
+		// Pass pull policy of persistent resource to resolver, it determines whether to make use
+		// of data pulled by previous WorkflowRuns.
+		if persistent != nil && persistent.PullPolicy != "" && envsMap[resolver.EnvPullPolicy] == "" {
+			envsMap[resolver.EnvPullPolicy] = string(persistent.PullPolicy)
 		}
 		var envs []corev1.EnvVar
 		for key, value := range envsMap {
@@ -305,6 +359,25 @@ func (m *PodBuilder) ResolveInputResources() error {
 			},
 			ImagePullPolicy: controller.ImagePullPolicy(),
 		}
+
+		// Mount credentials from SCM integration for git resources.
+		if integration := envsMap[gitresolver.EnvSCMIntegration]; resource.Spec.Type == v1alpha1.GitResourceType && integration != "" {
+			if err := m.mountSCMSecret(&container, integration); err != nil {
+				return err
+			}
+		}
+		// Credentials of SVN integration are injected as AUTH_USERNAME and AUTH_PASSWORD.
+		if resource.Spec.Type == v1alpha1.SVNResourceType {
+			if err := m.injectIntegration(&container, envsMap[svnresolver.EnvSCMIntegration]); err != nil {
+				return err
+			}
+		}
+		if resource.Spec.Type == v1alpha1.ImageResourceType {
+			m.mountDockerConfig(&container)
+		}
+		if err := m.injectIntegration(&container, envsMap[resolver.EnvIntegration]); err != nil {
+			return err
+		}
 		m.pod.Spec.InitContainers = append(m.pod.Spec.InitContainers, container)
 
 		// Mount the resource to all workload containers.
@@ -333,6 +406,121 @@ func (m *PodBuilder) ResolveInputResources() error {
 	return nil
 }
 
+// mountSCMSecret mounts secret generated from the SCM integration to git resource resolver. SSH
+// private key and known hosts in the secret are used for SSH urls, and token is used for HTTPS
+// urls if not given in resource parameters.
+func (m *PodBuilder) mountSCMSecret(container *corev1.Container, integration string) error {
+	secret, scm, err := EnsureSCMSecret(m.client, m.wfr.Namespace, integration)
+	if err != nil {
+		return err
+	}
+
+	var exist bool
+	for _, v := range m.pod.Spec.Volumes {
+		if v.Name == secret {
+			exist = true
+			break
+		}
+	}
+	if !exist {
+		mode := int32(0400)
+		m.pod.Spec.Volumes = append(m.pod.Spec.Volumes, corev1.Volume{
+			Name: secret,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName:  secret,
+					DefaultMode: &mode,
+				},
+			},
+		})
+	}
+
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:      secret,
+		MountPath: common.SCMSecretPath,
+		ReadOnly:  true,
+	})
+	if scm.SSHKey != "" {
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name:  gitresolver.EnvSSHKeyFile,
+			Value: common.SCMSecretPath + "/" + corev1.SSHAuthPrivateKey,
+		})
+	}
+	if scm.KnownHosts != "" {
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name:  gitresolver.EnvSSHKnownHostsFile,
+			Value: common.SCMSecretPath + "/" + common.SCMSecretKnownHostsKey,
+		})
+	}
+	if scm.InsecureSkipHostKeyCheck {
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name:  gitresolver.EnvSSHInsecureSkipHostKeyCheck,
+			Value: "true",
+		})
+	}
+
+	var hasToken bool
+	for _, env := range container.Env {
+		if env.Name == gitresolver.EnvToken {
+			hasToken = true
+		}
+	}
+	if scm.Token != "" && !hasToken {
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name: gitresolver.EnvToken,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: secret,
+					},
+					Key: common.SCMSecretTokenKey,
+				},
+			},
+		})
+	}
+
+	return nil
+}
+
+// injectIntegration injects credentials of the integration into resource resolver as environment
+// variables. Since explicit environment variables take precedence over envFrom, parameters given
+// in resource still work.
+func (m *PodBuilder) injectIntegration(container *corev1.Container, integration string) error {
+	if integration == "" {
+		return nil
+	}
+
+	secret, err := EnsureIntegrationSecret(m.client, m.wfr.Namespace, integration)
+	if err != nil {
+		return err
+	}
+	container.EnvFrom = append(container.EnvFrom, corev1.EnvFromSource{
+		SecretRef: &corev1.SecretEnvSource{
+			LocalObjectReference: corev1.LocalObjectReference{
+				Name: secret,
+			},
+		},
+	})
+	return nil
+}
+
+// mountDockerConfig mounts docker config.json holding registry auth to image resource resolver.
+func (m *PodBuilder) mountDockerConfig(container *corev1.Container) {
+	if m.dockerSecret == "" {
+		return
+	}
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:      common.DockerConfigJSONVolume,
+		MountPath: common.DockerConfigPath,
+	})
+}
+
+// resourceParameters gathers parameters of the resource from both the resource spec and the
+// WorkflowRun spec, parameters in WorkflowRun take precedence.
+func (m *PodBuilder) resourceParameters(resource *v1alpha1.Resource) map[string]string {
+	return ResourceParameters(m.wfr, resource)
+}
+
 // ResolveOutputResources add resource resolvers to pod spec.
 func (m *PodBuilder) ResolveOutputResources() error {
 	for _, r := range m.stg.Spec.Pod.Outputs.Resources {
@@ -343,7 +531,7 @@ func (m *PodBuilder) ResolveOutputResources() error {
 			return err
 		}
 
-		// Get resource resolver image, if the resource is build-in resource (Git, Image, KV), use
+		// Get resource resolver image, if the resource is build-in resource (Git, SVN, Image, KV, HTTP, S3, Helm, Maven), use
 		// the images configured, otherwise use images given in the resource spec.
 		var image string
 		if key, ok := controller.ResolverImageKeys[resource.Spec.Type]; ok {
@@ -354,18 +542,7 @@ func (m *PodBuilder) ResolveOutputResources() error {
 
 		// Create container for each output resource and project all parameters into the
 		// container through environment variables.
-		envsMap := make(map[string]string)
-		for _, p := range resource.Spec.Parameters {
-			envsMap[p.Name] = p.Value
-
-		}
-		for _, p := range m.wfr.Spec.Resources {
-			if p.Name == r.Name {
-				for _, c := range p.Parameters {
-					envsMap[c.Name] = c.Value
-				}
-			}
-		}
+		envsMap := m.resourceParameters(resource)
 		var envs []corev1.EnvVar
 		for key, value := range envsMap {
 			envs = append(envs, corev1.EnvVar{
@@ -405,17 +582,10 @@ func (m *PodBuilder) ResolveOutputResources() error {
 		}
 
 		if resource.Spec.Type == v1alpha1.ImageResourceType {
-			container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
-				Name:      common.DockerSockVolume,
-				MountPath: common.DockerSockPath,
-			})
-
-			if controller.Config.Secret != "" {
-				container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
-					Name:      common.DockerConfigJSONVolume,
-					MountPath: common.DockerConfigPath,
-				})
-			}
+			m.mountDockerConfig(&container)
+		}
+		if err := m.injectIntegration(&container, envsMap[resolver.EnvIntegration]); err != nil {
+			return err
 		}
 
 		m.pod.Spec.Containers = append(m.pod.Spec.Containers, container)
@@ -424,9 +594,12 @@ func (m *PodBuilder) ResolveOutputResources() error {
 	return nil
 }
 
-// ResolveInputArtifacts mount each input artifact from PVC.
+// ResolveInputArtifacts mount each input artifact. When artifacts are stored in PVC, they
+// are mounted from PVC directly, otherwise an init container is added to fetch them from
+// the artifact store to an emptyDir volume.
 func (m *PodBuilder) ResolveInputArtifacts() error {
-	if controller.Config.PVC == "" && len(m.stg.Spec.Pod.Inputs.Artifacts) > 0 {
+	storeType := controller.Config.Artifact.StoreType()
+	if storeType == artifact.PVCStore && controller.Config.PVC == "" && len(m.stg.Spec.Pod.Inputs.Artifacts) > 0 {
 		return fmt.Errorf("artifacts not supported when no PVC provided, but %d input artifacts found", len(m.stg.Spec.Pod.Inputs.Artifacts))
 	}
 
@@ -444,42 +617,69 @@ func (m *PodBuilder) ResolveInputArtifacts() error {
 		return fmt.Errorf("stage %s not found in workflow %s", m.stg.Name, m.wf.Name)
 	}
 
-	// For each input artifact, mount data from PVC.
-	for _, artifact := range m.stg.Spec.Pod.Inputs.Artifacts {
+	// For each input artifact, mount data from PVC or the fetched data.
+	var inputs []artifact.Input
+	for _, art := range m.stg.Spec.Pod.Inputs.Artifacts {
 		// Get source of this input artifact from Workflow StageItem
 		// It has format: <stage name>/<artifact name>
 		var source string
-		for _, art := range wfStage.Artifacts {
-			if art.Name == artifact.Name {
-				source = art.Source
+		for _, a := range wfStage.Artifacts {
+			if a.Name == art.Name {
+				source = a.Source
 			}
 		}
 		if source == "" {
 			log.WithField("stg", m.stg.Name).
 				WithField("wfr", m.wf.Name).
-				WithField("artifact", artifact.Name).
+				WithField("artifact", art.Name).
 				Error("Input artifact not bind in workflow")
 			return fmt.Errorf("input artifact %s not binded in workflow %s", m.stg.Name, m.wf.Name)
 		}
 		parts := strings.Split(source, "/")
 		log.WithField("source", source).
-			WithField("artifact", artifact.Name).
+			WithField("artifact", art.Name).
 			Info("To mount artifact")
 
+		output, err := m.outputArtifact(parts[0], parts[1])
+		if err != nil {
+			return err
+		}
+		fileName := artifact.OutputName(output.Name, strings.TrimSuffix(output.Path, "/"), output.OutputOptions)
+
+		// Artifacts in PVC are mounted directly, while artifacts in object storage and archived
+		// artifacts which need to be unpacked are fetched by artifact fetcher.
+		unpack := output.Archive != nil
+		volumeName := common.DefaultPvVolumeName
+		key := artifact.Key(m.wfr.Name, parts[0], parts[1])
+		subPath := common.ArtifactPath(m.wfr.Name, parts[0], parts[1]) + "/" + fileName
+
+		// If results of the source stage are restored from cache, artifacts are in the cache.
+		if cache := m.stageCache(parts[0]); cache != nil && cache.Hit {
+			key = artifact.CacheKey(cache.Key, parts[1])
+			subPath = common.CacheArtifactsPath(cache.Key) + parts[1] + "/" + fileName
+		}
+		if storeType != artifact.PVCStore || unpack {
+			volumeName = common.InputArtifactsVolumeName
+			subPath = art.Name
+			if !unpack {
+				subPath += "/" + fileName
+			}
+			inputs = append(inputs, artifact.Input{
+				Name:   art.Name,
+				Key:    key,
+				Unpack: unpack,
+			})
+		}
+
 		// Mount artifacts to each workload container.
 		var containers []corev1.Container
 		for _, c := range m.pod.Spec.Containers {
-			fileName, err := m.ArtifactFileName(parts[0], parts[1])
-			if err != nil {
-				return err
-			}
-
 			// Mount artifacts only to workload containers, with sidecars excluded.
 			if common.OnlyWorkload(c.Name) {
 				c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{
-					Name:      common.DefaultPvVolumeName,
-					MountPath: artifact.Path,
-					SubPath:   common.ArtifactPath(m.wfr.Name, parts[0], parts[1]) + "/" + fileName,
+					Name:      volumeName,
+					MountPath: art.Path,
+					SubPath:   subPath,
 				})
 			}
 			containers = append(containers, c)
@@ -487,10 +687,74 @@ func (m *PodBuilder) ResolveInputArtifacts() error {
 		m.pod.Spec.Containers = containers
 	}
 
+	if len(inputs) == 0 {
+		return nil
+	}
+
+	return m.addArtifactFetcher(inputs)
+}
+
+// stageCache gets cache status of the stage in WorkflowRun, nil is returned if cache is not
+// enabled for the stage.
+func (m *PodBuilder) stageCache(stage string) *v1alpha1.StageCacheStatus {
+	status, ok := m.wfr.Status.Stages[stage]
+	if !ok || status == nil || status.Cache == nil || status.Cache.Key == "" {
+		return nil
+	}
+	return status.Cache
+}
+
+// addArtifactFetcher adds an init container to fetch input artifacts from artifact store to
+// an emptyDir volume, which will be mounted to workload containers. For PVC store, the PVC is
+// mounted to the fetcher.
+func (m *PodBuilder) addArtifactFetcher(inputs []artifact.Input) error {
+	storeSecret, err := ensureArtifactStoreSecret(m.client, m.wfr.Namespace)
+	if err != nil {
+		return err
+	}
+	encodedInputs, err := artifact.EncodeInputs(inputs)
+	if err != nil {
+		return err
+	}
+
+	m.pod.Spec.Volumes = append(m.pod.Spec.Volumes, corev1.Volume{
+		Name: common.InputArtifactsVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{},
+		},
+	})
+	fetcher := corev1.Container{
+		Name:    common.ArtifactFetcherName,
+		Image:   controller.Config.Images[controller.CoordinatorImage],
+		Command: []string{"/workspace/coordinator", common.ArtifactFetcherCommand},
+		Env: []corev1.EnvVar{
+			artifactStoreEnv(storeSecret),
+			{
+				Name:  common.EnvInputArtifacts,
+				Value: encodedInputs,
+			},
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      common.InputArtifactsVolumeName,
+				MountPath: common.InputArtifactsPath,
+			},
+		},
+		ImagePullPolicy: controller.ImagePullPolicy(),
+	}
+	if controller.Config.Artifact.StoreType() == artifact.PVCStore {
+		fetcher.VolumeMounts = append(fetcher.VolumeMounts, corev1.VolumeMount{
+			Name:      common.DefaultPvVolumeName,
+			MountPath: common.ArtifactFetcherPVCPath,
+			ReadOnly:  true,
+		})
+	}
+	m.pod.Spec.InitContainers = append(m.pod.Spec.InitContainers, fetcher)
+
 	return nil
 }
 
-// AddVolumeMounts add common PVC  to workload containers
+// AddVolumeMounts add common PVC to workload containers, and docker socket if the stage asks for it.
 func (m *PodBuilder) AddVolumeMounts() error {
 	if controller.Config.PVC != "" {
 		var containers []corev1.Container
@@ -505,6 +769,20 @@ func (m *PodBuilder) AddVolumeMounts() error {
 		m.pod.Spec.Containers = containers
 	}
 
+	if m.stg.Spec.Pod.DockerSock {
+		var containers []corev1.Container
+		for _, c := range m.pod.Spec.Containers {
+			if common.OnlyWorkload(c.Name) {
+				c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{
+					Name:      common.DockerSockVolume,
+					MountPath: common.DockerSockPath,
+				})
+			}
+			containers = append(containers, c)
+		}
+		m.pod.Spec.Containers = containers
+	}
+
 	return nil
 }
 
@@ -512,10 +790,11 @@ func (m *PodBuilder) AddVolumeMounts() error {
 // to collect logs, artifacts and notify resource resolvers to push resources.
 func (m *PodBuilder) AddCoordinator() error {
 	// Get workload container name, for the moment, we support only one workload container.
-	var workloadContainer string
-	for _, c := range m.stg.Spec.Pod.Spec.Containers {
-		workloadContainer = c.Name
-		break
+	workloadContainer := m.workloadContainer()
+
+	storeSecret, err := ensureArtifactStoreSecret(m.client, m.wfr.Namespace)
+	if err != nil {
+		return err
 	}
 
 	coordinator := corev1.Container{
@@ -546,12 +825,13 @@ func (m *PodBuilder) AddCoordinator() error {
 				Name:  common.EnvCycloneServerAddr,
 				Value: controller.Config.CycloneServerAddr,
 			},
-		},
-		VolumeMounts: []corev1.VolumeMount{
+			artifactStoreEnv(storeSecret),
 			{
-				Name:      common.DockerSockVolume,
-				MountPath: common.DockerSockPath,
+				Name:  common.EnvExecutor,
+				Value: controller.Config.ExecutorType(),
 			},
+		},
+		VolumeMounts: []corev1.VolumeMount{
 			{
 				Name:      common.CoordinatorSidecarVolumeName,
 				MountPath: common.CoordinatorResolverPath,
@@ -559,18 +839,113 @@ func (m *PodBuilder) AddCoordinator() error {
 		},
 		ImagePullPolicy: controller.ImagePullPolicy(),
 	}
-	if controller.Config.PVC != "" {
+	if controller.Config.ExecutorType() == common.ExecutorDocker {
+		coordinator.VolumeMounts = append(coordinator.VolumeMounts, corev1.VolumeMount{
+			Name:      common.DockerSockVolume,
+			MountPath: common.DockerSockPath,
+		})
+	} else {
+		coordinator.VolumeMounts = append(coordinator.VolumeMounts, m.sharedVolumeMounts(workloadContainer)...)
+	}
+	if controller.Config.PVC != "" && controller.Config.Artifact.StoreType() == artifact.PVCStore {
 		coordinator.VolumeMounts = append(coordinator.VolumeMounts, corev1.VolumeMount{
 			Name:      common.DefaultPvVolumeName,
 			MountPath: common.CoordinatorWorkspacePath + "artifacts",
 			SubPath:   common.ArtifactsPath(m.wfr.Name, m.stage),
 		})
 	}
+
+	// If cache is enabled for the stage, coordinator also stores output artifacts under the
+	// cache key, so that they can be restored by later runs.
+	if cache := m.stageCache(m.stage); cache != nil && !cache.Hit {
+		coordinator.Env = append(coordinator.Env, corev1.EnvVar{
+			Name:  common.EnvStageCacheKey,
+			Value: cache.Key,
+		})
+		if controller.Config.PVC != "" && controller.Config.Artifact.StoreType() == artifact.PVCStore {
+			coordinator.VolumeMounts = append(coordinator.VolumeMounts, corev1.VolumeMount{
+				Name:      common.DefaultPvVolumeName,
+				MountPath: common.CoordinatorCachePath,
+				SubPath:   common.CacheArtifactsPath(cache.Key),
+			})
+		}
+	}
 	m.pod.Spec.Containers = append(m.pod.Spec.Containers, coordinator)
 
 	return nil
 }
 
+// workloadContainer gets name of the workload container, for the moment, we support only one
+// workload container, others are workload sidecars.
+func (m *PodBuilder) workloadContainer() string {
+	for _, c := range m.stg.Spec.Pod.Spec.Containers {
+		if common.OnlyWorkload(c.Name) {
+			return c.Name
+		}
+	}
+	return ""
+}
+
+// ResolveWorkloadSidecars makes workload sidecars work with the workload if there are any. Process
+// namespace is shared in the pod, so that coordinator can stop sidecars after workload finished.
+// Command of the workload container is wrapped by coordinator binary copied from an init
+// container, it waits coordinator to notify that sidecars are ready before running the command.
+// Command of the workload container is required then, since entrypoint of the image is unknown.
+func (m *PodBuilder) ResolveWorkloadSidecars() error {
+	var hasSidecars bool
+	for _, c := range m.pod.Spec.Containers {
+		if common.OnlyWorkloadSidecar(c.Name) {
+			hasSidecars = true
+			break
+		}
+	}
+	if !hasSidecars {
+		return nil
+	}
+
+	shareProcessNamespace := true
+	m.pod.Spec.ShareProcessNamespace = &shareProcessNamespace
+	m.CreateEmptyDirVolume(common.SidecarGateVolumeName)
+	m.pod.Spec.InitContainers = append(m.pod.Spec.InitContainers, corev1.Container{
+		Name:    common.SidecarGateName,
+		Image:   controller.Config.Images[controller.CoordinatorImage],
+		Command: []string{"cp", "/workspace/coordinator", common.CoordinatorSidecarGatePath},
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      common.SidecarGateVolumeName,
+				MountPath: common.CoordinatorSidecarGatePath,
+			},
+		},
+		ImagePullPolicy: controller.ImagePullPolicy(),
+	})
+
+	workload := m.workloadContainer()
+	var containers []corev1.Container
+	for _, c := range m.pod.Spec.Containers {
+		switch c.Name {
+		case common.CoordinatorSidecarName:
+			c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{
+				Name:      common.SidecarGateVolumeName,
+				MountPath: common.CoordinatorSidecarGatePath,
+			})
+		case workload:
+			c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{
+				Name:      common.SidecarGateVolumeName,
+				MountPath: common.SidecarGatePath,
+				ReadOnly:  true,
+			})
+			if len(c.Command) == 0 {
+				return fmt.Errorf("command of workload container %s is required with workload sidecars", c.Name)
+			}
+			c.Command = append([]string{common.SidecarGatePath + "/coordinator", common.SidecarGateCommand}, c.Command...)
+		}
+		containers = append(containers, c)
+	}
+	m.pod.Spec.Containers = containers
+
+	return nil
+}
+
 // InjectEnvs injects environment variables to containers, such as WorkflowRun name
 // stage name, namespace.
 func (m *PodBuilder) InjectEnvs() error {
@@ -642,6 +1017,30 @@ func (m *PodBuilder) ApplyQuota() error {
 	return nil
 }
 
+// sharedVolumeMounts mounts volumes of the workload container to coordinator read-only, so that
+// k8sapi executor can collect outputs on them after the workload container terminated. Each
+// volume mount is placed at '<CoordinatorVolumesPath>/<index>' with the same sub path.
+func (m *PodBuilder) sharedVolumeMounts(workload string) []corev1.VolumeMount {
+	var mounts []corev1.VolumeMount
+	for _, c := range m.pod.Spec.Containers {
+		if c.Name != workload {
+			continue
+		}
+		for i, vm := range c.VolumeMounts {
+			if vm.Name == common.DockerSockVolume {
+				continue
+			}
+			mounts = append(mounts, corev1.VolumeMount{
+				Name:      vm.Name,
+				MountPath: fmt.Sprintf("%s/%d", common.CoordinatorVolumesPath, i),
+				SubPath:   vm.SubPath,
+				ReadOnly:  true,
+			})
+		}
+	}
+	return mounts
+}
+
 // Build ...
 func (m *PodBuilder) Build() (*corev1.Pod, error) {
 	err := m.Prepare()
@@ -689,6 +1088,11 @@ func (m *PodBuilder) Build() (*corev1.Pod, error) {
 		return nil, err
 	}
 
+	err = m.ResolveWorkloadSidecars()
+	if err != nil {
+		return nil, err
+	}
+
 	err = m.InjectEnvs()
 	if err != nil {
 		return nil, err
@@ -702,20 +1106,29 @@ func (m *PodBuilder) Build() (*corev1.Pod, error) {
 	return m.pod, nil
 }
 
-// ArtifactFileName gets artifact file name from artifacts path.
+// ArtifactFileName gets file name of the collected output artifact, see artifact.OutputName.
 func (m *PodBuilder) ArtifactFileName(stageName, artifactName string) (string, error) {
+	output, err := m.outputArtifact(stageName, artifactName)
+	if err != nil {
+		return "", err
+	}
+
+	return artifact.OutputName(output.Name, strings.TrimSuffix(output.Path, "/"), output.OutputOptions), nil
+}
+
+// outputArtifact gets the output artifact from stage spec.
+func (m *PodBuilder) outputArtifact(stageName, artifactName string) (*v1alpha1.ArtifactItem, error) {
 	stage, err := m.client.CycloneV1alpha1().Stages(m.wfr.Namespace).Get(stageName, metav1.GetOptions{})
 	if err != nil {
 		log.WithField("stg", stageName).Error("Get stage error: ", err)
-		return "", err
+		return nil, err
 	}
 
-	for _, artifact := range stage.Spec.Pod.Outputs.Artifacts {
-		if artifact.Name == artifactName {
-			parts := strings.Split(strings.TrimSuffix(artifact.Path, "/"), "/")
-			return parts[len(parts)-1], nil
+	for _, a := range stage.Spec.Pod.Outputs.Artifacts {
+		if a.Name == artifactName {
+			return &a, nil
 		}
 	}
 
-	return "", fmt.Errorf("output artifact '%s' not found in stage '%s'", artifactName, stageName)
+	return nil, fmt.Errorf("output artifact '%s' not found in stage '%s'", artifactName, stageName)
 }
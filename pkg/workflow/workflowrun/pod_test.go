@@ -1,6 +1,7 @@
 package workflowrun
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -14,6 +15,7 @@ import (
 	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
 	"github.com/caicloud/cyclone/pkg/k8s/clientset"
 	"github.com/caicloud/cyclone/pkg/k8s/clientset/fake"
+	"github.com/caicloud/cyclone/pkg/workflow/artifact"
 	"github.com/caicloud/cyclone/pkg/workflow/common"
 	"github.com/caicloud/cyclone/pkg/workflow/controller"
 )
@@ -169,6 +171,30 @@ func (suite *PodBuilderSuite) SetupTest() {
 						},
 					},
 				}, nil
+			case "with-sidecar":
+				return true, &v1alpha1.Stage{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: name,
+					},
+					Spec: v1alpha1.StageSpec{
+						Pod: &v1alpha1.PodWorkload{
+							Spec: corev1.PodSpec{
+								Containers: []corev1.Container{
+									{
+										Name:  common.WorkloadSidecarPrefix + "db",
+										Image: "mysql:5.7",
+									},
+									{
+										Name:    "c1",
+										Image:   "golang:1.10",
+										Command: []string{"go"},
+										Args:    []string{"test", "./..."},
+									},
+								},
+							},
+						},
+					},
+				}, nil
 			case "unresolvable-argument":
 				return true, &v1alpha1.Stage{
 					ObjectMeta: metav1.ObjectMeta{
@@ -328,6 +354,10 @@ func (suite *PodBuilderSuite) TestResolveArguments() {
 }
 
 func (suite *PodBuilderSuite) TestCreateVolumes() {
+	controller.Config = controller.WorkflowControllerConfig{Executor: common.ExecutorKubernetes}
+	defer func() {
+		controller.Config = controller.WorkflowControllerConfig{}
+	}()
 	builder := NewPodBuilder(suite.client, wf, wfr, "stage1")
 	err := builder.Prepare()
 	assert.Nil(suite.T(), err)
@@ -341,9 +371,28 @@ func (suite *PodBuilderSuite) TestCreateVolumes() {
 		volumes = append(volumes, v.Name)
 	}
 	assert.Contains(suite.T(), volumes, common.CoordinatorSidecarVolumeName)
-	assert.Contains(suite.T(), volumes, common.DockerSockVolume)
+	assert.NotContains(suite.T(), volumes, common.DockerSockVolume)
 	assert.NotContains(suite.T(), volumes, common.DefaultPvVolumeName)
 	assert.NotContains(suite.T(), volumes, common.DockerConfigJSONVolume)
+	assert.Empty(suite.T(), builder.pod.Spec.ImagePullSecrets)
+
+	_, err = suite.client.CoreV1().Secrets("").Create(registryIntegrationSecret("r1", "", "r1.io", "u1", "p1"))
+	assert.Nil(suite.T(), err)
+	defer suite.client.CoreV1().Secrets("").Delete("r1", &metav1.DeleteOptions{})
+	builder = NewPodBuilder(suite.client, wf, wfr, "stage1")
+	assert.Nil(suite.T(), builder.Prepare())
+	assert.Nil(suite.T(), builder.ResolveArguments())
+	assert.Nil(suite.T(), builder.CreateVolumes())
+	var secretVolume *corev1.Volume
+	for _, v := range builder.pod.Spec.Volumes {
+		if v.Name == common.DockerConfigJSONVolume {
+			secretVolume = v.DeepCopy()
+		}
+	}
+	assert.NotNil(suite.T(), secretVolume)
+	assert.Equal(suite.T(), common.RegistrySecretName, secretVolume.Secret.SecretName)
+	assert.Equal(suite.T(), corev1.DockerConfigJsonKey, secretVolume.Secret.Items[0].Key)
+	assert.Equal(suite.T(), []corev1.LocalObjectReference{{Name: common.RegistrySecretName}}, builder.pod.Spec.ImagePullSecrets)
 }
 
 func (suite *PodBuilderSuite) TestCreatePVCVolume() {
@@ -415,10 +464,29 @@ func (suite *PodBuilderSuite) TestResolveOutputResources() {
 	}
 	assert.Contains(suite.T(), vms, common.CoordinatorSidecarVolumeName)
 	assert.Contains(suite.T(), mountPaths, common.ResolverDefaultDataPath)
-	assert.Contains(suite.T(), vms, common.DockerSockVolume)
+	assert.NotContains(suite.T(), vms, common.DockerSockVolume)
 	assert.Contains(suite.T(), mountPaths, common.ResolverNotifyDirPath)
 }
 
+func (suite *PodBuilderSuite) TestDockerSock() {
+	builder := NewPodBuilder(suite.client, wf, wfr, "stage1")
+	assert.Nil(suite.T(), builder.Prepare())
+	assert.Nil(suite.T(), builder.ResolveArguments())
+	builder.stg.Spec.Pod.DockerSock = true
+	assert.Nil(suite.T(), builder.CreateVolumes())
+	assert.Nil(suite.T(), builder.AddVolumeMounts())
+
+	var volumes []string
+	for _, v := range builder.pod.Spec.Volumes {
+		volumes = append(volumes, v.Name)
+	}
+	assert.Contains(suite.T(), volumes, common.DockerSockVolume)
+	assert.Contains(suite.T(), builder.pod.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+		Name:      common.DockerSockVolume,
+		MountPath: common.DockerSockPath,
+	})
+}
+
 func (suite *PodBuilderSuite) TestResolveInputArtifacts() {
 	controller.Config = controller.WorkflowControllerConfig{}
 	defer func() {
@@ -442,6 +510,154 @@ func (suite *PodBuilderSuite) TestResolveInputArtifacts() {
 	}
 }
 
+func (suite *PodBuilderSuite) TestResolveInputArtifactsFromStore() {
+	controller.Config = controller.WorkflowControllerConfig{
+		Artifact: artifact.Config{
+			Type: artifact.S3Store,
+			S3: &artifact.S3Config{
+				Endpoint: "minio:9000",
+				Location: artifact.Location{Bucket: "artifacts"},
+			},
+		},
+	}
+	defer func() {
+		controller.Config = controller.WorkflowControllerConfig{}
+	}()
+	// Mark the bucket prepared to avoid accessing the object storage.
+	preparedStores.Store("minio:9000/artifacts/"+wfr.Namespace, true)
+
+	builder := NewPodBuilder(suite.client, wf, wfr, "stage2")
+	assert.Nil(suite.T(), builder.Prepare())
+	assert.Nil(suite.T(), builder.ResolveArguments())
+	assert.Nil(suite.T(), builder.ResolveInputArtifacts())
+
+	for _, c := range builder.pod.Spec.Containers {
+		assert.Contains(suite.T(), c.VolumeMounts, corev1.VolumeMount{
+			Name:      common.InputArtifactsVolumeName,
+			MountPath: "/tmp/art1",
+			SubPath:   "art1/artifact.tar",
+		})
+	}
+
+	assert.Equal(suite.T(), 1, len(builder.pod.Spec.InitContainers))
+	fetcher := builder.pod.Spec.InitContainers[0]
+	assert.Equal(suite.T(), common.ArtifactFetcherName, fetcher.Name)
+	var inputs []artifact.Input
+	for _, env := range fetcher.Env {
+		if env.Name == common.EnvInputArtifacts {
+			inputs, _ = artifact.DecodeInputs(env.Value)
+		}
+	}
+	assert.Equal(suite.T(), []artifact.Input{{Name: "art1", Key: artifact.Key("wfr", "stage1", "art1")}}, inputs)
+
+	// Store config holding credentials is referred from secret instead of given in plain text.
+	assert.Contains(suite.T(), fetcher.Env, artifactStoreEnv(common.ArtifactStoreSecretName))
+	secret, err := suite.client.CoreV1().Secrets(wfr.Namespace).Get(common.ArtifactStoreSecretName, metav1.GetOptions{})
+	assert.Nil(suite.T(), err)
+	config, err := artifact.DecodeConfig(string(secret.Data[common.EnvArtifactStore]))
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "minio:9000", config.S3.Endpoint)
+}
+
+func (suite *PodBuilderSuite) TestAddCoordinator() {
+	controller.Config = controller.WorkflowControllerConfig{Executor: common.ExecutorKubernetes}
+	defer func() {
+		controller.Config = controller.WorkflowControllerConfig{}
+	}()
+	builder := NewPodBuilder(suite.client, wf, wfr, "stage1")
+	assert.Nil(suite.T(), builder.Prepare())
+	assert.Nil(suite.T(), builder.ResolveArguments())
+	assert.Nil(suite.T(), builder.CreateVolumes())
+	assert.Nil(suite.T(), builder.ResolveInputResources())
+	assert.Nil(suite.T(), builder.AddVolumeMounts())
+	assert.Nil(suite.T(), builder.AddCoordinator())
+
+	workload := builder.pod.Spec.Containers[0]
+	coordinator := builder.pod.Spec.Containers[len(builder.pod.Spec.Containers)-1]
+	assert.Equal(suite.T(), common.CoordinatorSidecarName, coordinator.Name)
+	assert.Contains(suite.T(), coordinator.Env, corev1.EnvVar{Name: common.EnvExecutor, Value: common.ExecutorKubernetes})
+	assert.NotEmpty(suite.T(), workload.VolumeMounts)
+	for i, vm := range workload.VolumeMounts {
+		assert.Contains(suite.T(), coordinator.VolumeMounts, corev1.VolumeMount{
+			Name:      vm.Name,
+			MountPath: fmt.Sprintf("%s/%d", common.CoordinatorVolumesPath, i),
+			SubPath:   vm.SubPath,
+			ReadOnly:  true,
+		})
+	}
+	for _, v := range builder.pod.Spec.Volumes {
+		assert.NotEqual(suite.T(), common.DockerSockVolume, v.Name)
+	}
+
+	// Docker executor is used by default.
+	controller.Config = controller.WorkflowControllerConfig{}
+	builder = NewPodBuilder(suite.client, wf, wfr, "stage1")
+	assert.Nil(suite.T(), builder.Prepare())
+	assert.Nil(suite.T(), builder.ResolveArguments())
+	assert.Nil(suite.T(), builder.CreateVolumes())
+	assert.Nil(suite.T(), builder.AddVolumeMounts())
+	assert.Nil(suite.T(), builder.AddCoordinator())
+
+	coordinator = builder.pod.Spec.Containers[len(builder.pod.Spec.Containers)-1]
+	assert.Contains(suite.T(), coordinator.Env, corev1.EnvVar{Name: common.EnvExecutor, Value: common.ExecutorDocker})
+	assert.Contains(suite.T(), coordinator.VolumeMounts, corev1.VolumeMount{
+		Name:      common.DockerSockVolume,
+		MountPath: common.DockerSockPath,
+	})
+	var volumes []string
+	for _, v := range builder.pod.Spec.Volumes {
+		volumes = append(volumes, v.Name)
+	}
+	assert.Contains(suite.T(), volumes, common.DockerSockVolume)
+	// Docker socket is only mounted to workload containers when stage asks for it.
+	assert.NotContains(suite.T(), builder.pod.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+		Name:      common.DockerSockVolume,
+		MountPath: common.DockerSockPath,
+	})
+}
+
+func (suite *PodBuilderSuite) TestResolveWorkloadSidecars() {
+	controller.Config = controller.WorkflowControllerConfig{}
+	builder := NewPodBuilder(suite.client, wf, wfr, "simple")
+	assert.Nil(suite.T(), builder.Prepare())
+	assert.Nil(suite.T(), builder.ResolveArguments())
+	assert.Nil(suite.T(), builder.AddCoordinator())
+	assert.Nil(suite.T(), builder.ResolveWorkloadSidecars())
+	assert.Nil(suite.T(), builder.pod.Spec.ShareProcessNamespace)
+	assert.Empty(suite.T(), builder.pod.Spec.InitContainers)
+
+	builder = NewPodBuilder(suite.client, wf, wfr, "with-sidecar")
+	assert.Nil(suite.T(), builder.Prepare())
+	assert.Nil(suite.T(), builder.ResolveArguments())
+	assert.Nil(suite.T(), builder.AddCoordinator())
+	assert.Nil(suite.T(), builder.ResolveWorkloadSidecars())
+
+	assert.True(suite.T(), *builder.pod.Spec.ShareProcessNamespace)
+	assert.Equal(suite.T(), common.SidecarGateName, builder.pod.Spec.InitContainers[0].Name)
+	sidecar, workload, coordinator := builder.pod.Spec.Containers[0], builder.pod.Spec.Containers[1], builder.pod.Spec.Containers[2]
+	assert.Equal(suite.T(), "mysql:5.7", sidecar.Image)
+	assert.Nil(suite.T(), sidecar.Command)
+	assert.Equal(suite.T(), []string{common.SidecarGatePath + "/coordinator", common.SidecarGateCommand, "go"}, workload.Command)
+	assert.Equal(suite.T(), []string{"test", "./..."}, workload.Args)
+	assert.Contains(suite.T(), coordinator.Env, corev1.EnvVar{Name: common.EnvWorkloadContainerName, Value: "c1"})
+	assert.Contains(suite.T(), coordinator.VolumeMounts, corev1.VolumeMount{
+		Name:      common.SidecarGateVolumeName,
+		MountPath: common.CoordinatorSidecarGatePath,
+	})
+
+	// Workload without command can't wait sidecars ready.
+	builder = NewPodBuilder(suite.client, wf, wfr, "with-sidecar")
+	assert.Nil(suite.T(), builder.Prepare())
+	assert.Nil(suite.T(), builder.ResolveArguments())
+	assert.Nil(suite.T(), builder.AddCoordinator())
+	for i := range builder.pod.Spec.Containers {
+		if builder.pod.Spec.Containers[i].Name == "c1" {
+			builder.pod.Spec.Containers[i].Command = nil
+		}
+	}
+	assert.Error(suite.T(), builder.ResolveWorkloadSidecars())
+}
+
 func (suite *PodBuilderSuite) TestArtifactFileName() {
 	builder := NewPodBuilder(suite.client, wf, wfr, "stage2")
 	name, _ := builder.ArtifactFileName("stage1", "art1")
@@ -0,0 +1,143 @@
+package workflowrun
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/caicloud/cyclone/pkg/k8s/clientset"
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/workflow/common"
+)
+
+// DockerConfig is the content of docker config.json
+type DockerConfig struct {
+	Auths map[string]DockerAuth `json:"auths"`
+}
+
+// DockerAuth is auth information of a docker registry.
+type DockerAuth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Auth     string `json:"auth"`
+}
+
+// GetRegistryIntegrations gets all DockerRegistry integrations in the given namespace.
+func GetRegistryIntegrations(client clientset.Interface, namespace string) ([]*api.DockerRegistrySource, error) {
+	secrets, err := client.CoreV1().Secrets(namespace).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", common.IntegrationTypeLabelName, api.DockerRegistry),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var registries []*api.DockerRegistrySource
+	for _, secret := range secrets.Items {
+		integration := &api.Integration{}
+		if err := json.Unmarshal(secret.Data[common.IntegrationSecretKey], integration); err != nil {
+			log.WithField("secret", secret.Name).Warn("Unmarshal integration error: ", err)
+			continue
+		}
+		if integration.Spec.Type != api.DockerRegistry || integration.Spec.DockerRegistry == nil {
+			continue
+		}
+		registries = append(registries, integration.Spec.DockerRegistry)
+	}
+
+	return registries, nil
+}
+
+// BuildDockerConfig builds docker config.json from the given docker registries.
+func BuildDockerConfig(registries []*api.DockerRegistrySource) ([]byte, error) {
+	config := DockerConfig{
+		Auths: make(map[string]DockerAuth),
+	}
+	for _, r := range registries {
+		server := strings.TrimSuffix(r.Server, "/")
+		if server == "" {
+			continue
+		}
+		config.Auths[server] = DockerAuth{
+			Username: r.User,
+			Password: r.Password,
+			Auth:     base64.StdEncoding.EncodeToString([]byte(r.User + ":" + r.Password)),
+		}
+	}
+
+	return json.Marshal(config)
+}
+
+// EnsureRegistrySecret generates docker registry auth secret from DockerRegistry integrations
+// in the namespace, the secret will be created or updated to keep consistent with the integrations.
+// Name of the secret is returned, and if there is no DockerRegistry integrations in the namespace,
+// empty string will be returned.
+func EnsureRegistrySecret(client clientset.Interface, namespace string) (string, error) {
+	registries, err := GetRegistryIntegrations(client, namespace)
+	if err != nil {
+		log.WithField("ns", namespace).Error("Get DockerRegistry integrations error: ", err)
+		return "", err
+	}
+
+	// If there is no registries integrated, remove the secret generated before if any.
+	if len(registries) == 0 {
+		err := client.CoreV1().Secrets(namespace).Delete(common.RegistrySecretName, &metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			log.WithField("ns", namespace).Warn("Delete registry secret error: ", err)
+		}
+		return "", nil
+	}
+
+	data, err := BuildDockerConfig(registries)
+	if err != nil {
+		return "", err
+	}
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		origin, err := client.CoreV1().Secrets(namespace).Get(common.RegistrySecretName, metav1.GetOptions{})
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				return err
+			}
+
+			_, err = client.CoreV1().Secrets(namespace).Create(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      common.RegistrySecretName,
+					Namespace: namespace,
+					Labels: map[string]string{
+						common.WorkflowLabelName: "true",
+					},
+				},
+				Type: corev1.SecretTypeDockerConfigJson,
+				Data: map[string][]byte{
+					corev1.DockerConfigJsonKey: data,
+				},
+			})
+			return err
+		}
+
+		if reflect.DeepEqual(origin.Data[corev1.DockerConfigJsonKey], data) {
+			return nil
+		}
+
+		secret := origin.DeepCopy()
+		secret.Data = map[string][]byte{
+			corev1.DockerConfigJsonKey: data,
+		}
+		_, err = client.CoreV1().Secrets(namespace).Update(secret)
+		return err
+	})
+	if err != nil {
+		log.WithField("ns", namespace).Error("Ensure registry secret error: ", err)
+		return "", err
+	}
+
+	return common.RegistrySecretName, nil
+}
@@ -0,0 +1,104 @@
+package workflowrun
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/cyclone/pkg/k8s/clientset/fake"
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/workflow/common"
+)
+
+func registryIntegrationSecret(name, namespace, server, user, password string) *corev1.Secret {
+	data, _ := json.Marshal(&api.Integration{
+		Metadata: api.Metadata{
+			Name: name,
+		},
+		Spec: api.IntegrationSpec{
+			Type: api.DockerRegistry,
+			IntegrationSource: api.IntegrationSource{
+				DockerRegistry: &api.DockerRegistrySource{
+					Server:   server,
+					User:     user,
+					Password: password,
+				},
+			},
+		},
+	})
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				common.IntegrationTypeLabelName: string(api.DockerRegistry),
+			},
+		},
+		Data: map[string][]byte{
+			common.IntegrationSecretKey: data,
+		},
+	}
+}
+
+func TestBuildDockerConfig(t *testing.T) {
+	data, err := BuildDockerConfig([]*api.DockerRegistrySource{
+		{
+			Server:   "registry.io/",
+			User:     "user",
+			Password: "pwd",
+		},
+		{
+			Server: "",
+		},
+	})
+	assert.Nil(t, err)
+
+	config := &DockerConfig{}
+	assert.Nil(t, json.Unmarshal(data, config))
+	assert.Equal(t, 1, len(config.Auths))
+	assert.Equal(t, DockerAuth{
+		Username: "user",
+		Password: "pwd",
+		Auth:     "dXNlcjpwd2Q=",
+	}, config.Auths["registry.io"])
+}
+
+func TestEnsureRegistrySecret(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	secret, err := EnsureRegistrySecret(client, "ns")
+	assert.Nil(t, err)
+	assert.Equal(t, "", secret)
+
+	client = fake.NewSimpleClientset(
+		registryIntegrationSecret("r1", "ns", "r1.io", "u1", "p1"),
+		registryIntegrationSecret("r2", "another", "r2.io", "u2", "p2"),
+	)
+	secret, err = EnsureRegistrySecret(client, "ns")
+	assert.Nil(t, err)
+	assert.Equal(t, common.RegistrySecretName, secret)
+
+	s, err := client.CoreV1().Secrets("ns").Get(common.RegistrySecretName, metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, corev1.SecretTypeDockerConfigJson, s.Type)
+	config := &DockerConfig{}
+	assert.Nil(t, json.Unmarshal(s.Data[corev1.DockerConfigJsonKey], config))
+	assert.Contains(t, config.Auths, "r1.io")
+	assert.NotContains(t, config.Auths, "r2.io")
+
+	// Integration changed, secret should be updated.
+	assert.Nil(t, client.CoreV1().Secrets("ns").Delete("r1", &metav1.DeleteOptions{}))
+	_, err = client.CoreV1().Secrets("ns").Create(registryIntegrationSecret("r3", "ns", "r3.io", "u3", "p3"))
+	assert.Nil(t, err)
+	_, err = EnsureRegistrySecret(client, "ns")
+	assert.Nil(t, err)
+	s, err = client.CoreV1().Secrets("ns").Get(common.RegistrySecretName, metav1.GetOptions{})
+	assert.Nil(t, err)
+	config = &DockerConfig{}
+	assert.Nil(t, json.Unmarshal(s.Data[corev1.DockerConfigJsonKey], config))
+	assert.Contains(t, config.Auths, "r3.io")
+	assert.NotContains(t, config.Auths, "r1.io")
+}
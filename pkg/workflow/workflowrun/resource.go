@@ -0,0 +1,94 @@
+package workflowrun
+
+import (
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/util/registry"
+	gitresolver "github.com/caicloud/cyclone/pkg/workflow/resolver/git"
+	helmresolver "github.com/caicloud/cyclone/pkg/workflow/resolver/helm"
+	httpresolver "github.com/caicloud/cyclone/pkg/workflow/resolver/http"
+	imageresolver "github.com/caicloud/cyclone/pkg/workflow/resolver/image"
+	mavenresolver "github.com/caicloud/cyclone/pkg/workflow/resolver/maven"
+	s3resolver "github.com/caicloud/cyclone/pkg/workflow/resolver/s3"
+	svnresolver "github.com/caicloud/cyclone/pkg/workflow/resolver/svn"
+)
+
+// ResourceParameters gathers parameters of the resource from both the resource spec and the
+// WorkflowRun spec, parameters in WorkflowRun take precedence.
+func ResourceParameters(wfr *v1alpha1.WorkflowRun, resource *v1alpha1.Resource) map[string]string {
+	parameters := make(map[string]string)
+	for _, p := range resource.Spec.Parameters {
+		parameters[p.Name] = p.Value
+	}
+	for _, p := range wfr.Spec.Resources {
+		if p.Name == resource.Name {
+			for _, c := range p.Parameters {
+				parameters[c.Name] = c.Value
+			}
+		}
+	}
+
+	return parameters
+}
+
+// replayParameters gets parameters to pin the input resource to the version recorded in the
+// replayed WorkflowRun, nil is returned if the WorkflowRun is not a replay or nothing recorded.
+func replayParameters(wfr *v1alpha1.WorkflowRun, resource string) []v1alpha1.ParameterItem {
+	if wfr.Spec.Replay == "" {
+		return nil
+	}
+	if status, ok := wfr.Status.Resources[resource]; ok && status != nil {
+		return status.Parameters
+	}
+	return nil
+}
+
+// resourceStatus gets the resolved version of an input resource from outputs of its resolver,
+// together with parameters to pull exactly the version again. Parameters are empty for
+// resources that can't be pinned, for example, S3 objects in buckets without versioning.
+func resourceStatus(resource *v1alpha1.Resource, stage string, parameters, outputs map[string]string) *v1alpha1.ResourceStatus {
+	status := &v1alpha1.ResourceStatus{
+		Type:  resource.Spec.Type,
+		Stage: stage,
+	}
+
+	pin := func(revision, name, value string) {
+		status.Revision = revision
+		if revision != "" && name != "" {
+			status.Parameters = []v1alpha1.ParameterItem{{Name: name, Value: value}}
+		}
+	}
+
+	switch resource.Spec.Type {
+	case v1alpha1.GitResourceType:
+		commit := outputs[gitresolver.OutputCommit]
+		pin(commit, gitresolver.EnvRevision, commit)
+	case v1alpha1.SVNResourceType:
+		revision := outputs[svnresolver.OutputRevision]
+		pin(revision, svnresolver.EnvRevision, revision)
+	case v1alpha1.ImageResourceType:
+		digest := outputs[imageresolver.OutputDigest]
+		ref, err := registry.ParseReference(parameters[imageresolver.EnvImage])
+		if err != nil {
+			pin(digest, "", "")
+			break
+		}
+		pin(digest, imageresolver.EnvImage, ref.WithDigest(digest).String())
+	case v1alpha1.HTTPResourceType:
+		digest := outputs[httpresolver.OutputDigest]
+		pin(digest, httpresolver.EnvSHA256, digest)
+	case v1alpha1.S3ResourceType:
+		if version := outputs[s3resolver.OutputVersionID]; version != "" {
+			pin(version, s3resolver.EnvVersionID, version)
+		} else {
+			pin(outputs[s3resolver.OutputETag], "", "")
+		}
+	case v1alpha1.HelmResourceType:
+		version := outputs[helmresolver.OutputVersion]
+		pin(version, helmresolver.EnvVersion, version)
+	case v1alpha1.MavenResourceType:
+		version := outputs[mavenresolver.OutputVersion]
+		pin(version, mavenresolver.EnvVersion, version)
+	}
+
+	return status
+}
@@ -0,0 +1,166 @@
+package workflowrun
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/k8s/clientset/fake"
+)
+
+func TestResourceStatus(t *testing.T) {
+	cases := []struct {
+		resourceType v1alpha1.ResourceType
+		parameters   map[string]string
+		outputs      map[string]string
+		revision     string
+		pinned       []v1alpha1.ParameterItem
+	}{
+		{
+			resourceType: v1alpha1.GitResourceType,
+			outputs:      map[string]string{"commit": "0123abcd", "author": "dev"},
+			revision:     "0123abcd",
+			pinned:       []v1alpha1.ParameterItem{{Name: "GIT_REVISION", Value: "0123abcd"}},
+		},
+		{
+			resourceType: v1alpha1.SVNResourceType,
+			outputs:      map[string]string{"revision": "1024"},
+			revision:     "1024",
+			pinned:       []v1alpha1.ParameterItem{{Name: "SVN_REVISION", Value: "1024"}},
+		},
+		{
+			resourceType: v1alpha1.ImageResourceType,
+			parameters:   map[string]string{"IMAGE": "alpine:3.8"},
+			outputs:      map[string]string{"digest": "sha256:abcd"},
+			revision:     "sha256:abcd",
+			pinned:       []v1alpha1.ParameterItem{{Name: "IMAGE", Value: "docker.io/library/alpine@sha256:abcd"}},
+		},
+		{
+			resourceType: v1alpha1.HTTPResourceType,
+			outputs:      map[string]string{"digest": "sha256:abcd", "etag": "v1"},
+			revision:     "sha256:abcd",
+			pinned:       []v1alpha1.ParameterItem{{Name: "HTTP_SHA256", Value: "sha256:abcd"}},
+		},
+		{
+			resourceType: v1alpha1.S3ResourceType,
+			outputs:      map[string]string{"digest": "sha256:abcd", "etag": "v1", "versionId": "3"},
+			revision:     "3",
+			pinned:       []v1alpha1.ParameterItem{{Name: "S3_VERSION_ID", Value: "3"}},
+		},
+		{
+			resourceType: v1alpha1.S3ResourceType,
+			outputs:      map[string]string{"digest": "sha256:abcd", "etag": "v1"},
+			revision:     "v1",
+		},
+		{
+			resourceType: v1alpha1.MavenResourceType,
+			outputs:      map[string]string{"version": "1.0-20181010.123456-1"},
+			revision:     "1.0-20181010.123456-1",
+			pinned:       []v1alpha1.ParameterItem{{Name: "MAVEN_VERSION", Value: "1.0-20181010.123456-1"}},
+		},
+		{
+			resourceType: v1alpha1.GitResourceType,
+			outputs:      map[string]string{},
+		},
+		{
+			resourceType: v1alpha1.KVResourceType,
+			outputs:      map[string]string{"key": "value"},
+		},
+	}
+
+	for _, c := range cases {
+		resource := &v1alpha1.Resource{
+			Spec: v1alpha1.ResourceSpec{Type: c.resourceType},
+		}
+		status := resourceStatus(resource, "build", c.parameters, c.outputs)
+		assert.Equal(t, c.resourceType, status.Type)
+		assert.Equal(t, "build", status.Stage)
+		assert.Equal(t, c.revision, status.Revision)
+		assert.Equal(t, c.pinned, status.Parameters)
+	}
+}
+
+func TestUpdateResourceStatus(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1alpha1.Resource{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "src",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.ResourceSpec{Type: v1alpha1.GitResourceType},
+	})
+	o := &operator{
+		client: client,
+		wfr: &v1alpha1.WorkflowRun{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "default",
+			},
+		},
+	}
+
+	assert.Nil(t, o.UpdateResourceStatus("A", "src", map[string]string{"commit": "first"}))
+	assert.Nil(t, o.UpdateResourceStatus("B", "src", map[string]string{"commit": "second"}))
+	status := o.wfr.Status.Resources["src"]
+	assert.Equal(t, "first", status.Revision)
+	assert.Equal(t, "A", status.Stage)
+
+	assert.Error(t, o.UpdateResourceStatus("A", "missing", map[string]string{"commit": "first"}))
+}
+
+func TestLoadReplay(t *testing.T) {
+	origin := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "origin",
+			Namespace: "default",
+		},
+		Status: v1alpha1.WorkflowRunStatus{
+			Resources: map[string]*v1alpha1.ResourceStatus{
+				"src": {
+					Type:       v1alpha1.GitResourceType,
+					Revision:   "0123abcd",
+					Parameters: []v1alpha1.ParameterItem{{Name: "GIT_REVISION", Value: "0123abcd"}},
+					Stage:      "A",
+				},
+			},
+		},
+	}
+	replay := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "replay",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.WorkflowRunSpec{Replay: "origin"},
+	}
+	client := fake.NewSimpleClientset(origin, replay)
+	o := &operator{
+		client: client,
+		wfr:    replay,
+	}
+	assert.Nil(t, o.loadReplay())
+	assert.Equal(t, origin.Status.Resources, o.wfr.Status.Resources)
+	assert.Equal(t, origin.Status.Resources["src"].Parameters, replayParameters(o.wfr, "src"))
+	assert.Nil(t, replayParameters(origin, "src"))
+
+	recorder := new(MockedRecorder)
+	recorder.On("Event", mock.Anything).Return()
+	missing := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "missing",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.WorkflowRunSpec{Replay: "deleted"},
+	}
+	client = fake.NewSimpleClientset(missing)
+	o = &operator{
+		client:   client,
+		recorder: recorder,
+		wf:       &v1alpha1.Workflow{ObjectMeta: metav1.ObjectMeta{Name: "wf", Namespace: "default"}},
+		wfr:      missing,
+	}
+	assert.Error(t, o.loadReplay())
+	assert.Equal(t, v1alpha1.StatusError, o.wfr.Status.Overall.Status)
+	assert.Equal(t, "ReplaySourceNotFound", o.wfr.Status.Overall.Reason)
+}
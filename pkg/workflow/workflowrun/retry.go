@@ -0,0 +1,76 @@
+package workflowrun
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+)
+
+const (
+	// maxRetries is the max times to retry processing a WorkflowRun in retry queues.
+	maxRetries = 5
+	// minRetryDelay and maxRetryDelay limit the exponential backoff between retries.
+	minRetryDelay = 5 * time.Second
+	maxRetryDelay = 5 * time.Minute
+)
+
+// processFunc processes the WorkflowRun with the key, lastAttempt tells whether it's the last
+// attempt, so that it can give up and record the result. Processing is retried if error returned.
+type processFunc func(key string, lastAttempt bool) error
+
+// retryQueue processes WorkflowRuns in a background worker, so that slow external systems, such
+// as SCM or notification receivers, don't block the WorkflowRun controller. Failures are retried
+// with exponential backoff until maxRetries reached. WorkflowRuns are queued by key, and
+// processors get the latest WorkflowRun to process.
+type retryQueue struct {
+	queue   workqueue.RateLimitingInterface
+	process processFunc
+}
+
+func newRetryQueue(process processFunc) *retryQueue {
+	return &retryQueue{
+		queue:   workqueue.NewRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(minRetryDelay, maxRetryDelay)),
+		process: process,
+	}
+}
+
+// Add adds the WorkflowRun to the queue, it's processed only once if added multiple times before
+// processed.
+func (q *retryQueue) Add(wfr *v1alpha1.WorkflowRun) {
+	key, err := cache.MetaNamespaceKeyFunc(wfr)
+	if err != nil {
+		log.WithField("wfr", wfr.Name).Warn("Get key of WorkflowRun error: ", err)
+		return
+	}
+	q.queue.Add(key)
+}
+
+// run processes WorkflowRuns in the queue until the queue is shut down.
+func (q *retryQueue) run() {
+	for q.processNext() {
+	}
+}
+
+// processNext processes the next WorkflowRun in the queue, it blocks if the queue is empty, and
+// returns false if the queue is shut down.
+func (q *retryQueue) processNext() bool {
+	item, quit := q.queue.Get()
+	if quit {
+		return false
+	}
+	defer q.queue.Done(item)
+
+	key := item.(string)
+	retries := q.queue.NumRequeues(item)
+	if err := q.process(key, retries >= maxRetries); err != nil && retries < maxRetries {
+		log.WithField("key", key).WithField("retries", retries).Warn("Process WorkflowRun error, retry later: ", err)
+		q.queue.AddRateLimited(item)
+		return true
+	}
+	q.queue.Forget(item)
+	return true
+}
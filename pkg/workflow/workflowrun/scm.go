@@ -0,0 +1,88 @@
+package workflowrun
+
+import (
+	"fmt"
+	"reflect"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/caicloud/cyclone/pkg/k8s/clientset"
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/workflow/common"
+)
+
+// GetSCMIntegration gets the SCM integration with the given name in the namespace.
+func GetSCMIntegration(client clientset.Interface, namespace, name string) (*api.SCMSource, error) {
+	integration, err := GetIntegration(client, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if integration.Spec.Type != api.SCM || integration.Spec.SCM == nil {
+		return nil, fmt.Errorf("integration %s is not a SCM integration", name)
+	}
+
+	return integration.Spec.SCM, nil
+}
+
+// EnsureSCMSecret generates secret holding credentials of the SCM integration, the secret has
+// the token in key 'token', the SSH private key in key 'ssh-privatekey' and SSH known hosts in key
+// 'known_hosts'. Name of the secret and the SCM integration are returned.
+func EnsureSCMSecret(client clientset.Interface, namespace, integration string) (string, *api.SCMSource, error) {
+	scm, err := GetSCMIntegration(client, namespace, integration)
+	if err != nil {
+		log.WithField("ns", namespace).WithField("integration", integration).Error("Get SCM integration error: ", err)
+		return "", nil, err
+	}
+
+	data := make(map[string][]byte)
+	if scm.Token != "" {
+		data[common.SCMSecretTokenKey] = []byte(scm.Token)
+	}
+	if scm.SSHKey != "" {
+		data[corev1.SSHAuthPrivateKey] = []byte(scm.SSHKey)
+	}
+	if scm.KnownHosts != "" {
+		data[common.SCMSecretKnownHostsKey] = []byte(scm.KnownHosts)
+	}
+
+	name := common.SCMSecretPrefix + integration
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		origin, err := client.CoreV1().Secrets(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				return err
+			}
+
+			_, err = client.CoreV1().Secrets(namespace).Create(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespace,
+					Labels: map[string]string{
+						common.WorkflowLabelName: "true",
+					},
+				},
+				Data: data,
+			})
+			return err
+		}
+
+		if reflect.DeepEqual(origin.Data, data) {
+			return nil
+		}
+
+		secret := origin.DeepCopy()
+		secret.Data = data
+		_, err = client.CoreV1().Secrets(namespace).Update(secret)
+		return err
+	})
+	if err != nil {
+		log.WithField("ns", namespace).Error("Ensure SCM secret error: ", err)
+		return "", nil, err
+	}
+
+	return name, scm, nil
+}
@@ -0,0 +1,280 @@
+package workflowrun
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/k8s/clientset"
+	"github.com/caicloud/cyclone/pkg/server/biz/scm"
+	"github.com/caicloud/cyclone/pkg/workflow/common"
+	gitresolver "github.com/caicloud/cyclone/pkg/workflow/resolver/git"
+)
+
+// SCMReporter reports results of WorkflowRuns to SCM. Statuses are reported to commits of
+// WorkflowRuns triggered by webhooks with SCM integration, and commits of Git resources that
+// are pulled with SCM integrations. Results are also commented on pull requests if the trigger
+// requires it.
+type SCMReporter struct {
+	client   clientset.Interface
+	recorder record.EventRecorder
+	queue    *retryQueue
+	// reported records targets that have been reported for each WorkflowRun key, so that they are
+	// not reported again when other targets are retried. It's only accessed by the queue worker.
+	reported map[string]map[string]bool
+}
+
+// NewSCMReporter creates a SCM reporter, and starts the worker to report in background.
+func NewSCMReporter(client clientset.Interface) *SCMReporter {
+	reporter := newSCMReporter(client)
+	go reporter.queue.run()
+	return reporter
+}
+
+func newSCMReporter(client clientset.Interface) *SCMReporter {
+	reporter := &SCMReporter{
+		client:   client,
+		recorder: common.GetEventRecorder(client, common.EventSourceWfrController),
+		reported: make(map[string]map[string]bool),
+	}
+	reporter.queue = newRetryQueue(reporter.process)
+	return reporter
+}
+
+// scmTarget is a commit to report status to.
+type scmTarget struct {
+	integration string
+	repo        string
+	commit      string
+	// pullRequest is number of the pull request to comment on, 0 if no comment is needed.
+	pullRequest int
+}
+
+// Report queues the WorkflowRun to report its status to SCM if it's changed since last report.
+// Reports are sent in background, so that slow SCM servers don't block the controller.
+func (r *SCMReporter) Report(wfr *v1alpha1.WorkflowRun) {
+	key := reportKey(wfr, commitState(wfr.Status.Overall.Status))
+	if key == "" || wfr.Annotations[common.SCMReportedAnnotationName] == key {
+		return
+	}
+	r.queue.Add(wfr)
+}
+
+// process reports status of the latest WorkflowRun to SCM. Failed targets are retried, and
+// targets already reported are skipped in retries to avoid duplicate pull request comments.
+// The reported status is recorded in annotation of the WorkflowRun after all targets are
+// reported or retries are exhausted, so that it's not reported again after controller restarts.
+func (r *SCMReporter) process(key string, lastAttempt bool) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return nil
+	}
+	wfr, err := r.client.CycloneV1alpha1().WorkflowRuns(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			delete(r.reported, key)
+			return nil
+		}
+		return err
+	}
+
+	state := commitState(wfr.Status.Overall.Status)
+	stateKey := reportKey(wfr, state)
+	if stateKey == "" || wfr.Annotations[common.SCMReportedAnnotationName] == stateKey {
+		delete(r.reported, key)
+		return nil
+	}
+
+	if r.reported[key] == nil {
+		r.reported[key] = make(map[string]bool)
+	}
+	var failed bool
+	for _, t := range r.targets(wfr) {
+		id := fmt.Sprintf("%s|%s@%s", stateKey, t.repo, t.commit)
+		if r.reported[key][id] {
+			continue
+		}
+		if err := r.report(wfr, t, state); err != nil {
+			failed = true
+			log.WithField("wfr", wfr.Name).WithField("repo", t.repo).WithField("commit", t.commit).Warn("Report status to SCM error: ", err)
+			if lastAttempt {
+				r.recorder.Eventf(wfr, corev1.EventTypeWarning, "SCMReportError", "Report status to %s@%s error: %v", t.repo, t.commit, err)
+			}
+			continue
+		}
+		r.reported[key][id] = true
+		r.recorder.Eventf(wfr, corev1.EventTypeNormal, "SCMReported", "Status '%s' reported to %s@%s", state, t.repo, t.commit)
+	}
+	if failed && !lastAttempt {
+		return fmt.Errorf("report status of %s to SCM failed", key)
+	}
+
+	// Give up failed reports after retries, otherwise SCM would be flooded if credentials are wrong.
+	if err := setAnnotation(r.client, wfr, common.SCMReportedAnnotationName, stateKey); err != nil {
+		log.WithField("wfr", wfr.Name).Warn("Record reported SCM status error: ", err)
+		return err
+	}
+	delete(r.reported, key)
+	return nil
+}
+
+// report reports status to the target commit, and comments on the pull request when the
+// WorkflowRun is terminated.
+func (r *SCMReporter) report(wfr *v1alpha1.WorkflowRun, t *scmTarget, state scm.State) error {
+	source, err := GetSCMIntegration(r.client, wfr.Namespace, t.integration)
+	if err != nil {
+		return err
+	}
+	provider, err := scm.NewProvider(source)
+	if err != nil {
+		return err
+	}
+
+	url := runURL(wfr)
+	err = provider.CreateStatus(t.repo, t.commit, &scm.CommitStatus{
+		State:       state,
+		Context:     "cyclone/" + workflowName(wfr),
+		TargetURL:   url,
+		Description: fmt.Sprintf("WorkflowRun %s %s", wfr.Name, stateDescription(state)),
+	})
+	if err != nil {
+		return err
+	}
+
+	if t.pullRequest > 0 && state != scm.StatePending {
+		return provider.CreateComment(t.repo, t.pullRequest, summary(wfr, state, url))
+	}
+	return nil
+}
+
+// targets gets commits to report status to. Commit of the triggering event comes first, then
+// resolved commits of Git resources that have SCM integration.
+func (r *SCMReporter) targets(wfr *v1alpha1.WorkflowRun) []*scmTarget {
+	var targets []*scmTarget
+	exists := func(repo, commit string) bool {
+		for _, t := range targets {
+			if strings.EqualFold(t.repo, repo) && t.commit == commit {
+				return true
+			}
+		}
+		return false
+	}
+
+	annotations := wfr.Annotations
+	if integration := annotations[common.SCMIntegrationAnnotationName]; integration != "" &&
+		annotations[common.SCMRepoAnnotationName] != "" && annotations[common.SCMCommitAnnotationName] != "" {
+		t := &scmTarget{
+			integration: integration,
+			repo:        annotations[common.SCMRepoAnnotationName],
+			commit:      annotations[common.SCMCommitAnnotationName],
+		}
+		if annotations[common.SCMCommentAnnotationName] == "true" {
+			t.pullRequest, _ = strconv.Atoi(annotations[common.SCMPullRequestAnnotationName])
+		}
+		targets = append(targets, t)
+	}
+
+	for _, name := range resolvedGitResources(wfr) {
+		resource, err := r.client.CycloneV1alpha1().Resources(wfr.Namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			log.WithField("wfr", wfr.Name).WithField("resource", name).Warn("Get resource error: ", err)
+			continue
+		}
+		parameters := ResourceParameters(wfr, resource)
+		integration := parameters[gitresolver.EnvSCMIntegration]
+		repo := scm.Repository(parameters[gitresolver.EnvURL])
+		commit := wfr.Status.Resources[name].Revision
+		if integration == "" || repo == "" || exists(repo, commit) {
+			continue
+		}
+		targets = append(targets, &scmTarget{integration: integration, repo: repo, commit: commit})
+	}
+
+	return targets
+}
+
+// resolvedGitResources gets sorted names of Git resources whose commits have been resolved.
+func resolvedGitResources(wfr *v1alpha1.WorkflowRun) []string {
+	var names []string
+	for name, status := range wfr.Status.Resources {
+		if status != nil && status.Type == v1alpha1.GitResourceType && status.Revision != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// reportKey identifies a report by the state and commits to report to. Empty key is returned
+// if there is nothing to report.
+func reportKey(wfr *v1alpha1.WorkflowRun, state scm.State) string {
+	resources := resolvedGitResources(wfr)
+	if state == "" || (wfr.Annotations[common.SCMIntegrationAnnotationName] == "" && len(resources) == 0) {
+		return ""
+	}
+	return fmt.Sprintf("%s:%s", state, strings.Join(resources, ","))
+}
+
+// commitState maps overall status of WorkflowRun to state of commit status.
+func commitState(status string) scm.State {
+	switch status {
+	case "", v1alpha1.StatusPending, v1alpha1.StatusRunning, v1alpha1.StatusWaiting:
+		return scm.StatePending
+	case v1alpha1.StatusCompleted:
+		return scm.StateSuccess
+	case v1alpha1.StatusError:
+		return scm.StateFailure
+	case v1alpha1.StatusCancelled:
+		return scm.StateError
+	}
+	return ""
+}
+
+func stateDescription(state scm.State) string {
+	switch state {
+	case scm.StateSuccess:
+		return "succeeded"
+	case scm.StateFailure:
+		return "failed"
+	case scm.StateError:
+		return "cancelled"
+	}
+	return "is running"
+}
+
+// summary generates markdown comment of the WorkflowRun result with statuses of all stages.
+func summary(wfr *v1alpha1.WorkflowRun, state scm.State, url string) string {
+	var b bytes.Buffer
+	name := "`" + wfr.Name + "`"
+	if url != "" {
+		name = fmt.Sprintf("[%s](%s)", name, url)
+	}
+	fmt.Fprintf(&b, "Cyclone WorkflowRun %s of workflow `%s` %s.\n", name, workflowName(wfr), stateDescription(state))
+	if msg := wfr.Status.Overall.Message; msg != "" && state != scm.StateSuccess {
+		fmt.Fprintf(&b, "\n> %s\n", msg)
+	}
+
+	var stages []string
+	for stage := range wfr.Status.Stages {
+		stages = append(stages, stage)
+	}
+	if len(stages) == 0 {
+		return b.String()
+	}
+	sort.Strings(stages)
+	b.WriteString("\n| Stage | Status |\n| --- | --- |\n")
+	for _, stage := range stages {
+		fmt.Fprintf(&b, "| %s | %s |\n", stage, wfr.Status.Stages[stage].Status.Status)
+	}
+	return b.String()
+}
@@ -0,0 +1,169 @@
+package workflowrun
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/k8s/clientset/fake"
+	api "github.com/caicloud/cyclone/pkg/server/apis/v1alpha1"
+	"github.com/caicloud/cyclone/pkg/workflow/common"
+	"github.com/caicloud/cyclone/pkg/workflow/controller"
+	gitresolver "github.com/caicloud/cyclone/pkg/workflow/resolver/git"
+)
+
+func TestSCMReporter(t *testing.T) {
+	var requests []string
+	var bodies []map[string]string
+	var failing string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.EscapedPath())
+		if failing != "" && strings.Contains(r.URL.Path, failing) {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body := make(map[string]string)
+		data, _ := ioutil.ReadAll(r.Body)
+		assert.Nil(t, json.Unmarshal(data, &body))
+		bodies = append(bodies, body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer s.Close()
+
+	controller.Config.RunURL = "https://cyclone.example.com/{tenant}/{project}/{workflow}/{workflowrun}"
+	defer func() { controller.Config.RunURL = "" }()
+
+	wfr := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "wfr",
+			Namespace: "cyclone--t1",
+			Labels:    map[string]string{"cyclone.io/project": "p1"},
+			Annotations: map[string]string{
+				common.SCMIntegrationAnnotationName: "github",
+				common.SCMRepoAnnotationName:        "caicloud/cyclone",
+				common.SCMCommitAnnotationName:      "abc",
+				common.SCMPullRequestAnnotationName: "12",
+				common.SCMCommentAnnotationName:     "true",
+			},
+		},
+		Spec: v1alpha1.WorkflowRunSpec{
+			WorkflowRef: &corev1.ObjectReference{Name: "wf"},
+		},
+	}
+	client := fake.NewSimpleClientset(
+		wfr,
+		integrationSecret("cyclone--t1", &api.Integration{
+			Metadata: api.Metadata{Name: "github"},
+			Spec: api.IntegrationSpec{
+				Type:              api.SCM,
+				IntegrationSource: api.IntegrationSource{SCM: &api.SCMSource{Type: api.GitHub, Server: s.URL, Token: "t0ken"}},
+			},
+		}),
+		&v1alpha1.Resource{
+			ObjectMeta: metav1.ObjectMeta{Name: "src", Namespace: "cyclone--t1"},
+			Spec: v1alpha1.ResourceSpec{
+				Type: v1alpha1.GitResourceType,
+				Parameters: []v1alpha1.ParameterItem{
+					{Name: gitresolver.EnvURL, Value: "https://github.com/caicloud/cyclone.git"},
+					{Name: gitresolver.EnvSCMIntegration, Value: "github"},
+				},
+			},
+		},
+		&v1alpha1.Resource{
+			ObjectMeta: metav1.ObjectMeta{Name: "lib", Namespace: "cyclone--t1"},
+			Spec: v1alpha1.ResourceSpec{
+				Type: v1alpha1.GitResourceType,
+				Parameters: []v1alpha1.ParameterItem{
+					{Name: gitresolver.EnvURL, Value: "git@github.com:caicloud/lib.git"},
+					{Name: gitresolver.EnvSCMIntegration, Value: "github"},
+				},
+			},
+		},
+	)
+	reporter := newSCMReporter(client)
+	report := func(wfr *v1alpha1.WorkflowRun) *v1alpha1.WorkflowRun {
+		wfr, err := client.CycloneV1alpha1().WorkflowRuns(wfr.Namespace).Update(wfr)
+		assert.Nil(t, err)
+		reporter.Report(wfr)
+		for reporter.queue.queue.Len() > 0 {
+			reporter.queue.processNext()
+		}
+		latest, err := client.CycloneV1alpha1().WorkflowRuns(wfr.Namespace).Get(wfr.Name, metav1.GetOptions{})
+		assert.Nil(t, err)
+		return latest
+	}
+
+	wfr = report(wfr)
+	assert.Equal(t, []string{"/api/v3/repos/caicloud/cyclone/statuses/abc"}, requests)
+	assert.Equal(t, map[string]string{
+		"state":       "pending",
+		"context":     "cyclone/wf",
+		"target_url":  "https://cyclone.example.com/t1/p1/wf/wfr",
+		"description": "WorkflowRun wfr is running",
+	}, bodies[0])
+	assert.Equal(t, "pending:", wfr.Annotations[common.SCMReportedAnnotationName])
+
+	// Nothing changed, no report.
+	wfr.Status.Overall.Status = v1alpha1.StatusRunning
+	wfr = report(wfr)
+	assert.Equal(t, 1, len(requests))
+
+	// Commits of Git resources resolved, commit of the event is not reported again.
+	wfr.Status.Resources = map[string]*v1alpha1.ResourceStatus{
+		"src": {Type: v1alpha1.GitResourceType, Revision: "abc"},
+		"lib": {Type: v1alpha1.GitResourceType, Revision: "def"},
+	}
+	wfr = report(wfr)
+	assert.Equal(t, []string{
+		"/api/v3/repos/caicloud/cyclone/statuses/abc",
+		"/api/v3/repos/caicloud/cyclone/statuses/abc",
+		"/api/v3/repos/caicloud/lib/statuses/def",
+	}, requests)
+
+	// Finished, results are commented on the pull request. Report to lib fails and is retried,
+	// the pull request is not commented again.
+	requests = nil
+	failing = "lib"
+	wfr.Status.Overall.Status = v1alpha1.StatusError
+	wfr.Status.Stages = map[string]*v1alpha1.StageStatus{
+		"build": {Status: v1alpha1.Status{Status: v1alpha1.StatusError}},
+	}
+	wfr = report(wfr)
+	assert.Equal(t, []string{
+		"/api/v3/repos/caicloud/cyclone/statuses/abc",
+		"/api/v3/repos/caicloud/cyclone/issues/12/comments",
+		"/api/v3/repos/caicloud/lib/statuses/def",
+	}, requests)
+	assert.Equal(t, "pending:lib,src", wfr.Annotations[common.SCMReportedAnnotationName])
+	assert.Equal(t, 1, reporter.queue.queue.NumRequeues("cyclone--t1/wfr"))
+
+	failing = ""
+	assert.Nil(t, reporter.process("cyclone--t1/wfr", false))
+	wfr, err := client.CycloneV1alpha1().WorkflowRuns(wfr.Namespace).Get(wfr.Name, metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"/api/v3/repos/caicloud/cyclone/statuses/abc",
+		"/api/v3/repos/caicloud/cyclone/issues/12/comments",
+		"/api/v3/repos/caicloud/lib/statuses/def",
+		"/api/v3/repos/caicloud/lib/statuses/def",
+	}, requests)
+	assert.Equal(t, "failure", bodies[3]["state"])
+	assert.Equal(t, "Cyclone WorkflowRun [`wfr`](https://cyclone.example.com/t1/p1/wf/wfr) of workflow `wf` failed.\n\n"+
+		"| Stage | Status |\n| --- | --- |\n| build | Error |\n", bodies[4]["body"])
+	assert.Equal(t, "failure:lib,src", wfr.Annotations[common.SCMReportedAnnotationName])
+}
+
+func TestCommitState(t *testing.T) {
+	assert.Equal(t, "pending", string(commitState(v1alpha1.StatusWaiting)))
+	assert.Equal(t, "success", string(commitState(v1alpha1.StatusCompleted)))
+	assert.Equal(t, "failure", string(commitState(v1alpha1.StatusError)))
+	assert.Equal(t, "error", string(commitState(v1alpha1.StatusCancelled)))
+}
@@ -3,12 +3,17 @@ package workflowrun
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
 
 	"github.com/caicloud/cyclone/pkg/apis/cyclone/v1alpha1"
 	"github.com/caicloud/cyclone/pkg/k8s/clientset"
+	servercommon "github.com/caicloud/cyclone/pkg/server/common"
+	"github.com/caicloud/cyclone/pkg/workflow/common"
+	"github.com/caicloud/cyclone/pkg/workflow/controller"
 )
 
 // GetResourceVolumeName generates a volume name for a resource.
@@ -126,3 +131,40 @@ func ensureOwner(client clientset.Interface, wf *v1alpha1.Workflow, wfr *v1alpha
 
 	return nil
 }
+
+// workflowName gets name of the Workflow that the WorkflowRun runs.
+func workflowName(wfr *v1alpha1.WorkflowRun) string {
+	if wfr.Spec.WorkflowRef != nil && wfr.Spec.WorkflowRef.Name != "" {
+		return wfr.Spec.WorkflowRef.Name
+	}
+	return wfr.Labels[common.WorkflowRunLabelName]
+}
+
+// runURL gets URL of the WorkflowRun from the configured template.
+func runURL(wfr *v1alpha1.WorkflowRun) string {
+	if controller.Config.RunURL == "" {
+		return ""
+	}
+	return strings.NewReplacer(
+		"{tenant}", servercommon.NamespaceTenant(wfr.Namespace),
+		"{project}", wfr.Labels[servercommon.LabelProject],
+		"{workflow}", workflowName(wfr),
+		"{workflowrun}", wfr.Name,
+	).Replace(controller.Config.RunURL)
+}
+
+// setAnnotation sets annotation of the WorkflowRun with retry on conflicts.
+func setAnnotation(client clientset.Interface, wfr *v1alpha1.WorkflowRun, key, value string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest, err := client.CycloneV1alpha1().WorkflowRuns(wfr.Namespace).Get(wfr.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if latest.Annotations == nil {
+			latest.Annotations = make(map[string]string)
+		}
+		latest.Annotations[key] = value
+		_, err = client.CycloneV1alpha1().WorkflowRuns(wfr.Namespace).Update(latest)
+		return err
+	})
+}